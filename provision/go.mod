@@ -1,17 +1,24 @@
 module github.com/kyma-incubator/hydroform/provision
 
-go 1.14
+go 1.16
 
 replace github.com/terraform-providers/terraform-provider-openstack => github.com/terraform-providers/terraform-provider-openstack v1.20.0
 
 require (
+	github.com/Azure/azure-sdk-for-go v45.0.0+incompatible
+	github.com/Azure/go-autorest/autorest v0.11.3
+	github.com/Azure/go-autorest/autorest/adal v0.9.0
 	github.com/Azure/go-ntlmssp v0.0.0-20200615164410-66371956d46c // indirect
 	github.com/ChrisTrenkamp/goxpath v0.0.0-20190607011252-c5096ec8773d // indirect
 	github.com/apparentlymart/go-cidr v1.1.0 // indirect
+	github.com/aws/aws-sdk-go v1.31.9
 	github.com/gofrs/uuid v3.3.0+incompatible // indirect
 	github.com/hashicorp/aws-sdk-go-base v0.6.0 // indirect
 	github.com/hashicorp/go-azure-helpers v0.12.0 // indirect
-	github.com/hashicorp/hcl/v2 v2.6.0 // indirect
+	github.com/hashicorp/go-hclog v0.0.0-20181001195459-61d530d6c27f
+	github.com/hashicorp/go-plugin v1.3.0
+	github.com/hashicorp/go-version v1.2.0
+	github.com/hashicorp/hcl/v2 v2.6.0
 	github.com/hashicorp/terraform v0.12.30
 	github.com/hashicorp/terraform-svchost v0.0.0-20200729002733-f050f53b9734
 	github.com/imdario/mergo v0.3.9 // indirect
@@ -22,10 +29,16 @@ require (
 	github.com/packer-community/winrmcp v0.0.0-20180921211025-c76d91c1e7db // indirect
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.6.1
-	github.com/zclconf/go-cty v1.5.1 // indirect
+	github.com/zclconf/go-cty v1.5.1
 	github.com/zclconf/go-cty-yaml v1.0.2 // indirect
-	golang.org/x/net v0.0.0-20200602114024-627f9648deb9 // indirect
+	golang.org/x/net v0.0.0-20200602114024-627f9648deb9
+	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
+	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd
+	google.golang.org/api v0.9.0
+	gopkg.in/yaml.v2 v2.2.8
+	k8s.io/api v0.18.9
 	k8s.io/apimachinery v0.18.9
 	k8s.io/client-go v0.18.9
 	k8s.io/utils v0.0.0-20200411171748-3d5a2fe318e4 // indirect
+	sigs.k8s.io/yaml v1.2.0
 )
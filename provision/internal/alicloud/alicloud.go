@@ -0,0 +1,198 @@
+package alicloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/internal/errs"
+	"github.com/kyma-incubator/hydroform/provision/internal/operator"
+	terraform_operator "github.com/kyma-incubator/hydroform/provision/internal/operator/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// alicloudProvisioner implements Provisioner
+type alicloudProvisioner struct {
+	provisionOperator operator.Operator
+}
+
+// Provision requests provisioning of a new Kubernetes cluster on Alibaba Cloud Container Service for Kubernetes with the given configurations.
+func (a *alicloudProvisioner) Provision(cluster *types.Cluster, provider *types.Provider) (*types.Cluster, error) {
+	if err := a.validateInputs(cluster, provider); err != nil {
+		return cluster, err
+	}
+
+	config, err := a.loadConfigurations(cluster, provider)
+	if err != nil {
+		return cluster, err
+	}
+
+	clusterInfo, err := a.provisionOperator.Create(provider.Type, config)
+	if clusterInfo != nil {
+		cluster.ClusterInfo = clusterInfo
+	}
+	if err != nil {
+		return cluster, errors.Wrap(err, "unable to provision alicloud cluster")
+	}
+	return cluster, nil
+}
+
+// Status returns the ClusterStatus for the requested cluster.
+func (a *alicloudProvisioner) Status(cluster *types.Cluster, p *types.Provider) (*types.ClusterStatus, error) {
+	var state *statefile.File
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.InternalState != nil {
+		state = cluster.ClusterInfo.InternalState.TerraformState
+	}
+
+	if err := a.validateInputs(cluster, p); err != nil {
+		return nil, err
+	}
+
+	cfg, err := a.loadConfigurations(cluster, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.provisionOperator.Status(state, p.Type, cfg)
+}
+
+// Credentials returns the Kubeconfig file as a byte array for the requested cluster.
+func (a *alicloudProvisioner) Credentials(cluster *types.Cluster, p *types.Provider) ([]byte, error) {
+	if err := a.validateInputs(cluster, p); err != nil {
+		return nil, err
+	}
+	if cluster.ClusterInfo == nil || cluster.ClusterInfo.Kubeconfig == "" {
+		return nil, errors.New(errs.EmptyClusterInfo)
+	}
+
+	return []byte(cluster.ClusterInfo.Kubeconfig), nil
+}
+
+// Deprovision requests deprovisioning of an existing cluster on Alibaba Cloud Container Service for Kubernetes with the given configurations.
+func (a *alicloudProvisioner) Deprovision(cluster *types.Cluster, p *types.Provider) error {
+	if err := a.validateInputs(cluster, p); err != nil {
+		return err
+	}
+
+	config, err := a.loadConfigurations(cluster, p)
+	if err != nil {
+		return err
+	}
+
+	var state *statefile.File
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.InternalState != nil {
+		state = cluster.ClusterInfo.InternalState.TerraformState
+	}
+
+	if err = a.provisionOperator.Delete(state, p.Type, config); err != nil {
+		return errors.Wrap(err, "unable to deprovision alicloud cluster")
+	}
+
+	return nil
+}
+
+// New creates a new instance of alicloudProvisioner.
+func New(operatorType operator.Type, ops ...types.Option) *alicloudProvisioner {
+	// parse config
+	os := &types.Options{}
+	for _, o := range ops {
+		o(os)
+	}
+
+	var op operator.Operator
+	switch operatorType {
+	case operator.TerraformOperator:
+		tfOps := terraform_operator.ToTerraformOptions(os)
+		op = terraform_operator.New(tfOps...)
+	default:
+		op = &operator.Unknown{}
+	}
+
+	return &alicloudProvisioner{
+		provisionOperator: op,
+	}
+}
+
+func (a *alicloudProvisioner) validateInputs(cluster *types.Cluster, provider *types.Provider) error {
+	var errMessage string
+	if cluster.NodeCount < 1 {
+		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.NodeCount", 1)
+	}
+	if cluster.DiskSizeGB < 0 {
+		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.DiskSizeGB", 0)
+	}
+	// Matches the regex for an ACK cluster name.
+	if match, _ := regexp.MatchString(`^[a-zA-Z0-9\p{Han}][-_a-zA-Z0-9\p{Han}]{0,62}$`, cluster.Name); !match {
+		errMessage += fmt.Sprintf(errs.Custom, "Cluster.Name must start with a letter, a digit or a Chinese character followed by up to 62 letters, "+
+			"digits, Chinese characters, hyphens or underscores")
+	}
+	if cluster.Location == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.Location")
+	}
+	if cluster.MachineType == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.MachineType")
+	}
+	if cluster.KubernetesVersion == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.KubernetesVersion")
+	}
+
+	if provider.ProjectName == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Provider.ProjectName")
+	}
+
+	if errMessage != "" {
+		return errors.New("input validation failed with the following information: " + errMessage)
+	}
+
+	return nil
+}
+
+func (a *alicloudProvisioner) loadConfigurations(cluster *types.Cluster, provider *types.Provider) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	config["cluster_name"] = cluster.Name
+	config["node_count"] = cluster.NodeCount
+	config["instance_types"] = []string{cluster.MachineType}
+	config["disk_size"] = cluster.DiskSizeGB
+	config["kubernetes_version"] = cluster.KubernetesVersion
+	config["region"] = cluster.Location
+	config["project"] = provider.ProjectName
+
+	if provider.CredentialsFilePath != "" {
+		keys, err := alicloudKeys(provider.CredentialsFilePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error loading credentials")
+		}
+		config["access_key"] = keys.AccessKey
+		config["secret_key"] = keys.SecretKey
+	}
+
+	for k, v := range provider.CustomConfigurations {
+		config[k] = v
+	}
+	return config, nil
+}
+
+// accessKey is the content of an Alibaba Cloud credentials file.
+type accessKey struct {
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// alicloudKeys reads the Alibaba Cloud access key from a JSON credentials file with the access_key and secret_key fields.
+func alicloudKeys(path string) (*accessKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := &accessKey{}
+	if err := json.Unmarshal(data, keys); err != nil {
+		return nil, errors.Wrap(err, "the credentials file must contain the access_key and secret_key in JSON")
+	}
+	if keys.AccessKey == "" || keys.SecretKey == "" {
+		return nil, errors.New("the credentials file must contain the access_key and secret_key in JSON")
+	}
+	return keys, nil
+}
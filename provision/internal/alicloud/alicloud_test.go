@@ -0,0 +1,193 @@
+package alicloud
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/internal/operator/mocks"
+	"github.com/pkg/errors"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func testCluster() *types.Cluster {
+	return &types.Cluster{
+		KubernetesVersion: "1.18.8-aliyun.1",
+		Name:              "hydro-cluster",
+		NodeCount:         2,
+		DiskSizeGB:        40,
+		Location:          "cn-hangzhou",
+		MachineType:       "ecs.g6.large",
+	}
+}
+
+func testProvider() *types.Provider {
+	return &types.Provider{
+		Type:        types.AliCloud,
+		ProjectName: "my-project",
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	t.Parallel()
+	a := &alicloudProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	require.NoError(t, a.validateInputs(cluster, provider), "Validation should pass")
+
+	cluster.NodeCount = 0
+	require.Error(t, a.validateInputs(cluster, provider), "Validation should fail when number of nodes is < 1")
+	cluster.NodeCount = 2
+
+	cluster.Name = ""
+	require.Error(t, a.validateInputs(cluster, provider), "Validation should fail when cluster name is empty")
+	cluster.Name = "-hydro-cluster"
+	require.Error(t, a.validateInputs(cluster, provider), "Validation should fail when cluster name starts with a hyphen")
+	cluster.Name = "hydro-cluster"
+
+	cluster.DiskSizeGB = -1
+	require.Error(t, a.validateInputs(cluster, provider), "Validation should fail when disk size is < 0")
+	cluster.DiskSizeGB = 40
+
+	cluster.Location = ""
+	require.Error(t, a.validateInputs(cluster, provider), "Validation should fail when cluster location is empty")
+	cluster.Location = "cn-hangzhou"
+
+	cluster.MachineType = ""
+	require.Error(t, a.validateInputs(cluster, provider), "Validation should fail when cluster machine type is empty")
+	cluster.MachineType = "ecs.g6.large"
+
+	cluster.KubernetesVersion = ""
+	require.Error(t, a.validateInputs(cluster, provider), "Validation should fail when Kubernetes version is empty")
+	cluster.KubernetesVersion = "1.18.8-aliyun.1"
+
+	provider.ProjectName = ""
+	require.Error(t, a.validateInputs(cluster, provider), "Validation should fail when project name is empty")
+}
+
+func TestLoadConfigurations(t *testing.T) {
+	t.Parallel()
+	a := &alicloudProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+	provider.CustomConfigurations = map[string]interface{}{"vswitch_id": "vsw-123"}
+
+	config, err := a.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+
+	require.Equal(t, cluster.Name, config["cluster_name"])
+	require.Equal(t, cluster.NodeCount, config["node_count"])
+	require.Equal(t, []string{cluster.MachineType}, config["instance_types"])
+	require.Equal(t, cluster.DiskSizeGB, config["disk_size"])
+	require.Equal(t, cluster.KubernetesVersion, config["kubernetes_version"])
+	require.Equal(t, cluster.Location, config["region"])
+	require.Equal(t, provider.ProjectName, config["project"])
+	require.NotContains(t, config, "access_key", "Without credentials file the keys should be taken from the environment")
+
+	for k, v := range provider.CustomConfigurations {
+		require.Equal(t, v, config[k], fmt.Sprintf("Custom config %s is incorrect", k))
+	}
+
+	// credentials file
+	f, err := ioutil.TempFile("", "alicloud-keys")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"access_key": "my-key", "secret_key": "my-secret"}`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	provider.CredentialsFilePath = f.Name()
+	config, err = a.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+	require.Equal(t, "my-key", config["access_key"])
+	require.Equal(t, "my-secret", config["secret_key"])
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`{"access_key": "my-key"}`), 0600))
+	_, err = a.loadConfigurations(cluster, provider)
+	require.Error(t, err, "Credentials without secret should fail")
+
+	provider.CredentialsFilePath = "/wrong/credentials/path"
+	_, err = a.loadConfigurations(cluster, provider)
+	require.Error(t, err)
+}
+
+func TestCredentials(t *testing.T) {
+	t.Parallel()
+	a := &alicloudProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	_, err := a.Credentials(cluster, provider)
+	require.Error(t, err, "Credentials should fail without cluster info")
+
+	cluster.ClusterInfo = &types.ClusterInfo{Kubeconfig: "apiVersion: v1"}
+	kubeconfig, err := a.Credentials(cluster, provider)
+	require.NoError(t, err)
+	require.Equal(t, []byte("apiVersion: v1"), kubeconfig, "Credentials should return the kubeconfig of the cluster info")
+}
+
+func TestProvision(t *testing.T) {
+	t.Parallel()
+	mockOp := &mocks.Operator{}
+	a := alicloudProvisioner{
+		provisionOperator: mockOp,
+	}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	result := &types.ClusterInfo{
+		CertificateAuthorityData: []byte("My cert"),
+		Endpoint:                 "https://cluster-url.fake",
+		Status: &types.ClusterStatus{
+			Phase: types.Provisioned,
+		},
+	}
+	config, err := a.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+	mockOp.On("Create", types.AliCloud, config).Return(result, nil)
+
+	cluster, err = a.Provision(cluster, provider)
+	require.NoError(t, err, "Provision should succeed")
+	require.Equal(t, result, cluster.ClusterInfo, "The cluster info returned from the operator should be in the cluster returned by Provision")
+
+	badCluster := &types.Cluster{}
+	_, err = a.Provision(badCluster, provider)
+	require.Error(t, err, "Provision should fail")
+}
+
+func TestDeprovision(t *testing.T) {
+	t.Parallel()
+	mockOp := &mocks.Operator{}
+	a := alicloudProvisioner{
+		provisionOperator: mockOp,
+	}
+
+	cluster := testCluster()
+	cluster.ClusterInfo = &types.ClusterInfo{}
+	provider := testProvider()
+
+	var state *statefile.File
+	config, err := a.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+	mockOp.On("Delete", state, types.AliCloud, config).Return(nil)
+
+	err = a.Deprovision(cluster, provider)
+	require.NoError(t, err, "Deprovision should succeed")
+
+	provider.CustomConfigurations = map[string]interface{}{"access_key": "wrong-key"}
+	config, err = a.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+	mockOp.On("Delete", state, types.AliCloud, config).Return(errors.New("Unable to deprovision cluster"))
+
+	err = a.Deprovision(cluster, provider)
+	require.Error(t, err, "Deprovision should fail")
+}
@@ -0,0 +1,158 @@
+package aws
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/internal/errs"
+	"github.com/kyma-incubator/hydroform/provision/internal/operator"
+	terraform_operator "github.com/kyma-incubator/hydroform/provision/internal/operator/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// awsProvisioner implements Provisioner
+type awsProvisioner struct {
+	provisionOperator operator.Operator
+}
+
+// Provision requests provisioning of a new Kubernetes cluster on AWS EKS with the given configurations.
+func (a *awsProvisioner) Provision(cluster *types.Cluster, provider *types.Provider) (*types.Cluster, error) {
+	if err := a.validateInputs(cluster, provider); err != nil {
+		return cluster, err
+	}
+
+	config := a.loadConfigurations(cluster, provider)
+
+	clusterInfo, err := a.provisionOperator.Create(provider.Type, config)
+	if clusterInfo != nil {
+		cluster.ClusterInfo = clusterInfo
+	}
+	if err != nil {
+		return cluster, errors.Wrap(err, "unable to provision aws cluster")
+	}
+	return cluster, nil
+}
+
+// Status returns the ClusterStatus for the requested cluster.
+func (a *awsProvisioner) Status(cluster *types.Cluster, p *types.Provider) (*types.ClusterStatus, error) {
+	var state *statefile.File
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.InternalState != nil {
+		state = cluster.ClusterInfo.InternalState.TerraformState
+	}
+
+	if err := a.validateInputs(cluster, p); err != nil {
+		return nil, err
+	}
+
+	cfg := a.loadConfigurations(cluster, p)
+
+	return a.provisionOperator.Status(state, p.Type, cfg)
+}
+
+// Credentials returns the Kubeconfig file as a byte array for the requested cluster.
+// The kubeconfig authenticates using the AWS CLI, which needs to be installed and configured with the same credentials.
+func (a *awsProvisioner) Credentials(cluster *types.Cluster, p *types.Provider) ([]byte, error) {
+	if err := a.validateInputs(cluster, p); err != nil {
+		return nil, err
+	}
+	if cluster.ClusterInfo == nil || cluster.ClusterInfo.Kubeconfig == "" {
+		return nil, errors.New(errs.EmptyClusterInfo)
+	}
+
+	return []byte(cluster.ClusterInfo.Kubeconfig), nil
+}
+
+// Deprovision requests deprovisioning of an existing cluster on AWS EKS with the given configurations.
+func (a *awsProvisioner) Deprovision(cluster *types.Cluster, p *types.Provider) error {
+	if err := a.validateInputs(cluster, p); err != nil {
+		return err
+	}
+
+	config := a.loadConfigurations(cluster, p)
+
+	var state *statefile.File
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.InternalState != nil {
+		state = cluster.ClusterInfo.InternalState.TerraformState
+	}
+
+	err := a.provisionOperator.Delete(state, p.Type, config)
+	if err != nil {
+		return errors.Wrap(err, "unable to deprovision aws cluster")
+	}
+
+	return nil
+}
+
+// New creates a new instance of awsProvisioner.
+func New(operatorType operator.Type, ops ...types.Option) *awsProvisioner {
+	// parse config
+	os := &types.Options{}
+	for _, o := range ops {
+		o(os)
+	}
+
+	var op operator.Operator
+	switch operatorType {
+	case operator.TerraformOperator:
+		tfOps := terraform_operator.ToTerraformOptions(os)
+		op = terraform_operator.New(tfOps...)
+	default:
+		op = &operator.Unknown{}
+	}
+
+	return &awsProvisioner{
+		provisionOperator: op,
+	}
+}
+
+func (a *awsProvisioner) validateInputs(cluster *types.Cluster, provider *types.Provider) error {
+	var errMessage string
+	if cluster.NodeCount < 1 {
+		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.NodeCount", 1)
+	}
+	// Matches the regex for an EKS cluster name.
+	if match, _ := regexp.MatchString(`^[0-9A-Za-z][A-Za-z0-9\-_]{0,99}$`, cluster.Name); !match {
+		errMessage += fmt.Sprintf(errs.Custom, "Cluster.Name must start with a letter or number followed by up to 99 letters, "+
+			"numbers, hyphens or underscores")
+	}
+	if cluster.Location == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.Location")
+	}
+	if cluster.MachineType == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.MachineType")
+	}
+	if cluster.KubernetesVersion == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.KubernetesVersion")
+	}
+	if cluster.DiskSizeGB < 0 {
+		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.DiskSizeGB", 0)
+	}
+
+	if provider.ProjectName == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Provider.ProjectName")
+	}
+
+	if errMessage != "" {
+		return errors.New("input validation failed with the following information: " + errMessage)
+	}
+
+	return nil
+}
+
+func (a *awsProvisioner) loadConfigurations(cluster *types.Cluster, provider *types.Provider) map[string]interface{} {
+	config := map[string]interface{}{}
+	config["cluster_name"] = cluster.Name
+	config["node_count"] = cluster.NodeCount
+	config["machine_type"] = cluster.MachineType
+	config["disk_size"] = cluster.DiskSizeGB
+	config["kubernetes_version"] = cluster.KubernetesVersion
+	config["region"] = cluster.Location
+	config["project"] = provider.ProjectName
+	config["credentials_file_path"] = provider.CredentialsFilePath
+	for k, v := range provider.CustomConfigurations {
+		config[k] = v
+	}
+	return config
+}
@@ -18,6 +18,8 @@ import (
 // azureProvisioner implements Provisioner
 type azureProvisioner struct {
 	provisionOperator operator.Operator
+	// credentials are the in-memory credentials given with the options, if any
+	credentials *types.Credentials
 }
 
 // Provision requests provisioning of a new Kubernetes cluster on Azure with the given configurations.
@@ -32,11 +34,12 @@ func (a *azureProvisioner) Provision(cluster *types.Cluster, provider *types.Pro
 	}
 
 	clusterInfo, err := a.provisionOperator.Create(provider.Type, config)
+	if clusterInfo != nil {
+		cluster.ClusterInfo = clusterInfo
+	}
 	if err != nil {
 		return cluster, errors.Wrap(err, "unable to provision azure cluster")
 	}
-
-	cluster.ClusterInfo = clusterInfo
 	return cluster, nil
 }
 
@@ -114,8 +117,14 @@ func New(operatorType operator.Type, ops ...types.Option) *azureProvisioner {
 		op = &operator.Unknown{}
 	}
 
+	var creds *types.Credentials
+	if c, ok := os.Credentials[types.Azure]; ok {
+		creds = &c
+	}
+
 	return &azureProvisioner{
 		provisionOperator: op,
+		credentials:       creds,
 	}
 }
 
@@ -142,7 +151,7 @@ func (a *azureProvisioner) validateInputs(cluster *types.Cluster, provider *type
 		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.DiskSizeGB", 0)
 	}
 
-	if provider.CredentialsFilePath == "" {
+	if provider.CredentialsFilePath == "" && a.credentials == nil {
 		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Provider.CredentialsFilePath")
 	}
 
@@ -164,10 +173,13 @@ func (a *azureProvisioner) loadConfigurations(cluster *types.Cluster, provider *
 	config["project"] = provider.ProjectName
 	config["resource_group"] = provider.ProjectName
 
-	var err error
-	config["subscription_id"], config["tenant_id"], config["client_id"], config["client_secret"], err = azureCredentials(provider.CredentialsFilePath)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error loading credentials")
+	// in-memory credentials are set by the operator
+	if provider.CredentialsFilePath != "" || a.credentials == nil {
+		var err error
+		config["subscription_id"], config["tenant_id"], config["client_id"], config["client_secret"], err = azureCredentials(provider.CredentialsFilePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error loading credentials")
+		}
 	}
 
 	for k, v := range provider.CustomConfigurations {
@@ -144,6 +144,13 @@ func TestLoadConfigurations(t *testing.T) {
 	provider.CredentialsFilePath = "/wrong/credentials/path"
 	_, err = g.loadConfigurations(cluster, provider)
 	require.Error(t, err)
+
+	// in-memory credentials
+	provider.CredentialsFilePath = ""
+	g.credentials = &types.Credentials{}
+	config, err = g.loadConfigurations(cluster, provider)
+	require.NoError(t, err, "Credentials should not be loaded from a file when they are in memory")
+	require.NotContains(t, config, "client_secret")
 }
 
 func fakeCredentials(file string) error {
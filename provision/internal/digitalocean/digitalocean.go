@@ -0,0 +1,180 @@
+package digitalocean
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/internal/errs"
+	"github.com/kyma-incubator/hydroform/provision/internal/operator"
+	terraform_operator "github.com/kyma-incubator/hydroform/provision/internal/operator/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// digitaloceanProvisioner implements Provisioner
+type digitaloceanProvisioner struct {
+	provisionOperator operator.Operator
+}
+
+// Provision requests provisioning of a new Kubernetes cluster on DigitalOcean Kubernetes with the given configurations.
+func (d *digitaloceanProvisioner) Provision(cluster *types.Cluster, provider *types.Provider) (*types.Cluster, error) {
+	if err := d.validateInputs(cluster, provider); err != nil {
+		return cluster, err
+	}
+
+	config, err := d.loadConfigurations(cluster, provider)
+	if err != nil {
+		return cluster, err
+	}
+
+	clusterInfo, err := d.provisionOperator.Create(provider.Type, config)
+	if clusterInfo != nil {
+		cluster.ClusterInfo = clusterInfo
+	}
+	if err != nil {
+		return cluster, errors.Wrap(err, "unable to provision digitalocean cluster")
+	}
+	return cluster, nil
+}
+
+// Status returns the ClusterStatus for the requested cluster.
+func (d *digitaloceanProvisioner) Status(cluster *types.Cluster, p *types.Provider) (*types.ClusterStatus, error) {
+	var state *statefile.File
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.InternalState != nil {
+		state = cluster.ClusterInfo.InternalState.TerraformState
+	}
+
+	if err := d.validateInputs(cluster, p); err != nil {
+		return nil, err
+	}
+
+	cfg, err := d.loadConfigurations(cluster, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.provisionOperator.Status(state, p.Type, cfg)
+}
+
+// Credentials returns the Kubeconfig file as a byte array for the requested cluster.
+func (d *digitaloceanProvisioner) Credentials(cluster *types.Cluster, p *types.Provider) ([]byte, error) {
+	if err := d.validateInputs(cluster, p); err != nil {
+		return nil, err
+	}
+	if cluster.ClusterInfo == nil || cluster.ClusterInfo.Kubeconfig == "" {
+		return nil, errors.New(errs.EmptyClusterInfo)
+	}
+
+	return []byte(cluster.ClusterInfo.Kubeconfig), nil
+}
+
+// Deprovision requests deprovisioning of an existing cluster on DigitalOcean Kubernetes with the given configurations.
+func (d *digitaloceanProvisioner) Deprovision(cluster *types.Cluster, p *types.Provider) error {
+	if err := d.validateInputs(cluster, p); err != nil {
+		return err
+	}
+
+	config, err := d.loadConfigurations(cluster, p)
+	if err != nil {
+		return err
+	}
+
+	var state *statefile.File
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.InternalState != nil {
+		state = cluster.ClusterInfo.InternalState.TerraformState
+	}
+
+	if err = d.provisionOperator.Delete(state, p.Type, config); err != nil {
+		return errors.Wrap(err, "unable to deprovision digitalocean cluster")
+	}
+
+	return nil
+}
+
+// New creates a new instance of digitaloceanProvisioner.
+func New(operatorType operator.Type, ops ...types.Option) *digitaloceanProvisioner {
+	// parse config
+	os := &types.Options{}
+	for _, o := range ops {
+		o(os)
+	}
+
+	var op operator.Operator
+	switch operatorType {
+	case operator.TerraformOperator:
+		tfOps := terraform_operator.ToTerraformOptions(os)
+		op = terraform_operator.New(tfOps...)
+	default:
+		op = &operator.Unknown{}
+	}
+
+	return &digitaloceanProvisioner{
+		provisionOperator: op,
+	}
+}
+
+func (d *digitaloceanProvisioner) validateInputs(cluster *types.Cluster, provider *types.Provider) error {
+	var errMessage string
+	if cluster.NodeCount < 1 {
+		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.NodeCount", 1)
+	}
+	// Matches the regex for a DigitalOcean Kubernetes cluster name.
+	if match, _ := regexp.MatchString(`^(?:[a-z](?:[-a-z0-9]{0,61}[a-z0-9])?)$`, cluster.Name); !match {
+		errMessage += fmt.Sprintf(errs.Custom, "Cluster.Name must start with a lowercase letter followed by up to 62 lowercase letters, "+
+			"numbers, or hyphens, and cannot end with a hyphen")
+	}
+	if cluster.Location == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.Location")
+	}
+	if cluster.MachineType == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.MachineType")
+	}
+	if cluster.KubernetesVersion == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.KubernetesVersion")
+	}
+
+	if provider.ProjectName == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Provider.ProjectName")
+	}
+
+	if errMessage != "" {
+		return errors.New("input validation failed with the following information: " + errMessage)
+	}
+
+	return nil
+}
+
+func (d *digitaloceanProvisioner) loadConfigurations(cluster *types.Cluster, provider *types.Provider) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	config["cluster_name"] = cluster.Name
+	config["node_count"] = cluster.NodeCount
+	config["node_size"] = cluster.MachineType
+	config["kubernetes_version"] = cluster.KubernetesVersion
+	config["region"] = cluster.Location
+	config["project"] = provider.ProjectName
+
+	if provider.CredentialsFilePath != "" {
+		token, err := digitaloceanToken(provider.CredentialsFilePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error loading credentials")
+		}
+		config["token"] = token
+	}
+
+	for k, v := range provider.CustomConfigurations {
+		config[k] = v
+	}
+	return config, nil
+}
+
+// digitaloceanToken reads the DigitalOcean API token from a credentials file containing only the token.
+func digitaloceanToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,182 @@
+package digitalocean
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/internal/operator/mocks"
+	"github.com/pkg/errors"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func testCluster() *types.Cluster {
+	return &types.Cluster{
+		KubernetesVersion: "1.18.8-do.0",
+		Name:              "hydro-cluster",
+		NodeCount:         2,
+		Location:          "fra1",
+		MachineType:       "s-2vcpu-4gb",
+	}
+}
+
+func testProvider() *types.Provider {
+	return &types.Provider{
+		Type:        types.DigitalOcean,
+		ProjectName: "my-project",
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	t.Parallel()
+	d := &digitaloceanProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	require.NoError(t, d.validateInputs(cluster, provider), "Validation should pass")
+
+	cluster.NodeCount = 0
+	require.Error(t, d.validateInputs(cluster, provider), "Validation should fail when number of nodes is < 1")
+	cluster.NodeCount = 2
+
+	cluster.Name = ""
+	require.Error(t, d.validateInputs(cluster, provider), "Validation should fail when cluster name is empty")
+	cluster.Name = "Hydro_cluster"
+	require.Error(t, d.validateInputs(cluster, provider), "Validation should fail when cluster name has uppercase letters or underscores")
+	cluster.Name = "hydro-cluster"
+
+	cluster.Location = ""
+	require.Error(t, d.validateInputs(cluster, provider), "Validation should fail when cluster location is empty")
+	cluster.Location = "fra1"
+
+	cluster.MachineType = ""
+	require.Error(t, d.validateInputs(cluster, provider), "Validation should fail when cluster machine type is empty")
+	cluster.MachineType = "s-2vcpu-4gb"
+
+	cluster.KubernetesVersion = ""
+	require.Error(t, d.validateInputs(cluster, provider), "Validation should fail when Kubernetes version is empty")
+	cluster.KubernetesVersion = "1.18.8-do.0"
+
+	provider.ProjectName = ""
+	require.Error(t, d.validateInputs(cluster, provider), "Validation should fail when project name is empty")
+}
+
+func TestLoadConfigurations(t *testing.T) {
+	t.Parallel()
+	d := &digitaloceanProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+	provider.CustomConfigurations = map[string]interface{}{"auto_upgrade": true}
+
+	config, err := d.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+
+	require.Equal(t, cluster.Name, config["cluster_name"])
+	require.Equal(t, cluster.NodeCount, config["node_count"])
+	require.Equal(t, cluster.MachineType, config["node_size"])
+	require.Equal(t, cluster.KubernetesVersion, config["kubernetes_version"])
+	require.Equal(t, cluster.Location, config["region"])
+	require.Equal(t, provider.ProjectName, config["project"])
+	require.NotContains(t, config, "token", "Without credentials file the token should be taken from the environment")
+
+	for k, v := range provider.CustomConfigurations {
+		require.Equal(t, v, config[k], fmt.Sprintf("Custom config %s is incorrect", k))
+	}
+
+	// token file
+	f, err := ioutil.TempFile("", "do-token")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("my-token\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	provider.CredentialsFilePath = f.Name()
+	config, err = d.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+	require.Equal(t, "my-token", config["token"])
+
+	provider.CredentialsFilePath = "/wrong/credentials/path"
+	_, err = d.loadConfigurations(cluster, provider)
+	require.Error(t, err)
+}
+
+func TestCredentials(t *testing.T) {
+	t.Parallel()
+	d := &digitaloceanProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	_, err := d.Credentials(cluster, provider)
+	require.Error(t, err, "Credentials should fail without cluster info")
+
+	cluster.ClusterInfo = &types.ClusterInfo{Kubeconfig: "apiVersion: v1"}
+	kubeconfig, err := d.Credentials(cluster, provider)
+	require.NoError(t, err)
+	require.Equal(t, []byte("apiVersion: v1"), kubeconfig, "Credentials should return the kubeconfig of the cluster info")
+}
+
+func TestProvision(t *testing.T) {
+	t.Parallel()
+	mockOp := &mocks.Operator{}
+	d := digitaloceanProvisioner{
+		provisionOperator: mockOp,
+	}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	result := &types.ClusterInfo{
+		CertificateAuthorityData: []byte("My cert"),
+		Endpoint:                 "https://cluster-url.fake",
+		Status: &types.ClusterStatus{
+			Phase: types.Provisioned,
+		},
+	}
+	config, err := d.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+	mockOp.On("Create", types.DigitalOcean, config).Return(result, nil)
+
+	cluster, err = d.Provision(cluster, provider)
+	require.NoError(t, err, "Provision should succeed")
+	require.Equal(t, result, cluster.ClusterInfo, "The cluster info returned from the operator should be in the cluster returned by Provision")
+
+	badCluster := &types.Cluster{}
+	_, err = d.Provision(badCluster, provider)
+	require.Error(t, err, "Provision should fail")
+}
+
+func TestDeprovision(t *testing.T) {
+	t.Parallel()
+	mockOp := &mocks.Operator{}
+	d := digitaloceanProvisioner{
+		provisionOperator: mockOp,
+	}
+
+	cluster := testCluster()
+	cluster.ClusterInfo = &types.ClusterInfo{}
+	provider := testProvider()
+
+	var state *statefile.File
+	config, err := d.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+	mockOp.On("Delete", state, types.DigitalOcean, config).Return(nil)
+
+	err = d.Deprovision(cluster, provider)
+	require.NoError(t, err, "Deprovision should succeed")
+
+	provider.CustomConfigurations = map[string]interface{}{"token": "wrong-token"}
+	config, err = d.loadConfigurations(cluster, provider)
+	require.NoError(t, err)
+	mockOp.On("Delete", state, types.DigitalOcean, config).Return(errors.New("Unable to deprovision cluster"))
+
+	err = d.Deprovision(cluster, provider)
+	require.Error(t, err, "Deprovision should fail")
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 const (
@@ -26,6 +27,8 @@ const (
 
 type gardenerProvisioner struct {
 	operator operator.Operator
+	// credentials are the in-memory credentials given with the options, if any
+	credentials *types.Credentials
 }
 
 func New(operatorType operator.Type, ops ...types.Option) *gardenerProvisioner {
@@ -43,8 +46,14 @@ func New(operatorType operator.Type, ops ...types.Option) *gardenerProvisioner {
 	default:
 		op = &operator.Unknown{}
 	}
+
+	var creds *types.Credentials
+	if c, ok := os.Credentials[types.Gardener]; ok {
+		creds = &c
+	}
 	return &gardenerProvisioner{
-		operator: op,
+		operator:    op,
+		credentials: creds,
 	}
 }
 
@@ -56,10 +65,12 @@ func (g *gardenerProvisioner) Provision(cluster *types.Cluster, provider *types.
 	config := g.loadConfigurations(cluster, provider)
 
 	clusterInfo, err := g.operator.Create(provider.Type, config)
+	if clusterInfo != nil {
+		cluster.ClusterInfo = clusterInfo
+	}
 	if err != nil {
 		return cluster, errors.Wrap(err, "unable to provision gardener cluster")
 	}
-	cluster.ClusterInfo = clusterInfo
 	return cluster, nil
 }
 
@@ -84,7 +95,13 @@ func (g *gardenerProvisioner) Credentials(cluster *types.Cluster, provider *type
 		return nil, err
 	}
 
-	config, err := clientcmd.BuildConfigFromFlags("", provider.CredentialsFilePath)
+	var config *rest.Config
+	var err error
+	if provider.CredentialsFilePath == "" && g.credentials != nil {
+		config, err = clientcmd.RESTConfigFromKubeConfig(g.credentials.File)
+	} else {
+		config, err = clientcmd.BuildConfigFromFlags("", provider.CredentialsFilePath)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -148,7 +165,7 @@ func (g *gardenerProvisioner) validate(cluster *types.Cluster, provider *types.P
 	}
 
 	// Provider
-	if provider.CredentialsFilePath == "" {
+	if provider.CredentialsFilePath == "" && g.credentials == nil {
 		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Provider.CredentialsFilePath")
 	}
 	if provider.ProjectName == "" {
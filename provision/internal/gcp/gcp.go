@@ -18,6 +18,8 @@ import (
 // gcpProvisioner implements Provisioner
 type gcpProvisioner struct {
 	provisionOperator operator.Operator
+	// credentials are the in-memory credentials given with the options, if any
+	credentials *types.Credentials
 }
 
 // Provision requests provisioning of a new Kubernetes cluster on GCP with the given configurations.
@@ -29,11 +31,12 @@ func (g *gcpProvisioner) Provision(cluster *types.Cluster, provider *types.Provi
 	config := g.loadConfigurations(cluster, provider)
 
 	clusterInfo, err := g.provisionOperator.Create(provider.Type, config)
+	if clusterInfo != nil {
+		cluster.ClusterInfo = clusterInfo
+	}
 	if err != nil {
 		return cluster, errors.Wrap(err, "unable to provision gcp cluster")
 	}
-
-	cluster.ClusterInfo = clusterInfo
 	return cluster, nil
 }
 
@@ -125,8 +128,14 @@ func New(operatorType operator.Type, ops ...types.Option) *gcpProvisioner {
 		op = &operator.Unknown{}
 	}
 
+	var creds *types.Credentials
+	if c, ok := os.Credentials[types.GCP]; ok {
+		creds = &c
+	}
+
 	return &gcpProvisioner{
 		provisionOperator: op,
+		credentials:       creds,
 	}
 }
 
@@ -146,14 +155,15 @@ func (g *gcpProvisioner) validateInputs(cluster *types.Cluster, provider *types.
 	if cluster.MachineType == "" {
 		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.MachineType")
 	}
-	if cluster.KubernetesVersion == "" {
+	// a release channel picks the version of the cluster
+	if _, ok := provider.CustomConfigurations["release_channel"]; cluster.KubernetesVersion == "" && !ok {
 		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.KubernetesVersion")
 	}
 	if cluster.DiskSizeGB < 0 {
 		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.DiskSizeGB", 0)
 	}
 
-	if provider.CredentialsFilePath == "" {
+	if provider.CredentialsFilePath == "" && g.credentials == nil {
 		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Provider.CredentialsFilePath")
 	}
 	if provider.ProjectName == "" {
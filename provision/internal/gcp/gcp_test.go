@@ -40,6 +40,12 @@ func TestValidateInputs(t *testing.T) {
 
 	require.NoError(t, g.validateInputs(cluster, provider), "Validation should pass")
 
+	provider.CredentialsFilePath = ""
+	g.credentials = &types.Credentials{File: []byte("key")}
+	require.NoError(t, g.validateInputs(cluster, provider), "Validation should pass without credentials file path when credentials are in memory")
+	g.credentials = nil
+	provider.CredentialsFilePath = "/path/to/credentials"
+
 	cluster.NodeCount = -5
 	require.Error(t, g.validateInputs(cluster, provider), "Validation should fail when number of nodes is < 1")
 	cluster.NodeCount = 2
@@ -185,6 +191,20 @@ func TestProvision(t *testing.T) {
 
 	_, err = g.Provision(badCluster, provider)
 	require.Error(t, err, "Provision should fail")
+
+	// the state of a partially created cluster is kept
+	failedCluster := *cluster
+	failedCluster.Name = "failed-cluster"
+	failedCluster.ClusterInfo = nil
+	partial := &types.ClusterInfo{
+		Status:        &types.ClusterStatus{Phase: types.Errored},
+		InternalState: &types.InternalState{},
+	}
+	mockOp.On("Create", types.GCP, g.loadConfigurations(&failedCluster, provider)).Return(partial, errors.New("Unable to provision cluster"))
+
+	failed, err := g.Provision(&failedCluster, provider)
+	require.Error(t, err, "Provision should fail")
+	require.Equal(t, partial, failed.ClusterInfo, "The partial cluster info should be in the cluster returned by Provision")
 }
 
 func TestDeprovision(t *testing.T) {
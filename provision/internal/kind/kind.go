@@ -29,11 +29,12 @@ func (k *kindProvisioner) Provision(cluster *types.Cluster, p *types.Provider) (
 	config := k.loadConfigurations(cluster, p)
 
 	clusterInfo, err := k.provisionOperator.Create(p.Type, config)
+	if clusterInfo != nil {
+		cluster.ClusterInfo = clusterInfo
+	}
 	if err != nil {
 		return cluster, errors.Wrap(err, "unable to provision kind cluster")
 	}
-
-	cluster.ClusterInfo = clusterInfo
 	return cluster, nil
 }
 
@@ -0,0 +1,164 @@
+package openstack
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/internal/errs"
+	"github.com/kyma-incubator/hydroform/provision/internal/operator"
+	terraform_operator "github.com/kyma-incubator/hydroform/provision/internal/operator/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// openstackProvisioner implements Provisioner
+type openstackProvisioner struct {
+	provisionOperator operator.Operator
+}
+
+// Provision requests provisioning of a new Kubernetes cluster on OpenStack Magnum with the given configurations.
+func (o *openstackProvisioner) Provision(cluster *types.Cluster, provider *types.Provider) (*types.Cluster, error) {
+	if err := o.validateInputs(cluster, provider); err != nil {
+		return cluster, err
+	}
+
+	config := o.loadConfigurations(cluster, provider)
+
+	clusterInfo, err := o.provisionOperator.Create(provider.Type, config)
+	if clusterInfo != nil {
+		cluster.ClusterInfo = clusterInfo
+	}
+	if err != nil {
+		return cluster, errors.Wrap(err, "unable to provision openstack cluster")
+	}
+	return cluster, nil
+}
+
+// Status returns the ClusterStatus for the requested cluster.
+func (o *openstackProvisioner) Status(cluster *types.Cluster, p *types.Provider) (*types.ClusterStatus, error) {
+	var state *statefile.File
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.InternalState != nil {
+		state = cluster.ClusterInfo.InternalState.TerraformState
+	}
+
+	if err := o.validateInputs(cluster, p); err != nil {
+		return nil, err
+	}
+
+	cfg := o.loadConfigurations(cluster, p)
+
+	return o.provisionOperator.Status(state, p.Type, cfg)
+}
+
+// Credentials returns the Kubeconfig file as a byte array for the requested cluster.
+func (o *openstackProvisioner) Credentials(cluster *types.Cluster, p *types.Provider) ([]byte, error) {
+	if err := o.validateInputs(cluster, p); err != nil {
+		return nil, err
+	}
+	if cluster.ClusterInfo == nil || cluster.ClusterInfo.Kubeconfig == "" {
+		return nil, errors.New(errs.EmptyClusterInfo)
+	}
+
+	return []byte(cluster.ClusterInfo.Kubeconfig), nil
+}
+
+// Deprovision requests deprovisioning of an existing cluster on OpenStack Magnum with the given configurations.
+func (o *openstackProvisioner) Deprovision(cluster *types.Cluster, p *types.Provider) error {
+	if err := o.validateInputs(cluster, p); err != nil {
+		return err
+	}
+
+	config := o.loadConfigurations(cluster, p)
+
+	var state *statefile.File
+	if cluster.ClusterInfo != nil && cluster.ClusterInfo.InternalState != nil {
+		state = cluster.ClusterInfo.InternalState.TerraformState
+	}
+
+	err := o.provisionOperator.Delete(state, p.Type, config)
+	if err != nil {
+		return errors.Wrap(err, "unable to deprovision openstack cluster")
+	}
+
+	return nil
+}
+
+// New creates a new instance of openstackProvisioner.
+func New(operatorType operator.Type, ops ...types.Option) *openstackProvisioner {
+	// parse config
+	os := &types.Options{}
+	for _, o := range ops {
+		o(os)
+	}
+
+	var op operator.Operator
+	switch operatorType {
+	case operator.TerraformOperator:
+		tfOps := terraform_operator.ToTerraformOptions(os)
+		op = terraform_operator.New(tfOps...)
+	default:
+		op = &operator.Unknown{}
+	}
+
+	return &openstackProvisioner{
+		provisionOperator: op,
+	}
+}
+
+func (o *openstackProvisioner) validateInputs(cluster *types.Cluster, provider *types.Provider) error {
+	var errMessage string
+	if cluster.NodeCount < 1 {
+		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.NodeCount", 1)
+	}
+	// Matches the regex for a Magnum cluster name.
+	if match, _ := regexp.MatchString(`^[a-zA-Z][a-zA-Z0-9_.\-]{0,241}$`, cluster.Name); !match {
+		errMessage += fmt.Sprintf(errs.Custom, "Cluster.Name must start with a letter followed by up to 241 letters, "+
+			"numbers, periods, hyphens or underscores")
+	}
+	if cluster.Location == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.Location")
+	}
+	if cluster.MachineType == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.MachineType")
+	}
+	if cluster.KubernetesVersion == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Cluster.KubernetesVersion")
+	}
+	if cluster.DiskSizeGB < 0 {
+		errMessage += fmt.Sprintf(errs.CannotBeLess, "Cluster.DiskSizeGB", 0)
+	}
+
+	if provider.ProjectName == "" {
+		errMessage += fmt.Sprintf(errs.CannotBeEmpty, "Provider.ProjectName")
+	}
+
+	// Custom openstack configuration
+	for _, c := range []string{"auth_url", "network", "image"} {
+		if _, ok := provider.CustomConfigurations[c]; !ok {
+			errMessage += fmt.Sprintf(errs.CannotBeEmpty, fmt.Sprintf("Provider.CustomConfigurations['%s']", c))
+		}
+	}
+
+	if errMessage != "" {
+		return errors.New("input validation failed with the following information: " + errMessage)
+	}
+
+	return nil
+}
+
+func (o *openstackProvisioner) loadConfigurations(cluster *types.Cluster, provider *types.Provider) map[string]interface{} {
+	config := map[string]interface{}{}
+	config["cluster_name"] = cluster.Name
+	config["node_count"] = cluster.NodeCount
+	config["flavor"] = cluster.MachineType
+	config["disk_size"] = cluster.DiskSizeGB
+	config["kubernetes_version"] = cluster.KubernetesVersion
+	config["region"] = cluster.Location
+	config["project"] = provider.ProjectName
+	config["tenant_name"] = provider.ProjectName
+	for k, v := range provider.CustomConfigurations {
+		config[k] = v
+	}
+	return config
+}
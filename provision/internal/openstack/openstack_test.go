@@ -0,0 +1,175 @@
+package openstack
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/internal/operator/mocks"
+	"github.com/pkg/errors"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func testCluster() *types.Cluster {
+	return &types.Cluster{
+		KubernetesVersion: "v1.18.2",
+		Name:              "hydro-cluster",
+		DiskSizeGB:        30,
+		NodeCount:         2,
+		Location:          "RegionOne",
+		MachineType:       "m1.large",
+	}
+}
+
+func testProvider() *types.Provider {
+	return &types.Provider{
+		Type:        types.OpenStack,
+		ProjectName: "my-project",
+		CustomConfigurations: map[string]interface{}{
+			"auth_url": "https://keystone.example.com:5000/v3",
+			"network":  "public",
+			"image":    "fedora-coreos-32",
+		},
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	t.Parallel()
+	o := &openstackProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	require.NoError(t, o.validateInputs(cluster, provider), "Validation should pass")
+
+	cluster.NodeCount = 0
+	require.Error(t, o.validateInputs(cluster, provider), "Validation should fail when number of nodes is < 1")
+	cluster.NodeCount = 2
+
+	cluster.Name = ""
+	require.Error(t, o.validateInputs(cluster, provider), "Validation should fail when cluster name is empty")
+	cluster.Name = "1-invalid-start"
+	require.Error(t, o.validateInputs(cluster, provider), "Validation should fail when cluster name does not start with a letter")
+	cluster.Name = "hydro-cluster"
+
+	cluster.Location = ""
+	require.Error(t, o.validateInputs(cluster, provider), "Validation should fail when cluster location is empty")
+	cluster.Location = "RegionOne"
+
+	cluster.MachineType = ""
+	require.Error(t, o.validateInputs(cluster, provider), "Validation should fail when cluster machine type is empty")
+	cluster.MachineType = "m1.large"
+
+	cluster.KubernetesVersion = ""
+	require.Error(t, o.validateInputs(cluster, provider), "Validation should fail when Kubernetes version is empty")
+	cluster.KubernetesVersion = "v1.18.2"
+
+	cluster.DiskSizeGB = -1
+	require.Error(t, o.validateInputs(cluster, provider), "Validation should fail when disk size is less than 0")
+	cluster.DiskSizeGB = 30
+
+	provider.ProjectName = ""
+	require.Error(t, o.validateInputs(cluster, provider), "Validation should fail when project name is empty")
+	provider.ProjectName = "my-project"
+
+	for _, c := range []string{"auth_url", "network", "image"} {
+		v := provider.CustomConfigurations[c]
+		delete(provider.CustomConfigurations, c)
+		require.Error(t, o.validateInputs(cluster, provider), fmt.Sprintf("Validation should fail when custom config %s is missing", c))
+		provider.CustomConfigurations[c] = v
+	}
+}
+
+func TestLoadConfigurations(t *testing.T) {
+	t.Parallel()
+	o := &openstackProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+	provider.CustomConfigurations["keypair"] = "hydroform"
+
+	config := o.loadConfigurations(cluster, provider)
+
+	require.Equal(t, cluster.Name, config["cluster_name"])
+	require.Equal(t, cluster.NodeCount, config["node_count"])
+	require.Equal(t, cluster.MachineType, config["flavor"])
+	require.Equal(t, cluster.DiskSizeGB, config["disk_size"])
+	require.Equal(t, cluster.KubernetesVersion, config["kubernetes_version"])
+	require.Equal(t, cluster.Location, config["region"])
+	require.Equal(t, provider.ProjectName, config["project"])
+	require.Equal(t, provider.ProjectName, config["tenant_name"])
+
+	for k, v := range provider.CustomConfigurations {
+		require.Equal(t, v, config[k], fmt.Sprintf("Custom config %s is incorrect", k))
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	t.Parallel()
+	o := &openstackProvisioner{}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	_, err := o.Credentials(cluster, provider)
+	require.Error(t, err, "Credentials should fail without cluster info")
+
+	cluster.ClusterInfo = &types.ClusterInfo{Kubeconfig: "apiVersion: v1"}
+	kubeconfig, err := o.Credentials(cluster, provider)
+	require.NoError(t, err)
+	require.Equal(t, []byte("apiVersion: v1"), kubeconfig, "Credentials should return the kubeconfig of the cluster info")
+}
+
+func TestProvision(t *testing.T) {
+	t.Parallel()
+	mockOp := &mocks.Operator{}
+	o := openstackProvisioner{
+		provisionOperator: mockOp,
+	}
+
+	cluster := testCluster()
+	provider := testProvider()
+
+	result := &types.ClusterInfo{
+		CertificateAuthorityData: []byte("My cert"),
+		Endpoint:                 "https://cluster-url.fake",
+		Status: &types.ClusterStatus{
+			Phase: types.Provisioned,
+		},
+	}
+	mockOp.On("Create", types.OpenStack, o.loadConfigurations(cluster, provider)).Return(result, nil)
+
+	cluster, err := o.Provision(cluster, provider)
+	require.NoError(t, err, "Provision should succeed")
+	require.Equal(t, result, cluster.ClusterInfo, "The cluster info returned from the operator should be in the cluster returned by Provision")
+
+	badCluster := &types.Cluster{}
+	_, err = o.Provision(badCluster, provider)
+	require.Error(t, err, "Provision should fail")
+}
+
+func TestDeprovision(t *testing.T) {
+	t.Parallel()
+	mockOp := &mocks.Operator{}
+	o := openstackProvisioner{
+		provisionOperator: mockOp,
+	}
+
+	cluster := testCluster()
+	cluster.ClusterInfo = &types.ClusterInfo{}
+	provider := testProvider()
+
+	var state *statefile.File
+	mockOp.On("Delete", state, types.OpenStack, o.loadConfigurations(cluster, provider)).Return(nil)
+
+	err := o.Deprovision(cluster, provider)
+	require.NoError(t, err, "Deprovision should succeed")
+
+	provider.CustomConfigurations["auth_url"] = "https://wrong.example.com"
+	mockOp.On("Delete", state, types.OpenStack, o.loadConfigurations(cluster, provider)).Return(errors.New("Unable to deprovision cluster"))
+
+	err = o.Deprovision(cluster, provider)
+	require.Error(t, err, "Deprovision should fail")
+}
@@ -0,0 +1,220 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// file name for the additional provider blocks of the configuration
+const tfAdditionalProvidersFile = "additional_providers.tf"
+
+// terraformProviders are the names of the terraform providers of the providers supported by Hydroform.
+var terraformProviders = map[types.ProviderType]string{
+	types.GCP:          "google",
+	types.Azure:        "azurerm",
+	types.AWS:          "aws",
+	types.Gardener:     "gardener",
+	types.Kind:         "kind",
+	types.OpenStack:    "openstack",
+	types.DigitalOcean: "digitalocean",
+	types.AliCloud:     "alicloud",
+}
+
+// credentialsFileArguments are the arguments of the terraform providers that take the path of the credentials file, the other providers only take values.
+var credentialsFileArguments = map[types.ProviderType]string{
+	types.GCP: "credentials",
+	types.AWS: "shared_credentials_file",
+}
+
+// additionalProviders returns the additional provider blocks of the configuration.
+func additionalProviders(cfg map[string]interface{}) []types.AdditionalProvider {
+	providers, _ := cfg["additional_providers"].([]types.AdditionalProvider)
+	return providers
+}
+
+// additionalProvidersErrors checks the additional provider blocks of the configuration and returns an error for each invalid field.
+func additionalProvidersErrors(cfg map[string]interface{}) []types.FieldError {
+	v, ok := cfg["additional_providers"]
+	if !ok || v == nil {
+		return nil
+	}
+	providers, ok := v.([]types.AdditionalProvider)
+	if !ok {
+		return []types.FieldError{{Field: "additional_providers", Reason: fmt.Sprintf("must be a list of additional providers, got %T", v)}}
+	}
+
+	var errs []types.FieldError
+	blocks := make(map[string]bool)
+	for i, a := range providers {
+		field := fmt.Sprintf("additional_providers[%d]", i)
+		name, ok := terraformProviders[a.Provider]
+		if !ok {
+			errs = append(errs, types.FieldError{Field: field + ".provider", Reason: fmt.Sprintf("provider %q is not supported", a.Provider)})
+		}
+		switch {
+		case a.Alias == "":
+			errs = append(errs, types.FieldError{Field: field + ".alias", Reason: "is required, the resources of the template select the provider by its alias"})
+		case !hclsyntax.ValidIdentifier(a.Alias):
+			errs = append(errs, types.FieldError{Field: field + ".alias", Reason: "must start with a letter or underscore followed by letters, numbers, underscores or hyphens"})
+		case blocks[name+"."+a.Alias]:
+			errs = append(errs, types.FieldError{Field: field + ".alias", Reason: fmt.Sprintf("%s.%s is declared more than once", name, a.Alias)})
+		}
+		blocks[name+"."+a.Alias] = true
+		for _, arg := range sortedKeys(a.Settings) {
+			if arg == "alias" || !hclsyntax.ValidIdentifier(arg) {
+				errs = append(errs, types.FieldError{Field: fmt.Sprintf("%s.settings.%s", field, arg), Reason: "is not a valid argument of a provider block"})
+			}
+		}
+	}
+	return errs
+}
+
+// withAdditionalProviderCredentials returns a copy of the configuration whose additional provider blocks authenticate with the given credentials
+// of their provider, see withCredentials. The settings of the blocks take precedence over the credentials.
+// The credentials are credentialVars of the configuration, so the blocks only reference them, see additionalProviderVar.
+// The returned function removes the credentials and must be called once the operation finishes.
+func withAdditionalProviderCredentials(cfg map[string]interface{}, creds map[types.ProviderType]types.Credentials) (map[string]interface{}, func() error, error) {
+	providers := additionalProviders(cfg)
+	if len(providers) == 0 {
+		return cfg, noCleanup, nil
+	}
+
+	var removes []func() error
+	remove := func() error {
+		var first error
+		for _, r := range removes {
+			if err := r(); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+
+	scoped := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		scoped[k] = v
+	}
+	var vars *credentialVars
+	if cv, ok := cfg[credentialVarsKey].(*credentialVars); ok {
+		vars = cv.copy()
+	}
+	for i, a := range providers {
+		if _, ok := creds[a.Provider]; !ok {
+			continue
+		}
+		values, r, err := withCredentials(a.Provider, map[string]interface{}{}, creds)
+		if err != nil {
+			if rerr := remove(); rerr != nil {
+				return nil, nil, errors.Wrapf(err, "could not write the credentials of the additional %s provider and %s", a.Provider, rerr)
+			}
+			return nil, nil, errors.Wrapf(err, "could not write the credentials of the additional %s provider", a.Provider)
+		}
+		removes = append(removes, r)
+		// without credentials of the cluster, the vars file of the first additional provider holds all of them
+		if vars == nil {
+			vars = values[credentialVarsKey].(*credentialVars).copy()
+		}
+
+		for k, v := range values {
+			if k == credentialVarsKey {
+				continue
+			}
+			if k == "credentials_file_path" {
+				arg, ok := credentialsFileArguments[a.Provider]
+				if !ok {
+					if rerr := remove(); rerr != nil {
+						return nil, nil, errors.Errorf("the additional %s provider takes no credentials file, set its credentials as values, and %s", a.Provider, rerr)
+					}
+					return nil, nil, errors.Errorf("the additional %s provider takes no credentials file, set its credentials as values", a.Provider)
+				}
+				k = arg
+			}
+			if _, ok := a.Settings[k]; ok {
+				continue
+			}
+			name := additionalProviderVar(i, k)
+			scoped[name] = stringValue(v)
+			vars.names[name] = true
+			if vars.providerArgs[i] == nil {
+				vars.providerArgs[i] = make(map[string]string)
+			}
+			vars.providerArgs[i][k] = name
+		}
+	}
+	if vars != nil {
+		scoped[credentialVarsKey] = vars
+	}
+	return scoped, remove, nil
+}
+
+// additionalProviderVar returns the name of the variable of the given argument of the additional provider block with the given index.
+// The arguments start with a letter or an underscore, so the names of different blocks never collide.
+func additionalProviderVar(i int, arg string) string {
+	return fmt.Sprintf("additional_provider_%d_%s", i, arg)
+}
+
+// writeAdditionalProvidersFile renders the additional provider blocks of the configuration into the cluster directory, terraform init installs their plugins.
+// The arguments set from the credentials reference their variables, which are declared in the file as well, see withAdditionalProviderCredentials.
+// The file is removed if the configuration has no additional providers.
+func writeAdditionalProvidersFile(dir string, cfg map[string]interface{}) error {
+	path := filepath.Join(dir, tfAdditionalProvidersFile)
+	providers := additionalProviders(cfg)
+	if len(providers) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	vars, _ := cfg[credentialVarsKey].(*credentialVars)
+
+	var data strings.Builder
+	var declared []string
+	for i, a := range providers {
+		if i > 0 {
+			data.WriteString("\n")
+		}
+		var credArgs map[string]string
+		if vars != nil {
+			credArgs = vars.providerArgs[i]
+		}
+		args := make(map[string]string, len(a.Settings)+len(credArgs))
+		for arg, v := range a.Settings {
+			args[arg] = hclString(v)
+		}
+		for arg, name := range credArgs {
+			args[arg] = "var." + name
+			declared = append(declared, name)
+		}
+
+		data.WriteString(fmt.Sprintf("provider %q {\n", terraformProviders[a.Provider]))
+		data.WriteString(fmt.Sprintf("  alias = %s\n", hclString(a.Alias)))
+		// sort the arguments to always render the same file for the same configuration
+		for _, arg := range sortedKeys(args) {
+			data.WriteString(fmt.Sprintf("  %s = %s\n", arg, args[arg]))
+		}
+		data.WriteString("}\n")
+	}
+	sort.Strings(declared)
+	for _, name := range declared {
+		data.WriteString(fmt.Sprintf("\nvariable %q {\n  type = string\n}\n", name))
+	}
+	return ioutil.WriteFile(path, []byte(data.String()), 0600)
+}
+
+// sortedKeys returns the keys of the given map in alphabetical order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,132 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdditionalProvidersErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, additionalProvidersErrors(map[string]interface{}{}))
+	require.Empty(t, additionalProvidersErrors(map[string]interface{}{
+		"additional_providers": []types.AdditionalProvider{
+			{Provider: types.AWS, Alias: "dns", Settings: map[string]string{"region": "eu-west-1"}},
+			{Provider: types.AWS, Alias: "backup"},
+		},
+	}))
+
+	errs := additionalProvidersErrors(map[string]interface{}{
+		"additional_providers": []types.AdditionalProvider{
+			{Provider: "ibm", Alias: "dns"},
+			{Provider: types.AWS},
+			{Provider: types.AWS, Alias: "dns", Settings: map[string]string{"alias": "other", "region": "eu-west-1"}},
+			{Provider: types.AWS, Alias: "dns"},
+		},
+	})
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	require.Equal(t, []string{
+		"additional_providers[0].provider",
+		"additional_providers[1].alias",
+		"additional_providers[2].settings.alias",
+		"additional_providers[3].alias",
+	}, fields)
+
+	errs = additionalProvidersErrors(map[string]interface{}{"additional_providers": []string{"aws"}})
+	require.Len(t, errs, 1, "Other values should be refused")
+}
+
+func TestWriteAdditionalProvidersFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-additionalproviders")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := map[string]interface{}{"additional_providers": []types.AdditionalProvider{
+		{Provider: types.AWS, Alias: "dns", Settings: map[string]string{"region": "eu-west-1", "profile": "dns"}},
+		{Provider: types.DigitalOcean, Alias: "backup"},
+	}}
+	require.NoError(t, writeAdditionalProvidersFile(dir, cfg))
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfAdditionalProvidersFile))
+	require.NoError(t, err)
+	require.Equal(t, `provider "aws" {
+  alias = "dns"
+  profile = "dns"
+  region = "eu-west-1"
+}
+
+provider "digitalocean" {
+  alias = "backup"
+}
+`, string(data))
+
+	file, diags := configs.NewParser(nil).LoadConfigFile(filepath.Join(dir, tfAdditionalProvidersFile))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Len(t, file.ProviderConfigs, 2, "Terraform should read the provider blocks")
+	require.Equal(t, "dns", file.ProviderConfigs[0].Alias)
+
+	require.NoError(t, writeAdditionalProvidersFile(dir, map[string]interface{}{}))
+	_, err = os.Stat(filepath.Join(dir, tfAdditionalProvidersFile))
+	require.True(t, os.IsNotExist(err), "The file should be removed without additional providers")
+}
+
+func TestWithAdditionalProviderCredentials(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"cluster_name": "my-cluster",
+		"additional_providers": []types.AdditionalProvider{
+			{Provider: types.AWS, Alias: "dns", Settings: map[string]string{"region": "eu-west-1"}},
+			{Provider: types.DigitalOcean, Alias: "backup", Settings: map[string]string{"token": "explicit"}},
+			{Provider: types.OpenStack, Alias: "other"},
+		},
+	}
+	creds := map[types.ProviderType]types.Credentials{
+		types.AWS:          {File: []byte("aws")},
+		types.DigitalOcean: {Values: map[string]string{"token": "from-credentials"}},
+	}
+
+	scoped, remove, err := withAdditionalProviderCredentials(cfg, creds)
+	require.NoError(t, err)
+	require.Equal(t, additionalProviders(cfg), additionalProviders(scoped), "The blocks should only reference the credentials")
+	vars := scoped[credentialVarsKey].(*credentialVars)
+	require.Equal(t, map[int]map[string]string{0: {"shared_credentials_file": "additional_provider_0_shared_credentials_file"}}, vars.providerArgs,
+		"The settings should take precedence over the credentials")
+	path := scoped["additional_provider_0_shared_credentials_file"].(string)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "aws", string(data), "The credentials file should authenticate the aws block")
+	_, ok := cfg[credentialVarsKey]
+	require.False(t, ok, "The given configuration should not be modified")
+
+	dir, err := ioutil.TempDir("", "hf-additionalproviders")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, writeAdditionalProvidersFile(dir, scoped))
+	rendered, err := ioutil.ReadFile(filepath.Join(dir, tfAdditionalProvidersFile))
+	require.NoError(t, err)
+	require.Contains(t, string(rendered), "  shared_credentials_file = var.additional_provider_0_shared_credentials_file\n")
+	require.NotContains(t, string(rendered), path, "The rendered blocks should not hold the credentials")
+	file, diags := configs.NewParser(nil).LoadConfigFile(filepath.Join(dir, tfAdditionalProvidersFile))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Equal(t, "additional_provider_0_shared_credentials_file", file.Variables[0].Name, "The variables of the credentials should be declared")
+
+	require.NoError(t, writeVarsFile(dir, filterVars(scoped, types.GCP)))
+	credVars, err := ioutil.ReadFile(vars.file)
+	require.NoError(t, err)
+	require.Contains(t, string(credVars), "additional_provider_0_shared_credentials_file = ")
+
+	require.NoError(t, remove())
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "The credentials should be removed")
+
+	_, _, err = withAdditionalProviderCredentials(cfg, map[types.ProviderType]types.Credentials{types.OpenStack: {File: []byte("clouds.yaml")}})
+	require.Error(t, err, "Providers without a credentials file argument should refuse a file")
+}
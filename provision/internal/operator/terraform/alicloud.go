@@ -0,0 +1,116 @@
+package terraform
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	alicloudSTSAPI      = "https://sts.aliyuncs.com"
+	alicloudKeysTimeout = 30 * time.Second
+)
+
+// error codes of the Alibaba Cloud API for rejected access keys
+var alicloudAuthErrors = []string{"InvalidAccessKeyId", "SignatureDoesNotMatch", "NoPermission"}
+
+// initAliCloudProvider checks that the Alibaba Cloud access key is valid before running any command,
+// so that missing or rejected keys are reported before init downloads the provider.
+// The keys are read from the configuration with the "access_key" and "secret_key" keys, or from the environment variables supported by the provider.
+func initAliCloudProvider(transport http.RoundTripper, cfg map[string]interface{}) error {
+	key, _ := cfg["access_key"].(string)
+	if key == "" {
+		key = os.Getenv("ALICLOUD_ACCESS_KEY")
+	}
+	secret, _ := cfg["secret_key"].(string)
+	if secret == "" {
+		secret = os.Getenv("ALICLOUD_SECRET_KEY")
+	}
+	if key == "" || secret == "" {
+		return errors.New("no Alibaba Cloud access key found, set access_key and secret_key in the configuration or the ALICLOUD_ACCESS_KEY and ALICLOUD_SECRET_KEY environment variables")
+	}
+
+	return checkAliCloudKeys(transport, alicloudSTSAPI, key, secret)
+}
+
+// checkAliCloudKeys requests the identity of the access key from the given Alibaba Cloud STS API.
+// It returns ErrAuthFailed if the API rejects the key.
+func checkAliCloudKeys(transport http.RoundTripper, api, key, secret string) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	params := url.Values{
+		"Action":           {"GetCallerIdentity"},
+		"Format":           {"JSON"},
+		"Version":          {"2015-04-01"},
+		"AccessKeyId":      {key},
+		"SignatureMethod":  {"HMAC-SHA1"},
+		"SignatureVersion": {"1.0"},
+		"SignatureNonce":   {hex.EncodeToString(nonce)},
+		"Timestamp":        {time.Now().UTC().Format("2006-01-02T15:04:05Z")},
+	}
+	params.Set("Signature", alicloudSignature(http.MethodGet, params, secret))
+
+	client := &http.Client{Transport: transport, Timeout: alicloudKeysTimeout}
+	resp, err := client.Get(fmt.Sprintf("%s/?%s", api, params.Encode()))
+	if err != nil {
+		return errors.Wrap(err, "could not check the Alibaba Cloud access key")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var apiErr struct {
+		Code string
+	}
+	// the body is only used to tell rejected keys from other failures
+	_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+	for _, c := range alicloudAuthErrors {
+		if strings.HasPrefix(apiErr.Code, c) {
+			return errors.Wrapf(types.ErrAuthFailed, "the Alibaba Cloud access key was rejected: %s", apiErr.Code)
+		}
+	}
+	return errors.Errorf("could not check the Alibaba Cloud access key, the API answered with status %s", resp.Status)
+}
+
+// alicloudSignature signs the parameters of an Alibaba Cloud RPC request with the given secret.
+func alicloudSignature(method string, params url.Values, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	query := make([]string, 0, len(keys))
+	for _, k := range keys {
+		query = append(query, alicloudEncode(k)+"="+alicloudEncode(params.Get(k)))
+	}
+	toSign := method + "&" + alicloudEncode("/") + "&" + alicloudEncode(strings.Join(query, "&"))
+
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(toSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// alicloudEncode percent-encodes a value the way the Alibaba Cloud API expects it in signatures.
+func alicloudEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
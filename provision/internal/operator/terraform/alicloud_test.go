@@ -0,0 +1,72 @@
+package terraform
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAliCloudKeys(t *testing.T) {
+	t.Parallel()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		require.Equal(t, "GetCallerIdentity", q.Get("Action"))
+
+		signature := q.Get("Signature")
+		q.Del("Signature")
+		switch {
+		case q.Get("AccessKeyId") == "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case q.Get("AccessKeyId") != "valid":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"Code": "InvalidAccessKeyId.NotFound"}`))
+		case signature != alicloudSignature(http.MethodGet, q, "secret"):
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"Code": "SignatureDoesNotMatch"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer api.Close()
+
+	require.NoError(t, checkAliCloudKeys(nil, api.URL, "valid", "secret"))
+
+	err := checkAliCloudKeys(nil, api.URL, "invalid", "secret")
+	require.True(t, errors.Is(err, types.ErrAuthFailed), "A rejected key should be an authentication error")
+
+	err = checkAliCloudKeys(nil, api.URL, "valid", "wrong")
+	require.True(t, errors.Is(err, types.ErrAuthFailed), "A rejected secret should be an authentication error")
+
+	err = checkAliCloudKeys(nil, api.URL, "broken", "secret")
+	require.Error(t, err)
+	require.False(t, errors.Is(err, types.ErrAuthFailed), "API failures should not be taken as rejected keys")
+}
+
+func TestAliCloudSignature(t *testing.T) {
+	t.Parallel()
+	// example request from the Alibaba Cloud signature documentation
+	params := url.Values{
+		"Action":           {"DescribeRegions"},
+		"Format":           {"XML"},
+		"Version":          {"2014-05-26"},
+		"AccessKeyId":      {"testid"},
+		"SignatureMethod":  {"HMAC-SHA1"},
+		"SignatureVersion": {"1.0"},
+		"SignatureNonce":   {"3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf"},
+		"Timestamp":        {"2016-02-23T12:46:24Z"},
+	}
+	require.Equal(t, "OLeaidS1JvxuMvnyHOwuJ+uX5qY=", alicloudSignature(http.MethodGet, params, "testsecret"))
+}
+
+func TestInitAliCloudProvider(t *testing.T) {
+	t.Parallel()
+	// keys in the environment are always checked against the API, so only check when there is no secret
+	if !envSet("ALICLOUD_SECRET_KEY") {
+		require.Error(t, initAliCloudProvider(nil, map[string]interface{}{"access_key": "key"}), "Validation should fail without secret")
+	}
+}
@@ -0,0 +1,231 @@
+package terraform
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// operationsDir is the directory in the data dir holding the state of the operations running in the background.
+const operationsDir = "operations"
+
+// operationID matches the IDs of the background operations, so a handle cannot point outside of the operations dir.
+var operationID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// runningOps tracks the background operations running in this process, their state files are only written by their goroutine.
+var runningOps = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: make(map[string]bool)}
+
+// DeleteAsync starts the deletion of the cluster in the background and returns right away with the handle of the operation.
+// The deletion works as Delete, use OperationStatus with the handle to poll its progress and whether it finished.
+// The state of the operation is kept in the data dir, so the handle can be persisted and polled after a restart of the process,
+// by any operator sharing the data dir. The operation holds its lock until its final state is stored, see lockOperation,
+// so an operation whose process exited before it finished is reported as failed.
+// The configuration is checked before the deletion starts, an invalid one fails right away.
+func (t *Terraform) DeleteAsync(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (types.OperationHandle, error) {
+	if err := t.checkAsync(p, cfg); err != nil {
+		return types.OperationHandle{}, err
+	}
+	// the deletion is in flight from now on, so a shutdown waits for it even before its goroutine runs
+	ctx, done, err := t.begin(context.Background())
+	if err != nil {
+		return types.OperationHandle{}, err
+	}
+
+	id, err := newOperationID()
+	if err != nil {
+		done(&err)
+		return types.OperationHandle{}, err
+	}
+	op := &types.OperationState{
+		Handle: types.OperationHandle{
+			ID:        id,
+			Operation: deleteMetric,
+			Provider:  p,
+			Project:   cfg["project"].(string),
+			Cluster:   cfg["cluster_name"].(string),
+		},
+		Phase: types.OperationRunning,
+		Start: time.Now(),
+	}
+	unlock, err := lockOperation(t.ops, id)
+	if err == nil && unlock == nil {
+		err = errors.Errorf("operation %s is already locked", id)
+	}
+	if err != nil {
+		done(&err)
+		return types.OperationHandle{}, err
+	}
+	if err := storeOperation(t.ops, op); err != nil {
+		unlock()
+		done(&err)
+		return types.OperationHandle{}, err
+	}
+
+	// the caller may change the configuration once the operation started
+	cfgCopy := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		cfgCopy[k] = v
+	}
+
+	runningOps.Lock()
+	runningOps.ids[id] = true
+	runningOps.Unlock()
+
+	bg := &Terraform{ops: t.ops, rotated: t.rotated, states: t.states}
+	bg.ops.ProgressHandler = func(e types.ProvisionEvent) {
+		if t.ops.ProgressHandler != nil {
+			t.ops.ProgressHandler(e)
+		}
+		op.Step, op.Message = e.Phase, e.Message
+		// the progress is best effort, the final state is written once the operation finishes
+		storeOperation(t.ops, op)
+	}
+	go func() {
+		// the deletion turns its error into ErrTimeout itself
+		defer done(nil)
+		defer unlock()
+		err := bg.DeleteWithContext(ctx, sf, p, cfgCopy)
+
+		end := time.Now()
+		op.End = &end
+		op.Phase = types.OperationSucceeded
+		if err != nil {
+			op.Phase, op.Error = types.OperationFailed, err.Error()
+		}
+		if err := storeOperation(t.ops, op); err != nil && t.ops.Logger != nil {
+			t.ops.Logger.Printf("could not store the state of operation %s: %s", id, err)
+		}
+
+		runningOps.Lock()
+		delete(runningOps.ids, id)
+		runningOps.Unlock()
+	}()
+	return op.Handle, nil
+}
+
+// OperationStatus returns the current state of the background operation with the given handle.
+// It fails with ErrOperationNotFound if the data dir has no operation with the handle.
+func (t *Terraform) OperationStatus(handle types.OperationHandle) (*types.OperationState, error) {
+	op, err := loadOperation(t.ops, handle.ID)
+	if err != nil {
+		return nil, err
+	}
+	if op.Phase != types.OperationRunning {
+		return op, nil
+	}
+
+	runningOps.Lock()
+	running := runningOps.ids[handle.ID]
+	runningOps.Unlock()
+	if running {
+		return op, nil
+	}
+
+	// the operation runs in another process as long as it holds its lock
+	unlock, err := lockOperation(t.ops, handle.ID)
+	if err != nil {
+		return nil, err
+	}
+	if unlock == nil {
+		return op, nil
+	}
+	defer unlock()
+
+	// the operation may have stored its final state since it was loaded
+	if op, err = loadOperation(t.ops, handle.ID); err != nil || op.Phase != types.OperationRunning {
+		return op, err
+	}
+	end := time.Now()
+	op.End = &end
+	op.Phase, op.Error = types.OperationFailed, "the operation was interrupted before it finished"
+	if err := storeOperation(t.ops, op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// checkAsync checks the configuration of a background operation, with the credentials of the options, so it fails before running in the background.
+func (t *Terraform) checkAsync(p types.ProviderType, cfg map[string]interface{}) (err error) {
+	cfg, removeCredentials, err := t.credentials(context.Background(), p, cfg)
+	if err != nil {
+		return err
+	}
+	defer t.removeFiles(&err, removeCredentials)
+	return t.preflight(p, cfg)
+}
+
+// newOperationID returns a random ID for a background operation.
+func newOperationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "could not generate the ID of the operation")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// operationFile returns the path of the state file of the given operation in the data dir.
+func operationFile(ops Options, id string) string {
+	return filepath.Join(ops.DataDir(), operationsDir, id+".json")
+}
+
+// lockOperation takes the lock of the given operation in the data dir without waiting, it returns no function if the operation holds it.
+// The operation holds its lock while it runs, until its final state is stored.
+func lockOperation(ops Options, id string) (func(), error) {
+	path := operationFile(ops, id) + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrap(err, "could not create the operations dir")
+	}
+	unlock, err := tryLock(path)
+	return unlock, errors.Wrapf(err, "could not lock operation %s", id)
+}
+
+// storeOperation stores the state of the given operation in the data dir.
+// The file is replaced at once, so readers never see a partially written state.
+func storeOperation(ops Options, op *types.OperationState) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	path := operationFile(ops, op.Handle.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "could not create the operations dir")
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "could not write the state of the operation")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "could not write the state of the operation")
+}
+
+// loadOperation loads the state of the operation with the given ID from the data dir.
+func loadOperation(ops Options, id string) (*types.OperationState, error) {
+	if !operationID.MatchString(id) {
+		return nil, errors.Wrapf(types.ErrOperationNotFound, "invalid operation ID %q", id)
+	}
+	data, err := ioutil.ReadFile(operationFile(ops, id))
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(types.ErrOperationNotFound, "there is no operation %s", id)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the state of the operation")
+	}
+	op := &types.OperationState{}
+	if err := json.Unmarshal(data, op); err != nil {
+		return nil, errors.Wrapf(err, "could not decode the state of operation %s", id)
+	}
+	return op, nil
+}
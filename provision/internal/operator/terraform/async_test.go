@@ -0,0 +1,119 @@
+package terraform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForOperation polls the operation of the given handle until it finishes.
+func waitForOperation(t *testing.T, tf *Terraform, handle types.OperationHandle) *types.OperationState {
+	for i := 0; i < 600; i++ {
+		op, err := tf.OperationStatus(handle)
+		require.NoError(t, err)
+		if op.Phase != types.OperationRunning {
+			return op
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.FailNow(t, "The operation did not finish")
+	return nil
+}
+
+func TestDeleteAsync(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-async")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+`)}}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+
+	_, err = New(WithDataDir(dir), WithTemplate(types.Kind, tmpl)).DeleteAsync(nil, types.Kind, map[string]interface{}{"project": "my-project"})
+	var verr *types.ValidationError
+	require.True(t, errors.As(err, &verr), "An invalid configuration should fail before the operation starts")
+
+	// without state there is nothing to delete
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl))
+	handle, err := tf.DeleteAsync(nil, types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "delete", handle.Operation)
+	require.Equal(t, "my-cluster", handle.Cluster)
+	op := waitForOperation(t, tf, handle)
+	require.Equal(t, types.OperationFailed, op.Phase)
+	require.Contains(t, op.Error, "state")
+	require.NotNil(t, op.End)
+
+	var events []types.ProvisionEvent
+	tf = New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), ForceDelete(), WithProgressHandler(func(e types.ProvisionEvent) { events = append(events, e) }))
+	handle, err = tf.DeleteAsync(nil, types.Kind, cfg)
+	require.NoError(t, err)
+
+	// the handle can be persisted and polled by another operator sharing the data dir
+	data, err := json.Marshal(handle)
+	require.NoError(t, err)
+	var restored types.OperationHandle
+	require.NoError(t, json.Unmarshal(data, &restored))
+	op = waitForOperation(t, New(WithDataDir(dir)), restored)
+	require.Equal(t, types.OperationSucceeded, op.Phase, op.Error)
+	require.Equal(t, handle, op.Handle)
+	require.NotEmpty(t, op.Step, "The progress of the operation should be recorded")
+	require.NotEmpty(t, events, "The progress handler of the options should still get the events")
+}
+
+func TestOperationStatus(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-async-status")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tf := New(WithDataDir(dir))
+
+	_, err = tf.OperationStatus(types.OperationHandle{ID: "0123456789abcdef0123456789abcdef"})
+	require.True(t, errors.Is(err, types.ErrOperationNotFound))
+	_, err = tf.OperationStatus(types.OperationHandle{ID: "../../clusters"})
+	require.True(t, errors.Is(err, types.ErrOperationNotFound), "Handles should not point outside of the operations dir")
+
+	// an operation of a process that exited while it was running
+	handle := types.OperationHandle{ID: "0123456789abcdef0123456789abcdef", Operation: "delete", Provider: types.Kind, Project: "my-project", Cluster: "my-cluster"}
+	require.NoError(t, storeOperation(tf.ops, &types.OperationState{Handle: handle, Phase: types.OperationRunning, Start: time.Now()}))
+
+	unlock, err := lockOperation(tf.ops, handle.ID)
+	require.NoError(t, err)
+	op, err := tf.OperationStatus(handle)
+	require.NoError(t, err)
+	require.Equal(t, types.OperationRunning, op.Phase, "An operation of another process holding its lock should be running")
+	unlock()
+
+	// the cluster lock is released before the final state of the operation is stored
+	unlockCluster, err := lockCluster(tf.ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	unlockCluster()
+	unlock, err = lockOperation(tf.ops, handle.ID)
+	require.NoError(t, err)
+	op, err = tf.OperationStatus(handle)
+	require.NoError(t, err)
+	require.Equal(t, types.OperationRunning, op.Phase, "An operation that released the cluster lock should still be running")
+	end := time.Now()
+	require.NoError(t, storeOperation(tf.ops, &types.OperationState{Handle: handle, Phase: types.OperationSucceeded, Start: end, End: &end}))
+	unlock()
+	op, err = tf.OperationStatus(handle)
+	require.NoError(t, err)
+	require.Equal(t, types.OperationSucceeded, op.Phase, "A finished operation should not be marked as interrupted")
+
+	require.NoError(t, storeOperation(tf.ops, &types.OperationState{Handle: handle, Phase: types.OperationRunning, Start: time.Now()}))
+
+	op, err = tf.OperationStatus(handle)
+	require.NoError(t, err)
+	require.Equal(t, types.OperationFailed, op.Phase, "An operation without a process running it should be interrupted")
+	require.Contains(t, op.Error, "interrupted")
+}
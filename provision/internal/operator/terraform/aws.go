@@ -0,0 +1,23 @@
+package terraform
+
+import (
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/pkg/errors"
+)
+
+// validateAWSCredentials checks that terraform will be able to authenticate on AWS before running any command.
+// Credentials are looked up the same way the terraform provider does: first in the environment and then in the shared credentials file.
+// The credentials file and profile can be set in the configuration with the "credentials_file_path" and "profile" keys.
+func validateAWSCredentials(cfg map[string]interface{}) error {
+	path, _ := cfg["credentials_file_path"].(string)
+	profile, _ := cfg["profile"].(string)
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvProvider{},
+		&credentials.SharedCredentialsProvider{Filename: path, Profile: profile},
+	})
+	if _, err := creds.Get(); err != nil {
+		return errors.New("no AWS credentials found, set the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables or provide a shared credentials file")
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAWSCredentials(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-aws-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	credentialsFile := filepath.Join(dir, "credentials")
+	require.NoError(t, ioutil.WriteFile(credentialsFile, []byte("[hydroform]\naws_access_key_id = AKID\naws_secret_access_key = SECRET\n"), 0600))
+
+	cfg := map[string]interface{}{
+		"credentials_file_path": credentialsFile,
+		"profile":               "hydroform",
+	}
+	require.NoError(t, validateAWSCredentials(cfg), "Credentials from the shared credentials file should be valid")
+
+	// credentials in the environment always take precedence, so only check when there are none
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		cfg["profile"] = "unknown"
+		require.Error(t, validateAWSCredentials(cfg), "Validation should fail when the profile is not in the credentials file")
+	}
+}
@@ -0,0 +1,375 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	be_init "github.com/hashicorp/terraform/backend/init"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/hashicorp/terraform/states/statemgr"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// file name for the terraform backend configuration
+	tfBackendFile = "backend.tf"
+
+	// backendDataDir is the terraform data dir in the directory of each cluster with a backend, see backendOptions
+	backendDataDir = ".terraform"
+	// file name of the backend configuration terraform init saves in its data dir
+	tfBackendStateFile = "terraform.tfstate"
+
+	s3Backend      = "s3"
+	gcsBackend     = "gcs"
+	azurermBackend = "azurerm"
+)
+
+// backendAttributes returns the settings of the given backend for a specific cluster.
+// Each cluster gets its own state inside the backend, following the same layout as the data dir.
+func backendAttributes(b types.BackendConfig, project, cluster string, p types.ProviderType) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for k, v := range b.Config {
+		attrs[k] = v
+	}
+
+	if b.LockTable != "" && b.Type != s3Backend {
+		return nil, errors.Errorf("the %s backend always locks the states, a lock table is only used by the %s backend", b.Type, s3Backend)
+	}
+
+	statePath := path.Join(b.Prefix, string(p), project, cluster)
+	switch b.Type {
+	case s3Backend:
+		attrs["bucket"] = b.Bucket
+		attrs["key"] = path.Join(statePath, tfStateFile)
+		if b.Region != "" {
+			attrs["region"] = b.Region
+		}
+		if b.Credentials != "" {
+			attrs["shared_credentials_file"] = b.Credentials
+		}
+		if b.LockTable != "" {
+			attrs["dynamodb_table"] = b.LockTable
+		}
+	case gcsBackend:
+		attrs["bucket"] = b.Bucket
+		attrs["prefix"] = statePath
+		if b.Credentials != "" {
+			attrs["credentials"] = b.Credentials
+		}
+	case azurermBackend:
+		attrs["container_name"] = b.Bucket
+		attrs["key"] = path.Join(statePath, tfStateFile)
+		if b.Credentials != "" {
+			attrs["access_key"] = b.Credentials
+		}
+	default:
+		return nil, errors.Errorf("backend type %q is not supported, it has to be one of: %s, %s, %s", b.Type, s3Backend, gcsBackend, azurermBackend)
+	}
+
+	return attrs, nil
+}
+
+// backendOptions returns the options of an operation on the cluster in the given directory with a backend.
+// Terraform init saves the configuration of the backend, with its secrets, and the selected workspace in its data dir, so the commands of each cluster
+// get a terraform data dir of their own in the directory of the cluster: concurrent operations on other clusters never replace them, and
+// removeBackendConfig removes the secrets once the operation finished. The cluster directory stays the given one.
+// The providers are installed from the plugin cache of the options, as in a sandbox. Without a backend, the options are returned as they are.
+func backendOptions(ops Options, dir string) Options {
+	if ops.Backend == nil {
+		return ops
+	}
+	ops.Meta.OverrideDataDir = filepath.Join(dir, backendDataDir)
+	ops.PathStrategy = func(string, string, string, types.ProviderType) string {
+		return dir
+	}
+	return ops
+}
+
+// removeBackendConfig removes the configuration of the backend terraform init saved in the data dir of the options.
+func removeBackendConfig(ops Options) error {
+	if err := os.Remove(filepath.Join(ops.DataDir(), tfBackendStateFile)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "could not remove the backend configuration")
+	}
+	return nil
+}
+
+// backendSecrets returns the settings of the given backend that are secrets, such as the access key of azurerm.
+// They are left out of the backend file of the cluster and passed to terraform init instead, see backendConfigArgs.
+func backendSecrets(b types.BackendConfig) map[string]string {
+	if b.Type == azurermBackend && b.Credentials != "" {
+		return map[string]string{"access_key": b.Credentials}
+	}
+	return nil
+}
+
+// backendConfigArgs generates the -backend-config flags of the terraform init command with the secrets of the given backend.
+func backendConfigArgs(b types.BackendConfig) []string {
+	secrets := backendSecrets(b)
+	args := make([]string, 0, len(secrets))
+	for k, v := range secrets {
+		args = append(args, fmt.Sprintf("-backend-config=%s=%s", k, v))
+	}
+	sort.Strings(args)
+	return args
+}
+
+// writeBackendFile renders the terraform backend block for the given cluster into the cluster directory.
+// The secrets of the backend are left out, see backendSecrets.
+func writeBackendFile(b types.BackendConfig, dir, project, cluster string, p types.ProviderType) error {
+	attrs, err := backendAttributes(b, project, cluster, p)
+	if err != nil {
+		return err
+	}
+
+	// sort the keys to always render the same file for the same config
+	secrets := backendSecrets(b)
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		if _, ok := secrets[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var data strings.Builder
+	data.WriteString("terraform {\n")
+	data.WriteString(fmt.Sprintf("  backend %q {\n", b.Type))
+	for _, k := range keys {
+		data.WriteString(fmt.Sprintf("    %s = %q\n", k, attrs[k]))
+	}
+	data.WriteString("  }\n}\n")
+
+	return ioutil.WriteFile(filepath.Join(dir, tfBackendFile), []byte(data.String()), 0700)
+}
+
+// backendStateMgr configures the given backend and returns the manager of the state for a specific cluster, in its workspace if it uses one.
+func backendStateMgr(ops Options, b types.BackendConfig, project, cluster string, p types.ProviderType) (statemgr.Full, error) {
+	attrs, err := backendAttributes(b, project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+
+	be_init.Init(ops.Services)
+	f := be_init.Backend(b.Type)
+	if f == nil {
+		return nil, errors.Errorf("backend type %q is not available", b.Type)
+	}
+	be := f()
+
+	schema := be.ConfigSchema()
+	vals := make(map[string]cty.Value)
+	for k, v := range attrs {
+		if _, ok := schema.Attributes[k]; !ok {
+			return nil, errors.Errorf("setting %q is not supported by the %s backend", k, b.Type)
+		}
+		vals[k] = cty.StringVal(v)
+	}
+	obj, err := schema.CoerceValue(cty.ObjectVal(vals))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s backend configuration", b.Type)
+	}
+
+	obj, diags := be.PrepareConfig(obj)
+	if diags.HasErrors() {
+		return nil, errors.Wrapf(diags.Err(), "invalid %s backend configuration", b.Type)
+	}
+	if diags := be.Configure(obj); diags.HasErrors() {
+		return nil, errors.Wrapf(diags.Err(), "could not configure the %s backend", b.Type)
+	}
+
+	return be.StateMgr(workspaceName(ops, project, cluster))
+}
+
+// stateFromBackend loads the terraform state of the given cluster from a remote backend.
+func stateFromBackend(ops Options, b types.BackendConfig, project, cluster string, p types.ProviderType) (*statefile.File, error) {
+	mgr, err := backendStateMgr(ops, b, project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mgr.RefreshState(); err != nil {
+		return nil, errors.Wrapf(err, "could not load the state from the %s backend", b.Type)
+	}
+	if mgr.State() == nil {
+		return nil, errors.Wrapf(types.ErrStateNotFound, "there is no state for cluster %s in the %s backend", cluster, b.Type)
+	}
+	return statemgr.Export(mgr), nil
+}
+
+// stateToBackend saves the terraform state of the given cluster into a remote backend.
+func stateToBackend(ops Options, state *statefile.File, b types.BackendConfig, project, cluster string, p types.ProviderType) error {
+	mgr, err := backendStateMgr(ops, b, project, cluster, p)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockBackendState(mgr, b)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := mgr.RefreshState(); err != nil {
+		return errors.Wrapf(err, "could not load the state from the %s backend", b.Type)
+	}
+	if err := statemgr.Import(state, mgr, false); err != nil {
+		return errors.Wrapf(err, "could not write the state into the %s backend", b.Type)
+	}
+	return mgr.PersistState()
+}
+
+// lockBackendState acquires the lock of the state in the backend, as terraform does for its commands, so the state is not written
+// while another operation, such as one of another replica, works on it. It waits for the lock up to the LockTimeout of the backend
+// and fails with a StateLockError telling who holds it. The lock is released when the returned function is called.
+func lockBackendState(mgr statemgr.Full, b types.BackendConfig) (func(), error) {
+	info := statemgr.NewLockInfo()
+	info.Operation = "hydroform"
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.LockTimeout)
+	defer cancel()
+	id, err := statemgr.LockWithContext(ctx, mgr, info)
+	if err != nil {
+		var lockErr *statemgr.LockError
+		if errors.As(err, &lockErr) && lockErr.Info != nil {
+			return nil, errors.WithStack(stateLockError(lockErr.Info))
+		}
+		return nil, errors.Wrapf(err, "could not lock the state in the %s backend", b.Type)
+	}
+	return func() {
+		// a lock that cannot be released is reported by the next operation that needs it
+		_ = mgr.Unlock(id)
+	}, nil
+}
+
+// stateLockError returns the StateLockError for the given lock held by another operation.
+func stateLockError(info *statemgr.LockInfo) *types.StateLockError {
+	return &types.StateLockError{
+		ID:        info.ID,
+		Path:      info.Path,
+		Operation: info.Operation,
+		Who:       info.Who,
+		Version:   info.Version,
+		Created:   info.Created,
+		Info:      info.Info,
+	}
+}
+
+// lockInfoField matches a field of the lock info terraform outputs when the state is locked, such as `  Who:       user@host`.
+var lockInfoField = regexp.MustCompile(`(?m)^\s*(ID|Path|Operation|Who|Version|Created|Info):[ \t]*(.*?)\s*$`)
+
+// lockInfoCreated is the layout of the creation time in the lock info terraform outputs.
+const lockInfoCreated = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// parseStateLockError returns the StateLockError for the message terraform outputs when it cannot acquire the lock of the state,
+// with the lock info of the operation holding it, or nil if the message is about something else.
+func parseStateLockError(msg string) *types.StateLockError {
+	i := strings.Index(msg, "Error acquiring the state lock")
+	if i < 0 {
+		return nil
+	}
+	lockErr := &types.StateLockError{}
+	info := msg[i:]
+	if j := strings.Index(info, "Lock Info:"); j >= 0 {
+		info = info[j:]
+	} else {
+		return lockErr
+	}
+	for _, m := range lockInfoField.FindAllStringSubmatch(info, -1) {
+		switch m[1] {
+		case "ID":
+			lockErr.ID = m[2]
+		case "Path":
+			lockErr.Path = m[2]
+		case "Operation":
+			lockErr.Operation = m[2]
+		case "Who":
+			lockErr.Who = m[2]
+		case "Version":
+			lockErr.Version = m[2]
+		case "Created":
+			lockErr.Created, _ = time.Parse(lockInfoCreated, m[2])
+		case "Info":
+			lockErr.Info = m[2]
+		}
+	}
+	return lockErr
+}
+
+// lockArgs generates the lock timeout flag of the terraform commands that lock the state in the backend, or nothing to fail right away if it is locked.
+func lockArgs(ops Options) []string {
+	if ops.Backend != nil && ops.Backend.LockTimeout > 0 {
+		return []string{fmt.Sprintf("-lock-timeout=%s", ops.Backend.LockTimeout)}
+	}
+	return nil
+}
+
+// loadState loads the terraform state of the given cluster from the configured backend, from memory with the InMemoryState option, or the data dir otherwise.
+func loadState(ops Options, mem *stateStore, project, cluster string, p types.ProviderType) (*statefile.File, error) {
+	if ops.Backend != nil {
+		return stateFromBackend(ops, *ops.Backend, project, cluster, p)
+	}
+	if ops.InMemoryState {
+		return stateFromMemory(ops, mem, project, cluster, p)
+	}
+	return stateFromFile(ops, project, cluster, p)
+}
+
+// lockedState loads the terraform state of the given cluster while holding its lock, so the state is not read while an operation writes it.
+// The lock is released once the state is loaded.
+func (t *Terraform) lockedState(project, cluster string, p types.ProviderType) (*statefile.File, error) {
+	unlock, err := lockCluster(t.ops, project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	sf, err := loadState(t.ops, t.states, project, cluster, p)
+	return sf, errors.Wrap(err, "could not load the state of the cluster")
+}
+
+// storeState saves the terraform state of the given cluster into the configured backend or the data dir if there is none.
+// With the InMemoryState option, the state is also kept in memory, the file is only there for terraform until the operation finishes.
+func storeState(ops Options, mem *stateStore, state *statefile.File, project, cluster string, p types.ProviderType) error {
+	if ops.Backend != nil {
+		return stateToBackend(ops, state, *ops.Backend, project, cluster, p)
+	}
+	if ops.InMemoryState {
+		if err := mem.store(ops, state, project, cluster, p); err != nil {
+			return err
+		}
+	}
+	return stateToFile(state, ops, project, cluster, p)
+}
+
+// forgetState drops all resources from the terraform state of the given cluster.
+// The state file is removed from the data dir and memory, or replaced by an empty state in the configured backend.
+func forgetState(ops Options, mem *stateStore, project, cluster string, p types.ProviderType) error {
+	if ops.Backend == nil {
+		if ops.InMemoryState {
+			if err := mem.remove(ops, project, cluster, p); err != nil {
+				return err
+			}
+		}
+		return removeStateFile(ops, project, cluster, p)
+	}
+
+	sf, err := stateFromBackend(ops, *ops.Backend, project, cluster, p)
+	if errors.Is(err, types.ErrStateNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return stateToBackend(ops, statefile.New(states.NewState(), sf.Lineage, sf.Serial+1), *ops.Backend, project, cluster, p)
+}
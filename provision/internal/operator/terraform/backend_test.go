@@ -0,0 +1,247 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statemgr"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendAttributes(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		Name     string
+		Backend  types.BackendConfig
+		Expected map[string]string
+	}{
+		{
+			Name: "S3",
+			Backend: types.BackendConfig{
+				Type:        "s3",
+				Bucket:      "my-bucket",
+				Prefix:      "hydroform",
+				Region:      "eu-west-1",
+				Credentials: "/path/to/credentials",
+			},
+			Expected: map[string]string{
+				"bucket":                  "my-bucket",
+				"key":                     "hydroform/gcp/my-project/my-cluster/terraform.tfstate",
+				"region":                  "eu-west-1",
+				"shared_credentials_file": "/path/to/credentials",
+			},
+		},
+		{
+			Name: "S3 with lock table",
+			Backend: types.BackendConfig{
+				Type:      "s3",
+				Bucket:    "my-bucket",
+				LockTable: "terraform-locks",
+			},
+			Expected: map[string]string{
+				"bucket":         "my-bucket",
+				"key":            "gcp/my-project/my-cluster/terraform.tfstate",
+				"dynamodb_table": "terraform-locks",
+			},
+		},
+		{
+			Name: "GCS",
+			Backend: types.BackendConfig{
+				Type:        "gcs",
+				Bucket:      "my-bucket",
+				Credentials: "/path/to/credentials",
+			},
+			Expected: map[string]string{
+				"bucket":      "my-bucket",
+				"prefix":      "gcp/my-project/my-cluster",
+				"credentials": "/path/to/credentials",
+			},
+		},
+		{
+			Name: "Azure",
+			Backend: types.BackendConfig{
+				Type:   "azurerm",
+				Bucket: "my-container",
+				Config: map[string]string{"storage_account_name": "my-account"},
+			},
+			Expected: map[string]string{
+				"container_name":       "my-container",
+				"key":                  "gcp/my-project/my-cluster/terraform.tfstate",
+				"storage_account_name": "my-account",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		attrs, err := backendAttributes(tc.Backend, "my-project", "my-cluster", types.GCP)
+		require.NoError(t, err, tc.Name)
+		require.Equal(t, tc.Expected, attrs, tc.Name)
+	}
+
+	_, err := backendAttributes(types.BackendConfig{Type: "consul"}, "my-project", "my-cluster", types.GCP)
+	require.Error(t, err, "Unsupported backend types should fail")
+	_, err = backendAttributes(types.BackendConfig{Type: "gcs", Bucket: "my-bucket", LockTable: "terraform-locks"}, "my-project", "my-cluster", types.GCP)
+	require.Error(t, err, "A lock table should only be accepted by the s3 backend")
+}
+
+func TestWriteBackendFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-backend-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	b := types.BackendConfig{
+		Type:   "gcs",
+		Bucket: "my-bucket",
+	}
+	require.NoError(t, writeBackendFile(b, dir, "my-project", "my-cluster", types.GCP))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfBackendFile))
+	require.NoError(t, err)
+	require.Equal(t, `terraform {
+  backend "gcs" {
+    bucket = "my-bucket"
+    prefix = "gcp/my-project/my-cluster"
+  }
+}
+`, string(data))
+}
+
+func TestWriteBackendFileSecrets(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-backend-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	b := types.BackendConfig{
+		Type:        "azurerm",
+		Bucket:      "tfstate",
+		Credentials: "secret-key",
+		Config:      map[string]string{"storage_account_name": "hydroform"},
+	}
+	require.NoError(t, writeBackendFile(b, dir, "my-project", "my-cluster", types.Azure))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfBackendFile))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret-key", "The access key should not be written into the cluster dir")
+	require.Contains(t, string(data), `storage_account_name = "hydroform"`)
+	require.Equal(t, []string{"-backend-config=access_key=secret-key"}, backendConfigArgs(b), "The access key should be passed to init")
+
+	require.Empty(t, backendConfigArgs(types.BackendConfig{Type: "s3", Credentials: "/home/hydroform/.aws/credentials"}), "Paths to credentials files are not secrets")
+}
+
+func TestBackendStateMgrInvalidSetting(t *testing.T) {
+	t.Parallel()
+	b := types.BackendConfig{
+		Type:   "gcs",
+		Bucket: "my-bucket",
+		Config: map[string]string{"unknown_setting": "value"},
+	}
+
+	_, err := backendStateMgr(Options{}, b, "my-project", "my-cluster", types.GCP)
+	require.Error(t, err, "Settings not supported by the backend should fail")
+}
+
+func TestLockBackendState(t *testing.T) {
+	t.Parallel()
+	mgr := statemgr.NewFullFake(nil, nil)
+	unlock, err := lockBackendState(mgr, types.BackendConfig{Type: "gcs"})
+	require.NoError(t, err)
+
+	_, err = lockBackendState(mgr, types.BackendConfig{Type: "gcs"})
+	require.True(t, errors.Is(err, types.ErrStateLocked), "A locked state should fail")
+	var lockErr *types.StateLockError
+	require.True(t, errors.As(err, &lockErr))
+	require.NotEmpty(t, lockErr.ID)
+	require.Equal(t, "hydroform", lockErr.Operation)
+	require.Contains(t, lockErr.Who, "@", "The error should tell who holds the lock")
+
+	unlock()
+	unlock, err = lockBackendState(mgr, types.BackendConfig{Type: "gcs"})
+	require.NoError(t, err, "A released lock should be acquired")
+	unlock()
+}
+
+func TestParseStateLockError(t *testing.T) {
+	t.Parallel()
+	msg := `Error: Error locking state: Error acquiring the state lock: ConditionalCheckFailedException: The conditional request failed
+	status code: 400, request id: 3LGP6CHQ7G2QR51E8N9VK5RLLVVV4KQNSO5AEMVJF66Q9ASUAAJG
+Lock Info:
+  ID:        0c7f1a7e-5bd6-4bd4-39f1-4fbc1c0c8d2b
+  Path:      my-bucket/gcp/my-project/my-cluster/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       hydroform@replica-1
+  Version:   0.12.30
+  Created:   2021-03-04 10:15:30.123456789 +0000 UTC
+  Info:      
+
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time.`
+	require.Equal(t, &types.StateLockError{
+		ID:        "0c7f1a7e-5bd6-4bd4-39f1-4fbc1c0c8d2b",
+		Path:      "my-bucket/gcp/my-project/my-cluster/terraform.tfstate",
+		Operation: "OperationTypeApply",
+		Who:       "hydroform@replica-1",
+		Version:   "0.12.30",
+		Created:   time.Date(2021, 3, 4, 10, 15, 30, 123456789, time.UTC),
+	}, parseStateLockError(msg))
+
+	err := classifyError(errors.New(msg))
+	require.True(t, errors.Is(err, types.ErrStateLocked))
+	require.Contains(t, err.Error(), "by hydroform@replica-1 for OperationTypeApply since 2021-03-04T10:15:30Z")
+
+	require.Equal(t, &types.StateLockError{}, parseStateLockError("Error: Error acquiring the state lock: context deadline exceeded"), "A lock error without info should be typed")
+	require.Nil(t, parseStateLockError("Error: googleapi: Error 403: forbidden"))
+}
+
+func TestLockArgs(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, lockArgs(Options{}))
+	require.Empty(t, lockArgs(options(WithBackend(types.BackendConfig{Type: "gcs"}))), "Without timeout terraform should fail right away")
+	require.Equal(t, []string{"-lock-timeout=2m0s"}, lockArgs(options(WithBackend(types.BackendConfig{Type: "gcs", LockTimeout: 2 * time.Minute}))))
+}
+
+func TestBackendOptions(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-backend-options")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ops := options(WithDataDir(dir))
+	ops = backendOptions(ops, filepath.Join(dir, "my-cluster"))
+	require.Equal(t, dir, ops.DataDir(), "Without a backend the data dir should be shared")
+
+	ops = options(WithDataDir(dir), WithBackend(types.BackendConfig{Type: "azurerm", Bucket: "my-container", Credentials: "my-key"}))
+	require.Equal(t, filepath.Join(dir, sandboxPluginCacheDir), ops.PluginCacheDir, "The plugins should be cached in the data dir")
+	myDir, err := clusterDir(ops, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	otherDir, err := clusterDir(ops, "my-project", "other-cluster", types.GCP)
+	require.NoError(t, err)
+	myOps, otherOps := backendOptions(ops, myDir), backendOptions(ops, otherDir)
+	require.Equal(t, filepath.Join(myDir, backendDataDir), myOps.DataDir(), "Each cluster should have a data dir of its own")
+	require.NotEqual(t, myOps.DataDir(), otherOps.DataDir())
+	require.Equal(t, myDir, myOps.PathStrategy(dir, "my-project", "my-cluster", types.GCP), "The cluster dir should not move into the data dir of the cluster")
+
+	// both clusters can be locked at the same time
+	unlock, err := lockCluster(myOps, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	defer unlock()
+	unlockOther, err := lockCluster(otherOps, "my-project", "other-cluster", types.GCP)
+	require.NoError(t, err, "Operations on other clusters should not be locked")
+	unlockOther()
+
+	// the backend configuration init saved, with the access key, is removed
+	require.NoError(t, os.MkdirAll(myOps.DataDir(), 0700))
+	backendState := filepath.Join(myOps.DataDir(), tfBackendStateFile)
+	require.NoError(t, ioutil.WriteFile(backendState, []byte(`{"backend": {"config": {"access_key": "my-key"}}}`), 0600))
+	require.NoError(t, removeBackendConfig(myOps))
+	_, err = os.Stat(backendState)
+	require.True(t, os.IsNotExist(err), "The backend configuration should be removed")
+	require.NoError(t, removeBackendConfig(myOps), "Removing a missing backend configuration should not fail")
+}
@@ -0,0 +1,122 @@
+package terraform
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp/terraform-svchost/disco"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// defaultBatchWorkers is the number of clusters of a batch run at the same time without the BatchWorkers option.
+const defaultBatchWorkers = 4
+
+// CreateBatch provisions the clusters of the given requests concurrently, with up to BatchWorkers clusters at the same time, see WithBatchWorkers.
+// Each cluster is provisioned as by CreateWithContext, in its own directory and with its own lock, so the failure of a cluster does not affect the others.
+// The result of each request is at its index in the returned results, check their errors for the clusters that failed.
+// Once the context is done, the clusters not started yet are skipped with the error of the context, terraform stops the running ones gracefully,
+// and the results are returned with the error of the context if clusters were skipped. The batch fails before provisioning any cluster if a request lacks the project or the cluster_name,
+// or names the same cluster as another request.
+func (t *Terraform) CreateBatch(ctx context.Context, reqs []types.ClusterRequest) ([]types.BatchResult, error) {
+	return t.batch(ctx, reqs, func(ctx context.Context, op *Terraform, r types.ClusterRequest) (*types.ClusterInfo, error) {
+		return op.CreateWithContext(ctx, r.Provider, r.Config)
+	})
+}
+
+// DeleteBatch deprovisions the clusters of the given requests concurrently, as CreateBatch provisions them. Each cluster is deprovisioned as by DeleteWithContext,
+// with the state of its request, or the stored one if it has none.
+func (t *Terraform) DeleteBatch(ctx context.Context, reqs []types.ClusterRequest) ([]types.BatchResult, error) {
+	return t.batch(ctx, reqs, func(ctx context.Context, op *Terraform, r types.ClusterRequest) (*types.ClusterInfo, error) {
+		return nil, op.DeleteWithContext(ctx, r.State, r.Provider, r.Config)
+	})
+}
+
+// batch runs the given operation for each request in a pool of BatchWorkers goroutines and collects the results in the order of the requests.
+// Each request runs on its own operator, see requestOperator.
+func (t *Terraform) batch(ctx context.Context, reqs []types.ClusterRequest, run func(context.Context, *Terraform, types.ClusterRequest) (*types.ClusterInfo, error)) ([]types.BatchResult, error) {
+	results := make([]types.BatchResult, len(reqs))
+	clusters := make(map[string]int, len(reqs))
+	for i, r := range reqs {
+		project, _ := r.Config["project"].(string)
+		cluster, _ := r.Config["cluster_name"].(string)
+		if project == "" || cluster == "" {
+			return nil, errors.Errorf("the project and the cluster_name are needed for request %d of the batch", i)
+		}
+		// two operations on the same cluster would fail with ErrLocked, or run one after the other
+		key, err := stateKey(t.ops, project, cluster, r.Provider)
+		if err != nil {
+			return nil, err
+		}
+		if j, ok := clusters[key]; ok {
+			return nil, errors.Errorf("requests %d and %d of the batch are for the same cluster %s of project %s", j, i, cluster, project)
+		}
+		clusters[key] = i
+		results[i] = types.BatchResult{Provider: r.Provider, Project: project, Cluster: cluster}
+	}
+
+	workers := t.ops.BatchWorkers
+	if workers <= 0 {
+		workers = defaultBatchWorkers
+	}
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// each worker writes the result of its own requests only
+				results[i].ClusterInfo, results[i].Err = run(ctx, t.requestOperator(), reqs[i])
+			}
+		}()
+	}
+
+	skipped := len(reqs)
+queue:
+	for i := range reqs {
+		if ctx.Err() != nil {
+			skipped = i
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			skipped = i
+			break queue
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if skipped == len(reqs) {
+		return results, nil
+	}
+	for i := skipped; i < len(reqs); i++ {
+		results[i].Err = errors.Wrap(ctx.Err(), "the cluster was skipped")
+	}
+	return results, errors.Wrapf(ctx.Err(), "the batch was stopped with %d of %d clusters skipped", len(reqs)-skipped, len(reqs))
+}
+
+// requestOperator returns an operator for one request of a batch, with the options of t but its own terraform meta:
+// a UI of its own, so that the errors of the clusters are never mixed, and its own service discovery, which terraform changes on each init.
+// The operations in flight, the rotated credentials and the in-memory states are shared with t, so Shutdown and RotateCredentials cover the requests as well.
+func (t *Terraform) requestOperator() *Terraform {
+	ops := t.ops
+	if h, ok := ops.Ui.(*HydroUI); ok {
+		ops.Ui = h.fork()
+	}
+	if ops.Services != nil {
+		ops.Services = disco.NewWithCredentialsSource(ops.Services.CredentialsSource())
+	}
+	return &Terraform{
+		ops:      ops,
+		inflight: t.inflight,
+		rotated:  t.rotated,
+		states:   t.states,
+	}
+}
@@ -0,0 +1,88 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-batch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+
+output "endpoint" {
+  value = "https://${var.cluster_name}.example.com"
+}
+`)}}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), Persistent(), WithBatchWorkers(2))
+
+	var reqs []types.ClusterRequest
+	for i := 0; i < 3; i++ {
+		reqs = append(reqs, types.ClusterRequest{Provider: types.Kind, Config: map[string]interface{}{"project": "my-project", "cluster_name": fmt.Sprintf("cluster-%d", i)}})
+	}
+	// a cluster failing validation does not stop the others
+	reqs = append(reqs, types.ClusterRequest{Provider: types.GCP, Config: map[string]interface{}{"project": "my-project", "cluster_name": "invalid"}})
+
+	results, err := tf.CreateBatch(context.Background(), reqs)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	for i, r := range results[:3] {
+		require.NoError(t, r.Err)
+		require.Equal(t, fmt.Sprintf("cluster-%d", i), r.Cluster, "The results should be in the order of the requests")
+		require.Equal(t, fmt.Sprintf("https://cluster-%d.example.com", i), r.ClusterInfo.Endpoint)
+	}
+	require.Error(t, results[3].Err)
+	require.Equal(t, "invalid", results[3].Cluster)
+
+	results, err = tf.DeleteBatch(context.Background(), reqs[:3])
+	require.NoError(t, err)
+	for _, r := range results {
+		require.NoError(t, r.Err)
+		require.Nil(t, r.ClusterInfo)
+	}
+
+	_, err = tf.CreateBatch(context.Background(), append(reqs[:1:1], reqs[0]))
+	require.Error(t, err, "The same cluster should not be requested twice")
+	_, err = tf.CreateBatch(context.Background(), []types.ClusterRequest{{Provider: types.Kind, Config: map[string]interface{}{"project": "my-project"}}})
+	require.Error(t, err, "The cluster name should be required")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = tf.CreateBatch(ctx, reqs[:3])
+	require.True(t, errors.Is(err, context.Canceled), "The batch should stop with the context")
+	for _, r := range results {
+		require.True(t, errors.Is(r.Err, context.Canceled), "The clusters should be skipped")
+	}
+}
+
+func TestRequestOperator(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	tf := New(WithOutputWriter(&out))
+
+	op := tf.requestOperator()
+	require.NotSame(t, tf.ops.Ui, op.ops.Ui, "Each request should get its own UI")
+	require.NotSame(t, tf.ops.Services, op.ops.Services, "Each request should get its own service discovery")
+	require.Same(t, tf.inflight, op.inflight, "The operations in flight should be shared")
+	require.Same(t, tf.rotated, op.rotated, "The rotated credentials should be shared")
+	require.Same(t, tf.states, op.states, "The in-memory states should be shared")
+	require.NotSame(t, tf.states, New().states, "Each operator should keep its own in-memory states")
+
+	op.ops.Ui.Error("ERROR")
+	require.Empty(t, tf.ops.Ui.(*HydroUI).Errors(), "The errors of a request should not reach the operator")
+	require.Equal(t, "ERROR\n", out.String(), "The output of a request should still be written")
+}
@@ -0,0 +1,105 @@
+package terraform
+
+import (
+	"context"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// cloneExcludedKeys are the configuration keys that identify the source cluster itself rather than its shape, they are not copied into a clone.
+var cloneExcludedKeys = []string{"cluster_id"}
+
+// Clone creates a new cluster with the configuration of an existing one, such as to create a staging copy of a production cluster.
+// The configuration of the source cluster is copied and the destination configuration overrides its values, it must at least set another
+// project or cluster_name. A nil value in the destination configuration removes the key from the copy. The source cluster is neither locked
+// nor read, its state is never referenced by the new cluster. The new cluster is then created as with Create.
+func (t *Terraform) Clone(srcCfg, dstCfg map[string]interface{}, p types.ProviderType) (*types.ClusterInfo, error) {
+	return t.CloneWithContext(context.Background(), srcCfg, dstCfg, p)
+}
+
+// CloneWithContext works as Clone but stops terraform gracefully when the given context is done.
+func (t *Terraform) CloneWithContext(ctx context.Context, srcCfg, dstCfg map[string]interface{}, p types.ProviderType) (*types.ClusterInfo, error) {
+	cfg, err := cloneConfig(srcCfg, dstCfg)
+	if err != nil {
+		return nil, err
+	}
+	return t.CreateWithContext(ctx, p, cfg)
+}
+
+// cloneConfig returns a copy of the source configuration with the values of the destination configuration.
+// It fails if the copy would have the project and cluster_name of the source, terraform would then apply it to the source cluster.
+func cloneConfig(src, dst map[string]interface{}) (map[string]interface{}, error) {
+	cfg := make(map[string]interface{}, len(src)+len(dst))
+	for k, v := range src {
+		if !contains(cloneExcludedKeys, k) {
+			cfg[k] = copyValue(v)
+		}
+	}
+	for k, v := range dst {
+		if v == nil {
+			delete(cfg, k)
+			continue
+		}
+		cfg[k] = copyValue(v)
+	}
+
+	if stringValue(cfg["project"]) == stringValue(src["project"]) && stringValue(cfg["cluster_name"]) == stringValue(src["cluster_name"]) {
+		return nil, errors.Errorf("the clone of the cluster %s in the project %s needs another project or cluster_name", stringValue(src["cluster_name"]), stringValue(src["project"]))
+	}
+	return cfg, nil
+}
+
+// copyValue returns a copy of the lists and maps of a configuration value, so the clone does not share them with the source.
+// Other values are returned as they are.
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case []string:
+		return append([]string(nil), t...)
+	case []types.NodePool:
+		pools := make([]types.NodePool, 0, len(t))
+		for _, np := range t {
+			np.Labels = copyValue(np.Labels).(map[string]string)
+			np.Taints = append([]types.Taint(nil), np.Taints...)
+			if np.Autoscaling != nil {
+				a := *np.Autoscaling
+				np.Autoscaling = &a
+			}
+			if np.Upgrade != nil {
+				u := *np.Upgrade
+				np.Upgrade = &u
+			}
+			pools = append(pools, np)
+		}
+		return pools
+	case []types.AdditionalProvider:
+		providers := make([]types.AdditionalProvider, 0, len(t))
+		for _, a := range t {
+			a.Settings = copyValue(a.Settings).(map[string]string)
+			providers = append(providers, a)
+		}
+		return providers
+	case map[string]string:
+		if t == nil {
+			return t
+		}
+		m := make(map[string]string, len(t))
+		for k, s := range t {
+			m[k] = s
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, 0, len(t))
+		for _, e := range t {
+			l = append(l, copyValue(e))
+		}
+		return l
+	}
+	return v
+}
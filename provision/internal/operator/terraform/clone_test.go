@@ -0,0 +1,78 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneConfig(t *testing.T) {
+	t.Parallel()
+	src := map[string]interface{}{
+		"project":      "my-project",
+		"cluster_name": "production",
+		"cluster_id":   "12345",
+		"machine_type": "n1-standard-4",
+		"dns_domain":   "prod.example.com",
+		"zones":        []string{"europe-west3-a"},
+		"labels":       map[string]string{"env": "production"},
+		"node_pools":   []types.NodePool{{Name: "gpu", Labels: map[string]string{"gpu": "true"}, Autoscaling: &types.Autoscaling{Enabled: true, MaxCount: 3}}},
+	}
+
+	cfg, err := cloneConfig(src, map[string]interface{}{"cluster_name": "staging", "dns_domain": nil, "labels": map[string]string{"env": "staging"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"project":      "my-project",
+		"cluster_name": "staging",
+		"machine_type": "n1-standard-4",
+		"zones":        []string{"europe-west3-a"},
+		"labels":       map[string]string{"env": "staging"},
+		"node_pools":   []types.NodePool{{Name: "gpu", Labels: map[string]string{"gpu": "true"}, Autoscaling: &types.Autoscaling{Enabled: true, MaxCount: 3}}},
+	}, cfg, "The clone should have the source values without the cluster ID, overridden by the destination")
+
+	cfg["zones"].([]string)[0] = "europe-west3-b"
+	pools := cfg["node_pools"].([]types.NodePool)
+	pools[0].Labels["gpu"] = "false"
+	pools[0].Autoscaling.MaxCount = 5
+	require.Equal(t, []string{"europe-west3-a"}, src["zones"], "The clone should not share its lists with the source")
+	require.Equal(t, "true", src["node_pools"].([]types.NodePool)[0].Labels["gpu"], "The clone should not share the node pool labels with the source")
+	require.Equal(t, 3, src["node_pools"].([]types.NodePool)[0].Autoscaling.MaxCount, "The clone should not share the node pool autoscaling with the source")
+
+	_, err = cloneConfig(src, map[string]interface{}{"machine_type": "n1-standard-8"})
+	require.Error(t, err, "A clone with the project and name of the source should be refused")
+	_, err = cloneConfig(src, map[string]interface{}{"project": "other-project"})
+	require.NoError(t, err, "A clone in another project can keep the name")
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-clone")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+variable "size" {}
+
+output "endpoint" { value = "https://${var.cluster_name}-${var.size}.example.com" }
+output "kubeconfig" { value = "kubeconfig" }
+`)}}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), Persistent())
+	src := map[string]interface{}{"project": "my-project", "cluster_name": "production", "size": "large"}
+
+	info, err := tf.Clone(src, map[string]interface{}{"cluster_name": "staging"}, types.Kind)
+	require.NoError(t, err)
+	require.Equal(t, "https://staging-large.example.com", info.Endpoint)
+
+	_, err = tf.ClusterInfo(types.Kind, src)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "The source cluster should not be touched by the clone")
+
+	_, err = tf.Clone(src, map[string]interface{}{"size": "small"}, types.Kind)
+	require.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	// cloudProfileResource is the Gardener API resource of the cloud profiles, which list what the shoots of a target provider can use.
+	cloudProfileResource = schema.GroupVersionResource{Group: "core.gardener.cloud", Version: "v1beta1", Resource: "cloudprofiles"}
+	// controllerRegistrationResource is the Gardener API resource of the extensions installed in the garden, with the resource types they support.
+	controllerRegistrationResource = schema.GroupVersionResource{Group: "core.gardener.cloud", Version: "v1beta1", Resource: "controllerregistrations"}
+)
+
+// checkGarden checks the shoot of a Gardener configuration against the garden before terraform applies it, since an unsupported region, zone,
+// machine type, machine image, volume type or DNS provider is only reported by Gardener once the shoot is reconciled:
+// the fields are checked against the cloud profile of the configuration, and the DNS provider against the DNS record types the garden supports.
+// It returns a ValidationError listing the fields the garden does not offer. Other providers, custom templates
+// and configurations without cloud profile nor DNS provider are not checked.
+func checkGarden(ctx context.Context, ops Options, p types.ProviderType, cfg map[string]interface{}) error {
+	if p != types.Gardener {
+		return nil
+	}
+	if _, ok := ops.Templates[p]; ok {
+		return nil
+	}
+	profile, dnsProvider := stringValue(cfg["target_profile"]), stringValue(cfg["dns_provider"])
+	if profile == "" && dnsProvider == "" {
+		return nil
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", stringValue(cfg["credentials_file_path"]))
+	if err != nil {
+		return errors.Wrap(err, "could not load the garden kubeconfig")
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return errors.Wrap(err, "could not create the Gardener client")
+	}
+
+	verr := &types.ValidationError{}
+	if profile != "" {
+		fields, err := cloudProfileErrors(ctx, client, profile, cfg)
+		if err != nil {
+			return err
+		}
+		verr.Fields = append(verr.Fields, fields...)
+	}
+	if dnsProvider != "" {
+		fields, err := dnsProviderErrors(ctx, client, dnsProvider)
+		if err != nil {
+			return err
+		}
+		verr.Fields = append(verr.Fields, fields...)
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// cloudProfileErrors reads the cloud profile with the given name and returns an error for each field of the configuration it does not offer.
+func cloudProfileErrors(ctx context.Context, client dynamic.Interface, name string, cfg map[string]interface{}) ([]types.FieldError, error) {
+	profile, err := client.Resource(cloudProfileResource).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return []types.FieldError{{Field: "target_profile", Reason: fmt.Sprintf("is not a cloud profile of the garden, got %s", name)}}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(classifyError(err), "could not read the cloud profile %s", name)
+	}
+
+	var fields []types.FieldError
+	// what describes the value in the reason, such as "the region europe-west1"
+	offered := func(field, what, value string, names []string) {
+		if value != "" && len(names) > 0 && !contains(names, value) {
+			fields = append(fields, types.FieldError{
+				Field:  field,
+				Reason: fmt.Sprintf("%s is not offered by the cloud profile %s, use one of: %s", what, name, strings.Join(names, ", ")),
+			})
+		}
+	}
+
+	location := stringValue(cfg["location"])
+	regions := profileItems(profile, "regions")
+	offered("location", "the region "+location, location, itemNames(regions, "name"))
+	if region := findItem(regions, location); region != nil {
+		zones := itemNames(nestedItems(region, "zones"), "name")
+		for _, z := range stringValues(cfg["zones"]) {
+			offered("zones", "the zone "+z, z, zones)
+		}
+	}
+
+	machineType, diskType := stringValue(cfg["machine_type"]), stringValue(cfg["disk_type"])
+	offered("machine_type", "the machine type "+machineType, machineType, itemNames(profileItems(profile, "machineTypes"), "name"))
+	offered("disk_type", "the volume type "+diskType, diskType, itemNames(profileItems(profile, "volumeTypes"), "name"))
+
+	image := stringValue(cfg["machine_image_name"])
+	images := profileItems(profile, "machineImages")
+	offered("machine_image_name", "the machine image "+image, image, itemNames(images, "name"))
+	if found := findItem(images, image); found != nil {
+		version := stringValue(cfg["machine_image_version"])
+		offered("machine_image_version", fmt.Sprintf("the version %s of the machine image %s", version, image), version, itemNames(nestedItems(found, "versions"), "version"))
+	}
+
+	return fields, nil
+}
+
+// profileItems returns the items of the given list in the spec of the Gardener resource, such as a cloud profile.
+func profileItems(profile *unstructured.Unstructured, list string) []interface{} {
+	items, _, _ := unstructured.NestedSlice(profile.Object, "spec", list)
+	return items
+}
+
+// nestedItems returns the items of the given list of an item of the cloud profile.
+func nestedItems(item map[string]interface{}, list string) []interface{} {
+	items, _, _ := unstructured.NestedSlice(item, list)
+	return items
+}
+
+// itemNames returns the values of the given field of the items.
+func itemNames(items []interface{}, field string) []string {
+	var names []string
+	for _, i := range items {
+		if m, ok := i.(map[string]interface{}); ok {
+			if n, ok := m[field].(string); ok {
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+// findItem returns the item with the given name, or nil if there is none.
+func findItem(items []interface{}, name string) map[string]interface{} {
+	for _, i := range items {
+		if m, ok := i.(map[string]interface{}); ok && m["name"] == name {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func testCloudProfile() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "core.gardener.cloud/v1beta1",
+		"kind":       "CloudProfile",
+		"metadata":   map[string]interface{}{"name": "gcp"},
+		"spec": map[string]interface{}{
+			"regions": []interface{}{
+				map[string]interface{}{
+					"name":  "europe-west4",
+					"zones": []interface{}{map[string]interface{}{"name": "europe-west4-a"}, map[string]interface{}{"name": "europe-west4-b"}},
+				},
+			},
+			"machineTypes": []interface{}{map[string]interface{}{"name": "n1-standard-4"}},
+			"volumeTypes":  []interface{}{map[string]interface{}{"name": "pd-standard"}, map[string]interface{}{"name": "pd-ssd"}},
+			"machineImages": []interface{}{
+				map[string]interface{}{
+					"name":     "gardenlinux",
+					"versions": []interface{}{map[string]interface{}{"version": "318.8.0"}},
+				},
+			},
+		},
+	}}
+}
+
+func TestCloudProfileErrors(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme(), testCloudProfile())
+	cfg := map[string]interface{}{
+		"location":              "europe-west4",
+		"zones":                 []string{"europe-west4-b"},
+		"machine_type":          "n1-standard-4",
+		"disk_type":             "pd-ssd",
+		"machine_image_name":    "gardenlinux",
+		"machine_image_version": "318.8.0",
+	}
+	fields, err := cloudProfileErrors(context.Background(), client, "gcp", cfg)
+	require.NoError(t, err)
+	require.Empty(t, fields)
+
+	cfg["zones"] = []string{"europe-west4-a", "europe-west4-c"}
+	cfg["machine_image_version"] = "27.1.0"
+	fields, err = cloudProfileErrors(context.Background(), client, "gcp", cfg)
+	require.NoError(t, err)
+	require.Equal(t, []types.FieldError{
+		{Field: "zones", Reason: "the zone europe-west4-c is not offered by the cloud profile gcp, use one of: europe-west4-a, europe-west4-b"},
+		{Field: "machine_image_version", Reason: "the version 27.1.0 of the machine image gardenlinux is not offered by the cloud profile gcp, use one of: 318.8.0"},
+	}, fields, "Values the profile does not offer should fail the validation")
+
+	cfg["location"] = "us-east1"
+	cfg["machine_image_name"] = "coreos"
+	fields, err = cloudProfileErrors(context.Background(), client, "gcp", cfg)
+	require.NoError(t, err)
+	require.Len(t, fields, 2, "The zones and versions of an unknown region or image cannot be checked")
+	require.Equal(t, "location", fields[0].Field)
+	require.Equal(t, "machine_image_name", fields[1].Field)
+
+	fields, err = cloudProfileErrors(context.Background(), client, "aws", cfg)
+	require.NoError(t, err)
+	require.Len(t, fields, 1, "A missing profile should fail the validation")
+	require.Equal(t, "target_profile", fields[0].Field)
+}
+
+func TestCheckGardenSkipped(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, checkGarden(context.Background(), options(), types.GCP, map[string]interface{}{"target_profile": "gcp"}))
+	require.NoError(t, checkGarden(context.Background(), options(), types.Gardener, map[string]interface{}{}), "Configurations without profile nor DNS provider should not be checked")
+	require.NoError(t, checkGarden(context.Background(), options(WithTemplate(types.Gardener, fstest.MapFS{})), types.Gardener, map[string]interface{}{"target_profile": "gcp"}))
+}
+
+func TestExpandGardenerSeedName(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{"target_provider": "gcp"}
+	tf, err := expandGardenerClusterTemplate(cfg)
+	require.NoError(t, err)
+	require.NotContains(t, tf, "seed_name = var.seed_name", "Gardener should pick the seed if the configuration has none")
+
+	cfg["seed_name"] = "gcp-eu1"
+	tf, err = expandGardenerClusterTemplate(cfg)
+	require.NoError(t, err)
+	require.Contains(t, tf, "seed_name = var.seed_name")
+}
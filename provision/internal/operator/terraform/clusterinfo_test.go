@@ -0,0 +1,75 @@
+package terraform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+	yamlv2 "gopkg.in/yaml.v2"
+	"sigs.k8s.io/yaml"
+)
+
+func TestClusterInfoRoundTrip(t *testing.T) {
+	t.Parallel()
+	sf := clusterState("google_container_cluster", "gke_cluster", "google", `{"id": "projects/my-project/locations/europe-west3/clusters/my-cluster", "name": "my-cluster"}`)
+	sf.Lineage, sf.Serial = "lineage", 3
+	info := &types.ClusterInfo{
+		Endpoint:                 "https://my-cluster.example.com",
+		CertificateAuthorityData: []byte("ca"),
+		Kubeconfig:               "kubeconfig",
+		Outputs:                  map[string]interface{}{"endpoint": "https://my-cluster.example.com"},
+		SensitiveOutputs:         []string{"kubeconfig"},
+		Autoscaling:              map[string]bool{"gpu": true},
+		Zones:                    []string{"europe-west3-a"},
+		ApplySummary:             &types.ApplySummary{Added: 1, AddedResources: []string{"google_container_cluster.gke_cluster"}},
+		InternalState:            &types.InternalState{TerraformState: sf},
+		Status:                   &types.ClusterStatus{Phase: types.Provisioned},
+	}
+	// the states are compared in the format of the state files, they hold pointers
+	requireSameInfo := func(t *testing.T, decoded *types.ClusterInfo) {
+		require.NotNil(t, decoded.InternalState)
+		require.NotNil(t, decoded.TerraformState())
+		require.Equal(t, "lineage", decoded.TerraformState().Lineage)
+		require.Equal(t, uint64(3), decoded.TerraformState().Serial)
+		want, err := json.Marshal(info.InternalState)
+		require.NoError(t, err)
+		got, err := json.Marshal(decoded.InternalState)
+		require.NoError(t, err)
+		require.JSONEq(t, string(want), string(got))
+
+		withoutState := *decoded
+		withoutState.InternalState = info.InternalState
+		require.Equal(t, info, &withoutState)
+	}
+
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"phase":"Provisioned"`, "The phase should be a string")
+	require.Contains(t, string(data), `"lineage":"lineage"`, "The state should be in the format of the state files")
+	decoded := &types.ClusterInfo{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	requireSameInfo(t, decoded)
+
+	data, err = yaml.Marshal(info)
+	require.NoError(t, err)
+	decoded = &types.ClusterInfo{}
+	require.NoError(t, yaml.Unmarshal(data, decoded))
+	requireSameInfo(t, decoded)
+
+	data, err = yamlv2.Marshal(info)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "phase: Provisioned", "The YAML tags should be used")
+	decoded = &types.ClusterInfo{}
+	require.NoError(t, yamlv2.Unmarshal(data, decoded))
+	requireSameInfo(t, decoded)
+
+	// without state the internal state stays empty
+	data, err = json.Marshal(&types.ClusterInfo{InternalState: &types.InternalState{}})
+	require.NoError(t, err)
+	decoded = &types.ClusterInfo{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.Nil(t, decoded.TerraformState())
+
+	require.Error(t, json.Unmarshal([]byte(`{"internalState": {"terraformState": {"version": 99}}}`), &types.ClusterInfo{}), "An unreadable state should fail")
+}
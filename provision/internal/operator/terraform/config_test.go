@@ -0,0 +1,114 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderConfigs(t *testing.T) {
+	t.Parallel()
+	id := types.ClusterConfig{Project: "my-project", ClusterName: "my-cluster"}
+	configs := []types.ProviderConfig{
+		types.GCPConfig{
+			ClusterConfig:       id,
+			CredentialsFilePath: "/path/to/key.json",
+			Location:            "europe-west3",
+			NodeCount:           3,
+			MachineType:         "n1-standard-4",
+			DiskSizeGB:          30,
+			KubernetesVersion:   "1.19",
+			Labels:              map[string]string{"team": "hydroform"},
+		},
+		types.GCPConfig{
+			ClusterConfig:       id,
+			CredentialsFilePath: "/path/to/key.json",
+			Location:            "europe-west3",
+			NodeCount:           3,
+			MachineType:         "n1-standard-4",
+			DiskSizeGB:          30,
+			ReleaseChannel:      "STABLE",
+		},
+		types.AzureConfig{
+			ClusterConfig:     id,
+			Location:          "westeurope",
+			NodeCount:         3,
+			MachineType:       "Standard_D4_v3",
+			DiskSizeGB:        30,
+			KubernetesVersion: "1.19.7",
+		},
+		types.AWSConfig{
+			ClusterConfig:     id,
+			Region:            "eu-central-1",
+			NodeCount:         3,
+			MachineType:       "m5.xlarge",
+			DiskSizeGB:        30,
+			KubernetesVersion: "1.19",
+		},
+		types.GardenerConfig{
+			ClusterConfig:       types.ClusterConfig{Project: "my-proj", ClusterName: "my-cluster"},
+			CredentialsFilePath: "/path/to/kubeconfig",
+			TargetProvider:      "azure",
+			TargetProfile:       "az",
+			TargetSecret:        "my-secret",
+			Location:            "westeurope",
+			Zones:               []string{"1"},
+			NodeCount:           3,
+			MachineType:         "Standard_D4_v3",
+			DiskSizeGB:          30,
+			KubernetesVersion:   "1.19.7",
+			VnetCIDR:            "10.250.0.0/16",
+		},
+		types.KindConfig{ClusterConfig: id, NodeImage: "kindest/node:v1.19.1"},
+	}
+	for _, cfg := range configs {
+		require.NoError(t, validateConfig(cfg.Provider(), cfg.ToMap()), "The typed configuration of %s should be valid", cfg.Provider())
+	}
+}
+
+func TestProviderConfigToMap(t *testing.T) {
+	t.Parallel()
+	m := types.AzureConfig{
+		ClusterConfig: types.ClusterConfig{Project: "my-project", ClusterName: "my-cluster", Custom: map[string]interface{}{"extra": "value", "project": "other"}, ExtraVars: map[string]interface{}{"max_pods": 110}},
+	}.ToMap()
+	require.Equal(t, "my-project", m["resource_group"], "The resource group should default to the project")
+	require.Equal(t, "my-project", m["project"], "The typed fields should take precedence over the custom values")
+	require.Equal(t, "value", m["extra"])
+	require.Equal(t, map[string]interface{}{"max_pods": 110}, m["extra_vars"])
+	require.NotContains(t, m, "additional_providers")
+	require.NotContains(t, m, "kubeconfig_context_name")
+
+	m = types.GCPConfig{ClusterConfig: types.ClusterConfig{AdditionalProviders: []types.AdditionalProvider{{Provider: types.AWS, Alias: "dns"}}, KubeconfigContextName: "ci-42"}}.ToMap()
+	require.Equal(t, "ci-42", m["kubeconfig_context_name"])
+	require.Equal(t, []types.AdditionalProvider{{Provider: types.AWS, Alias: "dns"}}, m["additional_providers"])
+	require.NotContains(t, m, "private_cluster", "Optional fields left empty should not be set")
+	require.NotContains(t, m, "labels")
+
+	m = types.GardenerConfig{
+		ClusterConfig:  types.ClusterConfig{Project: "my-proj"},
+		TargetProvider: "gcp",
+		WorkerCIDR:     "10.250.0.0/19",
+	}.ToMap()
+	require.Equal(t, "garden-my-proj", m["namespace"])
+	require.Equal(t, "10.250.0.0/19", m["networking_nodes"], "GCP shoots should use the worker range for the nodes")
+	require.Equal(t, "false", m["zoned"])
+	require.Equal(t, []string{}, m["zones"])
+}
+
+func TestCreateTyped(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-typed")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = New(WithDataDir(dir)).CreateTyped(types.GCPConfig{
+		ClusterConfig: types.ClusterConfig{Project: "my-project", ClusterName: "my-cluster"},
+		Location:      "europe-west3",
+	})
+	var verr *types.ValidationError
+	require.True(t, errors.As(err, &verr), "The configuration map of the typed configuration should be validated")
+}
@@ -0,0 +1,251 @@
+package terraform
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	credentialsFile     = "credentials"
+	credentialsVarsFile = "credentials.tfvars"
+	// credentialVarsKey is the key of the credentialVars in the configuration, it is not a valid variable name
+	credentialVarsKey = "hydroform:credentials"
+)
+
+// credentialVars are the variables of a configuration set from the credentials of the options, see withCredentials.
+// They are written into the vars file in the private directory of the credentials instead of the vars file of the cluster, see writeVarsFile,
+// which stays on disk with the Persistent option, and the directory is removed once the operation finishes.
+type credentialVars struct {
+	file  string
+	names map[string]bool
+	// providerArgs are the vars of the arguments of the additional provider blocks, by index of the block, see withAdditionalProviderCredentials
+	providerArgs map[int]map[string]string
+}
+
+// copy returns a copy of the vars, to add the ones of the additional providers to.
+func (v *credentialVars) copy() *credentialVars {
+	c := &credentialVars{file: v.file, names: make(map[string]bool, len(v.names)), providerArgs: make(map[int]map[string]string, len(v.providerArgs))}
+	for name := range v.names {
+		c.names[name] = true
+	}
+	for i, args := range v.providerArgs {
+		c.providerArgs[i] = make(map[string]string, len(args))
+		for arg, name := range args {
+			c.providerArgs[i][arg] = name
+		}
+	}
+	return c
+}
+
+// providerVar returns true if the given var is the one of an argument of an additional provider block.
+func (v *credentialVars) providerVar(name string) bool {
+	for _, args := range v.providerArgs {
+		for _, n := range args {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withCredentials returns a copy of the configuration that authenticates with the in-memory credentials of the provider.
+// The credentials file is written to a private temporary directory and passed to the provider as "credentials_file_path",
+// the other values are set as provider variables, all of them are credentialVars written into the same directory.
+// Nothing is set in the environment, since it is shared by the whole process and the provider plugins of concurrent operations
+// would see each other's credentials. The returned function removes the credentials and must be called once the operation finishes.
+func withCredentials(p types.ProviderType, cfg map[string]interface{}, creds map[types.ProviderType]types.Credentials) (map[string]interface{}, func() error, error) {
+	c, ok := creds[p]
+	if !ok || len(c.File) == 0 && len(c.Values) == 0 {
+		return cfg, noCleanup, nil
+	}
+
+	dir, err := ioutil.TempDir("", "hydroform-credentials")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not create the credentials directory")
+	}
+	remove := func() error {
+		return removeAll(dir)
+	}
+
+	scoped := make(map[string]interface{}, len(cfg)+len(c.Values)+2)
+	for k, v := range cfg {
+		scoped[k] = v
+	}
+	vars := &credentialVars{file: filepath.Join(dir, credentialsVarsFile), names: make(map[string]bool, len(c.Values)+1), providerArgs: make(map[int]map[string]string)}
+	for k, v := range c.Values {
+		scoped[k] = v
+		vars.names[k] = true
+	}
+	scoped[credentialVarsKey] = vars
+
+	if len(c.File) == 0 {
+		return scoped, remove, nil
+	}
+	path := filepath.Join(dir, credentialsFile)
+	if err := ioutil.WriteFile(path, c.File, 0600); err != nil {
+		if rerr := remove(); rerr != nil {
+			return nil, nil, errors.Wrapf(err, "could not write the credentials file and %s", rerr)
+		}
+		return nil, nil, errors.Wrap(err, "could not write the credentials file")
+	}
+	// forward slashes keep windows paths valid in the tfvars file
+	scoped["credentials_file_path"] = filepath.ToSlash(path)
+	vars.names["credentials_file_path"] = true
+
+	return scoped, remove, nil
+}
+
+func noCleanup() error {
+	return nil
+}
+
+// credentials returns a copy of the configuration that authenticates with the credentials of the options, see withCredentials.
+// The credentials set by RotateCredentials for the cluster replace the ones of its provider.
+// With the SecretCredentials option, the secret is read for each operation and replaces the in-memory credentials of the provider.
+// The additional providers of the configuration authenticate with the same credentials of their provider, see withAdditionalProviderCredentials,
+// so the blocks of the provider of the cluster get the rotated credentials and the ones of the secret as well.
+func (t *Terraform) credentials(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (map[string]interface{}, func() error, error) {
+	creds := make(map[types.ProviderType]types.Credentials, len(t.ops.Credentials)+1)
+	for provider, c := range t.ops.Credentials {
+		creds[provider] = c
+	}
+	if c, ok := t.rotated.load(t.ops, p, cfg); ok {
+		creds[p] = c
+	}
+	if t.ops.SecretCredentials != nil {
+		c, err := secretCredentials(ctx, t.ops.SecretCredentials)
+		if err != nil {
+			return nil, nil, err
+		}
+		creds[p] = c
+	}
+	scoped, remove, err := withCredentials(p, cfg, creds)
+	if err != nil {
+		return nil, nil, err
+	}
+	scoped, removeAdditional, err := withAdditionalProviderCredentials(scoped, creds)
+	if err != nil {
+		if rerr := remove(); rerr != nil {
+			return nil, nil, errors.Wrapf(err, "could not remove the credentials of the cluster (%s)", rerr)
+		}
+		return nil, nil, err
+	}
+	return scoped, func() error {
+		rerr := removeAdditional()
+		if err := remove(); err != nil {
+			return err
+		}
+		return rerr
+	}, nil
+}
+
+// secretCredentials reads the credentials of the given Kubernetes Secret. It fails if a key of the mapping is not in the secret.
+func secretCredentials(ctx context.Context, s *types.SecretCredentials) (types.Credentials, error) {
+	secret, err := s.Client.CoreV1().Secrets(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+	if err != nil {
+		return types.Credentials{}, errors.Wrapf(classifyError(err), "could not read the credentials secret %s/%s", s.Namespace, s.Name)
+	}
+
+	mapping := s.KeyMapping
+	if mapping == nil {
+		mapping = make(map[string]string, len(secret.Data))
+		for key := range secret.Data {
+			mapping[key] = key
+		}
+	}
+
+	c := types.Credentials{Values: make(map[string]string)}
+	for key, name := range mapping {
+		value, ok := secret.Data[key]
+		if !ok {
+			return types.Credentials{}, errors.Errorf("the credentials secret %s/%s has no key %s", s.Namespace, s.Name, key)
+		}
+		if name == "credentials_file_path" {
+			c.File = value
+		} else {
+			c.Values[name] = string(value)
+		}
+	}
+	return c, nil
+}
+
+// RotateCredentials replaces the credentials of the cluster of the given configuration for the following operations of the operator,
+// such as after a scheduled rotation of the service account keys, without changing the cluster resources.
+// The new credentials are checked against the provider first on GCP, Azure and AWS: if the provider rejects them, it fails with ErrAuthFailed
+// and the operations keep the previous credentials. They replace the Credentials option of the provider for this cluster only,
+// and are kept in memory by the operator, they are lost when the process ends.
+// With the SecretCredentials option, the secret is read by each operation: rotate the secret instead, RotateCredentials fails.
+func (t *Terraform) RotateCredentials(p types.ProviderType, cfg map[string]interface{}, newCreds types.Credentials) error {
+	return t.RotateCredentialsWithContext(context.Background(), p, cfg, newCreds)
+}
+
+// RotateCredentialsWithContext works as RotateCredentials but stops checking the credentials when the given context is done.
+func (t *Terraform) RotateCredentialsWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}, newCreds types.Credentials) error {
+	if t.ops.SecretCredentials != nil {
+		return errors.Errorf("the credentials are read from the secret %s/%s by each operation, rotate the secret instead", t.ops.SecretCredentials.Namespace, t.ops.SecretCredentials.Name)
+	}
+	if stringValue(cfg["project"]) == "" || stringValue(cfg["cluster_name"]) == "" {
+		return errors.New("the project and the cluster_name are needed to rotate the credentials of the cluster")
+	}
+	if len(newCreds.File) == 0 && len(newCreds.Values) == 0 {
+		return errors.New("the new credentials are empty")
+	}
+	if t.rotated == nil {
+		return errors.New("the credentials can only be rotated by operators created with New")
+	}
+
+	if newProbe, ok := preflightProbes[p]; ok {
+		scoped, remove, err := withCredentials(p, cfg, map[types.ProviderType]types.Credentials{p: newCreds})
+		if err != nil {
+			return err
+		}
+		defer remove()
+		probe, err := newProbe(ctx, scoped, proxyTransport(t.ops))
+		if err != nil {
+			return errors.Wrap(err, "could not load the new credentials")
+		}
+		if err := probe.authenticate(ctx); err != nil {
+			return errors.Wrapf(types.ErrAuthFailed, "%s rejected the new credentials: %s", p, err)
+		}
+	}
+
+	return t.rotated.store(t.ops, p, cfg, newCreds)
+}
+
+// credentialStore keeps the credentials set by RotateCredentials by cluster.
+type credentialStore struct {
+	mu    sync.Mutex
+	creds map[string]types.Credentials
+}
+
+func (s *credentialStore) load(ops Options, p types.ProviderType, cfg map[string]interface{}) (types.Credentials, bool) {
+	if s == nil {
+		return types.Credentials{}, false
+	}
+	key, err := stateKey(ops, stringValue(cfg["project"]), stringValue(cfg["cluster_name"]), p)
+	if err != nil {
+		return types.Credentials{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.creds[key]
+	return c, ok
+}
+
+func (s *credentialStore) store(ops Options, p types.ProviderType, cfg map[string]interface{}, c types.Credentials) error {
+	key, err := stateKey(ops, stringValue(cfg["project"]), stringValue(cfg["cluster_name"]), p)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds[key] = c
+	return nil
+}
@@ -0,0 +1,138 @@
+package terraform
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWithCredentials(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{"cluster_name": "my-cluster"}
+
+	// no credentials for the provider
+	scoped, remove, err := withCredentials(types.GCP, cfg, map[types.ProviderType]types.Credentials{types.AWS: {File: []byte("aws")}})
+	require.NoError(t, err)
+	require.NoError(t, remove())
+	require.Equal(t, cfg, scoped, "The configuration should not change without credentials for the provider")
+
+	// values only
+	scoped, remove, err = withCredentials(types.OpenStack, cfg, map[types.ProviderType]types.Credentials{
+		types.OpenStack: {Values: map[string]string{"user_name": "admin", "password": "secret"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, remove())
+	require.Equal(t, map[string]bool{"user_name": true, "password": true}, scoped[credentialVarsKey].(*credentialVars).names, "The values should be written with the credentials")
+	delete(scoped, credentialVarsKey)
+	require.Equal(t, map[string]interface{}{"cluster_name": "my-cluster", "user_name": "admin", "password": "secret"}, scoped)
+	require.Equal(t, map[string]interface{}{"cluster_name": "my-cluster"}, cfg, "The given configuration should not be modified")
+
+	// credentials files are scoped to each operation
+	tenantA, removeA, err := withCredentials(types.GCP, cfg, map[types.ProviderType]types.Credentials{types.GCP: {File: []byte("tenant-a")}})
+	require.NoError(t, err)
+	tenantB, removeB, err := withCredentials(types.GCP, cfg, map[types.ProviderType]types.Credentials{types.GCP: {File: []byte("tenant-b")}})
+	require.NoError(t, err)
+
+	pathA := tenantA["credentials_file_path"].(string)
+	pathB := tenantB["credentials_file_path"].(string)
+	require.NotEqual(t, pathA, pathB, "Each operation should have its own credentials file")
+
+	data, err := ioutil.ReadFile(pathA)
+	require.NoError(t, err)
+	require.Equal(t, "tenant-a", string(data))
+	data, err = ioutil.ReadFile(pathB)
+	require.NoError(t, err)
+	require.Equal(t, "tenant-b", string(data))
+
+	info, err := os.Stat(pathA)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm(), "The credentials file should only be readable by the owner")
+
+	require.NoError(t, removeA())
+	_, err = os.Stat(pathA)
+	require.True(t, os.IsNotExist(err), "The credentials should be removed")
+	_, err = os.Stat(pathB)
+	require.NoError(t, err, "Removing credentials should not affect other operations")
+	require.NoError(t, removeB())
+}
+
+func TestSecretCredentials(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kyma", Name: "azure"},
+		Data:       map[string][]byte{"clientID": []byte("id"), "clientSecret": []byte("secret"), "other": []byte("ignored")},
+	})
+	tf := New(WithKubernetesSecretCredentials(client, "kyma", "azure", map[string]string{"clientID": "client_id", "clientSecret": "client_secret"}))
+
+	scoped, remove, err := tf.credentials(context.Background(), types.Azure, map[string]interface{}{"cluster_name": "my-cluster"})
+	require.NoError(t, err)
+	require.NoError(t, remove())
+	delete(scoped, credentialVarsKey)
+	require.Equal(t, map[string]interface{}{"cluster_name": "my-cluster", "client_id": "id", "client_secret": "secret"}, scoped)
+
+	// rotated credentials are used by the next operation
+	secret, err := client.CoreV1().Secrets("kyma").Get(context.Background(), "azure", metav1.GetOptions{})
+	require.NoError(t, err)
+	secret.Data["clientSecret"] = []byte("rotated")
+	_, err = client.CoreV1().Secrets("kyma").Update(context.Background(), secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	scoped, remove, err = tf.credentials(context.Background(), types.Azure, map[string]interface{}{})
+	require.NoError(t, err)
+	require.NoError(t, remove())
+	require.Equal(t, "rotated", scoped["client_secret"])
+
+	// without mapping, the keys are the credentials
+	client = fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kyma", Name: "gcp"},
+		Data:       map[string][]byte{"credentials_file_path": []byte("key")},
+	})
+	scoped, remove, err = New(WithKubernetesSecretCredentials(client, "kyma", "gcp", nil)).credentials(context.Background(), types.GCP, map[string]interface{}{})
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(scoped["credentials_file_path"].(string))
+	require.NoError(t, err)
+	require.Equal(t, "key", string(data))
+	require.NoError(t, remove())
+
+	_, _, err = New(WithKubernetesSecretCredentials(client, "kyma", "gcp", map[string]string{"key.json": "credentials_file_path"})).credentials(context.Background(), types.GCP, map[string]interface{}{})
+	require.Error(t, err, "A missing key of the mapping should fail")
+	_, _, err = New(WithKubernetesSecretCredentials(client, "kyma", "missing", nil)).credentials(context.Background(), types.GCP, map[string]interface{}{})
+	require.Error(t, err, "A missing secret should fail")
+}
+
+func TestRotateCredentials(t *testing.T) {
+	t.Parallel()
+	tf := New(WithCredentials(types.OpenStack, types.Credentials{Values: map[string]string{"password": "old"}}))
+	cfgA := map[string]interface{}{"project": "my-project", "cluster_name": "cluster-a"}
+	cfgB := map[string]interface{}{"project": "my-project", "cluster_name": "cluster-b"}
+
+	require.NoError(t, tf.RotateCredentials(types.OpenStack, cfgA, types.Credentials{Values: map[string]string{"password": "new"}}))
+	scoped, remove, err := tf.credentials(context.Background(), types.OpenStack, cfgA)
+	require.NoError(t, err)
+	require.NoError(t, remove())
+	require.Equal(t, "new", scoped["password"], "The next operations should use the rotated credentials")
+	scoped, remove, err = tf.credentials(context.Background(), types.OpenStack, cfgB)
+	require.NoError(t, err)
+	require.NoError(t, remove())
+	require.Equal(t, "old", scoped["password"], "The other clusters should keep the credentials of the options")
+
+	// the additional blocks of the provider of the cluster authenticate with the rotated credentials as well
+	cfgA["additional_providers"] = []types.AdditionalProvider{{Provider: types.OpenStack, Alias: "other"}}
+	scoped, remove, err = tf.credentials(context.Background(), types.OpenStack, cfgA)
+	require.NoError(t, err)
+	require.NoError(t, remove())
+	require.Equal(t, "new", scoped[additionalProviderVar(0, "password")])
+	delete(cfgA, "additional_providers")
+
+	require.Error(t, tf.RotateCredentials(types.OpenStack, map[string]interface{}{"project": "my-project"}, types.Credentials{Values: map[string]string{"password": "new"}}), "The cluster should be needed")
+	require.Error(t, tf.RotateCredentials(types.OpenStack, cfgA, types.Credentials{}), "Empty credentials should fail")
+	client := fake.NewSimpleClientset()
+	require.Error(t, New(WithKubernetesSecretCredentials(client, "kyma", "openstack", nil)).RotateCredentials(types.OpenStack, cfgA, types.Credentials{Values: map[string]string{"password": "new"}}),
+		"Credentials read from a secret should be rotated in the secret")
+}
@@ -0,0 +1,59 @@
+package terraform
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	digitaloceanAPI          = "https://api.digitalocean.com"
+	digitaloceanTokenTimeout = 30 * time.Second
+)
+
+// initDigitalOceanProvider checks that the DigitalOcean token is valid before running any command,
+// so that a missing or rejected token is reported before init downloads the provider.
+// The token is read from the configuration with the "token" key, or from the environment variables supported by the provider.
+func initDigitalOceanProvider(transport http.RoundTripper, cfg map[string]interface{}) error {
+	token, _ := cfg["token"].(string)
+	if token == "" {
+		token = os.Getenv("DIGITALOCEAN_TOKEN")
+	}
+	if token == "" {
+		token = os.Getenv("DIGITALOCEAN_ACCESS_TOKEN")
+	}
+	if token == "" {
+		return errors.New("no DigitalOcean token found, set token in the configuration or the DIGITALOCEAN_TOKEN environment variable")
+	}
+
+	return checkDigitalOceanToken(transport, digitaloceanAPI, token)
+}
+
+// checkDigitalOceanToken requests the account of the token from the given DigitalOcean API.
+// It returns ErrAuthFailed if the API rejects the token.
+func checkDigitalOceanToken(transport http.RoundTripper, api, token string) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v2/account", api), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	client := &http.Client{Transport: transport, Timeout: digitaloceanTokenTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "could not check the DigitalOcean token")
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errors.Wrap(types.ErrAuthFailed, "the DigitalOcean token was rejected")
+	case resp.StatusCode != http.StatusOK:
+		return errors.Errorf("could not check the DigitalOcean token, the API answered with status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package terraform
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDigitalOceanToken(t *testing.T) {
+	t.Parallel()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v2/account", r.URL.Path)
+		switch r.Header.Get("Authorization") {
+		case "Bearer valid":
+			w.WriteHeader(http.StatusOK)
+		case "Bearer broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	require.NoError(t, checkDigitalOceanToken(nil, api.URL, "valid"))
+
+	err := checkDigitalOceanToken(nil, api.URL, "invalid")
+	require.True(t, errors.Is(err, types.ErrAuthFailed), "A rejected token should be an authentication error")
+
+	err = checkDigitalOceanToken(nil, api.URL, "broken")
+	require.Error(t, err)
+	require.False(t, errors.Is(err, types.ErrAuthFailed), "API failures should not be taken as a rejected token")
+}
+
+func TestInitDigitalOceanProvider(t *testing.T) {
+	t.Parallel()
+	// a token in the environment is always checked against the API, so only check when there is none
+	if !envSet("DIGITALOCEAN_TOKEN") && !envSet("DIGITALOCEAN_ACCESS_TOKEN") {
+		require.Error(t, initDigitalOceanProvider(nil, map[string]interface{}{}), "Validation should fail without token")
+	}
+}
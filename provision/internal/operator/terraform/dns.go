@@ -0,0 +1,65 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// gardenerAddons are the add-ons the template can enable in a Gardener shoot.
+var gardenerAddons = []string{"kubernetes_dashboard", "nginx_ingress"}
+
+// dnsErrors checks the DNS and the add-ons of a Gardener configuration and returns an error for each invalid field.
+// A DNS provider manages the domain of the shoot, so it needs the domain. Other providers are not checked.
+func dnsErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	if p != types.Gardener {
+		return nil
+	}
+	var errs []types.FieldError
+	if stringValue(cfg["dns_provider"]) != "" && stringValue(cfg["dns_domain"]) == "" {
+		errs = append(errs, types.FieldError{Field: "dns_provider", Reason: "needs the dns_domain it manages"})
+	}
+	for i, a := range stringValues(cfg["addons"]) {
+		if !contains(gardenerAddons, a) {
+			errs = append(errs, types.FieldError{
+				Field:  fmt.Sprintf("addons[%d]", i),
+				Reason: fmt.Sprintf("must be one of %s, got %q", strings.Join(gardenerAddons, ", "), a),
+			})
+		}
+	}
+	return errs
+}
+
+// dnsProviderErrors returns an error if no extension of the garden supports DNS records of the given provider type, such as aws-route53.
+// Gardens without any DNS record type are not checked, their DNS providers are not registered as extensions.
+func dnsProviderErrors(ctx context.Context, client dynamic.Interface, provider string) ([]types.FieldError, error) {
+	registrations, err := client.Resource(controllerRegistrationResource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(classifyError(err), "could not list the extensions of the garden")
+	}
+
+	var providers []string
+	for _, r := range registrations.Items {
+		for _, res := range profileItems(&r, "resources") {
+			if m, ok := res.(map[string]interface{}); ok && m["kind"] == "DNSRecord" {
+				if typ, ok := m["type"].(string); ok && !contains(providers, typ) {
+					providers = append(providers, typ)
+				}
+			}
+		}
+	}
+	if len(providers) == 0 || contains(providers, provider) {
+		return nil, nil
+	}
+	sort.Strings(providers)
+	return []types.FieldError{{
+		Field:  "dns_provider",
+		Reason: fmt.Sprintf("the DNS provider %s is not supported by the garden, use one of: %s", provider, strings.Join(providers, ", ")),
+	}}, nil
+}
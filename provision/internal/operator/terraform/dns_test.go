@@ -0,0 +1,89 @@
+package terraform
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func testControllerRegistration(name string, resources ...map[string]interface{}) *unstructured.Unstructured {
+	var l []interface{}
+	for _, r := range resources {
+		l = append(l, r)
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "core.gardener.cloud/v1beta1",
+		"kind":       "ControllerRegistration",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"resources": l},
+	}}
+}
+
+func TestDNSProviderErrors(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme(),
+		testControllerRegistration("provider-gcp",
+			map[string]interface{}{"kind": "Infrastructure", "type": "gcp"},
+			map[string]interface{}{"kind": "DNSRecord", "type": "google-clouddns"},
+		),
+		testControllerRegistration("provider-aws", map[string]interface{}{"kind": "DNSRecord", "type": "aws-route53"}),
+	)
+
+	fields, err := dnsProviderErrors(context.Background(), client, "aws-route53")
+	require.NoError(t, err)
+	require.Empty(t, fields)
+
+	fields, err = dnsProviderErrors(context.Background(), client, "azure-dns")
+	require.NoError(t, err)
+	require.Equal(t, []types.FieldError{
+		{Field: "dns_provider", Reason: "the DNS provider azure-dns is not supported by the garden, use one of: aws-route53, google-clouddns"},
+	}, fields)
+
+	empty := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	fields, err = dnsProviderErrors(context.Background(), empty, "azure-dns")
+	require.NoError(t, err)
+	require.Empty(t, fields, "Gardens without DNS record types should not be checked")
+}
+
+func TestDNSErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, dnsErrors(types.Gardener, map[string]interface{}{"dns_domain": "c.example.com", "dns_provider": "aws-route53", "addons": []string{"nginx_ingress"}}))
+	require.Empty(t, dnsErrors(types.GCP, map[string]interface{}{"dns_provider": "aws-route53"}), "Other providers should not be checked")
+
+	require.Equal(t, []types.FieldError{
+		{Field: "dns_provider", Reason: "needs the dns_domain it manages"},
+		{Field: "addons[1]", Reason: `must be one of kubernetes_dashboard, nginx_ingress, got "istio"`},
+	}, dnsErrors(types.Gardener, map[string]interface{}{"dns_provider": "aws-route53", "addons": []string{"nginx_ingress", "istio"}}))
+}
+
+func TestExpandGardenerDNS(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{"target_provider": "gcp"}
+	tf, err := expandGardenerClusterTemplate(cfg)
+	require.NoError(t, err)
+	require.NotContains(t, tf, "domain = var.dns_domain", "Gardener should assign the domain if the configuration has none")
+	require.NotContains(t, tf, "addons {")
+	require.NotContains(t, tf, "ingress_domain")
+
+	cfg["dns_domain"] = "c.example.com"
+	cfg["addons"] = []string{"kubernetes_dashboard"}
+	tf, err = expandGardenerClusterTemplate(cfg)
+	require.NoError(t, err)
+	require.Contains(t, tf, "domain = var.dns_domain")
+	require.NotContains(t, tf, "type = var.dns_provider")
+	require.Contains(t, tf, "kubernetes_dashboard {")
+	require.NotContains(t, tf, "ingress_domain", "The ingress domain needs the nginx ingress")
+
+	cfg["dns_provider"] = "aws-route53"
+	cfg["addons"] = []string{"kubernetes_dashboard", "nginx_ingress"}
+	tf, err = expandGardenerClusterTemplate(cfg)
+	require.NoError(t, err)
+	require.Contains(t, tf, "type = var.dns_provider")
+	require.Contains(t, tf, "nginx_ingress {")
+	require.Contains(t, tf, `value = "*.ingress.${var.dns_domain}"`)
+}
@@ -0,0 +1,266 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// driftField maps a configuration field to the attribute of the resource it sets in the built-in template of a provider.
+type driftField struct {
+	field    string
+	resource string
+	// attr is the path of the attribute, with the index of the list elements, such as node_config.0.machine_type
+	attr string
+}
+
+// driftFields are the configuration fields compared with the state by Drift for each provider.
+var driftFields = map[types.ProviderType][]driftField{
+	types.GCP: {
+		{"location", "google_container_cluster.gke_cluster", "location"},
+		{"region", "google_container_cluster.gke_cluster", "location"},
+		{"zone", "google_container_cluster.gke_cluster", "location"},
+		{"node_count", "google_container_cluster.gke_cluster", "initial_node_count"},
+		{"machine_type", "google_container_cluster.gke_cluster", "node_config.0.machine_type"},
+		{"disk_size", "google_container_cluster.gke_cluster", "node_config.0.disk_size_gb"},
+		{"kubernetes_version", "google_container_cluster.gke_cluster", "min_master_version"},
+		{"release_channel", "google_container_cluster.gke_cluster", "release_channel.0.channel"},
+		{"labels", "google_container_cluster.gke_cluster", "resource_labels"},
+	},
+	types.Azure: {
+		{"location", "azurerm_kubernetes_cluster.azure_cluster", "location"},
+		{"agent_count", "azurerm_kubernetes_cluster.azure_cluster", "default_node_pool.0.node_count"},
+		{"agent_vm_size", "azurerm_kubernetes_cluster.azure_cluster", "default_node_pool.0.vm_size"},
+		{"agent_disk_size", "azurerm_kubernetes_cluster.azure_cluster", "default_node_pool.0.os_disk_size_gb"},
+		{"kubernetes_version", "azurerm_kubernetes_cluster.azure_cluster", "kubernetes_version"},
+	},
+	types.AWS: {
+		{"kubernetes_version", "aws_eks_cluster.eks_cluster", "version"},
+		{"labels", "aws_eks_cluster.eks_cluster", "tags"},
+		{"node_count", "aws_eks_node_group.eks_nodes", "scaling_config.0.desired_size"},
+		{"machine_type", "aws_eks_node_group.eks_nodes", "instance_types.0"},
+		{"disk_size", "aws_eks_node_group.eks_nodes", "disk_size"},
+	},
+	types.Kind: {
+		{"node_image", "kind.kind-cluster", "node_image"},
+	},
+}
+
+// nodePoolDriftAttrs are the attributes of the node pools of each provider set by the fields of the types.NodePool, by field name.
+var nodePoolDriftAttrs = map[types.ProviderType]map[string]string{
+	types.GCP: {
+		"node_count":         "node_count",
+		"machine_type":       "node_config.0.machine_type",
+		"disk_size":          "node_config.0.disk_size_gb",
+		"kubernetes_version": "version",
+	},
+	types.Azure: {
+		"node_count":         "node_count",
+		"machine_type":       "vm_size",
+		"disk_size":          "os_disk_size_gb",
+		"kubernetes_version": "orchestrator_version",
+	},
+}
+
+// Drift compares the configuration with the state of the cluster and reports the resources and attributes that differ,
+// so a reconciler can tell whether an Update is needed without running a plan. No terraform command is run.
+// Only the fields the built-in template of the provider sets on the cluster, its default nodes and its node pools are compared,
+// a field missing in the configuration is not compared. Providers without known fields and custom templates fail with ErrUnsupportedOperation.
+// If the state is nil, Drift will attempt to load the state from the file system.
+func (t *Terraform) Drift(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.DriftReport, error) {
+	return t.DriftWithContext(context.Background(), sf, p, cfg)
+}
+
+// DriftWithContext works as Drift but fails right away if the given context is already done, the state is read without it.
+func (t *Terraform) DriftWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.DriftReport, error) {
+	if err := t.preflight(p, cfg); err != nil {
+		return nil, err
+	}
+	if _, ok := t.ops.Templates[p]; ok {
+		return nil, errors.Wrap(types.ErrUnsupportedOperation, "the resources of custom templates are unknown, their drift cannot be detected")
+	}
+	if _, ok := driftFields[p]; !ok {
+		return nil, errors.Wrapf(types.ErrUnsupportedOperation, "drift detection is not supported on %s", p)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if sf == nil {
+		var err error
+		if sf, err = t.lockedState(cfg["project"].(string), cfg["cluster_name"].(string), p); err != nil {
+			return nil, errors.Wrap(err, "no state provided")
+		}
+	}
+	return drift(sf, p, cfg)
+}
+
+// drift compares the configuration with the given state.
+func drift(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.DriftReport, error) {
+	actual, err := driftResources(sf, p)
+	if err != nil {
+		return nil, err
+	}
+
+	// the resources the configuration creates
+	expected := make(map[string]bool)
+	for _, f := range driftFields[p] {
+		expected[f.resource] = true
+	}
+	pools, _ := cfg["node_pools"].([]types.NodePool)
+	for _, pool := range pools {
+		expected[nodePoolTypes[p]+"."+pool.Name] = true
+	}
+
+	report := &types.DriftReport{}
+	for addr := range expected {
+		if _, ok := actual[addr]; !ok {
+			report.AddedResources = append(report.AddedResources, addr)
+		}
+	}
+	for addr := range actual {
+		if !expected[addr] {
+			report.RemovedResources = append(report.RemovedResources, addr)
+		}
+	}
+	sort.Strings(report.AddedResources)
+	sort.Strings(report.RemovedResources)
+
+	for _, f := range driftFields[p] {
+		desired, ok := cfg[f.field]
+		if !ok {
+			continue
+		}
+		if c, err := attributeDrift(actual, f.resource, f.attr, f.field, desired); err != nil {
+			return nil, err
+		} else if c != nil {
+			report.Changes = append(report.Changes, *c)
+		}
+	}
+
+	for i, pool := range pools {
+		addr := nodePoolTypes[p] + "." + pool.Name
+		values := map[string]interface{}{"machine_type": pool.MachineType}
+		// the autoscaler owns the number of nodes
+		if pool.Autoscaling == nil || !pool.Autoscaling.Enabled {
+			values["node_count"] = pool.NodeCount
+		}
+		if pool.DiskSizeGB > 0 {
+			values["disk_size"] = pool.DiskSizeGB
+		}
+		if pool.KubernetesVersion != "" {
+			values["kubernetes_version"] = pool.KubernetesVersion
+		}
+		fields := make([]string, 0, len(values))
+		for field := range values {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			c, err := attributeDrift(actual, addr, nodePoolDriftAttrs[p][field], fmt.Sprintf("node_pools[%d].%s", i, field), values[field])
+			if err != nil {
+				return nil, err
+			}
+			if c != nil {
+				report.Changes = append(report.Changes, *c)
+			}
+		}
+	}
+	return report, nil
+}
+
+// driftResources returns the attributes of the resources of the state created by the built-in template of the provider, by address.
+func driftResources(sf *statefile.File, p types.ProviderType) (map[string]map[string]interface{}, error) {
+	resources := make(map[string]map[string]interface{})
+	if sf == nil || sf.State == nil || sf.State.RootModule() == nil {
+		return resources, nil
+	}
+
+	known := make(map[string]bool)
+	for _, f := range driftFields[p] {
+		known[f.resource] = true
+	}
+	for _, r := range sf.State.RootModule().Resources {
+		if r.Addr.Mode != addrs.ManagedResourceMode || (!known[r.Addr.String()] && r.Addr.Type != nodePoolTypes[p]) {
+			continue
+		}
+		i := r.Instance(addrs.NoKey)
+		if i == nil || i.Current == nil {
+			continue
+		}
+		attrs := make(map[string]interface{})
+		if err := json.Unmarshal(i.Current.AttrsJSON, &attrs); err != nil {
+			return nil, errors.Wrapf(err, "could not decode the attributes of %s", r.Addr)
+		}
+		resources[r.Addr.String()] = attrs
+	}
+	return resources, nil
+}
+
+// attributeDrift compares the desired value of a configuration field with the attribute of the resource in the state.
+// It returns nil if they are the same or the resource is not in the state, its creation is reported already.
+func attributeDrift(resources map[string]map[string]interface{}, addr, attr, field string, desired interface{}) (*types.AttributeDrift, error) {
+	attrs, ok := resources[addr]
+	if !ok {
+		return nil, nil
+	}
+
+	// compare the JSON representations, the state has no Go types
+	data, err := json.Marshal(desired)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not convert the value of %s", field)
+	}
+	var want interface{}
+	if err := json.Unmarshal(data, &want); err != nil {
+		return nil, errors.Wrapf(err, "could not convert the value of %s", field)
+	}
+	got := attributeValue(attrs, attr)
+	if reflect.DeepEqual(want, got) || (isEmpty(want) && isEmpty(got)) {
+		return nil, nil
+	}
+	return &types.AttributeDrift{Address: addr, Attribute: attr, Field: field, Desired: want, Actual: got}, nil
+}
+
+// attributeValue returns the value at the given path of the attributes, or nil if there is none.
+func attributeValue(attrs map[string]interface{}, path string) interface{} {
+	var v interface{} = attrs
+	for _, step := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			v = t[step]
+		case []interface{}:
+			i, err := strconv.Atoi(step)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil
+			}
+			v = t[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// isEmpty reports whether the given JSON value is null or an empty string, list or object, which the state does not tell apart.
+func isEmpty(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case []interface{}:
+		return len(t) == 0
+	case map[string]interface{}:
+		return len(t) == 0
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package terraform
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+// addResource adds a resource of the google provider with the given attributes to the state.
+func addResource(sf *statefile.File, typ, name, attrs string) {
+	sf.State.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: typ, Name: name}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(attrs)},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+	)
+}
+
+func TestDrift(t *testing.T) {
+	t.Parallel()
+	sf := clusterState("google_container_cluster", "gke_cluster", "google",
+		`{"name": "my-cluster", "location": "europe-west3-a", "initial_node_count": 5, "node_config": [{"machine_type": "n1-standard-4", "disk_size_gb": 30}], "resource_labels": {"team": "a"}, "release_channel": []}`)
+	addResource(sf, "google_container_node_pool", "workers", `{"node_count": 2, "node_config": [{"machine_type": "n1-standard-2", "disk_size_gb": 100}], "version": "1.18"}`)
+	addResource(sf, "google_container_node_pool", "old", `{"node_count": 1}`)
+
+	cfg := map[string]interface{}{
+		"project":      "my-project",
+		"cluster_name": "my-cluster",
+		"location":     "europe-west3-a",
+		"node_count":   3,
+		"machine_type": "n1-standard-4",
+		"disk_size":    30,
+		"labels":       map[string]string{"team": "a"},
+		"node_pools": []types.NodePool{
+			{Name: "workers", MachineType: "n1-standard-2", NodeCount: 4, DiskSizeGB: 100, KubernetesVersion: "1.18"},
+			{Name: "gpu", MachineType: "n1-standard-8", NodeCount: 1},
+		},
+	}
+
+	report, err := drift(sf, types.GCP, cfg)
+	require.NoError(t, err)
+	require.True(t, report.HasDrift())
+	require.Equal(t, []string{"google_container_node_pool.gpu"}, report.AddedResources)
+	require.Equal(t, []string{"google_container_node_pool.old"}, report.RemovedResources)
+	require.Equal(t, []types.AttributeDrift{
+		{Address: "google_container_cluster.gke_cluster", Attribute: "initial_node_count", Field: "node_count", Desired: float64(3), Actual: float64(5)},
+		{Address: "google_container_node_pool.workers", Attribute: "node_count", Field: "node_pools[0].node_count", Desired: float64(4), Actual: float64(2)},
+	}, report.Changes, "Only the attributes set in the configuration with a different value should be reported")
+
+	cfg["node_count"] = 5
+	cfg["node_pools"] = []types.NodePool{
+		{Name: "workers", MachineType: "n1-standard-2", NodeCount: 4, DiskSizeGB: 100, Autoscaling: &types.Autoscaling{Enabled: true, MinCount: 1, MaxCount: 5}},
+		{Name: "old"},
+	}
+	cfg["release_channel"] = ""
+	report, err = drift(sf, types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, []types.AttributeDrift{
+		{Address: "google_container_node_pool.old", Attribute: "node_count", Field: "node_pools[1].node_count", Desired: float64(0), Actual: float64(1)},
+	}, report.Changes, "The node count of autoscaled pools and empty values should not be compared")
+
+	cfg["node_pools"] = []types.NodePool{{Name: "workers", MachineType: "n1-standard-2", NodeCount: 2}, {Name: "old", NodeCount: 1}}
+	report, err = drift(sf, types.GCP, cfg)
+	require.NoError(t, err)
+	require.False(t, report.HasDrift(), "%+v", report)
+
+	report, err = drift(statefile.New(states.NewState(), "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, []string{"google_container_cluster.gke_cluster", "google_container_node_pool.old", "google_container_node_pool.workers"}, report.AddedResources,
+		"All resources should be created for an empty state")
+	require.Empty(t, report.Changes)
+}
+
+func TestDriftWithContext(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-drift")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "node_image": "kindest/node:v1.20.2"}
+	tf := New(WithDataDir(dir))
+
+	_, err = tf.Drift(nil, types.Kind, cfg)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "Without state, it should be loaded from the data dir")
+
+	require.NoError(t, stateToFile(clusterState("kind", "kind-cluster", "kind", `{"name": "my-cluster", "node_image": "kindest/node:v1.19.1"}`), tf.ops, "my-project", "my-cluster", types.Kind))
+	report, err := tf.Drift(nil, types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, []types.AttributeDrift{
+		{Address: "kind.kind-cluster", Attribute: "node_image", Field: "node_image", Desired: "kindest/node:v1.20.2", Actual: "kindest/node:v1.19.1"},
+	}, report.Changes)
+	require.Empty(t, report.AddedResources)
+	require.Empty(t, report.RemovedResources)
+
+	_, err = New(WithTemplate(types.Kind, fstest.MapFS{})).Drift(nil, types.Kind, cfg)
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation), "The resources of custom templates should be unknown")
+
+	_, err = tf.Drift(nil, types.Kind, map[string]interface{}{"project": "my-project"})
+	require.Error(t, err, "The configuration should be validated")
+}
@@ -0,0 +1,149 @@
+package terraform
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// encryptedStateHeader starts the encrypted state files, it is followed by the nonce and the sealed state.
+// State files without it are plaintext terraform states.
+const encryptedStateHeader = "hydroform-encrypted-state-v1\n"
+
+// stateCipher returns the AES-GCM cipher of the given state encryption key.
+func stateCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid state encryption key")
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptState seals the given plaintext state with the key. The header is authenticated too.
+func encryptState(key, state []byte) ([]byte, error) {
+	gcm, err := stateCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "could not generate the nonce of the state")
+	}
+	out := append([]byte(encryptedStateHeader), nonce...)
+	return gcm.Seal(out, nonce, state, []byte(encryptedStateHeader)), nil
+}
+
+// decryptState opens the given state file content with the key. Plaintext states are returned as they are.
+func decryptState(key, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedStateHeader)) {
+		return data, nil
+	}
+	if len(key) == 0 {
+		return nil, errors.New("the state is encrypted, the state encryption key is needed to read it")
+	}
+	gcm, err := stateCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	sealed := data[len(encryptedStateHeader):]
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("the encrypted state is truncated")
+	}
+	state, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(encryptedStateHeader))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt the state, the key may be wrong")
+	}
+	return state, nil
+}
+
+// plainState decrypts the state files in the given dir for a terraform command, which reads and writes them in plaintext.
+// The returned function encrypts the state files terraform left in the dir again, and adds its error to the given one.
+// It must be called as soon as the command finished, so the states are only in plaintext while terraform runs.
+// Without the StateEncryptionKey option, or with a backend, there is nothing to do.
+func plainState(ops Options, dir string) (func(*error), error) {
+	if len(ops.StateEncryptionKey) == 0 || ops.Backend != nil {
+		return func(*error) {}, nil
+	}
+	files := []string{filepath.Join(dir, tfStateFile), filepath.Join(dir, tfStateFile+".backup")}
+	for _, f := range files {
+		if err := transformFile(f, func(data []byte) ([]byte, error) { return decryptState(ops.StateEncryptionKey, data) }); err != nil {
+			return nil, errors.Wrap(err, "could not decrypt the state for terraform")
+		}
+	}
+
+	return func(err *error) {
+		if eerr := encryptStateFiles(ops, files); eerr != nil {
+			if *err == nil {
+				*err = eerr
+				return
+			}
+			*err = errors.Wrapf(*err, "%s after the command failed", eerr)
+		}
+	}, nil
+}
+
+// encryptStateFiles encrypts the given state files that are in plaintext.
+func encryptStateFiles(ops Options, files []string) error {
+	for _, f := range files {
+		if err := transformFile(f, func(data []byte) ([]byte, error) {
+			if bytes.HasPrefix(data, []byte(encryptedStateHeader)) {
+				return data, nil
+			}
+			return encryptState(ops.StateEncryptionKey, data)
+		}); err != nil {
+			return errors.Wrap(err, "could not encrypt the state written by terraform")
+		}
+	}
+	return nil
+}
+
+// encryptedState keeps the state files of the cluster in its directory encrypted during an operation: terraform only gets them in plaintext
+// for each command, see plainState, and stateToFile encrypts the states it writes.
+// The returned function encrypts the state files left in plaintext, such as the ones written before the StateEncryptionKey option was used,
+// and removes the plan, which contains the state. It must be called once the operation finishes.
+// Without the StateEncryptionKey option, with a backend or the in-memory state, there is nothing to do.
+func encryptedState(ops Options, project, cluster string, p types.ProviderType) (func() error, error) {
+	if len(ops.StateEncryptionKey) == 0 || ops.Backend != nil || ops.InMemoryState {
+		return noCleanup, nil
+	}
+	dir, err := clusterDir(ops, project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		if err := encryptStateFiles(ops, []string{filepath.Join(dir, tfStateFile), filepath.Join(dir, tfStateFile+".backup")}); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(dir, tfPlanFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}, nil
+}
+
+// transformFile replaces the content of the given file with the result of fn, if the file exists.
+func transformFile(path string, fn func([]byte) ([]byte, error)) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	out, err := fn(data)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(out, data) {
+		return nil
+	}
+	return ioutil.WriteFile(path, out, 0600)
+}
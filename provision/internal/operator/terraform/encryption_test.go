@@ -0,0 +1,142 @@
+package terraform
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var testStateKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncryptState(t *testing.T) {
+	t.Parallel()
+	state := []byte(`{"version": 4, "outputs": {"token": {"value": "secret"}}}`)
+
+	data, err := encryptState(testStateKey, state)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, []byte(encryptedStateHeader)))
+	require.NotContains(t, string(data), "secret")
+
+	out, err := decryptState(testStateKey, data)
+	require.NoError(t, err)
+	require.Equal(t, state, out)
+
+	out, err = decryptState(testStateKey, state)
+	require.NoError(t, err)
+	require.Equal(t, state, out, "Plaintext states should still be read")
+
+	_, err = decryptState([]byte("fedcba9876543210fedcba9876543210"), data)
+	require.Error(t, err, "A wrong key should be detected")
+	_, err = decryptState(nil, data)
+	require.Error(t, err, "An encrypted state cannot be read without the key")
+	_, err = encryptState([]byte("short"), state)
+	require.Error(t, err)
+}
+
+func TestEncryptedState(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-encrypted")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// a plaintext state written before the key was used
+	ops := options(WithDataDir(dir), WithStateEncryption(testStateKey))
+	sf := clusterState("null_resource", "cluster", "null", `{"id": "1"}`)
+	require.NoError(t, stateToFile(sf, options(WithDataDir(dir)), "my-project", "my-cluster", types.Kind))
+	clusterDir, err := clusterDir(ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(clusterDir, tfPlanFile), []byte("plan"), 0600))
+
+	release, err := encryptedState(ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	require.NoError(t, release())
+
+	data, err := ioutil.ReadFile(filepath.Join(clusterDir, tfStateFile))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, []byte(encryptedStateHeader)), "The state should be encrypted once the operation finishes")
+	_, err = os.Stat(filepath.Join(clusterDir, tfPlanFile))
+	require.True(t, os.IsNotExist(err), "The plan contains the state and should be removed")
+
+	loaded, err := stateFromFile(ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	require.True(t, loaded.State.HasResources())
+	_, err = stateFromFile(options(WithDataDir(dir)), "my-project", "my-cluster", types.Kind)
+	require.Error(t, err, "The state should not be readable without the key")
+
+	// the states written during the operation are encrypted right away
+	require.NoError(t, stateToFile(sf, ops, "my-project", "my-cluster", types.Kind))
+	data, err = ioutil.ReadFile(filepath.Join(clusterDir, tfStateFile))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, []byte(encryptedStateHeader)), "The stored state should never be in plaintext")
+}
+
+func TestPlainState(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-plain")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ops := options(WithDataDir(dir), WithStateEncryption(testStateKey))
+	require.NoError(t, stateToFile(clusterState("null_resource", "cluster", "null", `{"id": "1"}`), ops, "my-project", "my-cluster", types.Kind))
+	clusterDir, err := clusterDir(ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+
+	// terraform gets the state in plaintext during a command
+	encrypt, err := plainState(ops, clusterDir)
+	require.NoError(t, err)
+	f, err := os.Open(filepath.Join(clusterDir, tfStateFile))
+	require.NoError(t, err)
+	_, err = statefile.Read(f)
+	f.Close()
+	require.NoError(t, err)
+
+	cmdErr := errors.New("command failed")
+	encrypt(&cmdErr)
+	require.EqualError(t, cmdErr, "command failed", "Encrypting the state should keep the error of the command")
+	data, err := ioutil.ReadFile(filepath.Join(clusterDir, tfStateFile))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, []byte(encryptedStateHeader)), "The state should be encrypted once the command finished")
+}
+
+func TestCreateWithStateEncryption(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-encrypted-create")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+
+output "kubeconfig" {
+  value     = "secret-kubeconfig"
+  sensitive = true
+}
+`)}}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+
+	_, err = New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), WithStateEncryption([]byte("short"))).Create(types.Kind, cfg)
+	require.Error(t, err, "An invalid key should be rejected before running terraform")
+
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), Persistent(), WithStateEncryption(testStateKey))
+	_, err = tf.Create(types.Kind, cfg)
+	require.NoError(t, err)
+
+	clusterDir, err := clusterDir(tf.ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(clusterDir, tfStateFile))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret-kubeconfig", "The persisted state should be encrypted")
+
+	sf, err := stateFromFile(tf.ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	require.Equal(t, "secret-kubeconfig", sf.State.RootModule().OutputValues["kubeconfig"].Value.AsString())
+}
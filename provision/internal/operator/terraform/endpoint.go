@@ -0,0 +1,87 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Endpoint returns the URL of the API server of the cluster and true if it is the private endpoint, only reachable from the network of the cluster.
+// It only reads the persisted state of the cluster, so callers such as liveness probes get the endpoint without the credentials of the provider
+// or a kubeconfig being assembled. It fails with ErrStateNotFound if the cluster has no state.
+// The endpoint is read from the endpoint output of the template, or from the kubeconfig outputs if the template has none,
+// a Gardener or Kind cluster only has its endpoint in the kubeconfig of ClusterInfo.
+func (t *Terraform) Endpoint(p types.ProviderType, cfg map[string]interface{}) (string, bool, error) {
+	return t.EndpointWithContext(context.Background(), p, cfg)
+}
+
+// EndpointWithContext works as Endpoint but fails if the given context is done before the state is read.
+func (t *Terraform) EndpointWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (string, bool, error) {
+	project, ok := cfg["project"].(string)
+	if !ok || project == "" {
+		return "", false, errors.New("the project is needed to read the cluster endpoint")
+	}
+	cluster, ok := cfg["cluster_name"].(string)
+	if !ok || cluster == "" {
+		return "", false, errors.New("the cluster_name is needed to read the cluster endpoint")
+	}
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+
+	sf, err := t.lockedState(project, cluster, p)
+	if err != nil {
+		return "", false, err
+	}
+	if sf.State == nil || !sf.State.HasResources() {
+		return "", false, errors.Wrapf(types.ErrStateNotFound, "the state of cluster %s has no resources", cluster)
+	}
+
+	endpoint, err := stateEndpoint(sf)
+	if err != nil {
+		return "", false, err
+	}
+	if endpoint == "" {
+		if p == types.Gardener || p == types.Kind {
+			return "", false, errors.Wrapf(types.ErrUnsupportedOperation, "the state of a %s cluster has no endpoint, read it from the kubeconfig of its ClusterInfo", p)
+		}
+		// reading the endpoint does not run terraform, Refresh evaluates the outputs again
+		return "", false, errors.Wrap(&types.IncompleteStateError{Outputs: []string{"endpoint"}}, "refresh the cluster to get its endpoint")
+	}
+	private, err := privateEndpoint(sf)
+	if err != nil {
+		return "", false, errors.Wrap(err, "could not decode the cluster resource")
+	}
+	return endpoint, private, nil
+}
+
+// stateEndpoint returns the URL of the API server from the endpoint output of the given state, or from the server of the current context
+// of its kube_config or kubeconfig output. Endpoints without a scheme, such as the IP addresses of GKE clusters, are returned as https URLs.
+// It returns an empty string if the state has none of these outputs.
+func stateEndpoint(sf *statefile.File) (string, error) {
+	if hasOutput(sf, "endpoint") {
+		endpoint := stateOutput(sf, "endpoint")
+		if endpoint != "" && !strings.Contains(endpoint, "://") {
+			endpoint = fmt.Sprintf("https://%s", endpoint)
+		}
+		return endpoint, nil
+	}
+	for _, name := range []string{"kube_config", "kubeconfig"} {
+		if !hasOutput(sf, name) {
+			continue
+		}
+		config, err := clientcmd.Load([]byte(stateOutput(sf, name)))
+		if err != nil {
+			return "", errors.Wrapf(err, "could not decode the %s output", name)
+		}
+		if c, ok := config.Contexts[config.CurrentContext]; ok && config.Clusters[c.Cluster] != nil {
+			return config.Clusters[c.Cluster].Server, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,60 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEndpoint(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-endpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tf := New(WithDataDir(dir), Persistent())
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+
+	_, _, err = tf.Endpoint(types.GCP, cfg)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "A cluster without state should fail")
+
+	gke := clusterState("google_container_cluster", "gke_cluster", "google", `{"name": "my-cluster", "private_cluster_config": [{"enable_private_endpoint": true}]}`)
+	gke.State.RootModule().SetOutputValue("endpoint", cty.StringVal("10.0.0.2"), false)
+	require.NoError(t, storeState(tf.ops, tf.states, gke, "my-project", "my-cluster", types.GCP))
+	endpoint, private, err := tf.Endpoint(types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://10.0.0.2", endpoint)
+	require.True(t, private, "The private endpoint should be flagged")
+
+	aks := clusterState("azurerm_kubernetes_cluster", "azure_cluster", "azurerm", `{"name": "my-cluster"}`)
+	aks.State.RootModule().SetOutputValue("kube_config", cty.StringVal(`apiVersion: v1
+kind: Config
+clusters:
+- name: my-cluster
+  cluster:
+    server: https://my-cluster.hcp.westeurope.azmk8s.io:443
+contexts:
+- name: my-cluster
+  context:
+    cluster: my-cluster
+    user: clusterUser
+current-context: my-cluster
+`), true)
+	require.NoError(t, storeState(tf.ops, tf.states, aks, "my-project", "my-cluster", types.Azure))
+	endpoint, private, err = tf.Endpoint(types.Azure, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://my-cluster.hcp.westeurope.azmk8s.io:443", endpoint, "The endpoint should be read from the kubeconfig without endpoint output")
+	require.False(t, private)
+
+	require.NoError(t, storeState(tf.ops, tf.states, clusterState("aws_eks_cluster", "eks_cluster", "aws", `{"name": "my-cluster"}`), "my-project", "my-cluster", types.AWS))
+	_, _, err = tf.Endpoint(types.AWS, cfg)
+	require.True(t, errors.Is(err, types.ErrIncompleteState), "A state without outputs should fail")
+
+	_, _, err = tf.Endpoint(types.GCP, map[string]interface{}{"project": "my-project"})
+	require.Error(t, err, "The cluster name should be required")
+}
@@ -0,0 +1,42 @@
+package terraform
+
+import (
+	"context"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// Ensure makes the cluster match the given configuration, such as in a reconcile loop that calls it repeatedly.
+// It plans the changes against the stored state of the cluster first and returns the ClusterInfo from that state if there are none,
+// with an empty ApplySummary, so an unchanged cluster costs a plan instead of an apply. Otherwise it works as Create.
+// The state is only stored between the calls with the Persistent option or a backend, without it every call applies.
+func (t *Terraform) Ensure(p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	return t.EnsureWithContext(context.Background(), p, cfg)
+}
+
+// EnsureWithContext works as Ensure but stops terraform gracefully when the given context is done.
+func (t *Terraform) EnsureWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	info, err := t.ClusterInfoWithContext(ctx, p, cfg)
+	// a cluster without state, or with a state missing outputs, needs the apply anyway
+	var incomplete *types.IncompleteStateError
+	if errors.Is(err, types.ErrStateNotFound) || errors.As(err, &incomplete) {
+		return t.CreateWithContext(ctx, p, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changed := true
+	if err := t.plan(ctx, p, cfg, func(_ Options, clusterDir string) (err error) {
+		changed, err = planChanges(clusterDir, info.TerraformState().State)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if changed {
+		return t.CreateWithContext(ctx, p, cfg)
+	}
+	info.ApplySummary = &types.ApplySummary{}
+	return info, nil
+}
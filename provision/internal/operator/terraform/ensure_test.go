@@ -0,0 +1,81 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEnsure(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-ensure")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+variable "upstream" {}
+
+data "terraform_remote_state" "upstream" {
+  backend = "local"
+  config  = { path = var.upstream }
+}
+
+output "endpoint" { value = data.terraform_remote_state.upstream.outputs.endpoint }
+output "kubeconfig" { value = "kubeconfig" }
+`)}}
+	upstream := filepath.Join(dir, "upstream.tfstate")
+	writeUpstream := func(endpoint string) {
+		s := states.NewState()
+		s.RootModule().SetOutputValue("endpoint", cty.StringVal(endpoint), false)
+		f, err := os.Create(upstream)
+		require.NoError(t, err)
+		require.NoError(t, statefile.Write(statefile.New(s, "upstream", 1), f))
+		require.NoError(t, f.Close())
+	}
+	writeUpstream("https://a.example.com")
+
+	var mu sync.Mutex
+	applied := false
+	handler := func(e types.ProvisionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		applied = applied || e.Phase == types.ApplyPhase
+	}
+	wasApplied := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		a := applied
+		applied = false
+		return a
+	}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "upstream": upstream}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), Persistent(), WithProgressHandler(handler))
+
+	info, err := tf.Ensure(types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://a.example.com", info.Endpoint)
+	require.True(t, wasApplied(), "A cluster without state should be created")
+
+	info, err = tf.Ensure(types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://a.example.com", info.Endpoint)
+	require.Equal(t, &types.ApplySummary{}, info.ApplySummary)
+	require.False(t, wasApplied(), "An unchanged cluster should not be applied")
+
+	writeUpstream("https://b.example.com")
+	info, err = tf.Ensure(types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://b.example.com", info.Endpoint)
+	require.True(t, wasApplied(), "A changed cluster should be applied")
+}
@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// errorClasses maps the typed errors to the messages terraform and the providers output for them, in lower case.
+// Missing plugins go first, their messages name the provider resources that could not be found.
+// Authentication errors go next, since a request with wrong credentials can also be reported as throttled.
+// Rate limits go before quotas, their messages such as "RateLimitExceeded" contain the ones of the quotas.
+var errorClasses = []struct {
+	err      error
+	messages []string
+}{
+	{
+		err: types.ErrTerraformNotFound,
+		messages: []string{
+			"failed to install provider", "could not retrieve the list of available versions", "no suitable version installed",
+			"missing or corrupted provider plugins", "provider requirements cannot be satisfied", "failed to instantiate provider",
+		},
+	},
+	{
+		err: types.ErrAuthFailed,
+		messages: []string{
+			"unauthorized", "forbidden", "permission denied", "access denied", "accessdenied", "authorizationfailed",
+			"invalid credentials", "invalidclienttokenid", "invalid_grant", "could not find default credentials",
+			"no valid credential sources", "authentication failed", "error building account",
+		},
+	},
+	{
+		err: types.ErrRateLimited,
+		messages: []string{
+			"rate limit", "ratelimitexceeded", "rate_limit_exceeded", "too many requests", "throttling", "requestlimitexceeded",
+		},
+	},
+	{
+		err: types.ErrQuotaExceeded,
+		messages: []string{
+			"quota exceeded", "quotaexceeded", "quota_exceeded", "exceeded quota", "insufficient regional quota", "limitexceeded",
+		},
+	},
+	{
+		err: types.ErrTimeout,
+		messages: []string{
+			"timeout while waiting", "timed out", "deadline exceeded",
+		},
+	},
+	{
+		err: types.ErrProviderUnavailable,
+		messages: []string{
+			"error 500", "error 502", "error 503", "error 504", "internal error", "internalservererror", "backend error",
+			"service unavailable", "serviceunavailable", "bad gateway",
+		},
+	},
+	{
+		err: types.ErrResourceNotFound,
+		messages: []string{
+			"not found", "notfound", "error 404", "does not exist", "no longer exists",
+		},
+	},
+}
+
+// diagnosticResource matches the location terraform gives for diagnostics in a resource,
+// such as `on main.tf line 19, in resource "google_container_cluster" "gke_cluster":`.
+var diagnosticResource = regexp.MustCompile(`on .+ line \d+, in (resource|data) "([^"]+)" "([^"]+)":`)
+
+// isTransient returns true if the given error is expected to go away when retrying the operation.
+// Exceeded quotas are not, they stay until resources are freed or the quota is raised.
+func isTransient(err error) bool {
+	return errors.Is(err, types.ErrRateLimited) || errors.Is(err, types.ErrTimeout) || errors.Is(err, types.ErrProviderUnavailable)
+}
+
+// notFoundOnly returns true if terraform reported errors and all of them mean that resources do not exist anymore.
+// Warnings are ignored, since terraform reports them through the same UI.
+func notFoundOnly(ui errorsUI) bool {
+	found := false
+	for _, e := range ui.Errors() {
+		if strings.HasPrefix(strings.TrimSpace(e.Error()), "Warning:") {
+			continue
+		}
+		if !errors.Is(classifyError(e), types.ErrResourceNotFound) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// resourcesGoneError is the error of a terraform command that only failed because resources do not exist anymore, see notFoundOnly.
+type resourcesGoneError struct {
+	err error
+}
+
+func (e *resourcesGoneError) Error() string {
+	return e.err.Error()
+}
+
+func (e *resourcesGoneError) Unwrap() error {
+	return e.err
+}
+
+// applyError returns the classified errors terraform reported while applying changes.
+// If terraform located an error in a resource, the result is a ResourceError for the first failed resource.
+// Terraform 0.12 has no machine readable output for applies, so the resource is taken from the location in the diagnostic.
+func applyError(ui errorsUI) error {
+	err := classifyError(checkUIErrors(ui))
+	if err == nil {
+		return err
+	}
+
+	for _, e := range ui.Errors() {
+		msg := strings.TrimSpace(e.Error())
+		if strings.HasPrefix(msg, "Warning:") {
+			continue
+		}
+		if m := diagnosticResource.FindStringSubmatch(msg); m != nil {
+			addr := m[2] + "." + m[3]
+			if m[1] == "data" {
+				addr = "data." + addr
+			}
+			return &types.ResourceError{Resource: addr, Message: msg, Err: err}
+		}
+	}
+	return err
+}
+
+// classifyError wraps a terraform error into the typed error matching its message, so callers can check it with errors.Is.
+// An error acquiring the lock of the state is returned as a StateLockError with the lock info terraform output.
+// Errors that match no class are returned as they are.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if lockErr := parseStateLockError(err.Error()); lockErr != nil {
+		return errors.WithStack(lockErr)
+	}
+	msg := strings.ToLower(err.Error())
+	for _, c := range errorClasses {
+		for _, m := range c.messages {
+			if strings.Contains(msg, m) {
+				return errors.Wrap(c.err, err.Error())
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package terraform
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/command"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		Message  string
+		Expected error
+	}{
+		{
+			Message:  "Error: googleapi: Error 403: Required 'container.clusters.create' permission, forbidden",
+			Expected: types.ErrAuthFailed,
+		},
+		{
+			Message:  "Error: error creating EKS Cluster: InvalidClientTokenId: The security token included in the request is invalid",
+			Expected: types.ErrAuthFailed,
+		},
+		{
+			Message:  "Error: googleapi: Error 403: Insufficient regional quota to satisfy request: resource \"CPUS\"",
+			Expected: types.ErrQuotaExceeded,
+		},
+		{
+			Message:  "Error: googleapi: Error 429: Quota exceeded for quota group 'ReadGroup'",
+			Expected: types.ErrQuotaExceeded,
+		},
+		{
+			Message:  "Error: error creating EKS Cluster: LimitExceededException: Cluster limit exceeded for account",
+			Expected: types.ErrQuotaExceeded,
+		},
+		{
+			Message:  "Error: googleapi: Error 403: Rate Limit Exceeded, rateLimitExceeded",
+			Expected: types.ErrRateLimited,
+		},
+		{
+			Message:  "Error: error describing EC2 instances: Throttling: Rate exceeded",
+			Expected: types.ErrRateLimited,
+		},
+		{
+			Message:  "Error: Error waiting for creating GKE cluster: timeout while waiting for state to become 'DONE'",
+			Expected: types.ErrTimeout,
+		},
+		{
+			Message:  "Error: Error reading Container Cluster \"hydro-cluster\": googleapi: Error 404: Not found: projects/my-project/zones/europe-west3-a/clusters/hydro-cluster",
+			Expected: types.ErrResourceNotFound,
+		},
+		{
+			Message:  "Error: error deleting EKS Cluster (hydro-cluster): ResourceNotFoundException: No cluster found for name: hydro-cluster",
+			Expected: types.ErrResourceNotFound,
+		},
+		{
+			Message:  "Error: Failed to install provider\n\nError while installing provider \"kind\": plugin not found",
+			Expected: types.ErrTerraformNotFound,
+		},
+		{
+			Message:  "Error: Could not satisfy plugin requirements: provider.google: no suitable version installed",
+			Expected: types.ErrTerraformNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := classifyError(errors.New(tc.Message))
+		require.True(t, errors.Is(err, tc.Expected), tc.Message)
+		require.Contains(t, err.Error(), tc.Message, "The terraform message should be kept")
+	}
+
+	err := errors.New("Error: Invalid value for variable")
+	require.Equal(t, err, classifyError(err), "Unknown errors should not be changed")
+	err = errors.New("Error: rpc error: code = Unavailable desc = transport is closing\n\nError: plugin did not respond")
+	require.Equal(t, err, classifyError(err), "A plugin that crashed or was killed should not be taken as a missing plugin")
+	require.Nil(t, classifyError(nil))
+}
+
+func TestNotFoundOnly(t *testing.T) {
+	t.Parallel()
+	ui := &HydroUI{}
+	require.False(t, notFoundOnly(ui), "No errors should not be taken as resources not found")
+
+	ui.Warn("Warning: Interpolation-only expressions are deprecated")
+	ui.Error("Error: googleapi: Error 404: Not found: projects/my-project/zones/europe-west3-a/clusters/hydro-cluster")
+	require.True(t, notFoundOnly(ui), "Warnings should be ignored")
+	require.True(t, errors.Is(&resourcesGoneError{err: applyError(ui)}, types.ErrResourceNotFound), "The error of the resources gone should keep its class")
+
+	ui.Error("Error: googleapi: Error 400: The network \"default\" is in use")
+	require.False(t, notFoundOnly(ui), "Other errors should not be hidden")
+}
+
+func TestApplyError(t *testing.T) {
+	t.Parallel()
+	ui := &HydroUI{}
+	require.Nil(t, applyError(ui))
+
+	ui.Error("Error: googleapi: Error 400: The network \"default\" is in use")
+	err := applyError(ui)
+	var rerr *types.ResourceError
+	require.False(t, errors.As(err, &rerr), "Errors without location should not be resource errors")
+
+	ui.Reset()
+	ui.Warn("\nWarning: Interpolation-only expressions are deprecated\n\n  on main.tf line 24, in resource \"kind\" \"kind-cluster\":\n  24:   name = \"${var.cluster_name}\"\n")
+	diag := "Error: googleapi: Error 403: Insufficient regional quota to satisfy request: resource \"CPUS\"\n\n" +
+		"  on main.tf line 19, in resource \"google_container_cluster\" \"gke_cluster\":\n" +
+		"  19: resource \"google_container_cluster\" \"gke_cluster\" {"
+	ui.Error("\n" + diag + "\n")
+	err = applyError(ui)
+	require.True(t, errors.As(err, &rerr), "Errors located in a resource should be resource errors")
+	require.Equal(t, "google_container_cluster.gke_cluster", rerr.ResourceAddress(), "Warnings should not be taken as the failed resource")
+	require.Equal(t, diag, rerr.Diagnostic())
+	require.True(t, errors.Is(err, types.ErrQuotaExceeded), "Resource errors should keep their class")
+
+	ui.Reset()
+	ui.Error("Error: Error reading: not found\n\n  on main.tf line 3, in data \"google_client_config\" \"current\":")
+	require.True(t, errors.As(applyError(ui), &rerr))
+	require.Equal(t, "data.google_client_config.current", rerr.ResourceAddress())
+}
+
+func TestForgetState(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-forget-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ops := Options{Meta: command.Meta{OverrideDataDir: dir}}
+	require.NoError(t, stateToFile(statefile.New(states.NewState(), "", 0), ops, "my-project", "my-cluster", types.GCP))
+
+	require.NoError(t, forgetState(ops, nil, "my-project", "my-cluster", types.GCP))
+	_, err = stateFromFile(ops, "my-project", "my-cluster", types.GCP)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "The state file should be removed")
+
+	require.NoError(t, forgetState(ops, nil, "my-project", "my-cluster", types.GCP), "Forgetting a missing state should succeed")
+
+	// in-memory states have no state file outside of the operations
+	ops.InMemoryState = true
+	mem := newStateStore()
+	require.NoError(t, mem.store(ops, clusterState("google_container_cluster", "gke_cluster", "google", `{"name": "my-cluster"}`), "my-project", "my-cluster", types.GCP))
+	require.NoError(t, forgetState(ops, mem, "my-project", "my-cluster", types.GCP), "Forgetting a state without state file should succeed")
+	_, err = mem.load(ops, "my-project", "my-cluster", types.GCP)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "The state should be removed from memory")
+}
+
+func TestStateNotFound(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-state-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = stateFromFile(Options{Meta: command.Meta{OverrideDataDir: dir}}, "my-project", "my-cluster", types.GCP)
+	require.True(t, errors.Is(err, types.ErrStateNotFound))
+}
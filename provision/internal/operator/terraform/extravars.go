@@ -0,0 +1,103 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// tfExtraVarsFile is the file name for the extra variables of the configuration, terraform reads it before the vars file.
+const tfExtraVarsFile = "terraform.tfvars.json"
+
+// varNamePattern matches the names terraform allows for variables.
+var varNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// writeExtraVarsFile writes the extra_vars of the configuration as is into a JSON vars file of the cluster directory, for the variables
+// of a template hydroform does not map. The file is removed if there are no extra vars, so the ones removed from the configuration fall back to their defaults.
+func writeExtraVarsFile(dir string, cfg map[string]interface{}) error {
+	path := filepath.Join(dir, tfExtraVarsFile)
+	vars, _ := cfg["extra_vars"].(map[string]interface{})
+	if len(vars) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode the extra vars")
+	}
+	return ioutil.WriteFile(path, data, 0700)
+}
+
+// varFileArgs returns the flags passing the variable files of the options and the vars files of the cluster directory to terraform.
+// On a collision terraform keeps the value of the last file: the variable files of the options come first, then the extra vars,
+// so the variables managed by hydroform take precedence.
+func varFileArgs(ops Options, cfg map[string]interface{}, clusterDir string) []string {
+	var args []string
+	for _, f := range ops.VarFiles {
+		args = append(args, fmt.Sprintf("-var-file=%s", f))
+	}
+	if _, err := os.Stat(filepath.Join(clusterDir, tfExtraVarsFile)); err == nil {
+		args = append(args, fmt.Sprintf("-var-file=%s", filepath.Join(clusterDir, tfExtraVarsFile)))
+	}
+	args = append(args, fmt.Sprintf("-var-file=%s", filepath.Join(clusterDir, tfVarsFile)))
+	// the credentials are written with the vars of the cluster, see writeVarsFile
+	if creds, ok := cfg[credentialVarsKey].(*credentialVars); ok {
+		if _, err := os.Stat(creds.file); err == nil {
+			args = append(args, fmt.Sprintf("-var-file=%s", creds.file))
+		}
+	}
+	return args
+}
+
+// varFilesError returns an error if a variable file of the options cannot be read, terraform would only report it in the middle of the operation.
+func varFilesError(ops Options) error {
+	for _, f := range ops.VarFiles {
+		if info, err := os.Stat(f); err != nil {
+			return errors.Wrap(err, "could not read the terraform variable file")
+		} else if info.IsDir() {
+			return errors.Errorf("the terraform variable file %s is a directory", f)
+		}
+	}
+	return nil
+}
+
+// extraVarsErrors checks that the extra vars of the configuration have valid variable names and values terraform can read from JSON.
+func extraVarsErrors(cfg map[string]interface{}) []types.FieldError {
+	v, ok := cfg["extra_vars"]
+	if !ok || v == nil {
+		return nil
+	}
+	vars, ok := v.(map[string]interface{})
+	if !ok {
+		return []types.FieldError{{Field: "extra_vars", Reason: fmt.Sprintf("must be a map of variables, got %T", v)}}
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []types.FieldError
+	for _, name := range names {
+		field := fmt.Sprintf("extra_vars.%s", name)
+		if !varNamePattern.MatchString(name) {
+			errs = append(errs, types.FieldError{Field: field, Reason: "must start with a letter or underscore followed by letters, numbers, underscores or hyphens"})
+			continue
+		}
+		if _, err := json.Marshal(vars[name]); err != nil {
+			errs = append(errs, types.FieldError{Field: field, Reason: fmt.Sprintf("cannot be a terraform value: %s", err)})
+		}
+	}
+	return errs
+}
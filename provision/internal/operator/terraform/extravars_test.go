@@ -0,0 +1,105 @@
+package terraform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteExtraVarsFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-extravars")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	extra := map[string]interface{}{"enable_shielded_nodes": true, "maintenance_window": map[string]interface{}{"start_time": "03:00"}, "node_count": 5}
+	require.NoError(t, writeExtraVarsFile(dir, map[string]interface{}{"node_count": 3, "extra_vars": extra}))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfExtraVarsFile))
+	require.NoError(t, err)
+	vars := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &vars))
+	require.Equal(t, map[string]interface{}{"enable_shielded_nodes": true, "maintenance_window": map[string]interface{}{"start_time": "03:00"}, "node_count": 5.0}, vars)
+
+	// the managed vars file comes last, so its node count wins
+	require.Equal(t, []string{
+		"-var-file=" + filepath.Join(dir, tfExtraVarsFile),
+		"-var-file=" + filepath.Join(dir, tfVarsFile),
+	}, varFileArgs(Options{}, nil, dir))
+
+	require.NoError(t, writeExtraVarsFile(dir, map[string]interface{}{"node_count": 3}))
+	_, err = os.Stat(filepath.Join(dir, tfExtraVarsFile))
+	require.True(t, os.IsNotExist(err), "The extra vars file should be removed without extra vars")
+	require.Equal(t, []string{"-var-file=" + filepath.Join(dir, tfVarsFile)}, varFileArgs(Options{}, nil, dir))
+}
+
+func TestExtraVarsErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, extraVarsErrors(map[string]interface{}{}))
+	require.Empty(t, extraVarsErrors(map[string]interface{}{"extra_vars": map[string]interface{}{"_private": "x", "max-pods": 110}}))
+
+	errs := extraVarsErrors(map[string]interface{}{"extra_vars": map[string]string{"a": "b"}})
+	require.Len(t, errs, 1)
+	require.Equal(t, "extra_vars", errs[0].Field)
+
+	errs = extraVarsErrors(map[string]interface{}{"extra_vars": map[string]interface{}{"1st": "x", "ok": true, "ch": make(chan int)}})
+	require.Equal(t, []string{"extra_vars.1st", "extra_vars.ch"}, []string{errs[0].Field, errs[1].Field})
+
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "node_image": "kindest/node:v1.19.1", "extra_vars": map[string]interface{}{"bad name": 1}}
+	var verr *types.ValidationError
+	require.True(t, errors.As(validateConfig(types.Kind, cfg), &verr))
+}
+
+func TestFilterExtraVars(t *testing.T) {
+	t.Parallel()
+	vars := filterVars(map[string]interface{}{"cluster_name": "my-cluster", "extra_vars": map[string]interface{}{"a": 1}}, types.GCP)
+	require.NotContains(t, vars, "extra_vars", "The extra vars should not be in the managed vars file")
+}
+
+func TestVarFilesPrecedence(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-varfiles")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+variable "from_default" { default = "default" }
+variable "from_file" { default = "default" }
+variable "from_extra" { default = "default" }
+variable "from_cfg" { default = "default" }
+
+output "endpoint" { value = "https://example.com" }
+output "kubeconfig" { value = "kubeconfig" }
+output "vars" { value = "${var.from_default},${var.from_file},${var.from_extra},${var.from_cfg}" }
+`)}}
+	common := filepath.Join(dir, "common.tfvars")
+	require.NoError(t, ioutil.WriteFile(common, []byte("from_file = \"common\"\nfrom_extra = \"common\"\nfrom_cfg = \"common\"\n"), 0600))
+	env := filepath.Join(dir, "env.tfvars.json")
+	require.NoError(t, ioutil.WriteFile(env, []byte(`{"from_file": "env", "from_extra": "env", "from_cfg": "env"}`), 0600))
+
+	cfg := map[string]interface{}{
+		"project":      "my-project",
+		"cluster_name": "my-cluster",
+		"from_cfg":     "cfg",
+		"extra_vars":   map[string]interface{}{"from_extra": "extra", "from_cfg": "extra"},
+	}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), WithVarFiles(common, env))
+	info, err := tf.Create(types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "default,env,extra,cfg", info.Outputs["vars"],
+		"The variable files should override the defaults, the later files the earlier ones, and be overridden by the extra vars and the configuration")
+
+	tf = New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), WithVarFiles(filepath.Join(dir, "missing.tfvars")))
+	_, err = tf.Create(types.Kind, cfg)
+	require.Error(t, err, "A missing variable file should fail the operation before terraform runs")
+	require.True(t, os.IsNotExist(errors.Cause(err)))
+}
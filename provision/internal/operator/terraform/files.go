@@ -1,13 +1,18 @@
 package terraform
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -16,6 +21,7 @@ import (
 	"github.com/hashicorp/terraform/states/statefile"
 	"github.com/kyma-incubator/hydroform/provision/types"
 	"github.com/pkg/errors"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 const (
@@ -23,20 +29,324 @@ const (
 	tfStateFile  = "terraform.tfstate"
 	tfModuleFile = "terraform.tf"
 	tfVarsFile   = "terraform.tfvars"
+	tfPlanFile   = "terraform.tfplan"
 	// TODO release modules and do not use master as ref when stable
 	azureMod = "git::https://github.com/kyma-incubator/terraform-modules//azurerm_kubernetes_cluster?ref=v0.0.3"
 
 	// TODO remove hardcoded TF templates once modules work
-	awsClusterTemplate = ``
+	awsClusterTemplate = `
+variable "project"						{}
+variable "cluster_name"					{}
+variable "region"						{}
+variable "credentials_file_path"		{
+	default = ""
+}
+variable "profile"						{
+	default = "default"
+}
+variable "node_count"					{}
+variable "machine_type"					{}
+variable "kubernetes_version"			{}
+variable "disk_size"					{}
+variable "vpc_cidr"						{
+	default = "10.0.0.0/16"
+}
+variable "create_network"				{
+	default = true
+}
+variable "network"						{
+	default = ""
+}
+variable "subnetworks"					{
+	type    = list(string)
+	default = []
+}
+variable "labels"						{
+	type    = map(string)
+	default = {}
+}
+variable "spot"							{
+	default = false
+}
+variable "workload_identity"			{
+	default = false
+}
+variable "oidc_issuer"					{
+	default = false
+}
+variable "enable_audit_logging"			{
+	default = false
+}
+variable "create_timeout"				{}
+variable "update_timeout"				{}
+variable "delete_timeout"				{}
+
+provider "aws" {
+	region                  = var.region
+	shared_credentials_file = var.credentials_file_path != "" ? var.credentials_file_path : null
+	profile                 = var.profile
+}
+
+data "aws_availability_zones" "available" {
+	state = "available"
+}
+
+# the network is only created if the cluster does not use an existing one
+resource "aws_vpc" "eks_vpc" {
+	count                = var.create_network ? 1 : 0
+	cidr_block           = var.vpc_cidr
+	enable_dns_hostnames = true
+	enable_dns_support   = true
+
+	tags = merge(var.labels, {
+		Name                                        = var.cluster_name
+		Project                                     = var.project
+		"kubernetes.io/cluster/${var.cluster_name}" = "shared"
+	})
+}
+
+resource "aws_internet_gateway" "eks_gateway" {
+	count  = var.create_network ? 1 : 0
+	vpc_id = aws_vpc.eks_vpc[0].id
+	tags   = var.labels
+}
+
+resource "aws_subnet" "eks_subnet" {
+	count                   = var.create_network ? 2 : 0
+	vpc_id                  = aws_vpc.eks_vpc[0].id
+	cidr_block              = cidrsubnet(var.vpc_cidr, 8, count.index)
+	availability_zone       = data.aws_availability_zones.available.names[count.index]
+	map_public_ip_on_launch = true
+
+	tags = merge(var.labels, {
+		"kubernetes.io/cluster/${var.cluster_name}" = "shared"
+	})
+}
+
+resource "aws_route_table" "eks_routes" {
+	count  = var.create_network ? 1 : 0
+	vpc_id = aws_vpc.eks_vpc[0].id
+	tags   = var.labels
+
+	route {
+		cidr_block = "0.0.0.0/0"
+		gateway_id = aws_internet_gateway.eks_gateway[0].id
+	}
+}
+
+resource "aws_route_table_association" "eks_routes" {
+	count          = var.create_network ? 2 : 0
+	subnet_id      = aws_subnet.eks_subnet[count.index].id
+	route_table_id = aws_route_table.eks_routes[0].id
+}
+
+# existing networks are only read, so deleting the cluster leaves them untouched
+data "aws_vpc" "existing" {
+	count = var.create_network ? 0 : 1
+	id    = var.network
+}
+
+data "aws_subnet" "existing" {
+	count  = var.create_network ? 0 : length(var.subnetworks)
+	id     = var.subnetworks[count.index]
+	vpc_id = data.aws_vpc.existing[0].id
+}
+
+locals {
+	subnet_ids = var.create_network ? aws_subnet.eks_subnet[*].id : data.aws_subnet.existing[*].id
+	# the IAM roles of the service accounts are federated with the OIDC issuer of the cluster
+	oidc_provider = var.workload_identity || var.oidc_issuer
+}
+
+resource "aws_iam_role" "eks_cluster_role" {
+	name               = "${var.cluster_name}-cluster"
+	assume_role_policy = jsonencode({
+		Version   = "2012-10-17"
+		Statement = [{
+			Effect    = "Allow"
+			Principal = { Service = "eks.amazonaws.com" }
+			Action    = "sts:AssumeRole"
+		}]
+	})
+	tags               = var.labels
+}
+
+resource "aws_iam_role_policy_attachment" "eks_cluster_policy" {
+	policy_arn = "arn:aws:iam::aws:policy/AmazonEKSClusterPolicy"
+	role       = aws_iam_role.eks_cluster_role.name
+}
+
+resource "aws_iam_role" "eks_node_role" {
+	name               = "${var.cluster_name}-nodes"
+	assume_role_policy = jsonencode({
+		Version   = "2012-10-17"
+		Statement = [{
+			Effect    = "Allow"
+			Principal = { Service = "ec2.amazonaws.com" }
+			Action    = "sts:AssumeRole"
+		}]
+	})
+	tags               = var.labels
+}
+
+resource "aws_iam_role_policy_attachment" "eks_node_policies" {
+	count      = 3
+	policy_arn = element([
+		"arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy",
+		"arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy",
+		"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+	], count.index)
+	role       = aws_iam_role.eks_node_role.name
+}
+
+resource "aws_eks_cluster" "eks_cluster" {
+	name     = var.cluster_name
+	role_arn = aws_iam_role.eks_cluster_role.arn
+	version  = var.kubernetes_version
+	tags     = var.labels
+
+	enabled_cluster_log_types = var.enable_audit_logging ? ["api", "audit", "authenticator"] : []
+
+	vpc_config {
+		subnet_ids = local.subnet_ids
+	}
+
+	timeouts {
+		create = var.create_timeout
+		update = var.update_timeout
+		delete = var.delete_timeout
+	}
+
+	depends_on = [aws_iam_role_policy_attachment.eks_cluster_policy]
+}
+
+data "tls_certificate" "eks_oidc" {
+	count = local.oidc_provider ? 1 : 0
+	url   = aws_eks_cluster.eks_cluster.identity.0.oidc.0.issuer
+}
+
+resource "aws_iam_openid_connect_provider" "eks_oidc" {
+	count           = local.oidc_provider ? 1 : 0
+	url             = aws_eks_cluster.eks_cluster.identity.0.oidc.0.issuer
+	client_id_list  = ["sts.amazonaws.com"]
+	thumbprint_list = [data.tls_certificate.eks_oidc[0].certificates.0.sha1_fingerprint]
+	tags            = var.labels
+}
+
+resource "aws_eks_node_group" "eks_nodes" {
+	cluster_name    = aws_eks_cluster.eks_cluster.name
+	node_group_name = "${var.cluster_name}-nodes"
+	node_role_arn   = aws_iam_role.eks_node_role.arn
+	subnet_ids      = local.subnet_ids
+	instance_types  = [var.machine_type]
+	disk_size       = var.disk_size
+	capacity_type   = var.spot ? "SPOT" : "ON_DEMAND"
+	tags            = var.labels
+
+	scaling_config {
+		desired_size = var.node_count
+		max_size     = var.node_count
+		min_size     = var.node_count
+	}
+
+	timeouts {
+		create = var.create_timeout
+		update = var.update_timeout
+		delete = var.delete_timeout
+	}
+
+	depends_on = [aws_iam_role_policy_attachment.eks_node_policies]
+}
+
+output "endpoint" {
+	value = aws_eks_cluster.eks_cluster.endpoint
+}
+
+output "cluster_ca_certificate" {
+	value = aws_eks_cluster.eks_cluster.certificate_authority.0.data
+}
+
+output "oidc_issuer_url" {
+	value = local.oidc_provider ? aws_eks_cluster.eks_cluster.identity.0.oidc.0.issuer : null
+}
+
+output "oidc_provider_arn" {
+	value = local.oidc_provider ? aws_iam_openid_connect_provider.eks_oidc[0].arn : null
+}
+
+output "kubeconfig" {
+	value = <<KUBECONFIG
+apiVersion: v1
+kind: Config
+clusters:
+- name: ${var.cluster_name}
+  cluster:
+    server: ${aws_eks_cluster.eks_cluster.endpoint}
+    certificate-authority-data: ${aws_eks_cluster.eks_cluster.certificate_authority.0.data}
+contexts:
+- name: ${var.cluster_name}
+  context:
+    cluster: ${var.cluster_name}
+    user: ${var.cluster_name}
+current-context: ${var.cluster_name}
+users:
+- name: ${var.cluster_name}
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1alpha1
+      command: aws
+      args: ["eks", "get-token", "--cluster-name", "${var.cluster_name}", "--region", "${var.region}"]
+KUBECONFIG
+}
+`
 	gcpClusterTemplate = `
   variable "node_count"    		{}
   variable "cluster_name"  		{}
   variable "credentials_file_path" 	{}
   variable "project"       		{}
-  variable "location"      		{}
+  variable "location"      		{
+		default = ""
+  }
+  variable "region"      		{
+		default = ""
+  }
+  variable "zone"      			{
+		default = ""
+  }
+  variable "availability_zones" 	{
+		type    = list(string)
+		default = []
+  }
   variable "machine_type"  		{}
-  variable "kubernetes_version"   	{}
+  variable "kubernetes_version"   	{
+		default = ""
+  }
+  variable "release_channel" 		{
+		default = ""
+  }
+  variable "node_version" 		{
+		default = ""
+  }
   variable "disk_size" 			{}
+  variable "labels" 			{
+		type    = map(string)
+		default = {}
+  }
+  variable "network" 			{
+		default = ""
+  }
+  variable "subnetwork" 		{
+		default = ""
+  }
+  variable "workload_identity" 	{
+		default = false
+  }
+  variable "oidc_issuer" 		{
+		default = false
+  }
+  variable "enable_audit_logging" {
+		default = false
+  }
   variable "create_timeout" 	{}
   variable "update_timeout" 	{}
   variable "delete_timeout" 	{}
@@ -46,16 +356,64 @@ const (
 		project       = var.project
   }
 
+  # a cluster in a region is regional, its control plane and nodes are spread over the zones of the region, a cluster in a zone is zonal
+  locals {
+		location = coalesce(var.location, var.region, var.zone)
+		# the OIDC issuer of GKE serves the keys of the service accounts of the workload identity pool
+		workload_identity = var.workload_identity || var.oidc_issuer
+  }
+
+  # the cluster uses the default network unless an existing one is given, the template never creates networks
+  data "google_compute_network" "existing" {
+		count = var.network != "" ? 1 : 0
+		name  = var.network
+  }
+
+  data "google_compute_subnetwork" "existing" {
+		count  = var.subnetwork != "" ? 1 : 0
+		name   = var.subnetwork
+		region = join("-", slice(split("-", local.location), 0, 2))
+  }
+
   resource "google_container_cluster" "gke_cluster" {
     	name               = var.cluster_name
-    	location 	       = var.location
+    	location 	       = local.location
+    	node_locations     = length(var.availability_zones) > 0 ? var.availability_zones : null
     	initial_node_count = var.node_count
-    	min_master_version = var.kubernetes_version
-    	node_version       = var.kubernetes_version
+    	# a release channel upgrades the nodes with the control plane, the kubernetes version is then only the minimum one of the control plane,
+    	# otherwise the default nodes run the node version, set while upgrading the control plane first, or the kubernetes version
+    	min_master_version = var.kubernetes_version != "" ? var.kubernetes_version : null
+    	node_version       = var.release_channel != "" ? null : var.node_version != "" ? var.node_version : var.kubernetes_version
+    	resource_labels    = var.labels
+    	network            = var.network != "" ? data.google_compute_network.existing[0].self_link : null
+    	subnetwork         = var.subnetwork != "" ? data.google_compute_subnetwork.existing[0].self_link : null
+    	# the audit logs of the API server go to Cloud Audit Logs with the logs of the system components
+    	logging_service    = var.enable_audit_logging ? "logging.googleapis.com/kubernetes" : null
     
+    dynamic "release_channel" {
+		for_each = var.release_channel != "" ? [var.release_channel] : []
+		content {
+			channel = release_channel.value
+		}
+    }
+
+    dynamic "workload_identity_config" {
+		for_each = local.workload_identity ? ["${var.project}.svc.id.goog"] : []
+		content {
+			identity_namespace = workload_identity_config.value
+		}
+    }
+
     node_config {
       	machine_type = var.machine_type
 		disk_size_gb = var.disk_size
+
+		dynamic "workload_metadata_config" {
+			for_each = local.workload_identity ? ["GKE_METADATA_SERVER"] : []
+			content {
+				node_metadata = workload_metadata_config.value
+			}
+		}
     }
 
 	timeouts {
@@ -78,6 +436,10 @@ const (
   output "cluster_ca_certificate" {
     value = google_container_cluster.gke_cluster.master_auth.0.cluster_ca_certificate
   }
+
+  output "oidc_issuer_url" {
+    value = local.workload_identity ? "https://container.googleapis.com/v1/projects/${var.project}/locations/${local.location}/clusters/${var.cluster_name}" : null
+  }
 `
 
 	gardenerClusterTemplate = `
@@ -130,6 +492,15 @@ variable "machine_image_version"	{}
 variable "privileged_containers"	{
 	default = "false"
 }
+variable "seed_name"				{
+	default = ""
+}
+variable "dns_domain"				{
+	default = ""
+}
+variable "dns_provider"				{
+	default = ""
+}
 
 
 provider "gardener" {
@@ -153,6 +524,9 @@ resource "gardener_shoot" "gardener_cluster" {
        cloud_profile_name = var.target_profile
        region  = var.location
 	   secret_binding_name = var.target_secret
+	   {{ if index .Cfg "seed_name" }}
+	   seed_name = var.seed_name
+	   {{ end }}
        networking {
          nodes = var.networking_nodes
          pods = var.networking_pods
@@ -241,8 +615,281 @@ resource "gardener_shoot" "gardener_cluster" {
 		allow_privileged_containers = var.privileged_containers
 		version = var.kubernetes_version
 	  }
+	  {{ if index .Cfg "dns_domain" }}
+	  dns {
+		domain = var.dns_domain
+		{{ if index .Cfg "dns_provider" }}
+		providers {
+		  type = var.dns_provider
+		  primary = true
+		}
+		{{ end }}
+	  }
+	  {{ end }}
+	  {{ if .Addons }}
+	  addons {
+		{{ range .Addons }}
+		{{ . }} {
+		  enabled = true
+		}
+		{{ end }}
+	  }
+	  {{ end }}
+	  {{ if .Hibernation }}
+	  hibernation {
+		enabled = {{ .Hibernation }}
+	  }
+	  {{ end }}
   }
 }
+{{ if and (index .Cfg "dns_domain") (contains .Addons "nginx_ingress") }}
+
+output "ingress_domain" {
+	value = "*.ingress.${var.dns_domain}"
+}
+{{ end }}
+`
+
+	openstackClusterTemplate = `
+variable "project"				{}
+variable "cluster_name"			{}
+variable "auth_url"				{}
+variable "tenant_name"			{}
+variable "region"				{}
+variable "user_name"			{
+	default = ""
+}
+variable "password"				{
+	default = ""
+}
+variable "network"				{}
+variable "image"				{}
+variable "flavor"				{}
+variable "master_flavor"		{
+	default = ""
+}
+variable "master_count"			{
+	default = 1
+}
+variable "keypair"				{
+	default = ""
+}
+variable "node_count"			{}
+variable "disk_size"			{}
+variable "kubernetes_version"	{}
+variable "create_timeout"		{}
+variable "update_timeout"		{}
+variable "delete_timeout"		{}
+
+provider "openstack" {
+	auth_url    = var.auth_url
+	tenant_name = var.tenant_name
+	region      = var.region
+	user_name   = var.user_name != "" ? var.user_name : null
+	password    = var.password != "" ? var.password : null
+}
+
+resource "openstack_containerinfra_clustertemplate_v1" "magnum_template" {
+	name                = "${var.cluster_name}-template"
+	coe                 = "kubernetes"
+	image               = var.image
+	external_network_id = var.network
+	flavor              = var.flavor
+	master_flavor       = var.master_flavor != "" ? var.master_flavor : var.flavor
+	docker_volume_size  = var.disk_size
+	network_driver      = "calico"
+	floating_ip_enabled = true
+	master_lb_enabled   = true
+
+	labels = {
+		kube_tag = var.kubernetes_version
+	}
+}
+
+resource "openstack_containerinfra_cluster_v1" "magnum_cluster" {
+	name                = var.cluster_name
+	cluster_template_id = openstack_containerinfra_clustertemplate_v1.magnum_template.id
+	master_count        = var.master_count
+	node_count          = var.node_count
+	keypair             = var.keypair != "" ? var.keypair : null
+
+	timeouts {
+		create = var.create_timeout
+		update = var.update_timeout
+		delete = var.delete_timeout
+	}
+}
+
+output "endpoint" {
+	value = openstack_containerinfra_cluster_v1.magnum_cluster.api_address
+}
+
+output "cluster_ca_certificate" {
+	value = base64encode(openstack_containerinfra_cluster_v1.magnum_cluster.kubeconfig["cluster_ca_certificate"])
+}
+
+output "kubeconfig" {
+	value     = openstack_containerinfra_cluster_v1.magnum_cluster.kubeconfig["raw_config"]
+	sensitive = true
+}
+`
+
+	digitaloceanClusterTemplate = `
+variable "cluster_name"			{}
+variable "token"				{
+	default = ""
+}
+variable "region"				{}
+variable "node_size"			{}
+variable "node_count"			{}
+variable "kubernetes_version"	{}
+
+provider "digitalocean" {
+	token = var.token != "" ? var.token : null
+}
+
+resource "digitalocean_kubernetes_cluster" "doks_cluster" {
+	name    = var.cluster_name
+	region  = var.region
+	version = var.kubernetes_version
+
+	node_pool {
+		name       = "${var.cluster_name}-pool"
+		size       = var.node_size
+		node_count = var.node_count
+	}
+}
+
+output "endpoint" {
+	value = digitalocean_kubernetes_cluster.doks_cluster.endpoint
+}
+
+output "cluster_ca_certificate" {
+	value = digitalocean_kubernetes_cluster.doks_cluster.kube_config[0].cluster_ca_certificate
+}
+
+output "kubeconfig" {
+	value     = digitalocean_kubernetes_cluster.doks_cluster.kube_config[0].raw_config
+	sensitive = true
+}
+`
+
+	alicloudClusterTemplate = `
+variable "cluster_name"			{}
+variable "access_key"			{
+	default = ""
+}
+variable "secret_key"			{
+	default = ""
+}
+variable "region"				{}
+variable "instance_types"		{
+	type = list(string)
+}
+variable "node_count"			{}
+variable "disk_size"			{}
+variable "kubernetes_version"	{}
+variable "vswitch_id"			{
+	default = ""
+}
+variable "vpc_cidr"				{
+	default = "10.0.0.0/8"
+}
+variable "vswitch_cidr"			{
+	default = "10.1.0.0/16"
+}
+variable "zone"					{
+	default = ""
+}
+variable "pod_cidr"				{
+	default = "172.20.0.0/16"
+}
+variable "service_cidr"			{
+	default = "172.21.0.0/20"
+}
+variable "create_timeout"		{}
+variable "update_timeout"		{}
+variable "delete_timeout"		{}
+
+provider "alicloud" {
+	region     = var.region
+	access_key = var.access_key != "" ? var.access_key : null
+	secret_key = var.secret_key != "" ? var.secret_key : null
+}
+
+data "alicloud_zones" "zones" {
+	available_resource_creation = "VSwitch"
+}
+
+# the network is only created if no vswitch is given
+resource "alicloud_vpc" "vpc" {
+	count      = var.vswitch_id == "" ? 1 : 0
+	name       = "${var.cluster_name}-vpc"
+	cidr_block = var.vpc_cidr
+}
+
+resource "alicloud_vswitch" "vswitch" {
+	count             = var.vswitch_id == "" ? 1 : 0
+	name              = "${var.cluster_name}-vswitch"
+	vpc_id            = alicloud_vpc.vpc[0].id
+	cidr_block        = var.vswitch_cidr
+	availability_zone = var.zone != "" ? var.zone : data.alicloud_zones.zones.zones[0].id
+}
+
+locals {
+	vswitch_id = var.vswitch_id != "" ? var.vswitch_id : alicloud_vswitch.vswitch[0].id
+}
+
+resource "alicloud_cs_managed_kubernetes" "ack_cluster" {
+	name                  = var.cluster_name
+	version               = var.kubernetes_version
+	worker_vswitch_ids    = [local.vswitch_id]
+	worker_instance_types = var.instance_types
+	worker_number         = var.node_count
+	worker_disk_size      = var.disk_size > 0 ? var.disk_size : null
+	pod_cidr              = var.pod_cidr
+	service_cidr          = var.service_cidr
+	new_nat_gateway       = var.vswitch_id == ""
+	slb_internet_enabled  = true
+
+	timeouts {
+		create = var.create_timeout
+		update = var.update_timeout
+		delete = var.delete_timeout
+	}
+}
+
+output "endpoint" {
+	value = alicloud_cs_managed_kubernetes.ack_cluster.connections["api_server_internet"]
+}
+
+output "cluster_ca_certificate" {
+	value = alicloud_cs_managed_kubernetes.ack_cluster.certificate_authority["cluster_cert"]
+}
+
+output "kubeconfig" {
+	value     = <<KUBECONFIG
+apiVersion: v1
+kind: Config
+clusters:
+- name: ${var.cluster_name}
+  cluster:
+    server: ${alicloud_cs_managed_kubernetes.ack_cluster.connections["api_server_internet"]}
+    certificate-authority-data: ${alicloud_cs_managed_kubernetes.ack_cluster.certificate_authority["cluster_cert"]}
+users:
+- name: ${var.cluster_name}-admin
+  user:
+    client-certificate-data: ${alicloud_cs_managed_kubernetes.ack_cluster.certificate_authority["client_cert"]}
+    client-key-data: ${alicloud_cs_managed_kubernetes.ack_cluster.certificate_authority["client_key"]}
+contexts:
+- name: ${var.cluster_name}
+  context:
+    cluster: ${var.cluster_name}
+    user: ${var.cluster_name}-admin
+current-context: ${var.cluster_name}
+KUBECONFIG
+	sensitive = true
+}
 `
 
 	kindClusterTemplate = `
@@ -268,12 +915,29 @@ resource "kind" "kind-cluster" {
 `
 )
 
-// initClusterFiles initializes all necessary files for a cluster in the given data directory
-func initClusterFiles(dataDir string, p types.ProviderType, cfg map[string]interface{}) error {
-	dir, err := clusterDir(dataDir, cfg["project"].(string), cfg["cluster_name"].(string), p)
+// initClusterFiles initializes all necessary files for a cluster in its directory
+func initClusterFiles(ops Options, p types.ProviderType, cfg map[string]interface{}, tmpl fs.FS) error {
+	dir, err := clusterDir(ops, cfg["project"].(string), cfg["cluster_name"].(string), p)
 	if err != nil {
 		return err
 	}
+	if err := writeProviderVersionsFile(dir, ops.ProviderVersions); err != nil {
+		return errors.Wrap(err, "could not render the provider version constraints")
+	}
+	if err := writeAdditionalProvidersFile(dir, cfg); err != nil {
+		return errors.Wrap(err, "could not render the additional providers")
+	}
+
+	if tmpl != nil {
+		if err := writeTemplate(dir, tmpl); err != nil {
+			return errors.Wrap(err, "could not copy the cluster template")
+		}
+		if err := writeExtraVarsFile(dir, cfg); err != nil {
+			return err
+		}
+		// custom templates declare their own variables, they get all values
+		return writeVarsFile(dir, cfg)
+	}
 
 	// create module file for providers that are not using modules
 	// TODO delete this when all providers have downloadable modules
@@ -293,6 +957,12 @@ func initClusterFiles(dataDir string, p types.ProviderType, cfg map[string]inter
 		data = []byte(awsClusterTemplate)
 	case types.Kind:
 		data = []byte(kindClusterTemplate)
+	case types.OpenStack:
+		data = []byte(openstackClusterTemplate)
+	case types.DigitalOcean:
+		data = []byte(digitaloceanClusterTemplate)
+	case types.AliCloud:
+		data = []byte(alicloudClusterTemplate)
 	}
 
 	if len(data) > 0 {
@@ -300,87 +970,195 @@ func initClusterFiles(dataDir string, p types.ProviderType, cfg map[string]inter
 			return err
 		}
 	}
+	if err := writeNodePoolsFile(dir, p, cfg); err != nil {
+		return err
+	}
+	if err := writePrivateClusterFile(dir, p, cfg); err != nil {
+		return err
+	}
+	if err := writeLabelsFile(dir, p, cfg); err != nil {
+		return err
+	}
+	if err := writeAutoProvisioningFile(dir, p, cfg); err != nil {
+		return err
+	}
+	if err := writeResourceGroupFiles(dir, p, cfg); err != nil {
+		return err
+	}
+	if err := writeZonesFile(dir, p, cfg); err != nil {
+		return err
+	}
+	if err := writeSecurityFiles(dir, p, cfg); err != nil {
+		return err
+	}
+	if err := writeExtraVarsFile(dir, cfg); err != nil {
+		return err
+	}
 
-	// create vars file
-	var vars strings.Builder
-	filtered := filterVars(cfg, p)
-	for k, v := range filtered {
+	return writeVarsFile(dir, filterVars(cfg, p))
+}
+
+// writeVarsFile writes the given variables into the vars file of the cluster directory.
+// The credentialVars among them are written into the vars file of their private directory instead, so they do not stay in the cluster directory.
+func writeVarsFile(dir string, vars map[string]interface{}) error {
+	creds, _ := vars[credentialVarsKey].(*credentialVars)
+	if creds == nil {
+		return writeVars(filepath.Join(dir, tfVarsFile), vars)
+	}
+	clusterVars := make(map[string]interface{}, len(vars))
+	credVars := make(map[string]interface{}, len(creds.names))
+	for k, v := range vars {
+		if creds.names[k] {
+			credVars[k] = v
+		} else {
+			clusterVars[k] = v
+		}
+	}
+	if err := writeVars(creds.file, credVars); err != nil {
+		return errors.Wrap(err, "could not write the vars file of the credentials")
+	}
+	return writeVars(filepath.Join(dir, tfVarsFile), clusterVars)
+}
+
+// writeVars writes the given variables into the vars file at the given path.
+// Only strings, numbers, booleans, durations, lists of strings and maps of strings can be terraform variables, other values are left out.
+// Strings are quoted with hclString, so values such as secrets cannot break the file or be evaluated as templates.
+func writeVars(path string, vars map[string]interface{}) error {
+	var tfvars strings.Builder
+	for k, v := range vars {
 		switch t := v.(type) {
 		case int:
-			if _, err := vars.WriteString(fmt.Sprintf("%s = \"%d\"\n", k, t)); err != nil {
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = \"%d\"\n", k, t)); err != nil {
+				return err
+			}
+		case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = \"%d\"\n", k, t)); err != nil {
+				return err
+			}
+		case float32:
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = \"%s\"\n", k, strconv.FormatFloat(float64(t), 'f', -1, 32))); err != nil {
+				return err
+			}
+		case float64:
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = \"%s\"\n", k, strconv.FormatFloat(t, 'f', -1, 64))); err != nil {
 				return err
 			}
 		case string:
-			if _, err := vars.WriteString(fmt.Sprintf("%s = \"%s\"\n", k, t)); err != nil {
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = %s\n", k, hclString(t))); err != nil {
+				return err
+			}
+		case bool:
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = %t\n", k, t)); err != nil {
 				return err
 			}
 		case time.Duration:
-			if _, err := vars.WriteString(fmt.Sprintf("%s = \"%s\"\n", k, t.String())); err != nil {
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = \"%s\"\n", k, t.String())); err != nil {
 				return err
 			}
 		case []string:
 			var a []string
 			for _, v := range t {
-				x := fmt.Sprintf("\"%s\"", v)
-				a = append(a, x)
+				a = append(a, hclString(v))
 			}
 			b := strings.Join(a, ",")
-			if _, err := vars.WriteString(fmt.Sprintf("%s = [%s]\n", k, b)); err != nil {
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = [%s]\n", k, b)); err != nil {
+				return err
+			}
+		case map[string]string:
+			if _, err := tfvars.WriteString(fmt.Sprintf("%s = %s\n", k, hclMap(t))); err != nil {
 				return err
 			}
 		}
 
 	}
-	if err := ioutil.WriteFile(filepath.Join(dir, tfVarsFile), []byte(vars.String()), 0700); err != nil {
+	if err := ioutil.WriteFile(path, []byte(tfvars.String()), 0700); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// stateFromFile loads the terraform state file for the given cluster
-func stateFromFile(dataDir, project, cluster string, p types.ProviderType) (*statefile.File, error) {
-	dir, err := clusterDir(dataDir, project, cluster, p)
+// stateFromFile loads the terraform state file for the given cluster, decrypting it if needed.
+// A state in an older format is upgraded in the file, a state terraform cannot read fails with a StateVersionError, see readState.
+func stateFromFile(ops Options, project, cluster string, p types.ProviderType) (*statefile.File, error) {
+	dir, err := clusterDir(ops, project, cluster, p)
 	if err != nil {
 		return nil, err
 	}
 
 	stateFilePath := filepath.Join(dir, tfStateFile)
-	f, err := os.Open(stateFilePath)
+	data, err := ioutil.ReadFile(stateFilePath)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(types.ErrStateNotFound, "there is no state file %s", stateFilePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// between the operations the state may be encrypted
+	plain, err := decryptState(ops.StateEncryptionKey, data)
 	if err != nil {
 		return nil, err
 	}
 
-	st, err := statefile.Read(f)
+	st, format, err := readState(plain)
 	if err != nil {
 		return nil, err
 	}
+	// states of older terraform versions are upgraded in place, once
+	if format < stateFormatVersion {
+		if err := upgradeStateFile(ops, dir, st, data); err != nil {
+			return nil, errors.Wrapf(err, "could not upgrade the state file %s from format version %d", stateFilePath, format)
+		}
+	}
 	return st, nil
 }
 
-// stateToFile saves the terraform state into its corresponding file
-func stateToFile(state *statefile.File, dataDir, project, cluster string, p types.ProviderType) error {
-	dir, err := clusterDir(dataDir, project, cluster, p)
+// stateToFile saves the terraform state into its corresponding file, encrypted with the StateEncryptionKey option,
+// so the state is never at rest in plaintext, see plainState.
+func stateToFile(state *statefile.File, ops Options, project, cluster string, p types.ProviderType) error {
+	dir, err := clusterDir(ops, project, cluster, p)
 	if err != nil {
 		return err
 	}
 
-	stateFilePath := filepath.Join(dir, tfStateFile)
-	f, err := os.Create(stateFilePath)
-	if err != nil {
+	var buf bytes.Buffer
+	if err := statefile.Write(state, &buf); err != nil {
 		return err
 	}
-	return statefile.Write(state, f)
+	data := buf.Bytes()
+	if len(ops.StateEncryptionKey) > 0 {
+		if data, err = encryptState(ops.StateEncryptionKey, data); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(filepath.Join(dir, tfStateFile), data, 0600)
 }
 
-func clusterInfoFromFile(dataDir, project, cluster string, p types.ProviderType) (*types.ClusterInfo, error) {
-	sf, err := stateFromFile(dataDir, project, cluster, p)
+// removeStateFile removes the terraform state file of the given cluster and its backup from the data dir.
+func removeStateFile(ops Options, project, cluster string, p types.ProviderType) error {
+	dir, err := clusterDir(ops, project, cluster, p)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
+	for _, f := range []string{tfStateFile, tfStateFile + ".backup"} {
+		if err := os.Remove(filepath.Join(dir, f)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// clusterInfoFromState extracts the ClusterInfo from the outputs of the given terraform state.
+func clusterInfoFromState(sf *statefile.File) (*types.ClusterInfo, error) {
+	var err error
 	var certificateData []byte
-	var endpoint string
+	var endpoint, kubeconfig string
+	var outputs map[string]interface{}
+	var sensitive []string
+	var autoscaling map[string]bool
+	var private bool
+	var zones []string
 
 	if len(sf.State.Modules) > 0 {
 		if val, ok := sf.State.Modules[""].OutputValues["cluster_ca_certificate"]; ok {
@@ -395,16 +1173,79 @@ func clusterInfoFromFile(dataDir, project, cluster string, p types.ProviderType)
 		if val, ok := sf.State.Modules[""].OutputValues["endpoint"]; ok {
 			endpoint = val.Value.AsString()
 		}
+		if val, ok := sf.State.Modules[""].OutputValues["kubeconfig"]; ok {
+			kubeconfig = val.Value.AsString()
+		}
+		outputs, sensitive, err = stateOutputs(sf)
+		if err != nil {
+			return &types.ClusterInfo{
+				InternalState: &types.InternalState{TerraformState: sf},
+				Status:        &types.ClusterStatus{Phase: types.Errored},
+			}, errors.Wrap(err, "Unable to decode the outputs")
+		}
+		autoscaling, err = nodePoolAutoscaling(sf)
+		if err != nil {
+			return &types.ClusterInfo{
+				InternalState: &types.InternalState{TerraformState: sf},
+				Status:        &types.ClusterStatus{Phase: types.Errored},
+			}, errors.Wrap(err, "Unable to decode the node pools")
+		}
+		private, err = privateEndpoint(sf)
+		if err != nil {
+			return &types.ClusterInfo{
+				InternalState: &types.InternalState{TerraformState: sf},
+				Status:        &types.ClusterStatus{Phase: types.Errored},
+			}, errors.Wrap(err, "Unable to decode the cluster resource")
+		}
+		zones, err = clusterZones(sf)
+		if err != nil {
+			return &types.ClusterInfo{
+				InternalState: &types.InternalState{TerraformState: sf},
+				Status:        &types.ClusterStatus{Phase: types.Errored},
+			}, errors.Wrap(err, "Unable to decode the zones of the cluster")
+		}
 	}
 
 	return &types.ClusterInfo{
 		Endpoint:                 endpoint,
 		CertificateAuthorityData: certificateData,
+		Kubeconfig:               kubeconfig,
+		Outputs:                  outputs,
+		SensitiveOutputs:         sensitive,
+		Autoscaling:              autoscaling,
+		PrivateEndpoint:          private,
+		Zones:                    zones,
 		InternalState:            &types.InternalState{TerraformState: sf},
 		Status:                   &types.ClusterStatus{Phase: types.Provisioned},
 	}, nil
 }
 
+// stateOutputs returns the values of all outputs of the root module in the given state and the names of the sensitive ones.
+// Values are converted to their JSON representation, so lists become []interface{} and objects map[string]interface{}.
+func stateOutputs(sf *statefile.File) (map[string]interface{}, []string, error) {
+	outputs := make(map[string]interface{})
+	var sensitive []string
+	for name, o := range sf.State.Modules[""].OutputValues {
+		if !o.Value.IsWhollyKnown() {
+			continue
+		}
+		data, err := ctyjson.Marshal(o.Value, o.Value.Type())
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "could not convert output %s", name)
+		}
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, nil, errors.Wrapf(err, "could not convert output %s", name)
+		}
+		outputs[name] = v
+		if o.Sensitive {
+			sensitive = append(sensitive, name)
+		}
+	}
+	sort.Strings(sensitive)
+	return outputs, sensitive, nil
+}
+
 func globalPluginDirs() ([]string, error) {
 	var ret []string
 	// Look in ~/.terraform.d/plugins/ , or its equivalent on non-UNIX
@@ -419,10 +1260,23 @@ func globalPluginDirs() ([]string, error) {
 	return ret, nil
 }
 
-// clusterDir either returns or creates the directory for a given cluster inside the given data directory.
+// clusterPath returns the absolute directory of the given cluster for the data dir and the path strategy of the options, without creating it.
+func clusterPath(ops Options, project, cluster string, p types.ProviderType) (string, error) {
+	strategy := ops.PathStrategy
+	if strategy == nil {
+		strategy = defaultPathStrategy
+	}
+	path := strategy(ops.DataDir(), project, cluster, p)
+	if path == "" {
+		return "", errors.Errorf("the path strategy returned no directory for cluster %s of project %s on %s", cluster, project, p)
+	}
+	return filepath.Abs(path)
+}
+
+// clusterDir either returns or creates the directory for a given cluster inside the data directory, following the path strategy of the options.
 // All state and configuration files needed by the operator will be stored in this directory.
-func clusterDir(dataDir, project, cluster string, p types.ProviderType) (string, error) {
-	clDir, err := filepath.Abs(filepath.Join(dataDir, "clusters", string(p), project, cluster))
+func clusterDir(ops Options, project, cluster string, p types.ProviderType) (string, error) {
+	clDir, err := clusterPath(ops, project, cluster, p)
 	if err != nil {
 		return "", err
 	}
@@ -446,10 +1300,18 @@ func expandGardenerClusterTemplate(cfg map[string]interface{}) (string, error) {
 		WorkerNets   []string
 		PublicNets   []string
 		InternalNets []string
-		Cfg          map[string]interface{}
+		// Hibernation is empty if the configuration does not set the hibernation, so shoots not managed with it are left alone
+		Hibernation string
+		// Addons are the Gardener add-ons enabled in the shoot, the others are left to their defaults
+		Addons []string
+		Cfg    map[string]interface{}
 	}{}
 
 	tmpCfg.Cfg = cfg
+	tmpCfg.Addons = stringValues(cfg["addons"])
+	if hibernated, ok := cfg["hibernated"].(bool); ok {
+		tmpCfg.Hibernation = strconv.FormatBool(hibernated)
+	}
 
 	funcs := template.FuncMap{
 		"seq": func(n int) []int {
@@ -460,6 +1322,7 @@ func expandGardenerClusterTemplate(cfg map[string]interface{}) (string, error) {
 			}
 			return r
 		},
+		"contains": contains,
 	}
 
 	if cfg["target_provider"] == string(types.AWS) {
@@ -479,14 +1342,111 @@ func expandGardenerClusterTemplate(cfg map[string]interface{}) (string, error) {
 	return s.String(), nil
 }
 
-// cleanup removes all terraform generated files for a given cluster
-func cleanup(dataDir, project, cluster string, p types.ProviderType) error {
-	d, err := clusterDir(dataDir, project, cluster, p)
+// cleanup removes all terraform generated files for a given cluster.
+// It removes as many files as possible and returns a CleanupError listing the ones left on disk.
+func cleanup(ops Options, project, cluster string, p types.ProviderType) error {
+	d, err := clusterDir(ops, project, cluster, p)
 	if err != nil {
 		return err
 	}
 
-	return os.RemoveAll(d)
+	return removeAll(d)
+}
+
+// removeAll removes the given path and everything it contains like os.RemoveAll,
+// but carries on when a file cannot be removed and returns a CleanupError with all of them.
+func removeAll(path string) error {
+	failed := make(map[string]error)
+	removeTree(path, failed)
+	if len(failed) > 0 {
+		return &types.CleanupError{Failed: failed}
+	}
+	return nil
+}
+
+func removeTree(path string, failed map[string]error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		failed[path] = err
+		return
+	}
+
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			failed[path] = err
+			return
+		}
+		before := len(failed)
+		for _, e := range entries {
+			removeTree(filepath.Join(path, e.Name()), failed)
+		}
+		// the directory is not empty, the files left in it are already reported
+		if len(failed) > before {
+			return
+		}
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		failed[path] = err
+	}
+}
+
+// defaultPathStrategy places the directory of a cluster at clusters/<provider>/<project>/<cluster> in the data dir.
+func defaultPathStrategy(dataDir, project, cluster string, p types.ProviderType) string {
+	return filepath.Join(dataDir, "clusters", string(p), project, cluster)
+}
+
+// clusterRefs returns the clusters that have a directory in the data dir, following its clusters/<provider>/<project>/<cluster> layout.
+func clusterRefs(dataDir string) ([]types.ClusterRef, error) {
+	var refs []types.ClusterRef
+	clustersDir := filepath.Join(dataDir, "clusters")
+	if _, err := os.Stat(clustersDir); os.IsNotExist(err) {
+		return refs, nil
+	}
+
+	providers, err := subDirs(clustersDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range providers {
+		projects, err := subDirs(filepath.Join(clustersDir, p))
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			clusters, err := subDirs(filepath.Join(clustersDir, p, project))
+			if err != nil {
+				return nil, err
+			}
+			for _, cluster := range clusters {
+				refs = append(refs, types.ClusterRef{
+					Provider: types.ProviderType(p),
+					Project:  project,
+					Name:     cluster,
+				})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// subDirs returns the names of the directories inside the given path.
+func subDirs(path string) ([]string, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	return dirs, nil
 }
 
 // isEmptyDir returns true if the given path contains no files or subdirectories, false otherwise.
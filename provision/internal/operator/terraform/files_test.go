@@ -0,0 +1,211 @@
+package terraform
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestClusterInfoFromState(t *testing.T) {
+	t.Parallel()
+	state := states.NewState()
+	state.RootModule().SetOutputValue("endpoint", cty.StringVal("1.2.3.4"), false)
+	state.RootModule().SetOutputValue("cluster_ca_certificate", cty.StringVal(base64.StdEncoding.EncodeToString([]byte("ca"))), false)
+	state.RootModule().SetOutputValue("node_count", cty.NumberIntVal(3), false)
+	state.RootModule().SetOutputValue("zones", cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}), false)
+	state.RootModule().SetOutputValue("service_account_key", cty.StringVal("secret"), true)
+	sf := statefile.New(state, "", 0)
+
+	info, err := clusterInfoFromState(sf)
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", info.Endpoint)
+	require.Equal(t, []byte("ca"), info.CertificateAuthorityData)
+	require.Equal(t, types.Provisioned, info.Status.Phase)
+	require.Equal(t, map[string]interface{}{
+		"endpoint":               "1.2.3.4",
+		"cluster_ca_certificate": base64.StdEncoding.EncodeToString([]byte("ca")),
+		"node_count":             float64(3),
+		"zones":                  []interface{}{"a", "b"},
+		"service_account_key":    "secret",
+	}, info.Outputs)
+	require.Equal(t, []string{"service_account_key"}, info.SensitiveOutputs)
+}
+
+func TestInitClusterFilesVars(t *testing.T) {
+	t.Parallel()
+	dataDir, err := ioutil.TempDir("", "hydroform-vars")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	cfg := map[string]interface{}{
+		"project":      "my-project",
+		"cluster_name": "my-cluster",
+		"node_count":   3,
+		"disk_size":    int64(30),
+		"max_price":    0.25,
+	}
+	require.NoError(t, initClusterFiles(options(WithDataDir(dataDir)), types.AWS, cfg, nil))
+
+	dir, err := clusterDir(options(WithDataDir(dataDir)), "my-project", "my-cluster", types.AWS)
+	require.NoError(t, err)
+	vars, err := ioutil.ReadFile(filepath.Join(dir, tfVarsFile))
+	require.NoError(t, err)
+	require.Contains(t, string(vars), "node_count = \"3\"\n")
+	require.Contains(t, string(vars), "disk_size = \"30\"\n")
+	require.Contains(t, string(vars), "max_price = \"0.25\"\n")
+}
+
+func TestInitClusterFilesCredentials(t *testing.T) {
+	t.Parallel()
+	dataDir, err := ioutil.TempDir("", "hydroform-vars")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	ops := options(WithDataDir(dataDir))
+
+	cfg, remove, err := withCredentials(types.GCP, map[string]interface{}{
+		"project":      "my-project",
+		"cluster_name": "my-cluster",
+		"node_count":   3,
+	}, map[types.ProviderType]types.Credentials{types.GCP: {File: []byte("key")}})
+	require.NoError(t, err)
+	require.NoError(t, initClusterFiles(ops, types.GCP, cfg, nil))
+
+	dir, err := clusterDir(ops, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	vars, err := ioutil.ReadFile(filepath.Join(dir, tfVarsFile))
+	require.NoError(t, err)
+	require.Contains(t, string(vars), "node_count = \"3\"\n")
+	require.NotContains(t, string(vars), "credentials_file_path", "The credentials should not stay in the cluster dir")
+
+	creds := cfg[credentialVarsKey].(*credentialVars)
+	credVars, err := ioutil.ReadFile(creds.file)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("credentials_file_path = \"%s\"\n", cfg["credentials_file_path"]), string(credVars))
+	args := varFileArgs(ops, cfg, dir)
+	require.Equal(t, "-var-file="+creds.file, args[len(args)-1], "The credentials should be passed to terraform")
+
+	require.NoError(t, remove())
+	require.Equal(t, []string{"-var-file=" + filepath.Join(dir, tfVarsFile)}, varFileArgs(ops, cfg, dir))
+}
+
+func TestInitClusterFilesSecretEscaping(t *testing.T) {
+	t.Parallel()
+	dataDir, err := ioutil.TempDir("", "hydroform-vars")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	ops := options(WithDataDir(dataDir))
+
+	secret := `p"ss\${var.cluster_name}%{ if true }x%{ endif }\`
+	cfg, remove, err := withCredentials(types.OpenStack, map[string]interface{}{
+		"project":      "my-project",
+		"cluster_name": "my-cluster",
+		"dns_servers":  []string{`"1.1.1.1"`, "${var.password}"},
+	}, map[types.ProviderType]types.Credentials{types.OpenStack: {Values: map[string]string{"user_name": "admin", "password": secret}}})
+	require.NoError(t, err)
+	defer remove()
+	require.NoError(t, initClusterFiles(ops, types.OpenStack, cfg, nil))
+
+	creds := cfg[credentialVarsKey].(*credentialVars)
+	f, diags := hclparse.NewParser().ParseHCLFile(creds.file)
+	require.False(t, diags.HasErrors(), "The vars file of the credentials should be valid: %s", diags)
+	attrs, diags := f.Body.JustAttributes()
+	require.False(t, diags.HasErrors(), diags.Error())
+	password, diags := attrs["password"].Expr.Value(nil)
+	require.False(t, diags.HasErrors(), "The secret should not be evaluated as a template: %s", diags)
+	require.Equal(t, secret, password.AsString())
+
+	dir, err := clusterDir(ops, "my-project", "my-cluster", types.OpenStack)
+	require.NoError(t, err)
+	f, diags = hclparse.NewParser().ParseHCLFile(filepath.Join(dir, tfVarsFile))
+	require.False(t, diags.HasErrors(), diags.Error())
+	attrs, diags = f.Body.JustAttributes()
+	require.False(t, diags.HasErrors(), diags.Error())
+	servers, diags := attrs["dns_servers"].Expr.Value(nil)
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Equal(t, cty.TupleVal([]cty.Value{cty.StringVal(`"1.1.1.1"`), cty.StringVal("${var.password}")}), servers)
+}
+
+func TestPathStrategy(t *testing.T) {
+	t.Parallel()
+	dataDir, err := ioutil.TempDir("", "hf-path-strategy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	tenantOps := func(tenant string) Options {
+		return options(WithDataDir(dataDir), WithPathStrategy(func(dataDir, project, cluster string, p types.ProviderType) string {
+			return filepath.Join(dataDir, "tenants", tenant, string(p), project, cluster)
+		}))
+	}
+	opsA, opsB := tenantOps("a"), tenantOps("b")
+
+	dir, err := clusterDir(opsA, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dataDir, "tenants", "a", "gcp", "my-project", "my-cluster"), dir)
+
+	// the same cluster of two tenants does not collide
+	require.NoError(t, stateToFile(statefile.New(states.NewState(), "tenant-a", 0), opsA, "my-project", "my-cluster", types.GCP))
+	require.FileExists(t, filepath.Join(dir, tfStateFile))
+	_, err = stateFromFile(opsB, "my-project", "my-cluster", types.GCP)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "Another tenant should not see the state")
+	sf, err := stateFromFile(opsA, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.Equal(t, "tenant-a", sf.Lineage)
+
+	unlock, err := lockCluster(opsA, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	defer unlock()
+	unlockB, err := lockCluster(opsB, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err, "Another tenant should have its own lock")
+	unlockB()
+
+	require.NoError(t, cleanup(opsA, "my-project", "my-cluster", types.GCP))
+	_, err = os.Stat(dir)
+	require.True(t, os.IsNotExist(err), "The cleanup should remove the directory of the strategy")
+	_, err = os.Stat(filepath.Join(dataDir, "clusters"))
+	require.True(t, os.IsNotExist(err), "Nothing should be written to the default layout")
+
+	_, err = (&Terraform{ops: opsA}).List()
+	require.Error(t, err, "The clusters cannot be listed with a path strategy")
+
+	_, err = clusterDir(options(WithPathStrategy(func(string, string, string, types.ProviderType) string { return "" })), "my-project", "my-cluster", types.GCP)
+	require.Error(t, err, "A strategy without a directory should fail")
+
+	tfOps := ToTerraformOptions(&types.Options{PathStrategy: opsA.PathStrategy})
+	require.Len(t, tfOps, 1, "The path strategy should be passed to the operator")
+}
+
+func TestRemoveAll(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hydroform-remove")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "b", "file"), []byte("data"), 0600))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "link")))
+
+	require.NoError(t, removeAll(dir))
+	_, err = os.Stat(dir)
+	require.True(t, os.IsNotExist(err), "The whole tree should be removed")
+	require.NoError(t, removeAll(dir), "Removing a missing path should succeed")
+}
+
+func TestCleanupError(t *testing.T) {
+	t.Parallel()
+	err := &types.CleanupError{Failed: map[string]error{
+		"/data/b": errors.New("permission denied"),
+		"/data/a": errors.New("file in use"),
+	}}
+	require.Equal(t, "could not remove the following files: /data/a (file in use), /data/b (permission denied)", err.Error())
+}
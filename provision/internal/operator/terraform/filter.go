@@ -8,12 +8,19 @@ import "github.com/kyma-incubator/hydroform/provision/types"
 type varFilter func(key string, value interface{}) bool
 
 func gcpFilter(key string, value interface{}) bool {
-	// by default all keys stay in the vars for GCP
+	// all keys stay in the vars for GCP but the private cluster settings and the node auto-provisioning, they have their own files
+	for _, e := range append(privateClusterKeys, "node_auto_provisioning") {
+		if key == e {
+			return false
+		}
+	}
 	return true
 }
 
 func azureFilter(key string, value interface{}) bool {
-	excludedKeys := []string{"project", "create_timeout", "update_timeout", "delete_timeout"}
+	// the labels are rendered as tags and the zones and identity settings into their own files, and an existing resource group is read by a data source
+	excludedKeys := append([]string{"project", "create_timeout", "update_timeout", "delete_timeout", "labels", "create_resource_group", "availability_zones"}, privateClusterKeys...)
+	excludedKeys = append(excludedKeys, securityKeys...)
 
 	for _, e := range excludedKeys {
 		if key == e {
@@ -29,8 +36,8 @@ func awsFilter(key string, value interface{}) bool {
 }
 
 func gardenerFilter(key string, value interface{}) bool {
-	// by default all keys stay in the vars for Gardener
-	return true
+	// all keys stay in the vars for Gardener but the hibernation, it is rendered into the template
+	return key != "hibernated"
 }
 
 func kindFilter(key string, value interface{}) bool {
@@ -38,6 +45,28 @@ func kindFilter(key string, value interface{}) bool {
 	return true
 }
 
+func openstackFilter(key string, value interface{}) bool {
+	// by default all keys stay in the vars for OpenStack
+	return true
+}
+
+func digitaloceanFilter(key string, value interface{}) bool {
+	// the project only groups the hydroform files and the DigitalOcean cluster resource has no timeouts
+	excludedKeys := []string{"project", "create_timeout", "update_timeout", "delete_timeout"}
+
+	for _, e := range excludedKeys {
+		if key == e {
+			return false
+		}
+	}
+	return true
+}
+
+func alicloudFilter(key string, value interface{}) bool {
+	// the project only groups the hydroform files, AliCloud has no projects
+	return key != "project"
+}
+
 // filterVars takes the full hydroform configuration map and given a provider, it fetches its filter function and removes the keys that should not be there.
 // Each provider should implement varFilter to control which vars it should have in its tfvars file.
 func filterVars(cfg map[string]interface{}, p types.ProviderType) map[string]interface{} {
@@ -51,12 +80,47 @@ func filterVars(cfg map[string]interface{}, p types.ProviderType) map[string]int
 	case types.Azure:
 		f = azureFilter
 	case types.AWS:
-		f = awsFilter // not supported yet
+		f = awsFilter
 	case types.Kind:
 		f = kindFilter
+	case types.OpenStack:
+		f = openstackFilter
+	case types.DigitalOcean:
+		f = digitaloceanFilter
+	case types.AliCloud:
+		f = alicloudFilter
 	}
 
 	for key, value := range cfg {
+		// templates without labels do not declare the variable
+		if key == "labels" && !labelProviders[p] {
+			continue
+		}
+		if contains(securityKeys, key) && !securityProviders[p] {
+			continue
+		}
+		// the cluster ID only guards the deletion, no template declares it
+		if key == "cluster_id" {
+			continue
+		}
+		// the context name only applies to the returned kubeconfigs
+		if key == "kubeconfig_context_name" {
+			continue
+		}
+		// the extra vars have their own vars file
+		if key == "extra_vars" {
+			continue
+		}
+		// the names of the credential vars are kept, the filtered ones are written into the vars file of the credentials
+		if key == credentialVarsKey {
+			vars[key] = value
+			continue
+		}
+		// the vars of the additional providers are declared with their blocks
+		if creds, ok := cfg[credentialVarsKey].(*credentialVars); ok && creds.providerVar(key) {
+			vars[key] = value
+			continue
+		}
 		if f(key, value) {
 			vars[key] = value
 		}
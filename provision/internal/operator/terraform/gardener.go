@@ -9,7 +9,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // TODO remove this file when the gardener provider is on the official terraform registry
@@ -20,14 +23,39 @@ const (
 	providerVersion = "v0.0.10"
 )
 
+// gardenerProvider guards the installation of the gardener provider, concurrent operations of the process install it only once.
+var gardenerProvider struct {
+	sync.Mutex
+	installed bool
+}
+
 // initGardenerProvider will check if the gardener provider is available and download it if not.
+// It is safe for concurrent use: the first call installs the provider, the others wait for it and reuse it.
+// A failed installation is retried by the next call.
+func initGardenerProvider(transport http.RoundTripper) error {
+	gardenerProvider.Lock()
+	defer gardenerProvider.Unlock()
+	if gardenerProvider.installed {
+		return nil
+	}
 
-func initGardenerProvider() error {
 	pluginDirs, err := globalPluginDirs()
 	if err != nil {
 		return err
 	}
-	providerPath := filepath.Join(pluginDirs[1], fmt.Sprintf("%s_%s", providerName, providerVersion))
+	if err := installGardenerProvider(transport, pluginDirs[1], fmt.Sprintf(providerURL, providerVersion, runtime.GOOS, runtime.GOARCH)); err != nil {
+		return err
+	}
+
+	gardenerProvider.installed = true
+	return nil
+}
+
+// installGardenerProvider downloads the gardener provider from the given URL into the plugin dir, unless it is there already.
+// The plugin is written to a temporary file first and renamed once complete,
+// so other processes sharing the plugin dir never run a partially written plugin.
+func installGardenerProvider(transport http.RoundTripper, pluginDir, url string) error {
+	providerPath := filepath.Join(pluginDir, fmt.Sprintf("%s_%s", providerName, providerVersion))
 
 	//check if plugin is in the plugins dir
 	if _, err := os.Stat(providerPath); !os.IsNotExist(err) {
@@ -41,27 +69,17 @@ func initGardenerProvider() error {
 	}
 
 	// Download the plugin for the OS and arch
-	r, err := downloadBinary(fmt.Sprintf(providerURL, providerVersion, runtime.GOOS, runtime.GOARCH))
+	r, err := downloadBinary(transport, url)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
 
 	// save the file
-	if _, err := os.Stat(pluginDirs[1]); os.IsNotExist(err) {
-		err = os.MkdirAll(pluginDirs[1], 0700)
-		if err != nil {
-			return err
-		}
-	}
-	providerFile, err := os.OpenFile(providerPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0700)
-	if err != nil {
-		return err
-	}
-	if _, err := io.Copy(providerFile, r); err != nil {
+	if err := os.MkdirAll(pluginDir, 0700); err != nil {
 		return err
 	}
-	if err := providerFile.Close(); err != nil {
+	if err := writeFileAtomic(providerPath, r); err != nil {
 		return err
 	}
 	if runtime.GOOS == "windows" {
@@ -73,38 +91,63 @@ func initGardenerProvider() error {
 	}
 
 	// if just downloaded a new version successfully, delete any old ones
-	err = filepath.Walk(pluginDirs[1], func(path string, info os.FileInfo, err error) error {
-
+	// another process may be deleting them at the same time
+	err = filepath.Walk(pluginDir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
 
-		if strings.HasPrefix(info.Name(), providerName) && !strings.HasSuffix(info.Name(), providerVersion) {
-			return os.Remove(path)
+		if strings.HasPrefix(info.Name(), providerName) && !strings.HasSuffix(strings.TrimSuffix(info.Name(), ".exe"), providerVersion) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
 		}
 		return nil
 	})
 	return err
 }
 
+// writeFileAtomic writes the content of r into an executable file at the given path, which only appears once it is complete.
+// The temporary file starts with a dot, so it is never taken for an old version of the provider.
+func writeFileAtomic(path string, r io.Reader) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0700); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
 func generateWindowsBinary(providerPath string) error {
 	windowsProviderPath := providerPath + ".exe"
 	if _, err := os.Stat(windowsProviderPath); os.IsNotExist(err) {
-		providerFile, err := ioutil.ReadFile(providerPath)
-		if err != nil {
-			return err
-		}
-		err = ioutil.WriteFile(windowsProviderPath, providerFile, 0700)
+		providerFile, err := os.Open(providerPath)
 		if err != nil {
 			return err
 		}
+		defer providerFile.Close()
+		return writeFileAtomic(windowsProviderPath, providerFile)
 	}
 	return nil
 }
 
-func downloadBinary(url string) (io.ReadCloser, error) {
+func downloadBinary(transport http.RoundTripper, url string) (io.ReadCloser, error) {
 	c := &http.Client{
-		Timeout: 5 * time.Minute,
+		Transport: transport,
+		Timeout:   5 * time.Minute,
 	}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -116,5 +159,10 @@ func downloadBinary(url string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
+	// never install an error page, such as the one of a rate limit, as the plugin
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("could not download %s: %s", url, resp.Status)
+	}
 	return resp.Body, nil
 }
@@ -0,0 +1,84 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallGardenerProvider(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-gardener-plugins")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plugin := strings.Repeat("plugin", 1<<16)
+	var downloads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "rate limit exceeded", http.StatusForbidden)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		fmt.Fprint(w, plugin)
+	}))
+	defer srv.Close()
+
+	providerPath := filepath.Join(dir, fmt.Sprintf("%s_%s", providerName, providerVersion))
+	require.Error(t, installGardenerProvider(nil, dir, srv.URL+"/missing"), "An error page should not be installed as the plugin")
+	_, err = os.Stat(providerPath)
+	require.True(t, os.IsNotExist(err))
+
+	// an old version is replaced
+	old := filepath.Join(dir, providerName+"_v0.0.1")
+	require.NoError(t, ioutil.WriteFile(old, []byte("old"), 0700))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = installGardenerProvider(nil, dir, srv.URL+"/plugin")
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	data, err := ioutil.ReadFile(providerPath)
+	require.NoError(t, err)
+	require.Equal(t, plugin, string(data), "The plugin should be complete")
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	for _, f := range files {
+		require.False(t, strings.HasPrefix(f.Name(), "."), "The temporary file %s should be removed", f.Name())
+	}
+	_, err = os.Stat(old)
+	require.True(t, os.IsNotExist(err), "The old version should be removed")
+
+	// an installed plugin is not downloaded again
+	n := atomic.LoadInt32(&downloads)
+	require.NoError(t, installGardenerProvider(nil, dir, srv.URL+"/plugin"))
+	require.Equal(t, n, atomic.LoadInt32(&downloads))
+}
+
+func TestSkipGardenerInit(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, initProvider(options(WithSkipGardenerInit()), types.Gardener, map[string]interface{}{}))
+
+	gardenerProvider.Lock()
+	defer gardenerProvider.Unlock()
+	require.False(t, gardenerProvider.installed, "The provider should not be installed when the caller installs it")
+}
@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// file name for the node auto-provisioning of GKE, terraform merges it into the cluster resource as an override file
+	tfAutoProvisioningFile = "auto_provisioning_override.tf"
+
+	gcpAutoProvisioningTemplate = `
+resource "google_container_cluster" "gke_cluster" {
+	cluster_autoscaling {
+		enabled = true
+
+		resource_limits {
+			resource_type = "cpu"
+			minimum       = {{.MinCPU}}
+			maximum       = {{.MaxCPU}}
+		}
+
+		resource_limits {
+			resource_type = "memory"
+			minimum       = {{.MinMemoryGB}}
+			maximum       = {{.MaxMemoryGB}}
+		}
+	}
+}
+`
+)
+
+// gkeReleaseChannels are the release channels a GKE cluster can be enrolled in.
+var gkeReleaseChannels = []string{"RAPID", "REGULAR", "STABLE"}
+
+// writeAutoProvisioningFile renders the node auto-provisioning of the configuration into an override file of the GKE cluster resource.
+// The file is removed if the configuration does not enable it, so GKE stops provisioning node pools.
+func writeAutoProvisioningFile(dir string, p types.ProviderType, cfg map[string]interface{}) error {
+	path := filepath.Join(dir, tfAutoProvisioningFile)
+	nap, ok := cfg["node_auto_provisioning"].(types.NodeAutoProvisioning)
+	if p != types.GCP || !ok {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	t, err := template.New("autoProvisioning").Parse(gcpAutoProvisioningTemplate)
+	if err != nil {
+		return err
+	}
+	s := &strings.Builder{}
+	if err := t.Execute(s, nap); err != nil {
+		return errors.Wrap(err, "could not render the node auto-provisioning")
+	}
+	return ioutil.WriteFile(path, []byte(s.String()), 0700)
+}
+
+// gkeErrors checks the release channel and the node auto-provisioning of the configuration and returns an error for each invalid field.
+// The kubernetes version can only be left out when a release channel picks it.
+func gkeErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	if p != types.GCP {
+		return nil
+	}
+
+	var errs []types.FieldError
+	channel, hasChannel := cfg["release_channel"].(string)
+	validChannel := false
+	for _, c := range gkeReleaseChannels {
+		validChannel = validChannel || c == channel
+	}
+	if hasChannel && !validChannel {
+		errs = append(errs, types.FieldError{Field: "release_channel", Reason: fmt.Sprintf("must be one of %s, got %q", strings.Join(gkeReleaseChannels, ", "), channel)})
+	}
+	if v, ok := cfg["kubernetes_version"]; (!ok || v == nil) && !hasChannel {
+		errs = append(errs, types.FieldError{Field: "kubernetes_version", Reason: "is missing, it can only be left out with a release channel"})
+	}
+
+	if nap, ok := cfg["node_auto_provisioning"].(types.NodeAutoProvisioning); ok {
+		if nap.MaxCPU <= 0 || nap.MinCPU < 0 || nap.MinCPU > nap.MaxCPU {
+			errs = append(errs, types.FieldError{Field: "node_auto_provisioning", Reason: "must have a maximum CPU above 0 and not below the minimum CPU"})
+		}
+		if nap.MaxMemoryGB <= 0 || nap.MinMemoryGB < 0 || nap.MinMemoryGB > nap.MaxMemoryGB {
+			errs = append(errs, types.FieldError{Field: "node_auto_provisioning", Reason: "must have a maximum memory above 0 and not below the minimum memory"})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,79 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAutoProvisioningFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-auto-provisioning")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(gcpClusterTemplate), 0600))
+
+	cfg := map[string]interface{}{
+		"release_channel":        "REGULAR",
+		"node_auto_provisioning": types.NodeAutoProvisioning{MaxCPU: 32, MaxMemoryGB: 128},
+		"node_pools":             []types.NodePool{{Name: "gpu", MachineType: "n1-standard-4", NodeCount: 1}},
+	}
+	require.NoError(t, writeNodePoolsFile(dir, types.GCP, cfg))
+	require.NoError(t, writeAutoProvisioningFile(dir, types.GCP, cfg))
+	_, diags := configs.NewParser(nil).LoadConfigDir(dir)
+	require.False(t, diags.HasErrors(), "The node auto-provisioning should be valid terraform: %s", diags.Error())
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfAutoProvisioningFile))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "maximum       = 32")
+	require.Contains(t, string(data), "maximum       = 128")
+	require.False(t, gcpFilter("node_auto_provisioning", cfg["node_auto_provisioning"]), "The node auto-provisioning should not be in the vars")
+
+	// disabling it removes the override
+	require.NoError(t, writeAutoProvisioningFile(dir, types.GCP, map[string]interface{}{}))
+	_, err = os.Stat(filepath.Join(dir, tfAutoProvisioningFile))
+	require.True(t, os.IsNotExist(err), "The node auto-provisioning file should be removed once disabled")
+}
+
+func TestGKEErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, gkeErrors(types.GCP, map[string]interface{}{"kubernetes_version": "1.19"}))
+	require.Empty(t, gkeErrors(types.GCP, map[string]interface{}{"release_channel": "STABLE"}), "A release channel should pick the kubernetes version")
+	require.Empty(t, gkeErrors(types.GCP, map[string]interface{}{"release_channel": "RAPID", "kubernetes_version": "1.19"}))
+	require.Empty(t, gkeErrors(types.Azure, map[string]interface{}{}), "Only GKE clusters should be checked")
+
+	fields := []string{}
+	for _, e := range gkeErrors(types.GCP, map[string]interface{}{
+		"release_channel":        "weekly",
+		"node_auto_provisioning": types.NodeAutoProvisioning{MinCPU: 8, MaxCPU: 4, MaxMemoryGB: 16},
+	}) {
+		fields = append(fields, e.Field)
+	}
+	require.Equal(t, []string{"release_channel", "node_auto_provisioning"}, fields)
+
+	errs := gkeErrors(types.GCP, map[string]interface{}{})
+	require.Len(t, errs, 1)
+	require.Equal(t, "kubernetes_version", errs[0].Field, "The kubernetes version should be required without a release channel")
+}
+
+func TestGKEFieldsOnly(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"project":         "my-project",
+		"cluster_name":    "my-cluster",
+		"resource_group":  "my-group",
+		"location":        "westeurope",
+		"agent_count":     3,
+		"agent_vm_size":   "Standard_D2_v3",
+		"agent_disk_size": 30,
+		"release_channel": "REGULAR",
+	}
+	err := validateConfig(types.Azure, cfg)
+	require.Error(t, err, "AKS clusters should still need a kubernetes version")
+	require.Equal(t, []types.FieldError{{Field: "kubernetes_version", Reason: "is missing"}}, err.(*types.ValidationError).Fields)
+}
@@ -0,0 +1,140 @@
+package terraform
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	// shootResource is the Gardener API resource of the shoots
+	shootResource = schema.GroupVersionResource{Group: "core.gardener.cloud", Version: "v1beta1", Resource: "shoots"}
+
+	// hibernationTimeout limits the wait for a shoot to reach its hibernation state after terraform updated it
+	hibernationTimeout = 30 * time.Minute
+	// hibernationPollInterval is the time between two checks of the shoot status
+	hibernationPollInterval = 15 * time.Second
+	// wakeUpReadyTimeout limits the wait for the API server of a woken up shoot, if the options have no timeout
+	wakeUpReadyTimeout = 10 * time.Minute
+)
+
+// Hibernate hibernates a Gardener shoot to save costs: its nodes are removed and its control plane scaled down until WakeUp is called.
+// It returns the updated ClusterInfo once the shoot is hibernated, or ErrUnsupportedOperation if the provider is not Gardener.
+// If the state is nil, Hibernate will attempt to load the state from the file system.
+func (t *Terraform) Hibernate(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	return t.HibernateWithContext(context.Background(), sf, p, cfg)
+}
+
+// HibernateWithContext works as Hibernate but stops terraform gracefully and stops waiting for the shoot when the given context is done.
+func (t *Terraform) HibernateWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	return t.setHibernation(ctx, sf, p, cfg, true)
+}
+
+// WakeUp wakes up a hibernated Gardener shoot.
+// It returns the updated ClusterInfo once the shoot is running again and its API server serves requests, or ErrUnsupportedOperation if the provider is not Gardener.
+// Gardener reports the shoot reconciled before its API server is reachable, so WakeUp polls it with the kubeconfig of the ClusterInfo:
+// it fails with ErrNotReady if the API server does not serve requests within the timeout of WithWakeUpReadyTimeout, 10 minutes by default.
+// If the state is nil, WakeUp will attempt to load the state from the file system.
+func (t *Terraform) WakeUp(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	return t.WakeUpWithContext(context.Background(), sf, p, cfg)
+}
+
+// WakeUpWithContext works as WakeUp but stops terraform gracefully and stops waiting for the shoot when the given context is done.
+func (t *Terraform) WakeUpWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	return t.setHibernation(ctx, sf, p, cfg, false)
+}
+
+// setHibernation updates the hibernation of the shoot with terraform and waits until Gardener reconciled it.
+func (t *Terraform) setHibernation(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, hibernated bool) (*types.ClusterInfo, error) {
+	if p != types.Gardener {
+		return nil, errors.Wrapf(types.ErrUnsupportedOperation, "hibernation is not supported on %s", p)
+	}
+
+	c := make(map[string]interface{}, len(cfg)+1)
+	for k, v := range cfg {
+		c[k] = v
+	}
+	c["hibernated"] = hibernated
+
+	info, err := t.UpdateWithContext(ctx, sf, p, c)
+	if err != nil {
+		return info, err
+	}
+
+	// the in-memory credentials are removed once the update finishes, take the kubeconfig from the options again
+	kubeconfig := t.ops.Credentials[p].File
+	if len(kubeconfig) == 0 {
+		path, _ := cfg["credentials_file_path"].(string)
+		if kubeconfig, err = ioutil.ReadFile(path); err != nil {
+			return info, errors.Wrap(err, "could not read the Gardener kubeconfig")
+		}
+	}
+	restCfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return info, errors.Wrap(err, "could not load the Gardener kubeconfig")
+	}
+	client, err := dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return info, errors.Wrap(err, "could not create the Gardener client")
+	}
+
+	hctx, cancel := context.WithTimeout(ctx, hibernationTimeout)
+	defer cancel()
+	if err := waitForHibernation(hctx, client, cfg["namespace"].(string), cfg["cluster_name"].(string), hibernated); err != nil {
+		return info, err
+	}
+	if !hibernated {
+		if err := waitForWakeUp(ctx, info, t.ops.WakeUpReadyTimeout, readyPollInterval); err != nil {
+			return info, err
+		}
+	}
+	return info, nil
+}
+
+// waitForWakeUp polls the API server of the woken up shoot of the given ClusterInfo at the given interval until it serves requests.
+// It returns ErrNotReady if the API server does not serve requests within the timeout, or within wakeUpReadyTimeout if the timeout is zero.
+func waitForWakeUp(ctx context.Context, info *types.ClusterInfo, timeout, interval time.Duration) error {
+	if timeout == 0 {
+		timeout = wakeUpReadyTimeout
+	}
+	err := waitForClusterReady(ctx, info, timeout, interval)
+	if errors.Is(err, types.ErrTimeout) {
+		return errors.Wrapf(types.ErrNotReady, "the API server did not serve requests within %s of waking up the shoot, %v", timeout, err)
+	}
+	return err
+}
+
+// waitForHibernation polls the shoot until its status reports the given hibernation and its last operation succeeded.
+func waitForHibernation(ctx context.Context, client dynamic.Interface, namespace, name string, hibernated bool) error {
+	for {
+		shoot, err := client.Resource(shootResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil && ctx.Err() == nil {
+			return errors.Wrapf(err, "could not get the shoot %s", name)
+		}
+		if err == nil {
+			current, _, _ := unstructured.NestedBool(shoot.Object, "status", "hibernated")
+			state, _, _ := unstructured.NestedString(shoot.Object, "status", "lastOperation", "state")
+			if current == hibernated && state == "Succeeded" {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return errors.Wrapf(types.ErrTimeout, "shoot %s did not reach the hibernation state %t", name, hibernated)
+			}
+			return errors.Wrapf(ctx.Err(), "stopped waiting for the hibernation of shoot %s", name)
+		case <-time.After(hibernationPollInterval):
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package terraform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func testShoot(hibernated bool, state string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "core.gardener.cloud/v1beta1",
+		"kind":       "Shoot",
+		"metadata":   map[string]interface{}{"name": "hydro", "namespace": "garden-dev"},
+		"status": map[string]interface{}{
+			"hibernated":    hibernated,
+			"lastOperation": map[string]interface{}{"state": state},
+		},
+	}}
+}
+
+func TestWaitForHibernation(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme(), testShoot(true, "Succeeded"))
+	require.NoError(t, waitForHibernation(context.Background(), client, "garden-dev", "hydro", true))
+
+	// the shoot is still running
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := waitForHibernation(ctx, client, "garden-dev", "hydro", false)
+	require.True(t, errors.Is(err, types.ErrTimeout), "Waiting for a state the shoot does not reach should time out")
+
+	// the shoot reached the state but Gardener is still reconciling it
+	client = fake.NewSimpleDynamicClient(runtime.NewScheme(), testShoot(true, "Processing"))
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = waitForHibernation(ctx, client, "garden-dev", "hydro", true)
+	require.True(t, errors.Is(err, context.Canceled), "Cancelling should stop the wait")
+
+	err = waitForHibernation(context.Background(), client, "garden-dev", "missing", true)
+	require.Error(t, err, "A missing shoot should fail right away")
+}
+
+func TestHibernateUnsupported(t *testing.T) {
+	t.Parallel()
+	_, err := New().Hibernate(nil, types.GCP, map[string]interface{}{})
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation))
+	_, err = New().WakeUp(nil, types.Azure, map[string]interface{}{})
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation))
+}
+
+func TestExpandGardenerHibernation(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{"target_provider": "gcp"}
+	tf, err := expandGardenerClusterTemplate(cfg)
+	require.NoError(t, err)
+	require.NotContains(t, tf, "hibernation", "The hibernation should not be set if the configuration has none")
+
+	cfg["hibernated"] = false
+	tf, err = expandGardenerClusterTemplate(cfg)
+	require.NoError(t, err)
+	require.Contains(t, tf, "enabled = false", "Waking up should set the hibernation explicitly")
+}
+
+func TestWaitForWakeUp(t *testing.T) {
+	t.Parallel()
+	var ready int32
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&ready) == 0 {
+			http.Error(w, "connection refused", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer s.Close()
+	info := &types.ClusterInfo{Kubeconfig: testKubeconfig(s)}
+
+	err := waitForWakeUp(context.Background(), info, 50*time.Millisecond, time.Millisecond)
+	require.True(t, errors.Is(err, types.ErrNotReady), "An API server not serving within the timeout should not be ready")
+
+	atomic.StoreInt32(&ready, 1)
+	require.NoError(t, waitForWakeUp(context.Background(), info, time.Minute, time.Millisecond))
+}
@@ -0,0 +1,257 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/hashicorp/terraform/states/statemgr"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// clusterIdentity is what identifies the cluster resource of a state on its provider.
+// Empty fields are not known for the resource, they are not compared.
+type clusterIdentity struct {
+	Provider types.ProviderType `json:"provider"`
+	Project  string             `json:"project,omitempty"`
+	Name     string             `json:"name,omitempty"`
+	ID       string             `json:"id,omitempty"`
+}
+
+// identitiesDir is the dir of the data dir, or the prefix in the backend, with the identities recorded for the created clusters, see recordIdentity.
+// It is kept when the files of the clusters are removed, so the identities also guard the states the callers keep themselves.
+const identitiesDir = "identities"
+
+// identityProjects are the attributes of the cluster resources holding the project of the configuration, and the configuration key it comes from.
+var identityProjects = map[types.ProviderType]struct{ attr, key string }{
+	types.GCP:      {"project", "project"},
+	types.Gardener: {"namespace", "namespace"},
+}
+
+// checkIdentity returns an ErrIdentityMismatch if the cluster resource in the given state is not the cluster of the configuration:
+// it belongs to another provider, or its project, name or ID differ. The ID is compared with the identity recorded when the cluster was created,
+// if any, and with the "cluster_id" of the configuration, if set.
+// States without a cluster resource, such as the ones of custom templates with other resource names, are not checked.
+func checkIdentity(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, recorded *clusterIdentity) error {
+	id, err := stateIdentity(sf)
+	if err != nil || id == nil {
+		return err
+	}
+
+	var mismatches []string
+	compare := func(field, state string, want interface{}) {
+		if w, ok := want.(string); ok && state != "" && w != "" && state != w {
+			mismatches = append(mismatches, fmt.Sprintf("%s %q instead of %q", field, state, w))
+		}
+	}
+	compare("provider", string(id.Provider), string(p))
+	if id.Provider == p {
+		compare("project", id.Project, cfg[identityProjects[p].key])
+		compare("name", id.Name, cfg["cluster_name"])
+		compare("ID", id.ID, cfg["cluster_id"])
+		if recorded != nil && recorded.Provider == p {
+			compare("ID", id.ID, recorded.ID)
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return errors.Wrapf(types.ErrIdentityMismatch, "the state holds the cluster with %s", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// stateIdentity returns the identity of the cluster resource in the given state, or nil if there is none.
+func stateIdentity(sf *statefile.File) (*clusterIdentity, error) {
+	if sf == nil || sf.State == nil || sf.State.RootModule() == nil {
+		return nil, nil
+	}
+
+	for _, p := range []types.ProviderType{types.GCP, types.Azure, types.AWS, types.Gardener, types.Kind, types.OpenStack, types.DigitalOcean, types.AliCloud} {
+		for _, r := range sf.State.RootModule().Resources {
+			if r.Addr.Mode != addrs.ManagedResourceMode || r.Addr.String() != clusterResource(p) {
+				continue
+			}
+			i := r.Instance(addrs.NoKey)
+			if i == nil || i.Current == nil {
+				continue
+			}
+
+			attrs := make(map[string]interface{})
+			if err := json.Unmarshal(i.Current.AttrsJSON, &attrs); err != nil {
+				return nil, errors.Wrapf(err, "could not decode the attributes of %s", r.Addr)
+			}
+			// the shoots have their name and namespace in the metadata block of the Kubernetes resource
+			if md, _ := attrs["metadata"].([]interface{}); len(md) > 0 {
+				if m, ok := md[0].(map[string]interface{}); ok {
+					attrs["name"], attrs["namespace"] = m["name"], m["namespace"]
+				}
+			}
+
+			id := &clusterIdentity{Provider: p}
+			id.Name, _ = attrs["name"].(string)
+			id.ID, _ = attrs["id"].(string)
+			if project, ok := identityProjects[p]; ok {
+				id.Project, _ = attrs[project.attr].(string)
+			}
+			return id, nil
+		}
+	}
+	return nil, nil
+}
+
+// identityOutput is the output holding the identity of the cluster in the identity states of a backend, see identityToBackend.
+const identityOutput = "identity"
+
+// recordIdentity records the identity of the cluster resource in the given state for the cluster of the configuration, once it is created,
+// so the following operations refuse the states of other clusters for it, see checkIdentity. States without a cluster resource record nothing.
+// The identity is kept alongside the state of the cluster: in the configured backend, so all hosts using it check the same identity,
+// in the given store with the InMemoryState option, so nothing is written to disk, or in the data dir otherwise.
+func recordIdentity(ops Options, mem *stateStore, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
+	id, err := stateIdentity(sf)
+	if err != nil || id == nil {
+		return err
+	}
+	return storeIdentity(ops, mem, id, p, cfg)
+}
+
+// recordedIdentity returns the identity recorded for the cluster of the configuration, nil if none was recorded, see recordIdentity.
+func recordedIdentity(ops Options, mem *stateStore, p types.ProviderType, cfg map[string]interface{}) (*clusterIdentity, error) {
+	project, cluster := stringValue(cfg["project"]), stringValue(cfg["cluster_name"])
+	switch {
+	case ops.Backend != nil:
+		return identityFromBackend(ops, *ops.Backend, project, cluster, p)
+	case ops.InMemoryState:
+		return mem.identity(ops, project, cluster, p)
+	}
+
+	path, err := identityFile(ops, p, cfg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the identity of the cluster")
+	}
+	id := &clusterIdentity{}
+	if err := json.Unmarshal(data, id); err != nil {
+		return nil, errors.Wrapf(err, "could not decode the identity of the cluster in %s", path)
+	}
+	return id, nil
+}
+
+// forgetIdentity removes the identity recorded for the cluster of the configuration, once it is deleted.
+func forgetIdentity(ops Options, mem *stateStore, p types.ProviderType, cfg map[string]interface{}) error {
+	return storeIdentity(ops, mem, nil, p, cfg)
+}
+
+// storeIdentity keeps the given identity for the cluster of the configuration where recordIdentity records it. A nil identity removes the recorded one.
+func storeIdentity(ops Options, mem *stateStore, id *clusterIdentity, p types.ProviderType, cfg map[string]interface{}) error {
+	project, cluster := stringValue(cfg["project"]), stringValue(cfg["cluster_name"])
+	switch {
+	case ops.Backend != nil:
+		return identityToBackend(ops, *ops.Backend, id, project, cluster, p)
+	case ops.InMemoryState:
+		return mem.setIdentity(ops, id, project, cluster, p)
+	}
+
+	path, err := identityFile(ops, p, cfg)
+	if err != nil {
+		return err
+	}
+	if id == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "could not remove the identity of the cluster")
+		}
+		return nil
+	}
+	data, err := json.Marshal(id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "could not create the identities dir")
+	}
+	// the file is replaced at once, so readers never see a partially written identity
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "could not record the identity of the cluster")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "could not record the identity of the cluster")
+}
+
+// identityFile returns the file of the identity recorded for the cluster of the configuration in the data dir of the options.
+func identityFile(ops Options, p types.ProviderType, cfg map[string]interface{}) (string, error) {
+	dir, err := clusterDir(ops, stringValue(cfg["project"]), stringValue(cfg["cluster_name"]), p)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(dir))
+	return filepath.Join(ops.DataDir(), identitiesDir, hex.EncodeToString(sum[:16])+".json"), nil
+}
+
+// identityBackend returns the backend holding the identities of the clusters: the given one with the identities dir appended to its prefix,
+// so the identity of each cluster is a state of its own next to the state of the cluster.
+func identityBackend(b types.BackendConfig) types.BackendConfig {
+	b.Prefix = path.Join(b.Prefix, identitiesDir)
+	return b
+}
+
+// identityFromBackend returns the identity recorded for the given cluster in the given backend, nil if none was recorded.
+func identityFromBackend(ops Options, b types.BackendConfig, project, cluster string, p types.ProviderType) (*clusterIdentity, error) {
+	sf, err := stateFromBackend(ops, identityBackend(b), project, cluster, p)
+	if errors.Is(err, types.ErrStateNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the identity of the cluster")
+	}
+	out := sf.State.RootModule().OutputValues[identityOutput]
+	if out == nil || out.Value.IsNull() || !out.Value.Type().Equals(cty.String) {
+		return nil, nil
+	}
+	id := &clusterIdentity{}
+	if err := json.Unmarshal([]byte(out.Value.AsString()), id); err != nil {
+		return nil, errors.Wrapf(err, "could not decode the identity of the cluster in the %s backend", b.Type)
+	}
+	return id, nil
+}
+
+// identityToBackend records the given identity for the given cluster in the given backend, as the output of a state without resources.
+// A nil identity removes the recorded one.
+func identityToBackend(ops Options, b types.BackendConfig, id *clusterIdentity, project, cluster string, p types.ProviderType) error {
+	ib := identityBackend(b)
+	lineage, serial := statemgr.NewLineage(), uint64(1)
+	sf, err := stateFromBackend(ops, ib, project, cluster, p)
+	switch {
+	case err == nil:
+		lineage, serial = sf.Lineage, sf.Serial+1
+	case !errors.Is(err, types.ErrStateNotFound):
+		return errors.Wrap(err, "could not read the identity of the cluster")
+	case id == nil:
+		return nil
+	}
+
+	state := states.NewState()
+	if id != nil {
+		data, err := json.Marshal(id)
+		if err != nil {
+			return err
+		}
+		state.RootModule().SetOutputValue(identityOutput, cty.StringVal(string(data)), false)
+	}
+	return errors.Wrap(stateToBackend(ops, statefile.New(state, lineage, serial), ib, project, cluster, p), "could not record the identity of the cluster")
+}
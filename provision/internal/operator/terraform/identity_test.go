@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// clusterState returns a state with the given cluster resource and attributes.
+func clusterState(typ, name, provider, attrs string) *statefile.File {
+	state := states.NewState()
+	state.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: typ, Name: name}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(attrs)},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider(provider)}.Absolute(addrs.RootModuleInstance),
+	)
+	return statefile.New(state, "", 0)
+}
+
+func TestCheckIdentity(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "namespace": "garden-my-project"}
+	gke := clusterState("google_container_cluster", "gke_cluster", "google", `{"id": "projects/my-project/locations/europe-west3-a/clusters/my-cluster", "name": "my-cluster", "project": "my-project"}`)
+
+	require.NoError(t, checkIdentity(gke, types.GCP, cfg, nil))
+	require.NoError(t, checkIdentity(nil, types.GCP, cfg, nil), "Without state there is nothing to compare")
+	require.NoError(t, checkIdentity(statefile.New(states.NewState(), "", 0), types.GCP, cfg, nil))
+	require.NoError(t, checkIdentity(clusterState("null_resource", "custom", "null", `{"id": "1"}`), types.GCP, cfg, nil), "Custom templates without cluster resource should not be checked")
+
+	err := checkIdentity(gke, types.GCP, map[string]interface{}{"project": "production", "cluster_name": "my-cluster"}, nil)
+	require.True(t, errors.Is(err, types.ErrIdentityMismatch))
+	require.Contains(t, err.Error(), `project "my-project" instead of "production"`)
+
+	err = checkIdentity(gke, types.GCP, map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "cluster_id": "projects/my-project/locations/us-east1/clusters/my-cluster"}, nil)
+	require.True(t, errors.Is(err, types.ErrIdentityMismatch), "The recorded cluster ID should be compared")
+
+	err = checkIdentity(gke, types.Azure, cfg, nil)
+	require.True(t, errors.Is(err, types.ErrIdentityMismatch))
+	require.Contains(t, err.Error(), `provider "gcp" instead of "azure"`)
+
+	shoot := clusterState("gardener_shoot", "gardener_cluster", "gardener", `{"id": "garden-my-project/my-cluster", "metadata": [{"name": "my-cluster", "namespace": "garden-my-project"}]}`)
+	require.NoError(t, checkIdentity(shoot, types.Gardener, cfg, nil))
+	err = checkIdentity(shoot, types.Gardener, map[string]interface{}{"project": "other", "cluster_name": "my-cluster", "namespace": "garden-other"}, nil)
+	require.True(t, errors.Is(err, types.ErrIdentityMismatch), "The namespace of the shoot should be compared")
+
+	// the cluster of the configuration was recreated, the state of the previous one is not its state anymore
+	recorded := &clusterIdentity{Provider: types.GCP, Project: "my-project", Name: "my-cluster", ID: "projects/my-project/locations/us-east1/clusters/my-cluster"}
+	err = checkIdentity(gke, types.GCP, cfg, recorded)
+	require.True(t, errors.Is(err, types.ErrIdentityMismatch), "The ID recorded at the creation should be compared")
+}
+
+func TestRecordIdentity(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-identity")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ops := options(WithDataDir(dir))
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+
+	recorded, err := recordedIdentity(ops, nil, types.GCP, cfg)
+	require.NoError(t, err)
+	require.Nil(t, recorded, "Clusters that were not created should have no identity")
+
+	gke := clusterState("google_container_cluster", "gke_cluster", "google", `{"id": "projects/my-project/locations/europe-west3-a/clusters/my-cluster", "name": "my-cluster", "project": "my-project"}`)
+	require.NoError(t, recordIdentity(ops, nil, gke, types.GCP, cfg))
+	recorded, err = recordedIdentity(ops, nil, types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, &clusterIdentity{Provider: types.GCP, Project: "my-project", Name: "my-cluster", ID: "projects/my-project/locations/europe-west3-a/clusters/my-cluster"}, recorded)
+	other, err := recordedIdentity(ops, nil, types.GCP, map[string]interface{}{"project": "my-project", "cluster_name": "other"})
+	require.NoError(t, err)
+	require.Nil(t, other, "Each cluster should have its own identity")
+
+	require.NoError(t, cleanup(ops, "my-project", "my-cluster", types.GCP))
+	recorded, err = recordedIdentity(ops, nil, types.GCP, cfg)
+	require.NoError(t, err)
+	require.NotNil(t, recorded, "The identity should be kept with the files of the cluster removed")
+
+	require.NoError(t, forgetIdentity(ops, nil, types.GCP, cfg))
+	recorded, err = recordedIdentity(ops, nil, types.GCP, cfg)
+	require.NoError(t, err)
+	require.Nil(t, recorded)
+	require.NoError(t, forgetIdentity(ops, nil, types.GCP, cfg), "Forgetting twice should not fail")
+}
+
+func TestDeleteIdentityMismatch(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-identity")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte("variable \"project\" {}\nvariable \"cluster_name\" {}\n")}}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	production := clusterState("kind", "kind-cluster", "kind", `{"id": "production", "name": "production"}`)
+
+	tf := New(WithDataDir(dir), Persistent(), WithTemplate(types.Kind, tmpl))
+	err = tf.Delete(production, types.Kind, cfg)
+	require.True(t, errors.Is(err, types.ErrIdentityMismatch), "The state of another cluster should not be destroyed")
+	_, err = stateFromFile(tf.ops, "my-project", "my-cluster", types.Kind)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "The state of another cluster should not be stored for the configured one")
+
+	err = New(WithDataDir(dir), Persistent(), WithTemplate(types.Kind, tmpl), WithAllowIdentityMismatch()).Delete(production, types.Kind, cfg)
+	require.False(t, errors.Is(err, types.ErrIdentityMismatch), "The override should skip the identity check")
+}
+
+func TestRecordIdentityInMemory(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-identity")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ops, mem := options(WithDataDir(dir), WithInMemoryState()), newStateStore()
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+
+	gke := clusterState("google_container_cluster", "gke_cluster", "google", `{"id": "projects/my-project/locations/europe-west3-a/clusters/my-cluster", "name": "my-cluster", "project": "my-project"}`)
+	require.NoError(t, recordIdentity(ops, mem, gke, types.GCP, cfg))
+	recorded, err := recordedIdentity(ops, mem, types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, &clusterIdentity{Provider: types.GCP, Project: "my-project", Name: "my-cluster", ID: "projects/my-project/locations/europe-west3-a/clusters/my-cluster"}, recorded)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files, "Nothing should be written to disk with in-memory states")
+
+	require.NoError(t, mem.remove(ops, "my-project", "my-cluster", types.GCP))
+	recorded, err = recordedIdentity(ops, mem, types.GCP, cfg)
+	require.NoError(t, err)
+	require.NotNil(t, recorded, "The identity should be kept with the state removed")
+
+	require.NoError(t, forgetIdentity(ops, mem, types.GCP, cfg))
+	recorded, err = recordedIdentity(ops, mem, types.GCP, cfg)
+	require.NoError(t, err)
+	require.Nil(t, recorded)
+}
+
+func TestIdentityBackend(t *testing.T) {
+	t.Parallel()
+	b := types.BackendConfig{Type: "gcs", Bucket: "my-bucket", Prefix: "hydroform"}
+	state, err := backendAttributes(b, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	identity, err := backendAttributes(identityBackend(b), "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.Equal(t, "hydroform/gcp/my-project/my-cluster", state["prefix"])
+	require.Equal(t, "hydroform/identities/gcp/my-project/my-cluster", identity["prefix"], "The identity should be stored next to the state in the same bucket")
+	require.Equal(t, "hydroform", b.Prefix, "The given backend should not change")
+}
@@ -0,0 +1,37 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// checkInstallation verifies that terraform can install the provider plugins before the first command. Terraform is embedded,
+// but it installs the plugins into the data dir and the plugin cache: with a read-only dir it would only fail with a low-level error of init.
+// Plugins that cannot be downloaded are reported by init, they are classified as ErrTerraformNotFound too.
+func checkInstallation(ops Options) error {
+	for _, dir := range []string{ops.DataDir(), ops.Meta.PluginCacheDir} {
+		if dir == "" {
+			continue
+		}
+		if err := writableDir(dir); err != nil {
+			return errors.Wrapf(types.ErrTerraformNotFound, "terraform cannot install the provider plugins into %s, choose a writable directory with the DataDir and PluginCacheDir options: %s", dir, err)
+		}
+	}
+	return nil
+}
+
+// writableDir creates the given dir if needed and checks that files can be created in it.
+func writableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".hydroform-check")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,34 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/command"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckInstallation(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-install")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ops := Options{Meta: command.Meta{OverrideDataDir: filepath.Join(dir, "data"), PluginCacheDir: filepath.Join(dir, "plugins")}}
+	require.NoError(t, checkInstallation(ops))
+	require.DirExists(t, filepath.Join(dir, "plugins"), "The plugin cache should be created")
+	files, err := ioutil.ReadDir(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+	require.Empty(t, files, "The check should not leave files behind")
+
+	// a file where the plugin cache should be
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0600))
+	ops.Meta.PluginCacheDir = filepath.Join(dir, "file")
+	err = checkInstallation(ops)
+	require.True(t, errors.Is(err, types.ErrTerraformNotFound))
+	require.Contains(t, err.Error(), "PluginCacheDir")
+}
@@ -0,0 +1,79 @@
+package terraform
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// Resources lists the resource instances Hydroform manages for the cluster, sorted by address, such as to find resources left behind in the provider.
+// It only reads the persisted state of the cluster and does not contact the provider, so the resources removed outside of terraform are still listed
+// until the next Refresh. Data sources are not listed. It fails with ErrStateNotFound if the cluster has no state.
+func (t *Terraform) Resources(p types.ProviderType, cfg map[string]interface{}) ([]types.ManagedResource, error) {
+	project, ok := cfg["project"].(string)
+	if !ok || project == "" {
+		return nil, errors.New("the project is needed to list the cluster resources")
+	}
+	cluster, ok := cfg["cluster_name"].(string)
+	if !ok || cluster == "" {
+		return nil, errors.New("the cluster_name is needed to list the cluster resources")
+	}
+
+	sf, err := t.lockedState(project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+	return managedResources(sf)
+}
+
+// managedResources returns the managed resource instances of the given state, sorted by address.
+func managedResources(sf *statefile.File) ([]types.ManagedResource, error) {
+	resources := []types.ManagedResource{}
+	if sf == nil || sf.State == nil {
+		return resources, nil
+	}
+	for _, m := range sf.State.Modules {
+		for _, rs := range m.Resources {
+			if rs.Addr.Mode != addrs.ManagedResourceMode {
+				continue
+			}
+			for key, is := range rs.Instances {
+				if is.Current == nil {
+					continue
+				}
+				id, err := resourceID(is.Current)
+				if err != nil {
+					return nil, errors.Wrapf(err, "could not decode the attributes of %s", rs.Addr.Instance(key).Absolute(m.Addr))
+				}
+				resources = append(resources, types.ManagedResource{
+					Address:  rs.Addr.Instance(key).Absolute(m.Addr).String(),
+					Type:     rs.Addr.Type,
+					Provider: rs.ProviderConfig.ProviderConfig.Type.Type,
+					ID:       id,
+					Tainted:  is.Current.Status == states.ObjectTainted,
+				})
+			}
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Address < resources[j].Address })
+	return resources, nil
+}
+
+// resourceID returns the id attribute of the given resource instance object, empty if it has none.
+func resourceID(obj *states.ResourceInstanceObjectSrc) (string, error) {
+	var attrs struct {
+		ID string `json:"id"`
+	}
+	if len(obj.AttrsJSON) == 0 {
+		return "", nil
+	}
+	if err := json.Unmarshal(obj.AttrsJSON, &attrs); err != nil {
+		return "", err
+	}
+	return attrs.ID, nil
+}
@@ -0,0 +1,35 @@
+package terraform
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResources(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-inventory")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tf := New(WithDataDir(dir))
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	_, err = tf.Resources(types.GCP, cfg)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "%v", err)
+
+	require.NoError(t, stateToFile(gkeNodePoolState(), tf.ops, "my-project", "my-cluster", types.GCP))
+	resources, err := tf.Resources(types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, []types.ManagedResource{
+		{Address: "google_compute_firewall.pool1", Type: "google_compute_firewall", Provider: "google", ID: "firewall"},
+		{Address: "google_container_cluster.gke_cluster", Type: "google_container_cluster", Provider: "google", ID: "cluster"},
+		{Address: "google_container_node_pool.pool1", Type: "google_container_node_pool", Provider: "google", ID: "pool1"},
+	}, resources)
+
+	_, err = tf.Resources(types.GCP, map[string]interface{}{"project": "my-project"})
+	require.Error(t, err, "The cluster name is needed to find the state")
+}
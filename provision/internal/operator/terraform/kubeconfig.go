@@ -0,0 +1,354 @@
+package terraform
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// gcpTokenScope is the OAuth2 scope of the tokens minted to access GKE clusters.
+	gcpTokenScope = "https://www.googleapis.com/auth/cloud-platform"
+	// gardenerMinExpiration is the shortest expiration Gardener accepts for the credentials of admin kubeconfigs.
+	gardenerMinExpiration = 10 * time.Minute
+)
+
+// Kubeconfig returns a kubeconfig to access the cluster of the given state, with credentials generated on each call,
+// so that long-running callers can keep accessing the cluster by calling it again when the credentials expire.
+// If the state is nil, it is loaded from the file system. The lifetime of the credentials depends on the provider:
+// - GCP: an OAuth2 access token of the service account in the credentials file is embedded, it is valid for one hour.
+// - Gardener: the kubeconfig is read again from the shoot secret, its token is valid until Gardener rotates it.
+// - AWS: kubectl runs "aws eks get-token" for tokens valid for 15 minutes, the kubeconfig itself does not expire.
+// - Others: the kubeconfig is read from the state, its certificates or tokens are valid as long as the provider issued them for.
+func (t *Terraform) Kubeconfig(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (string, error) {
+	return t.KubeconfigWithContext(context.Background(), sf, p, cfg)
+}
+
+// KubeconfigWithContext works as Kubeconfig but stops fetching the credentials when the given context is done.
+func (t *Terraform) KubeconfigWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (_ string, err error) {
+	cfg, removeCredentials, err := t.credentials(ctx, p, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer t.removeFiles(&err, removeCredentials)
+
+	if err := t.preflight(p, cfg); err != nil {
+		return "", err
+	}
+
+	// if no state given, try the file system
+	if sf == nil {
+		sf, err = loadState(t.ops, t.states, cfg["project"].(string), cfg["cluster_name"].(string), p)
+		if err != nil {
+			return "", errors.Wrap(err, "no state provided, attempted to load from file")
+		}
+	}
+	if !sf.State.HasResources() {
+		return "", errors.Wrap(types.ErrStateNotFound, "the state has no cluster")
+	}
+
+	info, err := clusterInfoFromState(sf)
+	if err != nil {
+		return "", err
+	}
+	if p != types.GCP {
+		return kubeconfig(ctx, sf, p, cfg, info)
+	}
+
+	if info.Endpoint == "" {
+		return "", errors.New("the state has no endpoint of the cluster")
+	}
+	token, err := gcpToken(ctx, cfg["credentials_file_path"].(string))
+	if err != nil {
+		return "", errors.Wrap(err, "could not get a token for the cluster")
+	}
+	return gcpTokenKubeconfig(kubeconfigContextName(p, cfg), info.Endpoint, info.CertificateAuthorityData, token)
+}
+
+// kubeconfig returns the kubeconfig to access the cluster described by the given state and ClusterInfo.
+// Each provider exposes the kubeconfig differently:
+// - GCP: it is assembled from the endpoint and CA of the cluster, using the gcp auth provider.
+// - Azure: it is read from the kube_config output of the module.
+// - Gardener: it is read from the kubeconfig secret of the shoot in the garden project namespace.
+// - Others: it is read from the kubeconfig output, if any.
+// Its current context is renamed after kubeconfigContextName.
+func kubeconfig(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, info *types.ClusterInfo) (string, error) {
+	name := kubeconfigContextName(p, cfg)
+	switch p {
+	case types.GCP:
+		if info.Endpoint == "" {
+			return "", nil
+		}
+		return gcpKubeconfig(name, info.Endpoint, info.CertificateAuthorityData)
+	case types.Azure:
+		return renameKubeconfig(stateOutput(sf, "kube_config"), name)
+	case types.Gardener:
+		kubeconfig, err := gardenerKubeconfig(ctx, cfg)
+		if err != nil {
+			return "", err
+		}
+		return renameKubeconfig(kubeconfig, name)
+	default:
+		return renameKubeconfig(info.Kubeconfig, name)
+	}
+}
+
+// kubeconfigContextName returns the name of the context, the cluster and the user of the kubeconfigs returned for the cluster:
+// the kubeconfig_context_name of the configuration, or <project>-<cluster_name>-<provider>, so the kubeconfigs of a fleet do not collide when merged.
+func kubeconfigContextName(p types.ProviderType, cfg map[string]interface{}) string {
+	if name, ok := cfg["kubeconfig_context_name"].(string); ok && name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s-%s-%s", cfg["project"], cfg["cluster_name"], p)
+}
+
+// renameKubeconfig renames the current context of the given kubeconfig, with its cluster and its user, to the given name.
+// The cluster and the user are kept under their previous names as well if other contexts refer to them.
+// Kubeconfigs that cannot be decoded or have no current context are returned as they are.
+func renameKubeconfig(kubeconfig, name string) (string, error) {
+	if kubeconfig == "" {
+		return "", nil
+	}
+	// the versioned config keeps the order and the fields of the entries as the provider wrote them
+	var config clientcmdv1.Config
+	if err := yaml.Unmarshal([]byte(kubeconfig), &config); err != nil {
+		// the outputs of custom templates are returned as the template wrote them
+		return kubeconfig, nil
+	}
+	current := -1
+	for i, c := range config.Contexts {
+		if c.Name == config.CurrentContext {
+			current = i
+		}
+	}
+	if current < 0 {
+		return kubeconfig, nil
+	}
+
+	ctx := &config.Contexts[current].Context
+	used := func(cluster, user string) bool {
+		for i, c := range config.Contexts {
+			if i != current && ((cluster != "" && c.Context.Cluster == cluster) || (user != "" && c.Context.AuthInfo == user)) {
+				return true
+			}
+		}
+		return false
+	}
+	for i, c := range config.Clusters {
+		if c.Name != ctx.Cluster {
+			continue
+		}
+		if used(c.Name, "") {
+			config.Clusters = append(config.Clusters, clientcmdv1.NamedCluster{Name: name, Cluster: c.Cluster})
+		} else {
+			config.Clusters[i].Name = name
+		}
+		ctx.Cluster = name
+		break
+	}
+	for i, u := range config.AuthInfos {
+		if u.Name != ctx.AuthInfo {
+			continue
+		}
+		if used("", u.Name) {
+			config.AuthInfos = append(config.AuthInfos, clientcmdv1.NamedAuthInfo{Name: name, AuthInfo: u.AuthInfo})
+		} else {
+			config.AuthInfos[i].Name = name
+		}
+		ctx.AuthInfo = name
+		break
+	}
+	config.Contexts[current].Name = name
+	config.CurrentContext = name
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", errors.Wrap(err, "could not encode the kubeconfig")
+	}
+	return string(data), nil
+}
+
+// gcpKubeconfig generates a kubeconfig for a GKE cluster that authenticates with the gcloud credentials of the user, its context, cluster and user get the given name.
+func gcpKubeconfig(cluster, endpoint string, ca []byte) (string, error) {
+	userName := cluster
+	config := api.NewConfig()
+
+	config.Clusters[cluster] = &api.Cluster{
+		Server:                   fmt.Sprintf("https://%v", endpoint),
+		CertificateAuthorityData: ca,
+	}
+
+	config.Contexts[cluster] = &api.Context{
+		Cluster:  cluster,
+		AuthInfo: userName,
+	}
+
+	config.CurrentContext = cluster
+
+	config.AuthInfos[userName] = &api.AuthInfo{
+		AuthProvider: &api.AuthProviderConfig{
+			Name: "gcp",
+		},
+	}
+
+	data, err := clientcmd.Write(*config)
+	return string(data), err
+}
+
+// gcpToken mints an OAuth2 access token with the GCP service account key in the given file.
+func gcpToken(ctx context.Context, credentialsFile string) (*oauth2.Token, error) {
+	data, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := google.CredentialsFromJSON(ctx, data, gcpTokenScope)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load the service account key")
+	}
+	return creds.TokenSource.Token()
+}
+
+// gcpTokenKubeconfig generates a kubeconfig for a GKE cluster with the given access token, for callers without gcloud.
+func gcpTokenKubeconfig(cluster, endpoint string, ca []byte, token *oauth2.Token) (string, error) {
+	userName := cluster
+	config := api.NewConfig()
+
+	config.Clusters[cluster] = &api.Cluster{
+		Server:                   fmt.Sprintf("https://%v", endpoint),
+		CertificateAuthorityData: ca,
+	}
+
+	config.Contexts[cluster] = &api.Context{
+		Cluster:  cluster,
+		AuthInfo: userName,
+	}
+
+	config.CurrentContext = cluster
+
+	config.AuthInfos[userName] = &api.AuthInfo{
+		Token: token.AccessToken,
+	}
+
+	data, err := clientcmd.Write(*config)
+	return string(data), err
+}
+
+// gardenerKubeconfig reads the kubeconfig of a shoot from its secret in the garden project namespace.
+// Gardener rotates the token in this kubeconfig, so it should be fetched again when it expires.
+func gardenerKubeconfig(ctx context.Context, cfg map[string]interface{}) (string, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", cfg["credentials_file_path"].(string))
+	if err != nil {
+		return "", errors.Wrap(err, "could not load the garden kubeconfig")
+	}
+
+	k8s, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := k8s.CoreV1().Secrets(cfg["namespace"].(string)).Get(ctx, fmt.Sprintf("%s.kubeconfig", cfg["cluster_name"]), metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return string(s.Data["kubeconfig"]), nil
+}
+
+// GardenerKubeconfig requests a kubeconfig of the Gardener shoot of the given configuration with the adminkubeconfig subresource of the shoot.
+// Its credentials are minted for this call and expire after the given duration, which must be at least 10 minutes,
+// unlike the kubeconfig in the shoot secret whose token is valid until Gardener rotates it.
+// It fails with ErrAuthFailed if the garden credentials are not allowed to create admin kubeconfigs of the shoots in the project namespace.
+func (t *Terraform) GardenerKubeconfig(cfg map[string]interface{}, expiration time.Duration) (string, error) {
+	return t.GardenerKubeconfigWithContext(context.Background(), cfg, expiration)
+}
+
+// GardenerKubeconfigWithContext works as GardenerKubeconfig but stops the request to the Gardener API when the given context is done.
+func (t *Terraform) GardenerKubeconfigWithContext(ctx context.Context, cfg map[string]interface{}, expiration time.Duration) (_ string, err error) {
+	cfg, removeCredentials, err := t.credentials(ctx, types.Gardener, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer t.removeFiles(&err, removeCredentials)
+
+	if err := t.preflight(types.Gardener, cfg); err != nil {
+		return "", err
+	}
+	if expiration < gardenerMinExpiration {
+		return "", errors.Errorf("the expiration of the kubeconfig must be at least %s", gardenerMinExpiration)
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", cfg["credentials_file_path"].(string))
+	if err != nil {
+		return "", errors.Wrap(err, "could not load the garden kubeconfig")
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	return adminKubeconfig(ctx, client, cfg["namespace"].(string), cfg["cluster_name"].(string), expiration)
+}
+
+// adminKubeconfig creates an AdminKubeconfigRequest for the given shoot and returns the kubeconfig Gardener issued.
+func adminKubeconfig(ctx context.Context, client dynamic.Interface, namespace, shoot string, expiration time.Duration) (string, error) {
+	req := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "authentication.gardener.cloud/v1alpha1",
+		"kind":       "AdminKubeconfigRequest",
+		// the name of the request is the shoot it is created for
+		"metadata": map[string]interface{}{
+			"name":      shoot,
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"expirationSeconds": int64(expiration.Seconds()),
+		},
+	}}
+
+	resp, err := client.Resource(shootResource).Namespace(namespace).Create(ctx, req, metav1.CreateOptions{}, "adminkubeconfig")
+	switch {
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return "", errors.Wrapf(types.ErrAuthFailed, "the garden credentials are not allowed to create the adminkubeconfig of shoot %s in namespace %s: %s", shoot, namespace, err)
+	case apierrors.IsNotFound(err):
+		return "", errors.Wrapf(err, "shoot %s not found in namespace %s, or the Gardener API has no adminkubeconfig subresource", shoot, namespace)
+	case err != nil:
+		return "", errors.Wrapf(err, "could not request the adminkubeconfig of shoot %s", shoot)
+	}
+
+	// the kubeconfig is returned as bytes, which are base64 encoded in JSON
+	encoded, _, err := unstructured.NestedString(resp.Object, "status", "kubeconfig")
+	if err != nil || encoded == "" {
+		return "", errors.Errorf("Gardener returned no kubeconfig for shoot %s", shoot)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.Wrap(err, "could not decode the kubeconfig returned by Gardener")
+	}
+	return string(data), nil
+}
+
+// stateOutput returns the string value of an output of the root module in the given state, or empty if there is none.
+func stateOutput(sf *statefile.File, name string) string {
+	if sf == nil || sf.State == nil || sf.State.Modules[""] == nil {
+		return ""
+	}
+	if val, ok := sf.State.Modules[""].OutputValues[name]; ok && val.Value.IsKnown() && !val.Value.IsNull() {
+		return val.Value.AsString()
+	}
+	return ""
+}
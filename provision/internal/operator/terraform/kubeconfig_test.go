@@ -0,0 +1,274 @@
+package terraform
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const azureKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: my-cluster
+  cluster:
+    server: https://my-cluster.hcp.westeurope.azmk8s.io:443
+users:
+- name: clusterUser_my-project_my-cluster
+  user:
+    token: secret
+contexts:
+- name: my-cluster
+  context:
+    cluster: my-cluster
+    user: clusterUser_my-project_my-cluster
+current-context: my-cluster
+`
+
+func TestKubeconfig(t *testing.T) {
+	t.Parallel()
+	state := states.NewState()
+	state.RootModule().SetOutputValue("kube_config", cty.StringVal(azureKubeconfig), false)
+	state.RootModule().SetOutputValue("kubeconfig", cty.StringVal("aws-kubeconfig"), false)
+	sf := statefile.New(state, "", 0)
+
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	info := &types.ClusterInfo{
+		Endpoint:                 "1.2.3.4",
+		CertificateAuthorityData: []byte("ca"),
+		Kubeconfig:               stateOutput(sf, "kubeconfig"),
+	}
+
+	// GCP
+	kc, err := kubeconfig(context.Background(), sf, types.GCP, cfg, &types.ClusterInfo{})
+	require.NoError(t, err)
+	require.Empty(t, kc, "GCP clusters without endpoint should have no kubeconfig")
+
+	// Azure
+	kc, err = kubeconfig(context.Background(), sf, types.Azure, cfg, info)
+	require.NoError(t, err)
+	config, err := clientcmd.Load([]byte(kc))
+	require.NoError(t, err)
+	require.Equal(t, "my-project-my-cluster-azure", config.CurrentContext)
+	require.Len(t, config.Contexts, 1)
+	require.Equal(t, "https://my-cluster.hcp.westeurope.azmk8s.io:443", config.Clusters["my-project-my-cluster-azure"].Server)
+	require.Equal(t, "secret", config.AuthInfos["my-project-my-cluster-azure"].Token)
+	require.Len(t, config.AuthInfos, 1, "The user should be renamed")
+
+	named := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "kubeconfig_context_name": "ci-42"}
+	kc, err = kubeconfig(context.Background(), sf, types.Azure, named, info)
+	require.NoError(t, err)
+	config, err = clientcmd.Load([]byte(kc))
+	require.NoError(t, err)
+	require.Equal(t, "ci-42", config.CurrentContext, "The configured context name should be used")
+
+	// AWS
+	kc, err = kubeconfig(context.Background(), sf, types.AWS, cfg, info)
+	require.NoError(t, err)
+	require.Equal(t, "aws-kubeconfig", kc, "Kubeconfigs that cannot be decoded should be returned as they are")
+}
+
+func TestRenameKubeconfig(t *testing.T) {
+	t.Parallel()
+	shared := `apiVersion: v1
+kind: Config
+clusters:
+- name: my-cluster
+  cluster:
+    server: https://my-cluster.example.com
+users:
+- name: my-user
+  user:
+    token: secret
+- name: admin
+  user:
+    token: admin-secret
+contexts:
+- name: my-cluster
+  context:
+    cluster: my-cluster
+    user: my-user
+- name: admin
+  context:
+    cluster: my-cluster
+    user: admin
+current-context: my-cluster
+`
+	kc, err := renameKubeconfig(shared, "fleet-1")
+	require.NoError(t, err)
+	renamed, err := clientcmd.Load([]byte(kc))
+	require.NoError(t, err)
+	require.Equal(t, "fleet-1", renamed.CurrentContext)
+	require.Contains(t, renamed.Clusters, "my-cluster", "The cluster of other contexts should be kept")
+	require.Contains(t, renamed.Clusters, "fleet-1")
+	require.NotContains(t, renamed.AuthInfos, "my-user", "The user of no other context should be renamed")
+	require.Equal(t, "my-cluster", renamed.Contexts["admin"].Cluster)
+
+	kc, err = renameKubeconfig("", "fleet-1")
+	require.NoError(t, err)
+	require.Empty(t, kc)
+}
+
+func TestStateOutput(t *testing.T) {
+	t.Parallel()
+	state := states.NewState()
+	state.RootModule().SetOutputValue("endpoint", cty.StringVal("1.2.3.4"), false)
+	state.RootModule().SetOutputValue("unknown", cty.UnknownVal(cty.String), false)
+	state.EnsureModule(addrs.RootModuleInstance.Child("child", addrs.NoKey)).SetOutputValue("kube_config", cty.StringVal("child"), false)
+	sf := statefile.New(state, "", 0)
+
+	require.Equal(t, "1.2.3.4", stateOutput(sf, "endpoint"))
+	require.Empty(t, stateOutput(sf, "unknown"), "Unknown outputs should be empty")
+	require.Empty(t, stateOutput(sf, "kube_config"), "Outputs of child modules should be ignored")
+	require.Empty(t, stateOutput(nil, "endpoint"), "A missing state should have no outputs")
+}
+
+func TestGCPToken(t *testing.T) {
+	t.Parallel()
+	oauth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.NotEmpty(t, r.Form.Get("assertion"), "The token should be requested with a signed assertion")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "fresh-token", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer oauth.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	sa, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "hydroform@my-project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"token_uri":    oauth.URL,
+	})
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "hf-gcp-token")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sa.json")
+	require.NoError(t, ioutil.WriteFile(path, sa, 0600))
+
+	token, err := gcpToken(context.Background(), path)
+	require.NoError(t, err)
+	require.Equal(t, "fresh-token", token.AccessToken)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a key"), 0600))
+	_, err = gcpToken(context.Background(), path)
+	require.Error(t, err, "An invalid service account key should fail")
+}
+
+func TestTerraformKubeconfig(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-kubeconfig-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tf := New(WithDataDir(dir))
+	cfg := map[string]interface{}{
+		"project":            "my-project",
+		"cluster_name":       "hydro",
+		"resource_group":     "my-group",
+		"location":           "westeurope",
+		"agent_count":        3,
+		"agent_vm_size":      "Standard_D2_v3",
+		"agent_disk_size":    50,
+		"kubernetes_version": "1.16",
+	}
+
+	_, err = tf.Kubeconfig(statefile.New(states.NewState(), "", 0), types.Azure, cfg)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "A state without cluster should have no kubeconfig")
+
+	state := states.NewState()
+	state.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "azurerm_kubernetes_cluster", Name: "azure_cluster"}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"name": "hydro"}`)},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("azurerm")}.Absolute(addrs.RootModuleInstance),
+	)
+	state.RootModule().SetOutputValue("kube_config", cty.StringVal("azure-kubeconfig"), true)
+	kc, err := tf.Kubeconfig(statefile.New(state, "", 0), types.Azure, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "azure-kubeconfig", kc)
+}
+
+func TestGardenerKubeconfig(t *testing.T) {
+	t.Parallel()
+	var forbidden int32
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/apis/core.gardener.cloud/v1beta1/namespaces/garden-my-project/shoots/my-cluster/adminkubeconfig", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.LoadInt32(&forbidden) == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"apiVersion": "v1", "kind": "Status", "status": "Failure", "reason": "Forbidden", "code": http.StatusForbidden})
+			return
+		}
+
+		var req struct {
+			Kind string `json:"kind"`
+			Spec struct {
+				ExpirationSeconds int64 `json:"expirationSeconds"`
+			} `json:"spec"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "AdminKubeconfigRequest", req.Kind)
+		require.Equal(t, int64(1800), req.Spec.ExpirationSeconds)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"apiVersion": "authentication.gardener.cloud/v1alpha1",
+			"kind":       "AdminKubeconfigRequest",
+			"status":     map[string]interface{}{"kubeconfig": []byte("shoot-kubeconfig")},
+		})
+	}))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "hf-gardener-kubeconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	gardenKubeconfig := filepath.Join(dir, "garden.yaml")
+	require.NoError(t, ioutil.WriteFile(gardenKubeconfig, []byte(testKubeconfig(s)), 0600))
+
+	cfg := map[string]interface{}{
+		"project":               "my-project",
+		"cluster_name":          "my-cluster",
+		"credentials_file_path": gardenKubeconfig,
+		"namespace":             "garden-my-project",
+		"target_provider":       "gcp",
+		"target_secret":         "my-secret",
+		"location":              "europe-west3",
+		"node_count":            3,
+		"machine_type":          "n1-standard-4",
+		"disk_size":             30,
+		"kubernetes_version":    "1.19",
+	}
+	tf := New(WithDataDir(dir))
+
+	kubeconfig, err := tf.GardenerKubeconfig(cfg, 30*time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "shoot-kubeconfig", kubeconfig)
+
+	_, err = tf.GardenerKubeconfig(cfg, time.Minute)
+	require.Error(t, err, "Gardener does not issue kubeconfigs for less than 10 minutes")
+
+	atomic.StoreInt32(&forbidden, 1)
+	_, err = tf.GardenerKubeconfig(cfg, 30*time.Minute)
+	require.True(t, errors.Is(err, types.ErrAuthFailed), "Missing permissions should fail with ErrAuthFailed: %v", err)
+}
@@ -0,0 +1,120 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// file name for the tags of the Azure resources, terraform merges it into the resources of the module as an override file
+	tfLabelsFile = "labels_override.tf"
+
+	// the Azure cluster comes from a downloaded module, so its tags cannot come from a variable
+	azureLabelsTemplate = `
+resource "azurerm_kubernetes_cluster" "azure_cluster" {
+	tags = {{labels .Labels}}
+}
+{{- range .NodePools}}
+
+resource "azurerm_kubernetes_cluster_node_pool" "{{.Name}}" {
+	tags = {{labels $.Labels}}
+}
+{{- end}}
+`
+)
+
+// labelProviders are the providers whose built-in templates apply the labels of the configuration.
+// GCP and AWS declare a labels variable in their templates, Azure gets them in an override file.
+var labelProviders = map[types.ProviderType]bool{
+	types.GCP:   true,
+	types.Azure: true,
+	types.AWS:   true,
+}
+
+var (
+	// gcpLabelKey and gcpLabelValue match the labels allowed on GCP resources.
+	gcpLabelKey   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	gcpLabelValue = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+)
+
+// writeLabelsFile renders the labels of the configuration as tags of the Azure cluster and its node pools into an override file.
+// The file is removed if there are no labels or the provider takes them from the vars, so the tags removed from the configuration are removed.
+func writeLabelsFile(dir string, p types.ProviderType, cfg map[string]interface{}) error {
+	path := filepath.Join(dir, tfLabelsFile)
+	labels, _ := cfg["labels"].(map[string]string)
+	if p != types.Azure || len(labels) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	t, err := template.New("labels").Funcs(template.FuncMap{"labels": hclMap}).Parse(azureLabelsTemplate)
+	if err != nil {
+		return err
+	}
+	pools, _ := cfg["node_pools"].([]types.NodePool)
+	s := &strings.Builder{}
+	if err := t.Execute(s, struct {
+		Labels    map[string]string
+		NodePools []types.NodePool
+	}{labels, pools}); err != nil {
+		return errors.Wrap(err, "could not render the labels")
+	}
+	return ioutil.WriteFile(path, []byte(s.String()), 0700)
+}
+
+// hclMap renders the given map as a terraform map of strings, sorted by key so the files only change with the map.
+func hclMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, fmt.Sprintf("%s = %s", hclString(k), hclString(m[k])))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(entries, ", "))
+}
+
+// labelErrors checks the labels of the configuration and returns an error for each invalid label.
+func labelErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	labels, ok := cfg["labels"].(map[string]string)
+	if !ok || len(labels) == 0 {
+		return nil
+	}
+	if !labelProviders[p] {
+		return []types.FieldError{{Field: "labels", Reason: fmt.Sprintf("are not supported on %s", p)}}
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []types.FieldError
+	for _, k := range keys {
+		field := fmt.Sprintf("labels[%s]", k)
+		switch {
+		case k == "":
+			errs = append(errs, types.FieldError{Field: field, Reason: "must have a key"})
+		case p == types.GCP && !gcpLabelKey.MatchString(k):
+			errs = append(errs, types.FieldError{Field: field, Reason: "must have a key starting with a lowercase letter followed by up to 62 lowercase letters, numbers, underscores or hyphens"})
+		case p == types.GCP && !gcpLabelValue.MatchString(labels[k]):
+			errs = append(errs, types.FieldError{Field: field, Reason: "must have a value of up to 63 lowercase letters, numbers, underscores or hyphens"})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,92 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+var testLabels = map[string]string{
+	"owner":       "team-a",
+	"environment": "dev",
+	"cost-center": "1234",
+}
+
+func TestWriteLabelsFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-labels")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the Azure cluster resource comes from a module, a minimal one is enough to merge the override into
+	cfg := map[string]interface{}{"labels": testLabels, "node_pools": testNodePools}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(`resource "azurerm_kubernetes_cluster" "azure_cluster" {}`), 0600))
+	require.NoError(t, writeNodePoolsFile(dir, types.Azure, cfg))
+
+	require.NoError(t, writeLabelsFile(dir, types.Azure, cfg))
+	_, diags := configs.NewParser(nil).LoadConfigDir(dir)
+	require.False(t, diags.HasErrors(), "The Azure labels should be valid terraform: %s", diags.Error())
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfLabelsFile))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `tags = {"cost-center" = "1234", "environment" = "dev", "owner" = "team-a"}`)
+	for _, pool := range testNodePools {
+		require.Contains(t, string(data), `resource "azurerm_kubernetes_cluster_node_pool" "`+pool.Name+`"`, "The node pools should be tagged")
+	}
+
+	// without labels there are no tags
+	require.NoError(t, writeLabelsFile(dir, types.Azure, map[string]interface{}{}))
+	_, err = os.Stat(filepath.Join(dir, tfLabelsFile))
+	require.True(t, os.IsNotExist(err), "The labels file should be removed without labels")
+
+	// GCP and AWS take the labels from the vars
+	require.NoError(t, writeLabelsFile(dir, types.GCP, cfg))
+	_, err = os.Stat(filepath.Join(dir, tfLabelsFile))
+	require.True(t, os.IsNotExist(err), "Providers with a labels variable should not get the file")
+}
+
+func TestLabelVars(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{"project": "my-project", "labels": testLabels}
+
+	for _, p := range []types.ProviderType{types.GCP, types.AWS} {
+		require.Equal(t, testLabels, filterVars(cfg, p)["labels"], "%s should get the labels in its vars", p)
+	}
+	for _, p := range []types.ProviderType{types.Azure, types.Kind, types.OpenStack} {
+		require.NotContains(t, filterVars(cfg, p), "labels", "%s should not get the labels in its vars", p)
+	}
+
+	dir, err := ioutil.TempDir("", "hf-labels-vars")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, writeVarsFile(dir, map[string]interface{}{"labels": testLabels}))
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfVarsFile))
+	require.NoError(t, err)
+	require.Equal(t, `labels = {"cost-center" = "1234", "environment" = "dev", "owner" = "team-a"}`+"\n", string(data))
+
+	// the built-in templates declare the variable
+	for _, tmpl := range []string{gcpClusterTemplate, awsClusterTemplate} {
+		require.Contains(t, tmpl, `variable "labels"`)
+	}
+}
+
+func TestLabelErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, labelErrors(types.GCP, map[string]interface{}{"labels": testLabels}))
+	require.Empty(t, labelErrors(types.Kind, map[string]interface{}{}), "No labels should always be valid")
+
+	errs := labelErrors(types.GCP, map[string]interface{}{"labels": map[string]string{"Owner": "team-a", "env": "Dev"}})
+	require.Len(t, errs, 2, "GCP labels must be lowercase")
+	require.Empty(t, labelErrors(types.AWS, map[string]interface{}{"labels": map[string]string{"Owner": "Team A"}}), "AWS tags can have any case")
+
+	errs = labelErrors(types.Azure, map[string]interface{}{"labels": map[string]string{"": "team-a"}})
+	require.Len(t, errs, 1, "Labels must have a key")
+
+	errs = labelErrors(types.Kind, map[string]interface{}{"labels": testLabels})
+	require.Len(t, errs, 1, "Providers without labels should reject them")
+	require.Equal(t, "labels", errs[0].Field)
+}
@@ -0,0 +1,50 @@
+package terraform
+
+import (
+	"os"
+	"sync"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// lockCluster acquires the lock of the given cluster, so that no other operation sharing the data dir works on its files at the same time.
+// The lock is a file next to the cluster directory, so it outlives the cleanup of the directory, and it is released when the returned function is called.
+// If the cluster is already locked, lockCluster fails right away with ErrLocked instead of waiting.
+func lockCluster(ops Options, project, cluster string, p types.ProviderType) (func(), error) {
+	dir, err := clusterDir(ops, project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := tryLock(dir + ".lock")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not lock the cluster")
+	}
+	if unlock == nil {
+		return nil, errors.Wrapf(types.ErrLocked, "cluster %s of project %s on %s", cluster, project, p)
+	}
+	return unlock, nil
+}
+
+// tryLock takes the lock file at the given path without waiting, it returns no function if the file is already locked.
+// The lock is released when the returned function is called.
+func tryLock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open the lock file")
+	}
+	locked, err := lockFile(f)
+	if err != nil || !locked {
+		f.Close()
+		return nil, err
+	}
+
+	// the lock belongs to the open file, closing it releases the lock
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			f.Close()
+		})
+	}, nil
+}
@@ -0,0 +1,48 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockCluster(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-lock-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ops := options(WithDataDir(dir))
+	unlock, err := lockCluster(ops, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+
+	_, err = lockCluster(ops, "my-project", "my-cluster", types.GCP)
+	require.True(t, errors.Is(err, types.ErrLocked), "A second operation on the same cluster should fail with ErrLocked")
+
+	// other clusters are not affected
+	unlockOther, err := lockCluster(ops, "my-project", "my-cluster", types.Azure)
+	require.NoError(t, err)
+	defer unlockOther()
+
+	// the lock outlives the cleanup of the cluster directory
+	require.NoError(t, cleanup(ops, "my-project", "my-cluster", types.GCP))
+	_, err = lockCluster(ops, "my-project", "my-cluster", types.GCP)
+	require.True(t, errors.Is(err, types.ErrLocked), "The cluster should stay locked after its files are cleaned up")
+
+	unlock()
+	unlock()
+	unlock, err = lockCluster(ops, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err, "The cluster should be free after the lock is released")
+	unlock()
+
+	// Cleanup refuses to remove the files of a locked cluster
+	unlock, err = lockCluster(ops, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	defer unlock()
+	err = New(WithDataDir(dir)).Cleanup(types.GCP, map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"})
+	require.True(t, errors.Is(err, types.ErrLocked))
+}
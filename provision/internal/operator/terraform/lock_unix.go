@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package terraform
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on the given file without waiting, and returns false if the file is already locked.
+// flock locks belong to the open file, so they also exclude other operations of the same process.
+func lockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,136 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+)
+
+// staticMachineTypes are the machine types of the providers without an API client in Hydroform, they are offered in all regions.
+var staticMachineTypes = map[types.ProviderType][]string{
+	types.DigitalOcean: {
+		"s-1vcpu-1gb", "s-1vcpu-2gb", "s-2vcpu-2gb", "s-2vcpu-4gb", "s-4vcpu-8gb", "s-6vcpu-16gb", "s-8vcpu-16gb", "s-8vcpu-32gb",
+		"s-12vcpu-48gb", "s-16vcpu-64gb", "s-20vcpu-96gb", "s-24vcpu-128gb", "s-32vcpu-192gb",
+		"s-1vcpu-1gb-amd", "s-1vcpu-2gb-amd", "s-2vcpu-2gb-amd", "s-2vcpu-4gb-amd", "s-2vcpu-8gb-amd", "s-4vcpu-8gb-amd", "s-4vcpu-16gb-amd", "s-8vcpu-16gb-amd", "s-8vcpu-32gb-amd",
+		"s-1vcpu-1gb-intel", "s-1vcpu-2gb-intel", "s-2vcpu-2gb-intel", "s-2vcpu-4gb-intel", "s-2vcpu-8gb-intel", "s-4vcpu-8gb-intel", "s-4vcpu-16gb-intel", "s-8vcpu-16gb-intel", "s-8vcpu-32gb-intel",
+		"c-2", "c-4", "c-8", "c-16", "c-32", "c-48",
+		"g-2vcpu-8gb", "g-4vcpu-16gb", "g-8vcpu-32gb", "g-16vcpu-64gb", "g-32vcpu-128gb", "g-40vcpu-160gb",
+		"gd-2vcpu-8gb", "gd-4vcpu-16gb", "gd-8vcpu-32gb", "gd-16vcpu-64gb", "gd-32vcpu-128gb", "gd-40vcpu-160gb",
+		"m-2vcpu-16gb", "m-4vcpu-32gb", "m-8vcpu-64gb", "m-16vcpu-128gb", "m-24vcpu-192gb", "m-32vcpu-256gb",
+		"so-2vcpu-16gb", "so-4vcpu-32gb", "so-8vcpu-64gb", "so-16vcpu-128gb", "so-24vcpu-192gb", "so-32vcpu-256gb",
+	},
+}
+
+// requestedMachineType is a machine type of the configuration with the field it is set in.
+type requestedMachineType struct {
+	field       string
+	machineType string
+}
+
+// requestedMachineTypes returns the machine types of the configuration, the one of the default nodes first and then the ones of the node pools.
+func requestedMachineTypes(p types.ProviderType, cfg map[string]interface{}) []requestedMachineType {
+	var requested []requestedMachineType
+	switch p {
+	case types.GCP, types.AWS:
+		requested = append(requested, requestedMachineType{"machine_type", stringValue(cfg["machine_type"])})
+	case types.Azure:
+		requested = append(requested, requestedMachineType{"agent_vm_size", stringValue(cfg["agent_vm_size"])})
+	case types.DigitalOcean:
+		requested = append(requested, requestedMachineType{"node_size", stringValue(cfg["node_size"])})
+	}
+	pools, _ := cfg["node_pools"].([]types.NodePool)
+	for i, pool := range pools {
+		requested = append(requested, requestedMachineType{fmt.Sprintf("node_pools[%d].machine_type", i), pool.MachineType})
+	}
+	return requested
+}
+
+// checkMachineTypes checks the machine types of the configuration against the ones the provider offers before terraform applies it,
+// since an unknown machine type is only reported by the provider once terraform creates the nodes. GCP, Azure and AWS list the machine types
+// of the location of the cluster with the probes of Preflight, DigitalOcean has a static list. It returns a MachineTypeError for the first machine type
+// not offered, with the closest one offered.
+// The check is skipped for custom templates and the other providers: Gardener checks the machine types against its cloud profile,
+// and OpenStack and AliCloud offer flavors and instance types that differ per cloud and zone. It is also skipped if the machine types cannot be listed,
+// such as without the permission to list them, terraform reports the errors of the credentials it needs.
+func checkMachineTypes(ctx context.Context, ops Options, p types.ProviderType, cfg map[string]interface{}) error {
+	if _, ok := ops.Templates[p]; ok {
+		return nil
+	}
+	offered, location := staticMachineTypes[p], ""
+	if newProbe, ok := preflightProbes[p]; ok {
+		probe, err := newProbe(ctx, cfg, proxyTransport(ops))
+		if err != nil {
+			return nil
+		}
+		if offered, location, err = probe.machineTypes(ctx); err != nil {
+			return nil
+		}
+	}
+	return machineTypeError(p, location, offered, requestedMachineTypes(p, cfg))
+}
+
+// machineTypeError returns a MachineTypeError for the first requested machine type that is not offered, nil if all are or none are offered.
+func machineTypeError(p types.ProviderType, location string, offered []string, requested []requestedMachineType) error {
+	if len(offered) == 0 {
+		return nil
+	}
+	for _, r := range requested {
+		if r.machineType == "" || contains(offered, r.machineType) {
+			continue
+		}
+		return &types.MachineTypeError{
+			Field:       r.field,
+			MachineType: r.machineType,
+			Provider:    p,
+			Location:    location,
+			Suggestion:  closestName(r.machineType, offered),
+		}
+	}
+	return nil
+}
+
+// closestName returns the given name with the smallest edit distance to s, the first one in alphabetical order if several are as close.
+func closestName(s string, names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	closest, best := "", -1
+	for _, n := range sorted {
+		if d := editDistance(s, n); best < 0 || d < best {
+			closest, best = n, d
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between the given strings: the number of characters to insert, delete or replace to turn a into b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// minInt returns the smallest of the given numbers.
+func minInt(n int, others ...int) int {
+	for _, o := range others {
+		if o < n {
+			n = o
+		}
+	}
+	return n
+}
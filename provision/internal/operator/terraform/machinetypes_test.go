@@ -0,0 +1,70 @@
+package terraform
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestedMachineTypes(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"agent_vm_size": "Standard_D4_v3",
+		"node_pools":    []types.NodePool{{Name: "gpu", MachineType: "Standard_NC6"}},
+	}
+	require.Equal(t, []requestedMachineType{
+		{"agent_vm_size", "Standard_D4_v3"},
+		{"node_pools[0].machine_type", "Standard_NC6"},
+	}, requestedMachineTypes(types.Azure, cfg))
+	require.Equal(t, []requestedMachineType{{"node_size", "s-2vcpu-4gb"}}, requestedMachineTypes(types.DigitalOcean, map[string]interface{}{"node_size": "s-2vcpu-4gb"}))
+}
+
+func TestMachineTypeError(t *testing.T) {
+	t.Parallel()
+	offered := []string{"Standard_D2_v3", "Standard_D4_v3", "Standard_D8_v3"}
+
+	require.NoError(t, machineTypeError(types.Azure, "westeurope", offered, []requestedMachineType{{"agent_vm_size", "Standard_D4_v3"}}))
+	require.NoError(t, machineTypeError(types.Azure, "westeurope", nil, []requestedMachineType{{"agent_vm_size", "n1-standard-4"}}), "Without machine types nothing should be checked")
+
+	err := machineTypeError(types.Azure, "westeurope", offered, []requestedMachineType{{"agent_vm_size", "Standard_D4_v3"}, {"node_pools[0].machine_type", "Standard_D4_v4"}})
+	require.True(t, errors.Is(err, types.ErrInvalidMachineType))
+	var merr *types.MachineTypeError
+	require.True(t, errors.As(err, &merr))
+	require.Equal(t, &types.MachineTypeError{
+		Field:       "node_pools[0].machine_type",
+		MachineType: "Standard_D4_v4",
+		Provider:    types.Azure,
+		Location:    "westeurope",
+		Suggestion:  "Standard_D4_v3",
+	}, merr, "The closest machine type should be suggested")
+	require.Contains(t, err.Error(), `did you mean "Standard_D4_v3"?`)
+}
+
+func TestClosestName(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "n1-standard-4", closestName("n1-standrd-4", []string{"n1-standard-8", "n1-standard-4", "e2-medium"}))
+	require.Equal(t, "a", closestName("c", []string{"b", "a"}), "Names as close should be chosen alphabetically")
+	require.Empty(t, closestName("n1-standard-4", nil))
+	require.Equal(t, 3, editDistance("kitten", "sitting"))
+}
+
+func TestCheckMachineTypes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "node_size": "s-2vcpu-4gbb"}
+
+	err := checkMachineTypes(ctx, options(), types.DigitalOcean, cfg)
+	require.True(t, errors.Is(err, types.ErrInvalidMachineType), "The sizes of DigitalOcean should be checked against the static list")
+	require.Contains(t, err.Error(), `did you mean "s-2vcpu-4gb"?`)
+
+	cfg["node_size"] = "s-2vcpu-4gb"
+	require.NoError(t, checkMachineTypes(ctx, options(), types.DigitalOcean, cfg))
+
+	cfg["node_size"] = "custom"
+	require.NoError(t, checkMachineTypes(ctx, options(WithTemplate(types.DigitalOcean, fstest.MapFS{})), types.DigitalOcean, cfg), "Custom templates should not be checked")
+	require.NoError(t, checkMachineTypes(ctx, options(), types.GCP, map[string]interface{}{"machine_type": "custom"}), "Machine types that cannot be listed should not be checked")
+}
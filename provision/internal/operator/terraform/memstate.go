@@ -0,0 +1,153 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// stateStore keeps terraform states in memory by cluster, for the InMemoryState option, and the identities recorded for the clusters, see recordIdentity.
+// Each operator has its own store, shared with the operators of its batches and background operations.
+type stateStore struct {
+	mu         sync.Mutex
+	states     map[string]*statefile.File
+	identities map[string]*clusterIdentity
+}
+
+// stateKey identifies the state of a cluster in the store by the directory of the cluster, so the operators with other data dirs
+// or path strategies never share their states.
+func stateKey(ops Options, project, cluster string, p types.ProviderType) (string, error) {
+	return clusterPath(ops, project, cluster, p)
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{states: make(map[string]*statefile.File), identities: make(map[string]*clusterIdentity)}
+}
+
+func (s *stateStore) load(ops Options, project, cluster string, p types.ProviderType) (*statefile.File, error) {
+	key, err := stateKey(ops, project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sf, ok := s.states[key]
+	if !ok {
+		return nil, errors.Wrapf(types.ErrStateNotFound, "there is no state in memory for cluster %s", cluster)
+	}
+	return sf, nil
+}
+
+// store keeps the given state for the cluster. A state without resources is dropped, so deleted clusters do not stay in memory.
+func (s *stateStore) store(ops Options, sf *statefile.File, project, cluster string, p types.ProviderType) error {
+	key, err := stateKey(ops, project, cluster, p)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sf == nil || sf.State == nil || !sf.State.HasResources() {
+		delete(s.states, key)
+		return nil
+	}
+	s.states[key] = sf
+	return nil
+}
+
+func (s *stateStore) remove(ops Options, project, cluster string, p types.ProviderType) error {
+	key, err := stateKey(ops, project, cluster, p)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+// identity returns the identity recorded for the cluster, nil if none was recorded.
+func (s *stateStore) identity(ops Options, project, cluster string, p types.ProviderType) (*clusterIdentity, error) {
+	key, err := stateKey(ops, project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.identities[key], nil
+}
+
+// setIdentity records the given identity for the cluster, a nil identity removes the recorded one.
+// The identities are kept when the states are removed, as in the data dir.
+func (s *stateStore) setIdentity(ops Options, id *clusterIdentity, project, cluster string, p types.ProviderType) error {
+	key, err := stateKey(ops, project, cluster, p)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == nil {
+		delete(s.identities, key)
+		return nil
+	}
+	s.identities[key] = id
+	return nil
+}
+
+// inMemoryState writes the in-memory state of the cluster into its directory, so terraform can use it during an operation.
+// The returned function moves the state terraform left in the directory back into memory and removes the state file, its backup and the plan.
+// It must be called once the operation finishes. Without the InMemoryState option, or with a backend, there is nothing to do.
+func inMemoryState(ops Options, mem *stateStore, project, cluster string, p types.ProviderType) (func() error, error) {
+	if !ops.InMemoryState || ops.Backend != nil {
+		return noCleanup, nil
+	}
+
+	if sf, err := mem.load(ops, project, cluster, p); err == nil {
+		if err := stateToFile(sf, ops, project, cluster, p); err != nil {
+			return nil, errors.Wrap(err, "could not write the state for terraform")
+		}
+	}
+
+	return func() error {
+		sf, err := stateFromFile(ops, project, cluster, p)
+		switch {
+		case err == nil:
+			if err := mem.store(ops, sf, project, cluster, p); err != nil {
+				return err
+			}
+		case !errors.Is(err, types.ErrStateNotFound):
+			// never leave the state on disk, even if it cannot be kept
+			if rerr := removeStateFile(ops, project, cluster, p); rerr != nil {
+				return errors.Wrapf(err, "could not read the state written by terraform and %s", rerr)
+			}
+			return errors.Wrap(err, "could not read the state written by terraform")
+		}
+		if err := removeStateFile(ops, project, cluster, p); err != nil {
+			return err
+		}
+		// plans contain the state they were made from
+		dir, err := clusterDir(ops, project, cluster, p)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(dir, tfPlanFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}, nil
+}
+
+// stateFromMemory returns the state of the cluster terraform is working on, or the one in memory if it is not running.
+func stateFromMemory(ops Options, mem *stateStore, project, cluster string, p types.ProviderType) (*statefile.File, error) {
+	dir, err := clusterDir(ops, project, cluster, p)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, tfStateFile)); err == nil {
+		return stateFromFile(ops, project, cluster, p)
+	}
+	return mem.load(ops, project, cluster, p)
+}
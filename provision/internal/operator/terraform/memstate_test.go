@@ -0,0 +1,98 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryState(t *testing.T) {
+	t.Parallel()
+	dataDir, err := ioutil.TempDir("", "hf-memstate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	ops, mem := options(WithDataDir(dataDir), WithInMemoryState()), newStateStore()
+	project, cluster := "memstate-project", "memstate-cluster"
+	dir, err := clusterDir(ops, project, cluster, types.GCP)
+	require.NoError(t, err)
+	stateFile := filepath.Join(dir, tfStateFile)
+
+	_, err = loadState(ops, mem, project, cluster, types.GCP)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "There should be no state in memory yet")
+
+	// a state given to an operation
+	release, err := inMemoryState(ops, mem, project, cluster, types.GCP)
+	require.NoError(t, err)
+	state := states.NewState()
+	state.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_cluster", Name: "gke_cluster"}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"name": "memstate-cluster"}`)},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+	)
+	require.NoError(t, storeState(ops, mem, statefile.New(state, "lineage", 1), project, cluster, types.GCP))
+	require.FileExists(t, stateFile, "Terraform should get the state in a file")
+	require.NoError(t, release())
+	_, err = os.Stat(stateFile)
+	require.True(t, os.IsNotExist(err), "The state file should be removed after the operation")
+
+	sf, err := loadState(ops, mem, project, cluster, types.GCP)
+	require.NoError(t, err)
+	require.Equal(t, "lineage", sf.Lineage, "The state should be kept in memory")
+
+	// the next operation gets the state from memory, and terraform destroys all resources
+	release, err = inMemoryState(ops, mem, project, cluster, types.GCP)
+	require.NoError(t, err)
+	require.FileExists(t, stateFile, "Terraform should get the state from memory")
+	require.NoError(t, stateToFile(statefile.New(states.NewState(), "lineage", 2), ops, project, cluster, types.GCP))
+	require.NoError(t, release())
+	_, err = os.Stat(stateFile)
+	require.True(t, os.IsNotExist(err), "The state file should be removed after the operation")
+	_, err = loadState(ops, mem, project, cluster, types.GCP)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "The state of a deleted cluster should not be kept")
+
+	// without the option the state stays in the data dir
+	release, err = inMemoryState(options(WithDataDir(dataDir)), mem, project, cluster, types.GCP)
+	require.NoError(t, err)
+	require.NoError(t, storeState(options(WithDataDir(dataDir)), mem, statefile.New(state, "lineage", 3), project, cluster, types.GCP))
+	require.NoError(t, release())
+	require.FileExists(t, stateFile)
+}
+
+func TestInMemoryStateKey(t *testing.T) {
+	t.Parallel()
+	dataDir, err := ioutil.TempDir("", "hf-memstate-key")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	ops := options(WithDataDir(filepath.Join(dataDir, "a")), WithInMemoryState())
+	other := options(WithDataDir(filepath.Join(dataDir, "b")), WithInMemoryState())
+	mem := newStateStore()
+	sf := clusterState("google_container_cluster", "gke_cluster", "google", `{"name": "my-cluster"}`)
+	require.NoError(t, mem.store(ops, sf, "my-project", "my-cluster", types.GCP))
+
+	_, err = mem.load(other, "my-project", "my-cluster", types.GCP)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "Operators with other data dirs should not share their states")
+	loaded, err := mem.load(ops, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.Same(t, sf, loaded)
+
+	// the same directory is the same cluster, whatever its names
+	flat := ops
+	flat.PathStrategy = func(dataDir, project, cluster string, p types.ProviderType) string {
+		return filepath.Join(dataDir, "clusters", "same")
+	}
+	k1, err := stateKey(flat, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	k2, err := stateKey(flat, "other-project", "other-cluster", types.GCP)
+	require.NoError(t, err)
+	require.Equal(t, k1, k2)
+}
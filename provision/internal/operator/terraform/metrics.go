@@ -0,0 +1,75 @@
+package terraform
+
+import (
+	"context"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// names of the operations in the metrics
+const (
+	createMetric = "create"
+	statusMetric = "status"
+	deleteMetric = "delete"
+)
+
+// errorKinds maps the error classes to their kind in the metrics, in the order they are checked.
+// An error wrapping several of them, such as a timeout while waiting for a lock, counts as the first one.
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{types.ErrLocked, "locked"},
+	{types.ErrIdentityMismatch, "identity_mismatch"},
+	{types.ErrAuthFailed, "auth"},
+	{types.ErrRateLimited, "rate_limit"},
+	{types.ErrQuotaExceeded, "quota"},
+	{types.ErrTimeout, "timeout"},
+	{types.ErrProviderUnavailable, "provider_unavailable"},
+	{types.ErrResourceNotFound, "resource_not_found"},
+	{types.ErrStateNotFound, "state_not_found"},
+	{types.ErrUnsupportedOperation, "unsupported_operation"},
+	{types.ErrTerraformNotFound, "terraform_not_found"},
+	{types.ErrIncompleteState, "incomplete_state"},
+	{types.ErrNotReady, "not_ready"},
+	{types.ErrInvalidMachineType, "invalid_machine_type"},
+	{types.ErrStateLocked, "state_locked"},
+	{types.ErrStateVersionMismatch, "state_version_mismatch"},
+	{context.Canceled, "canceled"},
+}
+
+// observe records the duration of the operation started at the given time in the metrics recorder of the options,
+// and counts its error, if any. It is meant to be deferred with the named error of the operation.
+func (t *Terraform) observe(op string, p types.ProviderType, start time.Time, err *error) {
+	if t.ops.Metrics == nil {
+		return
+	}
+	t.ops.Metrics.ObserveDuration(op, p, time.Since(start))
+	if *err != nil {
+		t.ops.Metrics.IncError(op, p, errorKind(*err))
+	}
+}
+
+// errorKind returns the kind of the given error in the metrics. There is a fixed set of kinds, so the labels stay the same for all providers.
+func errorKind(err error) string {
+	var validationErr *types.ValidationError
+	var versionErr *types.UnsupportedVersionError
+	var cleanupErr *types.CleanupError
+	switch {
+	case errors.As(err, &validationErr):
+		return "validation"
+	case errors.As(err, &versionErr):
+		return "unsupported_version"
+	}
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	if errors.As(err, &cleanupErr) {
+		return "cleanup"
+	}
+	return "other"
+}
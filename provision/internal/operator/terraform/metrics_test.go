@@ -0,0 +1,81 @@
+package terraform
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRecorder keeps the metrics it receives.
+type fakeRecorder struct {
+	mu        sync.Mutex
+	durations map[string]int
+	errors    map[string]int
+}
+
+func (r *fakeRecorder) ObserveDuration(op string, p types.ProviderType, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.durations[op+"/"+string(p)]++
+}
+
+func (r *fakeRecorder) IncError(op string, p types.ProviderType, kind string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors[op+"/"+string(p)+"/"+kind]++
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-metrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	r := &fakeRecorder{durations: map[string]int{}, errors: map[string]int{}}
+	tf := New(WithDataDir(dir), WithMetrics(r))
+
+	_, err = tf.Create(types.GCP, map[string]interface{}{})
+	require.Error(t, err)
+	require.Error(t, tf.Delete(nil, types.Azure, map[string]interface{}{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = tf.StatusWithContext(ctx, nil, types.GCP, map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "location": "europe-west3-a", "node_count": 1, "machine_type": "n1-standard-4", "kubernetes_version": "1.19", "disk_size": 30, "credentials_file_path": "credentials.json"})
+	require.Error(t, err)
+
+	require.Equal(t, map[string]int{"create/gcp": 1, "delete/azure": 1, "status/gcp": 1}, r.durations)
+	require.Equal(t, map[string]int{"create/gcp/validation": 1, "delete/azure/validation": 1, "status/gcp/canceled": 1}, r.errors)
+}
+
+func TestErrorKind(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		err  error
+		kind string
+	}{
+		{&types.ValidationError{Fields: []types.FieldError{{Field: "project", Reason: "is required"}}}, "validation"},
+		{errors.Wrap(types.ErrLocked, "cluster my-cluster"), "locked"},
+		{&types.ResourceError{Resource: "google_container_cluster.gke_cluster", Err: types.ErrQuotaExceeded}, "quota"},
+		{errors.Wrap(types.ErrRateLimited, "too many requests"), "rate_limit"},
+		{errors.Wrapf(types.ErrTimeout, "the API server is not ready"), "timeout"},
+		{&types.UnsupportedVersionError{Version: "1.10"}, "unsupported_version"},
+		{&types.CleanupError{Failed: map[string]error{"/data": errors.New("permission denied")}}, "cleanup"},
+		{errors.Wrap(context.Canceled, "stopped"), "canceled"},
+		{errors.Wrap(types.ErrTerraformNotFound, "the gardener plugin could not be downloaded"), "terraform_not_found"},
+		{&types.IncompleteStateError{Outputs: []string{"endpoint"}}, "incomplete_state"},
+		{errors.Wrap(types.ErrNotReady, "shoot my-cluster"), "not_ready"},
+		{&types.MachineTypeError{Field: "machine_type", MachineType: "n1-standard-4", Provider: types.Azure}, "invalid_machine_type"},
+		{&types.StateLockError{ID: "lock", Who: "hydroform@replica-1"}, "state_locked"},
+		{&types.StateVersionError{StateVersion: "0.14.0", FormatVersion: 4, SupportedVersion: "0.12.30"}, "state_version_mismatch"},
+		{errors.New("something else"), "other"},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.kind, errorKind(tc.err), tc.err.Error())
+	}
+}
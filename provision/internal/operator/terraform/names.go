@@ -0,0 +1,138 @@
+package terraform
+
+import (
+	"regexp"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// nameRule is a naming constraint of a provider, with the explanation shown when a name breaks it.
+type nameRule struct {
+	pattern *regexp.Regexp
+	reason  string
+}
+
+// clusterNameRules are the constraints of the providers on the cluster names.
+var clusterNameRules = map[types.ProviderType]nameRule{
+	types.GCP: {
+		regexp.MustCompile(`^[a-z](?:[-a-z0-9]{0,38}[a-z0-9])?$`),
+		"must start with a lowercase letter followed by up to 39 lowercase letters, numbers or hyphens, and cannot end with a hyphen",
+	},
+	types.Azure: {
+		regexp.MustCompile(`^[a-zA-Z0-9](?:[-_a-zA-Z0-9]{0,61}[a-zA-Z0-9])?$`),
+		"must start with a letter or number followed by up to 62 letters, numbers, underscores or hyphens, and cannot end with an underscore or a hyphen",
+	},
+	types.AWS: {
+		regexp.MustCompile(`^[0-9A-Za-z][A-Za-z0-9_-]{0,99}$`),
+		"must start with a letter or number followed by up to 99 letters, numbers, underscores or hyphens",
+	},
+	types.Gardener: {
+		regexp.MustCompile(`^[a-z](?:[-a-z0-9]{0,19}[a-z0-9])?$`),
+		"must start with a lowercase letter followed by up to 20 lowercase letters, numbers or hyphens, and cannot end with a hyphen",
+	},
+	types.Kind: {
+		regexp.MustCompile(`^[a-z](?:[-a-z0-9]{0,38}[a-z0-9])?$`),
+		"must start with a lowercase letter followed by up to 39 lowercase letters, numbers or hyphens, and cannot end with a hyphen",
+	},
+	types.OpenStack: {
+		regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]{0,241}$`),
+		"must start with a letter followed by up to 241 letters, numbers, underscores, dots or hyphens",
+	},
+	types.DigitalOcean: {
+		regexp.MustCompile(`^[a-z](?:[-a-z0-9]{0,61}[a-z0-9])?$`),
+		"must start with a lowercase letter followed by up to 62 lowercase letters, numbers or hyphens, and cannot end with a hyphen",
+	},
+	types.AliCloud: {
+		regexp.MustCompile(`^[a-zA-Z0-9\p{Han}][-_a-zA-Z0-9\p{Han}]{0,62}$`),
+		"must start with a letter, a number or a Chinese character followed by up to 62 letters, numbers, Chinese characters, underscores or hyphens",
+	},
+}
+
+// projectNameRules are the constraints of the providers on the project names.
+// The other providers do not create anything named after the project, it only identifies the cluster files.
+var projectNameRules = map[types.ProviderType]nameRule{
+	types.GCP: {
+		regexp.MustCompile(`^[a-z][-a-z0-9]{4,28}[a-z0-9]$`),
+		"must start with a lowercase letter followed by 5 to 29 lowercase letters, numbers or hyphens, and cannot end with a hyphen",
+	},
+	types.Gardener: {
+		regexp.MustCompile(`^[a-z](?:[-a-z0-9]{0,8}[a-z0-9])?$`),
+		"must start with a lowercase letter followed by up to 9 lowercase letters, numbers or hyphens, and cannot end with a hyphen",
+	},
+}
+
+// anyProjectName is the rule of the providers without constraints on the project names: the project is part of the path of the cluster files.
+var anyProjectName = nameRule{
+	regexp.MustCompile(`^[^/\\]+$`),
+	"must not be empty or contain slashes",
+}
+
+// ValidateName checks that the given cluster name follows the naming rules of the provider.
+// It returns a ValidationError explaining the rule that was violated, so invalid names are rejected before terraform runs.
+func ValidateName(p types.ProviderType, name string) error {
+	rule, ok := clusterNameRules[p]
+	if !ok {
+		return errors.Errorf("provider %q is not supported", p)
+	}
+	if !rule.pattern.MatchString(name) {
+		return &types.ValidationError{Fields: []types.FieldError{{Field: "cluster_name", Reason: rule.reason}}}
+	}
+	return nil
+}
+
+// nameErrors checks the cluster and project names of the configuration and returns an error for each name breaking the rules of the provider.
+// Names of the wrong type are reported by the field checks already.
+func nameErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	var errs []types.FieldError
+	if name, ok := cfg["cluster_name"].(string); ok {
+		if rule := clusterNameRules[p]; rule.pattern != nil && !rule.pattern.MatchString(name) {
+			errs = append(errs, types.FieldError{Field: "cluster_name", Reason: rule.reason})
+		}
+	}
+	if project, ok := cfg["project"].(string); ok {
+		rule, ok := projectNameRules[p]
+		if !ok {
+			rule = anyProjectName
+		}
+		if !rule.pattern.MatchString(project) {
+			errs = append(errs, types.FieldError{Field: "project", Reason: rule.reason})
+		}
+	}
+	return errs
+}
+
+// nameError returns a ValidationError with the names of the configuration breaking the rules of the provider, or nil if they follow them.
+// Only the operations naming resources after the configuration check the rules, so the clusters named before stay manageable:
+// Create, and Update when it renames the cluster, see renameError.
+func nameError(p types.ProviderType, cfg map[string]interface{}) error {
+	if errs := nameErrors(p, cfg); len(errs) > 0 {
+		return &types.ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+// renameError works as nameError for an update of the cluster in the given state, it only checks the names of the configuration
+// that differ from the ones of the cluster resource in the state. Names the state does not hold are not checked.
+func renameError(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
+	id, err := stateIdentity(sf)
+	if err != nil || id == nil || id.Provider != p {
+		return err
+	}
+
+	renamed := map[string]bool{
+		"cluster_name": id.Name != "" && id.Name != cfg["cluster_name"],
+		"project":      id.Project != "" && identityProjects[p].key == "project" && id.Project != cfg["project"],
+	}
+	var errs []types.FieldError
+	for _, e := range nameErrors(p, cfg) {
+		if renamed[e.Field] {
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) > 0 {
+		return &types.ValidationError{Fields: errs}
+	}
+	return nil
+}
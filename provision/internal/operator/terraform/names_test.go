@@ -0,0 +1,70 @@
+package terraform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, ValidateName(types.GCP, "my-cluster"))
+	require.NoError(t, ValidateName(types.Azure, "My_Cluster-1"))
+	require.NoError(t, ValidateName(types.AWS, "1-cluster"))
+
+	err := ValidateName(types.GCP, "My-Cluster")
+	var verr *types.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, "cluster_name", verr.Fields[0].Field)
+	require.Contains(t, verr.Fields[0].Reason, "lowercase")
+
+	require.Error(t, ValidateName(types.GCP, strings.Repeat("a", 41)), "GKE cluster names should have at most 40 characters")
+	require.NoError(t, ValidateName(types.GCP, strings.Repeat("a", 40)))
+	require.Error(t, ValidateName(types.Gardener, "my-cluster-with-a-long-name"), "Shoot names should be short")
+	require.Error(t, ValidateName(types.Azure, "my-cluster-"), "Azure cluster names should not end with a hyphen")
+	require.Error(t, ValidateName(types.AWS, ""))
+	require.Error(t, ValidateName("unknown", "my-cluster"))
+}
+
+func TestNameErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, nameErrors(types.GCP, map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}))
+	require.Empty(t, nameErrors(types.GCP, map[string]interface{}{"project": 1, "cluster_name": 2}), "Names of the wrong type should be reported by the field checks")
+
+	errs := nameErrors(types.GCP, map[string]interface{}{"project": "p", "cluster_name": "my_cluster"})
+	require.Len(t, errs, 2)
+	require.Equal(t, "cluster_name", errs[0].Field)
+	require.Equal(t, "project", errs[1].Field)
+
+	require.Empty(t, nameErrors(types.AWS, map[string]interface{}{"project": "Any Project"}), "AWS projects should only identify the cluster files")
+	errs = nameErrors(types.AWS, map[string]interface{}{"project": "../other"})
+	require.Len(t, errs, 1)
+	require.Equal(t, "project", errs[0].Field)
+
+	// Create rejects invalid names before running terraform
+	_, err := New().Create(types.Kind, map[string]interface{}{"project": "my-project", "cluster_name": "My Cluster"})
+	var verr *types.ValidationError
+	require.True(t, errors.As(err, &verr))
+}
+
+func TestRenameError(t *testing.T) {
+	t.Parallel()
+	// a cluster named before the rules applied
+	gke := clusterState("google_container_cluster", "gke_cluster", "google", `{"name": "My_Cluster", "project": "p"}`)
+
+	require.NoError(t, renameError(gke, types.GCP, map[string]interface{}{"project": "p", "cluster_name": "My_Cluster"}), "Existing names should stay manageable")
+	require.NoError(t, renameError(nil, types.GCP, map[string]interface{}{"project": "p", "cluster_name": "My_Cluster"}), "Names the state does not hold should not be checked")
+	require.NoError(t, renameError(gke, types.GCP, map[string]interface{}{"project": "p", "cluster_name": "my-cluster"}))
+
+	err := renameError(gke, types.GCP, map[string]interface{}{"project": "p", "cluster_name": "Other_Cluster"})
+	var verr *types.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Fields, 1, "Only the renamed cluster should be checked")
+	require.Equal(t, "cluster_name", verr.Fields[0].Field)
+
+	// the other operations never check the names
+	require.NoError(t, validateConfig(types.Kind, map[string]interface{}{"project": "my-project", "cluster_name": "My Cluster", "node_image": "kindest/node:v1.19.1"}))
+}
@@ -0,0 +1,39 @@
+package terraform
+
+import "github.com/kyma-incubator/hydroform/provision/types"
+
+// minEKSSubnets is the number of subnets in different availability zones that EKS needs for a cluster.
+const minEKSSubnets = 2
+
+// networkErrors checks the settings of existing networks in the configuration and returns an error for each invalid field.
+// On AWS the template creates the network unless create_network is false, then it uses the given VPC and subnets.
+// On GCP the template never creates networks, the cluster is in the given network and subnetwork or the default one.
+func networkErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	var errs []types.FieldError
+	network, _ := cfg["network"].(string)
+	switch p {
+	case types.AWS:
+		subnets, _ := cfg["subnetworks"].([]string)
+		create, ok := cfg["create_network"].(bool)
+		if !ok || create {
+			if network != "" {
+				errs = append(errs, types.FieldError{Field: "network", Reason: "can only be used with create_network set to false"})
+			}
+			if len(subnets) > 0 {
+				errs = append(errs, types.FieldError{Field: "subnetworks", Reason: "can only be used with create_network set to false"})
+			}
+			return errs
+		}
+		if network == "" {
+			errs = append(errs, types.FieldError{Field: "network", Reason: "is missing, the ID of an existing VPC is needed if create_network is false"})
+		}
+		if len(subnets) < minEKSSubnets {
+			errs = append(errs, types.FieldError{Field: "subnetworks", Reason: "must contain the IDs of at least 2 existing subnets in different availability zones"})
+		}
+	case types.GCP:
+		if subnetwork, _ := cfg["subnetwork"].(string); subnetwork != "" && network == "" {
+			errs = append(errs, types.FieldError{Field: "network", Reason: "is missing, the network of the subnetwork is needed"})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,58 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, networkErrors(types.AWS, map[string]interface{}{}), "A created network needs no settings")
+	require.Empty(t, networkErrors(types.AWS, map[string]interface{}{
+		"create_network": false,
+		"network":        "vpc-123",
+		"subnetworks":    []string{"subnet-a", "subnet-b"},
+	}))
+
+	errs := networkErrors(types.AWS, map[string]interface{}{"create_network": false, "subnetworks": []string{"subnet-a"}})
+	require.Len(t, errs, 2, "An existing network needs a VPC and 2 subnets")
+	errs = networkErrors(types.AWS, map[string]interface{}{"network": "vpc-123"})
+	require.Len(t, errs, 1, "An existing VPC cannot be used while creating the network")
+	require.Equal(t, "network", errs[0].Field)
+
+	require.Empty(t, networkErrors(types.GCP, map[string]interface{}{"network": "shared", "subnetwork": "nodes"}))
+	errs = networkErrors(types.GCP, map[string]interface{}{"subnetwork": "nodes"})
+	require.Len(t, errs, 1, "A GCP subnetwork needs its network")
+}
+
+func TestExistingNetworkTemplate(t *testing.T) {
+	t.Parallel()
+	dataDir, err := ioutil.TempDir("", "hf-network")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	cfg := map[string]interface{}{
+		"project":        "my-project",
+		"cluster_name":   "my-cluster",
+		"create_network": false,
+		"network":        "vpc-123",
+		"subnetworks":    []string{"subnet-a", "subnet-b"},
+	}
+	require.NoError(t, initClusterFiles(options(WithDataDir(dataDir)), types.AWS, cfg, nil))
+
+	dir, err := clusterDir(options(WithDataDir(dataDir)), "my-project", "my-cluster", types.AWS)
+	require.NoError(t, err)
+	_, diags := configs.NewParser(nil).LoadConfigDir(dir)
+	require.False(t, diags.HasErrors(), "The AWS template should be valid terraform: %s", diags.Error())
+
+	vars, err := ioutil.ReadFile(filepath.Join(dir, tfVarsFile))
+	require.NoError(t, err)
+	require.Contains(t, string(vars), "create_network = false\n", "Booleans should be written as terraform booleans")
+	require.Contains(t, string(vars), `subnetworks = ["subnet-a","subnet-b"]`)
+}
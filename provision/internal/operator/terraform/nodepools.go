@@ -0,0 +1,405 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// file name for the node pools of the cluster, next to the cluster module
+	tfNodePoolsFile = "node_pools.tf"
+
+	gcpNodePoolsTemplate = `
+{{- range .}}
+resource "google_container_node_pool" "{{.Name}}" {
+	name       = {{quote .Name}}
+	cluster    = google_container_cluster.gke_cluster.name
+	location   = google_container_cluster.gke_cluster.location
+	node_count = {{.NodeCount}}
+	{{- if .KubernetesVersion}}
+	version    = {{quote .KubernetesVersion}}
+	{{- else}}
+	version    = var.release_channel == "" ? var.kubernetes_version : null
+	{{- end}}
+	{{- if .Upgrade}}
+
+	upgrade_settings {
+		max_surge       = {{.Upgrade.MaxSurge}}
+		max_unavailable = {{.Upgrade.MaxUnavailable}}
+	}
+	{{- end}}
+	{{- if autoscaling .}}
+
+	autoscaling {
+		min_node_count = {{.Autoscaling.MinCount}}
+		max_node_count = {{.Autoscaling.MaxCount}}
+	}
+
+	# the autoscaler owns the number of nodes
+	lifecycle {
+		ignore_changes = [node_count]
+	}
+	{{- end}}
+
+	# the node pools of a cluster in a release channel must be upgraded with it
+	dynamic "management" {
+		for_each = var.release_channel != "" ? [var.release_channel] : []
+		content {
+			auto_upgrade = true
+			auto_repair  = true
+		}
+	}
+
+	node_config {
+		machine_type = {{quote .MachineType}}
+		{{- if .DiskSizeGB}}
+		disk_size_gb = {{.DiskSizeGB}}
+		{{- end}}
+		{{- if .DiskType}}
+		disk_type    = {{quote .DiskType}}
+		{{- end}}
+		{{- if .Spot}}
+		preemptible  = true
+		{{- end}}
+
+		dynamic "workload_metadata_config" {
+			for_each = local.workload_identity ? ["GKE_METADATA_SERVER"] : []
+			content {
+				node_metadata = workload_metadata_config.value
+			}
+		}
+
+		labels = {
+			{{- range $k, $v := .Labels}}
+			{{quote $k}} = {{quote $v}}
+			{{- end}}
+		}
+		{{- range .Taints}}
+
+		taint {
+			key    = {{quote .Key}}
+			value  = {{quote .Value}}
+			effect = {{quote (gcpTaintEffect .Effect)}}
+		}
+		{{- end}}
+	}
+
+	timeouts {
+		create = var.create_timeout
+		update = var.update_timeout
+		delete = var.delete_timeout
+	}
+}
+{{end}}`
+
+	azureNodePoolsTemplate = `
+{{- range .}}
+resource "azurerm_kubernetes_cluster_node_pool" "{{.Name}}" {
+	name                  = {{quote .Name}}
+	kubernetes_cluster_id = azurerm_kubernetes_cluster.azure_cluster.id
+	vm_size               = {{quote .MachineType}}
+	node_count            = {{.NodeCount}}
+	{{- if .DiskSizeGB}}
+	os_disk_size_gb       = {{.DiskSizeGB}}
+	{{- end}}
+	{{- if .DiskType}}
+	os_disk_type          = {{quote .DiskType}}
+	{{- end}}
+	{{- if .KubernetesVersion}}
+	orchestrator_version  = {{quote .KubernetesVersion}}
+	{{- end}}
+	{{- if .Spot}}
+	priority              = "Spot"
+	eviction_policy       = "Delete"
+	{{- if .MaxSpotPrice}}
+	spot_max_price        = {{.MaxSpotPrice}}
+	{{- end}}
+	{{- end}}
+	{{- if .Upgrade}}
+
+	upgrade_settings {
+		max_surge = {{quote (print .Upgrade.MaxSurge)}}
+	}
+	{{- end}}
+	{{- if autoscaling .}}
+	enable_auto_scaling   = true
+	min_count             = {{.Autoscaling.MinCount}}
+	max_count             = {{.Autoscaling.MaxCount}}
+
+	# the autoscaler owns the number of nodes
+	lifecycle {
+		ignore_changes = [node_count]
+	}
+	{{- end}}
+
+	# AKS sets the label and the taint of the spot nodes, they are repeated so terraform does not remove them
+	node_labels = {
+		{{- range $k, $v := .Labels}}
+		{{quote $k}} = {{quote $v}}
+		{{- end}}
+		{{- if .Spot}}
+		"kubernetes.azure.com/scalesetpriority" = "spot"
+		{{- end}}
+	}
+
+	node_taints = [
+		{{- range .Taints}}
+		{{quote (printf "%s=%s:%s" .Key .Value .Effect)}},
+		{{- end}}
+		{{- if .Spot}}
+		"kubernetes.azure.com/scalesetpriority=spot:NoSchedule",
+		{{- end}}
+	]
+}
+{{end}}`
+)
+
+// nodePoolTemplates contains the template rendering the node pools of each provider that supports them.
+var nodePoolTemplates = map[types.ProviderType]string{
+	types.GCP:   gcpNodePoolsTemplate,
+	types.Azure: azureNodePoolsTemplate,
+}
+
+// nodePoolTypes contains the terraform resource type of the node pools of each provider that supports them.
+var nodePoolTypes = map[types.ProviderType]string{
+	types.GCP:   "google_container_node_pool",
+	types.Azure: "azurerm_kubernetes_cluster_node_pool",
+}
+
+// nodePoolDiskTypes are the disk types of the nodes of each provider that supports node pools,
+// with the attribute of the node pool resource holding it and the type the provider uses by default, if it does not compute it.
+var nodePoolDiskTypes = map[types.ProviderType]struct {
+	names     []string
+	attribute string
+	fallback  string
+}{
+	types.GCP:   {names: []string{"pd-standard", "pd-balanced", "pd-ssd"}, attribute: "node_config.0.disk_type"},
+	types.Azure: {names: []string{"Managed", "Ephemeral"}, attribute: "os_disk_type", fallback: "Managed"},
+}
+
+// nodePoolName matches the node pool names that are valid terraform resource names and node pool names on all providers.
+var nodePoolName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// taintEffects are the Kubernetes taint effects and their name in the GKE API.
+var taintEffects = map[string]string{
+	"NoSchedule":       "NO_SCHEDULE",
+	"PreferNoSchedule": "PREFER_NO_SCHEDULE",
+	"NoExecute":        "NO_EXECUTE",
+}
+
+// writeNodePoolsFile renders the node pools of the configuration into a file next to the cluster module.
+// Each pool is a separate resource, so that adding or removing a pool on update leaves the other ones untouched.
+// The file is removed if there are no node pools, so pools removed from the configuration are destroyed.
+func writeNodePoolsFile(dir string, p types.ProviderType, cfg map[string]interface{}) error {
+	path := filepath.Join(dir, tfNodePoolsFile)
+	pools, _ := cfg["node_pools"].([]types.NodePool)
+	if len(pools) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := expandNodePoolsTemplate(p, pools)
+	if err != nil {
+		return errors.Wrap(err, "could not render the node pools")
+	}
+	return ioutil.WriteFile(path, []byte(data), 0700)
+}
+
+// expandNodePoolsTemplate renders the given node pools with the template of the provider.
+func expandNodePoolsTemplate(p types.ProviderType, pools []types.NodePool) (string, error) {
+	tmpl, ok := nodePoolTemplates[p]
+	if !ok {
+		return "", errors.Errorf("node pools are not supported on %s", p)
+	}
+
+	funcs := template.FuncMap{
+		"quote": hclString,
+		"autoscaling": func(pool types.NodePool) bool {
+			return pool.Autoscaling != nil && pool.Autoscaling.Enabled
+		},
+		"gcpTaintEffect": func(effect string) string {
+			return taintEffects[effect]
+		},
+	}
+
+	t, err := template.New("nodePools").Funcs(funcs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	s := &strings.Builder{}
+	if err := t.Execute(s, pools); err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+// hclString quotes the given value as a terraform string literal, escaping the template sequences.
+func hclString(s string) string {
+	q := strconv.Quote(s)
+	q = strings.Replace(q, "${", "$${", -1)
+	return strings.Replace(q, "%{", "%%{", -1)
+}
+
+// nodePoolErrors checks the node pools of the configuration and returns an error for each invalid field.
+func nodePoolErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	pools, ok := cfg["node_pools"].([]types.NodePool)
+	if !ok || len(pools) == 0 {
+		return nil
+	}
+	if _, ok := nodePoolTemplates[p]; !ok {
+		return []types.FieldError{{Field: "node_pools", Reason: fmt.Sprintf("are not supported on %s", p)}}
+	}
+
+	var errs []types.FieldError
+	names := make(map[string]bool)
+	for i, pool := range pools {
+		field := fmt.Sprintf("node_pools[%d]", i)
+		switch {
+		case !nodePoolName.MatchString(pool.Name):
+			errs = append(errs, types.FieldError{Field: field + ".name", Reason: "must start with a lowercase letter followed by lowercase letters, numbers or hyphens"})
+		case names[pool.Name]:
+			errs = append(errs, types.FieldError{Field: field + ".name", Reason: fmt.Sprintf("must be unique, %q is used by another node pool", pool.Name)})
+		}
+		names[pool.Name] = true
+
+		if pool.MachineType == "" {
+			errs = append(errs, types.FieldError{Field: field + ".machine_type", Reason: "is missing"})
+		}
+		if a := pool.Autoscaling; a != nil && a.Enabled {
+			if a.MinCount < 0 || a.MaxCount < 1 || a.MinCount > a.MaxCount {
+				errs = append(errs, types.FieldError{Field: field + ".autoscaling", Reason: "must have a max_count of at least 1 and a min_count between 0 and max_count"})
+			} else if pool.NodeCount < a.MinCount || pool.NodeCount > a.MaxCount {
+				errs = append(errs, types.FieldError{Field: field + ".node_count", Reason: fmt.Sprintf("must be between the autoscaling min_count %d and max_count %d", a.MinCount, a.MaxCount)})
+			}
+		} else if pool.NodeCount < 1 {
+			errs = append(errs, types.FieldError{Field: field + ".node_count", Reason: "must be at least 1"})
+		}
+		if pool.DiskSizeGB < 0 {
+			errs = append(errs, types.FieldError{Field: field + ".disk_size", Reason: "cannot be negative"})
+		}
+		if d := nodePoolDiskTypes[p]; pool.DiskType != "" && !contains(d.names, pool.DiskType) {
+			errs = append(errs, types.FieldError{Field: field + ".disk_type", Reason: fmt.Sprintf("must be one of %s on %s, got %q", strings.Join(d.names, ", "), p, pool.DiskType)})
+		}
+		if u := pool.Upgrade; u != nil {
+			switch {
+			case u.MaxSurge < 0 || u.MaxUnavailable < 0:
+				errs = append(errs, types.FieldError{Field: field + ".upgrade", Reason: "cannot have a negative max_surge or max_unavailable"})
+			case p == types.Azure && u.MaxUnavailable > 0:
+				errs = append(errs, types.FieldError{Field: field + ".upgrade", Reason: "cannot have a max_unavailable on Azure, only a max_surge"})
+			case u.MaxSurge == 0 && u.MaxUnavailable == 0:
+				errs = append(errs, types.FieldError{Field: field + ".upgrade", Reason: "must have a max_surge or max_unavailable above 0"})
+			}
+		}
+		switch {
+		case pool.MaxSpotPrice < 0:
+			errs = append(errs, types.FieldError{Field: field + ".max_spot_price", Reason: "cannot be negative"})
+		case pool.MaxSpotPrice > 0 && p != types.Azure:
+			errs = append(errs, types.FieldError{Field: field + ".max_spot_price", Reason: fmt.Sprintf("is not supported on %s, only on Azure", p)})
+		case pool.MaxSpotPrice > 0 && !pool.Spot:
+			errs = append(errs, types.FieldError{Field: field + ".max_spot_price", Reason: "can only be set on spot node pools"})
+		}
+		if pool.Spot && p == types.Azure && pool.Upgrade != nil {
+			errs = append(errs, types.FieldError{Field: field + ".upgrade", Reason: "cannot be set on spot node pools on Azure"})
+		}
+		for j, taint := range pool.Taints {
+			if _, ok := taintEffects[taint.Effect]; !ok {
+				errs = append(errs, types.FieldError{Field: fmt.Sprintf("%s.taints[%d].effect", field, j), Reason: "must be NoSchedule, PreferNoSchedule or NoExecute"})
+			}
+		}
+	}
+	return errs
+}
+
+// nodePoolResources are the resource types of the node pools, with the function telling from their attributes if autoscaling is active.
+var nodePoolResources = map[string]func(attrs map[string]interface{}) bool{
+	"google_container_node_pool": func(attrs map[string]interface{}) bool {
+		a, _ := attrs["autoscaling"].([]interface{})
+		return len(a) > 0
+	},
+	"azurerm_kubernetes_cluster_node_pool": func(attrs map[string]interface{}) bool {
+		enabled, _ := attrs["enable_auto_scaling"].(bool)
+		return enabled
+	},
+}
+
+// nodePoolAutoscaling returns whether autoscaling is active for each node pool in the state, by node pool name.
+// It returns nil if the state has no node pools.
+func nodePoolAutoscaling(sf *statefile.File) (map[string]bool, error) {
+	var autoscaling map[string]bool
+	for _, r := range sf.State.RootModule().Resources {
+		active, ok := nodePoolResources[r.Addr.Type]
+		if !ok {
+			continue
+		}
+		for _, i := range r.Instances {
+			if i.Current == nil {
+				continue
+			}
+			attrs := make(map[string]interface{})
+			if err := json.Unmarshal(i.Current.AttrsJSON, &attrs); err != nil {
+				return nil, errors.Wrapf(err, "could not decode the attributes of %s", r.Addr)
+			}
+			name, _ := attrs["name"].(string)
+			if name == "" {
+				name = r.Addr.Name
+			}
+			if autoscaling == nil {
+				autoscaling = make(map[string]bool)
+			}
+			autoscaling[name] = active(attrs)
+		}
+	}
+	return autoscaling, nil
+}
+
+// nodePoolDiskTypeError returns a RecreateError if the configuration changes the disk type of node pools in the given state:
+// the providers replace the node pool with all its nodes, which the caller should do with a new node pool instead.
+func nodePoolDiskTypeError(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
+	d, ok := nodePoolDiskTypes[p]
+	if !ok {
+		return nil
+	}
+	pools, _ := cfg["node_pools"].([]types.NodePool)
+	resources, err := driftResources(sf, p)
+	if err != nil {
+		return err
+	}
+
+	rerr := &types.RecreateError{}
+	var reasons []string
+	for _, pool := range pools {
+		addr := fmt.Sprintf("%s.%s", nodePoolTypes[p], pool.Name)
+		attrs, ok := resources[addr]
+		if !ok {
+			continue
+		}
+		desired := pool.DiskType
+		if desired == "" {
+			desired = d.fallback
+		}
+		actual, _ := attributeValue(attrs, d.attribute).(string)
+		if desired == "" || actual == "" || actual == desired {
+			continue
+		}
+		rerr.Resources = append(rerr.Resources, addr)
+		reasons = append(reasons, fmt.Sprintf("the disk type of the node pool %s cannot change from %s to %s", pool.Name, actual, desired))
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	rerr.Reason = strings.Join(reasons, ", ") + ", create a new node pool instead"
+	return rerr
+}
@@ -0,0 +1,203 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var testNodePools = []types.NodePool{
+	{
+		Name:        "gpu",
+		MachineType: "n1-standard-8",
+		NodeCount:   2,
+		DiskSizeGB:  100,
+		Labels:      map[string]string{"accelerator": "nvidia-tesla-t4"},
+		Taints:      []types.Taint{{Key: "nvidia.com/gpu", Value: "present", Effect: "NoSchedule"}},
+	},
+	{
+		Name:        "general",
+		MachineType: "n1-standard-4",
+		NodeCount:   3,
+	},
+	{
+		Name:        "spot",
+		MachineType: "n1-standard-4",
+		NodeCount:   1,
+		Autoscaling: &types.Autoscaling{Enabled: true, MinCount: 0, MaxCount: 5},
+		Spot:        true,
+	},
+}
+
+func TestWriteNodePoolsFile(t *testing.T) {
+	t.Parallel()
+	for _, p := range []types.ProviderType{types.GCP, types.Azure} {
+		dir, err := ioutil.TempDir("", "hf-node-pools")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		if p == types.GCP {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(gcpClusterTemplate), 0600))
+		}
+
+		require.NoError(t, writeNodePoolsFile(dir, p, map[string]interface{}{"node_pools": testNodePools}))
+		mod, diags := configs.NewParser(nil).LoadConfigDir(dir)
+		require.False(t, diags.HasErrors(), "%s node pools should be valid terraform: %s", p, diags.Error())
+
+		// one resource per pool
+		pools := 0
+		for _, r := range mod.ManagedResources {
+			if r.Name == "gpu" || r.Name == "general" || r.Name == "spot" {
+				pools++
+			}
+		}
+		require.Equal(t, 3, pools, "There should be a resource for each %s node pool", p)
+
+		// removing all pools removes the file
+		require.NoError(t, writeNodePoolsFile(dir, p, map[string]interface{}{}))
+		_, err = os.Stat(filepath.Join(dir, tfNodePoolsFile))
+		require.True(t, os.IsNotExist(err), "The node pools file should be removed without node pools")
+	}
+}
+
+func TestExpandNodePoolsTemplate(t *testing.T) {
+	t.Parallel()
+	gcp, err := expandNodePoolsTemplate(types.GCP, testNodePools)
+	require.NoError(t, err)
+	require.Contains(t, gcp, `"accelerator" = "nvidia-tesla-t4"`)
+	require.Contains(t, gcp, `effect = "NO_SCHEDULE"`)
+	require.Contains(t, gcp, "disk_size_gb = 100")
+	require.Contains(t, gcp, "max_node_count = 5")
+	require.Equal(t, 1, strings.Count(gcp, "autoscaling {"), "Only the autoscaled pool should have autoscaling")
+	require.Equal(t, 1, strings.Count(gcp, "preemptible  = true"), "Only the spot pool should be preemptible")
+
+	azure, err := expandNodePoolsTemplate(types.Azure, testNodePools)
+	require.NoError(t, err)
+	require.Contains(t, azure, `"nvidia.com/gpu=present:NoSchedule",`)
+	require.Equal(t, 1, strings.Count(azure, "enable_auto_scaling   = true"), "Only the autoscaled pool should have autoscaling")
+	require.Equal(t, 1, strings.Count(azure, "ignore_changes = [node_count]"), "Only the autoscaled pool should ignore its node count")
+	require.Equal(t, 1, strings.Count(azure, `priority              = "Spot"`), "Only the spot pool should have the spot priority")
+	require.Contains(t, azure, `"kubernetes.azure.com/scalesetpriority=spot:NoSchedule",`, "The spot pool should keep the taint set by AKS")
+	require.NotContains(t, azure, "spot_max_price", "Without max price, spot nodes should only be evicted for capacity")
+
+	azure, err = expandNodePoolsTemplate(types.Azure, []types.NodePool{{Name: "spot", MachineType: "Standard_D4_v3", NodeCount: 1, Spot: true, MaxSpotPrice: 0.05}})
+	require.NoError(t, err)
+	require.Contains(t, azure, "spot_max_price        = 0.05")
+
+	ssd := []types.NodePool{{Name: "io", MachineType: "n2-standard-8", NodeCount: 1, DiskSizeGB: 200, DiskType: "pd-ssd"}}
+	gcp, err = expandNodePoolsTemplate(types.GCP, ssd)
+	require.NoError(t, err)
+	require.Contains(t, gcp, `disk_type    = "pd-ssd"`)
+	require.NotContains(t, azure, "os_disk_type", "Without disk type, the provider default should be used")
+	ssd[0].DiskType = "Ephemeral"
+	azure, err = expandNodePoolsTemplate(types.Azure, ssd)
+	require.NoError(t, err)
+	require.Contains(t, azure, `os_disk_type          = "Ephemeral"`)
+
+	_, err = expandNodePoolsTemplate(types.Kind, testNodePools)
+	require.Error(t, err, "Node pools should not be supported on kind")
+
+	require.Equal(t, `"$${var.secret} %%{if}"`, hclString("${var.secret} %{if}"), "Template sequences should be escaped")
+}
+
+func TestNodePoolErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, nodePoolErrors(types.GCP, map[string]interface{}{"node_pools": testNodePools}))
+	require.Empty(t, nodePoolErrors(types.Kind, map[string]interface{}{}), "No node pools should always be valid")
+	require.Len(t, nodePoolErrors(types.Kind, map[string]interface{}{"node_pools": testNodePools}), 1, "Node pools should not be supported on kind")
+
+	invalid := []types.NodePool{
+		{Name: "GPU", MachineType: "n1-standard-8", NodeCount: 1},
+		{Name: "pool", NodeCount: 0, DiskSizeGB: -1},
+		{Name: "pool", MachineType: "n1-standard-4", NodeCount: 1, Taints: []types.Taint{{Key: "k", Effect: "Never"}}},
+		{Name: "scaled", MachineType: "n1-standard-4", NodeCount: 0, Autoscaling: &types.Autoscaling{Enabled: true, MinCount: 3, MaxCount: 1}},
+		{Name: "scaled-out", MachineType: "n1-standard-4", NodeCount: 6, Autoscaling: &types.Autoscaling{Enabled: true, MinCount: 1, MaxCount: 5}},
+		{Name: "scaled-off", MachineType: "n1-standard-4", NodeCount: 0, Autoscaling: &types.Autoscaling{MaxCount: 5}},
+		{Name: "spot", MachineType: "n1-standard-4", NodeCount: 1, Spot: true, MaxSpotPrice: 0.05},
+	}
+	fields := []string{}
+	for _, e := range nodePoolErrors(types.GCP, map[string]interface{}{"node_pools": invalid}) {
+		fields = append(fields, e.Field)
+	}
+	require.Equal(t, []string{
+		"node_pools[0].name",
+		"node_pools[1].machine_type", "node_pools[1].node_count", "node_pools[1].disk_size",
+		"node_pools[2].name", "node_pools[2].taints[0].effect",
+		"node_pools[3].autoscaling",
+		"node_pools[4].node_count",
+		"node_pools[5].node_count",
+		"node_pools[6].max_spot_price",
+	}, fields)
+
+	spot := []types.NodePool{
+		{Name: "on-demand", MachineType: "Standard_D4_v3", NodeCount: 1, MaxSpotPrice: 0.05},
+		{Name: "spot", MachineType: "Standard_D4_v3", NodeCount: 1, Spot: true, MaxSpotPrice: -1, Upgrade: &types.UpgradeSettings{MaxSurge: 1}},
+		{Name: "capped", MachineType: "Standard_D4_v3", NodeCount: 1, Spot: true, MaxSpotPrice: 0.05},
+	}
+	fields = []string{}
+	for _, e := range nodePoolErrors(types.Azure, map[string]interface{}{"node_pools": spot}) {
+		fields = append(fields, e.Field)
+	}
+	require.Equal(t, []string{"node_pools[0].max_spot_price", "node_pools[1].max_spot_price", "node_pools[1].upgrade"}, fields)
+
+	disks := []types.NodePool{{Name: "io", MachineType: "n2-standard-8", NodeCount: 1, DiskType: "pd-ssd"}}
+	require.Empty(t, nodePoolErrors(types.GCP, map[string]interface{}{"node_pools": disks}))
+	errs := nodePoolErrors(types.Azure, map[string]interface{}{"node_pools": disks})
+	require.Equal(t, []types.FieldError{{Field: "node_pools[0].disk_type", Reason: `must be one of Managed, Ephemeral on azure, got "pd-ssd"`}}, errs)
+}
+
+func TestNodePoolDiskTypeError(t *testing.T) {
+	t.Parallel()
+	gke := clusterState("google_container_node_pool", "io", "google", `{"name": "io", "node_config": [{"disk_type": "pd-standard", "disk_size_gb": 100}]}`)
+	pools := func(pool types.NodePool) map[string]interface{} {
+		pool.Name, pool.MachineType, pool.NodeCount = "io", "n2-standard-8", 1
+		return map[string]interface{}{"node_pools": []types.NodePool{pool}}
+	}
+
+	require.NoError(t, nodePoolDiskTypeError(gke, types.GCP, pools(types.NodePool{DiskType: "pd-standard", DiskSizeGB: 200})), "The disk size should be changeable")
+	require.NoError(t, nodePoolDiskTypeError(gke, types.GCP, pools(types.NodePool{})), "GKE should keep the disk type of a pool without one")
+	require.NoError(t, nodePoolDiskTypeError(nil, types.GCP, pools(types.NodePool{DiskType: "pd-ssd"})), "New pools should not be checked")
+
+	err := nodePoolDiskTypeError(gke, types.GCP, pools(types.NodePool{DiskType: "pd-ssd"}))
+	var rerr *types.RecreateError
+	require.True(t, errors.As(err, &rerr), "Changing the disk type should not replace the node pool")
+	require.Equal(t, []string{"google_container_node_pool.io"}, rerr.Resources)
+	require.Equal(t, "the disk type of the node pool io cannot change from pd-standard to pd-ssd, create a new node pool instead", rerr.Reason)
+
+	aks := clusterState("azurerm_kubernetes_cluster_node_pool", "io", "azurerm", `{"name": "io", "os_disk_type": "Ephemeral"}`)
+	require.NoError(t, nodePoolDiskTypeError(aks, types.Azure, pools(types.NodePool{DiskType: "Ephemeral"})))
+	require.Error(t, nodePoolDiskTypeError(aks, types.Azure, pools(types.NodePool{})), "AKS should fall back to managed disks for a pool without disk type")
+}
+
+func TestNodePoolAutoscaling(t *testing.T) {
+	t.Parallel()
+	state := states.NewState()
+	provider := addrs.NewDefaultProviderConfig("google").Absolute(addrs.RootModuleInstance)
+	for name, attrs := range map[string]string{
+		"general": `{"name": "general", "node_count": 3, "autoscaling": []}`,
+		"spot":    `{"name": "spot", "node_count": 4, "autoscaling": [{"min_node_count": 0, "max_node_count": 5}]}`,
+	} {
+		addr := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_node_pool", Name: name}.Instance(addrs.NoKey)
+		state.RootModule().SetResourceInstanceCurrent(addr, &states.ResourceInstanceObjectSrc{AttrsJSON: []byte(attrs), Status: states.ObjectReady}, provider)
+	}
+	cluster := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_cluster", Name: "gke_cluster"}.Instance(addrs.NoKey)
+	state.RootModule().SetResourceInstanceCurrent(cluster, &states.ResourceInstanceObjectSrc{AttrsJSON: []byte(`{"name": "hydro"}`), Status: states.ObjectReady}, provider)
+
+	info, err := clusterInfoFromState(statefile.New(state, "", 0))
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"general": false, "spot": true}, info.Autoscaling, "Only the node pools should be in the autoscaling")
+
+	info, err = clusterInfoFromState(statefile.New(states.NewState(), "", 0))
+	require.NoError(t, err)
+	require.Nil(t, info.Autoscaling, "A cluster without node pools should have no autoscaling")
+}
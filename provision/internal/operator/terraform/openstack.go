@@ -0,0 +1,42 @@
+package terraform
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// initOpenStackProvider checks that terraform will be able to authenticate on OpenStack before running any command.
+// The openstack provider is downloaded from the terraform registry by init, but it only fails on missing credentials
+// once it talks to the cloud. Credentials are accepted from the configuration with the "user_name" and "password" keys,
+// or from the environment variables supported by the provider: a user and password, an application credential, a token or a cloud from clouds.yaml.
+func initOpenStackProvider(cfg map[string]interface{}) error {
+	user, _ := cfg["user_name"].(string)
+	password, _ := cfg["password"].(string)
+	if user != "" && password != "" {
+		return nil
+	}
+
+	envCredentials := [][]string{
+		{"OS_USERNAME", "OS_PASSWORD"},
+		{"OS_APPLICATION_CREDENTIAL_ID", "OS_APPLICATION_CREDENTIAL_SECRET"},
+		{"OS_TOKEN"},
+		{"OS_CLOUD"},
+	}
+	for _, vars := range envCredentials {
+		if envSet(vars...) {
+			return nil
+		}
+	}
+	return errors.New("no OpenStack credentials found, set user_name and password in the configuration or the OS_USERNAME and OS_PASSWORD environment variables")
+}
+
+// envSet returns true if all given environment variables are set and not empty.
+func envSet(vars ...string) bool {
+	for _, v := range vars {
+		if os.Getenv(v) == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,31 @@
+package terraform
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitOpenStackProvider(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"user_name": "hydroform",
+		"password":  "secret",
+	}
+	require.NoError(t, initOpenStackProvider(cfg), "Credentials from the configuration should be valid")
+
+	// credentials in the environment are always accepted, so only check when there are none
+	if !envSet("OS_USERNAME", "OS_PASSWORD") && !envSet("OS_APPLICATION_CREDENTIAL_ID", "OS_APPLICATION_CREDENTIAL_SECRET") &&
+		!envSet("OS_TOKEN") && !envSet("OS_CLOUD") {
+		delete(cfg, "password")
+		require.Error(t, initOpenStackProvider(cfg), "Validation should fail without password")
+	}
+}
+
+func TestEnvSet(t *testing.T) {
+	t.Parallel()
+	require.True(t, envSet("PATH"))
+	require.False(t, envSet("PATH", "HYDROFORM_UNSET_TEST_VARIABLE"))
+	require.Equal(t, "", os.Getenv("HYDROFORM_UNSET_TEST_VARIABLE"))
+}
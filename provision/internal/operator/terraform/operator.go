@@ -1,9 +1,9 @@
 package terraform
 
 import (
-	"io/ioutil"
-	"log"
-	"os"
+	"context"
+	"sort"
+	"time"
 
 	"github.com/kyma-incubator/hydroform/provision/types"
 	"github.com/pkg/errors"
@@ -13,145 +13,817 @@ import (
 
 // Terraform is an Operator.
 type Terraform struct {
-	ops Options
+	ops      Options
+	inflight *inflightOps
+	rotated  *credentialStore
+	// states are the cluster states kept in memory with the InMemoryState option
+	states *stateStore
 }
 
 // New creates a new Terraform operator with the given options
 func New(ops ...Option) *Terraform {
-	// silence the logs since terraform prints a lot of stuff
-	tfOps := options(ops...)
-	if !tfOps.Verbose {
-		log.SetOutput(ioutil.Discard)
-	}
-
 	return &Terraform{
-		ops: tfOps,
+		ops:      options(ops...),
+		inflight: newInflightOps(),
+		rotated:  &credentialStore{creds: make(map[string]types.Credentials)},
+		states:   newStateStore(),
 	}
 }
 
 // Create creates a new cluster for a specific provider based on configuration details. It returns a ClusterInfo object with provider-related information, or an error if cluster provisioning failed.
+// A machine type the provider does not offer in the location of the cluster fails with a MachineTypeError before terraform runs,
+// as well as names breaking the naming rules of the provider with a ValidationError.
 func (t *Terraform) Create(p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
-	applyTimeouts(cfg, t.ops.Timeouts)
+	return t.CreateWithContext(context.Background(), p, cfg)
+}
 
-	// silence stdErr during terraform execution, plugins send debug and trace entries there
-	if !t.ops.Verbose {
-		stderr := os.Stderr
-		var err error
-		os.Stderr, err = os.Open(os.DevNull)
-		if err != nil {
+// CreateTyped creates a cluster with the given typed configuration, it works as Create with the configuration map of cfg.
+func (t *Terraform) CreateTyped(cfg types.ProviderConfig) (*types.ClusterInfo, error) {
+	return t.CreateTypedWithContext(context.Background(), cfg)
+}
+
+// CreateTypedWithContext works as CreateTyped but stops terraform gracefully when the given context is done.
+func (t *Terraform) CreateTypedWithContext(ctx context.Context, cfg types.ProviderConfig) (*types.ClusterInfo, error) {
+	return t.CreateWithContext(ctx, cfg.Provider(), cfg.ToMap())
+}
+
+// CreateWithContext works as Create but stops terraform gracefully when the given context is done.
+// The state produced by the apply is returned in the InternalState of the ClusterInfo, it is read before the cluster files are cleaned up.
+// If the apply fails or the context is done during the apply, it returns the ClusterInfo derived from the partial state together with the error,
+// so the resources created so far can still be deleted. The files of the cluster and its partial state are then kept even without the Persistent option,
+// so a Create with the same configuration continues from the resources created so far. Use Delete or Cleanup to remove them instead.
+func (t *Terraform) CreateWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (_ *types.ClusterInfo, err error) {
+	ctx, op, release, err := t.prepare(ctx, p, cfg, createOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release(&err)
+	cfg, rep, clusterDir := op.cfg, op.rep, op.dir
+
+	// refuse kubernetes versions the provider does not offer before creating anything
+	if _, ok := op.ops.Templates[p]; !ok {
+		if err := t.checkVersion(ctx, p, cfg); err != nil {
 			return nil, err
 		}
-		defer func() { os.Stderr = stderr }()
 	}
 
-	// init cluster files
-	if !t.ops.Persistent {
-		// remove all files if not persistent after running
-		defer cleanup(t.ops.DataDir(), cfg["project"].(string), cfg["cluster_name"].(string), p)
+	// INIT
+	if err := rep.phase(types.InitPhase, func() error {
+		if err := checkGarden(ctx, op.ops, p, cfg); err != nil {
+			return err
+		}
+		if err := checkMachineTypes(ctx, op.ops, p, cfg); err != nil {
+			return err
+		}
+		if err := initProvider(op.ops, p, cfg); err != nil {
+			return err
+		}
+		if err := tfInit(ctx, op.ops, p, cfg, clusterDir); err != nil {
+			return err
+		}
+		return errors.Wrap(initClusterFiles(op.ops, p, cfg, op.ops.Templates[p]), "Could not initialize cluster data")
+	}); err != nil {
+		return nil, err
 	}
+	rep.resourcesBefore(op.project, op.cluster, p)
 
-	clusterDir, err := clusterDir(t.ops.DataDir(), cfg["project"].(string), cfg["cluster_name"].(string), p)
+	// APPLY
+	summary := &applySummary{}
+	applyOps := summary.options(op.ops)
+	err = rep.phase(types.ApplyPhase, func() error {
+		return retry(ctx, op.ops, func() error { return tfApply(ctx, applyOps, t.states, p, cfg, clusterDir) })
+	})
+	rep.resourcesAfter(op.project, op.cluster, p)
+	if err != nil {
+		op.keepFiles = true
+		// return the state with the resources created so far, so they can also be deleted
+		info := t.partialClusterInfo(op.ops, op.project, op.cluster, p)
+		if info != nil {
+			info.ApplySummary = summary.result()
+			// the cluster resource may be created already
+			if rerr := recordIdentity(t.ops, t.states, info.InternalState.TerraformState, p, cfg); rerr != nil {
+				err = errors.Wrapf(err, "%s", rerr)
+			}
+		}
+		return info, err
+	}
+
+	var info *types.ClusterInfo
+	err = rep.phase(types.OutputPhase, func() error {
+		sf, err := loadState(op.ops, t.states, op.project, op.cluster, p)
+		if err != nil {
+			return err
+		}
+		// the identity is recorded with the options of the operator, the data dir of the sandbox is removed
+		if err := recordIdentity(t.ops, t.states, sf, p, cfg); err != nil {
+			return err
+		}
+		if sf, err = t.completeState(ctx, op.ops, sf, p, cfg, clusterDir); err != nil {
+			if sf != nil {
+				info = incompleteClusterInfo(sf)
+			}
+			return err
+		}
+		info, err = clusterInfo(ctx, sf, p, cfg)
+		return err
+	})
+	if info != nil {
+		info.ApplySummary = summary.result()
+	}
+	return info, err
+}
+
+// Update changes an existing cluster based on the given configuration details without recreating it.
+// It returns the updated ClusterInfo, or a RecreateError if the changes would destroy and recreate the cluster.
+// Node pools switching between spot and on-demand VMs are replaced, the nodes of an EKS cluster cannot be converted: it fails with ErrUnsupportedOperation.
+// Moving a cluster between a zonal and a regional control plane, or between zones, always needs a new cluster and fails with a RecreateError.
+// Changing the disk type of a node pool would replace all its nodes and fails with a RecreateError as well, changing its disk size replaces the node pool.
+// With the TargetedUpdate option, only the resources whose configuration changed and their dependencies are applied.
+// The naming rules of the provider are only checked for the names the update changes, so clusters named before the rules stay manageable.
+func (t *Terraform) Update(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	return t.UpdateWithContext(context.Background(), sf, p, cfg)
+}
+
+// UpdateWithContext works as Update but stops terraform gracefully when the given context is done.
+// If the apply fails or the context is done during the apply, it returns the ClusterInfo derived from the partial state together with the error.
+func (t *Terraform) UpdateWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (_ *types.ClusterInfo, err error) {
+	ctx, op, release, err := t.prepare(ctx, p, cfg, updateOperation)
 	if err != nil {
 		return nil, err
 	}
+	defer release(&err)
+	cfg, clusterDir := op.cfg, op.dir
+
 	// INIT
-	if p == types.Gardener {
-		if err := initGardenerProvider(); err != nil {
-			return nil, errors.Wrap(err, "could not initialize the gardener provider")
+	if err := checkGarden(ctx, op.ops, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := checkMachineTypes(ctx, op.ops, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := initProvider(op.ops, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := tfInit(ctx, op.ops, p, cfg, clusterDir); err != nil {
+		return nil, err
+	}
+	if err := initClusterFiles(op.ops, p, cfg, op.ops.Templates[p]); err != nil {
+		return nil, errors.Wrap(err, "Could not initialize cluster data")
+	}
+
+	// if no state given, check if it is already in the file system
+	given := sf != nil
+	if !given {
+		sf, err = loadState(op.ops, t.states, op.project, op.cluster, p)
+		if err != nil {
+			return nil, errors.Wrap(err, "no state provided, attempted to load from file")
 		}
 	}
-	if err := tfInit(t.ops, p, cfg, clusterDir); err != nil {
+
+	// a resource group of the state that the configuration declares as existing is not managed by terraform anymore
+	if released := releaseResourceGroup(sf, p, cfg); released != nil {
+		sf, given = released, true
+	}
+
+	if err := renameError(sf, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := capacityTypeError(sf, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := zoneChangeError(sf, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := nodePoolDiskTypeError(sf, p, cfg); err != nil {
 		return nil, err
 	}
 
-	if err := initClusterFiles(t.ops.DataDir(), p, cfg); err != nil {
-		return nil, errors.Wrap(err, "Could not initialize cluster data")
+	if given {
+		// save the given state into a file so terraform can use it
+		if err := storeState(op.ops, t.states, sf, op.project, op.cluster, p); err != nil {
+			return nil, errors.Wrap(err, "could not store state into file")
+		}
+	}
+
+	// PLAN
+	if err := tfPlan(ctx, op.ops, p, cfg, clusterDir); err != nil {
+		return nil, err
+	}
+	plan, err := planFromFile(clusterDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the terraform plan")
+	}
+	// never recreate the cluster, it would delete all its workloads
+	if recreated := recreatedResources(plan, clusterResource(p)); len(recreated) > 0 {
+		return nil, &types.RecreateError{Resources: recreated}
+	}
+	if op.ops.TargetedUpdate {
+		if _, err := targetedPlan(ctx, op.ops, sf, p, cfg, clusterDir, plan); err != nil {
+			return nil, err
+		}
 	}
 
 	// APPLY
-	if err := tfApply(t.ops, p, cfg, clusterDir); err != nil {
+	summary := &applySummary{}
+	if err := tfApplyPlan(ctx, summary.options(op.ops), p, clusterDir); err != nil {
+		info := t.partialClusterInfo(op.ops, op.project, op.cluster, p)
+		if info != nil {
+			info.ApplySummary = summary.result()
+		}
+		return info, err
+	}
+
+	sf, err = loadState(op.ops, t.states, op.project, op.cluster, p)
+	if err != nil {
+		return nil, err
+	}
+	if sf, err = t.completeState(ctx, op.ops, sf, p, cfg, clusterDir); err != nil {
+		if sf == nil {
+			return nil, err
+		}
+		info := incompleteClusterInfo(sf)
+		info.ApplySummary = summary.result()
+		return info, err
+	}
+	info, err := clusterInfo(ctx, sf, p, cfg)
+	if info != nil {
+		info.ApplySummary = summary.result()
+	}
+	return info, err
+}
+
+// Plan returns the changes that Create would perform for the given configuration details without applying them.
+// If there is a state for the cluster, the changes are calculated against it.
+func (t *Terraform) Plan(p types.ProviderType, cfg map[string]interface{}) (*types.ClusterPlan, error) {
+	return t.PlanWithContext(context.Background(), p, cfg)
+}
+
+// PlanWithContext works as Plan but stops terraform gracefully when the given context is done.
+func (t *Terraform) PlanWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterPlan, error) {
+	var cp *types.ClusterPlan
+	err := t.plan(ctx, p, cfg, func(_ Options, clusterDir string) error {
+		plan, err := planFromFile(clusterDir)
+		if err != nil {
+			return errors.Wrap(err, "could not read the terraform plan")
+		}
+		cp = clusterPlan(plan)
+		return nil
+	})
+	return cp, err
+}
+
+// PlanJSON returns the changes that Create would perform for the given configuration details in the JSON format of 'terraform show -json' for a plan file,
+// for tools diffing, storing or checking plans, such as policy engines.
+// If there is a state for the cluster, the changes are calculated against it.
+// The values are marshaled the way terraform does, with the sensitive outputs and attributes flagged by terraform.
+func (t *Terraform) PlanJSON(p types.ProviderType, cfg map[string]interface{}) ([]byte, error) {
+	return t.PlanJSONWithContext(context.Background(), p, cfg)
+}
+
+// PlanJSONWithContext works as PlanJSON but stops terraform gracefully when the given context is done.
+func (t *Terraform) PlanJSONWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) ([]byte, error) {
+	var data []byte
+	err := t.plan(ctx, p, cfg, func(ops Options, clusterDir string) (err error) {
+		data, err = planJSON(clusterDir, installedProviders(ops))
+		return err
+	})
+	return data, err
+}
+
+// plan saves the plan of the cluster in its directory and calls read with the directory before its files are cleaned up.
+func (t *Terraform) plan(ctx context.Context, p types.ProviderType, cfg map[string]interface{}, read func(ops Options, clusterDir string) error) (err error) {
+	ctx, op, release, err := t.prepare(ctx, p, cfg, readOperation)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+	cfg, clusterDir := op.cfg, op.dir
+
+	// INIT
+	if err := checkGarden(ctx, op.ops, p, cfg); err != nil {
+		return err
+	}
+	if err := initProvider(op.ops, p, cfg); err != nil {
+		return err
+	}
+	if err := tfInit(ctx, op.ops, p, cfg, clusterDir); err != nil {
+		return err
+	}
+	if err := initClusterFiles(op.ops, p, cfg, op.ops.Templates[p]); err != nil {
+		return errors.Wrap(err, "Could not initialize cluster data")
+	}
+
+	// PLAN
+	if err := tfPlan(ctx, op.ops, p, cfg, clusterDir); err != nil {
+		return err
+	}
+	return read(op.ops, clusterDir)
+}
+
+// Import brings an existing cluster created outside of Hydroform under its management.
+// resourceIDs maps the address of each resource in the terraform module to the ID of the existing resource of the provider.
+// If resourceIDs is empty, only the cluster resource is imported, with the ID derived from the configuration.
+// If some resources cannot be imported, the ClusterInfo of the imported ones is returned together with an ImportError.
+func (t *Terraform) Import(p types.ProviderType, cfg map[string]interface{}, resourceIDs map[string]string) (*types.ClusterInfo, error) {
+	return t.ImportWithContext(context.Background(), p, cfg, resourceIDs)
+}
+
+// ImportWithContext works as Import but stops terraform gracefully when the given context is done.
+func (t *Terraform) ImportWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}, resourceIDs map[string]string) (_ *types.ClusterInfo, err error) {
+	ctx, op, release, err := t.prepare(ctx, p, cfg, readOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release(&err)
+	cfg, clusterDir := op.cfg, op.dir
+
+	if len(resourceIDs) == 0 {
+		if id := clusterID(p, cfg); id != "" {
+			resourceIDs = map[string]string{clusterResource(p): id}
+		} else {
+			return nil, errors.Errorf("the cluster ID of provider %s cannot be derived from the configuration, provide the resource IDs to import", p)
+		}
+	}
+
+	// INIT
+	if err := initProvider(op.ops, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := tfInit(ctx, op.ops, p, cfg, clusterDir); err != nil {
+		return nil, err
+	}
+	if err := initClusterFiles(op.ops, p, cfg, op.ops.Templates[p]); err != nil {
+		return nil, errors.Wrap(err, "Could not initialize cluster data")
+	}
+
+	// IMPORT
+	// sort the addresses to always import in the same order
+	addrs := make([]string, 0, len(resourceIDs))
+	for addr := range resourceIDs {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	importErr := &types.ImportError{Failed: make(map[string]error)}
+	for _, addr := range addrs {
+		if err := tfImport(ctx, op.ops, cfg, clusterDir, addr, resourceIDs[addr]); err != nil {
+			importErr.Failed[addr] = err
+			continue
+		}
+		importErr.Imported = append(importErr.Imported, addr)
+	}
+	if len(importErr.Imported) == 0 {
+		return nil, importErr
+	}
+
+	// refresh to get the outputs of the imported resources into the state
+	if err := tfRefresh(ctx, op.ops, types.ImportPhase, p, cfg, clusterDir); err != nil {
+		return t.partialClusterInfo(op.ops, op.project, op.cluster, p), errors.Wrap(err, "could not refresh the state of the imported resources")
+	}
+
+	sf, err := loadState(op.ops, t.states, op.project, op.cluster, p)
+	if err != nil {
 		return nil, err
 	}
-	return clusterInfoFromFile(t.ops.DataDir(), cfg["project"].(string), cfg["cluster_name"].(string), p)
+	info, err := clusterInfo(ctx, sf, p, cfg)
+	if missing := missingOutputs(op.ops, sf, p); len(missing) > 0 && len(importErr.Failed) == 0 {
+		// the state was refreshed already, the outputs depend on resources that are not part of the import
+		return incompleteClusterInfo(sf), &types.IncompleteStateError{Outputs: missing}
+	}
+	if len(importErr.Failed) > 0 {
+		info.Status.Phase = types.Errored
+		return info, importErr
+	}
+	return info, err
 }
 
 // Status checks the current state of the cluster from the file
+// The cluster is only reported as provisioned if all its resources are in the state and healthy, run Refresh first to detect the ones removed outside of terraform.
 func (t *Terraform) Status(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterStatus, error) {
-	applyTimeouts(cfg, t.ops.Timeouts)
+	return t.StatusWithContext(context.Background(), sf, p, cfg)
+}
+
+// StatusWithContext works as Status but returns the context error if the given context is already done.
+func (t *Terraform) StatusWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (_ *types.ClusterStatus, err error) {
+	defer t.observe(statusMetric, p, time.Now(), &err)
+
+	cfg, removeCredentials, err := t.credentials(ctx, p, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer t.removeFiles(&err, removeCredentials)
+
+	if err := t.preflight(p, cfg); err != nil {
+		return nil, err
+	}
+	applyTimeouts(cfg, t.ops.Timeouts, readOperation)
 
 	cs := &types.ClusterStatus{
 		Phase: types.Unknown,
 	}
-	var err error
+	if err := ctx.Err(); err != nil {
+		return cs, err
+	}
 
 	// if no state given, try the file system
 	if sf == nil {
-		sf, err = stateFromFile(t.ops.DataDir(), cfg["project"].(string), cfg["cluster_name"].(string), p)
+		sf, err = loadState(t.ops, t.states, cfg["project"].(string), cfg["cluster_name"].(string), p)
 		if err != nil {
 			return cs, errors.Wrap(err, "no state provided, attempted to load from file")
 		}
 	}
 
-	if sf.State.HasResources() {
-		cs.Phase = types.Provisioned
+	if !sf.State.HasResources() {
+		return cs, nil
 	}
+	cs.Phase = types.Provisioned
 
+	// the resources of custom templates are unknown, having any is the best hint
+	if _, ok := t.ops.Templates[p]; ok {
+		return cs, nil
+	}
+	ds, err := detailedStatus(sf, p, cfg)
+	if err != nil {
+		return cs, err
+	}
+	cs.Phase = ds.Phase
 	return cs, nil
 }
 
-// Delete removes an existing cluster or returns an error if removing the cluster is not possible.
-func (t *Terraform) Delete(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
-	applyTimeouts(cfg, t.ops.Timeouts)
+// ClusterInfo returns the ClusterInfo of a provisioned cluster from its stored state, without running any terraform command.
+// It fails with ErrStateNotFound if there is no state of the cluster or the state has no resources, such as after deprovisioning,
+// so callers can tell a cluster that was never provisioned from one whose info could not be read.
+func (t *Terraform) ClusterInfo(p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	return t.ClusterInfoWithContext(context.Background(), p, cfg)
+}
 
-	// silence stdErr during terraform execution, plugins send debug and trace entries there
-	stderr := os.Stderr
-	var err error
-	os.Stderr, err = os.Open(os.DevNull)
+// ClusterInfoWithContext works as ClusterInfo but uses the given context to get the credentials of the provider and the kubeconfig.
+// It fails right away if the context is already done, the state is read without it.
+func (t *Terraform) ClusterInfoWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (_ *types.ClusterInfo, err error) {
+	cfg, removeCredentials, err := t.credentials(ctx, p, cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() { os.Stderr = stderr }()
+	defer t.removeFiles(&err, removeCredentials)
 
-	// init cluster files
-	if !t.ops.Persistent {
-		// remove all files if not persistent after running
-		defer cleanup(t.ops.DataDir(), cfg["project"].(string), cfg["cluster_name"].(string), p)
+	if err := t.preflight(p, cfg); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	clusterDir, err := clusterDir(t.ops.DataDir(), cfg["project"].(string), cfg["cluster_name"].(string), p)
+	sf, err := t.lockedState(cfg["project"].(string), cfg["cluster_name"].(string), p)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if sf.State == nil || !sf.State.HasResources() {
+		return nil, errors.Wrapf(types.ErrStateNotFound, "the state of cluster %s has no resources", cfg["cluster_name"])
 	}
 
-	// INIT
-	if p == types.Gardener {
-		if err := initGardenerProvider(); err != nil {
-			return errors.Wrap(err, "could not initialize the gardener provider")
-		}
+	if missing := missingOutputs(t.ops, sf, p); len(missing) > 0 {
+		// reading the info does not run terraform, Refresh evaluates the outputs again
+		return nil, errors.Wrap(&types.IncompleteStateError{Outputs: missing}, "refresh the cluster to get its outputs")
 	}
-	if err := tfInit(t.ops, p, cfg, clusterDir); err != nil {
-		return err
+	info, err := clusterInfo(ctx, sf, p, cfg)
+	if err != nil {
+		return nil, err
 	}
-	if err := initClusterFiles(t.ops.DataDir(), p, cfg); err != nil {
-		return errors.Wrap(err, "Could not initialize cluster data")
+	return info, nil
+}
+
+// StatusDetailed reports the health of the control plane and each node pool of the cluster, so that a half-provisioned cluster is not taken as provisioned.
+// The state is refreshed first to get the current condition of the resources from the provider.
+// If the state is nil, StatusDetailed will attempt to load the state from the file system.
+func (t *Terraform) StatusDetailed(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.DetailedStatus, error) {
+	return t.StatusDetailedWithContext(context.Background(), sf, p, cfg)
+}
+
+// StatusDetailedWithContext works as StatusDetailed but stops terraform gracefully when the given context is done.
+func (t *Terraform) StatusDetailedWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.DetailedStatus, error) {
+	sf, err := t.RefreshWithContext(ctx, sf, p, cfg)
+	if err != nil {
+		return nil, err
 	}
+	return detailedStatus(sf, p, cfg)
+}
 
-	// if no state given, check if it is already in the file system
+// Refresh updates the state of the cluster with the current condition of its resources, without making any changes to them.
+// Resources that do not exist anymore are removed from the state, so Status does not report the cluster as provisioned afterwards.
+// If the state is nil, Refresh will attempt to load the state from the file system.
+func (t *Terraform) Refresh(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*statefile.File, error) {
+	return t.RefreshWithContext(context.Background(), sf, p, cfg)
+}
+
+// RefreshWithContext works as Refresh but stops terraform gracefully when the given context is done.
+func (t *Terraform) RefreshWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (_ *statefile.File, err error) {
+	ctx, op, release, err := t.prepare(ctx, p, cfg, readOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release(&err)
+	cfg, clusterDir := op.cfg, op.dir
+
+	// if no state given, try the file system
 	if sf == nil {
-		_, err := stateFromFile(t.ops.DataDir(), cfg["project"].(string), cfg["cluster_name"].(string), p)
+		sf, err = loadState(op.ops, t.states, op.project, op.cluster, p)
 		if err != nil {
-			return errors.Wrap(err, "no state provided, attempted to load from file")
+			return nil, errors.Wrap(err, "no state provided, attempted to load from file")
 		}
 	} else {
-		// otherwise save the state into a file so terraform can use it
-		if err := stateToFile(sf, t.ops.DataDir(), cfg["project"].(string), cfg["cluster_name"].(string), p); err != nil {
-			return errors.Wrap(err, "could not store state into file")
+		// otherwise save the state into a file so terraform can refresh it
+		if err := storeState(op.ops, t.states, sf, op.project, op.cluster, p); err != nil {
+			return nil, errors.Wrap(err, "could not store state into file")
+		}
+	}
+	// nothing to refresh
+	if !sf.State.HasResources() {
+		return sf, nil
+	}
+
+	// INIT
+	if err := initProvider(op.ops, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := tfInit(ctx, op.ops, p, cfg, clusterDir); err != nil {
+		return nil, err
+	}
+	if err := initClusterFiles(op.ops, p, cfg, op.ops.Templates[p]); err != nil {
+		return nil, errors.Wrap(err, "Could not initialize cluster data")
+	}
+
+	// REFRESH
+	if err := tfRefresh(ctx, op.ops, types.RefreshPhase, p, cfg, clusterDir); err != nil {
+		return nil, errors.Wrap(err, "could not refresh the state of the cluster resources")
+	}
+
+	return loadState(op.ops, t.states, op.project, op.cluster, p)
+}
+
+// Delete removes an existing cluster or returns an error if removing the cluster is not possible.
+// With the ForceDelete option, it also succeeds when the cluster resources do not exist anymore and removes their state.
+// It fails with ErrIdentityMismatch if the cluster in the state has another provider, project, name or, if the configuration has a "cluster_id", another ID,
+// unless the AllowIdentityMismatch option is set.
+// On Azure, an existing resource group of the configuration, with create_resource_group set to false, is never deleted, only the cluster resources inside it.
+func (t *Terraform) Delete(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
+	return t.DeleteWithContext(context.Background(), sf, p, cfg)
+}
+
+// DeleteWithContext works as Delete but stops terraform gracefully when the given context is done.
+func (t *Terraform) DeleteWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
+	_, err := t.destroy(ctx, sf, p, cfg, nil)
+	return err
+}
+
+// destroy destroys the given targets of the cluster, or the whole cluster if there are none.
+// It returns the state of the resources that are not targeted once they are destroyed, nil for the whole cluster.
+func (t *Terraform) destroy(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, targets []string) (_ *statefile.File, err error) {
+	ctx, op, release, err := t.prepare(ctx, p, cfg, deleteOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release(&err)
+	cfg, rep, clusterDir := op.cfg, op.rep, op.dir
+
+	// INIT
+	if err := rep.phase(types.InitPhase, func() error {
+		if err := initProvider(op.ops, p, cfg); err != nil {
+			return err
+		}
+		if err := tfInit(ctx, op.ops, p, cfg, clusterDir); err != nil {
+			return err
+		}
+		return errors.Wrap(initClusterFiles(op.ops, p, cfg, op.ops.Templates[p]), "Could not initialize cluster data")
+	}); err != nil {
+		return nil, err
+	}
+
+	// if no state given, check if it is already in the file system
+	given := sf != nil
+	if !given {
+		sf, err = loadState(op.ops, t.states, op.project, op.cluster, p)
+		if op.ops.ForceDelete && len(targets) == 0 && errors.Is(err, types.ErrStateNotFound) {
+			// nothing was ever created or it was already forgotten
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "no state provided, attempted to load from file")
+		}
+	}
+
+	// never destroy the cluster of a state that belongs to another configuration, nor overwrite the state of the configured one with it
+	if !op.ops.AllowIdentityMismatch {
+		recorded, err := recordedIdentity(t.ops, t.states, p, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkIdentity(sf, p, cfg, recorded); err != nil {
+			return nil, err
+		}
+	}
+
+	// a resource group of the state that the configuration declares as existing stays, only the cluster resources inside it are destroyed
+	if released := releaseResourceGroup(sf, p, cfg); released != nil {
+		sf, given = released, true
+	}
+
+	if len(targets) > 0 {
+		if err := checkTargets(op.ops, sf, p, targets); err != nil {
+			return nil, err
+		}
+		// the state of the remaining resources is removed with the files of the cluster without the Persistent option, so it is returned and the files are kept
+		op.keepFiles = true
+	}
+
+	if given {
+		// save the given state into a file so terraform can use it
+		if err := storeState(op.ops, t.states, sf, op.project, op.cluster, p); err != nil {
+			return nil, errors.Wrap(err, "could not store state into file")
 		}
 	}
 
 	// APPLY
-	if err := tfDestroy(t.ops, p, cfg, clusterDir); err != nil {
+	rep.resourcesBefore(op.project, op.cluster, p)
+	defer rep.resourcesAfter(op.project, op.cluster, p)
+	if err := rep.phase(types.DestroyPhase, func() error {
+		return retry(ctx, op.ops, func() error { return tfDestroy(ctx, op.ops, p, cfg, clusterDir, targets...) })
+	}); err != nil {
+		// only resources that are already gone can be forgotten, any other failure must not be hidden,
+		// and the state of the resources that are not targeted must be kept
+		var gone *resourcesGoneError
+		if !op.ops.ForceDelete || len(targets) > 0 || !errors.As(err, &gone) {
+			return nil, err
+		}
+		if err := forgetState(op.ops, t.states, op.project, op.cluster, p); err != nil {
+			return nil, errors.Wrap(err, "could not remove the state of the deleted cluster")
+		}
+	}
+	if len(targets) > 0 {
+		sf, err := loadState(op.ops, t.states, op.project, op.cluster, p)
+		return sf, errors.Wrap(err, "could not load the state of the remaining resources")
+	}
+	return nil, forgetIdentity(t.ops, t.states, p, cfg)
+}
+
+// List returns the clusters tracked in the data dir and whether each of them has a usable state.
+// Clusters only appear in the data dir while their files are kept, so use the Persistent option to track them.
+// Clusters cannot be listed with a path strategy, their project and name cannot be derived from the directories.
+func (t *Terraform) List() ([]types.ClusterRef, error) {
+	if t.ops.PathStrategy != nil {
+		return nil, errors.New("the clusters cannot be listed with a custom path strategy")
+	}
+	refs, err := clusterRefs(t.ops.DataDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list the clusters in the data dir")
+	}
+
+	for i, ref := range refs {
+		sf, err := loadState(t.ops, t.states, ref.Project, ref.Name, ref.Provider)
+		refs[i].HasState = err == nil && sf.State != nil && sf.State.HasResources()
+	}
+	return refs, nil
+}
+
+// Cleanup removes all files of the cluster from the data dir, including its state if it is not stored in a remote backend.
+// With the InMemoryState option, the state of the cluster is also removed from memory.
+// Use it to purge the files of clusters managed with the Persistent option.
+// It removes as many files as possible and returns a CleanupError listing the ones left on disk.
+// It fails with ErrLocked while another operation is working on the cluster.
+func (t *Terraform) Cleanup(p types.ProviderType, cfg map[string]interface{}) error {
+	project, ok := cfg["project"].(string)
+	if !ok || project == "" {
+		return errors.New("the project is needed to clean up the cluster files")
+	}
+	cluster, ok := cfg["cluster_name"].(string)
+	if !ok || cluster == "" {
+		return errors.New("the cluster_name is needed to clean up the cluster files")
+	}
+	unlock, err := lockCluster(t.ops, project, cluster, p)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if t.ops.InMemoryState {
+		if err := t.states.remove(t.ops, project, cluster, p); err != nil {
+			return err
+		}
+	}
+	return cleanup(t.ops, project, cluster, p)
+}
+
+// removeFiles runs the given removal once an operation finishes.
+// Files left on disk, such as the state or credentials, must not go unnoticed: a removal error is logged
+// and returned when the operation succeeded, or added to the error of the operation otherwise.
+func (t *Terraform) removeFiles(err *error, remove func() error) {
+	rerr := remove()
+	if rerr == nil {
+		return
+	}
+	if t.ops.Logger != nil {
+		t.ops.Logger.Printf("[ERROR] %s", rerr)
+	}
+	if *err == nil {
+		*err = rerr
+		return
+	}
+	*err = errors.Wrapf(*err, "%s after the operation failed", rerr)
+}
+
+// preflight checks that an operation can run with the given configuration before running any terraform command.
+func (t *Terraform) preflight(p types.ProviderType, cfg map[string]interface{}) error {
+	if err := checkTerraformVersion(t.ops.TerraformVersion); err != nil {
+		return err
+	}
+	if t.ops.InMemoryState && t.ops.Sandbox && t.ops.Persistent && t.ops.Backend == nil {
+		// the in-memory states are kept by the directory of their cluster, which the operations in a sandbox do not use
+		return errors.New("the in-memory state cannot be used with the Sandbox option")
+	}
+	if t.ops.UseWorkspace && t.ops.Backend == nil {
+		// the local state of each cluster is already isolated in its directory
+		return errors.New("terraform workspaces can only be used with a backend")
+	}
+	if len(t.ops.StateEncryptionKey) > 0 {
+		if _, err := stateCipher(t.ops.StateEncryptionKey); err != nil {
+			return err
+		}
+	}
+	if err := varFilesError(t.ops); err != nil {
 		return err
 	}
+	if err := providerVersionsError(t.ops); err != nil {
+		return err
+	}
+	if err := terraformLogLevelError(t.ops); err != nil {
+		return err
+	}
+	if n := parallelism(t.ops, p); n < 0 {
+		// terraform would only fail once apply runs
+		return errors.Errorf("the parallelism must be at least 1, got %d", n)
+	}
+	if _, ok := t.ops.Templates[p]; ok {
+		return validateTemplateConfig(p, cfg)
+	}
+	return validateConfig(p, cfg)
+}
+
+// clusterInfo returns the ClusterInfo of the given state including the kubeconfig to access the cluster.
+// If the kubeconfig cannot be fetched, the ClusterInfo is still returned along with the error.
+func clusterInfo(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	info, err := clusterInfoFromState(sf)
+	if err != nil {
+		return info, err
+	}
+	if info.Kubeconfig, err = kubeconfig(ctx, sf, p, cfg, info); err != nil {
+		return info, errors.Wrap(err, "could not get the kubeconfig of the cluster")
+	}
+	return info, nil
+}
+
+// partialClusterInfo returns the ClusterInfo of a failed or interrupted operation derived from whatever state terraform persisted.
+// Since the cluster was not fully provisioned its phase is always errored. If there is no state at all, nil is returned.
+func (t *Terraform) partialClusterInfo(ops Options, project, cluster string, p types.ProviderType) *types.ClusterInfo {
+	sf, err := loadState(ops, t.states, project, cluster, p)
+	if err != nil {
+		return nil
+	}
+	info, _ := clusterInfoFromState(sf)
+	if info == nil {
+		return nil
+	}
+	info.Status.Phase = types.Errored
+	return info
+}
+
+// initProvider runs the provider specific initialization needed before running terraform.
+// Its requests to the providers use the proxy of the options, if any.
+func initProvider(ops Options, p types.ProviderType, cfg map[string]interface{}) error {
+	transport := proxyTransport(ops)
+	switch p {
+	case types.Gardener:
+		if ops.SkipGardenerInit {
+			// the host application installed the provider plugin
+			return nil
+		}
+		if err := initGardenerProvider(transport); err != nil {
+			// the plugin is downloaded by hydroform, not by terraform init
+			return errors.Wrapf(types.ErrTerraformNotFound, "could not install the gardener provider plugin: %s", err)
+		}
+	case types.AWS:
+		if err := validateAWSCredentials(cfg); err != nil {
+			return errors.Wrap(err, "could not initialize the aws provider")
+		}
+	case types.OpenStack:
+		if err := initOpenStackProvider(cfg); err != nil {
+			return errors.Wrap(err, "could not initialize the openstack provider")
+		}
+	case types.DigitalOcean:
+		if err := initDigitalOceanProvider(transport, cfg); err != nil {
+			return errors.Wrap(err, "could not initialize the digitalocean provider")
+		}
+	case types.AliCloud:
+		if err := initAliCloudProvider(transport, cfg); err != nil {
+			return errors.Wrap(err, "could not initialize the alicloud provider")
+		}
+	}
 	return nil
 }
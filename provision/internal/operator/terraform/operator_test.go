@@ -0,0 +1,173 @@
+package terraform
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	pkgErrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestList(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-list-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tf := New(WithDataDir(dir))
+
+	refs, err := tf.List()
+	require.NoError(t, err)
+	require.Empty(t, refs, "An empty data dir should have no clusters")
+
+	// a cluster with resources in its state
+	state := states.NewState()
+	state.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_cluster", Name: "gke_cluster"}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte("{}")},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+	)
+	state.RootModule().SetOutputValue("endpoint", cty.StringVal("1.2.3.4"), false)
+	require.NoError(t, stateToFile(statefile.New(state, "", 0), options(WithDataDir(dir)), "my-project", "with-state", types.GCP))
+
+	// a cluster without state and a stray file
+	_, err = clusterDir(options(WithDataDir(dir)), "my-project", "without-state", types.Azure)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "clusters", "stray"), []byte{}, 0600))
+
+	refs, err = tf.List()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []types.ClusterRef{
+		{Provider: types.GCP, Project: "my-project", Name: "with-state", HasState: true},
+		{Provider: types.Azure, Project: "my-project", Name: "without-state", HasState: false},
+	}, refs)
+}
+
+func TestCreateContinuesFailedApply(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-continue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the apply fails while the upstream state the outputs come from does not exist, as a provider would on a quota,
+	// timestamp is only known during the apply, so the upstream state is not read before
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+variable "upstream" {}
+
+data "terraform_remote_state" "upstream" {
+  backend = "local"
+  config  = { path = "${var.upstream}${substr(timestamp(), 0, 0)}" }
+}
+
+output "endpoint" { value = data.terraform_remote_state.upstream.outputs.endpoint }
+output "kubeconfig" { value = "kubeconfig" }
+`)}}
+	upstream := filepath.Join(dir, "upstream.tfstate")
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "upstream": upstream}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl))
+
+	_, err = tf.Create(types.Kind, cfg)
+	require.Error(t, err)
+	sf, err := stateFromFile(tf.ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err, "The state of the failed apply should be kept without the Persistent option")
+
+	upstreamState := states.NewState()
+	upstreamState.RootModule().SetOutputValue("endpoint", cty.StringVal("https://example.com"), false)
+	f, err := os.Create(upstream)
+	require.NoError(t, err)
+	require.NoError(t, statefile.Write(statefile.New(upstreamState, "upstream", 1), f))
+	require.NoError(t, f.Close())
+
+	info, err := tf.Create(types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", info.Endpoint)
+	require.Equal(t, sf.Lineage, info.TerraformState().Lineage, "The second Create should continue from the state of the failed one")
+	_, err = stateFromFile(tf.ops, "my-project", "my-cluster", types.Kind)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "The files should be cleaned up once the apply succeeds")
+}
+
+func TestClusterInfo(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-info")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, fstest.MapFS{}))
+
+	_, err = tf.ClusterInfo(types.Kind, cfg)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "A cluster that was never provisioned should have no state")
+
+	require.NoError(t, stateToFile(statefile.New(states.NewState(), "", 0), tf.ops, "my-project", "my-cluster", types.Kind))
+	info, err := tf.ClusterInfo(types.Kind, cfg)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "A deprovisioned cluster should have no info")
+	require.Nil(t, info)
+
+	sf := clusterState("null_resource", "cluster", "null", `{"id": "1"}`)
+	sf.State.RootModule().SetOutputValue("endpoint", cty.StringVal("https://example.com"), false)
+	sf.State.RootModule().SetOutputValue("kubeconfig", cty.StringVal("kubeconfig"), true)
+	require.NoError(t, stateToFile(sf, tf.ops, "my-project", "my-cluster", types.Kind))
+
+	info, err = tf.ClusterInfo(types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", info.Endpoint)
+	require.Equal(t, "kubeconfig", info.Kubeconfig)
+	require.Equal(t, types.Provisioned, info.Status.Phase)
+	require.NotNil(t, info.TerraformState())
+}
+
+func TestCleanup(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-cleanup-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tf := New(WithDataDir(dir), Persistent())
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+
+	clDir, err := clusterDir(options(WithDataDir(dir)), "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(clDir, ".terraform", "plugins"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(clDir, tfStateFile), []byte("{}"), 0600))
+
+	require.NoError(t, tf.Cleanup(types.GCP, cfg))
+	_, err = os.Stat(clDir)
+	require.True(t, os.IsNotExist(err), "The cluster dir should be removed")
+
+	require.NoError(t, tf.Cleanup(types.GCP, cfg), "Cleaning up a cluster without files should succeed")
+	require.Error(t, tf.Cleanup(types.GCP, map[string]interface{}{"project": "my-project"}), "Cleanup should fail without cluster name")
+}
+
+func TestRemoveFiles(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	tf := New(WithLogger(log.New(&logs, "", 0)))
+	cleanupErr := &types.CleanupError{Failed: map[string]error{"/tmp/credentials": errors.New("permission denied")}}
+
+	var err error
+	tf.removeFiles(&err, func() error { return nil })
+	require.NoError(t, err)
+
+	tf.removeFiles(&err, func() error { return cleanupErr })
+	require.Equal(t, cleanupErr, err, "The cleanup error should be returned when the operation succeeded")
+	require.Contains(t, logs.String(), "/tmp/credentials", "The cleanup error should be logged")
+
+	err = types.ErrTimeout
+	tf.removeFiles(&err, func() error { return cleanupErr })
+	require.True(t, errors.Is(err, types.ErrTimeout), "The operation error should be kept")
+	require.Contains(t, err.Error(), "/tmp/credentials", "The cleanup error should be added to the operation error")
+	require.Equal(t, types.ErrTimeout, pkgErrors.Cause(err))
+}
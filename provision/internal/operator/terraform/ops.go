@@ -2,9 +2,13 @@ package terraform
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/hashicorp/terraform-svchost/disco"
 	"github.com/hashicorp/terraform/command"
@@ -13,6 +17,7 @@ import (
 	"github.com/hashicorp/terraform/plugin/discovery"
 	"github.com/kyma-incubator/hydroform/provision/types"
 	"github.com/mitchellh/colorstring"
+	"k8s.io/client-go/kubernetes"
 
 	hashiCli "github.com/mitchellh/cli"
 )
@@ -31,6 +36,95 @@ type Options struct {
 
 	// Print terraform log for debugging
 	Verbose bool
+
+	// Backend is the remote backend where the cluster state is stored. If nil, the state is stored in the data dir.
+	Backend *types.BackendConfig
+
+	// Logger receives the output of the terraform commands at debug level. If nil, the output is discarded.
+	Logger types.Logger
+
+	// OutputWriter receives the raw output of the terraform commands, regardless of Verbose. If nil, the output is discarded.
+	OutputWriter io.Writer
+
+	// ProgressHandler receives the progress events of the terraform commands. It is called one event at a time.
+	ProgressHandler func(types.ProvisionEvent)
+
+	// ReportHandler receives the report of each create and delete operation once it finishes, even if it failed.
+	ReportHandler func(types.OperationReport)
+
+	// UseWorkspace stores the state of each cluster in a terraform workspace of the backend, selected during init.
+	UseWorkspace bool
+	// Workspace is the name of the workspace with UseWorkspace. If empty, it is derived from the project and the cluster name.
+	Workspace string
+
+	// Metrics records the duration and the errors of the create, status and delete operations. If nil, nothing is recorded.
+	Metrics types.MetricsRecorder
+
+	// Retry specifies how apply and destroy are retried on transient provider errors. By default they are not retried.
+	Retry types.Retry
+
+	// ForceDelete makes Delete succeed and remove the cluster state if the destroy only fails because the resources do not exist anymore.
+	ForceDelete bool
+
+	// AllowIdentityMismatch makes Delete destroy the cluster of the state even if it does not match the configuration.
+	AllowIdentityMismatch bool
+
+	// TerraformVersion is a version constraint the embedded terraform has to satisfy. If empty, any version is accepted.
+	TerraformVersion string
+
+	// Credentials are the in-memory credentials of each provider. They are written to a private temporary directory for each operation.
+	Credentials map[types.ProviderType]types.Credentials
+
+	// InMemoryState keeps the cluster states in memory instead of the data dir. Terraform gets them in a file that is removed once each operation finishes.
+	InMemoryState bool
+
+	// StateEncryptionKey encrypts the state files in the data dir at rest. Terraform gets them in plaintext while each command runs.
+	StateEncryptionKey []byte
+
+	// Templates are the terraform templates of each provider supplied by the caller. They replace the built-in modules, see types.WithTemplate.
+	Templates map[types.ProviderType]fs.FS
+
+	// PathStrategy returns the directory of each cluster in the data dir. If nil, it is clusters/<provider>/<project>/<cluster>.
+	PathStrategy types.PathStrategy
+
+	// Parallelism is the parallelism of apply and destroy, ProviderParallelism overrides it for each provider. If zero, terraform uses its default of 10.
+	Parallelism         int
+	ProviderParallelism map[types.ProviderType]int
+
+	// Proxy is the proxy of the provider plugins and of the requests to the providers. If nil, they use the proxy environment variables of the process.
+	Proxy *types.Proxy
+
+	// OperationTimeout limits each whole operation, its terraform commands are stopped once it expires. If zero, operations have no time limit.
+	OperationTimeout time.Duration
+
+	// SecretCredentials is the secret read by each operation for the credentials of its provider, instead of the Credentials.
+	SecretCredentials *types.SecretCredentials
+
+	// SkipGardenerInit skips the installation of the Gardener provider plugin, the caller installed it.
+	SkipGardenerInit bool
+
+	// VarFiles are the terraform variable files of the operations, the vars files of the cluster override their variables.
+	VarFiles []string
+
+	// WakeUpReadyTimeout limits the wait for the API server of a shoot after WakeUp. If zero, it is wakeUpReadyTimeout.
+	WakeUpReadyTimeout time.Duration
+
+	// TargetedUpdate limits the apply of Update to the resources whose configuration changed, unless that leaves their dependents behind.
+	TargetedUpdate bool
+
+	// ProviderVersions are the version constraints of the provider plugins by provider name, rendered into the required_providers of the cluster files.
+	ProviderVersions map[string]string
+
+	// Sandbox runs each operation of the Persistent mode in a new temporary dir, KeepFailedSandbox keeps the ones of the failed operations.
+	// Only the state is copied back after a failure, the resources the operation created before it could not be deleted otherwise.
+	Sandbox           bool
+	KeepFailedSandbox bool
+
+	// TerraformLogLevel is the TF_LOG level of the provider plugins, their log entries are sent to the Logger.
+	TerraformLogLevel string
+
+	// BatchWorkers is the number of clusters provisioned or deprovisioned at the same time by CreateBatch and DeleteBatch.
+	BatchWorkers int
 }
 
 // Option is a function that allows to extensibly configure the terraform operator.
@@ -50,6 +144,20 @@ func WithDataDir(dir string) Option {
 	}
 }
 
+// Cache the provider plugins in the given directory instead of the global plugins directory of terraform.
+func WithPluginCacheDir(dir string) Option {
+	return func(ops *Options) {
+		ops.Meta.PluginCacheDir = dir
+	}
+}
+
+// Place the files of each cluster in the directory returned by the given strategy
+func WithPathStrategy(fn types.PathStrategy) Option {
+	return func(ops *Options) {
+		ops.PathStrategy = fn
+	}
+}
+
 // Make files persistent after using terraform
 func Persistent() Option {
 	return func(ops *Options) {
@@ -57,6 +165,34 @@ func Persistent() Option {
 	}
 }
 
+// Keep the cluster states in memory, no state file is left on disk after an operation
+func WithInMemoryState() Option {
+	return func(ops *Options) {
+		ops.InMemoryState = true
+	}
+}
+
+// Encrypt the state files in the data dir with the given AES key between the operations
+func WithStateEncryption(key []byte) Option {
+	return func(ops *Options) {
+		ops.StateEncryptionKey = key
+	}
+}
+
+// Make Delete succeed when the cluster resources were already deleted
+func ForceDelete() Option {
+	return func(ops *Options) {
+		ops.ForceDelete = true
+	}
+}
+
+// Make Delete destroy the cluster of the state even if it does not match the configuration
+func WithAllowIdentityMismatch() Option {
+	return func(ops *Options) {
+		ops.AllowIdentityMismatch = true
+	}
+}
+
 // Sets operation timeouts
 func WithTimeouts(timeouts types.Timeouts) Option {
 	return func(ops *Options) {
@@ -70,6 +206,188 @@ func Verbose(verbose bool) Option {
 	}
 }
 
+// Store the cluster state in the given remote backend instead of the data dir.
+func WithBackend(backend types.BackendConfig) Option {
+	return func(ops *Options) {
+		ops.Backend = &backend
+	}
+}
+
+// Send the output of the terraform commands to the given logger.
+func WithLogger(l types.Logger) Option {
+	return func(ops *Options) {
+		ops.Logger = l
+	}
+}
+
+// Write the raw output of the terraform commands to the given writer.
+func WithOutputWriter(w io.Writer) Option {
+	return func(ops *Options) {
+		ops.OutputWriter = w
+	}
+}
+
+// Retry apply and destroy on transient provider errors up to maxAttempts times, waiting an exponential backoff between attempts.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(ops *Options) {
+		ops.Retry = types.Retry{MaxAttempts: maxAttempts, Backoff: backoff}
+	}
+}
+
+// Require the embedded terraform to satisfy the given version constraint.
+func WithTerraformVersion(constraint string) Option {
+	return func(ops *Options) {
+		ops.TerraformVersion = constraint
+	}
+}
+
+// Authenticate on the given provider with the given in-memory credentials.
+func WithCredentials(p types.ProviderType, creds types.Credentials) Option {
+	return func(ops *Options) {
+		if ops.Credentials == nil {
+			ops.Credentials = make(map[types.ProviderType]types.Credentials)
+		}
+		ops.Credentials[p] = creds
+	}
+}
+
+// Use the terraform files of fsys for the clusters of the given provider instead of the built-in module.
+func WithTemplate(p types.ProviderType, fsys fs.FS) Option {
+	return func(ops *Options) {
+		if ops.Templates == nil {
+			ops.Templates = make(map[types.ProviderType]fs.FS)
+		}
+		ops.Templates[p] = fsys
+	}
+}
+
+// Report the progress of the terraform commands to the given handler.
+func WithProgressHandler(handler func(types.ProvisionEvent)) Option {
+	return func(ops *Options) {
+		ops.ProgressHandler = handler
+	}
+}
+
+// Send the report of each create and delete operation to the given handler.
+func WithReportHandler(handler func(types.OperationReport)) Option {
+	return func(ops *Options) {
+		ops.ReportHandler = handler
+	}
+}
+
+// Store the cluster states in the given terraform workspace of the backend, or one derived from the project and the cluster name if empty
+func WithWorkspace(name string) Option {
+	return func(ops *Options) {
+		ops.UseWorkspace = true
+		ops.Workspace = name
+	}
+}
+
+// Record the metrics of the operations with the given recorder.
+func WithMetrics(m types.MetricsRecorder) Option {
+	return func(ops *Options) {
+		ops.Metrics = m
+	}
+}
+
+// Limit the number of resources changed at the same time by apply and destroy.
+func WithParallelism(n int) Option {
+	return func(ops *Options) {
+		ops.Parallelism = n
+	}
+}
+
+// Limit the number of resources changed at the same time by apply and destroy on the given provider, instead of the parallelism of all providers.
+func WithProviderParallelism(p types.ProviderType, n int) Option {
+	return func(ops *Options) {
+		if ops.ProviderParallelism == nil {
+			ops.ProviderParallelism = make(map[types.ProviderType]int)
+		}
+		ops.ProviderParallelism[p] = n
+	}
+}
+
+// Limit the time of each whole operation, terraform is stopped once it expires.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(ops *Options) {
+		ops.OperationTimeout = d
+	}
+}
+
+// Authenticate with the credentials of the given Kubernetes Secret, read again by each operation.
+func WithKubernetesSecretCredentials(client kubernetes.Interface, namespace, name string, keyMapping map[string]string) Option {
+	return func(ops *Options) {
+		ops.SecretCredentials = &types.SecretCredentials{Client: client, Namespace: namespace, Name: name, KeyMapping: keyMapping}
+	}
+}
+
+// Skip the installation of the Gardener provider plugin, which the caller installed.
+func WithSkipGardenerInit() Option {
+	return func(ops *Options) {
+		ops.SkipGardenerInit = true
+	}
+}
+
+// Pass the given terraform variable files to the operations, the configuration overrides their variables.
+func WithVarFiles(paths ...string) Option {
+	return func(ops *Options) {
+		ops.VarFiles = append(ops.VarFiles, paths...)
+	}
+}
+
+// Limit the wait for the API server of a shoot to serve requests after WakeUp.
+func WithWakeUpReadyTimeout(d time.Duration) Option {
+	return func(ops *Options) {
+		ops.WakeUpReadyTimeout = d
+	}
+}
+
+// Apply only the resources whose configuration changed and their dependencies in Update.
+func WithTargetedUpdate() Option {
+	return func(ops *Options) {
+		ops.TargetedUpdate = true
+	}
+}
+
+// Constrain the versions of the given provider plugin.
+func WithProviderVersion(provider, constraint string) Option {
+	return func(ops *Options) {
+		if ops.ProviderVersions == nil {
+			ops.ProviderVersions = make(map[string]string)
+		}
+		ops.ProviderVersions[provider] = constraint
+	}
+}
+
+// Run each operation of the Persistent mode in a new temporary dir, keeping the ones of the failed operations with keepFailed.
+func WithSandbox(keepFailed bool) Option {
+	return func(ops *Options) {
+		ops.Sandbox = true
+		ops.KeepFailedSandbox = keepFailed
+	}
+}
+
+// Set the TF_LOG level of the provider plugins and send their log entries to the logger
+func WithTerraformLogLevel(level string) Option {
+	return func(ops *Options) {
+		ops.TerraformLogLevel = level
+	}
+}
+
+// Set the number of clusters provisioned or deprovisioned at the same time in a batch
+func WithBatchWorkers(n int) Option {
+	return func(ops *Options) {
+		ops.BatchWorkers = n
+	}
+}
+
+// Send the outbound traffic of the provider plugins and of the requests to the providers through the given proxies instead of the ones of the environment.
+func WithProxy(httpsProxy, httpProxy, noProxy string) Option {
+	return func(ops *Options) {
+		ops.Proxy = &types.Proxy{HTTPSProxy: httpsProxy, HTTPProxy: httpProxy, NoProxy: noProxy}
+	}
+}
+
 // ToTerraformOptions turns Hydroform options into terraform operator specific options
 func ToTerraformOptions(ops *types.Options) (tfOps []Option) {
 
@@ -81,6 +399,22 @@ func ToTerraformOptions(ops *types.Options) (tfOps []Option) {
 		tfOps = append(tfOps, Persistent())
 	}
 
+	if ops.ForceDelete {
+		tfOps = append(tfOps, ForceDelete())
+	}
+
+	if ops.AllowIdentityMismatch {
+		tfOps = append(tfOps, WithAllowIdentityMismatch())
+	}
+
+	if ops.InMemoryState {
+		tfOps = append(tfOps, WithInMemoryState())
+	}
+
+	if len(ops.StateEncryptionKey) > 0 {
+		tfOps = append(tfOps, WithStateEncryption(ops.StateEncryptionKey))
+	}
+
 	if ops.Timeouts != nil {
 		tfOps = append(tfOps, WithTimeouts(*ops.Timeouts))
 	}
@@ -89,6 +423,110 @@ func ToTerraformOptions(ops *types.Options) (tfOps []Option) {
 		tfOps = append(tfOps, Verbose(ops.Verbose))
 	}
 
+	if ops.Backend != nil {
+		tfOps = append(tfOps, WithBackend(*ops.Backend))
+	}
+
+	if ops.Logger != nil {
+		tfOps = append(tfOps, WithLogger(ops.Logger))
+	}
+
+	if ops.Output != nil {
+		tfOps = append(tfOps, WithOutputWriter(ops.Output))
+	}
+
+	if ops.Retry != nil {
+		tfOps = append(tfOps, WithRetry(ops.Retry.MaxAttempts, ops.Retry.Backoff))
+	}
+
+	if ops.TerraformVersion != "" {
+		tfOps = append(tfOps, WithTerraformVersion(ops.TerraformVersion))
+	}
+
+	if ops.Progress != nil {
+		tfOps = append(tfOps, WithProgressHandler(ops.Progress))
+	}
+
+	if ops.Report != nil {
+		tfOps = append(tfOps, WithReportHandler(ops.Report))
+	}
+
+	if ops.Metrics != nil {
+		tfOps = append(tfOps, WithMetrics(ops.Metrics))
+	}
+
+	if ops.UseWorkspace {
+		tfOps = append(tfOps, WithWorkspace(ops.Workspace))
+	}
+
+	for p, creds := range ops.Credentials {
+		tfOps = append(tfOps, WithCredentials(p, creds))
+	}
+
+	for p, fsys := range ops.Templates {
+		tfOps = append(tfOps, WithTemplate(p, fsys))
+	}
+
+	if ops.PathStrategy != nil {
+		tfOps = append(tfOps, WithPathStrategy(ops.PathStrategy))
+	}
+
+	if ops.PluginCacheDir != "" {
+		tfOps = append(tfOps, WithPluginCacheDir(ops.PluginCacheDir))
+	}
+
+	if ops.Parallelism != 0 {
+		tfOps = append(tfOps, WithParallelism(ops.Parallelism))
+	}
+
+	for p, n := range ops.ProviderParallelism {
+		tfOps = append(tfOps, WithProviderParallelism(p, n))
+	}
+
+	if ops.Proxy != nil {
+		tfOps = append(tfOps, WithProxy(ops.Proxy.HTTPSProxy, ops.Proxy.HTTPProxy, ops.Proxy.NoProxy))
+	}
+
+	if ops.OperationTimeout != 0 {
+		tfOps = append(tfOps, WithOperationTimeout(ops.OperationTimeout))
+	}
+
+	if c := ops.SecretCredentials; c != nil {
+		tfOps = append(tfOps, WithKubernetesSecretCredentials(c.Client, c.Namespace, c.Name, c.KeyMapping))
+	}
+
+	if ops.SkipGardenerInit {
+		tfOps = append(tfOps, WithSkipGardenerInit())
+	}
+
+	if len(ops.VarFiles) > 0 {
+		tfOps = append(tfOps, WithVarFiles(ops.VarFiles...))
+	}
+
+	if ops.WakeUpReadyTimeout != 0 {
+		tfOps = append(tfOps, WithWakeUpReadyTimeout(ops.WakeUpReadyTimeout))
+	}
+
+	if ops.TargetedUpdate {
+		tfOps = append(tfOps, WithTargetedUpdate())
+	}
+
+	for provider, constraint := range ops.ProviderVersions {
+		tfOps = append(tfOps, WithProviderVersion(provider, constraint))
+	}
+
+	if ops.Sandbox {
+		tfOps = append(tfOps, WithSandbox(ops.KeepFailedSandbox))
+	}
+
+	if ops.TerraformLogLevel != "" {
+		tfOps = append(tfOps, WithTerraformLogLevel(ops.TerraformLogLevel))
+	}
+
+	if ops.BatchWorkers != 0 {
+		tfOps = append(tfOps, WithBatchWorkers(ops.BatchWorkers))
+	}
+
 	return tfOps
 }
 
@@ -141,7 +579,6 @@ func options(ops ...Option) Options {
 			Services:            services,
 			RunningInAutomation: true,
 			CLIConfigDir:        configDir,
-			PluginCacheDir:      pluginsDirs[0],
 			OverrideDataDir:     defaultDataDir,
 			ShutdownCh:          makeShutdownCh(),
 		},
@@ -152,6 +589,22 @@ func options(ops ...Option) Options {
 		o(&tfOps)
 	}
 
+	// the sandboxes of the operations are removed with the plugins installed into them, and with a backend each cluster installs its own plugins,
+	// so they get a cache in the data dir
+	if tfOps.PluginCacheDir == "" {
+		tfOps.PluginCacheDir = pluginsDirs[0]
+		if tfOps.Sandbox || tfOps.Backend != nil {
+			tfOps.PluginCacheDir = filepath.Join(tfOps.OverrideDataDir, sandboxPluginCacheDir)
+		}
+	}
+
+	if h, ok := tfOps.Ui.(*HydroUI); ok {
+		h.logger = tfOps.Logger
+		if tfOps.OutputWriter != nil {
+			h.output = &syncWriter{w: tfOps.OutputWriter}
+		}
+	}
+
 	return tfOps
 }
 
@@ -1,7 +1,12 @@
 package terraform
 
 import (
+	"io/fs"
+	"io/ioutil"
+	"log"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/hashicorp/terraform/command"
 	"github.com/kyma-incubator/hydroform/provision/types"
@@ -44,6 +49,7 @@ func TestPersistent(t *testing.T) {
 
 func TestToTerraformOptions(t *testing.T) {
 	t.Parallel()
+	logger := log.New(ioutil.Discard, "", 0)
 	testCases := []struct {
 		Name     string
 		Input    types.Options
@@ -87,6 +93,230 @@ func TestToTerraformOptions(t *testing.T) {
 				Persistent: true,
 			},
 		},
+		{
+			Name: "Only force delete",
+			Input: types.Options{
+				ForceDelete: true,
+			},
+			Expected: Options{
+				ForceDelete: true,
+			},
+		},
+		{
+			Name: "Only backend",
+			Input: types.Options{
+				Backend: &types.BackendConfig{Type: "gcs", Bucket: "my-bucket"},
+			},
+			Expected: Options{
+				Backend: &types.BackendConfig{Type: "gcs", Bucket: "my-bucket"},
+			},
+		},
+		{
+			Name: "Only retry",
+			Input: types.Options{
+				Retry: &types.Retry{MaxAttempts: 3, Backoff: time.Second},
+			},
+			Expected: Options{
+				Retry: types.Retry{MaxAttempts: 3, Backoff: time.Second},
+			},
+		},
+		{
+			Name: "Only logger",
+			Input: types.Options{
+				Logger: logger,
+			},
+			Expected: Options{
+				Logger: logger,
+			},
+		},
+		{
+			Name: "Only output writer",
+			Input: types.Options{
+				Output: ioutil.Discard,
+			},
+			Expected: Options{
+				OutputWriter: ioutil.Discard,
+			},
+		},
+		{
+			Name: "Only credentials",
+			Input: types.Options{
+				Credentials: map[types.ProviderType]types.Credentials{types.GCP: {File: []byte("key")}},
+			},
+			Expected: Options{
+				Credentials: map[types.ProviderType]types.Credentials{types.GCP: {File: []byte("key")}},
+			},
+		},
+		{
+			Name: "Only identity mismatch allowed",
+			Input: types.Options{
+				AllowIdentityMismatch: true,
+			},
+			Expected: Options{
+				AllowIdentityMismatch: true,
+			},
+		},
+		{
+			Name: "Only in-memory state",
+			Input: types.Options{
+				InMemoryState: true,
+			},
+			Expected: Options{
+				InMemoryState: true,
+			},
+		},
+		{
+			Name: "Only state encryption",
+			Input: types.Options{
+				StateEncryptionKey: []byte("0123456789abcdef"),
+			},
+			Expected: Options{
+				StateEncryptionKey: []byte("0123456789abcdef"),
+			},
+		},
+		{
+			Name: "Only workspace",
+			Input: types.Options{
+				UseWorkspace: true,
+				Workspace:    "my-workspace",
+			},
+			Expected: Options{
+				UseWorkspace: true,
+				Workspace:    "my-workspace",
+			},
+		},
+		{
+			Name: "Only plugin cache",
+			Input: types.Options{
+				PluginCacheDir: "/path/to/plugins",
+			},
+			Expected: Options{
+				Meta: command.Meta{
+					PluginCacheDir: "/path/to/plugins",
+				},
+			},
+		},
+		{
+			Name: "Only templates",
+			Input: types.Options{
+				Templates: map[types.ProviderType]fs.FS{types.GCP: fstest.MapFS{}},
+			},
+			Expected: Options{
+				Templates: map[types.ProviderType]fs.FS{types.GCP: fstest.MapFS{}},
+			},
+		},
+		{
+			Name: "Only parallelism",
+			Input: types.Options{
+				Parallelism:         20,
+				ProviderParallelism: map[types.ProviderType]int{types.Gardener: 2},
+			},
+			Expected: Options{
+				Parallelism:         20,
+				ProviderParallelism: map[types.ProviderType]int{types.Gardener: 2},
+			},
+		},
+		{
+			Name: "Only proxy",
+			Input: types.Options{
+				Proxy: &types.Proxy{HTTPSProxy: "http://proxy:3128", NoProxy: "localhost"},
+			},
+			Expected: Options{
+				Proxy: &types.Proxy{HTTPSProxy: "http://proxy:3128", NoProxy: "localhost"},
+			},
+		},
+		{
+			Name: "Only operation timeout",
+			Input: types.Options{
+				OperationTimeout: time.Hour,
+			},
+			Expected: Options{
+				OperationTimeout: time.Hour,
+			},
+		},
+		{
+			Name: "Only secret credentials",
+			Input: types.Options{
+				SecretCredentials: &types.SecretCredentials{Namespace: "kyma", Name: "gcp", KeyMapping: map[string]string{"key.json": "credentials_file_path"}},
+			},
+			Expected: Options{
+				SecretCredentials: &types.SecretCredentials{Namespace: "kyma", Name: "gcp", KeyMapping: map[string]string{"key.json": "credentials_file_path"}},
+			},
+		},
+		{
+			Name: "Only skip Gardener init",
+			Input: types.Options{
+				SkipGardenerInit: true,
+			},
+			Expected: Options{
+				SkipGardenerInit: true,
+			},
+		},
+		{
+			Name: "Only var files",
+			Input: types.Options{
+				VarFiles: []string{"env/dev.tfvars", "env/dev-eu.tfvars.json"},
+			},
+			Expected: Options{
+				VarFiles: []string{"env/dev.tfvars", "env/dev-eu.tfvars.json"},
+			},
+		},
+		{
+			Name: "Only wake-up ready timeout",
+			Input: types.Options{
+				WakeUpReadyTimeout: 5 * time.Minute,
+			},
+			Expected: Options{
+				WakeUpReadyTimeout: 5 * time.Minute,
+			},
+		},
+		{
+			Name: "Only targeted update",
+			Input: types.Options{
+				TargetedUpdate: true,
+			},
+			Expected: Options{
+				TargetedUpdate: true,
+			},
+		},
+		{
+			Name: "Only provider versions",
+			Input: types.Options{
+				ProviderVersions: map[string]string{"google": "~> 3.5", "azurerm": "2.40.0"},
+			},
+			Expected: Options{
+				ProviderVersions: map[string]string{"google": "~> 3.5", "azurerm": "2.40.0"},
+			},
+		},
+		{
+			Name: "Only sandbox",
+			Input: types.Options{
+				Sandbox:           true,
+				KeepFailedSandbox: true,
+			},
+			Expected: Options{
+				Sandbox:           true,
+				KeepFailedSandbox: true,
+			},
+		},
+		{
+			Name: "Only terraform log level",
+			Input: types.Options{
+				TerraformLogLevel: "DEBUG",
+			},
+			Expected: Options{
+				TerraformLogLevel: "DEBUG",
+			},
+		},
+		{
+			Name: "Only batch workers",
+			Input: types.Options{
+				BatchWorkers: 8,
+			},
+			Expected: Options{
+				BatchWorkers: 8,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,73 @@
+package terraform
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// requiredOutputs are the outputs of the built-in templates the ClusterInfo needs to access the cluster.
+// Gardener and Kind clusters are accessed without outputs, and custom templates decide on their own outputs.
+var requiredOutputs = map[types.ProviderType][]string{
+	types.GCP:          {"endpoint", "cluster_ca_certificate"},
+	types.Azure:        {"kube_config"},
+	types.AWS:          {"endpoint", "cluster_ca_certificate", "kubeconfig"},
+	types.OpenStack:    {"endpoint", "cluster_ca_certificate", "kubeconfig"},
+	types.DigitalOcean: {"endpoint", "cluster_ca_certificate", "kubeconfig"},
+	types.AliCloud:     {"endpoint", "cluster_ca_certificate", "kubeconfig"},
+}
+
+// missingOutputs returns the required outputs of the provider that have no value in the given state, in the order of requiredOutputs.
+func missingOutputs(ops Options, sf *statefile.File, p types.ProviderType) []string {
+	if _, ok := ops.Templates[p]; ok {
+		return nil
+	}
+	var missing []string
+	for _, name := range requiredOutputs[p] {
+		if !hasOutput(sf, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// hasOutput returns true if the root module of the given state has a known, non-null value for the output.
+func hasOutput(sf *statefile.File, name string) bool {
+	if sf == nil || sf.State == nil || sf.State.Modules[""] == nil {
+		return false
+	}
+	val, ok := sf.State.Modules[""].OutputValues[name]
+	return ok && val.Value.IsWhollyKnown() && !val.Value.IsNull()
+}
+
+// completeState returns the given state of the cluster after a refresh if it lacks required outputs, such as a state written by an older template
+// or an apply interrupted before terraform saved the outputs. The refresh evaluates the outputs of the template again from the resources,
+// so it needs the initialized cluster directory. If outputs are still missing, the refreshed state is returned with an IncompleteStateError.
+func (t *Terraform) completeState(ctx context.Context, ops Options, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, dir string) (*statefile.File, error) {
+	if len(missingOutputs(ops, sf, p)) == 0 {
+		return sf, nil
+	}
+	if err := tfRefresh(ctx, ops, types.OutputPhase, p, cfg, dir); err != nil {
+		return sf, errors.Wrap(err, "could not refresh the state to get its missing outputs")
+	}
+	sf, err := loadState(ops, t.states, cfg["project"].(string), cfg["cluster_name"].(string), p)
+	if err != nil {
+		return nil, err
+	}
+	if missing := missingOutputs(ops, sf, p); len(missing) > 0 {
+		return sf, &types.IncompleteStateError{Outputs: missing}
+	}
+	return sf, nil
+}
+
+// incompleteClusterInfo returns the ClusterInfo of a state lacking outputs, with an errored phase, so the cluster can still be deleted.
+func incompleteClusterInfo(sf *statefile.File) *types.ClusterInfo {
+	info, _ := clusterInfoFromState(sf)
+	if info == nil {
+		info = &types.ClusterInfo{InternalState: &types.InternalState{TerraformState: sf}}
+	}
+	info.Status = &types.ClusterStatus{Phase: types.Errored}
+	return info
+}
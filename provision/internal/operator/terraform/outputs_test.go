@@ -0,0 +1,70 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestMissingOutputs(t *testing.T) {
+	t.Parallel()
+	sf := clusterState("google_container_cluster", "gke_cluster", "google", `{"id": "1"}`)
+	require.Equal(t, []string{"endpoint", "cluster_ca_certificate"}, missingOutputs(Options{}, sf, types.GCP))
+
+	sf.State.RootModule().SetOutputValue("endpoint", cty.StringVal("1.2.3.4"), false)
+	sf.State.RootModule().SetOutputValue("cluster_ca_certificate", cty.NullVal(cty.String), false)
+	require.Equal(t, []string{"cluster_ca_certificate"}, missingOutputs(Options{}, sf, types.GCP), "Null outputs should be missing")
+
+	sf.State.RootModule().SetOutputValue("cluster_ca_certificate", cty.StringVal("Y2E="), false)
+	require.Empty(t, missingOutputs(Options{}, sf, types.GCP))
+
+	require.Empty(t, missingOutputs(Options{}, nil, types.Gardener), "Gardener clusters are accessed without outputs")
+	require.Empty(t, missingOutputs(options(WithTemplate(types.GCP, fstest.MapFS{})), nil, types.GCP), "Custom templates decide on their outputs")
+}
+
+func TestClusterInfoIncompleteState(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-outputs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := map[string]interface{}{
+		"project":               "my-project",
+		"cluster_name":          "my-cluster",
+		"credentials_file_path": "/path/to/key.json",
+		"location":              "europe-west3-a",
+		"node_count":            3,
+		"machine_type":          "n1-standard-4",
+		"disk_size":             30,
+		"kubernetes_version":    "1.19",
+	}
+	tf := New(WithDataDir(dir))
+	// a state written by an older template, without the certificate of the cluster
+	sf := clusterState("google_container_cluster", "gke_cluster", "google", `{"id": "projects/my-project/locations/europe-west3-a/clusters/my-cluster", "name": "my-cluster", "project": "my-project"}`)
+	sf.State.RootModule().SetOutputValue("endpoint", cty.StringVal("1.2.3.4"), false)
+	require.NoError(t, stateToFile(sf, tf.ops, "my-project", "my-cluster", types.GCP))
+
+	info, err := tf.ClusterInfo(types.GCP, cfg)
+	require.Nil(t, info)
+	require.True(t, errors.Is(err, types.ErrIncompleteState), "%v", err)
+	var stateErr *types.IncompleteStateError
+	require.True(t, errors.As(err, &stateErr))
+	require.Equal(t, []string{"cluster_ca_certificate"}, stateErr.Outputs)
+}
+
+func TestIncompleteClusterInfo(t *testing.T) {
+	t.Parallel()
+	sf := clusterState("google_container_cluster", "gke_cluster", "google", `{"id": "1"}`)
+	sf.State.RootModule().SetOutputValue("endpoint", cty.StringVal("1.2.3.4"), false)
+
+	info := incompleteClusterInfo(sf)
+	require.Equal(t, types.Errored, info.Status.Phase)
+	require.Equal(t, "1.2.3.4", info.Endpoint)
+	require.Equal(t, sf, info.TerraformState(), "The state should be returned, so the cluster can be deleted")
+}
@@ -0,0 +1,146 @@
+package terraform
+
+import (
+	"path/filepath"
+
+	"github.com/hashicorp/terraform/command/jsonplan"
+	"github.com/hashicorp/terraform/plans"
+	"github.com/hashicorp/terraform/plans/planfile"
+	"github.com/hashicorp/terraform/states"
+	tf "github.com/hashicorp/terraform/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// planFromFile loads the plan saved by tfPlan in the given cluster directory.
+func planFromFile(clusterDir string) (*plans.Plan, error) {
+	r, err := planfile.Open(filepath.Join(clusterDir, tfPlanFile))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return r.ReadPlan()
+}
+
+// planJSON marshals the plan saved by tfPlan in the given cluster directory the way 'terraform show -json' does.
+// The plan file holds the configuration and the prior state it was made with, the schemas come from the given providers.
+func planJSON(clusterDir string, pp components) ([]byte, error) {
+	r, err := planfile.Open(filepath.Join(clusterDir, tfPlanFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open the terraform plan")
+	}
+	defer r.Close()
+
+	plan, err := r.ReadPlan()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the terraform plan")
+	}
+	sf, err := r.ReadStateFile()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the prior state of the plan")
+	}
+	config, diags := r.ReadConfig()
+	if diags.HasErrors() {
+		return nil, errors.Wrap(diags.Err(), "could not read the configuration of the plan")
+	}
+
+	schemas, err := tf.LoadSchemas(config, sf.State, pp)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load the schemas of the providers")
+	}
+	data, err := jsonplan.Marshal(config, plan, sf, schemas)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal the plan")
+	}
+	return data, nil
+}
+
+// recreatedResources returns the addresses of the given resources that the plan would destroy and create again.
+// Resources are identified by their type and name, regardless of the module they belong to.
+func recreatedResources(plan *plans.Plan, resources ...string) []string {
+	var recreated []string
+	for _, rc := range plan.Changes.Resources {
+		if !rc.Action.IsReplace() {
+			continue
+		}
+		for _, r := range resources {
+			if rc.Addr.ContainingResource().Resource.String() == r {
+				recreated = append(recreated, rc.Addr.String())
+			}
+		}
+	}
+	return recreated
+}
+
+// clusterPlan summarizes the resource changes of the given plan.
+func clusterPlan(plan *plans.Plan) *types.ClusterPlan {
+	cp := &types.ClusterPlan{}
+	for _, rc := range plan.Changes.Resources {
+		addr := rc.Addr.String()
+		switch {
+		case rc.Action == plans.Create:
+			cp.Add = append(cp.Add, addr)
+		case rc.Action == plans.Update:
+			cp.Change = append(cp.Change, addr)
+		case rc.Action == plans.Delete:
+			cp.Destroy = append(cp.Destroy, addr)
+		case rc.Action.IsReplace():
+			cp.Add = append(cp.Add, addr)
+			cp.Destroy = append(cp.Destroy, addr)
+		}
+	}
+	return cp
+}
+
+// planChanges reports whether applying the plan saved by tfPlan in the given cluster directory would change the resources
+// or the outputs of the cluster with the given stored state. Terraform plans every output as created with the value it has after the refresh,
+// so the planned outputs are compared with the outputs of the stored state.
+func planChanges(clusterDir string, stored *states.State) (bool, error) {
+	plan, err := planFromFile(clusterDir)
+	if err != nil {
+		return false, errors.Wrap(err, "could not read the terraform plan")
+	}
+	if !plan.Changes.Empty() {
+		return true, nil
+	}
+	return outputChanges(plan, stored)
+}
+
+// outputChanges reports whether the planned outputs of the root module differ from the outputs of the given state.
+func outputChanges(plan *plans.Plan, state *states.State) (bool, error) {
+	planned := make(map[string]bool)
+	for _, ocs := range plan.Changes.Outputs {
+		if !ocs.Addr.Module.IsRoot() {
+			continue
+		}
+		oc, err := ocs.Decode()
+		if err != nil {
+			return false, errors.Wrapf(err, "could not decode the planned output %s", ocs.Addr.OutputValue.Name)
+		}
+		name := oc.Addr.OutputValue.Name
+		planned[name] = true
+		var before *states.OutputValue
+		if state != nil {
+			before = state.RootModule().OutputValues[name]
+		}
+		if oc.Action == plans.Delete {
+			if before != nil {
+				return true, nil
+			}
+			continue
+		}
+		if before == nil || !oc.After.IsWhollyKnown() || !oc.After.RawEquals(before.Value) {
+			return true, nil
+		}
+	}
+	// outputs removed from the configuration have no planned change
+	if state != nil {
+		for name := range state.RootModule().OutputValues {
+			if !planned[name] {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
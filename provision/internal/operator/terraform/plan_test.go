@@ -0,0 +1,103 @@
+package terraform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/plans"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecreatedResources(t *testing.T) {
+	t.Parallel()
+	plan := &plans.Plan{
+		Changes: &plans.Changes{
+			Resources: []*plans.ResourceInstanceChangeSrc{
+				testResourceChange("google_container_cluster", "gke_cluster", plans.Update),
+				testResourceChange("google_container_node_pool", "pool", plans.DeleteThenCreate),
+			},
+		},
+	}
+
+	require.Empty(t, recreatedResources(plan, "google_container_cluster.gke_cluster"), "In-place updates should not be reported")
+	require.Equal(t, []string{"google_container_node_pool.pool"}, recreatedResources(plan, "google_container_node_pool.pool"))
+
+	plan.Changes.Resources = append(plan.Changes.Resources, testResourceChange("google_container_cluster", "gke_cluster", plans.CreateThenDelete))
+	require.Equal(t, []string{"google_container_cluster.gke_cluster"}, recreatedResources(plan, "google_container_cluster.gke_cluster"))
+}
+
+func TestClusterPlan(t *testing.T) {
+	t.Parallel()
+	plan := &plans.Plan{
+		Changes: &plans.Changes{
+			Resources: []*plans.ResourceInstanceChangeSrc{
+				testResourceChange("google_container_cluster", "gke_cluster", plans.Create),
+				testResourceChange("google_container_node_pool", "updated", plans.Update),
+				testResourceChange("google_container_node_pool", "replaced", plans.DeleteThenCreate),
+				testResourceChange("google_container_node_pool", "deleted", plans.Delete),
+				testResourceChange("google_container_node_pool", "unchanged", plans.NoOp),
+			},
+		},
+	}
+
+	expected := &types.ClusterPlan{
+		Add:     []string{"google_container_cluster.gke_cluster", "google_container_node_pool.replaced"},
+		Change:  []string{"google_container_node_pool.updated"},
+		Destroy: []string{"google_container_node_pool.replaced", "google_container_node_pool.deleted"},
+	}
+	require.Equal(t, expected, clusterPlan(plan))
+	require.Equal(t, &types.ClusterPlan{}, clusterPlan(&plans.Plan{Changes: plans.NewChanges()}), "An empty plan should have no changes")
+}
+
+func testResourceChange(resourceType, name string, action plans.Action) *plans.ResourceInstanceChangeSrc {
+	return &plans.ResourceInstanceChangeSrc{
+		Addr: addrs.Resource{
+			Mode: addrs.ManagedResourceMode,
+			Type: resourceType,
+			Name: name,
+		}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance),
+		ChangeSrc: plans.ChangeSrc{
+			Action: action,
+		},
+	}
+}
+
+func TestPlanJSON(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-plan-json")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+
+output "endpoint" { value = "https://${var.cluster_name}.example.com" }
+output "token" {
+  value     = "secret"
+  sensitive = true
+}
+`)}}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	data, err := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl)).PlanJSON(types.Kind, cfg)
+	require.NoError(t, err)
+
+	var plan struct {
+		FormatVersion string `json:"format_version"`
+		PlannedValues struct {
+			Outputs map[string]struct {
+				Sensitive bool        `json:"sensitive"`
+				Value     interface{} `json:"value"`
+			} `json:"outputs"`
+		} `json:"planned_values"`
+	}
+	require.NoError(t, json.Unmarshal(data, &plan), "The plan should be JSON: %s", data)
+	require.NotEmpty(t, plan.FormatVersion)
+	require.Equal(t, "https://my-cluster.example.com", plan.PlannedValues.Outputs["endpoint"].Value)
+	require.True(t, plan.PlannedValues.Outputs["token"].Sensitive, "Sensitive outputs should be flagged")
+}
@@ -0,0 +1,51 @@
+package terraform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pluginCacheLockFile is the lock in the plugin cache held while terraform installs the providers into it.
+const pluginCacheLockFile = ".hydroform.lock"
+
+// pluginCacheLockInterval is the time between the attempts to acquire the lock of the plugin cache.
+const pluginCacheLockInterval = 100 * time.Millisecond
+
+// lockPluginCache acquires the lock of the given plugin cache, waiting until the operations of this or other processes holding it are done or the context is done.
+// Terraform writes the plugins into the cache without any locking, so concurrent inits could leave a partially written plugin in it.
+// The lock is released when the returned function is called. Without a plugin cache, there is nothing to lock.
+func lockPluginCache(ctx context.Context, dir string) (func(), error) {
+	if dir == "" {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "could not create the plugin cache")
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, pluginCacheLockFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open the lock file of the plugin cache")
+	}
+	for {
+		locked, err := lockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "could not lock the plugin cache")
+		}
+		if locked {
+			// the lock belongs to the open file, closing it releases the lock
+			return func() { f.Close() }, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, errors.Wrap(ctx.Err(), "could not lock the plugin cache")
+		case <-time.After(pluginCacheLockInterval):
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package terraform
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockPluginCache(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-plugins")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	unlock, err := lockPluginCache(context.Background(), dir)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*pluginCacheLockInterval)
+	defer cancel()
+	_, err = lockPluginCache(ctx, dir)
+	require.True(t, errors.Is(err, context.DeadlineExceeded), "The lock should be waited for until the context is done")
+
+	// a waiting init gets the lock as soon as it is released
+	done := make(chan error)
+	go func() {
+		unlock, err := lockPluginCache(context.Background(), dir)
+		if err == nil {
+			unlock()
+		}
+		done <- err
+	}()
+	time.Sleep(pluginCacheLockInterval)
+	unlock()
+	require.NoError(t, <-done)
+
+	unlock, err = lockPluginCache(context.Background(), "")
+	require.NoError(t, err, "Without a plugin cache there should be nothing to lock")
+	unlock()
+}
@@ -0,0 +1,159 @@
+package terraform
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/terraform/plugin/discovery"
+	"github.com/pkg/errors"
+)
+
+// pluginMux serves a provider configured more than once, with aliases, to terraform.
+// Terraform reattaches to one address per provider, but each configuration of the provider needs a plugin process of its own,
+// so the mux starts a process for each connection terraform opens and forwards the connection to it.
+// The processes are started like the other plugins of the command. Terraform keeps its connections open until the command finished,
+// so the processes are only killed with the mux.
+type pluginMux struct {
+	ops      Options
+	meta     discovery.PluginMeta
+	listener net.Listener
+	// dir is the temporary dir of the socket of the listener, empty on windows
+	dir string
+
+	mu      sync.Mutex
+	clients []*plugin.Client
+	// idle is the first process, started with the mux, until a connection gets it
+	idle   *plugin.Client
+	closed bool
+}
+
+// newPluginMux starts the first process of the given plugin and listens for the connections of terraform.
+func newPluginMux(ops Options, meta discovery.PluginMeta) (*pluginMux, error) {
+	client, err := startPlugin(ops, meta)
+	if err != nil {
+		return nil, err
+	}
+	listener, dir, err := muxListener()
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+	m := &pluginMux{ops: ops, meta: meta, listener: listener, dir: dir, clients: []*plugin.Client{client}, idle: client}
+	go m.serve()
+	return m, nil
+}
+
+// muxListener listens on a unix socket in a new temporary dir, like go-plugin does for the plugins, and on a local port on windows.
+func muxListener() (net.Listener, string, error) {
+	if runtime.GOOS == "windows" {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		return l, "", err
+	}
+	dir, err := ioutil.TempDir("", "hf-plugin")
+	if err != nil {
+		return nil, "", err
+	}
+	l, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+	return l, dir, nil
+}
+
+// reattach returns the reattach config of the first process with the address of the mux.
+// go-plugin waits for the process of the config to exit, the mux kills it last with the others.
+func (m *pluginMux) reattach() *plugin.ReattachConfig {
+	rc := *m.clients[0].ReattachConfig()
+	rc.Addr = m.listener.Addr()
+	return &rc
+}
+
+func (m *pluginMux) serve() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+		go m.forward(conn)
+	}
+}
+
+// forward forwards the connection to a plugin process once terraform sent something over it.
+// go-plugin dials the address once before connecting to check that the plugin still runs, without sending anything, these connections get no process.
+func (m *pluginMux) forward(conn net.Conn) {
+	defer conn.Close()
+
+	buf := make([]byte, 4096)
+	n, _ := conn.Read(buf)
+	if n == 0 {
+		return
+	}
+	client, err := m.process()
+	if err != nil {
+		return
+	}
+	addr := client.ReattachConfig().Addr
+	pconn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		return
+	}
+	defer pconn.Close()
+	if _, err := pconn.Write(buf[:n]); err != nil {
+		return
+	}
+
+	// closing both connections once one side is done ends the other copy
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(pconn, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, pconn)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// process returns the idle first process, or a new one.
+func (m *pluginMux) process() (*plugin.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, errors.New("the plugin mux is closed")
+	}
+	if m.idle != nil {
+		client := m.idle
+		m.idle = nil
+		return client, nil
+	}
+	client, err := startPlugin(m.ops, m.meta)
+	if err != nil {
+		return nil, err
+	}
+	m.clients = append(m.clients, client)
+	return client, nil
+}
+
+// kill stops listening and kills the processes of the mux.
+func (m *pluginMux) kill() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	m.listener.Close()
+	for _, c := range m.clients {
+		c.Kill()
+	}
+	if m.dir != "" {
+		os.RemoveAll(m.dir)
+	}
+}
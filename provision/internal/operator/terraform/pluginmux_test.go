@@ -0,0 +1,79 @@
+package terraform
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/go-plugin"
+	tfplugin "github.com/hashicorp/terraform/plugin"
+	"github.com/hashicorp/terraform/plugin/discovery"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// muxProvider connects to the provider of the given reattach config as terraform does for unmanaged providers.
+func muxProvider(t *testing.T, rc *plugin.ReattachConfig) providers.Interface {
+	client := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig:  tfplugin.Handshake,
+		Plugins:          tfplugin.VersionedPlugins[5],
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+		Reattach:         rc,
+	})
+	rpcClient, err := client.Client()
+	require.NoError(t, err)
+	raw, err := rpcClient.Dispense(tfplugin.ProviderPluginName)
+	require.NoError(t, err)
+	return raw.(providers.Interface)
+}
+
+// providerLabel configures the test provider with the given label and reads it back with the test_provider_label data source.
+func providerLabel(t *testing.T, p providers.Interface, label string) string {
+	schema := p.GetSchema()
+	require.NoError(t, schema.Diagnostics.Err())
+	cfg, err := schema.Provider.Block.CoerceValue(cty.ObjectVal(map[string]cty.Value{"label": cty.StringVal(label)}))
+	require.NoError(t, err)
+	require.NoError(t, p.Configure(providers.ConfigureRequest{Config: cfg}).Diagnostics.Err())
+
+	ds, err := schema.DataSources["test_provider_label"].Block.CoerceValue(cty.EmptyObjectVal)
+	require.NoError(t, err)
+	resp := p.ReadDataSource(providers.ReadDataSourceRequest{TypeName: "test_provider_label", Config: ds})
+	require.NoError(t, resp.Diagnostics.Err())
+	return resp.State.GetAttr("label").AsString()
+}
+
+func TestPluginMux(t *testing.T) {
+	t.Parallel()
+	// the test binary is the plugin of the test provider, see TestMain
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	m, err := newPluginMux(options(), discovery.PluginMeta{Name: "test", Version: "1.0.0", Path: exe})
+	require.NoError(t, err)
+	defer m.kill()
+
+	// the configurations of an aliased provider are used at the same time
+	rc := m.reattach()
+	require.Equal(t, m.listener.Addr(), rc.Addr, "Terraform should connect to the mux")
+	first, second := muxProvider(t, rc), muxProvider(t, rc)
+	require.Equal(t, "first", providerLabel(t, first, "first"))
+	require.Equal(t, "second", providerLabel(t, second, "second"))
+	require.Equal(t, "first", providerLabel(t, first, "first"), "Each connection should keep a plugin of its own")
+
+	m.mu.Lock()
+	clients, idle := append([]*plugin.Client(nil), m.clients...), m.idle
+	m.mu.Unlock()
+	require.Len(t, clients, 2, "The checks of go-plugin before connecting should start no process")
+	require.Nil(t, idle, "The first connection should get the process started with the mux")
+
+	m.kill()
+	for _, c := range clients {
+		require.True(t, c.Exited(), "The processes should be killed with the mux")
+	}
+	_, err = net.Dial(rc.Addr.Network(), rc.Addr.String())
+	require.Error(t, err, "The mux should stop listening")
+	_, err = os.Stat(m.dir)
+	require.True(t, os.IsNotExist(err), "The dir of the socket should be removed")
+	_, err = m.process()
+	require.Error(t, err, "No process should be started once the mux is killed")
+}
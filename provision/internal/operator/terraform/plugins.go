@@ -0,0 +1,292 @@
+package terraform
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/configs/configload"
+	tfplugin "github.com/hashicorp/terraform/plugin"
+	"github.com/hashicorp/terraform/plugin/discovery"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	tf "github.com/hashicorp/terraform/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+)
+
+var (
+	// pluginLogLine matches the lines the clients of the provider plugins log for the stderr of the plugins: the time, the level and the name of the plugin
+	pluginLogLine = regexp.MustCompile(`^\S+ \[(TRACE|DEBUG|INFO|WARN|ERROR)\] +(plugin\.[^:]+): (.*)$`)
+	// pluginLogLevel matches the level tag of the log entries of the plugins, after the time of their own log package
+	pluginLogLevel = regexp.MustCompile(`\[(TRACE|DEBUG|INFO|WARN|ERROR)\] ?`)
+)
+
+// commandPlugins are the provider plugins Hydroform starts for a terraform command, instead of terraform.
+// Terraform starts its plugins with the environment of the process and writes their log to the stderr of the process, both shared by all operations,
+// so the plugins of a command are started with the proxy and the log level of its operation in their environment and with its log writer,
+// see proxyEnv, terraformLogEnv and pluginLogOutput,
+// and passed to terraform as unmanaged providers. Terraform 0.12 has no settings for the environment and the log of the plugins of a command.
+// Providers configured more than once, with aliases, need a plugin process for each configuration, they are served through a pluginMux.
+// Terraform still logs the state of its connections to the plugins, not their log, to the stderr of the process.
+type commandPlugins struct {
+	clients  map[addrs.Provider]*plugin.Client
+	muxes    map[addrs.Provider]*pluginMux
+	killOnce sync.Once
+}
+
+// pluginDigests caches the digests of the plugin executables by path, hashing the big providers before each command takes a while.
+// A digest is used as long as the size and the modification time of its executable are unchanged.
+var pluginDigests = struct {
+	sync.Mutex
+	byPath map[string]pluginDigest
+}{byPath: make(map[string]pluginDigest)}
+
+type pluginDigest struct {
+	size    int64
+	modTime time.Time
+	sum     []byte
+}
+
+// startPlugins starts the plugins of the providers the configuration and the local state in the given dir require, as terraform would choose them.
+// Providers without a suitable plugin are left to terraform, which reports them. It starts no plugins if the configuration cannot be loaded,
+// terraform reports its errors as well. The plugins must be killed once the command finished.
+func startPlugins(ops Options, dir string) *commandPlugins {
+	cp := &commandPlugins{clients: make(map[addrs.Provider]*plugin.Client), muxes: make(map[addrs.Provider]*pluginMux)}
+
+	reqd, aliased, ok := pluginRequirements(ops, dir)
+	if !ok {
+		return cp
+	}
+	reqd.LockExecutables(pluginLock(ops))
+	available, _ := discovery.FindPlugins("provider", pluginDirs(ops)).ValidateVersions()
+	chosen := available.ConstrainVersions(reqd)
+
+	for name, req := range reqd {
+		// the terraform provider is built into terraform
+		if name == "terraform" {
+			continue
+		}
+		metas, ok := chosen[name]
+		if !ok || metas.Count() == 0 {
+			continue
+		}
+		meta := metas.Newest()
+		if digest, err := pluginSHA256(meta); err != nil || !req.AcceptsSHA256(digest) {
+			continue
+		}
+
+		// plugins that do not start, such as the ones of older plugin protocols, are left to terraform as well
+		if aliased[name] {
+			if mux, err := newPluginMux(ops, meta); err == nil {
+				cp.muxes[addrs.NewLegacyProvider(name)] = mux
+			}
+			continue
+		}
+		if client, err := startPlugin(ops, meta); err == nil {
+			cp.clients[addrs.NewLegacyProvider(name)] = client
+		}
+	}
+	return cp
+}
+
+// startPlugin starts a process of the given plugin for a command.
+func startPlugin(ops Options, meta discovery.PluginMeta) (*plugin.Client, error) {
+	client := plugin.NewClient(pluginClientConfig(ops, meta))
+	if _, err := client.Start(); err != nil {
+		client.Kill()
+		return nil, err
+	}
+	return client, nil
+}
+
+// pluginSHA256 returns the digest of the executable of the given plugin, from pluginDigests if it did not change.
+func pluginSHA256(meta discovery.PluginMeta) ([]byte, error) {
+	info, err := os.Stat(meta.Path)
+	if err != nil {
+		return nil, err
+	}
+	pluginDigests.Lock()
+	d, ok := pluginDigests.byPath[meta.Path]
+	pluginDigests.Unlock()
+	if ok && d.size == info.Size() && d.modTime.Equal(info.ModTime()) {
+		return d.sum, nil
+	}
+
+	sum, err := meta.SHA256()
+	if err != nil {
+		return nil, err
+	}
+	pluginDigests.Lock()
+	pluginDigests.byPath[meta.Path] = pluginDigest{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	pluginDigests.Unlock()
+	return sum, nil
+}
+
+// reattach returns the plugins to pass to terraform as unmanaged providers.
+func (cp *commandPlugins) reattach() map[addrs.Provider]*plugin.ReattachConfig {
+	if cp == nil || len(cp.clients)+len(cp.muxes) == 0 {
+		return nil
+	}
+	rc := make(map[addrs.Provider]*plugin.ReattachConfig, len(cp.clients)+len(cp.muxes))
+	for p, c := range cp.clients {
+		rc[p] = c.ReattachConfig()
+	}
+	for p, m := range cp.muxes {
+		rc[p] = m.reattach()
+	}
+	return rc
+}
+
+// kill stops the plugins of the command, once.
+func (cp *commandPlugins) kill() {
+	if cp == nil {
+		return
+	}
+	cp.killOnce.Do(func() {
+		for _, c := range cp.clients {
+			c.Kill()
+		}
+		for _, m := range cp.muxes {
+			m.kill()
+		}
+	})
+}
+
+// pluginRequirements returns the providers the configuration and the local state in the given dir require, and the providers configured with an alias.
+// It returns false if the configuration cannot be loaded.
+func pluginRequirements(ops Options, dir string) (discovery.PluginRequirements, map[string]bool, bool) {
+	// without services, the modules are only read from the modules dir init installed them in
+	loader, err := configload.NewLoader(&configload.Config{ModulesDir: filepath.Join(ops.DataDir(), "modules")})
+	if err != nil {
+		return nil, nil, false
+	}
+	config, diags := loader.LoadConfig(dir)
+	if diags.HasErrors() {
+		return nil, nil, false
+	}
+
+	var state *states.State
+	if f, err := os.Open(filepath.Join(dir, tfStateFile)); err == nil {
+		if sf, err := statefile.Read(f); err == nil {
+			state = sf.State
+		}
+		f.Close()
+	}
+
+	aliased := make(map[string]bool)
+	config.DeepEach(func(c *configs.Config) {
+		for _, pc := range c.Module.ProviderConfigs {
+			if pc.Alias != "" {
+				aliased[pc.Name] = true
+			}
+		}
+	})
+	return tf.ConfigTreeDependencies(config, state).AllPluginRequirements(), aliased, true
+}
+
+// pluginDirs returns the dirs terraform looks for the provider plugins in, the dir init installs them in and the global plugin dirs.
+func pluginDirs(ops Options) []string {
+	return append([]string{filepath.Join(ops.DataDir(), "plugins", fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH))}, ops.GlobalPluginDirs...)
+}
+
+// pluginLock returns the digests of the plugins init installed, which terraform checks before starting them. It is empty if init recorded none.
+func pluginLock(ops Options) map[string][]byte {
+	data, err := ioutil.ReadFile(filepath.Join(pluginDirs(ops)[0], "lock.json"))
+	if err != nil {
+		return nil
+	}
+	digests := make(map[string]string)
+	if err := json.Unmarshal(data, &digests); err != nil {
+		return nil
+	}
+	lock := make(map[string][]byte, len(digests))
+	for name, d := range digests {
+		if b, err := hex.DecodeString(d); err == nil {
+			lock[name] = b
+		}
+	}
+	return lock
+}
+
+// pluginClientConfig returns the configuration of the client of the given plugin, which logs to the writer of the options
+// and gets the proxy and the log level of the options in its environment. Terraform reattaches to the plugin without TLS, so the plugin serves without it as well.
+func pluginClientConfig(ops Options, meta discovery.PluginMeta) *plugin.ClientConfig {
+	cmd := exec.Command(meta.Path)
+	cmd.Env = terraformLogEnv(proxyEnv(os.Environ(), ops.Proxy), ops.TerraformLogLevel)
+	return &plugin.ClientConfig{
+		Cmd:              cmd,
+		HandshakeConfig:  tfplugin.Handshake,
+		VersionedPlugins: tfplugin.VersionedPlugins,
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "plugin",
+			Level:  hclog.Trace,
+			Output: pluginLogOutput(ops),
+		}),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+	}
+}
+
+// pluginLogOutput returns the writer of the log of the provider plugins run with the given options: the stderr of the process with Verbose,
+// the logger with the TerraformLogLevel option, the log is discarded otherwise.
+func pluginLogOutput(ops Options) io.Writer {
+	switch {
+	case ops.Verbose:
+		return os.Stderr
+	case ops.TerraformLogLevel != "" && ops.Logger != nil:
+		return &pluginLogWriter{logger: ops.Logger}
+	}
+	return ioutil.Discard
+}
+
+// pluginLogWriter sends the log entries of the provider plugins to the logger, with the level of each entry.
+// The clients of the plugins write their log from several goroutines, one line at a time.
+type pluginLogWriter struct {
+	logger types.Logger
+	mu     sync.Mutex
+	line   strings.Builder
+}
+
+func (w *pluginLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, b := range p {
+		if b != '\n' {
+			w.line.WriteByte(b)
+			continue
+		}
+		if level, entry, ok := pluginLogEntry(w.line.String()); ok {
+			w.logger.Printf("[%s] %s", level, entry)
+		}
+		w.line.Reset()
+	}
+	return len(p), nil
+}
+
+// pluginLogEntry returns the level and the message of the log entry of a provider plugin in the given log line, prefixed with the name of the plugin.
+// The level is the one of the entry, or the one the client logged it at if the entry has none. The other lines of the clients are not log entries of plugins.
+func pluginLogEntry(line string) (string, string, bool) {
+	m := pluginLogLine.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	level, name, msg := m[1], m[2], m[3]
+	if loc := pluginLogLevel.FindStringSubmatchIndex(msg); loc != nil {
+		level, msg = msg[loc[2]:loc[3]], msg[loc[1]:]
+	}
+	return level, fmt.Sprintf("%s: %s", name, msg), true
+}
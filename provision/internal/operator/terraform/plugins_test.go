@@ -0,0 +1,154 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/terraform/builtin/providers/test"
+	tfplugin "github.com/hashicorp/terraform/plugin"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain serves the test provider of terraform when the test binary is started as a provider plugin, see TestAliasedPlugins.
+func TestMain(m *testing.M) {
+	if os.Getenv(tfplugin.Handshake.MagicCookieKey) == tfplugin.Handshake.MagicCookieValue {
+		log.Printf("[INFO] serving the test provider")
+		tfplugin.Serve(&tfplugin.ServeOpts{ProviderFunc: test.Provider})
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func TestPluginLogEntry(t *testing.T) {
+	t.Parallel()
+	level, entry, ok := pluginLogEntry("2021-03-01T10:00:00.000Z [DEBUG] plugin.terraform-provider-google_v3.5.0_x5: 2021/03/01 10:00:00 [TRACE] Google API Request Details:")
+	require.True(t, ok)
+	require.Equal(t, "TRACE", level, "The level of the entry should be used")
+	require.Equal(t, "plugin.terraform-provider-google_v3.5.0_x5: Google API Request Details:", entry)
+
+	level, entry, ok = pluginLogEntry("2021-03-01T10:00:00.000Z [INFO]  plugin.terraform-provider-kind: configuring server")
+	require.True(t, ok)
+	require.Equal(t, "INFO", level, "Entries without level should get the one of terraform")
+	require.Equal(t, "plugin.terraform-provider-kind: configuring server", entry)
+
+	_, _, ok = pluginLogEntry("2021-03-01T10:00:00.000Z [DEBUG] plugin: starting plugin: path=/plugins/terraform-provider-kind")
+	require.False(t, ok, "The lines of terraform about the plugins are not entries of the plugins")
+	_, _, ok = pluginLogEntry("Error: something failed")
+	require.False(t, ok)
+}
+
+func TestPluginLogWriter(t *testing.T) {
+	t.Parallel()
+	logger := &recordingLogger{}
+	w := &pluginLogWriter{logger: logger}
+
+	_, err := w.Write([]byte("2021-03-01T10:00:00.000Z [DEBUG] plugin.terraform-provider-kind: 2021/03/01 10:00:00 [DEBUG] creating "))
+	require.NoError(t, err)
+	require.Empty(t, logger.logged(), "Entries should only be logged once their line is complete")
+	_, err = w.Write([]byte("cluster\n2021-03-01T10:00:00.000Z [DEBUG] plugin: plugin process exited\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"[DEBUG] plugin.terraform-provider-kind: creating cluster"}, logger.logged(), "Only the entries of the plugins should be logged")
+}
+
+func TestPluginLogOutput(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, ioutil.Discard, pluginLogOutput(options()), "The log of the plugins should be discarded by default")
+	require.Equal(t, ioutil.Discard, pluginLogOutput(options(WithLogger(&recordingLogger{}))), "The logger should only get the log with a log level")
+	require.IsType(t, &pluginLogWriter{}, pluginLogOutput(options(WithLogger(&recordingLogger{}), WithTerraformLogLevel("DEBUG"))))
+	require.Equal(t, os.Stderr, pluginLogOutput(options(Verbose(true))), "The log should be printed with Verbose")
+}
+
+func TestStartPlugins(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-plugins")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ops := options(WithDataDir(dir))
+
+	require.Nil(t, startPlugins(ops, dir).reattach(), "Without a configuration there should be no plugins")
+
+	require.NoError(t, writeTemplate(dir, fstest.MapFS{"main.tf": {Data: []byte(`
+provider "google" {}
+
+provider "aws" {
+  alias = "dns"
+}
+
+data "terraform_remote_state" "network" {
+  backend = "local"
+}
+`)}}))
+	reqd, aliased, ok := pluginRequirements(ops, dir)
+	require.True(t, ok)
+	require.Contains(t, reqd, "google")
+	require.Contains(t, reqd, "terraform")
+	require.Equal(t, map[string]bool{"aws": true}, aliased, "The providers with aliases should be served through a mux")
+
+	cp := startPlugins(ops, dir)
+	require.Nil(t, cp.reattach(), "Plugins that are not installed should be left to terraform")
+	cp.kill()
+	cp.kill()
+}
+
+func TestAliasedPlugins(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-plugins-aliased")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the test binary is the plugin of the test provider
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	pluginDir := filepath.Join(dir, "plugins", fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH))
+	require.NoError(t, os.MkdirAll(pluginDir, 0700))
+	require.NoError(t, os.Symlink(exe, filepath.Join(pluginDir, "terraform-provider-test_v1.0.0_x5")))
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+
+provider "test" {
+  label = "default"
+}
+
+provider "test" {
+  alias = "other"
+  label = "other"
+}
+
+data "test_provider_label" "default" {}
+
+data "test_provider_label" "other" {
+  provider = test.other
+}
+
+output "labels" {
+  value = "${data.test_provider_label.default.label},${data.test_provider_label.other.label}"
+}
+`)}}
+	logger := &recordingLogger{}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), Persistent(), WithLogger(logger), WithTerraformLogLevel("INFO"))
+	_, err = tf.Create(types.Kind, cfg)
+	require.NoError(t, err)
+
+	sf, err := tf.Refresh(nil, types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "default,other", sf.State.RootModule().OutputValues["labels"].Value.AsString(), "Each configuration of the provider should get a plugin of its own")
+
+	var served int
+	for _, l := range logger.logged() {
+		if strings.Contains(l, "serving the test provider") {
+			served++
+		}
+	}
+	require.Greater(t, served, 1, "The log of all the plugins of the aliased provider should be sent to the logger")
+}
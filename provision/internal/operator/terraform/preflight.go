@@ -0,0 +1,224 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// preflightProbe queries the API of a provider for the checks of Preflight.
+type preflightProbe interface {
+	// authenticate checks that the provider accepts the credentials.
+	authenticate(ctx context.Context) error
+	// locate checks that the location of the cluster and its zones exist, and returns the number of CPUs of each of the given machine types.
+	locate(ctx context.Context, machineTypes []string) (map[string]int, error)
+	// quotas returns the quotas of the location the provider reports, by check name.
+	quotas(ctx context.Context) (map[string]quota, error)
+	// machineTypes returns the machine types offered in the location of the cluster, and the location they were listed for.
+	machineTypes(ctx context.Context) ([]string, string, error)
+}
+
+// quota is the amount of a resource a project can still use in the location, and its limit.
+type quota struct {
+	available float64
+	limit     float64
+}
+
+// preflightProbes creates the probe of each provider Preflight supports for the given configuration.
+// Creating the probe fails if the credentials are missing or cannot be read. The probes send their requests with the given transport, or the default one if it is nil.
+var preflightProbes = map[types.ProviderType]func(ctx context.Context, cfg map[string]interface{}, transport http.RoundTripper) (preflightProbe, error){
+	types.GCP:   newGCPProbe,
+	types.Azure: newAzureProbe,
+	types.AWS:   newAWSProbe,
+}
+
+// Preflight checks that the cluster of the given configuration can be provisioned before running the much longer Create:
+// it authenticates to the provider, checks that the location and the machine types exist, and compares the quotas of the location
+// with the CPUs and IP addresses of the nodes. The checks run in order, the ones depending on a failed check are skipped.
+// It returns an error only if the checks cannot run, such as for an invalid configuration, failed checks are part of the result.
+// Preflight is supported on GCP, Azure and AWS with their built-in templates, it returns ErrUnsupportedOperation otherwise.
+func (t *Terraform) Preflight(p types.ProviderType, cfg map[string]interface{}) (*types.PreflightResult, error) {
+	return t.PreflightWithContext(context.Background(), p, cfg)
+}
+
+// PreflightWithContext works as Preflight but stops querying the provider when the given context is done.
+func (t *Terraform) PreflightWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (_ *types.PreflightResult, err error) {
+	cfg, removeCredentials, err := t.credentials(ctx, p, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer t.removeFiles(&err, removeCredentials)
+
+	if err := t.preflight(p, cfg); err != nil {
+		return nil, err
+	}
+	newProbe, ok := preflightProbes[p]
+	if !ok {
+		return nil, errors.Wrapf(types.ErrUnsupportedOperation, "preflight checks are not supported on %s", p)
+	}
+	if _, ok := t.ops.Templates[p]; ok {
+		return nil, errors.Wrap(types.ErrUnsupportedOperation, "the resources of custom templates are unknown, they cannot be checked")
+	}
+
+	result := &types.PreflightResult{}
+	probe, err := newProbe(ctx, cfg, proxyTransport(t.ops))
+	if err != nil {
+		result.Checks = append(result.Checks, failedCheck(types.CredentialsCheck, err))
+	} else {
+		result = runPreflight(ctx, probe, p, cfg)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return skipPreflightChecks(result), nil
+}
+
+// runPreflight runs the checks with the given probe, it stops at the first failed check.
+func runPreflight(ctx context.Context, probe preflightProbe, p types.ProviderType, cfg map[string]interface{}) *types.PreflightResult {
+	result := &types.PreflightResult{}
+	if err := probe.authenticate(ctx); err != nil {
+		result.Checks = append(result.Checks, failedCheck(types.CredentialsCheck, err))
+		return result
+	}
+	result.Checks = append(result.Checks, types.PreflightCheck{Name: types.CredentialsCheck, Status: types.PreflightPassed, Message: fmt.Sprintf("authenticated to %s", p)})
+
+	nodes := requestedNodes(p, cfg)
+	var machineTypes []string
+	for _, n := range nodes {
+		machineTypes = append(machineTypes, n.machineType)
+	}
+	cpus, err := probe.locate(ctx, machineTypes)
+	if err != nil {
+		result.Checks = append(result.Checks, failedCheck(types.LocationCheck, err))
+		return result
+	}
+	result.Checks = append(result.Checks, types.PreflightCheck{Name: types.LocationCheck, Status: types.PreflightPassed, Message: fmt.Sprintf("%s offers the machine types of the cluster", clusterLocation(cfg))})
+
+	quotas, err := probe.quotas(ctx)
+	if err != nil {
+		result.Checks = append(result.Checks, failedCheck(types.CPUQuotaCheck, err))
+		return result
+	}
+
+	requested := map[string]int{}
+	for _, n := range nodes {
+		requested[types.CPUQuotaCheck] += n.count * cpus[n.machineType]
+		requested[types.IPAddressQuotaCheck] += n.count
+	}
+	// private nodes have no external IP address
+	if private, _ := cfg["enable_private_nodes"].(bool); private {
+		requested[types.IPAddressQuotaCheck] = 0
+	}
+	units := map[string]string{types.CPUQuotaCheck: "CPUs", types.IPAddressQuotaCheck: "IP addresses"}
+	for _, name := range []string{types.CPUQuotaCheck, types.IPAddressQuotaCheck} {
+		q, ok := quotas[name]
+		if !ok {
+			continue
+		}
+		check := types.PreflightCheck{Name: name, Status: types.PreflightPassed}
+		if float64(requested[name]) > q.available {
+			check.Status = types.PreflightFailed
+		}
+		check.Message = fmt.Sprintf("the nodes need %d %s, %g of the limit of %g are available", requested[name], units[name], q.available, q.limit)
+		result.Checks = append(result.Checks, check)
+	}
+	return result
+}
+
+// skipPreflightChecks adds the checks that did not run to the result as skipped, so it always lists all checks.
+func skipPreflightChecks(result *types.PreflightResult) *types.PreflightResult {
+	ran := make(map[string]bool)
+	failed := false
+	for _, c := range result.Checks {
+		ran[c.Name] = true
+		failed = failed || c.Status == types.PreflightFailed
+	}
+	for _, name := range []string{types.CredentialsCheck, types.LocationCheck, types.CPUQuotaCheck, types.IPAddressQuotaCheck} {
+		if ran[name] {
+			continue
+		}
+		msg := "the provider does not report this quota"
+		if failed {
+			msg = "an earlier check failed"
+		}
+		result.Checks = append(result.Checks, types.PreflightCheck{Name: name, Status: types.PreflightSkipped, Message: msg})
+	}
+	return result
+}
+
+func failedCheck(name string, err error) types.PreflightCheck {
+	return types.PreflightCheck{Name: name, Status: types.PreflightFailed, Message: err.Error()}
+}
+
+// nodeGroup is a number of nodes of the same machine type requested by a configuration.
+type nodeGroup struct {
+	machineType string
+	count       int
+}
+
+// requestedNodes returns the nodes the configuration provisions: the default nodes and the ones of the node pools.
+// On GCP the node counts are per zone.
+func requestedNodes(p types.ProviderType, cfg map[string]interface{}) []nodeGroup {
+	var nodes []nodeGroup
+	zones := 1
+	switch p {
+	case types.GCP:
+		zones = gcpZoneCount(cfg)
+		nodes = append(nodes, nodeGroup{stringValue(cfg["machine_type"]), numberValue(cfg["node_count"]) * zones})
+	case types.Azure:
+		nodes = append(nodes, nodeGroup{stringValue(cfg["agent_vm_size"]), numberValue(cfg["agent_count"])})
+	case types.AWS:
+		nodes = append(nodes, nodeGroup{stringValue(cfg["machine_type"]), numberValue(cfg["node_count"])})
+	}
+	pools, _ := cfg["node_pools"].([]types.NodePool)
+	for _, pool := range pools {
+		nodes = append(nodes, nodeGroup{pool.MachineType, pool.NodeCount * zones})
+	}
+	return nodes
+}
+
+// gcpZones is the number of zones GKE spreads the nodes of a regional cluster over if the configuration does not choose them.
+const gcpZones = 3
+
+// gcpZoneCount returns the number of zones a GKE cluster has nodes in. A zonal cluster always has nodes in its own zone.
+func gcpZoneCount(cfg map[string]interface{}) int {
+	zones, _ := cfg["availability_zones"].([]string)
+	location := clusterLocation(cfg)
+	switch {
+	case !gcpZone(location) && len(zones) == 0:
+		return gcpZones
+	case !gcpZone(location):
+		return len(zones)
+	case contains(zones, location):
+		return len(zones)
+	default:
+		return len(zones) + 1
+	}
+}
+
+// stringValue returns the given value if it is a string, or an empty string.
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// numberValue returns the given value as an int if it is a number of any type, or 0. Configurations from JSON or YAML have float numbers.
+func numberValue(v interface{}) int {
+	if v == nil {
+		return 0
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int(rv.Float())
+	}
+	return 0
+}
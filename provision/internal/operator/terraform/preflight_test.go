@@ -0,0 +1,191 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	gcompute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// fakeProbe answers the checks of Preflight with fixed values.
+type fakeProbe struct {
+	authErr   error
+	locateErr error
+	cpus      map[string]int
+	quota     map[string]quota
+}
+
+func (f *fakeProbe) authenticate(ctx context.Context) error { return f.authErr }
+
+func (f *fakeProbe) locate(ctx context.Context, machineTypes []string) (map[string]int, error) {
+	return f.cpus, f.locateErr
+}
+
+func (f *fakeProbe) quotas(ctx context.Context) (map[string]quota, error) { return f.quota, nil }
+
+func (f *fakeProbe) machineTypes(ctx context.Context) ([]string, string, error) {
+	names := make([]string, 0, len(f.cpus))
+	for mt := range f.cpus {
+		names = append(names, mt)
+	}
+	return names, "europe-west3-a", f.locateErr
+}
+
+func checkStatuses(r *types.PreflightResult) map[string]types.PreflightStatus {
+	s := make(map[string]types.PreflightStatus)
+	for _, c := range r.Checks {
+		s[c.Name] = c.Status
+	}
+	return s
+}
+
+func TestRunPreflight(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cfg := map[string]interface{}{
+		"location":     "europe-west3",
+		"machine_type": "n1-standard-4",
+		"node_count":   2,
+		"node_pools":   []types.NodePool{{Name: "big", MachineType: "n1-standard-8", NodeCount: 1}},
+	}
+	probe := &fakeProbe{
+		cpus: map[string]int{"n1-standard-4": 4, "n1-standard-8": 8},
+		quota: map[string]quota{
+			types.CPUQuotaCheck:       {available: 72, limit: 100},
+			types.IPAddressQuotaCheck: {available: 8, limit: 8},
+		},
+	}
+
+	// 3 zones with 2 default nodes of 4 CPUs and 1 node of 8 CPUs each
+	r := skipPreflightChecks(runPreflight(ctx, probe, types.GCP, cfg))
+	require.False(t, r.Passed())
+	require.Equal(t, map[string]types.PreflightStatus{
+		types.CredentialsCheck:    types.PreflightPassed,
+		types.LocationCheck:       types.PreflightPassed,
+		types.CPUQuotaCheck:       types.PreflightPassed,
+		types.IPAddressQuotaCheck: types.PreflightFailed,
+	}, checkStatuses(r), "9 nodes need more IP addresses than available")
+	require.Contains(t, r.Checks[2].Message, "need 48 CPUs")
+
+	cfg["enable_private_nodes"] = true
+	r = skipPreflightChecks(runPreflight(ctx, probe, types.GCP, cfg))
+	require.True(t, r.Passed(), "Private nodes have no external IP addresses")
+
+	probe.quota = map[string]quota{types.CPUQuotaCheck: {available: 40, limit: 100}}
+	r = skipPreflightChecks(runPreflight(ctx, probe, types.GCP, cfg))
+	require.False(t, r.Passed())
+	require.Equal(t, types.PreflightFailed, checkStatuses(r)[types.CPUQuotaCheck])
+	require.Equal(t, types.PreflightSkipped, checkStatuses(r)[types.IPAddressQuotaCheck], "The quota is not reported")
+
+	probe.locateErr = errors.New("the zone europe-west3-x is not in the region europe-west3")
+	r = skipPreflightChecks(runPreflight(ctx, probe, types.GCP, cfg))
+	require.Equal(t, map[string]types.PreflightStatus{
+		types.CredentialsCheck:    types.PreflightPassed,
+		types.LocationCheck:       types.PreflightFailed,
+		types.CPUQuotaCheck:       types.PreflightSkipped,
+		types.IPAddressQuotaCheck: types.PreflightSkipped,
+	}, checkStatuses(r))
+	require.Equal(t, probe.locateErr.Error(), r.Checks[1].Message)
+
+	probe.authErr = errors.New("invalid_grant")
+	r = skipPreflightChecks(runPreflight(ctx, probe, types.GCP, cfg))
+	require.Equal(t, types.PreflightFailed, checkStatuses(r)[types.CredentialsCheck])
+	require.Equal(t, types.PreflightSkipped, checkStatuses(r)[types.LocationCheck])
+	require.Len(t, r.Checks, 4)
+}
+
+func TestRequestedNodes(t *testing.T) {
+	t.Parallel()
+	pools := []types.NodePool{{Name: "pool", MachineType: "big", NodeCount: 2}}
+
+	require.Equal(t, []nodeGroup{{"n1-standard-4", 3}, {"big", 6}},
+		requestedNodes(types.GCP, map[string]interface{}{"region": "europe-west3", "machine_type": "n1-standard-4", "node_count": 1, "node_pools": pools}),
+		"Regional clusters have nodes in 3 zones")
+	require.Equal(t, []nodeGroup{{"n1-standard-4", 2}},
+		requestedNodes(types.GCP, map[string]interface{}{"zone": "europe-west3-a", "availability_zones": []string{"europe-west3-b"}, "machine_type": "n1-standard-4", "node_count": 1.0}),
+		"Zonal clusters have nodes in their own zone too")
+	require.Equal(t, []nodeGroup{{"n1-standard-4", 1}},
+		requestedNodes(types.GCP, map[string]interface{}{"location": "europe-west3-a", "machine_type": "n1-standard-4", "node_count": int64(1)}))
+	require.Equal(t, []nodeGroup{{"Standard_D4_v3", 3}, {"big", 2}},
+		requestedNodes(types.Azure, map[string]interface{}{"agent_vm_size": "Standard_D4_v3", "agent_count": 3, "node_pools": pools}))
+	require.Equal(t, []nodeGroup{{"m5.xlarge", 4}},
+		requestedNodes(types.AWS, map[string]interface{}{"machine_type": "m5.xlarge", "node_count": uint(4)}))
+}
+
+func TestGCPProbe(t *testing.T) {
+	t.Parallel()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res interface{}
+		switch r.URL.Path {
+		case "/my-project/regions/europe-west3":
+			res = gcompute.Region{
+				Name: "europe-west3",
+				Zones: []string{
+					"https://www.googleapis.com/compute/v1/projects/my-project/zones/europe-west3-a",
+					"https://www.googleapis.com/compute/v1/projects/my-project/zones/europe-west3-b",
+				},
+				Quotas: []*gcompute.Quota{
+					{Metric: "CPUS", Limit: 24, Usage: 8},
+					{Metric: "IN_USE_ADDRESSES", Limit: 8, Usage: 1},
+					{Metric: "SSD_TOTAL_GB", Limit: 500},
+				},
+			}
+		case "/my-project/zones/europe-west3-a/machineTypes/n1-standard-4":
+			res = gcompute.MachineType{Name: "n1-standard-4", GuestCpus: 4}
+		default:
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	defer api.Close()
+
+	service, err := gcompute.NewService(context.Background(), option.WithEndpoint(api.URL+"/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	probe := &gcpProbe{
+		project:  "my-project",
+		location: "europe-west3",
+		tokens:   oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+		service:  service,
+	}
+	ctx := context.Background()
+
+	require.NoError(t, probe.authenticate(ctx))
+
+	cpus, err := probe.locate(ctx, []string{"n1-standard-4", "n1-standard-4"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"n1-standard-4": 4}, cpus)
+
+	quotas, err := probe.quotas(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]quota{
+		types.CPUQuotaCheck:       {available: 16, limit: 24},
+		types.IPAddressQuotaCheck: {available: 7, limit: 8},
+	}, quotas)
+
+	_, err = probe.locate(ctx, []string{"n1-standard-96"})
+	require.Error(t, err, "The machine type is not offered")
+
+	probe.zones = []string{"europe-west3-c"}
+	_, err = probe.locate(ctx, nil)
+	require.EqualError(t, err, "the zone europe-west3-c is not in the region europe-west3")
+
+	probe.location = "us-east1"
+	_, err = probe.locate(ctx, nil)
+	require.Error(t, err, "The region does not exist")
+}
+
+func TestPreflightUnsupported(t *testing.T) {
+	t.Parallel()
+	tf := New()
+	_, err := tf.Preflight(types.Kind, map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "node_image": "kindest/node:v1.19.1"})
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation))
+}
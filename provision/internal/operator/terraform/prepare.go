@@ -0,0 +1,154 @@
+package terraform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+)
+
+// clusterOperation is an operation on a cluster set up by prepare.
+type clusterOperation struct {
+	// ops are the options the operation runs with, the ones of its sandbox with the Sandbox option
+	ops Options
+	// cfg is the configuration of the cluster, with the credentials of its provider and the timeouts of its resources
+	cfg     map[string]interface{}
+	project string
+	cluster string
+	// dir is the directory of the cluster terraform runs in
+	dir string
+	// rep records the phases of the operation, the report is only sent for create and delete operations
+	rep *reporter
+	// keepFiles keeps the files of the cluster once the operation finishes even without the Persistent option, such as the partial state of a failed apply
+	keepFiles bool
+}
+
+// prepare sets up an operation of the given kind on the cluster of the configuration, before it runs any terraform command.
+// It registers the operation, see begin, records its metrics and its report if it creates or deletes the cluster, scopes the credentials of the provider
+// to the configuration, checks the configuration and the names of a new cluster, limits the context to the timeout of the kind, locks the cluster,
+// moves the operation into its sandbox with the Sandbox option, and gets the state of the cluster to terraform with the InMemoryState and StateEncryptionKey options.
+// The returned function releases all of it, in the reverse order, and must be deferred with the error of the operation: files left on disk are added to the error,
+// and the files of the cluster are removed without the Persistent option, unless the keepFiles of the operation is set.
+// If the preparation fails, what was set up so far is released before the error is returned.
+func (t *Terraform) prepare(ctx context.Context, p types.ProviderType, cfg map[string]interface{}, kind operation) (_ context.Context, _ *clusterOperation, _ func(*error), err error) {
+	var releases []func(*error)
+	release := func(err *error) {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i](err)
+		}
+	}
+	defer func() {
+		if err != nil {
+			release(&err)
+		}
+	}()
+
+	ctx, done, err := t.begin(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	releases = append(releases, done)
+
+	metric := kind.metric()
+	if metric != "" {
+		start := time.Now()
+		releases = append(releases, func(err *error) { t.observe(metric, p, start, err) })
+	}
+	op := &clusterOperation{ops: t.ops, rep: newReporter(t.ops, t.states, metric, p)}
+	if metric != "" {
+		releases = append(releases, func(err *error) { op.rep.finish(*err) })
+	}
+
+	cfg, removeCredentials, err := t.credentials(ctx, p, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	releases = append(releases, func(err *error) { t.removeFiles(err, removeCredentials) })
+
+	if err := t.preflight(p, cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	if kind == createOperation {
+		if err := nameError(p, cfg); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	op.cfg = cfg
+	op.project, op.cluster = cfg["project"].(string), cfg["cluster_name"].(string)
+	// only the operations changing the cluster resources have a deadline, the timeouts are set in the configuration for all of them
+	ctx, cancel := withTimeout(ctx, applyTimeouts(cfg, t.ops.Timeouts, kind))
+	releases = append(releases, func(*error) { cancel() })
+
+	// lock the cluster, so other operations on it fail until this one is finished and its files are cleaned up
+	unlock, err := lockCluster(t.ops, op.project, op.cluster, p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	releases = append(releases, func(*error) { unlock() })
+
+	// with the Sandbox option, the operation works in a temporary dir that only shares the state with the cluster directory
+	ops, dir, finishSandbox, err := t.sandbox(op.project, op.cluster, p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	releases = append(releases, finishSandbox)
+	// with a backend, terraform keeps the backend configuration and the workspace of the cluster in a data dir of its own
+	op.ops, op.dir = backendOptions(ops, dir), dir
+	if op.ops.Backend != nil {
+		releases = append(releases, func(err *error) { t.removeFiles(err, func() error { return removeBackendConfig(op.ops) }) })
+	}
+	// the report counts the resources of the state in the sandbox
+	op.rep.ops = op.ops
+
+	// a saved plan holds the values of all variables, so it does not keep the credentials in the cluster dir either, see credentialVars
+	if _, ok := cfg[credentialVarsKey]; ok {
+		releases = append(releases, func(err *error) {
+			t.removeFiles(err, func() error {
+				if err := os.Remove(filepath.Join(op.dir, tfPlanFile)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				return nil
+			})
+		})
+	}
+
+	if !op.ops.Persistent {
+		// remove all files if not persistent after running
+		releases = append(releases, func(err *error) {
+			t.removeFiles(err, func() error {
+				if op.keepFiles {
+					return nil
+				}
+				return cleanup(op.ops, op.project, op.cluster, p)
+			})
+		})
+	}
+
+	// with the in-memory state, terraform gets the state in a file that is removed once the operation finishes
+	releaseState, err := inMemoryState(op.ops, t.states, op.project, op.cluster, p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	releases = append(releases, func(err *error) { t.removeFiles(err, releaseState) })
+
+	// with the state encryption, the states left in plaintext are encrypted and the plan is removed once the operation finishes
+	reencryptState, err := encryptedState(op.ops, op.project, op.cluster, p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	releases = append(releases, func(err *error) { t.removeFiles(err, reencryptState) })
+	return ctx, op, release, nil
+}
+
+// metric returns the name of the metrics of the operations of the kind, empty if they record none.
+func (o operation) metric() string {
+	switch o {
+	case createOperation:
+		return createMetric
+	case deleteOperation:
+		return deleteMetric
+	}
+	return ""
+}
@@ -0,0 +1,173 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// file name for the private cluster settings, terraform merges it into the cluster resource as an override file
+	tfPrivateClusterFile = "private_cluster_override.tf"
+
+	// defaultMasterCIDR is the range of the GKE control plane of private clusters if none is configured
+	defaultMasterCIDR = "172.16.0.0/28"
+
+	gcpPrivateClusterTemplate = `
+resource "google_container_cluster" "gke_cluster" {
+	{{- if .PrivateNodes}}
+	private_cluster_config {
+		enable_private_nodes    = true
+		enable_private_endpoint = {{.PrivateEndpoint}}
+		master_ipv4_cidr_block  = {{quote .MasterCIDR}}
+	}
+
+	# private clusters must be VPC-native
+	ip_allocation_policy {}
+	{{- end}}
+	{{- if or .PrivateEndpoint .AuthorizedNetworks}}
+
+	master_authorized_networks_config {
+		{{- range .AuthorizedNetworks}}
+		cidr_blocks {
+			cidr_block = {{quote .}}
+		}
+		{{- end}}
+	}
+	{{- end}}
+}
+`
+
+	azurePrivateClusterTemplate = `
+resource "azurerm_kubernetes_cluster" "azure_cluster" {
+	{{- if .PrivateEndpoint}}
+	private_cluster_enabled = true
+	{{- end}}
+	{{- if .AuthorizedNetworks}}
+	api_server_authorized_ip_ranges = [
+		{{- range .AuthorizedNetworks}}
+		{{quote .}},
+		{{- end}}
+	]
+	{{- end}}
+}
+`
+)
+
+// privateClusterTemplates contains the template rendering the private cluster settings of each provider that supports them.
+var privateClusterTemplates = map[types.ProviderType]string{
+	types.GCP:   gcpPrivateClusterTemplate,
+	types.Azure: azurePrivateClusterTemplate,
+}
+
+// privateClusterKeys are the configuration keys rendered into the private cluster file instead of the vars file.
+var privateClusterKeys = []string{"private_cluster", "enable_private_nodes", "master_authorized_networks", "master_ipv4_cidr_block"}
+
+// privateCluster contains the private cluster settings of a configuration.
+type privateCluster struct {
+	// PrivateEndpoint makes the control plane only reachable from inside the network of the cluster.
+	PrivateEndpoint bool
+	// PrivateNodes leaves the nodes without public IP addresses. A private endpoint needs private nodes.
+	PrivateNodes bool
+	// AuthorizedNetworks are the CIDRs allowed to reach the control plane.
+	AuthorizedNetworks []string
+	// MasterCIDR is the range of the GKE control plane in the network of the cluster.
+	MasterCIDR string
+}
+
+func privateClusterConfig(cfg map[string]interface{}) privateCluster {
+	pc := privateCluster{MasterCIDR: defaultMasterCIDR}
+	pc.PrivateEndpoint, _ = cfg["private_cluster"].(bool)
+	pc.PrivateNodes, _ = cfg["enable_private_nodes"].(bool)
+	pc.PrivateNodes = pc.PrivateNodes || pc.PrivateEndpoint
+	pc.AuthorizedNetworks, _ = cfg["master_authorized_networks"].([]string)
+	if cidr, ok := cfg["master_ipv4_cidr_block"].(string); ok && cidr != "" {
+		pc.MasterCIDR = cidr
+	}
+	return pc
+}
+
+// writePrivateClusterFile renders the private cluster settings of the configuration into an override file of the cluster resource.
+// Override files also work for the providers whose cluster resource comes from a downloaded module.
+// The file is removed if the cluster is not private, so the settings removed from the configuration are reverted.
+func writePrivateClusterFile(dir string, p types.ProviderType, cfg map[string]interface{}) error {
+	path := filepath.Join(dir, tfPrivateClusterFile)
+	pc := privateClusterConfig(cfg)
+	tmpl, ok := privateClusterTemplates[p]
+	if !ok || (!pc.PrivateNodes && len(pc.AuthorizedNetworks) == 0) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	t, err := template.New("privateCluster").Funcs(template.FuncMap{"quote": hclString}).Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	s := &strings.Builder{}
+	if err := t.Execute(s, pc); err != nil {
+		return errors.Wrap(err, "could not render the private cluster settings")
+	}
+	return ioutil.WriteFile(path, []byte(s.String()), 0700)
+}
+
+// privateClusterErrors checks the private cluster settings of the configuration and returns an error for each invalid field.
+func privateClusterErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	var errs []types.FieldError
+	networks, _ := cfg["master_authorized_networks"].([]string)
+	for i, n := range networks {
+		if _, _, err := net.ParseCIDR(n); err != nil {
+			errs = append(errs, types.FieldError{Field: fmt.Sprintf("master_authorized_networks[%d]", i), Reason: fmt.Sprintf("must be a CIDR, got %q", n)})
+		}
+	}
+	if cidr, ok := cfg["master_ipv4_cidr_block"].(string); ok {
+		if _, n, err := net.ParseCIDR(cidr); err != nil || n.IP.To4() == nil {
+			errs = append(errs, types.FieldError{Field: "master_ipv4_cidr_block", Reason: fmt.Sprintf("must be an IPv4 CIDR, got %q", cidr)})
+		} else if ones, _ := n.Mask.Size(); ones != 28 {
+			errs = append(errs, types.FieldError{Field: "master_ipv4_cidr_block", Reason: "must be a /28 range"})
+		}
+	}
+	if private, _ := cfg["private_cluster"].(bool); private && len(networks) > 0 && p == types.Azure {
+		errs = append(errs, types.FieldError{Field: "master_authorized_networks", Reason: "cannot be used with a private cluster on Azure"})
+	}
+	return errs
+}
+
+// privateEndpoint returns true if the cluster resource in the state has a control plane reachable only from inside its network.
+func privateEndpoint(sf *statefile.File) (bool, error) {
+	for _, r := range sf.State.RootModule().Resources {
+		if r.Addr.Type != "google_container_cluster" && r.Addr.Type != "azurerm_kubernetes_cluster" {
+			continue
+		}
+		for _, i := range r.Instances {
+			if i.Current == nil {
+				continue
+			}
+			attrs := make(map[string]interface{})
+			if err := json.Unmarshal(i.Current.AttrsJSON, &attrs); err != nil {
+				return false, errors.Wrapf(err, "could not decode the attributes of %s", r.Addr)
+			}
+			if private, _ := attrs["private_cluster_enabled"].(bool); private {
+				return true, nil
+			}
+			if pcc, _ := attrs["private_cluster_config"].([]interface{}); len(pcc) > 0 {
+				if c, ok := pcc[0].(map[string]interface{}); ok {
+					if private, _ := c["enable_private_endpoint"].(bool); private {
+						return true, nil
+					}
+				}
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,98 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePrivateClusterFile(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"private_cluster":            true,
+		"master_authorized_networks": []string{"10.0.0.0/8"},
+	}
+	// the Azure cluster resource comes from a module, a minimal one is enough to merge the override into
+	bases := map[types.ProviderType]string{
+		types.GCP:   gcpClusterTemplate,
+		types.Azure: `resource "azurerm_kubernetes_cluster" "azure_cluster" {}`,
+	}
+	for p, base := range bases {
+		dir, err := ioutil.TempDir("", "hf-private-cluster")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(base), 0600))
+
+		require.NoError(t, writePrivateClusterFile(dir, p, cfg))
+		_, diags := configs.NewParser(nil).LoadConfigDir(dir)
+		require.False(t, diags.HasErrors(), "%s private cluster settings should be valid terraform: %s", p, diags.Error())
+
+		// a public cluster has no override
+		require.NoError(t, writePrivateClusterFile(dir, p, map[string]interface{}{}))
+		_, err = os.Stat(filepath.Join(dir, tfPrivateClusterFile))
+		require.True(t, os.IsNotExist(err), "The private cluster file should be removed for public clusters")
+	}
+
+	dir, err := ioutil.TempDir("", "hf-private-cluster")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, writePrivateClusterFile(dir, types.GCP, map[string]interface{}{"enable_private_nodes": true}))
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfPrivateClusterFile))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "enable_private_endpoint = false", "Private nodes should keep the endpoint public")
+	require.Contains(t, string(data), defaultMasterCIDR)
+	require.NotContains(t, string(data), "master_authorized_networks_config")
+
+	require.NoError(t, writePrivateClusterFile(dir, types.AWS, cfg))
+	_, err = os.Stat(filepath.Join(dir, tfPrivateClusterFile))
+	require.True(t, os.IsNotExist(err), "Providers without private cluster template should not get the file")
+}
+
+func TestPrivateClusterErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, privateClusterErrors(types.GCP, map[string]interface{}{
+		"private_cluster":            true,
+		"master_authorized_networks": []string{"10.0.0.0/8", "192.168.1.1/32"},
+		"master_ipv4_cidr_block":     "172.16.0.16/28",
+	}))
+
+	fields := []string{}
+	for _, e := range privateClusterErrors(types.Azure, map[string]interface{}{
+		"private_cluster":            true,
+		"master_authorized_networks": []string{"10.0.0.0/8", "10.0.0.1"},
+		"master_ipv4_cidr_block":     "172.16.0.0/24",
+	}) {
+		fields = append(fields, e.Field)
+	}
+	require.Equal(t, []string{"master_authorized_networks[1]", "master_ipv4_cidr_block", "master_authorized_networks"}, fields)
+
+	require.False(t, gcpFilter("master_authorized_networks", []string{}), "The private cluster settings should not be in the vars")
+	require.False(t, azureFilter("private_cluster", true), "The private cluster settings should not be in the vars")
+}
+
+func TestPrivateEndpoint(t *testing.T) {
+	t.Parallel()
+	for attrs, private := range map[string]bool{
+		`{"name": "gke", "private_cluster_config": []}`:                                   false,
+		`{"name": "gke", "private_cluster_config": [{"enable_private_endpoint": false}]}`: false,
+		`{"name": "gke", "private_cluster_config": [{"enable_private_endpoint": true}]}`:  true,
+	} {
+		state := states.NewState()
+		state.RootModule().SetResourceInstanceCurrent(
+			addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_cluster", Name: "gke_cluster"}.Instance(addrs.NoKey),
+			&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(attrs)},
+			addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+		)
+		info, err := clusterInfoFromState(statefile.New(state, "", 0))
+		require.NoError(t, err)
+		require.Equal(t, private, info.PrivateEndpoint, "Wrong private endpoint for the attributes %s", attrs)
+	}
+}
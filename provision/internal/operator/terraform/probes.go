@@ -0,0 +1,374 @@
+package terraform
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	gcompute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// gcpProbe queries the Compute Engine API of the project of a GKE cluster.
+type gcpProbe struct {
+	project   string
+	location  string
+	zones     []string
+	tokens    oauth2.TokenSource
+	service   *gcompute.Service
+	regionAPI *gcompute.Region
+}
+
+func newGCPProbe(ctx context.Context, cfg map[string]interface{}, transport http.RoundTripper) (preflightProbe, error) {
+	data, err := ioutil.ReadFile(stringValue(cfg["credentials_file_path"]))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the credentials file")
+	}
+	// the tokens are requested with the client of the context
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+	creds, err := google.CredentialsFromJSON(ctx, data, gcpTokenScope)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load the service account key")
+	}
+	service, err := gcompute.NewService(ctx, option.WithHTTPClient(oauth2.NewClient(ctx, creds.TokenSource)))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the Compute Engine client")
+	}
+	zones, _ := cfg["availability_zones"].([]string)
+	return &gcpProbe{
+		project:  stringValue(cfg["project"]),
+		location: clusterLocation(cfg),
+		zones:    zones,
+		tokens:   creds.TokenSource,
+		service:  service,
+	}, nil
+}
+
+func (g *gcpProbe) authenticate(ctx context.Context) error {
+	if _, err := g.tokens.Token(); err != nil {
+		return errors.Wrap(err, "could not get a token for the service account")
+	}
+	return nil
+}
+
+// locate checks the region, and the zone of a zonal cluster, and finds the machine types in a zone of the cluster.
+func (g *gcpProbe) locate(ctx context.Context, machineTypes []string) (map[string]int, error) {
+	region, err := g.service.Regions.Get(g.project, gcpRegion(g.location)).Context(ctx).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find the region %s", gcpRegion(g.location))
+	}
+	g.regionAPI = region
+
+	var regionZones []string
+	for _, z := range region.Zones {
+		regionZones = append(regionZones, path.Base(z))
+	}
+	zones := g.zones
+	if gcpZone(g.location) {
+		zones = append([]string{g.location}, zones...)
+	}
+	for _, z := range zones {
+		if !contains(regionZones, z) {
+			return nil, errors.Errorf("the zone %s is not in the region %s", z, region.Name)
+		}
+	}
+	if len(zones) == 0 {
+		zones = regionZones
+	}
+	if len(zones) == 0 {
+		return nil, errors.Errorf("the region %s has no zones", region.Name)
+	}
+
+	cpus := make(map[string]int)
+	for _, mt := range machineTypes {
+		if _, ok := cpus[mt]; ok {
+			continue
+		}
+		m, err := g.service.MachineTypes.Get(g.project, zones[0], mt).Context(ctx).Do()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not find the machine type %s in %s", mt, zones[0])
+		}
+		cpus[mt] = int(m.GuestCpus)
+	}
+	return cpus, nil
+}
+
+// machineTypes lists the machine types of the first zone of the cluster, the region of a regional cluster without availability zones
+// is asked for its zones first.
+func (g *gcpProbe) machineTypes(ctx context.Context) ([]string, string, error) {
+	zone := ""
+	switch {
+	case gcpZone(g.location):
+		zone = g.location
+	case len(g.zones) > 0:
+		zone = g.zones[0]
+	default:
+		region, err := g.service.Regions.Get(g.project, g.location).Context(ctx).Do()
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "could not find the region %s", g.location)
+		}
+		if len(region.Zones) == 0 {
+			return nil, "", errors.Errorf("the region %s has no zones", region.Name)
+		}
+		zone = path.Base(region.Zones[0])
+	}
+
+	var names []string
+	if err := g.service.MachineTypes.List(g.project, zone).Pages(ctx, func(l *gcompute.MachineTypeList) error {
+		for _, m := range l.Items {
+			names = append(names, m.Name)
+		}
+		return nil
+	}); err != nil {
+		return nil, "", errors.Wrapf(err, "could not list the machine types of %s", zone)
+	}
+	return names, zone, nil
+}
+
+// gcpQuotas are the metrics of the region quotas checked by Preflight.
+var gcpQuotas = map[string]string{
+	"CPUS":             types.CPUQuotaCheck,
+	"IN_USE_ADDRESSES": types.IPAddressQuotaCheck,
+}
+
+// quotas returns the region quotas loaded by locate.
+func (g *gcpProbe) quotas(ctx context.Context) (map[string]quota, error) {
+	if g.regionAPI == nil {
+		return nil, errors.New("the region of the cluster is unknown")
+	}
+	quotas := make(map[string]quota)
+	for _, q := range g.regionAPI.Quotas {
+		if name, ok := gcpQuotas[q.Metric]; ok {
+			quotas[name] = quota{available: q.Limit - q.Usage, limit: q.Limit}
+		}
+	}
+	return quotas, nil
+}
+
+// awsProbe queries the EC2 API of the region of an EKS cluster.
+type awsProbe struct {
+	session *session.Session
+	spot    bool
+}
+
+// AWS quota codes of the vCPUs of running instances of the standard families (A, C, D, H, I, M, R, T and Z).
+const (
+	awsOnDemandVCPUQuota = "L-1216C47A"
+	awsSpotVCPUQuota     = "L-34B43A08"
+)
+
+func newAWSProbe(ctx context.Context, cfg map[string]interface{}, transport http.RoundTripper) (preflightProbe, error) {
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvProvider{},
+		&credentials.SharedCredentialsProvider{Filename: stringValue(cfg["credentials_file_path"]), Profile: stringValue(cfg["profile"])},
+	})
+	s, err := session.NewSession(&aws.Config{
+		Region:      aws.String(stringValue(cfg["region"])),
+		Credentials: creds,
+		HTTPClient:  &http.Client{Transport: transport},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the AWS session")
+	}
+	spot, _ := cfg["spot"].(bool)
+	return &awsProbe{session: s, spot: spot}, nil
+}
+
+func (a *awsProbe) authenticate(ctx context.Context) error {
+	if _, err := sts.New(a.session).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+		return errors.Wrap(err, "could not get the identity of the credentials")
+	}
+	return nil
+}
+
+func (a *awsProbe) locate(ctx context.Context, machineTypes []string) (map[string]int, error) {
+	client := ec2.New(a.session)
+	zones, err := client.DescribeAvailabilityZonesWithContext(ctx, &ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find the region %s", aws.StringValue(a.session.Config.Region))
+	}
+	if len(zones.AvailabilityZones) == 0 {
+		return nil, errors.Errorf("the region %s has no availability zones", aws.StringValue(a.session.Config.Region))
+	}
+
+	out, err := client.DescribeInstanceTypesWithContext(ctx, &ec2.DescribeInstanceTypesInput{InstanceTypes: aws.StringSlice(machineTypes)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find the instance types %v", machineTypes)
+	}
+	cpus := make(map[string]int)
+	for _, it := range out.InstanceTypes {
+		if it.VCpuInfo != nil {
+			cpus[aws.StringValue(it.InstanceType)] = int(aws.Int64Value(it.VCpuInfo.DefaultVCpus))
+		}
+	}
+	for _, mt := range machineTypes {
+		if _, ok := cpus[mt]; !ok {
+			return nil, errors.Errorf("the instance type %s is not offered in %s", mt, aws.StringValue(a.session.Config.Region))
+		}
+	}
+	return cpus, nil
+}
+
+// machineTypes lists the instance types offered in the region.
+func (a *awsProbe) machineTypes(ctx context.Context) ([]string, string, error) {
+	region := aws.StringValue(a.session.Config.Region)
+	var names []string
+	if err := ec2.New(a.session).DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String(ec2.LocationTypeRegion),
+	}, func(out *ec2.DescribeInstanceTypeOfferingsOutput, last bool) bool {
+		for _, o := range out.InstanceTypeOfferings {
+			names = append(names, aws.StringValue(o.InstanceType))
+		}
+		return true
+	}); err != nil {
+		return nil, "", errors.Wrapf(err, "could not list the instance types of %s", region)
+	}
+	return names, region, nil
+}
+
+// quotas returns the vCPU limit of the standard instance families. AWS does not report the usage with the limit,
+// so the whole limit counts as available.
+func (a *awsProbe) quotas(ctx context.Context) (map[string]quota, error) {
+	code := awsOnDemandVCPUQuota
+	if a.spot {
+		code = awsSpotVCPUQuota
+	}
+	out, err := servicequotas.New(a.session).GetServiceQuotaWithContext(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String("ec2"),
+		QuotaCode:   aws.String(code),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get the vCPU quota")
+	}
+	limit := aws.Float64Value(out.Quota.Value)
+	return map[string]quota{types.CPUQuotaCheck: {available: limit, limit: limit}}, nil
+}
+
+// azureProbe queries the Compute API of the subscription of an AKS cluster.
+type azureProbe struct {
+	subscription string
+	location     string
+	token        *adal.ServicePrincipalToken
+	client       *http.Client
+}
+
+// azureCredentials are the configuration fields of the service principal, with the environment variables of the azurerm provider if they are not set.
+var azureCredentials = map[string]string{
+	"subscription_id": "ARM_SUBSCRIPTION_ID",
+	"tenant_id":       "ARM_TENANT_ID",
+	"client_id":       "ARM_CLIENT_ID",
+	"client_secret":   "ARM_CLIENT_SECRET",
+}
+
+func newAzureProbe(ctx context.Context, cfg map[string]interface{}, transport http.RoundTripper) (preflightProbe, error) {
+	creds := make(map[string]string)
+	for field, env := range azureCredentials {
+		creds[field] = stringValue(cfg[field])
+		if creds[field] == "" {
+			creds[field] = os.Getenv(env)
+		}
+		if creds[field] == "" {
+			return nil, errors.Errorf("no Azure service principal found, set %s or the %s environment variable", field, env)
+		}
+	}
+	oauth, err := adal.NewOAuthConfig(azure.PublicCloud.ActiveDirectoryEndpoint, creds["tenant_id"])
+	if err != nil {
+		return nil, errors.Wrap(err, "could not configure the Azure authentication")
+	}
+	token, err := adal.NewServicePrincipalToken(*oauth, creds["client_id"], creds["client_secret"], azure.PublicCloud.ResourceManagerEndpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the token of the service principal")
+	}
+	client := &http.Client{Transport: transport}
+	token.SetSender(client)
+	return &azureProbe{subscription: creds["subscription_id"], location: stringValue(cfg["location"]), token: token, client: client}, nil
+}
+
+func (a *azureProbe) authenticate(ctx context.Context) error {
+	if err := a.token.EnsureFreshWithContext(ctx); err != nil {
+		return errors.Wrap(err, "could not get a token for the service principal")
+	}
+	return nil
+}
+
+func (a *azureProbe) locate(ctx context.Context, machineTypes []string) (map[string]int, error) {
+	client := compute.NewVirtualMachineSizesClient(a.subscription)
+	client.Authorizer = autorest.NewBearerAuthorizer(a.token)
+	client.Sender = a.client
+	sizes, err := client.List(ctx, a.location)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find the VM sizes of the location %s", a.location)
+	}
+	cpus := make(map[string]int)
+	if sizes.Value != nil {
+		for _, s := range *sizes.Value {
+			if s.Name != nil && s.NumberOfCores != nil {
+				cpus[*s.Name] = int(*s.NumberOfCores)
+			}
+		}
+	}
+	for _, mt := range machineTypes {
+		if _, ok := cpus[mt]; !ok {
+			return nil, errors.Errorf("the VM size %s is not offered in %s", mt, a.location)
+		}
+	}
+	return cpus, nil
+}
+
+// machineTypes lists the VM sizes of the location.
+func (a *azureProbe) machineTypes(ctx context.Context) ([]string, string, error) {
+	client := compute.NewVirtualMachineSizesClient(a.subscription)
+	client.Authorizer = autorest.NewBearerAuthorizer(a.token)
+	client.Sender = a.client
+	sizes, err := client.List(ctx, a.location)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "could not list the VM sizes of the location %s", a.location)
+	}
+	var names []string
+	if sizes.Value != nil {
+		for _, s := range *sizes.Value {
+			if s.Name != nil {
+				names = append(names, *s.Name)
+			}
+		}
+	}
+	return names, a.location, nil
+}
+
+// quotas returns the regional vCPU quota of the subscription. AKS nodes have no public IP addresses.
+func (a *azureProbe) quotas(ctx context.Context) (map[string]quota, error) {
+	client := compute.NewUsageClient(a.subscription)
+	client.Authorizer = autorest.NewBearerAuthorizer(a.token)
+	client.Sender = a.client
+	usages, err := client.ListComplete(ctx, a.location)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get the usage of the subscription")
+	}
+	quotas := make(map[string]quota)
+	for ; usages.NotDone(); err = usages.NextWithContext(ctx) {
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get the usage of the subscription")
+		}
+		u := usages.Value()
+		if u.Name != nil && u.Name.Value != nil && *u.Name.Value == "cores" && u.Limit != nil && u.CurrentValue != nil {
+			quotas[types.CPUQuotaCheck] = quota{available: float64(*u.Limit - int64(*u.CurrentValue)), limit: float64(*u.Limit)}
+		}
+	}
+	return quotas, nil
+}
@@ -0,0 +1,72 @@
+package terraform
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/command"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	hashiCli "github.com/mitchellh/cli"
+)
+
+// resourceProgress matches the messages terraform outputs for each resource while running, such as
+// "google_container_cluster.gke_cluster: Still creating... [10m0s elapsed]".
+var resourceProgress = regexp.MustCompile(`^(.+?): (Creating|Modifying|Destroying|Still creating|Still modifying|Still destroying|` +
+	`Creation complete|Modifications complete|Destruction complete|Refreshing state|Importing from ID|Import prepared)`)
+
+// progressUI forwards the output of a terraform command to the wrapped UI and reports each line as a progress event.
+type progressUI struct {
+	hashiCli.Ui
+	phase   types.ProvisionPhase
+	handler func(types.ProvisionEvent)
+	// terraform outputs the progress of resources from several goroutines, the handler is called one event at a time
+	mu sync.Mutex
+}
+
+// Output is called for normal standard output.
+func (u *progressUI) Output(s string) {
+	u.Ui.Output(s)
+	u.report(s)
+}
+
+// Info is called for information related to the previous output.
+func (u *progressUI) Info(s string) {
+	u.Ui.Info(s)
+	u.report(s)
+}
+
+// report sends an event to the handler for each line of the given output.
+func (u *progressUI) report(s string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		ev := types.ProvisionEvent{
+			Phase:   u.phase,
+			Message: line,
+		}
+		if m := resourceProgress.FindStringSubmatch(line); m != nil {
+			ev.Resource = m[1]
+		}
+		u.handler(ev)
+	}
+}
+
+// progressMeta returns a copy of the given terraform meta that reports the output of the command as progress events of the given phase.
+// If there is no progress handler, the meta is returned unchanged.
+func progressMeta(m command.Meta, handler func(types.ProvisionEvent), phase types.ProvisionPhase) command.Meta {
+	if handler != nil {
+		m.Ui = &progressUI{
+			Ui:      m.Ui,
+			phase:   phase,
+			handler: handler,
+		}
+	}
+	return m
+}
@@ -0,0 +1,32 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/command"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressMeta(t *testing.T) {
+	t.Parallel()
+	ui := &HydroUI{}
+	m := command.Meta{Ui: ui}
+
+	require.Equal(t, m, progressMeta(m, nil, types.ApplyPhase), "Without handler the meta should not change")
+
+	var events []types.ProvisionEvent
+	pm := progressMeta(m, func(ev types.ProvisionEvent) { events = append(events, ev) }, types.ApplyPhase)
+
+	pm.Ui.Output("google_container_cluster.gke_cluster: Creating...")
+	pm.Ui.Output("google_container_cluster.gke_cluster: Still creating... [10s elapsed]\n\n")
+	pm.Ui.Info("Apply complete! Resources: 1 added, 0 changed, 0 destroyed.")
+	pm.Ui.Error("ERROR")
+
+	require.Equal(t, []types.ProvisionEvent{
+		{Phase: types.ApplyPhase, Message: "google_container_cluster.gke_cluster: Creating...", Resource: "google_container_cluster.gke_cluster"},
+		{Phase: types.ApplyPhase, Message: "google_container_cluster.gke_cluster: Still creating... [10s elapsed]", Resource: "google_container_cluster.gke_cluster"},
+		{Phase: types.ApplyPhase, Message: "Apply complete! Resources: 1 added, 0 changed, 0 destroyed."},
+	}, events)
+	require.Len(t, ui.Errors(), 1, "Errors should still be collected by the wrapped UI")
+}
@@ -0,0 +1,62 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	goversion "github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/pkg/errors"
+)
+
+// file name for the version constraints of the provider plugins, terraform merges its required_providers with the ones of the templates
+const tfProviderVersionsFile = "provider_versions.tf"
+
+// writeProviderVersionsFile renders the given version constraints of the provider plugins into the required_providers block of the cluster directory,
+// so terraform init installs and plan and apply only use plugin versions that satisfy them. The file is removed if there are no constraints.
+func writeProviderVersionsFile(dir string, versions map[string]string) error {
+	path := filepath.Join(dir, tfProviderVersionsFile)
+	if len(versions) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	// sort the providers to always render the same file for the same options
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data strings.Builder
+	data.WriteString("terraform {\n  required_providers {\n")
+	for _, name := range names {
+		data.WriteString(fmt.Sprintf("    %s = %s\n", name, hclString(versions[name])))
+	}
+	data.WriteString("  }\n}\n")
+	return ioutil.WriteFile(path, []byte(data.String()), 0700)
+}
+
+// providerVersionsError returns an error if a version constraint of the options is invalid, terraform would only report it once init runs.
+// Constraints that no installed or downloadable plugin satisfies fail the operation with ErrTerraformNotFound when terraform installs the plugins.
+func providerVersionsError(ops Options) error {
+	for name, constraint := range ops.ProviderVersions {
+		if !hclsyntax.ValidIdentifier(name) {
+			return errors.Errorf("invalid provider name %q for the version constraint %q", name, constraint)
+		}
+		if name == "terraform" {
+			// the terraform provider is built in, terraform refuses constraints on it
+			return errors.New("the terraform provider is built into terraform, its version cannot be constrained")
+		}
+		if _, err := goversion.NewConstraint(constraint); err != nil {
+			return errors.Wrapf(err, "invalid version constraint %q for the %s provider", constraint, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProviderVersionsFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-providerversions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, writeProviderVersionsFile(dir, map[string]string{"google": "~> 3.5", "azurerm": ">= 2.40, < 3.0"}))
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfProviderVersionsFile))
+	require.NoError(t, err)
+	require.Equal(t, "terraform {\n  required_providers {\n    azurerm = \">= 2.40, < 3.0\"\n    google = \"~> 3.5\"\n  }\n}\n", string(data),
+		"The providers should be sorted")
+
+	file, diags := configs.NewParser(nil).LoadConfigFile(filepath.Join(dir, tfProviderVersionsFile))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.Len(t, file.RequiredProviders, 2, "Terraform should read the constraints")
+
+	require.NoError(t, writeProviderVersionsFile(dir, nil))
+	_, err = os.Stat(filepath.Join(dir, tfProviderVersionsFile))
+	require.True(t, os.IsNotExist(err), "The file should be removed without constraints")
+	require.NoError(t, writeProviderVersionsFile(dir, nil), "A missing file should not fail")
+}
+
+func TestProviderVersionsError(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, providerVersionsError(options()))
+	require.NoError(t, providerVersionsError(options(WithProviderVersion("google", "~> 3.5"), WithProviderVersion("azurerm", "2.40.0"))))
+
+	require.Error(t, providerVersionsError(options(WithProviderVersion("google", "latest"))), "An invalid constraint should fail")
+	require.Error(t, providerVersionsError(options(WithProviderVersion("google provider", "~> 3.5"))), "An invalid provider name should fail")
+	require.Error(t, providerVersionsError(options(WithProviderVersion("terraform", "~> 1.0"))), "The built-in provider cannot be constrained")
+}
@@ -0,0 +1,74 @@
+package terraform
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxyEnvVars are the environment variables of each proxy setting. Both cases are set, since programs differ in the one they read first.
+var proxyEnvVars = map[string][]string{
+	"https": {"HTTPS_PROXY", "https_proxy"},
+	"http":  {"HTTP_PROXY", "http_proxy"},
+	"no":    {"NO_PROXY", "no_proxy"},
+}
+
+// proxyEnv returns the given environment with the proxy environment variables of the given proxy, the environment of the provider plugins
+// started for an operation, see startPlugins. The variables the proxy leaves empty are removed, so the plugins never get the proxy of the process instead.
+// Without a proxy, the environment is returned unchanged.
+func proxyEnv(env []string, proxy *types.Proxy) []string {
+	if proxy == nil {
+		return env
+	}
+	values := map[string]string{"https": proxy.HTTPSProxy, "http": proxy.HTTPProxy, "no": proxy.NoProxy}
+	proxied := make([]string, 0, len(env)+6)
+	for _, kv := range env {
+		if !isProxyEnvVar(strings.SplitN(kv, "=", 2)[0]) {
+			proxied = append(proxied, kv)
+		}
+	}
+	for setting, vars := range proxyEnvVars {
+		if values[setting] == "" {
+			continue
+		}
+		for _, v := range vars {
+			proxied = append(proxied, v+"="+values[setting])
+		}
+	}
+	return proxied
+}
+
+// isProxyEnvVar returns true if the given environment variable is one of proxyEnvVars.
+func isProxyEnvVar(name string) bool {
+	for _, vars := range proxyEnvVars {
+		for _, v := range vars {
+			if name == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyTransport returns the transport of the requests Hydroform sends to the providers itself, such as to check the credentials.
+// It is nil without a proxy option, so the requests use the default transport and the proxy of the environment.
+// Go reads the environment only once per process, so the requests get the proxy of the options directly instead.
+func proxyTransport(ops Options) http.RoundTripper {
+	if ops.Proxy == nil {
+		return nil
+	}
+	proxy := (&httpproxy.Config{
+		HTTPSProxy: ops.Proxy.HTTPSProxy,
+		HTTPProxy:  ops.Proxy.HTTPProxy,
+		NoProxy:    ops.Proxy.NoProxy,
+	}).ProxyFunc()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	return transport
+}
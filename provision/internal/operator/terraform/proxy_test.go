@@ -0,0 +1,48 @@
+package terraform
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyEnv(t *testing.T) {
+	t.Parallel()
+
+	env := []string{"PATH=/bin", "HTTPS_PROXY=http://ambient:3128", "http_proxy=http://ambient:3128", "no_proxy="}
+	require.Equal(t, env, proxyEnv(env, nil), "Without a proxy, the environment should not change")
+
+	proxied := proxyEnv(env, &types.Proxy{HTTPSProxy: "http://corporate:3128", NoProxy: "localhost"})
+	require.ElementsMatch(t, []string{
+		"PATH=/bin",
+		"HTTPS_PROXY=http://corporate:3128", "https_proxy=http://corporate:3128",
+		"NO_PROXY=localhost", "no_proxy=localhost",
+	}, proxied, "Unset proxies should not be taken from the environment")
+	require.Equal(t, "HTTPS_PROXY=http://ambient:3128", env[1], "The given environment should not change")
+}
+
+func TestProxyTransport(t *testing.T) {
+	t.Parallel()
+	require.Nil(t, proxyTransport(Options{}), "Without option the default transport should be used")
+
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client := &http.Client{Transport: proxyTransport(Options{Proxy: &types.Proxy{HTTPProxy: proxy.URL, NoProxy: "direct.example.com"}})}
+	resp, err := client.Get("http://api.example.com/v1/account")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "http://api.example.com/v1/account", requested, "The request should go through the proxy")
+
+	req, err := http.NewRequest(http.MethodGet, "http://direct.example.com/", nil)
+	require.NoError(t, err)
+	u, err := client.Transport.(*http.Transport).Proxy(req)
+	require.NoError(t, err)
+	require.Nil(t, u, "The hosts of NoProxy should be reached directly")
+}
@@ -0,0 +1,85 @@
+package terraform
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// readyPollInterval is the time between two checks of the API server of a new cluster
+const readyPollInterval = 5 * time.Second
+
+// WaitForReady polls the readiness endpoint of the API server in the kubeconfig of the given ClusterInfo until it reports ready.
+// Clusters older than Kubernetes 1.16 have no /readyz endpoint, their /healthz endpoint is polled instead.
+// The credentials of the kubeconfig are used if the client supports them, otherwise the endpoint is polled anonymously, which Kubernetes allows by default.
+// It returns ErrTimeout if the cluster is not ready within the given timeout, a timeout of 0 waits until the context is done.
+func WaitForReady(ctx context.Context, info *types.ClusterInfo, timeout time.Duration) error {
+	return waitForClusterReady(ctx, info, timeout, readyPollInterval)
+}
+
+// waitForClusterReady works as WaitForReady, polling the API server at the given interval.
+func waitForClusterReady(ctx context.Context, info *types.ClusterInfo, timeout, interval time.Duration) error {
+	if info == nil || info.Kubeconfig == "" {
+		return errors.New("the cluster info has no kubeconfig")
+	}
+	restCfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(info.Kubeconfig))
+	if err != nil {
+		return errors.Wrap(err, "could not load the kubeconfig of the cluster")
+	}
+
+	client, err := readyClient(restCfg)
+	if err != nil {
+		// auth providers such as gcp are not part of the client, the readiness endpoints do not need them
+		if client, err = readyClient(rest.AnonymousClientConfig(restCfg)); err != nil {
+			return errors.Wrap(err, "could not create the client of the cluster")
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	return waitForReady(ctx, client, interval)
+}
+
+// readyClient returns a client for the raw endpoints of the API server with the given config.
+func readyClient(cfg *rest.Config) (rest.Interface, error) {
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// the health endpoints are not part of an API group, like the discovery endpoints
+	return cs.Discovery().RESTClient(), nil
+}
+
+// waitForReady polls the readiness endpoint of the API server with the given client until it answers with ok.
+func waitForReady(ctx context.Context, client rest.Interface, interval time.Duration) error {
+	endpoint := "/readyz"
+	var lastErr error
+	for {
+		var status int
+		_, err := client.Get().AbsPath(endpoint).Do(ctx).StatusCode(&status).Raw()
+		switch {
+		case err == nil:
+			return nil
+		case status == http.StatusNotFound && endpoint == "/readyz":
+			endpoint = "/healthz"
+			continue
+		case ctx.Err() == nil:
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return errors.Wrapf(types.ErrTimeout, "the API server is not ready: %v", lastErr)
+			}
+			return errors.Wrap(ctx.Err(), "stopped waiting for the API server")
+		case <-time.After(interval):
+		}
+	}
+}
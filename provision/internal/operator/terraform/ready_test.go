@@ -0,0 +1,94 @@
+package terraform
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// testKubeconfig returns a kubeconfig for the given TLS test server.
+func testKubeconfig(s *httptest.Server) string {
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+users:
+- name: test
+  user:
+    token: test-token
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`, s.URL, base64.StdEncoding.EncodeToString(ca))
+}
+
+func TestWaitForReady(t *testing.T) {
+	t.Parallel()
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/readyz", r.URL.Path)
+		require.Equal(t, "Bearer test-token", r.Header.Get("Authorization"), "The credentials of the kubeconfig should be used")
+		fmt.Fprint(w, "ok")
+	}))
+	defer s.Close()
+
+	require.NoError(t, WaitForReady(context.Background(), &types.ClusterInfo{Kubeconfig: testKubeconfig(s)}, time.Minute))
+
+	require.Error(t, WaitForReady(context.Background(), nil, time.Minute), "Waiting without cluster info should fail")
+	require.Error(t, WaitForReady(context.Background(), &types.ClusterInfo{}, time.Minute), "Waiting without kubeconfig should fail")
+}
+
+func TestWaitForReadyPolling(t *testing.T) {
+	t.Parallel()
+	var calls int32
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		// servers before Kubernetes 1.16 only have the healthz endpoint
+		case r.URL.Path == "/readyz":
+			http.NotFound(w, r)
+		case atomic.AddInt32(&calls, 1) < 3:
+			http.Error(w, "etcd not ready", http.StatusInternalServerError)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer s.Close()
+
+	restCfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(testKubeconfig(s)))
+	require.NoError(t, err)
+	client, err := readyClient(restCfg)
+	require.NoError(t, err)
+
+	require.NoError(t, waitForReady(context.Background(), client, time.Millisecond))
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls), "The endpoint should be polled until it is ready")
+
+	// never ready
+	atomic.StoreInt32(&calls, -1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = waitForReady(ctx, client, time.Millisecond)
+	require.True(t, errors.Is(err, types.ErrTimeout), "Waiting past the timeout should time out: %v", err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = waitForReady(ctx, client, time.Millisecond)
+	require.Error(t, err)
+	require.False(t, errors.Is(err, types.ErrTimeout), "A cancelled wait should not time out")
+}
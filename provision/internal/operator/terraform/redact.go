@@ -0,0 +1,109 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs/configschema"
+	tf "github.com/hashicorp/terraform/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// redactStateJSON replaces the values terraform marks sensitive in the given state marshaled by jsonstate:
+// the outputs flagged in the state and the attributes flagged in the given schemas.
+func redactStateJSON(data []byte, schemas *tf.Schemas) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	// keep the numbers as terraform wrote them
+	d.UseNumber()
+	var state map[string]interface{}
+	if err := d.Decode(&state); err != nil {
+		return nil, errors.Wrap(err, "could not decode the state")
+	}
+
+	values, _ := state["values"].(map[string]interface{})
+	outputs, _ := values["outputs"].(map[string]interface{})
+	for _, o := range outputs {
+		if o, ok := o.(map[string]interface{}); ok && o["sensitive"] == true {
+			o["value"] = types.RedactedValue
+		}
+	}
+	if root, ok := values["root_module"].(map[string]interface{}); ok {
+		redactModule(root, schemas)
+	}
+
+	redacted, err := json.Marshal(state)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal the redacted state")
+	}
+	return redacted, nil
+}
+
+// redactModule redacts the sensitive attributes of the resources of the given module and its child modules.
+func redactModule(module map[string]interface{}, schemas *tf.Schemas) {
+	resources, _ := module["resources"].([]interface{})
+	for _, r := range resources {
+		r, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		mode := addrs.ManagedResourceMode
+		if r["mode"] == "data" {
+			mode = addrs.DataResourceMode
+		}
+		// the provider name has the alias of the provider configuration after a dot
+		provider, _ := r["provider_name"].(string)
+		provider = strings.SplitN(provider, ".", 2)[0]
+		typ, _ := r["type"].(string)
+
+		schema, _ := schemas.ResourceTypeConfig(provider, mode, typ)
+		if schema == nil {
+			// without schema the sensitive attributes are not known
+			if _, ok := r["values"]; ok {
+				r["values"] = types.RedactedValue
+			}
+			continue
+		}
+		if values, ok := r["values"].(map[string]interface{}); ok {
+			redactBlock(values, schema)
+		}
+	}
+
+	children, _ := module["child_modules"].([]interface{})
+	for _, c := range children {
+		if c, ok := c.(map[string]interface{}); ok {
+			redactModule(c, schemas)
+		}
+	}
+}
+
+// redactBlock redacts the sensitive attributes of the given values of a block and of its nested blocks.
+func redactBlock(values map[string]interface{}, block *configschema.Block) {
+	for name, a := range block.Attributes {
+		if v, ok := values[name]; ok && v != nil && a.Sensitive {
+			values[name] = types.RedactedValue
+		}
+	}
+	for name, nb := range block.BlockTypes {
+		switch v := values[name].(type) {
+		case []interface{}:
+			for _, e := range v {
+				if e, ok := e.(map[string]interface{}); ok {
+					redactBlock(e, &nb.Block)
+				}
+			}
+		case map[string]interface{}:
+			if nb.Nesting != configschema.NestingMap {
+				redactBlock(v, &nb.Block)
+				continue
+			}
+			for _, e := range v {
+				if e, ok := e.(map[string]interface{}); ok {
+					redactBlock(e, &nb.Block)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package terraform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	tf "github.com/hashicorp/terraform/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestRedactedStateJSON(t *testing.T) {
+	t.Parallel()
+	provider := &tf.MockProvider{
+		GetSchemaReturn: &tf.ProviderSchema{
+			ResourceTypes: map[string]*configschema.Block{
+				"google_container_cluster": {
+					Attributes: map[string]*configschema.Attribute{
+						"name":       {Type: cty.String, Optional: true},
+						"node_count": {Type: cty.Number, Optional: true},
+					},
+					BlockTypes: map[string]*configschema.NestedBlock{
+						"master_auth": {
+							Nesting: configschema.NestingList,
+							Block: configschema.Block{
+								Attributes: map[string]*configschema.Attribute{
+									"username": {Type: cty.String, Optional: true},
+									"password": {Type: cty.String, Optional: true, Sensitive: true},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	state := states.NewState()
+	state.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_cluster", Name: "gke_cluster"}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"name": "my-cluster", "node_count": 3, "master_auth": [{"username": "admin", "password": "secret"}]}`),
+		},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+	)
+	state.RootModule().SetOutputValue("endpoint", cty.StringVal("https://example.com"), false)
+	state.RootModule().SetOutputValue("service_account_key", cty.StringVal("key"), true)
+	sf := statefile.New(state, "", 1)
+
+	data, err := stateJSON(sf, mockComponents{provider: provider}, true)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret")
+	require.NotContains(t, string(data), `"key"`)
+
+	var out struct {
+		Values struct {
+			Outputs map[string]struct {
+				Value interface{} `json:"value"`
+			} `json:"outputs"`
+			RootModule struct {
+				Resources []struct {
+					Values map[string]interface{} `json:"values"`
+				} `json:"resources"`
+			} `json:"root_module"`
+		} `json:"values"`
+	}
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.Equal(t, "https://example.com", out.Values.Outputs["endpoint"].Value)
+	require.Equal(t, types.RedactedValue, out.Values.Outputs["service_account_key"].Value, "The outputs flagged in the state should be redacted")
+	require.Equal(t, map[string]interface{}{
+		"name":        "my-cluster",
+		"node_count":  3.0,
+		"master_auth": []interface{}{map[string]interface{}{"username": "admin", "password": types.RedactedValue}},
+	}, out.Values.RootModule.Resources[0].Values, "The attributes flagged in the schema should be redacted")
+
+	data, err = stateJSON(sf, mockComponents{provider: provider}, false)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "secret", "The state should only be redacted on demand")
+}
+
+func TestRedactSensitive(t *testing.T) {
+	t.Parallel()
+	sf := clusterState("null_resource", "cluster", "null", `{"id": "1"}`)
+	sf.State.RootModule().SetOutputValue("endpoint", cty.StringVal("https://example.com"), false)
+	sf.State.RootModule().SetOutputValue("kubeconfig", cty.StringVal("kubeconfig"), false)
+	sf.State.RootModule().SetOutputValue("admin_token", cty.StringVal("token"), true)
+	info, err := clusterInfoFromState(sf)
+	require.NoError(t, err)
+
+	redacted := info.RedactSensitive()
+	require.Equal(t, map[string]interface{}{
+		"endpoint":    "https://example.com",
+		"kubeconfig":  types.RedactedValue,
+		"admin_token": types.RedactedValue,
+	}, redacted.Outputs, "The sensitive outputs and the kubeconfig should be redacted")
+	require.Equal(t, types.RedactedValue, redacted.Kubeconfig)
+	require.Equal(t, "https://example.com", redacted.Endpoint)
+	require.Nil(t, redacted.InternalState, "The state should not be part of the redacted info")
+
+	require.Equal(t, "kubeconfig", info.Kubeconfig, "The info should not be changed")
+	require.Equal(t, "token", info.Outputs["admin_token"])
+	require.NotNil(t, info.TerraformState())
+
+	var none *types.ClusterInfo
+	require.Nil(t, none.RedactSensitive())
+}
@@ -0,0 +1,96 @@
+package terraform
+
+import (
+	"time"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states/statefile"
+	tfversion "github.com/hashicorp/terraform/version"
+	"github.com/kyma-incubator/hydroform/provision/types"
+)
+
+// reporter records the phases of an operation and sends its report to the report handler of the options once it finishes.
+// Without a report handler, it only runs the phases.
+type reporter struct {
+	ops Options
+	// states are the in-memory states of the operator, see WithInMemoryState
+	states *stateStore
+	report types.OperationReport
+}
+
+// newReporter starts the report of the given operation.
+func newReporter(ops Options, states *stateStore, operation string, p types.ProviderType) *reporter {
+	return &reporter{
+		ops:    ops,
+		states: states,
+		report: types.OperationReport{
+			Operation:        operation,
+			Provider:         p,
+			TerraformVersion: tfversion.String(),
+			Start:            time.Now(),
+		},
+	}
+}
+
+// phase runs the given step of the operation and records how long it took and whether it failed.
+func (r *reporter) phase(phase types.ProvisionPhase, step func() error) error {
+	start := time.Now()
+	err := step()
+	r.report.Phases = append(r.report.Phases, types.PhaseReport{
+		Phase:    phase,
+		Duration: time.Since(start),
+		Failed:   err != nil,
+	})
+	return err
+}
+
+// resourcesBefore records the number of resources in the state of the cluster before the operation changes it.
+func (r *reporter) resourcesBefore(project, cluster string, p types.ProviderType) {
+	if r.ops.ReportHandler != nil {
+		r.report.ResourcesBefore = r.resources(project, cluster, p)
+	}
+}
+
+// resourcesAfter records the number of resources in the state of the cluster once the operation changed it.
+// It must be called before the cluster files are cleaned up.
+func (r *reporter) resourcesAfter(project, cluster string, p types.ProviderType) {
+	if r.ops.ReportHandler != nil {
+		r.report.ResourcesAfter = r.resources(project, cluster, p)
+	}
+}
+
+func (r *reporter) resources(project, cluster string, p types.ProviderType) int {
+	sf, err := loadState(r.ops, r.states, project, cluster, p)
+	if err != nil {
+		return 0
+	}
+	return stateResources(sf)
+}
+
+// finish sends the report to the handler with the result of the operation.
+func (r *reporter) finish(err error) {
+	if r.ops.ReportHandler == nil {
+		return
+	}
+	r.report.Duration = time.Since(r.report.Start)
+	if err != nil {
+		r.report.Error = err.Error()
+	}
+	r.ops.ReportHandler(r.report)
+}
+
+// stateResources returns the number of managed resource instances in the given state.
+func stateResources(sf *statefile.File) int {
+	if sf == nil || sf.State == nil {
+		return 0
+	}
+	n := 0
+	for _, m := range sf.State.Modules {
+		for _, rs := range m.Resources {
+			if rs.Addr.Mode == addrs.ManagedResourceMode {
+				n += len(rs.Instances)
+			}
+		}
+	}
+	return n
+}
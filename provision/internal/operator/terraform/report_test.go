@@ -0,0 +1,80 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporter(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-report")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var reports []types.OperationReport
+	ops := options(WithDataDir(dir), WithReportHandler(func(r types.OperationReport) { reports = append(reports, r) }))
+
+	state := states.NewState()
+	for _, name := range []string{"gke_cluster", "gke_node_pool"} {
+		state.RootModule().SetResourceInstanceCurrent(
+			addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_cluster", Name: name}.Instance(addrs.NoKey),
+			&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{}`)},
+			addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+		)
+	}
+	// data sources are not resources of the cluster
+	state.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.DataResourceMode, Type: "google_compute_network", Name: "network"}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{}`)},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+	)
+
+	rep := newReporter(ops, nil, "create", types.GCP)
+	rep.resourcesBefore("my-project", "my-cluster", types.GCP)
+	require.NoError(t, rep.phase(types.InitPhase, func() error { return nil }))
+	err = rep.phase(types.ApplyPhase, func() error {
+		if err := stateToFile(statefile.New(state, "", 0), ops, "my-project", "my-cluster", types.GCP); err != nil {
+			return err
+		}
+		return errors.New("quota exceeded")
+	})
+	require.EqualError(t, err, "quota exceeded", "The error of the phase should be returned")
+	rep.resourcesAfter("my-project", "my-cluster", types.GCP)
+	rep.finish(err)
+
+	require.Len(t, reports, 1)
+	r := reports[0]
+	require.Equal(t, "create", r.Operation)
+	require.Equal(t, types.GCP, r.Provider)
+	require.NotEmpty(t, r.TerraformVersion)
+	require.Equal(t, "quota exceeded", r.Error)
+	require.Len(t, r.Phases, 2, "The phases until the failure should be reported")
+	require.Equal(t, types.InitPhase, r.Phases[0].Phase)
+	require.False(t, r.Phases[0].Failed)
+	require.Equal(t, types.ApplyPhase, r.Phases[1].Phase)
+	require.True(t, r.Phases[1].Failed)
+	require.Equal(t, 0, r.ResourcesBefore)
+	require.Equal(t, 2, r.ResourcesAfter, "Only the managed resources should be counted")
+	require.True(t, r.Duration >= r.Phases[0].Duration+r.Phases[1].Duration)
+
+	// operations failing before running terraform are reported too
+	_, err = New(WithDataDir(dir), WithReportHandler(ops.ReportHandler)).Create(types.GCP, map[string]interface{}{})
+	require.Error(t, err)
+	require.Len(t, reports, 2)
+	require.Empty(t, reports[1].Phases)
+	require.Equal(t, err.Error(), reports[1].Error)
+
+	// without handler nothing is reported
+	rep = newReporter(options(WithDataDir(dir)), nil, "delete", types.GCP)
+	require.NoError(t, rep.phase(types.DestroyPhase, func() error { return nil }))
+	rep.finish(nil)
+	require.Len(t, reports, 2)
+}
@@ -0,0 +1,83 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+)
+
+const (
+	// file names for an existing Azure resource group: the data source reading it, and the override file of the module resources using it instead of creating one
+	tfResourceGroupFile         = "resource_group.tf"
+	tfResourceGroupOverrideFile = "resource_group_override.tf"
+
+	// azureResourceGroup is the resource group created by the Azure module
+	azureResourceGroup = "azure_cluster"
+
+	azureExistingResourceGroupTemplate = `
+data "azurerm_resource_group" "existing" {
+	name = var.resource_group
+}
+`
+
+	azureResourceGroupOverrideTemplate = `
+# the resource group exists already, it is not created nor deleted with the cluster
+resource "azurerm_resource_group" "` + azureResourceGroup + `" {
+	count = 0
+}
+
+resource "azurerm_kubernetes_cluster" "azure_cluster" {
+	location            = data.azurerm_resource_group.existing.location
+	resource_group_name = data.azurerm_resource_group.existing.name
+}
+`
+)
+
+// existingResourceGroup reports whether the cluster goes into an existing Azure resource group, which create_resource_group set to false requests.
+// On GCP the template never creates project-level resources, the cluster always goes into the existing project.
+func existingResourceGroup(p types.ProviderType, cfg map[string]interface{}) bool {
+	create, ok := cfg["create_resource_group"].(bool)
+	return p == types.Azure && ok && !create
+}
+
+// writeResourceGroupFiles makes the Azure module use the existing resource group of the configuration instead of creating one.
+// The files are removed for the clusters creating their resource group, so a configuration can switch back.
+func writeResourceGroupFiles(dir string, p types.ProviderType, cfg map[string]interface{}) error {
+	files := map[string]string{
+		tfResourceGroupFile:         azureExistingResourceGroupTemplate,
+		tfResourceGroupOverrideFile: azureResourceGroupOverrideTemplate,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if !existingResourceGroup(p, cfg) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseResourceGroup returns a copy of the given state without the resource group created by the Azure module, if the configuration uses an existing one.
+// The resource group of a cluster created before it was declared as existing then stays when the cluster is updated or destroyed,
+// terraform would delete it with everything inside it otherwise. It returns nil if the state has no resource group to release.
+func releaseResourceGroup(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) *statefile.File {
+	if !existingResourceGroup(p, cfg) || sf == nil || sf.State == nil {
+		return nil
+	}
+	addr := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "azurerm_resource_group", Name: azureResourceGroup}
+	if sf.State.RootModule().Resource(addr) == nil {
+		return nil
+	}
+	released := sf.DeepCopy()
+	released.State.RootModule().RemoveResource(addr)
+	return released
+}
@@ -0,0 +1,79 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteResourceGroupFiles(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-resource-group")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the Azure resources come from a module, a minimal one is enough to merge the override into
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(`
+variable "resource_group" {}
+variable "location" {}
+
+resource "azurerm_resource_group" "azure_cluster" {
+	name     = var.resource_group
+	location = var.location
+}
+
+resource "azurerm_kubernetes_cluster" "azure_cluster" {
+	location            = azurerm_resource_group.azure_cluster.location
+	resource_group_name = azurerm_resource_group.azure_cluster.name
+}
+`), 0600))
+
+	cfg := map[string]interface{}{"resource_group": "my-group", "create_resource_group": false}
+	require.NoError(t, writeResourceGroupFiles(dir, types.Azure, cfg))
+	mod, diags := configs.NewParser(nil).LoadConfigDir(dir)
+	require.False(t, diags.HasErrors(), "The existing resource group should be valid terraform: %s", diags.Error())
+	require.NotNil(t, mod.ManagedResources["azurerm_resource_group.azure_cluster"].Count, "The resource group should not be created")
+	require.Contains(t, mod.DataResources, "data.azurerm_resource_group.existing")
+	require.NotContains(t, filterVars(cfg, types.Azure), "create_resource_group")
+
+	// the resource group is created by default
+	for _, cfg := range []map[string]interface{}{{}, {"create_resource_group": true}} {
+		require.NoError(t, writeResourceGroupFiles(dir, types.Azure, cfg))
+		for _, f := range []string{tfResourceGroupFile, tfResourceGroupOverrideFile} {
+			_, err = os.Stat(filepath.Join(dir, f))
+			require.True(t, os.IsNotExist(err), "%s should be removed when the resource group is created", f)
+		}
+	}
+
+	// GCP never creates project-level resources
+	require.NoError(t, writeResourceGroupFiles(dir, types.GCP, cfg))
+	_, err = os.Stat(filepath.Join(dir, tfResourceGroupFile))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestReleaseResourceGroup(t *testing.T) {
+	t.Parallel()
+	existing := map[string]interface{}{"resource_group": "my-group", "create_resource_group": false}
+	sf := clusterState("azurerm_kubernetes_cluster", "azure_cluster", "azurerm", `{"id": "1", "name": "my-cluster"}`)
+	require.Nil(t, releaseResourceGroup(sf, types.Azure, existing), "A state without resource group should be used as is")
+
+	sf.State.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "azurerm_resource_group", Name: "azure_cluster"}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"id": "my-group"}`)},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("azurerm")}.Absolute(addrs.RootModuleInstance),
+	)
+	require.Nil(t, releaseResourceGroup(sf, types.Azure, map[string]interface{}{"resource_group": "my-group"}), "A created resource group should stay managed")
+
+	released := releaseResourceGroup(sf, types.Azure, existing)
+	require.NotNil(t, released)
+	require.Len(t, released.State.RootModule().Resources, 1, "Only the cluster resources should be left")
+	require.Contains(t, released.State.RootModule().Resources, "azurerm_kubernetes_cluster.azure_cluster")
+	require.Len(t, sf.State.RootModule().Resources, 2, "The given state should not be changed")
+}
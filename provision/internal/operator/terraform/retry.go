@@ -0,0 +1,43 @@
+package terraform
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+)
+
+// retry runs the given terraform command until it succeeds, fails with an error that is not transient or runs out of attempts.
+// The command always runs on the same cluster dir, so each attempt continues from the state the previous one left.
+// Between attempts it waits an exponential backoff with jitter, so that concurrent operations do not retry at the same time.
+func retry(ctx context.Context, ops Options, cmd func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = cmd(); err == nil || !isTransient(err) || attempt >= ops.Retry.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff(ops.Retry, attempt)):
+		}
+	}
+}
+
+// maxBackoff is the wait after which the backoff stops doubling.
+const maxBackoff = 10 * time.Minute
+
+// backoff returns the time to wait after the given attempt: the configured backoff doubled on each attempt,
+// of which a random half is added as jitter.
+func backoff(r types.Retry, attempt int) time.Duration {
+	d := r.Backoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
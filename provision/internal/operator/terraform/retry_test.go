@@ -0,0 +1,89 @@
+package terraform
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	ops := Options{Retry: types.Retry{MaxAttempts: 3, Backoff: time.Millisecond}}
+
+	// transient errors are retried until success
+	attempts := 0
+	err := retry(context.Background(), ops, func() error {
+		attempts++
+		if attempts < 2 {
+			return errors.Wrap(types.ErrRateLimited, "rate limit")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+
+	// the last error is returned after all attempts
+	attempts = 0
+	err = retry(context.Background(), ops, func() error {
+		attempts++
+		return errors.Wrapf(types.ErrProviderUnavailable, "attempt %d", attempts)
+	})
+	require.EqualError(t, err, "attempt 3: provider API unavailable")
+	require.Equal(t, 3, attempts)
+
+	// other errors fail right away
+	attempts = 0
+	err = retry(context.Background(), ops, func() error {
+		attempts++
+		return errors.Wrap(types.ErrAuthFailed, "forbidden")
+	})
+	require.True(t, errors.Is(err, types.ErrAuthFailed))
+	require.Equal(t, 1, attempts, "Auth errors should not be retried")
+
+	attempts = 0
+	err = retry(context.Background(), ops, func() error {
+		attempts++
+		return errors.Wrap(types.ErrQuotaExceeded, "insufficient regional quota")
+	})
+	require.True(t, errors.Is(err, types.ErrQuotaExceeded))
+	require.Equal(t, 1, attempts, "Exceeded quotas should not be retried")
+
+	// without retry settings commands run once
+	attempts = 0
+	_ = retry(context.Background(), Options{}, func() error {
+		attempts++
+		return types.ErrTimeout
+	})
+	require.Equal(t, 1, attempts)
+}
+
+func TestRetryCancelled(t *testing.T) {
+	t.Parallel()
+	ops := Options{Retry: types.Retry{MaxAttempts: 3, Backoff: time.Hour}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := retry(ctx, ops, func() error {
+		attempts++
+		return types.ErrTimeout
+	})
+	require.Equal(t, types.ErrTimeout, err)
+	require.Equal(t, 1, attempts, "No retries should happen after the context is done")
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	r := types.Retry{Backoff: time.Second}
+
+	for attempt, base := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second} {
+		d := backoff(r, attempt)
+		require.True(t, d >= base/2 && d <= base, "attempt %d waited %s", attempt, d)
+	}
+	require.True(t, backoff(r, 100) <= 2*maxBackoff, "The backoff should stop growing")
+	require.Zero(t, backoff(types.Retry{}, 1))
+}
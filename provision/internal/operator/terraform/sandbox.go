@@ -0,0 +1,102 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// file name of the marker the Sandbox option writes into the cluster directory once an operation succeeded, it holds the time the operation finished
+const sandboxSuccessFile = "hydroform.success"
+
+// sandboxPluginCacheDir is the plugin cache in the data dir of the sandboxed operations and of the operations with workspaces without the PluginCacheDir option.
+const sandboxPluginCacheDir = "plugin-cache"
+
+// sandboxStateFiles are the files copied between the cluster directory and the sandbox, all other files of the operation stay in the sandbox.
+var sandboxStateFiles = []string{tfStateFile, tfStateFile + ".backup"}
+
+// sandbox returns the options and the cluster directory of an operation on the cluster: with the Sandbox option and the Persistent option,
+// the ones of a new temporary dir, the options of the operator and the cluster directory in the data dir otherwise.
+// The terraform data dir and the cluster directory of the sandbox are in the temporary dir, the cluster directory gets the state files of the cluster,
+// and the providers are installed from the plugin cache of the options, so each sandbox does not download them again.
+// It must be called once the cluster is locked, and the returned function must be deferred with the error of the operation before the other files are set up,
+// so it runs once they are released: it copies the state files back, writes or removes the success marker and removes the temporary dir,
+// unless the operation failed with the KeepFailedSandbox option.
+func (t *Terraform) sandbox(project, cluster string, p types.ProviderType) (Options, string, func(err *error), error) {
+	dir, err := clusterDir(t.ops, project, cluster, p)
+	if err != nil {
+		return Options{}, "", nil, err
+	}
+	if !t.ops.Sandbox || !t.ops.Persistent {
+		return t.ops, dir, func(*error) {}, nil
+	}
+
+	tmp, err := ioutil.TempDir("", "hydroform-sandbox-")
+	if err != nil {
+		return Options{}, "", nil, errors.Wrap(err, "could not create the sandbox of the operation")
+	}
+
+	ops := t.ops
+	ops.Meta.OverrideDataDir = tmp
+	ops.PathStrategy = func(string, string, string, types.ProviderType) string {
+		return filepath.Join(tmp, "cluster")
+	}
+	sandboxDir, err := clusterDir(ops, project, cluster, p)
+	if err == nil {
+		err = copyFiles(dir, sandboxDir, sandboxStateFiles)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return Options{}, "", nil, errors.Wrap(err, "could not copy the state into the sandbox of the operation")
+	}
+
+	return ops, sandboxDir, func(err *error) {
+		t.removeFiles(err, func() error {
+			if err := copyFiles(sandboxDir, dir, sandboxStateFiles); err != nil {
+				return errors.Wrapf(err, "could not copy the state out of the sandbox %s", tmp)
+			}
+			return writeSuccessMarker(dir, *err == nil)
+		})
+		if *err != nil && t.ops.KeepFailedSandbox {
+			*err = errors.Wrapf(*err, "the files of the operation are kept in %s", tmp)
+			return
+		}
+		t.removeFiles(err, func() error { return removeAll(tmp) })
+	}, nil
+}
+
+// copyFiles copies the given files from one directory into another, the files missing in the source are removed from the destination.
+func copyFiles(from, to string, files []string) error {
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(from, f))
+		if os.IsNotExist(err) {
+			if err := os.Remove(filepath.Join(to, f)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(to, f), data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSuccessMarker writes the success marker into the given cluster directory if the operation succeeded, and removes it otherwise.
+func writeSuccessMarker(dir string, succeeded bool) error {
+	path := filepath.Join(dir, sandboxSuccessFile)
+	if !succeeded {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0600)
+}
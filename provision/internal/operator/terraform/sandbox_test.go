@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateInSandbox(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-sandbox-create")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+
+output "endpoint" {
+  value = "https://my-cluster.example.com"
+}
+`)}}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), Persistent(), WithSandbox(false))
+	_, err = tf.Create(types.Kind, cfg)
+	require.NoError(t, err)
+
+	clusterDir, err := clusterDir(tf.ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	entries, err := ioutil.ReadDir(clusterDir)
+	require.NoError(t, err)
+	var files []string
+	for _, e := range entries {
+		files = append(files, e.Name())
+	}
+	sort.Strings(files)
+	require.Equal(t, []string{sandboxSuccessFile, tfStateFile}, files, "Only the state and the success marker should be copied to the data dir")
+
+	// the next operation continues from the copied state
+	sf, err := tf.Refresh(nil, types.Kind, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://my-cluster.example.com", sf.State.RootModule().OutputValues["endpoint"].Value.AsString())
+
+	// the in-memory states are kept by the cluster directory the sandbox replaces
+	_, err = New(WithDataDir(dir), WithTemplate(types.Kind, tmpl), Persistent(), WithSandbox(false), WithInMemoryState()).Create(types.Kind, cfg)
+	require.EqualError(t, err, "the in-memory state cannot be used with the Sandbox option")
+}
+
+func TestSandbox(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-sandbox")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tf := New(WithDataDir(dir), Persistent(), WithSandbox(true))
+	realDir, err := clusterDir(tf.ops, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(realDir, tfStateFile), []byte("state"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(realDir, sandboxSuccessFile), []byte("marker"), 0600))
+
+	sandboxed, sandboxDir, finish, err := tf.sandbox("my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.NotEqual(t, realDir, sandboxDir)
+	clusterDirOfSandbox, err := clusterDir(sandboxed, "my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.Equal(t, sandboxDir, clusterDirOfSandbox, "The options of the sandbox should place the cluster in it")
+	require.Equal(t, filepath.Join(dir, sandboxPluginCacheDir), sandboxed.PluginCacheDir, "The plugins should be cached in the data dir")
+	data, err := ioutil.ReadFile(filepath.Join(sandboxDir, tfStateFile))
+	require.NoError(t, err)
+	require.Equal(t, "state", string(data), "The sandbox should get the state of the cluster")
+
+	// a failed operation keeps its partial state and, with keepFailed, its files
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sandboxDir, tfStateFile), []byte("partial state"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sandboxDir, tfPlanFile), []byte("plan"), 0600))
+	opErr := errors.New("apply failed")
+	finish(&opErr)
+	require.Contains(t, opErr.Error(), sandboxed.DataDir(), "The error should tell where the files are kept")
+	_, err = os.Stat(filepath.Join(sandboxDir, tfPlanFile))
+	require.NoError(t, err, "The files of a failed operation should be kept")
+	defer os.RemoveAll(sandboxed.DataDir())
+	data, err = ioutil.ReadFile(filepath.Join(realDir, tfStateFile))
+	require.NoError(t, err)
+	require.Equal(t, "partial state", string(data), "The partial state should be copied back")
+	_, err = os.Stat(filepath.Join(realDir, tfPlanFile))
+	require.True(t, os.IsNotExist(err), "The other files should stay in the sandbox")
+	_, err = os.Stat(filepath.Join(realDir, sandboxSuccessFile))
+	require.True(t, os.IsNotExist(err), "The success marker should be removed")
+
+	// without Persistent the cluster files are removed anyway
+	unsandboxed, unsandboxedDir, _, err := New(WithDataDir(dir), WithSandbox(true)).sandbox("my-project", "my-cluster", types.GCP)
+	require.NoError(t, err)
+	require.Equal(t, dir, unsandboxed.DataDir())
+	require.Equal(t, realDir, unsandboxedDir)
+
+	// the plugin cache of the options is kept
+	cached := New(WithDataDir(dir), Persistent(), WithPluginCacheDir("/path/to/plugins"), WithSandbox(false))
+	require.Equal(t, "/path/to/plugins", cached.ops.PluginCacheDir)
+}
+
+func TestFailedCreateInSandbox(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-sandbox-failed")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+
+output "endpoint" {
+  value = "https://my-cluster.example.com"
+}
+`)}}), Persistent(), WithSandbox(false))
+	_, err = tf.Create(types.Kind, cfg)
+	require.NoError(t, err)
+
+	// the same cluster with a template failing to apply
+	failing := New(WithDataDir(dir), WithTemplate(types.Kind, fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+
+output "endpoint" {
+  value = tonumber(var.cluster_name)
+}
+`)}}), Persistent(), WithSandbox(false))
+	_, err = failing.Create(types.Kind, cfg)
+	require.Error(t, err)
+
+	clusterDir, err := clusterDir(tf.ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	entries, err := ioutil.ReadDir(clusterDir)
+	require.NoError(t, err)
+	var files []string
+	for _, e := range entries {
+		files = append(files, e.Name())
+	}
+	require.Equal(t, []string{tfStateFile}, files, "Only the state should be copied to the data dir after a failure")
+
+	// the state copied back is the one the next operation continues from
+	sf, err := tf.Refresh(nil, types.Kind, cfg)
+	require.NoError(t, err)
+	require.NotNil(t, sf)
+}
@@ -0,0 +1,134 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// file names for the identity and audit settings of AKS: the resources added to the module, and the override file of its cluster
+	tfSecurityFile         = "security.tf"
+	tfSecurityOverrideFile = "security_override.tf"
+
+	// oidcIssuerOutput is the output with the URL of the OIDC issuer of the cluster, for the IAM federation of its service accounts or users.
+	oidcIssuerOutput = "oidc_issuer_url"
+
+	// the API server of an AAD integrated cluster accepts the tokens of the tenant, its issuer is the one of the tenant
+	azureSecurityTemplate = `
+{{- if .OIDCIssuer}}
+output "` + oidcIssuerOutput + `" {
+	value = "https://sts.windows.net/${azurerm_kubernetes_cluster.azure_cluster.role_based_access_control[0].azure_active_directory[0].tenant_id}/"
+}
+{{- end}}
+{{- if .AuditLogging}}
+
+resource "azurerm_log_analytics_workspace" "audit" {
+	name                = "${replace(var.cluster_name, "_", "-")}-audit"
+	location            = azurerm_kubernetes_cluster.azure_cluster.location
+	resource_group_name = azurerm_kubernetes_cluster.azure_cluster.resource_group_name
+	sku                 = "PerGB2018"
+	retention_in_days   = 30
+}
+
+resource "azurerm_monitor_diagnostic_setting" "audit" {
+	name                       = "audit"
+	target_resource_id         = azurerm_kubernetes_cluster.azure_cluster.id
+	log_analytics_workspace_id = azurerm_log_analytics_workspace.audit.id
+
+	log {
+		category = "kube-audit"
+		enabled  = true
+
+		retention_policy {
+			enabled = false
+		}
+	}
+}
+{{- end}}
+`
+
+	azureSecurityOverrideTemplate = `
+resource "azurerm_kubernetes_cluster" "azure_cluster" {
+	role_based_access_control {
+		enabled = true
+
+		azure_active_directory {
+			managed = true
+		}
+	}
+}
+`
+)
+
+// securityKeys are the configuration keys of the identity and audit settings.
+var securityKeys = []string{"workload_identity", "oidc_issuer", "enable_audit_logging"}
+
+// securityProviders are the providers whose built-in templates apply the identity and audit settings.
+// GCP and AWS declare variables for them in their templates, Azure gets them in its own files.
+var securityProviders = map[types.ProviderType]bool{
+	types.GCP:   true,
+	types.Azure: true,
+	types.AWS:   true,
+}
+
+// securitySettings contains the identity and audit settings of a configuration.
+type securitySettings struct {
+	// OIDCIssuer integrates the API server with AAD, whose tenant issues the tokens of the users.
+	OIDCIssuer bool
+	// AuditLogging sends the audit logs of the API server to a Log Analytics workspace.
+	AuditLogging bool
+}
+
+// writeSecurityFiles renders the identity and audit settings of the configuration into the files of the AKS cluster.
+// The files are removed if the settings are off or the provider takes them from the vars, so the settings removed from the configuration are reverted.
+func writeSecurityFiles(dir string, p types.ProviderType, cfg map[string]interface{}) error {
+	s := securitySettings{}
+	s.OIDCIssuer, _ = cfg["oidc_issuer"].(bool)
+	s.AuditLogging, _ = cfg["enable_audit_logging"].(bool)
+
+	files := []struct {
+		name  string
+		tmpl  string
+		write bool
+	}{
+		{tfSecurityFile, azureSecurityTemplate, s.OIDCIssuer || s.AuditLogging},
+		{tfSecurityOverrideFile, azureSecurityOverrideTemplate, s.OIDCIssuer},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if p != types.Azure || !f.write {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		t, err := template.New(f.name).Parse(f.tmpl)
+		if err != nil {
+			return err
+		}
+		b := &strings.Builder{}
+		if err := t.Execute(b, s); err != nil {
+			return errors.Wrap(err, "could not render the identity and audit settings")
+		}
+		if err := ioutil.WriteFile(path, []byte(b.String()), 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// securityErrors returns an error for each identity setting the provider does not support.
+// The azurerm provider of the AKS module has no workload identity, the AAD integration of oidc_issuer is its identity federation.
+func securityErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	if wi, _ := cfg["workload_identity"].(bool); wi && p == types.Azure {
+		return []types.FieldError{{Field: "workload_identity", Reason: "is not supported on Azure, use oidc_issuer for the AAD integration"}}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSecurityFiles(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-security")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the AKS cluster of the module
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(`
+variable "cluster_name" {}
+
+resource "azurerm_kubernetes_cluster" "azure_cluster" {
+	name = var.cluster_name
+
+	role_based_access_control {
+		enabled = true
+	}
+}
+`), 0600))
+
+	require.NoError(t, writeSecurityFiles(dir, types.Azure, map[string]interface{}{"oidc_issuer": true, "enable_audit_logging": true}))
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfSecurityFile))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `output "oidc_issuer_url"`)
+	require.Contains(t, string(data), `category = "kube-audit"`)
+	data, err = ioutil.ReadFile(filepath.Join(dir, tfSecurityOverrideFile))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "managed = true")
+	_, diags := configs.NewParser(nil).LoadConfigDir(dir)
+	require.False(t, diags.HasErrors(), "The settings should override the cluster of the module: %s", diags.Error())
+
+	// audit logs only, the cluster keeps its access control
+	require.NoError(t, writeSecurityFiles(dir, types.Azure, map[string]interface{}{"enable_audit_logging": true}))
+	data, err = ioutil.ReadFile(filepath.Join(dir, tfSecurityFile))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "oidc_issuer_url")
+	_, err = os.Stat(filepath.Join(dir, tfSecurityOverrideFile))
+	require.True(t, os.IsNotExist(err), "The override file should be removed without the OIDC issuer")
+
+	require.NoError(t, writeSecurityFiles(dir, types.Azure, map[string]interface{}{}))
+	_, err = os.Stat(filepath.Join(dir, tfSecurityFile))
+	require.True(t, os.IsNotExist(err), "The security file should be removed without settings")
+
+	require.NoError(t, writeSecurityFiles(dir, types.GCP, map[string]interface{}{"oidc_issuer": true}))
+	_, err = os.Stat(filepath.Join(dir, tfSecurityFile))
+	require.True(t, os.IsNotExist(err), "GCP has the settings in its template")
+
+	// the built-in templates declare the variables and expose the issuer
+	for _, tmpl := range []string{gcpClusterTemplate, awsClusterTemplate} {
+		for _, key := range securityKeys {
+			require.Contains(t, tmpl, `variable "`+key+`"`)
+		}
+		require.Contains(t, tmpl, `output "`+oidcIssuerOutput+`"`)
+	}
+}
+
+func TestSecurityTemplates(t *testing.T) {
+	t.Parallel()
+	for p, tmpl := range map[types.ProviderType]string{types.GCP: gcpClusterTemplate, types.AWS: awsClusterTemplate} {
+		dir, err := ioutil.TempDir("", "hf-security")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(tmpl), 0600))
+		if p == types.GCP {
+			// the node pools use the workload identity of the template
+			require.NoError(t, writeNodePoolsFile(dir, p, map[string]interface{}{"node_pools": testNodePools}))
+		}
+		_, diags := configs.NewParser(nil).LoadConfigDir(dir)
+		require.False(t, diags.HasErrors(), "The %s template should be valid: %s", p, diags.Error())
+	}
+}
+
+func TestSecurityErrors(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, securityErrors(types.GCP, map[string]interface{}{"workload_identity": true}))
+	require.Empty(t, securityErrors(types.AWS, map[string]interface{}{"workload_identity": true, "oidc_issuer": true}))
+	require.Empty(t, securityErrors(types.Azure, map[string]interface{}{"oidc_issuer": true, "enable_audit_logging": true}))
+
+	errs := securityErrors(types.Azure, map[string]interface{}{"workload_identity": true})
+	require.Len(t, errs, 1)
+	require.Equal(t, "workload_identity", errs[0].Field)
+}
+
+func TestFilterSecurityKeys(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{"cluster_name": "my-cluster", "oidc_issuer": true, "enable_audit_logging": true}
+	require.Contains(t, filterVars(cfg, types.GCP), "oidc_issuer")
+	require.Contains(t, filterVars(cfg, types.AWS), "enable_audit_logging")
+	require.NotContains(t, filterVars(cfg, types.Azure), "oidc_issuer", "Azure has the settings in its own files")
+	require.NotContains(t, filterVars(cfg, types.Kind), "oidc_issuer", "The kind template does not declare the variables")
+}
@@ -0,0 +1,94 @@
+package terraform
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// inflightOps tracks the operations of an operator running terraform, so Shutdown can wait for them or cancel them.
+type inflightOps struct {
+	sync.Mutex
+	closed  bool
+	next    int
+	cancels map[int]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// inflightKey marks the context of a tracked operation, so the operations it runs itself, such as the updates of an upgrade, are not tracked again.
+type inflightKey struct{}
+
+func newInflightOps() *inflightOps {
+	return &inflightOps{cancels: make(map[int]context.CancelFunc)}
+}
+
+// begin registers an operation starting with the given context. It returns the context of the operation, canceled by Shutdown when its context is done,
+// and the function to call with the error of the operation once it returned, deferred before the cleanup of the operation so it runs last.
+// The context is done once the OperationTimeout option expires, the function then turns the error into ErrTimeout, see withOperationTimeout.
+// It fails with ErrShuttingDown once Shutdown was called. Operators created without New track nothing.
+func (t *Terraform) begin(ctx context.Context) (context.Context, func(*error), error) {
+	ctx, expire := withOperationTimeout(ctx, t.ops.OperationTimeout)
+	in := t.inflight
+	if in == nil || ctx.Value(inflightKey{}) == in {
+		return ctx, expire, nil
+	}
+
+	in.Lock()
+	if in.closed {
+		in.Unlock()
+		expire(nil)
+		return nil, nil, errors.Wrap(types.ErrShuttingDown, "no new operations are accepted")
+	}
+	id := in.next
+	in.next++
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, inflightKey{}, in))
+	in.cancels[id] = cancel
+	in.wg.Add(1)
+	in.Unlock()
+
+	end := func(err *error) {
+		in.Lock()
+		delete(in.cancels, id)
+		in.Unlock()
+		expire(err)
+		cancel()
+		in.wg.Done()
+	}
+	return ctx, end, nil
+}
+
+// Shutdown stops the operator for a graceful termination of the process: the operations started afterwards fail with ErrShuttingDown,
+// and it waits for the operations in flight to finish. When the given context is done first, the operations still running are canceled,
+// terraform stops them gracefully, and Shutdown still waits for them to return, so their cluster files are cleaned up and their locks released.
+// It then returns the error of the context. The read-only operations, such as Status, are not tracked and keep working.
+func (t *Terraform) Shutdown(ctx context.Context) error {
+	in := t.inflight
+	if in == nil {
+		return nil
+	}
+	in.Lock()
+	in.closed = true
+	in.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		in.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+	}
+
+	in.Lock()
+	canceled := len(in.cancels)
+	for _, cancel := range in.cancels {
+		cancel()
+	}
+	in.Unlock()
+	<-finished
+	return errors.Wrapf(ctx.Err(), "canceled %d operations still running at shutdown", canceled)
+}
@@ -0,0 +1,90 @@
+package terraform
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+	tf := New()
+	ctx, done, err := tf.begin(context.Background())
+	require.NoError(t, err)
+
+	shutdown := make(chan error)
+	go func() { shutdown <- tf.Shutdown(context.Background()) }()
+
+	// the shutdown waits for the operation in flight
+	select {
+	case err := <-shutdown:
+		require.FailNow(t, "Shutdown returned before the operation finished", "%v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	_, _, err = tf.begin(context.Background())
+	require.True(t, errors.Is(err, types.ErrShuttingDown), "New operations should be refused")
+	_, _, err = tf.begin(ctx)
+	require.NoError(t, err, "The operations run by an operation in flight should be accepted")
+	require.NoError(t, ctx.Err(), "The operation should not be canceled")
+
+	done(nil)
+	require.NoError(t, <-shutdown)
+
+	_, err = tf.Create(types.Kind, map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "node_image": "kindest/node:v1.19.1"})
+	require.True(t, errors.Is(err, types.ErrShuttingDown))
+	require.NoError(t, tf.Shutdown(context.Background()), "Shutting down twice should work")
+}
+
+func TestShutdownCancels(t *testing.T) {
+	t.Parallel()
+	tf := New()
+	ctx, done, err := tf.begin(context.Background())
+	require.NoError(t, err)
+
+	cleanedUp := false
+	go func() {
+		// the operation stops when canceled and cleans up
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		cleanedUp = true
+		done(nil)
+	}()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = tf.Shutdown(shutdownCtx)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Contains(t, err.Error(), "canceled 1 operations")
+	require.True(t, cleanedUp, "Shutdown should wait for the canceled operations to return")
+}
+
+func TestShutdownDeleteAsync(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-shutdown")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmpl := fstest.MapFS{"main.tf": {Data: []byte(`
+variable "project" {}
+variable "cluster_name" {}
+`)}}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	tf := New(WithDataDir(dir), WithTemplate(types.Kind, tmpl))
+
+	handle, err := tf.DeleteAsync(nil, types.Kind, cfg)
+	require.NoError(t, err)
+	require.NoError(t, tf.Shutdown(context.Background()))
+	op, err := tf.OperationStatus(handle)
+	require.NoError(t, err)
+	require.NotEqual(t, types.OperationRunning, op.Phase, "Shutdown should wait for the background operations")
+
+	_, err = tf.DeleteAsync(nil, types.Kind, cfg)
+	require.True(t, errors.Is(err, types.ErrShuttingDown))
+}
@@ -0,0 +1,35 @@
+package terraform
+
+import (
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// eksNodeGroup is the node group of the default nodes in the AWS template.
+const eksNodeGroup = "aws_eks_node_group.eks_nodes"
+
+// capacityTypeError checks that an update does not change the capacity type of nodes the provider cannot convert.
+// Node pools on GCP and Azure are replaced when they switch between spot and on-demand VMs, the other pools keep running.
+// The default nodes of an EKS cluster are its only node group though: EKS cannot convert it, and replacing it would evict all workloads at once.
+func capacityTypeError(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
+	if p != types.AWS {
+		return nil
+	}
+	resources, err := driftResources(sf, p)
+	if err != nil {
+		return err
+	}
+	attrs, ok := resources[eksNodeGroup]
+	if !ok {
+		return nil
+	}
+
+	// node groups created before the capacity type was supported run on demand
+	actual, _ := attributeValue(attrs, "capacity_type").(string)
+	spot, _ := cfg["spot"].(bool)
+	if (actual == "SPOT") != spot {
+		return errors.Wrap(types.ErrUnsupportedOperation, "the nodes of an EKS cluster cannot be converted between spot and on-demand instances, create a new cluster instead")
+	}
+	return nil
+}
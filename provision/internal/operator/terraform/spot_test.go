@@ -0,0 +1,31 @@
+package terraform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapacityTypeError(t *testing.T) {
+	t.Parallel()
+	onDemand := clusterState("aws_eks_node_group", "eks_nodes", "aws", `{"capacity_type": "ON_DEMAND"}`)
+	spot := clusterState("aws_eks_node_group", "eks_nodes", "aws", `{"capacity_type": "SPOT"}`)
+
+	require.NoError(t, capacityTypeError(onDemand, types.AWS, map[string]interface{}{}))
+	require.NoError(t, capacityTypeError(spot, types.AWS, map[string]interface{}{"spot": true}))
+	require.NoError(t, capacityTypeError(clusterState("aws_eks_node_group", "eks_nodes", "aws", `{}`), types.AWS, map[string]interface{}{"spot": false}),
+		"Node groups without capacity type should run on demand")
+	require.NoError(t, capacityTypeError(statefile.New(states.NewState(), "", 0), types.AWS, map[string]interface{}{"spot": true}),
+		"A node group that does not exist yet can have any capacity type")
+
+	err := capacityTypeError(onDemand, types.AWS, map[string]interface{}{"spot": true})
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation))
+	err = capacityTypeError(spot, types.AWS, map[string]interface{}{})
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation))
+
+	require.NoError(t, capacityTypeError(onDemand, types.GCP, map[string]interface{}{"spot": true}), "Only the EKS nodes cannot be converted")
+}
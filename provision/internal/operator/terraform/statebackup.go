@@ -0,0 +1,102 @@
+package terraform
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// ExportState writes the stored state of the cluster to the given writer in the format of terraform state files, to back it up.
+// The state is written in plaintext even with the WithStateEncryption option, encrypt the backup where it is kept.
+// It returns ErrStateNotFound if the cluster has no state.
+func (t *Terraform) ExportState(p types.ProviderType, cfg map[string]interface{}, w io.Writer) error {
+	return t.ExportStateWithContext(context.Background(), p, cfg, w)
+}
+
+// ExportStateWithContext works as ExportState but fails right away if the given context is already done, the state is read without it.
+func (t *Terraform) ExportStateWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}, w io.Writer) error {
+	if err := t.preflight(p, cfg); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sf, err := t.lockedState(cfg["project"].(string), cfg["cluster_name"].(string), p)
+	if err != nil {
+		return err
+	}
+	if err := statefile.Write(sf, w); err != nil {
+		return errors.Wrap(err, "could not write the state")
+	}
+	return nil
+}
+
+// ImportState reads a state exported by ExportState from the given reader and stores it as the state of the cluster, to restore a backup.
+// The state must be readable by the embedded terraform and hold the cluster of the configuration, or ErrIdentityMismatch is returned.
+// Nothing is overwritten if the state is invalid. The stored state is replaced as a whole, including its lineage and serial.
+func (t *Terraform) ImportState(p types.ProviderType, cfg map[string]interface{}, r io.Reader) error {
+	return t.ImportStateWithContext(context.Background(), p, cfg, r)
+}
+
+// ImportStateWithContext works as ImportState but fails right away if the given context is already done, the state is written without it.
+// Shutdown waits for the import like for the other operations.
+func (t *Terraform) ImportStateWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}, r io.Reader) (err error) {
+	ctx, done, err := t.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done(&err)
+
+	if err := t.preflight(p, cfg); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "could not read the state")
+	}
+	sf, _, err := readState(data)
+	if err != nil {
+		return errors.Wrap(err, "could not read the state")
+	}
+	recorded, err := recordedIdentity(t.ops, t.states, p, cfg)
+	if err != nil {
+		return err
+	}
+	if err := checkIdentity(sf, p, cfg, recorded); err != nil {
+		return err
+	}
+
+	// lock the cluster, so no operation uses the state while it is replaced
+	unlock, err := lockCluster(t.ops, cfg["project"].(string), cfg["cluster_name"].(string), p)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// the imported state goes through the files of the cluster like the one of an operation, to be kept in memory or encrypted
+	releaseState, err := inMemoryState(t.ops, t.states, cfg["project"].(string), cfg["cluster_name"].(string), p)
+	if err != nil {
+		return err
+	}
+	defer t.removeFiles(&err, releaseState)
+
+	reencryptState, err := encryptedState(t.ops, cfg["project"].(string), cfg["cluster_name"].(string), p)
+	if err != nil {
+		return err
+	}
+	defer t.removeFiles(&err, reencryptState)
+
+	if err := storeState(t.ops, t.states, sf, cfg["project"].(string), cfg["cluster_name"].(string), p); err != nil {
+		return errors.Wrap(err, "could not store the state")
+	}
+	return nil
+}
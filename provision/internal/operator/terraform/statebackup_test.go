@@ -0,0 +1,75 @@
+package terraform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	tfversion "github.com/hashicorp/terraform/version"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportState(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-state-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+	tf := New(WithDataDir(filepath.Join(dir, "source")), WithTemplate(types.Kind, fstest.MapFS{}))
+
+	err = tf.ExportState(types.Kind, cfg, ioutil.Discard)
+	require.True(t, errors.Is(err, types.ErrStateNotFound), "A cluster that was never provisioned should have no state to export")
+
+	sf := clusterState("kind", "kind-cluster", "kind", `{"name": "my-cluster"}`)
+	require.NoError(t, stateToFile(sf, tf.ops, "my-project", "my-cluster", types.Kind))
+	backup := &bytes.Buffer{}
+	require.NoError(t, tf.ExportState(types.Kind, cfg, backup))
+
+	// restore the backup on another machine, with the state encrypted at rest
+	restored := New(WithDataDir(filepath.Join(dir, "target")), WithTemplate(types.Kind, fstest.MapFS{}), WithStateEncryption([]byte("0123456789abcdef")))
+	require.NoError(t, restored.ImportState(types.Kind, cfg, bytes.NewReader(backup.Bytes())))
+
+	clDir, err := clusterDir(restored.ops, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(clDir, tfStateFile))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), encryptedStateHeader), "The imported state should be encrypted like the ones of the operations")
+
+	loaded, err := loadState(restored.ops, restored.states, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	require.Equal(t, sf.Lineage, loaded.Lineage)
+	id, err := stateIdentity(loaded)
+	require.NoError(t, err)
+	require.Equal(t, "my-cluster", id.Name)
+
+	export := &bytes.Buffer{}
+	require.NoError(t, restored.ExportState(types.Kind, cfg, export))
+	require.Equal(t, backup.String(), export.String(), "The export should be in plaintext")
+
+	// invalid states never overwrite the stored one
+	other := &bytes.Buffer{}
+	require.NoError(t, statefile.Write(clusterState("kind", "kind-cluster", "kind", `{"name": "other-cluster"}`), other))
+	err = restored.ImportState(types.Kind, cfg, other)
+	require.True(t, errors.Is(err, types.ErrIdentityMismatch), "The state of another cluster should not be imported")
+
+	// writing a state always records the embedded terraform
+	future := strings.Replace(backup.String(), fmt.Sprintf(`"terraform_version": %q`, tfversion.SemVer), `"terraform_version": "99.0.0"`, 1)
+	require.NotEqual(t, backup.String(), future)
+	err = restored.ImportState(types.Kind, cfg, strings.NewReader(future))
+	require.True(t, errors.Is(err, types.ErrStateVersionMismatch), "A state of a newer terraform should not be imported")
+
+	require.Error(t, restored.ImportState(types.Kind, cfg, strings.NewReader("not a state")))
+
+	loaded, err = loadState(restored.ops, restored.states, "my-project", "my-cluster", types.Kind)
+	require.NoError(t, err)
+	require.Equal(t, sf.Lineage, loaded.Lineage, "The stored state should be left as is")
+}
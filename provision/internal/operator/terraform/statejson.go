@@ -0,0 +1,215 @@
+package terraform
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/command/jsonstate"
+	tfplugin "github.com/hashicorp/terraform/plugin"
+	"github.com/hashicorp/terraform/plugin/discovery"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/hashicorp/terraform/provisioners"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	tf "github.com/hashicorp/terraform/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// StateJSON returns the state of the cluster in the JSON format of 'terraform show -json', for tools reading terraform states.
+// The state is loaded from where the operations store it, usually the state file in the data dir, no terraform command is run.
+// States written in an older format or with older resource schemas are upgraded in memory, the stored state is left as is.
+// The provider plugins of the state must be installed, which is the case for any cluster provisioned on this machine.
+func (t *Terraform) StateJSON(p types.ProviderType, cfg map[string]interface{}) ([]byte, error) {
+	return t.StateJSONWithContext(context.Background(), p, cfg)
+}
+
+// StateJSONWithContext works as StateJSON but fails right away if the given context is already done, the state is read without it.
+func (t *Terraform) StateJSONWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) ([]byte, error) {
+	return t.stateJSON(ctx, p, cfg, false)
+}
+
+// RedactedStateJSON works as StateJSON but replaces the sensitive values with types.RedactedValue, such as to attach the state to a bug report.
+// The values are the ones terraform marks sensitive: the outputs flagged in the state and the attributes the schemas of the providers flag,
+// so the sensitive outputs of custom templates are redacted as well. Resources of a provider without schema have all their values redacted.
+func (t *Terraform) RedactedStateJSON(p types.ProviderType, cfg map[string]interface{}) ([]byte, error) {
+	return t.RedactedStateJSONWithContext(context.Background(), p, cfg)
+}
+
+// RedactedStateJSONWithContext works as RedactedStateJSON but fails right away if the given context is already done, the state is read without it.
+func (t *Terraform) RedactedStateJSONWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) ([]byte, error) {
+	return t.stateJSON(ctx, p, cfg, true)
+}
+
+// stateJSON loads the state of the cluster and marshals it, with its sensitive values redacted if redact is set.
+func (t *Terraform) stateJSON(ctx context.Context, p types.ProviderType, cfg map[string]interface{}, redact bool) ([]byte, error) {
+	if err := t.preflight(p, cfg); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sf, err := t.lockedState(cfg["project"].(string), cfg["cluster_name"].(string), p)
+	if err != nil {
+		return nil, err
+	}
+	return stateJSON(sf, installedProviders(t.ops), redact)
+}
+
+// components creates the providers terraform needs to get the schemas of a state.
+type components interface {
+	ResourceProvider(typ, uid string) (providers.Interface, error)
+	ResourceProviders() []string
+	ResourceProvisioner(typ, uid string) (provisioners.Interface, error)
+	ResourceProvisioners() []string
+}
+
+// stateJSON upgrades a copy of the given state to the schemas of the given providers and marshals it.
+// If redact is set, the sensitive values are redacted.
+func stateJSON(sf *statefile.File, pp components, redact bool) ([]byte, error) {
+	sf = sf.DeepCopy()
+	if sf.State == nil {
+		sf.State = states.NewState()
+	}
+
+	schemas, err := tf.LoadSchemas(nil, sf.State, pp)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load the schemas of the providers")
+	}
+	if err := upgradeState(sf.State, schemas, pp); err != nil {
+		return nil, err
+	}
+
+	data, err := jsonstate.Marshal(sf, schemas)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal the state")
+	}
+	if redact {
+		return redactStateJSON(data, schemas)
+	}
+	return data, nil
+}
+
+// upgradeState upgrades the managed resources of the state written with an older schema version than the one of their provider.
+func upgradeState(state *states.State, schemas *tf.Schemas, pp components) error {
+	opened := make(map[string]providers.Interface)
+	defer func() {
+		for _, p := range opened {
+			p.Close()
+		}
+	}()
+
+	for _, m := range state.Modules {
+		for _, r := range m.Resources {
+			if r.Addr.Mode != addrs.ManagedResourceMode {
+				continue
+			}
+			typeName := r.ProviderConfig.ProviderConfig.Type.LegacyString()
+			schema, version := schemas.ResourceTypeConfig(typeName, r.Addr.Mode, r.Addr.Type)
+			if schema == nil {
+				return errors.Errorf("no schema found for %s", r.Addr)
+			}
+
+			for _, ri := range r.Instances {
+				objs := []*states.ResourceInstanceObjectSrc{ri.Current}
+				for _, o := range ri.Deposed {
+					objs = append(objs, o)
+				}
+				for _, o := range objs {
+					if o == nil || o.SchemaVersion >= version {
+						continue
+					}
+					provider, ok := opened[typeName]
+					if !ok {
+						var err error
+						if provider, err = pp.ResourceProvider(typeName, "upgrade/"+typeName); err != nil {
+							return err
+						}
+						opened[typeName] = provider
+					}
+
+					resp := provider.UpgradeResourceState(providers.UpgradeResourceStateRequest{
+						TypeName:        r.Addr.Type,
+						Version:         int64(o.SchemaVersion),
+						RawStateJSON:    o.AttrsJSON,
+						RawStateFlatmap: o.AttrsFlat,
+					})
+					if resp.Diagnostics.HasErrors() {
+						return errors.Wrapf(resp.Diagnostics.Err(), "could not upgrade the state of %s", r.Addr)
+					}
+					attrs, err := ctyjson.Marshal(resp.UpgradedState, schema.ImpliedType())
+					if err != nil {
+						return errors.Wrapf(err, "could not encode the upgraded state of %s", r.Addr)
+					}
+					o.AttrsJSON = attrs
+					o.AttrsFlat = nil
+					o.SchemaVersion = version
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// pluginProviders starts the installed provider plugins by name, to get their schemas outside of terraform commands.
+// The plugins log to the writer of the options they were installed with, see pluginLogOutput.
+type pluginProviders struct {
+	ops   Options
+	metas map[string]discovery.PluginMeta
+}
+
+// installedProviders returns the newest version of each provider plugin installed by init or in the global plugin dirs.
+func installedProviders(ops Options) pluginProviders {
+	pp := pluginProviders{ops: ops, metas: make(map[string]discovery.PluginMeta)}
+	for name, metas := range discovery.FindPlugins("provider", pluginDirs(ops)).ByName() {
+		pp.metas[name] = metas.Newest()
+	}
+	return pp
+}
+
+// ResourceProvider starts the plugin of the given provider type.
+func (pp pluginProviders) ResourceProvider(typ, uid string) (providers.Interface, error) {
+	meta, ok := pp.metas[typ]
+	if !ok {
+		return nil, errors.Errorf("the %s provider plugin is not installed", typ)
+	}
+
+	client := plugin.NewClient(pluginClientConfig(pp.ops, meta))
+	rpcClient, err := client.Client()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rpcClient.Dispense(tfplugin.ProviderPluginName)
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+	// the provider kills the plugin when it is closed
+	p := raw.(*tfplugin.GRPCProvider)
+	p.PluginClient = client
+	return p, nil
+}
+
+// ResourceProviders returns the names of the installed provider plugins.
+func (pp pluginProviders) ResourceProviders() []string {
+	names := make([]string, 0, len(pp.metas))
+	for name := range pp.metas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResourceProvisioner always fails, states do not need provisioners.
+func (pp pluginProviders) ResourceProvisioner(typ, uid string) (provisioners.Interface, error) {
+	return nil, errors.Errorf("provisioner %s is not available", typ)
+}
+
+// ResourceProvisioners returns no provisioners, states do not need them.
+func (pp pluginProviders) ResourceProvisioners() []string {
+	return nil
+}
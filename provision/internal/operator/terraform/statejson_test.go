@@ -0,0 +1,112 @@
+package terraform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/hashicorp/terraform/provisioners"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	tf "github.com/hashicorp/terraform/terraform"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// mockComponents provides the same mock provider for all provider types.
+type mockComponents struct {
+	provider *tf.MockProvider
+}
+
+func (m mockComponents) ResourceProvider(typ, uid string) (providers.Interface, error) {
+	return m.provider, nil
+}
+
+func (m mockComponents) ResourceProviders() []string {
+	return []string{"google"}
+}
+
+func (m mockComponents) ResourceProvisioner(typ, uid string) (provisioners.Interface, error) {
+	return nil, errors.New("no provisioners")
+}
+
+func (m mockComponents) ResourceProvisioners() []string {
+	return nil
+}
+
+func TestStateJSON(t *testing.T) {
+	t.Parallel()
+	provider := &tf.MockProvider{
+		GetSchemaReturn: &tf.ProviderSchema{
+			ResourceTypes: map[string]*configschema.Block{
+				"google_container_cluster": {
+					Attributes: map[string]*configschema.Attribute{
+						"name":     {Type: cty.String, Optional: true},
+						"location": {Type: cty.String, Optional: true},
+					},
+				},
+			},
+			ResourceTypeSchemaVersions: map[string]uint64{"google_container_cluster": 1},
+		},
+		// version 0 called the location zone
+		UpgradeResourceStateFn: func(r providers.UpgradeResourceStateRequest) providers.UpgradeResourceStateResponse {
+			var attrs map[string]string
+			if err := json.Unmarshal(r.RawStateJSON, &attrs); err != nil {
+				panic(err)
+			}
+			return providers.UpgradeResourceStateResponse{
+				UpgradedState: cty.ObjectVal(map[string]cty.Value{
+					"name":     cty.StringVal(attrs["name"]),
+					"location": cty.StringVal(attrs["zone"]),
+				}),
+			}
+		},
+	}
+
+	state := states.NewState()
+	state.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_cluster", Name: "gke_cluster"}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{
+			Status:        states.ObjectReady,
+			SchemaVersion: 0,
+			AttrsJSON:     []byte(`{"name": "my-cluster", "zone": "europe-west3-a"}`),
+		},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+	)
+	sf := statefile.New(state, "", 1)
+
+	data, err := stateJSON(sf, mockComponents{provider: provider}, false)
+	require.NoError(t, err)
+	require.True(t, provider.UpgradeResourceStateCalled, "The resource of the older schema version should be upgraded")
+
+	var out struct {
+		FormatVersion string `json:"format_version"`
+		Values        struct {
+			RootModule struct {
+				Resources []struct {
+					Address       string                 `json:"address"`
+					SchemaVersion uint64                 `json:"schema_version"`
+					Values        map[string]interface{} `json:"values"`
+				} `json:"resources"`
+			} `json:"root_module"`
+		} `json:"values"`
+	}
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.NotEmpty(t, out.FormatVersion)
+	require.Len(t, out.Values.RootModule.Resources, 1)
+	r := out.Values.RootModule.Resources[0]
+	require.Equal(t, "google_container_cluster.gke_cluster", r.Address)
+	require.Equal(t, uint64(1), r.SchemaVersion)
+	require.Equal(t, map[string]interface{}{"name": "my-cluster", "location": "europe-west3-a"}, r.Values)
+
+	obj := sf.State.RootModule().Resources["google_container_cluster.gke_cluster"].Instances[addrs.NoKey].Current
+	require.Equal(t, uint64(0), obj.SchemaVersion, "The given state should not be changed")
+
+	// empty states have no values
+	data, err = stateJSON(statefile.New(states.NewState(), "", 1), mockComponents{provider: provider}, false)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "values")
+}
@@ -0,0 +1,81 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	goversion "github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform/states/statefile"
+	tfversion "github.com/hashicorp/terraform/version"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// stateFormatVersion is the format version of the state files the embedded terraform writes, it reads the older ones too
+	stateFormatVersion = 4
+	// legacyStateMagic starts the binary states of the terraform versions before 0.7
+	legacyStateMagic = "tfstate"
+)
+
+// readState reads the given state file content and returns its format version. States in an older format are upgraded in memory by terraform,
+// states written by a newer terraform, or in a format it cannot read, fail with a StateVersionError before terraform reads them,
+// since terraform either fails with an obscure error on their newer provider addresses, or may not understand them.
+func readState(data []byte) (*statefile.File, uint64, error) {
+	if bytes.HasPrefix(data, []byte(legacyStateMagic)) {
+		return nil, 0, stateVersionError("", 0)
+	}
+
+	var sniff struct {
+		Version          *uint64 `json:"version"`
+		TerraformVersion string  `json:"terraform_version"`
+	}
+	// the state is not JSON or has no version, terraform names the problem
+	if err := json.Unmarshal(data, &sniff); err == nil && sniff.Version != nil {
+		if *sniff.Version > stateFormatVersion {
+			return nil, *sniff.Version, stateVersionError(sniff.TerraformVersion, *sniff.Version)
+		}
+		if v, err := goversion.NewVersion(sniff.TerraformVersion); err == nil && v.GreaterThan(tfversion.SemVer) {
+			return nil, *sniff.Version, stateVersionError(sniff.TerraformVersion, *sniff.Version)
+		}
+	}
+
+	sf, err := statefile.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	format := uint64(stateFormatVersion)
+	if sniff.Version != nil {
+		format = *sniff.Version
+	}
+	return sf, format, nil
+}
+
+// stateVersionError returns the StateVersionError of a state written by the given terraform version in the given format.
+func stateVersionError(stateVersion string, format uint64) error {
+	return &types.StateVersionError{StateVersion: stateVersion, FormatVersion: format, SupportedVersion: tfversion.SemVer.String()}
+}
+
+// upgradeStateFile replaces the state file in the given directory, in an older format, with the given state read from it in the current format,
+// so terraform and the other tools reading the file get the upgraded state. The previous file is kept as the backup of the state,
+// encrypted states stay encrypted.
+func upgradeStateFile(ops Options, dir string, sf *statefile.File, previous []byte) error {
+	var buf bytes.Buffer
+	if err := statefile.Write(sf, &buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if bytes.HasPrefix(previous, []byte(encryptedStateHeader)) {
+		var err error
+		if data, err = encryptState(ops.StateEncryptionKey, data); err != nil {
+			return err
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, tfStateFile+".backup"), previous, 0600); err != nil {
+		return errors.Wrap(err, "could not back up the state before upgrading it")
+	}
+	return ioutil.WriteFile(filepath.Join(dir, tfStateFile), data, 0600)
+}
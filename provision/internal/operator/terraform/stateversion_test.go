@@ -0,0 +1,107 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tfversion "github.com/hashicorp/terraform/version"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// v3State is a state written by terraform 0.11
+const v3State = `{
+  "version": 3,
+  "terraform_version": "0.11.14",
+  "serial": 2,
+  "lineage": "lineage",
+  "modules": [{
+    "path": ["root"],
+    "outputs": {"endpoint": {"sensitive": false, "type": "string", "value": "https://my-cluster.example.com"}},
+    "resources": {
+      "null_resource.custom": {
+        "type": "null_resource",
+        "depends_on": [],
+        "primary": {"id": "123", "attributes": {"id": "123"}, "meta": {}, "tainted": false},
+        "deposed": [],
+        "provider": "provider.null"
+      }
+    },
+    "depends_on": []
+  }]
+}`
+
+func TestReadState(t *testing.T) {
+	t.Parallel()
+	_, _, err := readState([]byte(`{"version": 4, "terraform_version": "0.14.0", "serial": 1, "lineage": "lineage", "outputs": {}, "resources": []}`))
+	var versionErr *types.StateVersionError
+	require.True(t, errors.As(err, &versionErr), "A state of a newer terraform should fail")
+	require.True(t, errors.Is(err, types.ErrStateVersionMismatch))
+	require.Equal(t, "0.14.0", versionErr.StateVersion)
+	require.Equal(t, uint64(4), versionErr.FormatVersion)
+	require.Equal(t, tfversion.SemVer.String(), versionErr.SupportedVersion)
+	require.Contains(t, err.Error(), "0.14.0")
+	require.Contains(t, err.Error(), tfversion.SemVer.String())
+
+	_, _, err = readState([]byte(`{"version": 5, "serial": 1, "lineage": "lineage"}`))
+	require.True(t, errors.As(err, &versionErr), "A newer format should fail")
+	require.Equal(t, uint64(5), versionErr.FormatVersion)
+	require.Empty(t, versionErr.StateVersion)
+
+	_, _, err = readState([]byte("tfstate\x00\x01"))
+	require.True(t, errors.Is(err, types.ErrStateVersionMismatch), "The binary states of terraform 0.6 should fail")
+
+	sf, format, err := readState([]byte(v3State))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), format)
+	require.Equal(t, "lineage", sf.Lineage)
+
+	_, _, err = readState([]byte("not a state"))
+	require.Error(t, err)
+	require.False(t, errors.Is(err, types.ErrStateVersionMismatch), "Invalid states are not version mismatches")
+}
+
+func TestUpgradeStateFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-stateversion")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name string
+		ops  Options
+	}{
+		{name: "plaintext", ops: options(WithDataDir(filepath.Join(dir, "plaintext")))},
+		{name: "encrypted", ops: options(WithDataDir(filepath.Join(dir, "encrypted")), WithStateEncryption(testStateKey))},
+	} {
+		clusterDir, err := clusterDir(tc.ops, "my-project", "my-cluster", types.GCP)
+		require.NoError(t, err)
+		data := []byte(v3State)
+		if len(tc.ops.StateEncryptionKey) > 0 {
+			data, err = encryptState(tc.ops.StateEncryptionKey, data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, ioutil.WriteFile(filepath.Join(clusterDir, tfStateFile), data, 0600))
+
+		sf, err := stateFromFile(tc.ops, "my-project", "my-cluster", types.GCP)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, "https://my-cluster.example.com", sf.State.RootModule().OutputValues["endpoint"].Value.AsString(), tc.name)
+
+		backup, err := ioutil.ReadFile(filepath.Join(clusterDir, tfStateFile+".backup"))
+		require.NoError(t, err, tc.name)
+		require.Equal(t, data, backup, "%s: the previous state should be backed up", tc.name)
+
+		upgraded, err := ioutil.ReadFile(filepath.Join(clusterDir, tfStateFile))
+		require.NoError(t, err, tc.name)
+		plain, err := decryptState(tc.ops.StateEncryptionKey, upgraded)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, len(tc.ops.StateEncryptionKey) > 0, len(plain) != len(upgraded), "%s: the state should stay encrypted", tc.name)
+		reread, format, err := readState(plain)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, "lineage", reread.Lineage, tc.name)
+		require.Equal(t, uint64(stateFormatVersion), format, "%s: the file should be in the current format", tc.name)
+	}
+}
@@ -0,0 +1,148 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// healthyStatuses and progressingStatuses are the values of the status attributes of cluster resources, in lower case, that do not indicate a failure.
+// Any other status, such as "failed", "degraded" or "deleting", makes the resource Failed.
+var (
+	healthyStatuses = map[string]bool{
+		"active":          true,
+		"running":         true,
+		"ready":           true,
+		"create_complete": true,
+		"update_complete": true,
+	}
+	progressingStatuses = map[string]bool{
+		"creating":           true,
+		"provisioning":       true,
+		"pending":            true,
+		"updating":           true,
+		"upgrading":          true,
+		"reconciling":        true,
+		"create_in_progress": true,
+		"update_in_progress": true,
+	}
+)
+
+// detailedStatus returns the health of the control plane and the node pools of the cluster in the given state.
+// Node pools of the configuration that are not in the state are reported as missing.
+func detailedStatus(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.DetailedStatus, error) {
+	ds := &types.DetailedStatus{Phase: types.Unknown}
+	if sf == nil || sf.State == nil || !sf.State.HasResources() {
+		return ds, nil
+	}
+
+	ds.ControlPlane = &types.ResourceStatus{
+		Name:    fmt.Sprintf("%v", cfg["cluster_name"]),
+		Address: clusterResource(p),
+		Health:  types.Missing,
+		Reason:  "the cluster resource is not in the state",
+	}
+	pools := make(map[string]types.ResourceStatus)
+	configured, _ := cfg["node_pools"].([]types.NodePool)
+	for _, pool := range configured {
+		pools[pool.Name] = types.ResourceStatus{
+			Name:    pool.Name,
+			Address: fmt.Sprintf("%s.%s", nodePoolTypes[p], pool.Name),
+			Health:  types.Missing,
+			Reason:  "the node pool is not in the state",
+		}
+	}
+
+	for _, r := range sf.State.RootModule().Resources {
+		_, isPool := nodePoolResources[r.Addr.Type]
+		if r.Addr.Mode != addrs.ManagedResourceMode || (!isPool && r.Addr.String() != clusterResource(p)) {
+			continue
+		}
+		rs, err := resourceStatus(r)
+		if err != nil {
+			return nil, err
+		}
+		if isPool {
+			pools[r.Addr.Name] = rs
+		} else {
+			ds.ControlPlane = &rs
+		}
+	}
+
+	ds.NodePools = make([]types.ResourceStatus, 0, len(pools))
+	for _, rs := range pools {
+		ds.NodePools = append(ds.NodePools, rs)
+	}
+	sort.Slice(ds.NodePools, func(i, j int) bool { return ds.NodePools[i].Name < ds.NodePools[j].Name })
+
+	ds.Phase = clusterPhase(append([]types.ResourceStatus{*ds.ControlPlane}, ds.NodePools...))
+	return ds, nil
+}
+
+// resourceStatus returns the health of the given resource based on its state and the status reported by the provider.
+// Resources whose creation did not complete are tainted by terraform.
+// GKE resources have no status, but the name of the operation in progress.
+func resourceStatus(r *states.Resource) (types.ResourceStatus, error) {
+	rs := types.ResourceStatus{
+		Name:    r.Addr.Name,
+		Address: r.Addr.String(),
+		Health:  types.Missing,
+		Reason:  "the resource does not exist",
+	}
+	i := r.Instance(addrs.NoKey)
+	if i == nil || i.Current == nil {
+		return rs, nil
+	}
+
+	attrs := make(map[string]interface{})
+	if err := json.Unmarshal(i.Current.AttrsJSON, &attrs); err != nil {
+		return rs, errors.Wrapf(err, "could not decode the attributes of %s", r.Addr)
+	}
+	if name, ok := attrs["name"].(string); ok && name != "" {
+		rs.Name = name
+	}
+
+	status, _ := attrs["status"].(string)
+	operation, _ := attrs["operation"].(string)
+	switch {
+	case i.Current.Status == states.ObjectTainted:
+		rs.Health, rs.Reason = types.Failed, "the resource was not created completely"
+	case status != "":
+		rs.Reason = fmt.Sprintf("the provider reports the status %s", status)
+		switch s := strings.ToLower(status); {
+		case healthyStatuses[s]:
+			rs.Health = types.Healthy
+		case progressingStatuses[s]:
+			rs.Health = types.Progressing
+		default:
+			rs.Health = types.Failed
+		}
+	case operation != "":
+		rs.Health, rs.Reason = types.Progressing, fmt.Sprintf("the operation %s is in progress", operation)
+	default:
+		rs.Health, rs.Reason = types.Healthy, ""
+	}
+	return rs, nil
+}
+
+// clusterPhase returns the phase of a cluster with resources of the given statuses.
+// A cluster is only provisioned if all its resources are healthy.
+func clusterPhase(statuses []types.ResourceStatus) types.Phase {
+	phase := types.Provisioned
+	for _, rs := range statuses {
+		switch rs.Health {
+		case types.Failed, types.Missing:
+			return types.Errored
+		case types.Progressing:
+			phase = types.Provisioning
+		}
+	}
+	return phase
+}
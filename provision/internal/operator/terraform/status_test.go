@@ -0,0 +1,138 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetailedStatus(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"cluster_name": "hydro",
+		"node_pools":   []types.NodePool{{Name: "general"}, {Name: "gpu"}},
+	}
+
+	ds, err := detailedStatus(statefile.New(states.NewState(), "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, &types.DetailedStatus{Phase: types.Unknown}, ds, "A cluster without resources should have an unknown status")
+
+	state := states.NewState()
+	setResource := func(typ, name, attrs string, status states.ObjectStatus) {
+		state.RootModule().SetResourceInstanceCurrent(
+			addrs.Resource{Mode: addrs.ManagedResourceMode, Type: typ, Name: name}.Instance(addrs.NoKey),
+			&states.ResourceInstanceObjectSrc{Status: status, AttrsJSON: []byte(attrs)},
+			addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+		)
+	}
+
+	// a half-provisioned cluster: the control plane is being created and a node pool is missing
+	setResource("google_container_cluster", "gke_cluster", `{"name": "hydro", "operation": "operation-123"}`, states.ObjectReady)
+	setResource("google_container_node_pool", "general", `{"name": "general"}`, states.ObjectReady)
+	ds, err = detailedStatus(statefile.New(state, "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Errored, ds.Phase, "A cluster with a missing node pool should be errored")
+	require.Equal(t, types.Progressing, ds.ControlPlane.Health)
+	require.Equal(t, "hydro", ds.ControlPlane.Name)
+	require.Equal(t, "google_container_cluster.gke_cluster", ds.ControlPlane.Address)
+	require.Len(t, ds.NodePools, 2)
+	require.Equal(t, types.Healthy, ds.NodePools[0].Health)
+	require.Equal(t, types.Missing, ds.NodePools[1].Health)
+	require.Equal(t, "google_container_node_pool.gpu", ds.NodePools[1].Address)
+
+	// all node pools there, the control plane still in progress
+	setResource("google_container_node_pool", "gpu", `{"name": "gpu"}`, states.ObjectReady)
+	ds, err = detailedStatus(statefile.New(state, "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Provisioning, ds.Phase)
+
+	// a node pool that failed to be created
+	setResource("google_container_cluster", "gke_cluster", `{"name": "hydro"}`, states.ObjectReady)
+	setResource("google_container_node_pool", "gpu", `{"name": "gpu"}`, states.ObjectTainted)
+	ds, err = detailedStatus(statefile.New(state, "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Errored, ds.Phase, "A cluster with a tainted node pool should be errored")
+	require.Equal(t, types.Failed, ds.NodePools[1].Health)
+
+	setResource("google_container_node_pool", "gpu", `{"name": "gpu"}`, states.ObjectReady)
+	ds, err = detailedStatus(statefile.New(state, "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Provisioned, ds.Phase, "A cluster with all resources healthy should be provisioned")
+}
+
+func TestResourceStatus(t *testing.T) {
+	t.Parallel()
+	state := states.NewState()
+	for status, health := range map[string]types.Health{
+		"ACTIVE":        types.Healthy,
+		"running":       types.Healthy,
+		"CREATING":      types.Progressing,
+		"provisioning":  types.Progressing,
+		"CREATE_FAILED": types.Failed,
+		"degraded":      types.Failed,
+	} {
+		addr := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "aws_eks_cluster", Name: "eks_cluster"}
+		state.RootModule().SetResourceInstanceCurrent(
+			addr.Instance(addrs.NoKey),
+			&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"status": "` + status + `"}`)},
+			addrs.ProviderConfig{Type: addrs.NewLegacyProvider("aws")}.Absolute(addrs.RootModuleInstance),
+		)
+		rs, err := resourceStatus(state.RootModule().Resource(addr))
+		require.NoError(t, err)
+		require.Equal(t, health, rs.Health, "The status %s should be %s", status, health)
+		require.Equal(t, "eks_cluster", rs.Name, "The resource name should be used without name attribute")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-status-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tf := New(WithDataDir(dir))
+	cfg := map[string]interface{}{
+		"project":               "my-project",
+		"cluster_name":          "hydro",
+		"credentials_file_path": "/path/to/credentials",
+		"location":              "europe-west3-a",
+		"node_count":            3,
+		"machine_type":          "n1-standard-4",
+		"disk_size":             30,
+		"kubernetes_version":    "1.16",
+		"node_pools":            []types.NodePool{{Name: "general", MachineType: "n1-standard-4", NodeCount: 1}},
+	}
+
+	// refreshing a cluster without resources has nothing to do
+	sf, err := tf.Refresh(statefile.New(states.NewState(), "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	cs, err := tf.Status(sf, types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Unknown, cs.Phase)
+
+	// the node pool was deleted outside of terraform and removed from the state by a refresh
+	state := states.NewState()
+	state.RootModule().SetResourceInstanceCurrent(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "google_container_cluster", Name: "gke_cluster"}.Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"name": "hydro"}`)},
+		addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance),
+	)
+	cs, err = tf.Status(statefile.New(state, "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Errored, cs.Phase, "A cluster with a missing node pool should not be provisioned")
+
+	cs, err = New(WithDataDir(dir), WithTemplate(types.GCP, testTemplate)).Status(statefile.New(state, "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Provisioned, cs.Phase, "A custom template with resources should be provisioned")
+
+	delete(cfg, "node_pools")
+	cs, err = tf.Status(statefile.New(state, "", 0), types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Provisioned, cs.Phase)
+}
@@ -0,0 +1,54 @@
+package terraform
+
+import (
+	"regexp"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+)
+
+// appliedResource matches the messages terraform outputs once it finished changing a resource, such as
+// "google_container_cluster.gke_cluster: Creation complete after 5m2s [id=projects/my-project/locations/europe-west3/clusters/my-cluster]".
+var appliedResource = regexp.MustCompile(`^(.+?): (Creation|Modifications|Destruction) complete`)
+
+// applySummary collects the resources changed by the applies of an operation from their progress events.
+// Terraform reports the progress one line at a time, so the summary needs no lock.
+type applySummary struct {
+	summary types.ApplySummary
+}
+
+// options returns a copy of the given options whose progress handler also records the resources applied by terraform.
+// The events are still sent to the progress handler of the options, if any.
+func (s *applySummary) options(ops Options) Options {
+	forward := ops.ProgressHandler
+	ops.ProgressHandler = func(e types.ProvisionEvent) {
+		s.record(e)
+		if forward != nil {
+			forward(e)
+		}
+	}
+	return ops
+}
+
+func (s *applySummary) record(e types.ProvisionEvent) {
+	m := appliedResource.FindStringSubmatch(e.Message)
+	if e.Phase != types.ApplyPhase || m == nil {
+		return
+	}
+	switch m[2] {
+	case "Creation":
+		s.summary.Added++
+		s.summary.AddedResources = append(s.summary.AddedResources, m[1])
+	case "Modifications":
+		s.summary.Changed++
+		s.summary.ChangedResources = append(s.summary.ChangedResources, m[1])
+	case "Destruction":
+		s.summary.Destroyed++
+		s.summary.DestroyedResources = append(s.summary.DestroyedResources, m[1])
+	}
+}
+
+// result returns a copy of the collected summary.
+func (s *applySummary) result() *types.ApplySummary {
+	summary := s.summary
+	return &summary
+}
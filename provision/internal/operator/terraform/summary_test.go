@@ -0,0 +1,40 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/command"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplySummary(t *testing.T) {
+	t.Parallel()
+	var events []types.ProvisionEvent
+	summary := &applySummary{}
+	ops := summary.options(options(WithProgressHandler(func(e types.ProvisionEvent) { events = append(events, e) })))
+	ui := progressMeta(command.Meta{Ui: &HydroUI{}}, ops.ProgressHandler, types.ApplyPhase).Ui
+
+	ui.Output("google_container_node_pool.pool1: Destroying... [id=pool1]")
+	ui.Output("google_container_node_pool.pool1: Destruction complete after 2m0s")
+	ui.Output("google_container_cluster.gke_cluster: Modifications complete after 10s [id=projects/my-project/locations/europe-west3/clusters/my-cluster]")
+	ui.Output("google_container_node_pool.pool1: Creation complete after 3m0s [id=pool1]")
+	ui.Output("google_compute_firewall.nodes: Creation complete after 5s [id=nodes]")
+	ui.Info("Apply complete! Resources: 2 added, 1 changed, 1 destroyed.")
+
+	require.Equal(t, &types.ApplySummary{
+		Added:              2,
+		Changed:            1,
+		Destroyed:          1,
+		AddedResources:     []string{"google_container_node_pool.pool1", "google_compute_firewall.nodes"},
+		ChangedResources:   []string{"google_container_cluster.gke_cluster"},
+		DestroyedResources: []string{"google_container_node_pool.pool1"},
+	}, summary.result())
+	require.Equal(t, "2 added, 1 changed, 1 destroyed", summary.result().String())
+	require.Len(t, events, 6, "The events should still be sent to the progress handler")
+
+	// refreshes and destroys of other phases are not part of the apply
+	summary = &applySummary{}
+	summary.options(Options{}).ProgressHandler(types.ProvisionEvent{Phase: types.DestroyPhase, Message: "google_container_cluster.gke_cluster: Destruction complete after 5m0s"})
+	require.Equal(t, &types.ApplySummary{}, summary.result())
+}
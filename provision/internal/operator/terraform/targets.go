@@ -0,0 +1,241 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/plans"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// DeleteTargets destroys only the resources of the cluster with the given terraform addresses, such as google_container_node_pool.pool1,
+// and keeps the others, such as to rebuild the node pools of a cluster without its control plane.
+// Terraform also destroys the resources that depend on the targets, they are reported to the progress handler before the destroy starts.
+// It fails without destroying anything if a target is not in the state of the cluster, or if the cluster resource of the provider would be destroyed:
+// the remaining resources would then belong to no cluster, use Delete to remove the whole cluster.
+// It returns the state of the remaining resources, and keeps the files of the cluster even without the Persistent option, so the state is not lost.
+// If the state is nil, DeleteTargets will attempt to load the state from the file system.
+func (t *Terraform) DeleteTargets(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, targets []string) (*statefile.File, error) {
+	return t.DeleteTargetsWithContext(context.Background(), sf, p, cfg, targets)
+}
+
+// DeleteTargetsWithContext works as DeleteTargets but stops terraform gracefully when the given context is done.
+func (t *Terraform) DeleteTargetsWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, targets []string) (*statefile.File, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no targets to delete, use Delete to remove the whole cluster")
+	}
+	return t.destroy(ctx, sf, p, cfg, targets)
+}
+
+// checkTargets checks that each target matches resources of the given state and that destroying them keeps the cluster resource.
+// It reports the resources terraform destroys because they depend on the targets to the progress handler of the options.
+func checkTargets(ops Options, sf *statefile.File, p types.ProviderType, targets []string) error {
+	if sf == nil || sf.State == nil {
+		return errors.Wrap(types.ErrStateNotFound, "the targets cannot be checked")
+	}
+
+	var resources []stateResource
+	for _, m := range sf.State.Modules {
+		for _, rs := range m.Resources {
+			if rs.Addr.Mode == addrs.ManagedResourceMode {
+				resources = append(resources, stateResource{addr: rs.Addr.Absolute(m.Addr), resource: rs})
+			}
+		}
+	}
+
+	destroyed := make(map[string]bool)
+	var missing []string
+	for _, target := range targets {
+		addr, diags := addrs.ParseTargetStr(target)
+		if diags.HasErrors() {
+			return errors.Wrapf(diags.Err(), "invalid target %s", target)
+		}
+		found := false
+		for _, rs := range resources {
+			for key := range rs.resource.Instances {
+				if addr.Subject.TargetContains(rs.addr.Instance(key)) {
+					destroyed[rs.addr.String()], found = true, true
+				}
+			}
+		}
+		if !found {
+			missing = append(missing, target)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("the following targets are not in the state of the cluster: %s", strings.Join(missing, ", "))
+	}
+
+	dependents := targetDependents(resources, destroyed)
+	if cluster := clusterResource(p); destroyed[cluster] {
+		return errors.Errorf("the targets would destroy the cluster resource %s, use Delete to remove the whole cluster", cluster)
+	}
+	if len(dependents) > 0 && ops.ProgressHandler != nil {
+		ops.ProgressHandler(types.ProvisionEvent{
+			Phase:   types.DestroyPhase,
+			Message: fmt.Sprintf("the following resources depend on the targets and are destroyed with them: %s", strings.Join(dependents, ", ")),
+		})
+	}
+	return nil
+}
+
+// stateResource is a resource of a state with its absolute address.
+type stateResource struct {
+	addr     addrs.AbsResource
+	resource *states.Resource
+}
+
+// targetDependents adds the resources depending on the destroyed ones, directly or not, to the destroyed resources and returns their sorted addresses.
+func targetDependents(resources []stateResource, destroyed map[string]bool) []string {
+	var dependents []string
+	for added := true; added; {
+		added = false
+		for _, rs := range resources {
+			addr := rs.addr.String()
+			if destroyed[addr] || !dependsOnAny(rs.resource, destroyed) {
+				continue
+			}
+			destroyed[addr], added = true, true
+			dependents = append(dependents, addr)
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// dependsOnAny returns true if an instance of the given resource depends on one of the given resources.
+func dependsOnAny(rs *states.Resource, resources map[string]bool) bool {
+	for _, is := range rs.Instances {
+		if is.Current == nil {
+			continue
+		}
+		for _, dep := range is.Current.Dependencies {
+			if resources[dep.String()] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// targetedPlan replaces the full plan saved by tfPlan in the cluster directory by a plan limited to the resources whose configuration changed,
+// which terraform applies together with the resources they depend on. The resources changed by the configuration are the ones of a plan
+// against the stored state without refresh, the drift of the other resources stays for the next full apply.
+// The full plan is kept if the configuration changes all the changed resources anyway, or if the targeted plan would leave dependencies
+// unsatisfied: a target planned differently without the other changes, or a changed resource depending on a target left out of the apply.
+// It returns the targets of the saved plan, nil if the full plan is kept.
+func targetedPlan(ctx context.Context, ops Options, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, clusterDir string, full *plans.Plan) ([]string, error) {
+	planFile := filepath.Join(clusterDir, tfPlanFile)
+	fullPlan, err := ioutil.ReadFile(planFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the terraform plan")
+	}
+	keepFull := func(reason string) ([]string, error) {
+		if ops.ProgressHandler != nil && reason != "" {
+			ops.ProgressHandler(types.ProvisionEvent{Phase: types.PlanPhase, Message: reason + ", all the changes are applied"})
+		}
+		return nil, ioutil.WriteFile(planFile, fullPlan, 0700)
+	}
+
+	if err := tfPlan(ctx, ops, p, cfg, clusterDir, "-refresh=false"); err != nil {
+		return nil, err
+	}
+	configured, err := planFromFile(clusterDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the terraform plan")
+	}
+	changes := changedResources(full)
+	targets := make([]string, 0, len(changes))
+	for addr := range changedResources(configured) {
+		targets = append(targets, addr)
+	}
+	sort.Strings(targets)
+	if len(targets) == 0 || len(targets) >= len(changes) {
+		return keepFull("")
+	}
+	if dependents := untargetedDependents(sf, changes, targets); len(dependents) > 0 {
+		return keepFull(fmt.Sprintf("the changed resources %s depend on the targets %s", strings.Join(dependents, ", "), strings.Join(targets, ", ")))
+	}
+
+	flags := make([]string, 0, len(targets))
+	for _, target := range targets {
+		flags = append(flags, "-target="+target)
+	}
+	if err := tfPlan(ctx, ops, p, cfg, clusterDir, flags...); err != nil {
+		return nil, err
+	}
+	targeted, err := planFromFile(clusterDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the terraform plan")
+	}
+	planned := changedResources(targeted)
+	for _, target := range targets {
+		if planned[target] != changes[target] {
+			return keepFull(fmt.Sprintf("the target %s is planned differently without the other changes", target))
+		}
+	}
+
+	if ops.ProgressHandler != nil {
+		ops.ProgressHandler(types.ProvisionEvent{
+			Phase:   types.PlanPhase,
+			Message: fmt.Sprintf("only the following changed resources and their dependencies are applied: %s", strings.Join(targets, ", ")),
+		})
+	}
+	return targets, nil
+}
+
+// changedResources returns the actions of the plan on the managed resource instances it changes, by address.
+func changedResources(plan *plans.Plan) map[string]plans.Action {
+	changes := make(map[string]plans.Action)
+	for _, rc := range plan.Changes.Resources {
+		if rc.Addr.Resource.Resource.Mode == addrs.ManagedResourceMode && rc.Action != plans.NoOp {
+			changes[rc.Addr.String()] = rc.Action
+		}
+	}
+	return changes
+}
+
+// untargetedDependents returns the sorted addresses of the changed resource instances that are not targets but depend on one in the given state.
+func untargetedDependents(sf *statefile.File, changes map[string]plans.Action, targets []string) []string {
+	if sf == nil || sf.State == nil {
+		return nil
+	}
+	targetResources := make(map[string]bool)
+	for _, m := range sf.State.Modules {
+		for _, rs := range m.Resources {
+			for key := range rs.Instances {
+				if contains(targets, rs.Addr.Instance(key).Absolute(m.Addr).String()) {
+					targetResources[rs.Addr.Absolute(m.Addr).String()] = true
+				}
+			}
+		}
+	}
+
+	var dependents []string
+	for _, m := range sf.State.Modules {
+		for _, rs := range m.Resources {
+			for key, is := range rs.Instances {
+				addr := rs.Addr.Instance(key).Absolute(m.Addr).String()
+				if _, changed := changes[addr]; !changed || contains(targets, addr) || is.Current == nil {
+					continue
+				}
+				for _, dep := range is.Current.Dependencies {
+					if targetResources[dep.String()] {
+						dependents = append(dependents, addr)
+						break
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
@@ -0,0 +1,104 @@
+package terraform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/plans"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+// gkeNodePoolState returns the state of a GKE cluster with a node pool and a firewall rule depending on the pool.
+func gkeNodePoolState() *statefile.File {
+	sf := clusterState("google_container_cluster", "gke_cluster", "google", `{"id": "cluster"}`)
+	provider := addrs.ProviderConfig{Type: addrs.NewLegacyProvider("google")}.Absolute(addrs.RootModuleInstance)
+	resource := func(typ, name string) addrs.Resource {
+		return addrs.Resource{Mode: addrs.ManagedResourceMode, Type: typ, Name: name}
+	}
+	sf.State.RootModule().SetResourceInstanceCurrent(
+		resource("google_container_node_pool", "pool1").Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{
+			Status:       states.ObjectReady,
+			AttrsJSON:    []byte(`{"id": "pool1"}`),
+			Dependencies: []addrs.AbsResource{resource("google_container_cluster", "gke_cluster").Absolute(addrs.RootModuleInstance)},
+		},
+		provider,
+	)
+	sf.State.RootModule().SetResourceInstanceCurrent(
+		resource("google_compute_firewall", "pool1").Instance(addrs.NoKey),
+		&states.ResourceInstanceObjectSrc{
+			Status:       states.ObjectReady,
+			AttrsJSON:    []byte(`{"id": "firewall"}`),
+			Dependencies: []addrs.AbsResource{resource("google_container_node_pool", "pool1").Absolute(addrs.RootModuleInstance)},
+		},
+		provider,
+	)
+	return sf
+}
+
+func TestCheckTargets(t *testing.T) {
+	t.Parallel()
+	var events []types.ProvisionEvent
+	ops := options(WithProgressHandler(func(e types.ProvisionEvent) { events = append(events, e) }))
+
+	require.NoError(t, checkTargets(ops, gkeNodePoolState(), types.GCP, []string{"google_compute_firewall.pool1"}))
+	require.Empty(t, events, "Targets without dependents should not be reported")
+
+	require.NoError(t, checkTargets(ops, gkeNodePoolState(), types.GCP, []string{"google_container_node_pool.pool1"}))
+	require.Len(t, events, 1)
+	require.Equal(t, types.DestroyPhase, events[0].Phase)
+	require.Contains(t, events[0].Message, "google_compute_firewall.pool1", "The dependents destroyed with the targets should be reported")
+
+	err := checkTargets(ops, gkeNodePoolState(), types.GCP, []string{"google_container_node_pool.pool1", "google_container_node_pool.pool2"})
+	require.Error(t, err, "Targets missing from the state should fail")
+	require.Contains(t, err.Error(), "google_container_node_pool.pool2")
+
+	err = checkTargets(ops, gkeNodePoolState(), types.GCP, []string{"google_container_cluster.gke_cluster"})
+	require.Error(t, err, "Destroying the cluster resource should fail")
+	require.Contains(t, err.Error(), "use Delete")
+
+	require.Error(t, checkTargets(ops, gkeNodePoolState(), types.GCP, []string{"not an address"}))
+
+	err = checkTargets(ops, nil, types.GCP, []string{"google_container_node_pool.pool1"})
+	require.True(t, errors.Is(err, types.ErrStateNotFound))
+}
+
+func TestDeleteTargetsWithoutTargets(t *testing.T) {
+	t.Parallel()
+	_, err := New().DeleteTargets(gkeNodePoolState(), types.GCP, map[string]interface{}{}, nil)
+	require.Error(t, err, "Deleting no targets should not delete the whole cluster")
+}
+
+func TestChangedResources(t *testing.T) {
+	t.Parallel()
+	plan := &plans.Plan{Changes: &plans.Changes{Resources: []*plans.ResourceInstanceChangeSrc{
+		testResourceChange("google_container_cluster", "gke_cluster", plans.NoOp),
+		testResourceChange("google_container_node_pool", "pool1", plans.Update),
+		testResourceChange("google_container_node_pool", "pool2", plans.DeleteThenCreate),
+	}}}
+	require.Equal(t, map[string]plans.Action{
+		"google_container_node_pool.pool1": plans.Update,
+		"google_container_node_pool.pool2": plans.DeleteThenCreate,
+	}, changedResources(plan), "Only the changed resources should be returned")
+}
+
+func TestUntargetedDependents(t *testing.T) {
+	t.Parallel()
+	changes := map[string]plans.Action{
+		"google_container_node_pool.pool1": plans.Update,
+		"google_compute_firewall.pool1":    plans.Update,
+	}
+	require.Equal(t, []string{"google_compute_firewall.pool1"}, untargetedDependents(gkeNodePoolState(), changes, []string{"google_container_node_pool.pool1"}),
+		"A changed resource depending on a target should be reported")
+	require.Empty(t, untargetedDependents(gkeNodePoolState(), changes, []string{"google_container_node_pool.pool1", "google_compute_firewall.pool1"}),
+		"Targeted dependents should not be reported")
+	require.Empty(t, untargetedDependents(gkeNodePoolState(), map[string]plans.Action{"google_container_node_pool.pool1": plans.Update}, []string{"google_container_node_pool.pool1"}),
+		"Unchanged dependents should not be reported")
+	require.Empty(t, untargetedDependents(gkeNodePoolState(), changes, []string{"google_compute_firewall.pool1"}),
+		"Changed dependencies of the targets are applied with them")
+	require.Empty(t, untargetedDependents(nil, changes, []string{"google_container_node_pool.pool1"}))
+}
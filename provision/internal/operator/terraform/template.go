@@ -0,0 +1,33 @@
+package terraform
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeTemplate copies the files of a custom template into the cluster directory.
+// The files hydroform writes for its built-in templates are removed, so a cluster can switch to a custom template.
+func writeTemplate(dir string, tmpl fs.FS) error {
+	for _, f := range []string{tfModuleFile, tfNodePoolsFile, tfPrivateClusterFile, tfLabelsFile, tfAutoProvisioningFile, tfResourceGroupFile, tfResourceGroupOverrideFile, tfZonesFile, tfSecurityFile, tfSecurityOverrideFile} {
+		if err := os.Remove(filepath.Join(dir, f)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return fs.WalkDir(tmpl, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		data, err := fs.ReadFile(tmpl, path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0700)
+	})
+}
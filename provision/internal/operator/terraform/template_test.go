@@ -0,0 +1,85 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+var testTemplate = fstest.MapFS{
+	"main.tf":             {Data: []byte(`variable "cluster_name" {}`)},
+	"outputs.tf":          {Data: []byte(`output "endpoint" { value = "https://example.com" }`)},
+	"modules/net/main.tf": {Data: []byte(`variable "cidr" {}`)},
+}
+
+func TestWriteTemplate(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-template")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// files of the built-in template from a previous operation
+	for _, f := range []string{tfModuleFile, tfNodePoolsFile, tfPrivateClusterFile, "backend.tf"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, f), []byte("# built-in"), 0600))
+	}
+
+	require.NoError(t, writeTemplate(dir, testTemplate))
+	for name, f := range testTemplate {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		require.NoError(t, err, "%s should be copied", name)
+		require.Equal(t, f.Data, data)
+	}
+	for _, f := range []string{tfModuleFile, tfNodePoolsFile, tfPrivateClusterFile} {
+		_, err := os.Stat(filepath.Join(dir, f))
+		require.True(t, os.IsNotExist(err), "The built-in %s should be removed", f)
+	}
+	_, err = os.Stat(filepath.Join(dir, "backend.tf"))
+	require.NoError(t, err, "The backend should be kept")
+}
+
+func TestInitClusterFilesTemplate(t *testing.T) {
+	t.Parallel()
+	dataDir, err := ioutil.TempDir("", "hf-template-vars")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	cfg := map[string]interface{}{
+		"project":         "my-project",
+		"cluster_name":    "my-cluster",
+		"private_cluster": "yes",
+		"vnet_name":       "my-vnet",
+	}
+	require.NoError(t, initClusterFiles(options(WithDataDir(dataDir)), types.Azure, cfg, testTemplate))
+
+	dir, err := clusterDir(options(WithDataDir(dataDir)), "my-project", "my-cluster", types.Azure)
+	require.NoError(t, err)
+	vars, err := ioutil.ReadFile(filepath.Join(dir, tfVarsFile))
+	require.NoError(t, err)
+	// the template declares its variables, none are filtered out
+	require.Contains(t, string(vars), "project = \"my-project\"\n")
+	require.Contains(t, string(vars), "private_cluster = \"yes\"\n")
+	require.Contains(t, string(vars), "vnet_name = \"my-vnet\"\n")
+
+	_, err = os.Stat(filepath.Join(dir, tfPrivateClusterFile))
+	require.True(t, os.IsNotExist(err), "The built-in private cluster file should not be written")
+}
+
+func TestValidateTemplateConfig(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"project":      "my-project",
+		"cluster_name": "my-cluster",
+		"node_count":   "any",
+	}
+	require.NoError(t, validateTemplateConfig(types.GCP, cfg), "Only the common fields should be validated")
+	require.Error(t, validateConfig(types.GCP, cfg))
+
+	delete(cfg, "cluster_name")
+	require.Error(t, validateTemplateConfig(types.GCP, cfg), "The common fields are required")
+	require.Error(t, validateTemplateConfig("unknown", cfg), "Unknown providers should fail")
+}
@@ -1,36 +1,87 @@
 package terraform
 
 import (
+	"context"
 	"fmt"
 	be_init "github.com/hashicorp/terraform/backend/init"
 	"github.com/hashicorp/terraform/command"
 	"github.com/kyma-incubator/hydroform/provision/types"
-	hashiCli "github.com/mitchellh/cli"
 	"github.com/pkg/errors"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // tfInit runs the 'terraform init' command with the specified options and config in the given working directory.
 // Always run this before creating any files in the given dir, modules can only be downloaded into empty dirs.
 // If the given dir is not empty, no modules will be downloaded and init will assume there is a valid module in dir.
-func tfInit(ops Options, p types.ProviderType, cfg map[string]interface{}, dir string) error {
+// A custom template of the provider is copied into the dir beforehand and used instead of the module.
+func tfInit(ctx context.Context, ops Options, p types.ProviderType, cfg map[string]interface{}, dir string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkInstallation(ops); err != nil {
+		return err
+	}
+	// init installs the plugins of the providers, so none are started for it
+	meta, ui, release, err := commandMeta(ctx, ops, dir, types.InitPhase, false)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+
 	// need to init all backends before we start
 	be_init.Init(ops.Services)
-	i := &command.InitCommand{
-		Meta: ops.Meta,
+
+	// custom templates replace the module, copy them first so init installs the providers and modules they use
+	if tmpl, ok := ops.Templates[p]; ok {
+		if err := writeTemplate(dir, tmpl); err != nil {
+			return errors.Wrap(err, "could not copy the cluster template")
+		}
 	}
 
-	if p == types.Gardener {
-		// on gardener we manage the provider download ourselves
-		// verification will fail but we know it is fine
-		os.Setenv(command.ProviderSkipVerifyEnvVar, "1")
+	// terraform does not synchronize the installs into the plugin cache, so only one init at a time may use it
+	unlock, err := lockPluginCache(ctx, meta.PluginCacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	args := initArgs(p, cfg, dir)
+	fromModule := strings.HasPrefix(args[0], "-from-module")
+	if ops.Backend != nil {
+		// modules can only be downloaded into empty dirs, so the backend is rendered after downloading them
+		if fromModule {
+			i := &command.InitCommand{
+				Meta: meta,
+			}
+			if e := i.Run(args); e != 0 {
+				return classifyError(checkUIErrors(ui))
+			}
+		}
+		if err := writeBackendFile(*ops.Backend, dir, cfg["project"].(string), cfg["cluster_name"].(string), p); err != nil {
+			return errors.Wrap(err, "could not configure the terraform backend")
+		}
+		// the data dir is shared by all clusters, always reconfigure so that the backend of another cluster is never migrated
+		args = append(append(append([]string{"-reconfigure"}, lockArgs(ops)...), backendConfigArgs(*ops.Backend)...), args[len(args)-1])
+		fromModule = false
+	}
+	// the version constraints are only rendered before init once the module is downloaded, initClusterFiles renders them otherwise
+	if !fromModule {
+		if err := writeProviderVersionsFile(dir, ops.ProviderVersions); err != nil {
+			return errors.Wrap(err, "could not render the provider version constraints")
+		}
 	}
 
-	if e := i.Run(initArgs(p, cfg, dir)); e != 0 {
-		return checkUIErrors(ops.Ui)
+	i := &command.InitCommand{
+		Meta: meta,
+	}
+	if e := i.Run(args); e != 0 {
+		return classifyError(checkUIErrors(ui))
+	}
+	if ops.UseWorkspace {
+		return selectWorkspace(ctx, ops, workspaceName(ops, cfg["project"].(string), cfg["cluster_name"].(string)), dir)
 	}
 	return nil
 }
@@ -52,6 +103,10 @@ func initArgs(p types.ProviderType, cfg map[string]interface{}, clusterDir strin
 			args = append(args, fmt.Sprintf("-from-module=%s", m))
 		}
 	}
+	// on gardener we manage the provider download ourselves, verification will fail but we know it is fine
+	if p == types.Gardener {
+		args = append(args, "-verify-plugins=false")
+	}
 	if runtime.GOOS == "windows" { // remove '\\?\' path prefix
 		clusterDir = clusterDir[4:]
 	}
@@ -71,6 +126,12 @@ func tfMod(p types.ProviderType) string {
 		return ""
 	case types.Gardener:
 		return ""
+	case types.OpenStack:
+		return ""
+	case types.DigitalOcean:
+		return ""
+	case types.AliCloud:
+		return ""
 	default:
 		return ""
 	}
@@ -84,98 +145,365 @@ func tfMod(p types.ProviderType) string {
 // - If failed with error "already exists" it means that the cluster exists but
 //   we do not have its state locally, so import the existing cluster and
 //   refresh the local state.
-// - if failed with error "not found" => probably state is corrupt => delete
+// - if failed with error "not found" => probably state is corrupt => forget
 //   the state and start over with apply.
-func tfApply(ops Options, p types.ProviderType, cfg map[string]interface{}, dir string) error {
+//
+// If the context is cancelled while applying, terraform is stopped gracefully
+// and the state of the resources created so far is kept in the state file.
+// The state is reset with forgetState, so the given store keeps the states of the InMemoryState option.
+func tfApply(ctx context.Context, ops Options, mem *stateStore, p types.ProviderType, cfg map[string]interface{}, dir string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	meta, ui, release, err := commandMeta(ctx, ops, dir, types.ApplyPhase, true)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+
 	a := &command.ApplyCommand{
-		Meta: ops.Meta,
+		Meta: meta,
 	}
-	e := a.Run(applyArgs(p, cfg, dir))
+	e := a.Run(append(parallelismArgs(ops, p), applyArgs(ops, p, cfg, dir)...))
 	if e != 0 {
-		errList := checkUIErrors(ops.Ui)
+		errList := checkUIErrors(ui)
+
+		// the operation was interrupted, do not attempt to recover from the error
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "terraform apply was interrupted")
+		}
 
 		// if cluster already exists import it and refresh the state
 		if strings.Contains(strings.ToLower(errList.Error()), "already exists") {
 			i := &command.ImportCommand{
-				Meta: ops.Meta,
+				Meta: meta,
 			}
 
-			if e := i.Run(importArgs(p, cfg, dir)); e != 0 {
-				return checkUIErrors(ops.Ui)
+			if e := i.Run(importArgs(ops, p, cfg, dir)); e != 0 {
+				return classifyError(checkUIErrors(ui))
 			}
 
 			r := &command.RefreshCommand{
-				Meta: ops.Meta,
+				Meta: meta,
 			}
 
-			if e := r.Run(refreshArgs(p, cfg, dir)); e != 0 {
-				return checkUIErrors(ops.Ui)
+			if e := r.Run(refreshArgs(ops, p, cfg, dir)); e != 0 {
+				return classifyError(checkUIErrors(ui))
 			}
 			return nil
 		}
 
 		// if cluster was not found, cluster got deeted on the remote or state is wrong, delete state and start over
 		if strings.Contains(errList.Error(), "not found") {
-			// delete the corrupt state file
-			stateFile := filepath.Join(dir, tfStateFile)
-			if err := os.Remove(stateFile); err != nil {
-				return err
+			// forget the corrupt state, wherever the options keep it
+			project, _ := cfg["project"].(string)
+			cluster, _ := cfg["cluster_name"].(string)
+			if ferr := forgetState(ops, mem, project, cluster, p); ferr != nil {
+				return errors.Wrapf(applyError(ui), "could not reset the state after the apply failed: %s", ferr)
 			}
 
 			// try applying again
-			if err := tfApply(ops, p, cfg, dir); err != nil {
+			if err := tfApply(ctx, ops, mem, p, cfg, dir); err != nil {
 				return errors.Wrap(err, errList.Error())
 			} else {
 				return nil
 			}
 		}
-		return errList
+		return applyError(ui)
 	}
 	return nil
 }
 
 // tfDestroy runs the 'terraform destroy' command with the specified options and config in the given working directory
-func tfDestroy(ops Options, p types.ProviderType, cfg map[string]interface{}, dir string) error {
+// If the context is cancelled while destroying, terraform is stopped gracefully.
+// With targets, only the resources with the given addresses and the ones depending on them are destroyed.
+// If all errors of the destroy mean that resources do not exist anymore, the error is a resourcesGoneError.
+func tfDestroy(ctx context.Context, ops Options, p types.ProviderType, cfg map[string]interface{}, dir string, targets ...string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	meta, ui, release, err := commandMeta(ctx, ops, dir, types.DestroyPhase, true)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+
 	a := &command.ApplyCommand{
-		Meta:    ops.Meta,
+		Meta:    meta,
 		Destroy: true,
 	}
-	if e := a.Run(applyArgs(p, cfg, dir)); e != 0 {
-		return checkUIErrors(ops.Ui)
+	args := parallelismArgs(ops, p)
+	for _, target := range targets {
+		args = append(args, "-target="+target)
+	}
+	if e := a.Run(append(args, applyArgs(ops, p, cfg, dir)...)); e != 0 {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "terraform destroy was interrupted")
+		}
+		if notFoundOnly(ui) {
+			return &resourcesGoneError{err: applyError(ui)}
+		}
+		return applyError(ui)
+	}
+	return nil
+}
+
+// tfPlan runs the 'terraform plan' command with the specified options and config in the given working directory.
+// The resulting plan is saved into the plan file of the working directory, so it can be inspected and applied afterwards.
+// The given flags, such as -target flags, are passed to terraform before the ones of the operation.
+func tfPlan(ctx context.Context, ops Options, p types.ProviderType, cfg map[string]interface{}, dir string, flags ...string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	meta, ui, release, err := commandMeta(ctx, ops, dir, types.PlanPhase, true)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+
+	pl := &command.PlanCommand{
+		Meta: meta,
+	}
+	if e := pl.Run(append(flags, planArgs(ops, p, cfg, dir)...)); e != 0 {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "terraform plan was interrupted")
+		}
+		return classifyError(checkUIErrors(ui))
+	}
+	return nil
+}
+
+// tfApplyPlan runs the 'terraform apply' command on the plan file previously saved by tfPlan in the given working directory.
+// Contrary to tfApply, exactly the planned changes are applied.
+func tfApplyPlan(ctx context.Context, ops Options, p types.ProviderType, dir string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	meta, ui, release, err := commandMeta(ctx, ops, dir, types.ApplyPhase, true)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+
+	a := &command.ApplyCommand{
+		Meta: meta,
+	}
+	if e := a.Run(append(append(parallelismArgs(ops, p), lockArgs(ops)...), applyPlanArgs(dir)...)); e != 0 {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "terraform apply was interrupted")
+		}
+		return applyError(ui)
+	}
+	return nil
+}
+
+// tfImport runs the 'terraform import' command for the resource with the given address and ID in the given working directory.
+func tfImport(ctx context.Context, ops Options, cfg map[string]interface{}, dir, addr, id string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	meta, ui, release, err := commandMeta(ctx, ops, dir, types.ImportPhase, true)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+
+	i := &command.ImportCommand{
+		Meta: meta,
+	}
+	if e := i.Run(resourceImportArgs(ops, cfg, dir, addr, id)); e != 0 {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "terraform import was interrupted")
+		}
+		return classifyError(checkUIErrors(ui))
 	}
 	return nil
 }
 
+// tfRefresh runs the 'terraform refresh' command with the specified options and config in the given working directory.
+// The progress events are reported in the given phase, since refreshing is part of other operations.
+func tfRefresh(ctx context.Context, ops Options, phase types.ProvisionPhase, p types.ProviderType, cfg map[string]interface{}, dir string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	meta, ui, release, err := commandMeta(ctx, ops, dir, phase, true)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+
+	r := &command.RefreshCommand{
+		Meta: meta,
+	}
+	if e := r.Run(refreshArgs(ops, p, cfg, dir)); e != 0 {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "terraform refresh was interrupted")
+		}
+		return classifyError(checkUIErrors(ui))
+	}
+	return nil
+}
+
+// commandMeta prepares the terraform meta of a command in the given dir, which reports its progress in the given phase.
+// Terraform reads and writes the state in plaintext, so the states in the dir are decrypted for the command, see plainState.
+// With startProviders, the plugins of the providers are started for the command, so they log to the writer of the operation, see startPlugins.
+// Without Verbose, the log terraform writes to the standard logger is dropped while the command runs, see filterTerraformLog.
+// The returned function stops all of it and encrypts the states again, adding its error to the given one.
+// It must be called with the named error of the command as soon as the command finished.
+func commandMeta(ctx context.Context, ops Options, dir string, phase types.ProvisionPhase, startProviders bool) (command.Meta, *commandUI, func(*error), error) {
+	encrypt, err := plainState(ops, dir)
+	if err != nil {
+		return command.Meta{}, nil, nil, err
+	}
+	restoreLog := func() {}
+	if !ops.Verbose {
+		restoreLog = filterTerraformLog()
+	}
+	var plugins *commandPlugins
+	if startProviders {
+		plugins = startPlugins(ops, dir)
+	}
+	meta, ui, stop := contextMeta(ctx, ops.Meta, plugins)
+	return progressMeta(meta, ops.ProgressHandler, phase), ui, func(err *error) {
+		stop()
+		plugins.kill()
+		restoreLog()
+		encrypt(err)
+	}, nil
+}
+
+// contextMeta returns a copy of the given terraform meta whose shutdown channel is also signaled when ctx is done.
+// Terraform handles the shutdown signal as a graceful stop: resources in progress are finished and the state is persisted.
+// Only one signal is sent, a second one would make the command return while terraform still runs the operation and writes the state,
+// after the cluster is unlocked. If the operation timeout of ctx expired and terraform did not stop within forceStopTimeout, the given plugins
+// of the command are killed instead, so the calls stuck in them fail and terraform finishes the operation.
+// The UI of the returned meta collects the errors of the command, returned as well, so that concurrent operations never see each other's errors.
+// The returned meta uses the given plugins of the command, if any, see startPlugins.
+// The returned function releases the signal forwarding and must be called once the command finished.
+func contextMeta(ctx context.Context, m command.Meta, plugins *commandPlugins) (command.Meta, *commandUI, func()) {
+	shutdownCh := make(chan struct{})
+	stopCh := make(chan struct{})
+
+	ui := &commandUI{Ui: m.Ui}
+	m.Ui = ui
+	m.UnmanagedProviders = plugins.reattach()
+
+	parentCh := m.ShutdownCh
+	go func() {
+		ctxDone := ctx.Done()
+		var force <-chan time.Time
+		for {
+			select {
+			case <-ctxDone:
+				// only signal the context once, a second signal would cancel terraform immediately
+				ctxDone = nil
+				if forceStop(ctx) {
+					force = time.After(forceStopTimeout)
+				}
+			case <-force:
+				// terraform is stuck, such as in a provider plugin that does not return
+				force = nil
+				plugins.kill()
+				continue
+			case <-parentCh:
+			case <-stopCh:
+				return
+			}
+			select {
+			case shutdownCh <- struct{}{}:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	m.ShutdownCh = shutdownCh
+	return m, ui, func() { close(stopCh) }
+}
+
 // applyArgs generates the flag list for the terraform apply command based on the operator configuration
-func applyArgs(p types.ProviderType, cfg map[string]interface{}, clusterDir string) []string {
+func applyArgs(ops Options, p types.ProviderType, cfg map[string]interface{}, clusterDir string) []string {
 	args := make([]string, 0)
 
 	stateFile := filepath.Join(clusterDir, tfStateFile)
-	varsFile := filepath.Join(clusterDir, tfVarsFile)
 
+	args = append(args, fmt.Sprintf("-state=%s", stateFile))
+	args = append(args, varFileArgs(ops, cfg, clusterDir)...)
+	args = append(args, lockArgs(ops)...)
 	args = append(args,
-		fmt.Sprintf("-state=%s", stateFile),
-		fmt.Sprintf("-var-file=%s", varsFile),
 		"-auto-approve",
 		clusterDir)
 
 	return args
 }
 
-// importArgs generates the flag list for the terraform import command based on the operator configuration
-func importArgs(p types.ProviderType, cfg map[string]interface{}, clusterDir string) []string {
+// parallelismArgs generates the parallelism flag of the apply commands on the given provider, or nothing to keep the default of terraform.
+func parallelismArgs(ops Options, p types.ProviderType) []string {
+	if n := parallelism(ops, p); n != 0 {
+		return []string{fmt.Sprintf("-parallelism=%d", n)}
+	}
+	return nil
+}
+
+// parallelism returns the parallelism of the options for the given provider, zero if none is set.
+func parallelism(ops Options, p types.ProviderType) int {
+	if n, ok := ops.ProviderParallelism[p]; ok {
+		return n
+	}
+	return ops.Parallelism
+}
+
+// planArgs generates the flag list for the terraform plan command based on the operator configuration
+func planArgs(ops Options, p types.ProviderType, cfg map[string]interface{}, clusterDir string) []string {
+	args := make([]string, 0)
+
+	stateFile := filepath.Join(clusterDir, tfStateFile)
+	planFile := filepath.Join(clusterDir, tfPlanFile)
+
+	args = append(args, fmt.Sprintf("-state=%s", stateFile))
+	args = append(args, varFileArgs(ops, cfg, clusterDir)...)
+	args = append(args, lockArgs(ops)...)
+	args = append(args,
+		fmt.Sprintf("-out=%s", planFile),
+		clusterDir)
+
+	return args
+}
+
+// applyPlanArgs generates the flag list for the terraform apply command of a saved plan
+func applyPlanArgs(clusterDir string) []string {
+	args := make([]string, 0)
+
+	stateFile := filepath.Join(clusterDir, tfStateFile)
+	planFile := filepath.Join(clusterDir, tfPlanFile)
+
+	args = append(args,
+		fmt.Sprintf("-state=%s", stateFile),
+		planFile)
+
+	return args
+}
+
+// importArgs generates the flag list for the terraform import command of the cluster resource based on the operator configuration
+func importArgs(ops Options, p types.ProviderType, cfg map[string]interface{}, clusterDir string) []string {
+	return resourceImportArgs(ops, cfg, clusterDir, clusterResource(p), clusterID(p, cfg))
+}
+
+// resourceImportArgs generates the flag list for the terraform import command of the resource with the given address and ID
+func resourceImportArgs(ops Options, cfg map[string]interface{}, clusterDir, addr, id string) []string {
 	args := make([]string, 0)
 
 	stateFile := filepath.Join(clusterDir, tfStateFile)
-	varsFile := filepath.Join(clusterDir, tfVarsFile)
 
 	args = append(args,
 		fmt.Sprintf("-state=%s", stateFile),
-		fmt.Sprintf("-state-out=%s", stateFile),
-		fmt.Sprintf("-var-file=%s", varsFile),
+		fmt.Sprintf("-state-out=%s", stateFile))
+	args = append(args, varFileArgs(ops, cfg, clusterDir)...)
+	args = append(args, lockArgs(ops)...)
+	args = append(args,
 		fmt.Sprintf("-config=%s", clusterDir),
-		clusterResource(p), // cluster resource
-		clusterID(p, cfg))  // cluster ID
+		addr,
+		id)
 
 	return args
 }
@@ -189,8 +517,16 @@ func clusterResource(p types.ProviderType) string {
 		return "azurerm_kubernetes_cluster.azure_cluster"
 	case types.Gardener:
 		return "gardener_shoot.gardener_cluster"
+	case types.Kind:
+		return "kind.kind-cluster"
 	case types.AWS:
-		return "not supported"
+		return "aws_eks_cluster.eks_cluster"
+	case types.OpenStack:
+		return "openstack_containerinfra_cluster_v1.magnum_cluster"
+	case types.DigitalOcean:
+		return "digitalocean_kubernetes_cluster.doks_cluster"
+	case types.AliCloud:
+		return "alicloud_cs_managed_kubernetes.ack_cluster"
 	}
 	return ""
 }
@@ -200,37 +536,39 @@ func clusterResource(p types.ProviderType) string {
 func clusterID(p types.ProviderType, cfg map[string]interface{}) string {
 	switch p {
 	case types.GCP:
-		return fmt.Sprintf("%s/%s/%s", cfg["project"], cfg["location"], cfg["cluster_name"])
+		return fmt.Sprintf("%s/%s/%s", cfg["project"], clusterLocation(cfg), cfg["cluster_name"])
 	case types.Gardener:
 		return fmt.Sprintf("%s/%s", cfg["namespace"], cfg["cluster_name"])
 	case types.AWS:
-		return "not supported"
+		return fmt.Sprintf("%s", cfg["cluster_name"])
 	}
 	return ""
 }
 
 // refreshArgs generates the flag list for the terraform refresh command based on the operator configuration
-func refreshArgs(p types.ProviderType, cfg map[string]interface{}, clusterDir string) []string {
+func refreshArgs(ops Options, p types.ProviderType, cfg map[string]interface{}, clusterDir string) []string {
 	args := make([]string, 0)
 
 	stateFile := filepath.Join(clusterDir, tfStateFile)
-	varsFile := filepath.Join(clusterDir, tfVarsFile)
 
-	args = append(args,
-		fmt.Sprintf("-state=%s", stateFile),
-		fmt.Sprintf("-var-file=%s", varsFile),
-		clusterDir)
+	args = append(args, fmt.Sprintf("-state=%s", stateFile))
+	args = append(args, varFileArgs(ops, cfg, clusterDir)...)
+	args = append(args, lockArgs(ops)...)
+	args = append(args, clusterDir)
 
 	return args
 }
 
-func checkUIErrors(ui hashiCli.Ui) error {
+// errorsUI is a UI that collects the errors and warnings terraform reports, such as the commandUI of a command.
+type errorsUI interface {
+	Errors() []error
+}
+
+func checkUIErrors(ui errorsUI) error {
 	var errsum strings.Builder
-	if h, ok := ui.(*HydroUI); ok {
-		for _, e := range h.Errors() {
-			if _, err := errsum.WriteString(e.Error()); err != nil {
-				return errors.Wrap(err, "could not fetch errors from terraform")
-			}
+	for _, e := range ui.Errors() {
+		if _, err := errsum.WriteString(e.Error()); err != nil {
+			return errors.Wrap(err, "could not fetch errors from terraform")
 		}
 	}
 
@@ -1,9 +1,12 @@
 package terraform
 
 import (
+	"context"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/terraform/command"
 	"github.com/kyma-incubator/hydroform/provision/types"
 	"github.com/stretchr/testify/require"
 )
@@ -23,7 +26,7 @@ func TestInitArgs(t *testing.T) {
 	require.Equal(t, ".", res[0]) // cluster config directory
 
 	// test provider that has module and an empty cluster dir => modules will be initialized
-	dir, err := clusterDir(".hf-test", "project", "cluster", types.Azure)
+	dir, err := clusterDir(options(WithDataDir(".hf-test")), "project", "cluster", types.Azure)
 	defer os.RemoveAll(".hf-test")
 	require.NoError(t, err)
 
@@ -32,12 +35,15 @@ func TestInitArgs(t *testing.T) {
 	require.Contains(t, res[0], "-from-module")
 	require.Equal(t, res[1], dir) // cluster config directory
 
+	// the gardener provider is installed by hydroform, it is not verified
+	res = initArgs(types.Gardener, nil, ".")
+	require.Equal(t, []string{"-verify-plugins=false", "."}, res)
 }
 
 func TestApplyArgs(t *testing.T) {
 	t.Parallel()
 	// for now apply args does not use the cluster and provider config for anything
-	res := applyArgs("", nil, "/path/to/cluster")
+	res := applyArgs(Options{}, "", nil, "/path/to/cluster")
 
 	require.Len(t, res, 4)
 	require.Equal(t, "-state=/path/to/cluster/terraform.tfstate", res[0])   // state file
@@ -46,12 +52,25 @@ func TestApplyArgs(t *testing.T) {
 	require.Equal(t, "/path/to/cluster", res[3])                            // cluster config directory
 }
 
+func TestParallelismArgs(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, parallelismArgs(Options{}, types.GCP), "The default of terraform should be kept")
+
+	ops := options(WithParallelism(30), WithProviderParallelism(types.Gardener, 2))
+	require.Equal(t, []string{"-parallelism=30"}, parallelismArgs(ops, types.GCP))
+	require.Equal(t, []string{"-parallelism=2"}, parallelismArgs(ops, types.Gardener), "The parallelism of the provider should win")
+
+	tf := New(WithProviderParallelism(types.Kind, -1))
+	_, err := tf.Create(types.Kind, map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster", "node_image": "kindest/node:v1.19.1"})
+	require.EqualError(t, err, "the parallelism must be at least 1, got -1")
+}
+
 func TestImportArgs(t *testing.T) {
 	t.Parallel()
 	cfg := map[string]interface{}{"project": "my-project", "namespace": "my-namespace", "location": "somewhere", "cluster_name": "my-cluster"}
 
 	// test GCP
-	res := importArgs(types.GCP, cfg, "/path/to/cluster")
+	res := importArgs(Options{}, types.GCP, cfg, "/path/to/cluster")
 	require.Len(t, res, 6)
 	require.Equal(t, "-state=/path/to/cluster/terraform.tfstate", res[0])     // state file
 	require.Equal(t, "-state-out=/path/to/cluster/terraform.tfstate", res[1]) // state output file
@@ -61,7 +80,7 @@ func TestImportArgs(t *testing.T) {
 	require.Equal(t, "my-project/somewhere/my-cluster", res[5])               // cluster ID
 
 	// test Gardener
-	res = importArgs(types.Gardener, cfg, "/path/to/cluster")
+	res = importArgs(Options{}, types.Gardener, cfg, "/path/to/cluster")
 	require.Len(t, res, 6)
 	require.Equal(t, "-state=/path/to/cluster/terraform.tfstate", res[0])     // state file
 	require.Equal(t, "-state-out=/path/to/cluster/terraform.tfstate", res[1]) // state output file
@@ -69,4 +88,76 @@ func TestImportArgs(t *testing.T) {
 	require.Equal(t, "-config=/path/to/cluster", res[3])                      // config folder for import to know where the tf files are (if any)
 	require.Equal(t, "gardener_shoot.gardener_cluster", res[4])               // resource type for a GCP cluster
 	require.Equal(t, "my-namespace/my-cluster", res[5])                       // cluster ID
+
+	// test AWS
+	res = importArgs(Options{}, types.AWS, cfg, "/path/to/cluster")
+	require.Len(t, res, 6)
+	require.Equal(t, "aws_eks_cluster.eks_cluster", res[4]) // resource type for an AWS cluster
+	require.Equal(t, "my-cluster", res[5])                  // cluster ID
+}
+
+func TestResourceImportArgs(t *testing.T) {
+	t.Parallel()
+	res := resourceImportArgs(Options{}, nil, "/path/to/cluster", "google_container_node_pool.pool", "my-project/somewhere/my-cluster/my-pool")
+	require.Len(t, res, 6)
+	require.Equal(t, "-state-out=/path/to/cluster/terraform.tfstate", res[1]) // state output file
+	require.Equal(t, "google_container_node_pool.pool", res[4])               // resource address
+	require.Equal(t, "my-project/somewhere/my-cluster/my-pool", res[5])       // resource ID
+}
+
+func TestContextMeta(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	meta, _, stop := contextMeta(ctx, command.Meta{}, nil)
+	defer stop()
+
+	select {
+	case <-meta.ShutdownCh:
+		t.Fatal("Shutdown should not be signaled before the context is done")
+	default:
+	}
+
+	cancel()
+	select {
+	case <-meta.ShutdownCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown should be signaled when the context is done")
+	}
+
+	// the context is only signaled once so that terraform can stop gracefully
+	select {
+	case <-meta.ShutdownCh:
+		t.Fatal("Shutdown should be signaled only once for a done context")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.Equal(t, context.Canceled, tfInit(ctx, Options{}, types.GCP, nil, "/path/to/cluster"))
+	require.Equal(t, context.Canceled, tfApply(ctx, Options{}, nil, types.GCP, nil, "/path/to/cluster"))
+	require.Equal(t, context.Canceled, tfDestroy(ctx, Options{}, types.GCP, nil, "/path/to/cluster"))
+}
+
+func TestPlanArgs(t *testing.T) {
+	t.Parallel()
+	res := planArgs(Options{}, "", nil, "/path/to/cluster")
+
+	require.Len(t, res, 4)
+	require.Equal(t, "-state=/path/to/cluster/terraform.tfstate", res[0])   // state file
+	require.Equal(t, "-var-file=/path/to/cluster/terraform.tfvars", res[1]) // vars file
+	require.Equal(t, "-out=/path/to/cluster/terraform.tfplan", res[2])      // plan file to inspect and apply afterwards
+	require.Equal(t, "/path/to/cluster", res[3])                            // cluster config directory
+}
+
+func TestApplyPlanArgs(t *testing.T) {
+	t.Parallel()
+	res := applyPlanArgs("/path/to/cluster")
+
+	require.Len(t, res, 2)
+	require.Equal(t, "-state=/path/to/cluster/terraform.tfstate", res[0]) // state file
+	require.Equal(t, "/path/to/cluster/terraform.tfplan", res[1])         // plan file, variables are already part of the plan
 }
@@ -0,0 +1,114 @@
+package terraform
+
+import (
+	"io"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/helper/logging"
+	"github.com/pkg/errors"
+)
+
+// terraformLogEnv returns the given environment with the given TF_LOG level, the environment of the provider plugins started for an operation,
+// see startPlugins. TF_LOG_PATH is removed with it, so the plugins write their log to the client, which sends it to the logger, see pluginLogOutput.
+// Without a level, the environment is returned unchanged.
+func terraformLogEnv(env []string, level string) []string {
+	if level == "" {
+		return env
+	}
+	logEnv := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if name != logging.EnvLog && name != logging.EnvLogFile {
+			logEnv = append(logEnv, kv)
+		}
+	}
+	return append(logEnv, logging.EnvLog+"="+strings.ToUpper(level))
+}
+
+// terraformLogLevelError returns an error if the log level of the options is not one of the levels of terraform,
+// which would log everything at TRACE level instead.
+func terraformLogLevelError(ops Options) error {
+	if ops.TerraformLogLevel == "" {
+		return nil
+	}
+	for _, l := range logging.ValidLevels {
+		if strings.EqualFold(string(l), ops.TerraformLogLevel) {
+			return nil
+		}
+	}
+	return errors.Errorf("invalid terraform log level %q, it must be one of %v", ops.TerraformLogLevel, logging.ValidLevels)
+}
+
+// terraformPackages is the prefix of the packages of terraform and of its own libraries, such as terraform-svchost, which log through the standard logger.
+const terraformPackages = "github.com/hashicorp/terraform"
+
+// terraformLogFilter drops the entries terraform writes to the standard logger and passes the other ones to the writer of the logger.
+// The entries are told apart by the package that logged them, so the entries of the caller and of hydroform, such as the ones of a Logger
+// writing to the standard logger, are kept whatever their level tag.
+type terraformLogFilter struct {
+	w io.Writer
+}
+
+func (f *terraformLogFilter) Write(p []byte) (int, error) {
+	if loggedByTerraform() {
+		return len(p), nil
+	}
+	return f.w.Write(p)
+}
+
+// loggedByTerraform returns true if the entry being written to the standard logger was logged by terraform:
+// the function calling into the log package is one of the terraform packages.
+func loggedByTerraform() bool {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	inLog := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case strings.HasPrefix(frame.Function, "log."):
+			inLog = true
+		case inLog:
+			return strings.HasPrefix(frame.Function, terraformPackages)
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// stdLog tracks the filter of the standard logger, installed while terraform commands without Verbose run, see filterTerraformLog.
+var stdLog struct {
+	sync.Mutex
+	commands int
+	filter   *terraformLogFilter
+}
+
+// filterTerraformLog stops terraform from writing its log to the output of the standard logger, the standard error by default, while a command runs.
+// Terraform logs through the standard logger of the process, so the filter is installed while at least one command without Verbose runs,
+// and the output the standard logger had is restored once the last of them finished. The entries the caller logs meanwhile are still written to that output.
+// The returned function must be called once the command finished.
+func filterTerraformLog() func() {
+	stdLog.Lock()
+	defer stdLog.Unlock()
+	if stdLog.commands == 0 {
+		stdLog.filter = &terraformLogFilter{w: log.Writer()}
+		log.SetOutput(stdLog.filter)
+	}
+	stdLog.commands++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			stdLog.Lock()
+			defer stdLog.Unlock()
+			stdLog.commands--
+			// an output the caller set meanwhile is kept
+			if stdLog.commands == 0 && log.Writer() == io.Writer(stdLog.filter) {
+				log.SetOutput(stdLog.filter.w)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statemgr"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) logged() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+func TestTerraformLogLevelError(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, terraformLogLevelError(options()))
+	require.NoError(t, terraformLogLevelError(options(WithTerraformLogLevel("DEBUG"))))
+	require.NoError(t, terraformLogLevelError(options(WithTerraformLogLevel("trace"))), "The level should be case insensitive")
+	require.Error(t, terraformLogLevelError(options(WithTerraformLogLevel("VERBOSE"))))
+}
+
+func TestTerraformLogEnv(t *testing.T) {
+	t.Parallel()
+
+	env := []string{"PATH=/bin", "TF_LOG=ERROR", "TF_LOG_PATH=/tmp/tf.log"}
+	require.Equal(t, env, terraformLogEnv(env, ""), "Without a level, the environment should not change")
+	require.Equal(t, []string{"PATH=/bin", "TF_LOG=DEBUG"}, terraformLogEnv(env, "debug"))
+	require.Equal(t, "TF_LOG=ERROR", env[1], "The given environment should not change")
+}
+
+// TestFilterTerraformLog is not parallel, it changes the output of the standard logger.
+func TestFilterTerraformLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hf-tflog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prev := log.Writer()
+	defer log.SetOutput(prev)
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	// terraform logs "[TRACE] statemgr.Filesystem: reading latest snapshot from ..."
+	terraformLogs := func() {
+		require.NoError(t, statemgr.NewFilesystem(filepath.Join(dir, tfStateFile)).RefreshState())
+	}
+
+	restoreA := filterTerraformLog()
+	restoreB := filterTerraformLog()
+	terraformLogs()
+	log.Printf("[INFO] serving on :8080")
+	log.Printf("starting the server")
+	// a Logger writing to the standard logger, such as the one of the plugin log
+	w := &pluginLogWriter{logger: log.Default()}
+	_, err = w.Write([]byte("2021-03-01T10:00:00.000Z [DEBUG] plugin.terraform-provider-kind: 2021/03/01 10:00:00 [DEBUG] creating cluster\n"))
+	require.NoError(t, err)
+	restoreA()
+	restoreA()
+	terraformLogs()
+	require.NotContains(t, out.String(), "statemgr", "The entries of terraform should be dropped while a command runs")
+	require.Contains(t, out.String(), "[INFO] serving on :8080", "The entries of the caller should be kept while a command runs")
+	require.Contains(t, out.String(), "starting the server", "The entries of the caller should be kept while a command runs")
+	require.Contains(t, out.String(), "[DEBUG] plugin.terraform-provider-kind: creating cluster", "The entries of the Logger should be kept")
+
+	restoreB()
+	require.Equal(t, io.Writer(&out), log.Writer(), "The output of the standard logger should be restored once the commands finished")
+	terraformLogs()
+	require.Contains(t, out.String(), "statemgr", "The entries of terraform should not be dropped outside of commands")
+
+	var other bytes.Buffer
+	restore := filterTerraformLog()
+	log.SetOutput(&other)
+	restore()
+	require.Equal(t, io.Writer(&other), log.Writer(), "An output set during a command should be kept")
+}
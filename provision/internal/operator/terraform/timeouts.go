@@ -1,9 +1,11 @@
 package terraform
 
 import (
+	"context"
 	"time"
 
 	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -12,7 +14,40 @@ const (
 	defaultDeleteTimeout = 20 * time.Minute
 )
 
-func applyTimeouts(cfg map[string]interface{}, timeouts types.Timeouts) {
+// forceStopTimeout is the time terraform gets to stop gracefully once the operation timeout expired, the provider plugins of the command are killed afterwards.
+var forceStopTimeout = 2 * time.Minute
+
+// operation is the kind of change an operation makes to a cluster, each has its own timeout.
+type operation int
+
+const (
+	// readOperation does not change the cluster resources, such as a plan or a refresh, it has no timeout of its own
+	readOperation operation = iota
+	createOperation
+	updateOperation
+	deleteOperation
+)
+
+// applyTimeouts sets the create, update and delete timeouts of the cluster resources in the configuration.
+// Timeouts that are not set are taken from the default timeout, and the built-in defaults if there is none.
+// It returns the timeout set for the given operation, or zero if the operation should not have a deadline.
+func applyTimeouts(cfg map[string]interface{}, timeouts types.Timeouts, op operation) time.Duration {
+	for _, d := range []*time.Duration{&timeouts.Create, &timeouts.Update, &timeouts.Delete} {
+		if *d == 0 {
+			*d = timeouts.Default
+		}
+	}
+
+	var limit time.Duration
+	switch op {
+	case createOperation:
+		limit = timeouts.Create
+	case updateOperation:
+		limit = timeouts.Update
+	case deleteOperation:
+		limit = timeouts.Delete
+	}
+
 	if timeouts.Create == 0 {
 		timeouts.Create = defaultCreateTimeout
 	}
@@ -26,4 +61,62 @@ func applyTimeouts(cfg map[string]interface{}, timeouts types.Timeouts) {
 	cfg["create_timeout"] = timeouts.Create
 	cfg["update_timeout"] = timeouts.Update
 	cfg["delete_timeout"] = timeouts.Delete
+	return limit
+}
+
+// withTimeout returns a copy of the context that is done once the given timeout expires, or the context itself if the timeout is zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// operationDeadline is the deadline of an operation with the OperationTimeout option, in the context of the operation.
+// The operations it runs itself, such as the updates of an upgrade, share it.
+type operationDeadline struct {
+	timeout time.Duration
+	parent  context.Context
+	ctx     context.Context
+}
+
+// operationDeadlineKey is the key of the operationDeadline in the context of an operation.
+type operationDeadlineKey struct{}
+
+// withOperationTimeout returns a copy of the context that is done once the operation timeout expires, or the context itself if there is no timeout
+// or it already has the deadline of an operation. The returned function must be called with the error of the operation once it finished:
+// it releases the context and turns the error into ErrTimeout if the operation failed because its timeout expired.
+func withOperationTimeout(ctx context.Context, timeout time.Duration) (context.Context, func(*error)) {
+	if d, ok := ctx.Value(operationDeadlineKey{}).(*operationDeadline); ok {
+		return ctx, d.check
+	}
+	if timeout == 0 {
+		return ctx, func(*error) {}
+	}
+
+	d := &operationDeadline{timeout: timeout, parent: ctx}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, operationDeadlineKey{}, d), timeout)
+	d.ctx = ctx
+	return ctx, func(err *error) {
+		d.check(err)
+		cancel()
+	}
+}
+
+// check turns the given error into ErrTimeout if the deadline expired, and not the one of the context the operation was started with.
+func (d *operationDeadline) check(err *error) {
+	if err == nil || *err == nil || errors.Is(*err, types.ErrTimeout) || !d.expired() {
+		return
+	}
+	*err = errors.Wrapf(types.ErrTimeout, "the operation did not finish within %s: %s", d.timeout, *err)
+}
+
+func (d *operationDeadline) expired() bool {
+	return d.parent.Err() == nil && errors.Is(d.ctx.Err(), context.DeadlineExceeded)
+}
+
+// forceStop reports whether the provider plugins of terraform must be killed if it does not stop gracefully, because the operation timeout of the given context expired.
+func forceStop(ctx context.Context) bool {
+	d, ok := ctx.Value(operationDeadlineKey{}).(*operationDeadline)
+	return ok && d.expired()
 }
@@ -1,11 +1,15 @@
 package terraform
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/terraform/command"
 	"github.com/kyma-incubator/hydroform/provision/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestApplyTimeouts(t *testing.T) {
@@ -13,7 +17,9 @@ func TestApplyTimeouts(t *testing.T) {
 	for _, testCase := range []struct {
 		description    string
 		timeouts       types.Timeouts
+		op             operation
 		expectedConfig map[string]interface{}
+		expectedLimit  time.Duration
 	}{
 		{
 			description: "timeouts provided",
@@ -22,30 +28,123 @@ func TestApplyTimeouts(t *testing.T) {
 				Update: 200 * time.Minute,
 				Delete: 300 * time.Minute,
 			},
+			op: deleteOperation,
 			expectedConfig: map[string]interface{}{
 				"create_timeout": 100 * time.Minute,
 				"update_timeout": 200 * time.Minute,
 				"delete_timeout": 300 * time.Minute,
 			},
+			expectedLimit: 300 * time.Minute,
 		},
 		{
 			description: "timeouts not provided",
 			timeouts:    types.Timeouts{},
+			op:          createOperation,
 			expectedConfig: map[string]interface{}{
 				"create_timeout": defaultCreateTimeout,
 				"update_timeout": defaultUpdateTimeout,
 				"delete_timeout": defaultDeleteTimeout,
 			},
 		},
+		{
+			description: "a single timeout is provided",
+			timeouts:    types.Timeouts{Default: 45 * time.Minute},
+			op:          updateOperation,
+			expectedConfig: map[string]interface{}{
+				"create_timeout": 45 * time.Minute,
+				"update_timeout": 45 * time.Minute,
+				"delete_timeout": 45 * time.Minute,
+			},
+			expectedLimit: 45 * time.Minute,
+		},
+		{
+			description: "some timeouts are provided",
+			timeouts:    types.Timeouts{Delete: 90 * time.Minute},
+			op:          updateOperation,
+			expectedConfig: map[string]interface{}{
+				"create_timeout": defaultCreateTimeout,
+				"update_timeout": defaultUpdateTimeout,
+				"delete_timeout": 90 * time.Minute,
+			},
+		},
+		{
+			description: "the operation does not change resources",
+			timeouts:    types.Timeouts{Default: 45 * time.Minute},
+			op:          readOperation,
+			expectedConfig: map[string]interface{}{
+				"create_timeout": 45 * time.Minute,
+				"update_timeout": 45 * time.Minute,
+				"delete_timeout": 45 * time.Minute,
+			},
+		},
 	} {
 		t.Run("should load timeouts configuration when "+testCase.description, func(t *testing.T) {
 			// when
 			config := make(map[string]interface{})
-			applyTimeouts(config, testCase.timeouts)
+			limit := applyTimeouts(config, testCase.timeouts, testCase.op)
 
 			// then
 			assert.Equal(t, testCase.expectedConfig, config)
+			assert.Equal(t, testCase.expectedLimit, limit)
 		})
 	}
 
 }
+
+func TestWithOperationTimeout(t *testing.T) {
+	t.Parallel()
+	ctx, expire := withOperationTimeout(context.Background(), 0)
+	require.Equal(t, context.Background(), ctx, "Without timeout the context should not change")
+	err := context.Canceled
+	expire(&err)
+	require.Equal(t, context.Canceled, err)
+
+	ctx, expire = withOperationTimeout(context.Background(), 10*time.Millisecond)
+	nested, expireNested := withOperationTimeout(ctx, time.Hour)
+	require.Equal(t, ctx, nested, "Nested operations should share the deadline of the operation")
+	<-ctx.Done()
+	require.True(t, forceStop(ctx))
+
+	err = errors.New("terraform apply was interrupted")
+	expireNested(&err)
+	require.True(t, errors.Is(err, types.ErrTimeout), "The error of an expired operation should be a timeout")
+	expire(&err)
+	require.Contains(t, err.Error(), "terraform apply was interrupted")
+	var noErr error
+	expire(&noErr)
+	require.NoError(t, noErr, "Operations that finished in time should not fail")
+
+	// the deadline of the caller is not the one of the operation
+	parent, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	ctx, expire = withOperationTimeout(parent, time.Hour)
+	<-ctx.Done()
+	require.False(t, forceStop(ctx))
+	err = context.DeadlineExceeded
+	expire(&err)
+	require.False(t, errors.Is(err, types.ErrTimeout))
+}
+
+func TestContextMetaForceStop(t *testing.T) {
+	timeout := forceStopTimeout
+	forceStopTimeout = 50 * time.Millisecond
+	defer func() { forceStopTimeout = timeout }()
+
+	ctx, expire := withOperationTimeout(context.Background(), time.Millisecond)
+	defer expire(nil)
+	plugins := &commandPlugins{}
+	meta, _, stop := contextMeta(ctx, command.Meta{}, plugins)
+	defer stop()
+
+	select {
+	case <-meta.ShutdownCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Terraform should be stopped once the operation timeout expired")
+	}
+	select {
+	case <-meta.ShutdownCh:
+		t.Fatal("Terraform should not be canceled, it would return before the operation finished")
+	case <-time.After(10 * forceStopTimeout):
+	}
+	plugins.killOnce.Do(func() { t.Error("The plugins of the command should be killed once terraform did not stop in time") })
+}
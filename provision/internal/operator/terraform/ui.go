@@ -1,11 +1,21 @@
 package terraform
 
 import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	hashiCli "github.com/mitchellh/cli"
 	"github.com/pkg/errors"
 )
 
 type HydroUI struct {
 	errs []error
+	// errsMu guards errs, the operations of an operator share its UI
+	errsMu sync.Mutex
+	logger types.Logger
+	output io.Writer
 }
 
 // Ask asks the user for input using the given query. For Hydroform,
@@ -21,26 +31,125 @@ func (h *HydroUI) AskSecret(string) (string, error) {
 }
 
 // Output is called for normal standard output.
-// Terraform output is ignored in Hydroform
-func (h *HydroUI) Output(string) {}
+// Terraform output is sent to the logger at debug level and to the output writer, if there are any.
+func (h *HydroUI) Output(s string) {
+	h.debug(s)
+	h.write(s)
+}
 
 // Info is called for information related to the previous output.
 // In general this may be the exact same as Output, but this gives
 // Ui implementors some flexibility with output formats.
-// Terraform info is ignored in Hydroform.
-func (h *HydroUI) Info(string) {}
+// Terraform info is sent to the logger at debug level, if there is one.
+func (h *HydroUI) Info(s string) {
+	h.debug(s)
+	h.write(s)
+}
 
 // Error saves error messages from terraform as an error slice to be retrieved later by Hydroform.
 func (h *HydroUI) Error(s string) {
-	h.errs = append(h.errs, errors.New(s))
+	h.debug(s)
+	h.write(s)
+	h.addError(s)
 }
 
 // Warn saves warning messages from terraform as an error slice to be retrieved later by Hydroform.
 func (h *HydroUI) Warn(s string) {
-	h.errs = append(h.errs, errors.New(s))
+	h.debug(s)
+	h.write(s)
+	h.addError(s)
 }
 
 // Errors returns any errors or warnings that happened during a terraform command execution
 func (h *HydroUI) Errors() []error {
-	return h.errs
+	h.errsMu.Lock()
+	defer h.errsMu.Unlock()
+	return append([]error(nil), h.errs...)
+}
+
+// Reset discards the errors and warnings collected so far.
+func (h *HydroUI) Reset() {
+	h.errsMu.Lock()
+	defer h.errsMu.Unlock()
+	h.errs = nil
+}
+
+// addError saves the given error or warning message.
+func (h *HydroUI) addError(s string) {
+	h.errsMu.Lock()
+	defer h.errsMu.Unlock()
+	h.errs = append(h.errs, errors.New(s))
+}
+
+// debug sends the given terraform output to the logger, or discards it if there is none.
+func (h *HydroUI) debug(s string) {
+	if h.logger != nil {
+		h.logger.Printf("[DEBUG] %s", s)
+	}
+}
+
+// write copies the given terraform output to the output writer, or discards it if there is none.
+func (h *HydroUI) write(s string) {
+	if h.output == nil {
+		return
+	}
+	fmt.Fprintln(h.output, s)
+}
+
+// fork returns a UI without errors that sends the terraform output to the same logger and output writer as h.
+func (h *HydroUI) fork() *HydroUI {
+	return &HydroUI{logger: h.logger, output: h.output}
+}
+
+// syncWriter serializes the writes to the wrapped writer, terraform commands report from several goroutines and operations.
+type syncWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// commandUI forwards the output of one terraform command to the wrapped UI and collects the errors and warnings of the command.
+// The UI of the operator is shared by all of its operations, so each command gets its own errors from this one.
+type commandUI struct {
+	hashiCli.Ui
+	errs []error
+	// terraform reports from several goroutines
+	mu sync.Mutex
+}
+
+// Error is called for errors of the command.
+func (u *commandUI) Error(s string) {
+	u.Ui.Error(s)
+	u.add(s)
+}
+
+// Warn is called for warnings of the command.
+func (u *commandUI) Warn(s string) {
+	u.Ui.Warn(s)
+	u.add(s)
+}
+
+// Errors returns the errors and warnings the command reported.
+func (u *commandUI) Errors() []error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]error(nil), u.errs...)
+}
+
+// Reset discards the errors and warnings collected so far, so that the next command run with the UI only reports its own.
+func (u *commandUI) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.errs = nil
+}
+
+func (u *commandUI) add(s string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.errs = append(u.errs, errors.New(s))
 }
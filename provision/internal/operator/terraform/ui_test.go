@@ -1,6 +1,9 @@
 package terraform
 
 import (
+	"bytes"
+	"log"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -33,3 +36,71 @@ func TestWarnAnError(t *testing.T) {
 
 	require.Len(t, ui.Errors(), 2, "There should be 2 errors in total (1 errror and 1 warning)")
 }
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	ui := &HydroUI{logger: log.New(&out, "", 0)}
+
+	ui.Output("OUTPUT")
+	ui.Info("INFO")
+	ui.Error("ERROR")
+
+	require.Equal(t, "[DEBUG] OUTPUT\n[DEBUG] INFO\n[DEBUG] ERROR\n", out.String())
+	require.Len(t, ui.Errors(), 1, "Logging should not affect the collected errors")
+
+	ui = &HydroUI{}
+	ui.Output("OUTPUT")
+	require.Empty(t, ui.Errors(), "Output without logger should be discarded")
+}
+
+func TestOutputWriter(t *testing.T) {
+	t.Parallel()
+	var out, logs bytes.Buffer
+	ui := &HydroUI{output: &out, logger: log.New(&logs, "", 0)}
+
+	ui.Output("OUTPUT")
+	ui.Info("INFO")
+	ui.Warn("WARN")
+	ui.Error("ERROR")
+
+	require.Equal(t, "OUTPUT\nINFO\nWARN\nERROR\n", out.String(), "The output should be written without log prefixes")
+	require.Equal(t, "[DEBUG] OUTPUT\n[DEBUG] INFO\n[DEBUG] WARN\n[DEBUG] ERROR\n", logs.String(), "The logger should still receive the output")
+}
+
+func TestReset(t *testing.T) {
+	t.Parallel()
+	ui := &HydroUI{}
+
+	ui.Error("ERROR")
+	ui.Reset()
+	require.Empty(t, ui.Errors(), "There should be no errors after a reset")
+}
+
+func TestCommandUI(t *testing.T) {
+	t.Parallel()
+	ui := &HydroUI{}
+	first, second := &commandUI{Ui: ui}, &commandUI{Ui: ui}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			first.Error("ERROR")
+		}()
+		go func() {
+			defer wg.Done()
+			second.Warn("WARNING")
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, first.Errors(), 10, "Each command should only collect its own errors")
+	require.Len(t, second.Errors(), 10, "Each command should only collect its own warnings")
+	require.Len(t, ui.Errors(), 20, "The errors should still be forwarded to the wrapped UI")
+
+	first.Reset()
+	require.Empty(t, first.Errors(), "There should be no errors after a reset")
+	require.Len(t, second.Errors(), 10, "Resetting a command should not affect the others")
+}
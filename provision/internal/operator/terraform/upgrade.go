@@ -0,0 +1,133 @@
+package terraform
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// upgradeProviders are the providers whose nodes can run another version than the control plane, so they can be upgraded after it.
+var upgradeProviders = map[types.ProviderType]bool{
+	types.GCP:   true,
+	types.Azure: true,
+}
+
+// Upgrade changes the Kubernetes version of an existing cluster to the target version in steps, so the nodes never run a newer version than the control plane:
+// it upgrades the control plane first keeping the nodes on their version, then the default nodes, then each node pool one after the other.
+// Each step is an Update, between two steps it waits for the API server of the cluster to be ready when hydroform can reach it.
+// Set the Upgrade settings of the node pools for surge upgrades, which replace several nodes at a time.
+// On Azure, the default nodes come from the cluster module and are upgraded with the control plane.
+// GKE clusters in a release channel are upgraded by the channel, Upgrade fails for them, as for the providers other than GCP and Azure.
+// If a step fails, the ClusterInfo of the last successful step is returned together with the error, so the upgrade can be continued from it.
+func (t *Terraform) Upgrade(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, targetVersion string) (*types.ClusterInfo, error) {
+	return t.UpgradeWithContext(context.Background(), sf, p, cfg, targetVersion)
+}
+
+// UpgradeWithContext works as Upgrade but stops terraform gracefully when the given context is done.
+func (t *Terraform) UpgradeWithContext(ctx context.Context, sf *statefile.File, p types.ProviderType, cfg map[string]interface{}, targetVersion string) (_ *types.ClusterInfo, err error) {
+	// the updates of the steps belong to the upgrade, a shutdown waits for all of them
+	ctx, done, err := t.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	if !upgradeProviders[p] {
+		return nil, errors.Wrapf(types.ErrUnsupportedOperation, "step by step upgrades are not supported on %s", p)
+	}
+	if channel, ok := cfg["release_channel"].(string); ok && channel != "" {
+		return nil, errors.Wrapf(types.ErrUnsupportedOperation, "the cluster is upgraded by the %s release channel", channel)
+	}
+	if targetVersion == "" {
+		return nil, errors.New("the target kubernetes version is missing")
+	}
+
+	steps := upgradeSteps(p, cfg, targetVersion)
+	var info *types.ClusterInfo
+	for i, step := range steps {
+		next, err := t.UpdateWithContext(ctx, sf, p, step.cfg)
+		if err != nil {
+			if next == nil {
+				next = info
+			}
+			return next, errors.Wrapf(err, "could not upgrade the %s", step.name)
+		}
+		info, sf = next, next.TerraformState()
+
+		if i < len(steps)-1 {
+			if err := waitForUpgradeStep(ctx, info); err != nil {
+				return info, errors.Wrapf(err, "the cluster did not get ready after upgrading the %s", step.name)
+			}
+		}
+	}
+	return info, nil
+}
+
+// upgradeStep is an update of the cluster upgrading one of its parts.
+type upgradeStep struct {
+	name string
+	cfg  map[string]interface{}
+}
+
+// upgradeSteps returns the configurations upgrading the control plane, the default nodes and the node pools to the target version one after the other.
+// Each step keeps the parts of the next steps on the current version of the cluster, unless they have a version of their own.
+func upgradeSteps(p types.ProviderType, cfg map[string]interface{}, targetVersion string) []upgradeStep {
+	current, _ := cfg["kubernetes_version"].(string)
+	pools, _ := cfg["node_pools"].([]types.NodePool)
+
+	// versions of the pools until their step, the ones without version run the current version of the cluster
+	versions := make([]string, len(pools))
+	for i, pool := range pools {
+		versions[i] = pool.KubernetesVersion
+		if versions[i] == "" {
+			versions[i] = current
+		}
+	}
+	nodeVersion := current
+
+	step := func(name string) upgradeStep {
+		c := make(map[string]interface{}, len(cfg)+1)
+		for k, v := range cfg {
+			c[k] = v
+		}
+		c["kubernetes_version"] = targetVersion
+		if p == types.GCP {
+			c["node_version"] = nodeVersion
+		}
+		if len(pools) > 0 {
+			upgraded := make([]types.NodePool, len(pools))
+			for i, pool := range pools {
+				upgraded[i] = pool
+				upgraded[i].KubernetesVersion = versions[i]
+			}
+			c["node_pools"] = upgraded
+		}
+		return upgradeStep{name: name, cfg: c}
+	}
+
+	steps := []upgradeStep{step("control plane")}
+	if p == types.GCP {
+		nodeVersion = targetVersion
+		steps = append(steps, step("default nodes"))
+	}
+	for i, pool := range pools {
+		versions[i] = targetVersion
+		steps = append(steps, step("node pool "+pool.Name))
+	}
+	return steps
+}
+
+// waitForUpgradeStep waits for the API server of the cluster to be ready after a step of an upgrade.
+// Terraform already waits for the operations of the providers, so clusters hydroform cannot reach are not waited for.
+func waitForUpgradeStep(ctx context.Context, info *types.ClusterInfo) error {
+	if info.Kubeconfig == "" || info.PrivateEndpoint {
+		return nil
+	}
+	return WaitForReady(ctx, info, upgradeReadyTimeout)
+}
+
+// upgradeReadyTimeout is how long an upgrade waits for the API server to be ready after each step
+const upgradeReadyTimeout = 10 * time.Minute
@@ -0,0 +1,92 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeSteps(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"kubernetes_version": "1.18",
+		"node_pools": []types.NodePool{
+			{Name: "gpu", MachineType: "n1-standard-8", NodeCount: 1},
+			{Name: "old", MachineType: "n1-standard-4", NodeCount: 1, KubernetesVersion: "1.17"},
+		},
+	}
+
+	steps := upgradeSteps(types.GCP, cfg, "1.19")
+	names := []string{}
+	for _, s := range steps {
+		names = append(names, s.name)
+		require.Equal(t, "1.19", s.cfg["kubernetes_version"], "The control plane should be upgraded in the first step")
+	}
+	require.Equal(t, []string{"control plane", "default nodes", "node pool gpu", "node pool old"}, names)
+
+	versions := func(s upgradeStep) []string {
+		v := []string{s.cfg["node_version"].(string)}
+		for _, pool := range s.cfg["node_pools"].([]types.NodePool) {
+			v = append(v, pool.KubernetesVersion)
+		}
+		return v
+	}
+	require.Equal(t, []string{"1.18", "1.18", "1.17"}, versions(steps[0]), "The nodes should keep their version while the control plane is upgraded")
+	require.Equal(t, []string{"1.19", "1.18", "1.17"}, versions(steps[1]))
+	require.Equal(t, []string{"1.19", "1.19", "1.17"}, versions(steps[2]))
+	require.Equal(t, []string{"1.19", "1.19", "1.19"}, versions(steps[3]))
+	require.Equal(t, "1.18", cfg["kubernetes_version"], "The given configuration should not be modified")
+
+	steps = upgradeSteps(types.Azure, map[string]interface{}{"kubernetes_version": "1.18"}, "1.19")
+	require.Len(t, steps, 1, "The default nodes on Azure should be upgraded with the control plane")
+	require.NotContains(t, steps[0].cfg, "node_version")
+}
+
+func TestUpgradeUnsupported(t *testing.T) {
+	t.Parallel()
+	tf := New()
+	_, err := tf.Upgrade(nil, types.Kind, map[string]interface{}{}, "1.19")
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation))
+
+	_, err = tf.Upgrade(nil, types.GCP, map[string]interface{}{"release_channel": "STABLE"}, "1.19")
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation), "Release channels should upgrade their clusters")
+
+	_, err = tf.Upgrade(nil, types.GCP, map[string]interface{}{}, "")
+	require.Error(t, err)
+}
+
+func TestNodePoolUpgradeSettings(t *testing.T) {
+	t.Parallel()
+	pools := []types.NodePool{{Name: "pool", MachineType: "n1-standard-4", NodeCount: 3, KubernetesVersion: "1.18", Upgrade: &types.UpgradeSettings{MaxSurge: 2}}}
+
+	for p, base := range map[types.ProviderType]string{
+		types.GCP:   gcpClusterTemplate,
+		types.Azure: "variable \"kubernetes_version\" {}\nresource \"azurerm_kubernetes_cluster\" \"azure_cluster\" {}\n",
+	} {
+		dir, err := ioutil.TempDir("", "hf-upgrade")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(base), 0600))
+		require.NoError(t, writeNodePoolsFile(dir, p, map[string]interface{}{"node_pools": pools}))
+		_, diags := configs.NewParser(nil).LoadConfigDir(dir)
+		require.False(t, diags.HasErrors(), "%s node pools with upgrade settings should be valid terraform: %s", p, diags.Error())
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, tfNodePoolsFile))
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"1.18"`, "The version of the pool should be rendered on %s", p)
+		require.Contains(t, string(data), "upgrade_settings {")
+	}
+
+	require.Empty(t, nodePoolErrors(types.GCP, map[string]interface{}{"node_pools": pools}))
+	pools[0].Upgrade = &types.UpgradeSettings{MaxSurge: 1, MaxUnavailable: 1}
+	require.Empty(t, nodePoolErrors(types.GCP, map[string]interface{}{"node_pools": pools}))
+	require.Len(t, nodePoolErrors(types.Azure, map[string]interface{}{"node_pools": pools}), 1, "AKS should only support a max surge")
+	pools[0].Upgrade = &types.UpgradeSettings{}
+	require.Len(t, nodePoolErrors(types.GCP, map[string]interface{}{"node_pools": pools}), 1)
+}
@@ -0,0 +1,267 @@
+package terraform
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// fieldKind is the kind of value expected for a configuration field.
+type fieldKind string
+
+const (
+	stringField     fieldKind = "a string"
+	numberField     fieldKind = "a number"
+	stringListField fieldKind = "a list of strings"
+	nodePoolsField  fieldKind = "a list of node pools"
+	boolField       fieldKind = "a boolean"
+	stringMapField  fieldKind = "a map of strings"
+
+	autoProvisioningField fieldKind = "node auto-provisioning limits"
+)
+
+// configField describes a configuration field used by the terraform templates of a provider.
+type configField struct {
+	name     string
+	kind     fieldKind
+	optional bool
+}
+
+// commonFields are the fields of all providers: the required ones identify the cluster, the optional one names it in the returned kubeconfigs.
+var commonFields = []configField{
+	{name: "project", kind: stringField},
+	{name: "cluster_name", kind: stringField},
+	{name: "kubeconfig_context_name", kind: stringField, optional: true},
+}
+
+// providerFields contains the configuration fields of each provider on top of the common ones.
+var providerFields = map[types.ProviderType][]configField{
+	types.GCP: {
+		{name: "credentials_file_path", kind: stringField},
+		{name: "location", kind: stringField, optional: true},
+		{name: "region", kind: stringField, optional: true},
+		{name: "zone", kind: stringField, optional: true},
+		{name: "availability_zones", kind: stringListField, optional: true},
+		{name: "node_count", kind: numberField},
+		{name: "machine_type", kind: stringField},
+		{name: "disk_size", kind: numberField},
+		{name: "kubernetes_version", kind: stringField, optional: true},
+		{name: "release_channel", kind: stringField, optional: true},
+		{name: "node_version", kind: stringField, optional: true},
+		{name: "node_auto_provisioning", kind: autoProvisioningField, optional: true},
+		{name: "node_pools", kind: nodePoolsField, optional: true},
+		{name: "private_cluster", kind: boolField, optional: true},
+		{name: "enable_private_nodes", kind: boolField, optional: true},
+		{name: "master_authorized_networks", kind: stringListField, optional: true},
+		{name: "master_ipv4_cidr_block", kind: stringField, optional: true},
+		{name: "labels", kind: stringMapField, optional: true},
+		{name: "network", kind: stringField, optional: true},
+		{name: "subnetwork", kind: stringField, optional: true},
+		{name: "workload_identity", kind: boolField, optional: true},
+		{name: "oidc_issuer", kind: boolField, optional: true},
+		{name: "enable_audit_logging", kind: boolField, optional: true},
+	},
+	types.Azure: {
+		{name: "resource_group", kind: stringField},
+		{name: "create_resource_group", kind: boolField, optional: true},
+		{name: "location", kind: stringField},
+		{name: "availability_zones", kind: stringListField, optional: true},
+		{name: "agent_count", kind: numberField},
+		{name: "agent_vm_size", kind: stringField},
+		{name: "agent_disk_size", kind: numberField},
+		{name: "kubernetes_version", kind: stringField},
+		{name: "node_pools", kind: nodePoolsField, optional: true},
+		{name: "private_cluster", kind: boolField, optional: true},
+		{name: "enable_private_nodes", kind: boolField, optional: true},
+		{name: "master_authorized_networks", kind: stringListField, optional: true},
+		{name: "labels", kind: stringMapField, optional: true},
+		{name: "workload_identity", kind: boolField, optional: true},
+		{name: "oidc_issuer", kind: boolField, optional: true},
+		{name: "enable_audit_logging", kind: boolField, optional: true},
+	},
+	types.AWS: {
+		{name: "region", kind: stringField},
+		{name: "node_count", kind: numberField},
+		{name: "machine_type", kind: stringField},
+		{name: "disk_size", kind: numberField},
+		{name: "kubernetes_version", kind: stringField},
+		{name: "credentials_file_path", kind: stringField, optional: true},
+		{name: "profile", kind: stringField, optional: true},
+		{name: "labels", kind: stringMapField, optional: true},
+		{name: "create_network", kind: boolField, optional: true},
+		{name: "network", kind: stringField, optional: true},
+		{name: "subnetworks", kind: stringListField, optional: true},
+		{name: "spot", kind: boolField, optional: true},
+		{name: "workload_identity", kind: boolField, optional: true},
+		{name: "oidc_issuer", kind: boolField, optional: true},
+		{name: "enable_audit_logging", kind: boolField, optional: true},
+	},
+	types.Gardener: {
+		{name: "credentials_file_path", kind: stringField},
+		{name: "namespace", kind: stringField},
+		{name: "target_provider", kind: stringField},
+		{name: "target_secret", kind: stringField},
+		{name: "target_profile", kind: stringField, optional: true},
+		{name: "seed_name", kind: stringField, optional: true},
+		{name: "dns_domain", kind: stringField, optional: true},
+		{name: "dns_provider", kind: stringField, optional: true},
+		{name: "addons", kind: stringListField, optional: true},
+		{name: "location", kind: stringField},
+		{name: "node_count", kind: numberField},
+		{name: "machine_type", kind: stringField},
+		{name: "machine_image_name", kind: stringField, optional: true},
+		{name: "machine_image_version", kind: stringField, optional: true},
+		{name: "disk_size", kind: numberField},
+		{name: "disk_type", kind: stringField, optional: true},
+		{name: "kubernetes_version", kind: stringField},
+		{name: "zones", kind: stringListField, optional: true},
+		{name: "vnetcidr", kind: stringField, optional: true},
+		{name: "workercidr", kind: stringField, optional: true},
+		{name: "hibernated", kind: boolField, optional: true},
+	},
+	types.Kind: {
+		{name: "node_image", kind: stringField},
+	},
+	types.OpenStack: {
+		{name: "auth_url", kind: stringField},
+		{name: "tenant_name", kind: stringField},
+		{name: "region", kind: stringField},
+		{name: "network", kind: stringField},
+		{name: "image", kind: stringField},
+		{name: "flavor", kind: stringField},
+		{name: "node_count", kind: numberField},
+		{name: "disk_size", kind: numberField},
+		{name: "kubernetes_version", kind: stringField},
+		{name: "user_name", kind: stringField, optional: true},
+		{name: "password", kind: stringField, optional: true},
+		{name: "master_flavor", kind: stringField, optional: true},
+		{name: "master_count", kind: numberField, optional: true},
+		{name: "keypair", kind: stringField, optional: true},
+	},
+	types.DigitalOcean: {
+		{name: "region", kind: stringField},
+		{name: "node_size", kind: stringField},
+		{name: "node_count", kind: numberField},
+		{name: "kubernetes_version", kind: stringField},
+		{name: "token", kind: stringField, optional: true},
+	},
+	types.AliCloud: {
+		{name: "region", kind: stringField},
+		{name: "instance_types", kind: stringListField},
+		{name: "node_count", kind: numberField},
+		{name: "disk_size", kind: numberField},
+		{name: "kubernetes_version", kind: stringField},
+		{name: "access_key", kind: stringField, optional: true},
+		{name: "secret_key", kind: stringField, optional: true},
+		{name: "vswitch_id", kind: stringField, optional: true},
+		{name: "vpc_cidr", kind: stringField, optional: true},
+		{name: "vswitch_cidr", kind: stringField, optional: true},
+		{name: "zone", kind: stringField, optional: true},
+		{name: "pod_cidr", kind: stringField, optional: true},
+		{name: "service_cidr", kind: stringField, optional: true},
+	},
+}
+
+// Validate checks that the given configuration contains all fields required by the provider with values of the right type,
+// and that its names follow the naming rules of the provider, as Create checks them for a new cluster.
+// It returns a ValidationError listing all invalid fields, or nil if the configuration is valid.
+func (t *Terraform) Validate(p types.ProviderType, cfg map[string]interface{}) error {
+	if err := validateConfig(p, cfg); err != nil {
+		return err
+	}
+	return nameError(p, cfg)
+}
+
+func validateConfig(p types.ProviderType, cfg map[string]interface{}) error {
+	fields, ok := providerFields[p]
+	if !ok {
+		return errors.Errorf("provider %q is not supported", p)
+	}
+
+	verr := fieldErrors(cfg, append(commonFields, fields...))
+	verr.Fields = append(verr.Fields, nodePoolErrors(p, cfg)...)
+	verr.Fields = append(verr.Fields, privateClusterErrors(p, cfg)...)
+	verr.Fields = append(verr.Fields, labelErrors(p, cfg)...)
+	verr.Fields = append(verr.Fields, networkErrors(p, cfg)...)
+	verr.Fields = append(verr.Fields, gkeErrors(p, cfg)...)
+	verr.Fields = append(verr.Fields, zoneErrors(p, cfg)...)
+	verr.Fields = append(verr.Fields, securityErrors(p, cfg)...)
+	verr.Fields = append(verr.Fields, dnsErrors(p, cfg)...)
+	verr.Fields = append(verr.Fields, extraVarsErrors(cfg)...)
+	verr.Fields = append(verr.Fields, additionalProvidersErrors(cfg)...)
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// validateTemplateConfig checks the configuration of a cluster provisioned with a custom template.
+// The template declares the fields it needs, so only the ones identifying the cluster are required.
+func validateTemplateConfig(p types.ProviderType, cfg map[string]interface{}) error {
+	if _, ok := providerFields[p]; !ok {
+		return errors.Errorf("provider %q is not supported", p)
+	}
+
+	verr := fieldErrors(cfg, commonFields)
+	verr.Fields = append(verr.Fields, extraVarsErrors(cfg)...)
+	verr.Fields = append(verr.Fields, additionalProvidersErrors(cfg)...)
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// fieldErrors returns a ValidationError with the given fields that are missing from the configuration or have a value of the wrong type.
+func fieldErrors(cfg map[string]interface{}, fields []configField) *types.ValidationError {
+	verr := &types.ValidationError{}
+	for _, f := range fields {
+		v, ok := cfg[f.name]
+		if !ok || v == nil {
+			if !f.optional {
+				verr.Fields = append(verr.Fields, types.FieldError{Field: f.name, Reason: "is missing"})
+			}
+			continue
+		}
+		if !f.kind.matches(v) {
+			verr.Fields = append(verr.Fields, types.FieldError{Field: f.name, Reason: fmt.Sprintf("must be %s, got %T", f.kind, v)})
+		}
+	}
+	return verr
+}
+
+// matches returns true if the given value is of the field kind.
+// Numbers can be of any numeric type, since the configuration may come from decoded JSON or YAML.
+func (k fieldKind) matches(v interface{}) bool {
+	switch k {
+	case stringField:
+		_, ok := v.(string)
+		return ok
+	case numberField:
+		switch reflect.TypeOf(v).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return true
+		}
+		return false
+	case stringListField:
+		_, ok := v.([]string)
+		return ok
+	case nodePoolsField:
+		_, ok := v.([]types.NodePool)
+		return ok
+	case boolField:
+		_, ok := v.(bool)
+		return ok
+	case stringMapField:
+		_, ok := v.(map[string]string)
+		return ok
+	case autoProvisioningField:
+		_, ok := v.(types.NodeAutoProvisioning)
+		return ok
+	}
+	return false
+}
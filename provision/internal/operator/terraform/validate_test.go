@@ -0,0 +1,54 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"project":               "my-project",
+		"cluster_name":          "my-cluster",
+		"credentials_file_path": "/path/to/credentials",
+		"location":              "europe-west3-a",
+		"node_count":            3,
+		"machine_type":          "n1-standard-4",
+		"disk_size":             float64(30),
+		"kubernetes_version":    "1.16",
+	}
+	require.NoError(t, validateConfig(types.GCP, cfg))
+
+	delete(cfg, "project")
+	cfg["node_count"] = "3"
+	err := validateConfig(types.GCP, cfg)
+	require.Error(t, err)
+
+	verr, ok := err.(*types.ValidationError)
+	require.True(t, ok, "Validation should return a ValidationError")
+	require.Equal(t, []types.FieldError{
+		{Field: "project", Reason: "is missing"},
+		{Field: "node_count", Reason: "must be a number, got string"},
+	}, verr.Fields)
+
+	require.Error(t, validateConfig("unknown", cfg), "Unknown providers should fail")
+}
+
+func TestValidateConfigOptionalFields(t *testing.T) {
+	t.Parallel()
+	cfg := map[string]interface{}{
+		"project":            "my-project",
+		"cluster_name":       "my-cluster",
+		"region":             "eu-west-1",
+		"node_count":         3,
+		"machine_type":       "t3.large",
+		"disk_size":          30,
+		"kubernetes_version": "1.16",
+	}
+	require.NoError(t, validateConfig(types.AWS, cfg), "Optional fields can be missing")
+
+	cfg["profile"] = 1
+	require.Error(t, validateConfig(types.AWS, cfg), "Optional fields must have the right type if present")
+}
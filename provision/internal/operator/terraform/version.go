@@ -0,0 +1,25 @@
+package terraform
+
+import (
+	goversion "github.com/hashicorp/go-version"
+	tfversion "github.com/hashicorp/terraform/version"
+	"github.com/pkg/errors"
+)
+
+// checkTerraformVersion verifies that the embedded terraform satisfies the given version constraint.
+// Checking it before running any command avoids failing in the middle of an apply, or writing a state
+// that the terraform version expected by the caller cannot read.
+func checkTerraformVersion(constraint string) error {
+	if constraint == "" {
+		return nil
+	}
+
+	c, err := goversion.NewConstraint(constraint)
+	if err != nil {
+		return errors.Wrapf(err, "invalid terraform version constraint %q", constraint)
+	}
+	if !c.Check(tfversion.SemVer) {
+		return errors.Errorf("terraform %s does not satisfy the version constraint %q", tfversion.SemVer, constraint)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTerraformVersion(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, checkTerraformVersion(""), "No constraint should accept any version")
+	require.NoError(t, checkTerraformVersion("~> 0.12.0"))
+	require.Error(t, checkTerraformVersion(">= 0.13"), "Unsatisfied constraints should fail")
+	require.Error(t, checkTerraformVersion("not a version"), "Invalid constraints should fail")
+}
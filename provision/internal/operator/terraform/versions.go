@@ -0,0 +1,297 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// file name of the terraform config reading the kubernetes versions of a provider
+	tfVersionsFile = "versions.tf"
+	// output of the versions config with the list of versions
+	versionsOutput = "versions"
+
+	gcpVersionsTemplate = `
+variable "credentials_file_path" {}
+variable "project"               {}
+variable "location"              {}
+
+provider "google" {
+	credentials = file(var.credentials_file_path)
+	project     = var.project
+}
+
+data "google_container_engine_versions" "versions" {
+	location = var.location
+}
+
+output "versions" {
+	value = data.google_container_engine_versions.versions.valid_master_versions
+}
+`
+
+	azureVersionsTemplate = `
+variable "location"        {}
+variable "subscription_id" {
+	default = ""
+}
+variable "tenant_id"       {
+	default = ""
+}
+variable "client_id"       {
+	default = ""
+}
+variable "client_secret"   {
+	default = ""
+}
+
+provider "azurerm" {
+	features {}
+	subscription_id = var.subscription_id != "" ? var.subscription_id : null
+	tenant_id       = var.tenant_id != "" ? var.tenant_id : null
+	client_id       = var.client_id != "" ? var.client_id : null
+	client_secret   = var.client_secret != "" ? var.client_secret : null
+}
+
+data "azurerm_kubernetes_service_versions" "versions" {
+	location = var.location
+}
+
+output "versions" {
+	value = data.azurerm_kubernetes_service_versions.versions.versions
+}
+`
+
+	digitaloceanVersionsTemplate = `
+variable "token" {
+	default = ""
+}
+
+provider "digitalocean" {
+	token = var.token != "" ? var.token : null
+}
+
+data "digitalocean_kubernetes_versions" "versions" {}
+
+output "versions" {
+	value = data.digitalocean_kubernetes_versions.versions.valid_versions
+}
+`
+)
+
+// versionQuery is the terraform config reading the kubernetes versions offered by a provider.
+type versionQuery struct {
+	template string
+	// fields are the configuration fields used by the template
+	fields []configField
+	// location is the configuration field the offered versions depend on, if any
+	location string
+}
+
+// versionQueries contains the version query of each provider that can list its kubernetes versions.
+var versionQueries = map[types.ProviderType]versionQuery{
+	types.GCP: {
+		template: gcpVersionsTemplate,
+		fields: []configField{
+			{name: "credentials_file_path", kind: stringField},
+			{name: "project", kind: stringField},
+			{name: "location", kind: stringField},
+		},
+		location: "location",
+	},
+	types.Azure: {
+		template: azureVersionsTemplate,
+		fields: []configField{
+			{name: "location", kind: stringField},
+			{name: "subscription_id", kind: stringField, optional: true},
+			{name: "tenant_id", kind: stringField, optional: true},
+			{name: "client_id", kind: stringField, optional: true},
+			{name: "client_secret", kind: stringField, optional: true},
+		},
+		location: "location",
+	},
+	types.DigitalOcean: {
+		template: digitaloceanVersionsTemplate,
+		fields: []configField{
+			{name: "token", kind: stringField, optional: true},
+		},
+	},
+}
+
+// SupportedVersions returns the kubernetes versions the provider offers for the location of the given configuration.
+// Providers that cannot list their versions return ErrUnsupportedOperation.
+func (t *Terraform) SupportedVersions(p types.ProviderType, cfg map[string]interface{}) ([]string, error) {
+	return t.SupportedVersionsWithContext(context.Background(), p, cfg)
+}
+
+// SupportedVersionsWithContext works as SupportedVersions but stops terraform gracefully when the given context is done.
+func (t *Terraform) SupportedVersionsWithContext(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (_ []string, err error) {
+	ctx, done, err := t.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done(&err)
+
+	cfg, removeCredentials, err := t.credentials(ctx, p, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer t.removeFiles(&err, removeCredentials)
+
+	if err := checkTerraformVersion(t.ops.TerraformVersion); err != nil {
+		return nil, err
+	}
+
+	return t.supportedVersions(ctx, p, cfg)
+}
+
+// supportedVersions reads the kubernetes versions of the provider with its version query in a temporary directory.
+// The query has its own local state, it is never stored in the backend of the cluster.
+func (t *Terraform) supportedVersions(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) (_ []string, err error) {
+	q, ok := versionQueries[p]
+	if !ok {
+		return nil, errors.Wrapf(types.ErrUnsupportedOperation, "%s cannot list its kubernetes versions", p)
+	}
+	// the versions depend on the location of the cluster, also when the configuration gives it as region or zone
+	if l := clusterLocation(cfg); q.location != "" && l != "" {
+		located := make(map[string]interface{}, len(cfg)+1)
+		for k, v := range cfg {
+			located[k] = v
+		}
+		located[q.location] = l
+		cfg = located
+	}
+	if verr := fieldErrors(cfg, q.fields); len(verr.Fields) > 0 {
+		return nil, verr
+	}
+
+	dir, err := ioutil.TempDir("", "hydroform-versions")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the versions directory")
+	}
+	defer t.removeFiles(&err, func() error {
+		return removeAll(dir)
+	})
+
+	// the config is written before init, so no module is downloaded into the directory
+	if err := ioutil.WriteFile(filepath.Join(dir, tfVersionsFile), []byte(q.template), 0700); err != nil {
+		return nil, err
+	}
+	vars := make(map[string]interface{}, len(q.fields)+1)
+	for _, f := range q.fields {
+		if v, ok := cfg[f.name]; ok {
+			vars[f.name] = v
+		}
+	}
+	if creds, ok := cfg[credentialVarsKey]; ok {
+		vars[credentialVarsKey] = creds
+	}
+	if err := writeVarsFile(dir, vars); err != nil {
+		return nil, err
+	}
+
+	ops := t.ops
+	// the versions are read from a local state in the temporary dir
+	ops.Backend = nil
+	ops.InMemoryState = false
+	ops.UseWorkspace = false
+	ops.PathStrategy = func(string, string, string, types.ProviderType) string {
+		return dir
+	}
+	ops.Templates = nil
+	ops.ProgressHandler = nil
+	if err := initProvider(t.ops, p, cfg); err != nil {
+		return nil, err
+	}
+	if err := tfInit(ctx, ops, p, cfg, dir); err != nil {
+		return nil, err
+	}
+	// the config only has data sources, applying it creates nothing
+	if err := tfApply(ctx, ops, t.states, p, cfg, dir); err != nil {
+		return nil, errors.Wrap(err, "could not read the kubernetes versions")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfStateFile))
+	if err != nil {
+		return nil, err
+	}
+	// the state is encrypted with the StateEncryptionKey option once the command finished
+	plain, err := decryptState(ops.StateEncryptionKey, data)
+	if err != nil {
+		return nil, err
+	}
+	sf, err := statefile.Read(bytes.NewReader(plain))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the state of the versions")
+	}
+	return outputVersions(sf)
+}
+
+// outputVersions returns the list of versions in the versions output of the given state.
+func outputVersions(sf *statefile.File) ([]string, error) {
+	o, ok := sf.State.RootModule().OutputValues[versionsOutput]
+	if !ok || o.Value.IsNull() {
+		return nil, errors.Errorf("the provider did not return any kubernetes versions")
+	}
+	if !o.Value.CanIterateElements() {
+		return nil, errors.Errorf("the kubernetes versions must be a list, got %s", o.Value.Type().FriendlyName())
+	}
+
+	versions := make([]string, 0, o.Value.LengthInt())
+	for it := o.Value.ElementIterator(); it.Next(); {
+		_, v := it.Element()
+		if v.IsNull() || v.Type() != cty.String {
+			continue
+		}
+		versions = append(versions, v.AsString())
+	}
+	return versions, nil
+}
+
+// checkVersion returns an UnsupportedVersionError if the provider does not offer the kubernetes version of the configuration.
+// Providers that cannot list their versions, and configurations leaving the version to the provider, are not checked.
+func (t *Terraform) checkVersion(ctx context.Context, p types.ProviderType, cfg map[string]interface{}) error {
+	q, ok := versionQueries[p]
+	if !ok {
+		return nil
+	}
+	version, _ := cfg["kubernetes_version"].(string)
+	if version == "" || version == "latest" {
+		return nil
+	}
+
+	versions, err := t.supportedVersions(ctx, p, cfg)
+	if err != nil {
+		return errors.Wrap(err, "could not verify the kubernetes version")
+	}
+	if versionSupported(version, versions) {
+		return nil
+	}
+	location := ""
+	if q.location != "" {
+		location = clusterLocation(cfg)
+	}
+	return &types.UnsupportedVersionError{
+		Version:   version,
+		Location:  location,
+		Supported: versions,
+	}
+}
+
+// versionSupported returns true if the given version is one of the supported versions, or a prefix of one, such as 1.16 for 1.16.15-gke.6000.
+func versionSupported(version string, supported []string) bool {
+	for _, s := range supported {
+		if s == version || strings.HasPrefix(s, version+".") || strings.HasPrefix(s, version+"-") {
+			return true
+		}
+	}
+	return false
+}
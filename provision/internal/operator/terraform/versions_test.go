@@ -0,0 +1,72 @@
+package terraform
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestVersionTemplates(t *testing.T) {
+	t.Parallel()
+	for p, q := range versionQueries {
+		dir, err := ioutil.TempDir("", "hf-versions")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfVersionsFile), []byte(q.template), 0600))
+		mod, diags := configs.NewParser(nil).LoadConfigDir(dir)
+		require.False(t, diags.HasErrors(), "The %s versions should be valid terraform: %s", p, diags.Error())
+		require.Contains(t, mod.Outputs, versionsOutput, "The %s versions should be in the versions output", p)
+		for _, f := range q.fields {
+			require.Contains(t, mod.Variables, f.name, "The %s versions should declare the variable %s", p, f.name)
+		}
+	}
+}
+
+func TestOutputVersions(t *testing.T) {
+	t.Parallel()
+	state := states.NewState()
+	_, err := outputVersions(statefile.New(state, "", 0))
+	require.Error(t, err, "A state without versions should fail")
+
+	state.RootModule().SetOutputValue(versionsOutput, cty.ListVal([]cty.Value{cty.StringVal("1.16.15-gke.6000"), cty.StringVal("1.17.14-gke.400")}), false)
+	versions, err := outputVersions(statefile.New(state, "", 0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1.16.15-gke.6000", "1.17.14-gke.400"}, versions)
+}
+
+func TestVersionSupported(t *testing.T) {
+	t.Parallel()
+	supported := []string{"1.16.15-gke.6000", "1.17.14-gke.400", "1.18.8-do.0"}
+	require.True(t, versionSupported("1.16", supported))
+	require.True(t, versionSupported("1.16.15", supported))
+	require.True(t, versionSupported("1.18.8-do.0", supported))
+	require.False(t, versionSupported("1.1", supported), "Versions should only match whole version parts")
+	require.False(t, versionSupported("1.19", supported))
+
+	err := &types.UnsupportedVersionError{Version: "1.19", Location: "europe-west3-a", Supported: []string{"1.16.15-gke.6000", "1.17.14-gke.400"}}
+	require.EqualError(t, err, "kubernetes version 1.19 is not supported in europe-west3-a, use one of: 1.16.15-gke.6000, 1.17.14-gke.400")
+}
+
+func TestCheckVersion(t *testing.T) {
+	t.Parallel()
+	tf := New()
+	_, err := tf.SupportedVersions(types.Kind, map[string]interface{}{})
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation), "Kind cannot list its versions")
+
+	_, err = tf.SupportedVersions(types.GCP, map[string]interface{}{"project": "my-project"})
+	require.IsType(t, &types.ValidationError{}, err, "The fields of the version query should be validated")
+
+	require.NoError(t, tf.checkVersion(context.Background(), types.Kind, map[string]interface{}{"kubernetes_version": "1.16"}), "Providers that cannot list their versions should not be checked")
+	require.NoError(t, tf.checkVersion(context.Background(), types.GCP, map[string]interface{}{"kubernetes_version": "latest"}), "The default version should not be checked")
+}
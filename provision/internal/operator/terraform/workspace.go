@@ -0,0 +1,61 @@
+package terraform
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform/backend"
+	"github.com/hashicorp/terraform/command"
+	"github.com/pkg/errors"
+)
+
+// invalidWorkspaceChars matches the characters terraform does not allow in workspace names.
+var invalidWorkspaceChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// workspaceName returns the terraform workspace of the given cluster: the one of the options, one derived from the project and the cluster name,
+// or the default workspace without the UseWorkspace option. The derived names end with a digest of the project and the cluster name,
+// so clusters whose names only differ in the replaced characters or in where the project ends never share a workspace.
+func workspaceName(ops Options, project, cluster string) string {
+	switch {
+	case !ops.UseWorkspace:
+		return backend.DefaultStateName
+	case ops.Workspace != "":
+		return ops.Workspace
+	default:
+		sum := sha256.Sum256([]byte(project + "\x00" + cluster))
+		return invalidWorkspaceChars.ReplaceAllString(strings.Join([]string{project, cluster, hex.EncodeToString(sum[:4])}, "-"), "_")
+	}
+}
+
+// selectWorkspace runs 'terraform workspace select' for the workspace of the cluster in the given directory,
+// and 'terraform workspace new' if it does not exist yet.
+func selectWorkspace(ctx context.Context, ops Options, name, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !ops.Verbose {
+		defer filterTerraformLog()()
+	}
+	meta, ui, stop := contextMeta(ctx, ops.Meta, nil)
+	defer stop()
+
+	s := &command.WorkspaceSelectCommand{
+		Meta: meta,
+	}
+	if e := s.Run([]string{name, dir}); e == 0 {
+		return nil
+	}
+
+	// the workspace does not exist yet
+	ui.Reset()
+	n := &command.WorkspaceNewCommand{
+		Meta: meta,
+	}
+	if e := n.Run([]string{name, dir}); e != 0 {
+		return errors.Wrapf(classifyError(checkUIErrors(ui)), "could not select the terraform workspace %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceName(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "default", workspaceName(options(), "my-project", "my-cluster"), "Without workspaces the default one should be used")
+	require.Equal(t, "my-workspace", workspaceName(options(WithWorkspace("my-workspace")), "my-project", "my-cluster"))
+	require.Equal(t, "my-project-my-cluster-fe5347de", workspaceName(options(WithWorkspace("")), "my-project", "my-cluster"))
+	require.Equal(t, "my_project-my_cluster-920139e8", workspaceName(options(WithWorkspace("")), "my project", "my/cluster"), "Invalid characters should be replaced")
+	require.NotEqual(t, workspaceName(options(WithWorkspace("")), "a-b", "c"), workspaceName(options(WithWorkspace("")), "a", "b-c"), "Different clusters should not share a workspace")
+}
+
+func TestWorkspaceOptions(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-workspace")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the local state of each cluster is already isolated
+	_, err = New(WithDataDir(dir), WithWorkspace("")).Create(types.GCP, map[string]interface{}{})
+	require.EqualError(t, err, "terraform workspaces can only be used with a backend")
+}
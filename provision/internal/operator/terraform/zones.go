@@ -0,0 +1,255 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// file name for the availability zones of AKS, terraform merges it into the resources of the module as an override file
+	tfZonesFile = "zones_override.tf"
+
+	// override files replace whole nested blocks, so the default node pool of the module is repeated with its zones
+	azureZonesTemplate = `
+resource "azurerm_kubernetes_cluster" "azure_cluster" {
+	default_node_pool {
+		name               = "agentpool"
+		type               = "VirtualMachineScaleSets"
+		node_count         = var.agent_count
+		vm_size            = var.agent_vm_size
+		os_disk_size_gb    = var.agent_disk_size
+		availability_zones = {{zones .Zones}}
+	}
+}
+{{- range .NodePools}}
+
+resource "azurerm_kubernetes_cluster_node_pool" "{{.Name}}" {
+	availability_zones = {{zones $.Zones}}
+}
+{{- end}}
+`
+)
+
+// azureZones are the availability zones of the Azure regions that have them.
+var azureZones = map[string]bool{"1": true, "2": true, "3": true}
+
+// clusterLocation returns the location of the cluster: the location of the configuration, or on GCP its region for a regional cluster or its zone for a zonal one.
+func clusterLocation(cfg map[string]interface{}) string {
+	for _, key := range []string{"location", "region", "zone"} {
+		if l, _ := cfg[key].(string); l != "" {
+			return l
+		}
+	}
+	return ""
+}
+
+// gcpZone reports whether the given GCP location is a zone, such as europe-west3-a, rather than a region, such as europe-west3.
+func gcpZone(location string) bool {
+	return strings.Count(location, "-") == 2
+}
+
+// gcpRegion returns the region of the given GCP location.
+func gcpRegion(location string) string {
+	if gcpZone(location) {
+		return location[:strings.LastIndex(location, "-")]
+	}
+	return location
+}
+
+// writeZonesFile renders the availability zones of the configuration into an override file of the AKS cluster and its node pools.
+// The file is removed if the configuration has no zones. GCP has the zones in its template already.
+func writeZonesFile(dir string, p types.ProviderType, cfg map[string]interface{}) error {
+	path := filepath.Join(dir, tfZonesFile)
+	zones, _ := cfg["availability_zones"].([]string)
+	if p != types.Azure || len(zones) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	t, err := template.New("zones").Funcs(template.FuncMap{"zones": hclList}).Parse(azureZonesTemplate)
+	if err != nil {
+		return err
+	}
+	pools, _ := cfg["node_pools"].([]types.NodePool)
+	s := &strings.Builder{}
+	if err := t.Execute(s, struct {
+		Zones     []string
+		NodePools []types.NodePool
+	}{zones, pools}); err != nil {
+		return errors.Wrap(err, "could not render the availability zones")
+	}
+	return ioutil.WriteFile(path, []byte(s.String()), 0700)
+}
+
+// hclList renders the given strings as a terraform list of strings.
+func hclList(l []string) string {
+	entries := make([]string, 0, len(l))
+	for _, s := range l {
+		entries = append(entries, hclString(s))
+	}
+	return fmt.Sprintf("[%s]", strings.Join(entries, ", "))
+}
+
+// zoneErrors checks the location and the availability zones of the configuration and returns an error for each invalid field.
+// On GCP the cluster is in exactly one location: a region for a regional cluster, or a zone.
+// The availability zones spread the nodes, they must be zones of the region of the cluster on GCP, and zone numbers on Azure.
+func zoneErrors(p types.ProviderType, cfg map[string]interface{}) []types.FieldError {
+	var errs []types.FieldError
+	if p == types.GCP {
+		var set []string
+		for _, key := range []string{"location", "region", "zone"} {
+			if v, ok := cfg[key]; ok && v != nil {
+				set = append(set, key)
+			}
+		}
+		region, _ := cfg["region"].(string)
+		zone, _ := cfg["zone"].(string)
+		switch {
+		case len(set) == 0:
+			errs = append(errs, types.FieldError{Field: "location", Reason: "is missing, set the region of a regional cluster or the zone of a zonal one"})
+		case len(set) > 1:
+			errs = append(errs, types.FieldError{Field: set[1], Reason: fmt.Sprintf("cannot be set together with %s, the cluster is either regional or zonal", set[0])})
+		case region != "" && gcpZone(region):
+			errs = append(errs, types.FieldError{Field: "region", Reason: fmt.Sprintf("must be a region, %q is a zone", region)})
+		case zone != "" && !gcpZone(zone):
+			errs = append(errs, types.FieldError{Field: "zone", Reason: fmt.Sprintf("must be a zone, %q is a region", zone)})
+		}
+	}
+
+	zones, ok := cfg["availability_zones"].([]string)
+	if !ok {
+		return errs
+	}
+	region := gcpRegion(clusterLocation(cfg))
+	for i, z := range zones {
+		field := fmt.Sprintf("availability_zones[%d]", i)
+		switch {
+		case p == types.GCP && (!gcpZone(z) || gcpRegion(z) != region):
+			errs = append(errs, types.FieldError{Field: field, Reason: fmt.Sprintf("must be a zone of the region %s of the cluster, got %q", region, z)})
+		case p == types.Azure && !azureZones[z]:
+			errs = append(errs, types.FieldError{Field: field, Reason: fmt.Sprintf("must be 1, 2 or 3, got %q", z)})
+		}
+	}
+	return errs
+}
+
+// clusterZones returns the availability zones the nodes of the cluster resource in the given state span, sorted.
+// A zonal GKE cluster without additional zones is in its zone. It returns nil if the state has no zones.
+func clusterZones(sf *statefile.File) ([]string, error) {
+	var zones []string
+	for _, p := range []types.ProviderType{types.GCP, types.Azure} {
+		resources, err := driftResources(sf, p)
+		if err != nil {
+			return nil, err
+		}
+		attrs, ok := resources[clusterResource(p)]
+		if !ok {
+			continue
+		}
+		switch p {
+		case types.GCP:
+			zones = stringValues(attributeValue(attrs, "node_locations"))
+			if location, _ := attrs["location"].(string); gcpZone(location) && !contains(zones, location) {
+				zones = append(zones, location)
+			}
+		case types.Azure:
+			zones = stringValues(attributeValue(attrs, "default_node_pool.0.availability_zones"))
+		}
+	}
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// zoneChangeError returns a RecreateError if the configuration moves an existing cluster between a zonal and a regional control plane,
+// or changes the zones of an AKS cluster: the providers cannot change them in place, terraform would create a new cluster.
+func zoneChangeError(sf *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
+	if p != types.GCP && p != types.Azure {
+		return nil
+	}
+	resources, err := driftResources(sf, p)
+	if err != nil {
+		return err
+	}
+	attrs, ok := resources[clusterResource(p)]
+	if !ok {
+		return nil
+	}
+
+	var reason string
+	switch p {
+	case types.GCP:
+		actual, _ := attrs["location"].(string)
+		desired := clusterLocation(cfg)
+		if actual == "" || actual == desired {
+			return nil
+		}
+		switch {
+		case gcpZone(actual) && !gcpZone(desired):
+			reason = fmt.Sprintf("the zonal cluster in %s cannot become a regional cluster in %s", actual, desired)
+		case !gcpZone(actual) && gcpZone(desired):
+			reason = fmt.Sprintf("the regional cluster in %s cannot become a zonal cluster in %s", actual, desired)
+		default:
+			reason = fmt.Sprintf("the cluster cannot move from %s to %s", actual, desired)
+		}
+	case types.Azure:
+		actual := stringValues(attributeValue(attrs, "default_node_pool.0.availability_zones"))
+		desired, _ := cfg["availability_zones"].([]string)
+		if sameStrings(actual, desired) {
+			return nil
+		}
+		reason = fmt.Sprintf("the availability zones of the cluster cannot change from [%s] to [%s]", strings.Join(actual, ", "), strings.Join(desired, ", "))
+	}
+	return &types.RecreateError{Resources: []string{clusterResource(p)}, Reason: reason}
+}
+
+// stringValues returns the strings of the given JSON list, or a copy of the given strings.
+func stringValues(v interface{}) []string {
+	if l, ok := v.([]string); ok {
+		return append([]string(nil), l...)
+	}
+	l, _ := v.([]interface{})
+	var values []string
+	for _, e := range l {
+		if s, ok := e.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// sameStrings reports whether the given lists have the same strings, in any order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether the given list has the given string.
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
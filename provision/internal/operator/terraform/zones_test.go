@@ -0,0 +1,118 @@
+package terraform
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/configs"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZoneErrors(t *testing.T) {
+	t.Parallel()
+	fields := func(p types.ProviderType, cfg map[string]interface{}) []string {
+		f := []string{}
+		for _, e := range zoneErrors(p, cfg) {
+			f = append(f, e.Field)
+		}
+		return f
+	}
+
+	require.Empty(t, fields(types.GCP, map[string]interface{}{"location": "europe-west3-a"}))
+	require.Empty(t, fields(types.GCP, map[string]interface{}{"region": "europe-west3", "availability_zones": []string{"europe-west3-a", "europe-west3-b"}}))
+	require.Empty(t, fields(types.GCP, map[string]interface{}{"zone": "europe-west3-a", "availability_zones": []string{"europe-west3-b"}}))
+	require.Empty(t, fields(types.Azure, map[string]interface{}{"location": "westeurope", "availability_zones": []string{"1", "2", "3"}}))
+
+	require.Equal(t, []string{"location"}, fields(types.GCP, map[string]interface{}{}), "GCP clusters need a location")
+	require.Equal(t, []string{"zone"}, fields(types.GCP, map[string]interface{}{"region": "europe-west3", "zone": "europe-west3-a"}), "A cluster cannot be regional and zonal")
+	require.Equal(t, []string{"region"}, fields(types.GCP, map[string]interface{}{"region": "europe-west3-a"}))
+	require.Equal(t, []string{"zone"}, fields(types.GCP, map[string]interface{}{"zone": "europe-west3"}))
+	require.Equal(t, []string{"availability_zones[1]", "availability_zones[2]"},
+		fields(types.GCP, map[string]interface{}{"region": "europe-west3", "availability_zones": []string{"europe-west3-a", "us-east1-b", "europe-west3"}}),
+		"The zones must be in the region of the cluster")
+	require.Equal(t, []string{"availability_zones[0]"}, fields(types.Azure, map[string]interface{}{"location": "westeurope", "availability_zones": []string{"westeurope-1"}}))
+}
+
+func TestWriteZonesFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-zones")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the AKS cluster of the module, the zones override its default node pool
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, tfModuleFile), []byte(`
+variable "agent_count"     {}
+variable "agent_vm_size"   {}
+variable "agent_disk_size" {}
+
+resource "azurerm_kubernetes_cluster" "azure_cluster" {
+	default_node_pool {
+		name       = "agentpool"
+		node_count = var.agent_count
+		vm_size    = var.agent_vm_size
+	}
+}
+`), 0600))
+	cfg := map[string]interface{}{"availability_zones": []string{"1", "2"}, "node_pools": testNodePools}
+	require.NoError(t, writeNodePoolsFile(dir, types.Azure, cfg))
+	require.NoError(t, writeZonesFile(dir, types.Azure, cfg))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, tfZonesFile))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `availability_zones = ["1", "2"]`)
+	_, diags := configs.NewParser(nil).LoadConfigDir(dir)
+	require.False(t, diags.HasErrors(), "The zones should override the cluster and its node pools: %s", diags.Error())
+
+	require.NoError(t, writeZonesFile(dir, types.Azure, map[string]interface{}{}))
+	_, err = os.Stat(filepath.Join(dir, tfZonesFile))
+	require.True(t, os.IsNotExist(err), "The zones file should be removed without zones")
+
+	require.NoError(t, writeZonesFile(dir, types.GCP, cfg))
+	_, err = os.Stat(filepath.Join(dir, tfZonesFile))
+	require.True(t, os.IsNotExist(err), "GCP has the zones in its template")
+}
+
+func TestClusterZones(t *testing.T) {
+	t.Parallel()
+	zones, err := clusterZones(clusterState("google_container_cluster", "gke_cluster", "google", `{"location": "europe-west3", "node_locations": ["europe-west3-c", "europe-west3-a"]}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"europe-west3-a", "europe-west3-c"}, zones)
+
+	zones, err = clusterZones(clusterState("google_container_cluster", "gke_cluster", "google", `{"location": "europe-west3-a", "node_locations": []}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"europe-west3-a"}, zones, "A zonal cluster should be in its zone")
+
+	zones, err = clusterZones(clusterState("azurerm_kubernetes_cluster", "azure_cluster", "azurerm", `{"default_node_pool": [{"availability_zones": ["1", "2", "3"]}]}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "2", "3"}, zones)
+
+	zones, err = clusterZones(clusterState("kind", "kind-cluster", "kind", `{}`))
+	require.NoError(t, err)
+	require.Empty(t, zones)
+}
+
+func TestZoneChangeError(t *testing.T) {
+	t.Parallel()
+	zonal := clusterState("google_container_cluster", "gke_cluster", "google", `{"location": "europe-west3-a"}`)
+
+	require.NoError(t, zoneChangeError(zonal, types.GCP, map[string]interface{}{"location": "europe-west3-a"}))
+	require.NoError(t, zoneChangeError(zonal, types.GCP, map[string]interface{}{"zone": "europe-west3-a", "availability_zones": []string{"europe-west3-b"}}),
+		"Nodes can be added in other zones of the region")
+
+	err := zoneChangeError(zonal, types.GCP, map[string]interface{}{"region": "europe-west3"})
+	var recreateErr *types.RecreateError
+	require.True(t, errors.As(err, &recreateErr))
+	require.Equal(t, []string{"google_container_cluster.gke_cluster"}, recreateErr.Resources)
+	require.Contains(t, err.Error(), "the zonal cluster in europe-west3-a cannot become a regional cluster in europe-west3")
+
+	regional := clusterState("google_container_cluster", "gke_cluster", "google", `{"location": "europe-west3"}`)
+	require.Contains(t, zoneChangeError(regional, types.GCP, map[string]interface{}{"zone": "europe-west3-b"}).Error(), "cannot become a zonal cluster")
+
+	aks := clusterState("azurerm_kubernetes_cluster", "azure_cluster", "azurerm", `{"default_node_pool": [{"availability_zones": ["1", "2"]}]}`)
+	require.NoError(t, zoneChangeError(aks, types.Azure, map[string]interface{}{"availability_zones": []string{"2", "1"}}))
+	require.True(t, errors.As(zoneChangeError(aks, types.Azure, map[string]interface{}{}), &recreateErr), "Removing the zones should be refused")
+}
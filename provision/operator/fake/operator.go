@@ -0,0 +1,120 @@
+// Package fake provides an Operator for the tests of the hydroform consumers, so they can check how their code provisions clusters without a provider.
+package fake
+
+import (
+	"sync"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/operator"
+	"github.com/kyma-incubator/hydroform/provision/types"
+)
+
+// names of the operations in the recorded calls
+const (
+	CreateCall = "Create"
+	StatusCall = "Status"
+	DeleteCall = "Delete"
+)
+
+var _ operator.Operator = &Operator{}
+
+// Call is an operation run on the fake operator, with its arguments.
+type Call struct {
+	// Method is the name of the operation: CreateCall, StatusCall or DeleteCall.
+	Method   string
+	Provider types.ProviderType
+	// Config is a copy of the configuration the operation got, so later changes of the caller do not alter it.
+	Config map[string]interface{}
+	// State is the state given to Status and Delete.
+	State *statefile.File
+}
+
+// Operator records the operations it runs and returns the results configured by the test.
+// The zero value is ready to use: all operations succeed, Create returns an empty ClusterInfo and Status a provisioned cluster.
+// It is safe for concurrent use, but the results must be set before the operations run.
+type Operator struct {
+	// ClusterInfo is returned by Create.
+	ClusterInfo *types.ClusterInfo
+	// ClusterStatus is returned by Status.
+	ClusterStatus *types.ClusterStatus
+	// CreateErr, StatusErr and DeleteErr are returned by their operations. Create still returns its ClusterInfo with the error,
+	// as the real operators return the state of the resources created before failing.
+	CreateErr error
+	StatusErr error
+	DeleteErr error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Create records the call and returns the configured ClusterInfo and CreateErr.
+func (o *Operator) Create(p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error) {
+	o.record(Call{Method: CreateCall, Provider: p, Config: copyConfig(cfg)})
+	info := o.ClusterInfo
+	if info == nil {
+		info = &types.ClusterInfo{}
+	}
+	return info, o.CreateErr
+}
+
+// Status records the call and returns the configured ClusterStatus and StatusErr.
+func (o *Operator) Status(state *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterStatus, error) {
+	o.record(Call{Method: StatusCall, Provider: p, Config: copyConfig(cfg), State: state})
+	if o.StatusErr != nil {
+		return nil, o.StatusErr
+	}
+	status := o.ClusterStatus
+	if status == nil {
+		status = &types.ClusterStatus{Phase: types.Provisioned}
+	}
+	return status, nil
+}
+
+// Delete records the call and returns the configured DeleteErr.
+func (o *Operator) Delete(state *statefile.File, p types.ProviderType, cfg map[string]interface{}) error {
+	o.record(Call{Method: DeleteCall, Provider: p, Config: copyConfig(cfg), State: state})
+	return o.DeleteErr
+}
+
+// Calls returns the operations run so far, in order.
+func (o *Operator) Calls() []Call {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Call(nil), o.calls...)
+}
+
+// CallsTo returns the calls of the given operation run so far, in order.
+func (o *Operator) CallsTo(method string) []Call {
+	var calls []Call
+	for _, c := range o.Calls() {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls, the configured results are kept.
+func (o *Operator) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = nil
+}
+
+func (o *Operator) record(c Call) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, c)
+}
+
+// copyConfig returns a shallow copy of the configuration, or nil for a nil one.
+func copyConfig(cfg map[string]interface{}) map[string]interface{} {
+	if cfg == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		c[k] = v
+	}
+	return c
+}
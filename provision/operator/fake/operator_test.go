@@ -0,0 +1,61 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperator(t *testing.T) {
+	t.Parallel()
+	op := &Operator{}
+	cfg := map[string]interface{}{"project": "my-project", "cluster_name": "my-cluster"}
+
+	info, err := op.Create(types.GCP, cfg)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	status, err := op.Status(nil, types.GCP, cfg)
+	require.NoError(t, err)
+	require.Equal(t, types.Provisioned, status.Phase, "The default status should be provisioned")
+
+	// the recorded configuration does not change with the one of the caller
+	cfg["cluster_name"] = "other-cluster"
+	state := &statefile.File{Serial: 3}
+	require.NoError(t, op.Delete(state, types.GCP, cfg))
+
+	calls := op.Calls()
+	require.Len(t, calls, 3)
+	require.Equal(t, []string{CreateCall, StatusCall, DeleteCall}, []string{calls[0].Method, calls[1].Method, calls[2].Method})
+	require.Equal(t, "my-cluster", calls[0].Config["cluster_name"])
+	require.Equal(t, []Call{{Method: DeleteCall, Provider: types.GCP, Config: map[string]interface{}{"project": "my-project", "cluster_name": "other-cluster"}, State: state}}, op.CallsTo(DeleteCall))
+
+	op.Reset()
+	require.Empty(t, op.Calls())
+}
+
+func TestOperatorResults(t *testing.T) {
+	t.Parallel()
+	errQuota := errors.Wrap(types.ErrQuotaExceeded, "too many CPUs")
+	op := &Operator{
+		ClusterInfo:   &types.ClusterInfo{Endpoint: "https://1.2.3.4"},
+		ClusterStatus: &types.ClusterStatus{Phase: types.Errored},
+		CreateErr:     errQuota,
+		DeleteErr:     types.ErrStateNotFound,
+	}
+
+	info, err := op.Create(types.AWS, nil)
+	require.True(t, errors.Is(err, types.ErrQuotaExceeded))
+	require.Equal(t, "https://1.2.3.4", info.Endpoint, "Create should return the cluster with its error")
+	status, err := op.Status(nil, types.AWS, nil)
+	require.NoError(t, err)
+	require.Equal(t, types.Errored, status.Phase)
+	require.Equal(t, types.ErrStateNotFound, op.Delete(nil, types.AWS, nil))
+
+	op.StatusErr = types.ErrProviderUnavailable
+	_, err = op.Status(nil, types.AWS, nil)
+	require.Equal(t, types.ErrProviderUnavailable, err)
+	require.Len(t, op.CallsTo(StatusCall), 2)
+}
@@ -0,0 +1,51 @@
+// Package operator provides the operators provisioning the clusters, so callers can depend on the Operator interface
+// and switch between its implementations.
+package operator
+
+import (
+	"github.com/hashicorp/terraform/states/statefile"
+	terraform_operator "github.com/kyma-incubator/hydroform/provision/internal/operator/terraform"
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+)
+
+// Operator creates, checks and removes clusters on the providers.
+type Operator interface {
+	// Create creates a new cluster on the given provider based on the configuration and returns the same cluster enriched with its current state.
+	// If it fails after creating some resources, the ClusterInfo with their state is returned together with the error.
+	Create(p types.ProviderType, cfg map[string]interface{}) (*types.ClusterInfo, error)
+	// Status checks the cluster status based on the given state.
+	// If the state is empty or nil, Status will attempt to load the state from the file system.
+	Status(state *statefile.File, p types.ProviderType, cfg map[string]interface{}) (*types.ClusterStatus, error)
+	// Delete removes a cluster. For this operation a valid state is necessary.
+	// If the state is empty or nil, Delete will attempt to load the state from the file system.
+	Delete(state *statefile.File, p types.ProviderType, cfg map[string]interface{}) error
+}
+
+// Kind is the implementation of an operator.
+type Kind string
+
+const (
+	// Terraform provisions the clusters with the terraform templates of the providers.
+	Terraform Kind = "terraform"
+	// Native provisions the clusters with the SDKs of the providers.
+	Native Kind = "native"
+)
+
+// New returns an operator of the given kind configured with the options.
+// There is no native operator yet, so asking for one returns an ErrUnsupportedOperation.
+func New(kind Kind, ops ...types.Option) (Operator, error) {
+	options := &types.Options{}
+	for _, o := range ops {
+		o(options)
+	}
+
+	switch kind {
+	case Terraform:
+		return terraform_operator.New(terraform_operator.ToTerraformOptions(options)...), nil
+	case Native:
+		return nil, errors.Wrap(types.ErrUnsupportedOperation, "the native operator is not available yet")
+	default:
+		return nil, errors.Errorf("operator kind %q is not supported", kind)
+	}
+}
@@ -0,0 +1,31 @@
+package operator
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kyma-incubator/hydroform/provision/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "hf-operator")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	op, err := New(Terraform, types.WithDataDir(dir))
+	require.NoError(t, err)
+	require.NotNil(t, op)
+	// the options reach the operator, which rejects the configuration before running terraform
+	_, err = op.Create(types.GCP, map[string]interface{}{})
+	require.Error(t, err)
+
+	_, err = New(Native)
+	require.True(t, errors.Is(err, types.ErrUnsupportedOperation))
+
+	_, err = New("other")
+	require.Error(t, err)
+}
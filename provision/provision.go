@@ -8,9 +8,13 @@ import (
 
 	"github.com/kyma-incubator/hydroform/provision/action"
 
+	"github.com/kyma-incubator/hydroform/provision/internal/alicloud"
+	"github.com/kyma-incubator/hydroform/provision/internal/aws"
 	"github.com/kyma-incubator/hydroform/provision/internal/azure"
+	"github.com/kyma-incubator/hydroform/provision/internal/digitalocean"
 	"github.com/kyma-incubator/hydroform/provision/internal/gardener"
 	"github.com/kyma-incubator/hydroform/provision/internal/kind"
+	"github.com/kyma-incubator/hydroform/provision/internal/openstack"
 
 	"github.com/kyma-incubator/hydroform/provision/internal/gcp"
 	"github.com/kyma-incubator/hydroform/provision/internal/operator"
@@ -27,7 +31,7 @@ type Provisioner interface {
 	Deprovision(cluster *types.Cluster, provider *types.Provider) error
 }
 
-// Provision creates a new cluster for a given provider based on specific cluster and provider parameters. It returns a cluster object enriched with information from the provider, such as the IP address or the connection endpoint. This object is necessary for the other operations, such as retrieving the cluster status or deprovisioning the cluster. If the cluster cannot be created, the function returns an error.
+// Provision creates a new cluster for a given provider based on specific cluster and provider parameters. It returns a cluster object enriched with information from the provider, such as the IP address or the connection endpoint. This object is necessary for the other operations, such as retrieving the cluster status or deprovisioning the cluster. If the cluster cannot be created, the function returns an error. If it fails after creating some resources, the returned cluster holds their state together with the error, so the cluster can still be deprovisioned.
 func Provision(cluster *types.Cluster, provider *types.Provider, ops ...types.Option) (*types.Cluster, error) {
 	var err error
 	var cl *types.Cluster
@@ -46,11 +50,17 @@ func Provision(cluster *types.Cluster, provider *types.Provider, ops ...types.Op
 	case types.Gardener:
 		cl, err = newGardenerProvisioner(provisioningOperator, ops...).Provision(cluster, provider)
 	case types.AWS:
-		err = errors.New("aws not supported yet")
+		cl, err = newAWSProvisioner(provisioningOperator, ops...).Provision(cluster, provider)
 	case types.Azure:
 		cl, err = newAzureProvisioner(provisioningOperator, ops...).Provision(cluster, provider)
 	case types.Kind:
 		cl, err = newKindProvisioner(provisioningOperator, ops...).Provision(cluster, provider)
+	case types.OpenStack:
+		cl, err = newOpenStackProvisioner(provisioningOperator, ops...).Provision(cluster, provider)
+	case types.DigitalOcean:
+		cl, err = newDigitalOceanProvisioner(provisioningOperator, ops...).Provision(cluster, provider)
+	case types.AliCloud:
+		cl, err = newAliCloudProvisioner(provisioningOperator, ops...).Provision(cluster, provider)
 	default:
 		err = errors.New("unknown provider")
 	}
@@ -80,11 +90,17 @@ func Status(cluster *types.Cluster, provider *types.Provider, ops ...types.Optio
 	case types.Gardener:
 		cs, err = newGardenerProvisioner(provisioningOperator, ops...).Status(cluster, provider)
 	case types.AWS:
-		err = errors.New("aws not supported yet")
+		cs, err = newAWSProvisioner(provisioningOperator, ops...).Status(cluster, provider)
 	case types.Azure:
 		cs, err = newAzureProvisioner(provisioningOperator, ops...).Status(cluster, provider)
 	case types.Kind:
 		cs, err = newKindProvisioner(provisioningOperator, ops...).Status(cluster, provider)
+	case types.OpenStack:
+		cs, err = newOpenStackProvisioner(provisioningOperator, ops...).Status(cluster, provider)
+	case types.DigitalOcean:
+		cs, err = newDigitalOceanProvisioner(provisioningOperator, ops...).Status(cluster, provider)
+	case types.AliCloud:
+		cs, err = newAliCloudProvisioner(provisioningOperator, ops...).Status(cluster, provider)
 	default:
 		err = errors.New("unknown provider")
 	}
@@ -114,11 +130,17 @@ func Credentials(cluster *types.Cluster, provider *types.Provider, ops ...types.
 	case types.Gardener:
 		cr, err = newGardenerProvisioner(provisioningOperator, ops...).Credentials(cluster, provider)
 	case types.AWS:
-		err = errors.New("aws not supported yet")
+		cr, err = newAWSProvisioner(provisioningOperator, ops...).Credentials(cluster, provider)
 	case types.Azure:
 		cr, err = newAzureProvisioner(provisioningOperator, ops...).Credentials(cluster, provider)
 	case types.Kind:
 		cr, err = newKindProvisioner(provisioningOperator, ops...).Credentials(cluster, provider)
+	case types.OpenStack:
+		cr, err = newOpenStackProvisioner(provisioningOperator, ops...).Credentials(cluster, provider)
+	case types.DigitalOcean:
+		cr, err = newDigitalOceanProvisioner(provisioningOperator, ops...).Credentials(cluster, provider)
+	case types.AliCloud:
+		cr, err = newAliCloudProvisioner(provisioningOperator, ops...).Credentials(cluster, provider)
 	default:
 		err = errors.New("unknown provider")
 	}
@@ -147,11 +169,17 @@ func Deprovision(cluster *types.Cluster, provider *types.Provider, ops ...types.
 	case types.Gardener:
 		err = newGardenerProvisioner(provisioningOperator, ops...).Deprovision(cluster, provider)
 	case types.AWS:
-		err = errors.New("aws not supported yet")
+		err = newAWSProvisioner(provisioningOperator, ops...).Deprovision(cluster, provider)
 	case types.Azure:
 		err = newAzureProvisioner(provisioningOperator, ops...).Deprovision(cluster, provider)
 	case types.Kind:
 		err = newKindProvisioner(provisioningOperator, ops...).Deprovision(cluster, provider)
+	case types.OpenStack:
+		err = newOpenStackProvisioner(provisioningOperator, ops...).Deprovision(cluster, provider)
+	case types.DigitalOcean:
+		err = newDigitalOceanProvisioner(provisioningOperator, ops...).Deprovision(cluster, provider)
+	case types.AliCloud:
+		err = newAliCloudProvisioner(provisioningOperator, ops...).Deprovision(cluster, provider)
 	default:
 		err = errors.New("unknown provider")
 	}
@@ -170,7 +198,7 @@ func newGardenerProvisioner(operatorType operator.Type, ops ...types.Option) Pro
 }
 
 func newAWSProvisioner(operatorType operator.Type, ops ...types.Option) Provisioner {
-	return nil
+	return aws.New(operatorType, ops...)
 }
 
 func newAzureProvisioner(operatorType operator.Type, ops ...types.Option) Provisioner {
@@ -181,6 +209,18 @@ func newKindProvisioner(operatorType operator.Type, ops ...types.Option) Provisi
 	return kind.New(operatorType, ops...)
 }
 
+func newOpenStackProvisioner(operatorType operator.Type, ops ...types.Option) Provisioner {
+	return openstack.New(operatorType, ops...)
+}
+
+func newDigitalOceanProvisioner(operatorType operator.Type, ops ...types.Option) Provisioner {
+	return digitalocean.New(operatorType, ops...)
+}
+
+func newAliCloudProvisioner(operatorType operator.Type, ops ...types.Option) Provisioner {
+	return alicloud.New(operatorType, ops...)
+}
+
 func updateWindowsPath(windowsPath string) string {
 	cleanWindowsPath := filepath.Clean(windowsPath)
 	return strings.Replace(cleanWindowsPath, `\`, `\\`, -1)
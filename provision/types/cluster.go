@@ -1,40 +1,240 @@
 package types
 
-import "github.com/hashicorp/terraform/states/statefile"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
 
 // Cluster contains detailed cluster specification and properties.
 type Cluster struct {
 	// Name specifies the unique name used to identify the cluster.
-	Name string `json:"name"`
+	Name string `json:"name" yaml:"name"`
 	// KubernetesVersion specifies the Kubernetes version used.
-	KubernetesVersion string `json:"kubernetesVersion"`
+	KubernetesVersion string `json:"kubernetesVersion" yaml:"kubernetesVersion"`
 	// CPU specifies the number of CPUs available in the cluster.
-	CPU int `json:"cpu"`
+	CPU int `json:"cpu" yaml:"cpu"`
 	// DiskSizeGB indicates the disk size available in the cluster.
-	DiskSizeGB int `json:"diskSizeGB"`
+	DiskSizeGB int `json:"diskSizeGB" yaml:"diskSizeGB"`
 	// NodeCount specifies the number of nodes available in the cluster.
-	NodeCount int `json:"nodeCount"`
+	NodeCount int `json:"nodeCount" yaml:"nodeCount"`
 	// MachineType specifies the hardware cluster is provisioned on.
-	MachineType string `json:"machineType"`
+	MachineType string `json:"machineType" yaml:"machineType"`
 	// Location specifies the location of the actual cluster.
-	Location    string       `json:"location"`
-	ClusterInfo *ClusterInfo `json:"clusterInfo"`
+	Location    string       `json:"location" yaml:"location"`
+	ClusterInfo *ClusterInfo `json:"clusterInfo" yaml:"clusterInfo"`
 }
 
 // ClusterInfo contains the actual provider-related cluster details retrieved after the cluster was provisioned.
 type ClusterInfo struct {
 	// Endpoint specifies the URL at which you can reach the cluster.
-	Endpoint string `json:"endpoint"`
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
 	// CertificateAuthorityData contains certificates required to access the cluster.
-	CertificateAuthorityData []byte `json:"certificateAuthorityData"`
+	CertificateAuthorityData []byte `json:"certificateAuthorityData" yaml:"certificateAuthorityData"`
+	// Kubeconfig contains the kubeconfig to access the cluster. It is only set once the cluster is provisioned.
+	Kubeconfig string `json:"kubeconfig" yaml:"kubeconfig"`
+	// Outputs contains all outputs of the cluster module, decoded as JSON values.
+	Outputs map[string]interface{} `json:"outputs" yaml:"outputs"`
+	// SensitiveOutputs lists the names of the outputs marked as sensitive, so they can be redacted.
+	SensitiveOutputs []string `json:"sensitiveOutputs" yaml:"sensitiveOutputs"`
+	// Autoscaling tells for each node pool, by name, whether the provider scales its nodes automatically.
+	Autoscaling map[string]bool `json:"autoscaling" yaml:"autoscaling"`
+	// PrivateEndpoint indicates that the control plane is only reachable from inside the network of the cluster,
+	// so the endpoint may not be usable from where Hydroform runs.
+	PrivateEndpoint bool `json:"privateEndpoint" yaml:"privateEndpoint"`
+	// Zones lists the availability zones the nodes of the cluster span, sorted. It is empty if the provider does not place the cluster in zones.
+	Zones []string `json:"zones" yaml:"zones"`
+	// ApplySummary lists the resources the apply of Create or Update changed. It is nil for the other operations.
+	ApplySummary *ApplySummary `json:"applySummary,omitempty" yaml:"applySummary,omitempty"`
 	// InternalState contains the Hydroform-specific information used to manage the cluster.
-	InternalState *InternalState `json:"internalState"`
-	Status        *ClusterStatus `json:"status"`
+	InternalState *InternalState `json:"internalState" yaml:"internalState"`
+	Status        *ClusterStatus `json:"status" yaml:"status"`
+}
+
+// ApplySummary counts the resources an apply added, changed and destroyed, as the summary terraform prints once it finished.
+// A replaced resource is counted as added and destroyed. The resources are listed by address, in the order terraform completed them.
+type ApplySummary struct {
+	Added     int `json:"added" yaml:"added"`
+	Changed   int `json:"changed" yaml:"changed"`
+	Destroyed int `json:"destroyed" yaml:"destroyed"`
+
+	AddedResources     []string `json:"addedResources,omitempty" yaml:"addedResources,omitempty"`
+	ChangedResources   []string `json:"changedResources,omitempty" yaml:"changedResources,omitempty"`
+	DestroyedResources []string `json:"destroyedResources,omitempty" yaml:"destroyedResources,omitempty"`
+}
+
+func (s ApplySummary) String() string {
+	return fmt.Sprintf("%d added, %d changed, %d destroyed", s.Added, s.Changed, s.Destroyed)
+}
+
+// RedactedValue replaces the sensitive values redacted from cluster infos and states.
+const RedactedValue = "<sensitive>"
+
+// RedactSensitive returns a copy of the ClusterInfo that is safe to log or attach to a bug report.
+// The outputs terraform marks sensitive are replaced with RedactedValue, as are the kubeconfig with the credentials of the cluster
+// and the outputs it was read from. The copy has no InternalState, since the state holds the same values: export it with RedactedStateJSON.
+func (c *ClusterInfo) RedactSensitive() *ClusterInfo {
+	if c == nil {
+		return nil
+	}
+	r := *c
+	if r.Kubeconfig != "" {
+		r.Kubeconfig = RedactedValue
+	}
+	if c.Outputs != nil {
+		sensitive := make(map[string]bool, len(c.SensitiveOutputs))
+		for _, name := range c.SensitiveOutputs {
+			sensitive[name] = true
+		}
+		r.Outputs = make(map[string]interface{}, len(c.Outputs))
+		for name, v := range c.Outputs {
+			if s, ok := v.(string); sensitive[name] || (ok && c.Kubeconfig != "" && s == c.Kubeconfig) {
+				v = RedactedValue
+			}
+			r.Outputs[name] = v
+		}
+	}
+	r.InternalState = nil
+	return &r
+}
+
+// TerraformState returns the terraform state of the cluster, or nil if there is none.
+// Pass it to the operations on the cluster, it is the only copy of the state when the cluster files are not persistent.
+func (c *ClusterInfo) TerraformState() *statefile.File {
+	if c == nil || c.InternalState == nil {
+		return nil
+	}
+	return c.InternalState.TerraformState
 }
 
 // ClusterStatus contains possible values used to indicate the current cluster status.
 type ClusterStatus struct {
+	Phase Phase `json:"phase" yaml:"phase"`
+}
+
+// DetailedStatus contains the health of each resource of the cluster next to its phase.
+// The phase is Provisioned only if the control plane and all node pools are healthy.
+type DetailedStatus struct {
 	Phase Phase `json:"phase"`
+	// ControlPlane is the status of the cluster resource. It is nil if there are no resources in the state.
+	ControlPlane *ResourceStatus `json:"controlPlane"`
+	// NodePools are the statuses of the node pools of the configuration and the state, sorted by name.
+	NodePools []ResourceStatus `json:"nodePools"`
+}
+
+// ResourceStatus contains the health of a cluster resource.
+type ResourceStatus struct {
+	// Name is the name of the resource on the provider, or the terraform resource name if it is not known.
+	Name string `json:"name"`
+	// Address is the terraform address of the resource.
+	Address string `json:"address"`
+	Health  Health `json:"health"`
+	// Reason explains the health, such as the status reported by the provider.
+	Reason string `json:"reason"`
+}
+
+// DriftReport lists the differences between a configuration and the state of its cluster, as a hint whether an update is needed without planning it.
+// It compares the values of the configuration with the ones recorded in the state, refresh the state first to compare with the current condition of the resources.
+type DriftReport struct {
+	// AddedResources are the addresses of the resources of the configuration missing in the state, they would be created.
+	AddedResources []string `json:"addedResources"`
+	// RemovedResources are the addresses of the resources of the state that are not in the configuration anymore, they would be destroyed.
+	RemovedResources []string `json:"removedResources"`
+	// Changes are the attributes whose value in the state differs from the configuration.
+	Changes []AttributeDrift `json:"changes"`
+}
+
+// HasDrift reports whether the state differs from the configuration.
+func (r *DriftReport) HasDrift() bool {
+	return len(r.AddedResources) > 0 || len(r.RemovedResources) > 0 || len(r.Changes) > 0
+}
+
+// AttributeDrift is an attribute of a cluster resource whose value in the state differs from the configuration.
+type AttributeDrift struct {
+	// Address is the terraform address of the resource.
+	Address string `json:"address"`
+	// Attribute is the path of the attribute in the resource, such as node_config.0.machine_type.
+	Attribute string `json:"attribute"`
+	// Field is the configuration field the attribute comes from, such as machine_type or node_pools[0].node_count.
+	Field string `json:"field"`
+	// Desired is the value of the configuration, Actual the one of the state, in their JSON representation.
+	Desired interface{} `json:"desired"`
+	Actual  interface{} `json:"actual"`
+}
+
+// Health indicates the condition of a cluster resource.
+type Health string
+
+const (
+	// Healthy indicates that the resource exists and is ready.
+	Healthy Health = "Healthy"
+	// Progressing indicates that the provider is still creating or updating the resource.
+	Progressing Health = "Progressing"
+	// Failed indicates that the resource could not be created or is in an error condition.
+	Failed Health = "Failed"
+	// Missing indicates that the resource is expected but does not exist.
+	Missing Health = "Missing"
+)
+
+// ClusterPlan contains the changes that provisioning the cluster would perform on its resources.
+// Resources that would be destroyed and created again are listed both in Add and Destroy.
+type ClusterPlan struct {
+	// Add lists the addresses of the resources that would be created.
+	Add []string `json:"add"`
+	// Change lists the addresses of the resources that would be updated in place.
+	Change []string `json:"change"`
+	// Destroy lists the addresses of the resources that would be destroyed.
+	Destroy []string `json:"destroy"`
+}
+
+// ClusterRef identifies a cluster tracked by Hydroform.
+type ClusterRef struct {
+	// Provider is the provider the cluster runs on.
+	Provider ProviderType `json:"provider"`
+	// Project is the project the cluster belongs to.
+	Project string `json:"project"`
+	// Name is the name of the cluster.
+	Name string `json:"name"`
+	// HasState indicates if there is a usable terraform state for the cluster, with at least one resource in it.
+	HasState bool `json:"hasState"`
+}
+
+// ClusterRequest is a cluster to provision or deprovision in a batch.
+type ClusterRequest struct {
+	// Provider is the provider of the cluster.
+	Provider ProviderType
+	// Config is the configuration of the cluster, as for single clusters.
+	Config map[string]interface{}
+	// State is the state of the cluster to deprovision, if nil it is loaded from the data dir or the backend. Provisioning ignores it.
+	State *statefile.File
+}
+
+// BatchResult is the outcome of provisioning or deprovisioning one cluster of a batch.
+type BatchResult struct {
+	// Provider, Project and Cluster identify the cluster of the request.
+	Provider ProviderType
+	Project  string
+	Cluster  string
+	// ClusterInfo is the cluster provisioned, also when the provisioning failed after creating resources. It is nil for deprovisioning.
+	ClusterInfo *ClusterInfo
+	// Err is the error of the cluster, nil if it succeeded.
+	Err error
+}
+
+// ManagedResource describes a resource instance in the terraform state of a cluster.
+type ManagedResource struct {
+	// Address is the terraform address of the resource instance, such as google_container_node_pool.pool1 or module.network.aws_vpc.vpc[0].
+	Address string `json:"address"`
+	// Type is the terraform resource type, such as google_container_cluster.
+	Type string `json:"type"`
+	// Provider is the terraform provider managing the resource, such as google.
+	Provider string `json:"provider"`
+	// ID is the ID of the resource in the provider, empty if the state has none.
+	ID string `json:"id,omitempty"`
+	// Tainted indicates that the resource failed to be created and will be replaced by the next apply.
+	Tainted bool `json:"tainted,omitempty"`
 }
 
 // Phase indicates the current status of the cluster.
@@ -45,11 +245,170 @@ const (
 	Provisioned Phase = "Provisioned"
 	// Errored indicates that the cluster may be unusable due to errors.
 	Errored Phase = "Errored"
+	// Provisioning indicates that the cluster resources are still being created or updated.
+	Provisioning Phase = "Provisioning"
 	// Unknown indicates that the cluster status is not known.
 	Unknown Phase = "Unknown"
 )
 
 // InternalState holds the state information of the internal operator which is currently in use. Hydroform uses this information for internal purposes only.
+// It is marshaled to JSON with the terraform state in the format of the state files, and to YAML with the state file as a string,
+// so a ClusterInfo passed between processes keeps a state the operations can use.
 type InternalState struct {
 	TerraformState *statefile.File
 }
+
+// internalStateYAML is the YAML representation of an InternalState.
+type internalStateYAML struct {
+	TerraformState string `yaml:"terraformState,omitempty"`
+}
+
+func (s InternalState) MarshalJSON() ([]byte, error) {
+	state, err := s.stateFile()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = []byte("null")
+	}
+	return json.Marshal(struct {
+		TerraformState json.RawMessage `json:"terraformState"`
+	}{state})
+}
+
+func (s *InternalState) UnmarshalJSON(data []byte) error {
+	var v struct {
+		TerraformState json.RawMessage `json:"terraformState"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.TerraformState) == 0 || string(v.TerraformState) == "null" {
+		s.TerraformState = nil
+		return nil
+	}
+	return s.readStateFile(v.TerraformState)
+}
+
+func (s InternalState) MarshalYAML() (interface{}, error) {
+	state, err := s.stateFile()
+	if err != nil {
+		return nil, err
+	}
+	return internalStateYAML{TerraformState: string(state)}, nil
+}
+
+func (s *InternalState) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v internalStateYAML
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	if v.TerraformState == "" {
+		s.TerraformState = nil
+		return nil
+	}
+	return s.readStateFile([]byte(v.TerraformState))
+}
+
+// stateFile returns the terraform state in the format of the state files, nil if there is none.
+func (s InternalState) stateFile() ([]byte, error) {
+	if s.TerraformState == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := statefile.Write(s.TerraformState, &buf); err != nil {
+		return nil, fmt.Errorf("could not write the terraform state: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readStateFile sets the terraform state from the given state file.
+func (s *InternalState) readStateFile(data []byte) error {
+	sf, err := statefile.Read(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not read the terraform state: %w", err)
+	}
+	s.TerraformState = sf
+	return nil
+}
+
+// NodePool describes a group of nodes added to a cluster next to its default nodes, such as nodes with GPUs.
+// Node pools are set in the configuration with the "node_pools" key. They are supported on GCP and Azure.
+type NodePool struct {
+	// Name identifies the node pool in the cluster. It must be unique per cluster.
+	Name string `json:"name"`
+	// MachineType specifies the hardware the nodes of the pool run on.
+	MachineType string `json:"machineType"`
+	// NodeCount specifies the number of nodes in the pool.
+	NodeCount int `json:"nodeCount"`
+	// DiskSizeGB indicates the disk size of each node. If 0, the provider default is used.
+	// Neither GKE nor AKS grow the disks of the nodes in place, changing it on update replaces the node pool.
+	DiskSizeGB int `json:"diskSizeGB"`
+	// DiskType is the type of the boot disk of each node: pd-standard, pd-balanced or pd-ssd on GCP, Managed or Ephemeral on Azure.
+	// If empty, the provider default is used. Update refuses to change it with a RecreateError, create a new node pool instead.
+	DiskType string `json:"diskType"`
+	// Labels are the Kubernetes labels set on the nodes of the pool.
+	Labels map[string]string `json:"labels"`
+	// Taints are the Kubernetes taints set on the nodes of the pool.
+	Taints []Taint `json:"taints"`
+	// Autoscaling lets the provider scale the number of nodes of the pool. If nil, the pool keeps NodeCount nodes.
+	Autoscaling *Autoscaling `json:"autoscaling"`
+	// KubernetesVersion is the version of the nodes of the pool. If empty, the nodes run the version of the cluster.
+	KubernetesVersion string `json:"kubernetesVersion"`
+	// Upgrade controls how the provider replaces the nodes when their version changes. If nil, the provider defaults are used.
+	Upgrade *UpgradeSettings `json:"upgrade"`
+	// Spot runs the nodes on spare capacity of the provider at a discount: spot VMs on Azure, preemptible VMs on GCP.
+	// The provider can take the nodes away at any time. Changing it on update replaces the node pool.
+	Spot bool `json:"spot"`
+	// MaxSpotPrice is the highest price per hour in US dollars paid for a node of a spot pool, nodes are evicted above it.
+	// If 0, nodes are evicted for capacity only, up to the on-demand price. It is only supported on Azure.
+	MaxSpotPrice float64 `json:"maxSpotPrice"`
+}
+
+// UpgradeSettings specifies a surge upgrade of the nodes of a node pool: the provider adds up to MaxSurge new nodes
+// and takes down up to MaxUnavailable old nodes at a time, instead of replacing them one after the other.
+// Azure only supports MaxSurge.
+type UpgradeSettings struct {
+	MaxSurge       int `json:"maxSurge"`
+	MaxUnavailable int `json:"maxUnavailable"`
+}
+
+// Autoscaling specifies the range in which the provider scales the nodes of a node pool.
+// While autoscaling is enabled, NodeCount is only the initial number of nodes: updates keep the current number of nodes.
+type Autoscaling struct {
+	Enabled  bool `json:"enabled"`
+	MinCount int  `json:"minCount"`
+	MaxCount int  `json:"maxCount"`
+}
+
+// Taint is a Kubernetes taint set on the nodes of a node pool.
+type Taint struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Effect is the Kubernetes taint effect: NoSchedule, PreferNoSchedule or NoExecute.
+	Effect string `json:"effect"`
+}
+
+// NodeAutoProvisioning lets GKE create and remove node pools for the pending workloads of the cluster, within the resource limits of the whole cluster.
+// It is set in the configuration with the "node_auto_provisioning" key. It is only supported on GCP.
+type NodeAutoProvisioning struct {
+	MinCPU      int `json:"minCPU"`
+	MaxCPU      int `json:"maxCPU"`
+	MinMemoryGB int `json:"minMemoryGB"`
+	MaxMemoryGB int `json:"maxMemoryGB"`
+}
+
+// AdditionalProvider is a terraform provider block rendered next to the one of the cluster, so the template can manage resources on another provider,
+// such as the Route53 records of the domain of a GCP cluster. It is set in the configuration with the "additional_providers" key.
+// The block authenticates with the credentials of its provider, the Credentials option or, for the provider of the cluster, the rotated credentials
+// and the ones of the secret: the values are set as arguments of the block, and the credentials file as the credentials of the google provider
+// and the shared_credentials_file of the aws provider. The other providers only take values. The arguments reference variables the operation
+// passes in a private temporary vars file, so the rendered block never holds the credentials.
+type AdditionalProvider struct {
+	// Provider is the provider of the block, such as AWS for the aws terraform provider.
+	Provider ProviderType `json:"provider"`
+	// Alias is the alias of the block, the resources of the template select it with provider = aws.<alias>. It is required.
+	Alias string `json:"alias"`
+	// Settings are the arguments of the block, such as the region. They take precedence over the credentials.
+	Settings map[string]string `json:"settings"`
+}
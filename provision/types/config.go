@@ -0,0 +1,361 @@
+package types
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// ProviderConfig is the typed configuration of a cluster on a provider, an alternative to the configuration maps of the operators
+// that is checked at compile time. The operators receive it as a configuration map.
+type ProviderConfig interface {
+	// Provider returns the provider the cluster runs on.
+	Provider() ProviderType
+	// ToMap returns the configuration map of the cluster, without the optional fields left empty.
+	ToMap() map[string]interface{}
+}
+
+// ClusterConfig identifies a cluster, it is part of the configuration of all providers.
+type ClusterConfig struct {
+	Project     string
+	ClusterName string
+	// Custom contains additional configuration values, such as the variables of a custom template.
+	// They are set as is, the typed fields take precedence over them.
+	Custom map[string]interface{}
+	// ExtraVars are passed to the terraform template as is, for the variables it declares but hydroform does not map.
+	// The variables hydroform sets from the configuration take precedence over them.
+	ExtraVars map[string]interface{}
+	// AdditionalProviders are provider blocks rendered next to the one of the cluster, for the resources the template manages on other providers.
+	AdditionalProviders []AdditionalProvider
+	// KubeconfigContextName names the context, the cluster and the user of the returned kubeconfigs, so the kubeconfigs of a fleet can be merged.
+	// If empty, they are named <project>-<cluster name>-<provider>.
+	KubeconfigContextName string
+}
+
+// GCPConfig is the configuration of a GKE cluster.
+type GCPConfig struct {
+	ClusterConfig
+	CredentialsFilePath string
+	// Location is the region of a regional cluster or the zone of a zonal one.
+	// Region and Zone set it explicitly instead, only one of them can be set.
+	Location string
+	Region   string
+	Zone     string
+	// AvailabilityZones spread the nodes over the given zones of the region of the cluster.
+	AvailabilityZones []string
+	NodeCount         int
+	MachineType       string
+	DiskSizeGB        int
+	// KubernetesVersion can only be left empty with a release channel.
+	KubernetesVersion        string
+	ReleaseChannel           string
+	NodeAutoProvisioning     *NodeAutoProvisioning
+	NodePools                []NodePool
+	PrivateCluster           bool
+	EnablePrivateNodes       bool
+	MasterAuthorizedNetworks []string
+	MasterIPv4CIDRBlock      string
+	Labels                   map[string]string
+	Network                  string
+	Subnetwork               string
+	// WorkloadIdentity lets the pods act as the service accounts of the workload identity pool of the project.
+	// OIDCIssuer enables it too, the URL of the issuer of the cluster is then in the oidc_issuer_url output.
+	WorkloadIdentity bool
+	OIDCIssuer       bool
+	// EnableAuditLogging sends the logs of the API server and the system components to Cloud Logging.
+	EnableAuditLogging bool
+}
+
+// Provider returns GCP.
+func (c GCPConfig) Provider() ProviderType {
+	return GCP
+}
+
+// ToMap returns the configuration map of the GKE cluster.
+func (c GCPConfig) ToMap() map[string]interface{} {
+	m := c.ClusterConfig.toMap()
+	m["credentials_file_path"] = c.CredentialsFilePath
+	setOptional(m, "location", c.Location)
+	setOptional(m, "region", c.Region)
+	setOptional(m, "zone", c.Zone)
+	setOptional(m, "availability_zones", c.AvailabilityZones)
+	m["node_count"] = c.NodeCount
+	m["machine_type"] = c.MachineType
+	m["disk_size"] = c.DiskSizeGB
+	setOptional(m, "kubernetes_version", c.KubernetesVersion)
+	setOptional(m, "release_channel", c.ReleaseChannel)
+	if c.NodeAutoProvisioning != nil {
+		m["node_auto_provisioning"] = *c.NodeAutoProvisioning
+	}
+	setOptional(m, "node_pools", c.NodePools)
+	setOptional(m, "private_cluster", c.PrivateCluster)
+	setOptional(m, "enable_private_nodes", c.EnablePrivateNodes)
+	setOptional(m, "master_authorized_networks", c.MasterAuthorizedNetworks)
+	setOptional(m, "master_ipv4_cidr_block", c.MasterIPv4CIDRBlock)
+	setOptional(m, "labels", c.Labels)
+	setOptional(m, "network", c.Network)
+	setOptional(m, "subnetwork", c.Subnetwork)
+	setOptional(m, "workload_identity", c.WorkloadIdentity)
+	setOptional(m, "oidc_issuer", c.OIDCIssuer)
+	setOptional(m, "enable_audit_logging", c.EnableAuditLogging)
+	return m
+}
+
+// AzureConfig is the configuration of an AKS cluster.
+type AzureConfig struct {
+	ClusterConfig
+	// ResourceGroup is the resource group of the cluster. If empty, it is the project.
+	// With ExistingResourceGroup, the cluster goes into the existing resource group, which is not created nor deleted with the cluster.
+	ResourceGroup         string
+	ExistingResourceGroup bool
+	Location              string
+	NodeCount             int
+	MachineType           string
+	DiskSizeGB            int
+	KubernetesVersion     string
+	// SubscriptionID, TenantID, ClientID and ClientSecret are the credentials of the service principal creating the cluster.
+	SubscriptionID           string
+	TenantID                 string
+	ClientID                 string
+	ClientSecret             string
+	NodePools                []NodePool
+	PrivateCluster           bool
+	EnablePrivateNodes       bool
+	MasterAuthorizedNetworks []string
+	Labels                   map[string]string
+	// AvailabilityZones spread the nodes over the given zones of the location, such as 1, 2 and 3.
+	AvailabilityZones []string
+	// OIDCIssuer integrates the API server with AAD, the URL of the issuer of the tenant is then in the oidc_issuer_url output.
+	OIDCIssuer bool
+	// EnableAuditLogging sends the audit logs of the API server to a Log Analytics workspace created with the cluster.
+	EnableAuditLogging bool
+}
+
+// Provider returns Azure.
+func (c AzureConfig) Provider() ProviderType {
+	return Azure
+}
+
+// ToMap returns the configuration map of the AKS cluster.
+func (c AzureConfig) ToMap() map[string]interface{} {
+	m := c.ClusterConfig.toMap()
+	m["resource_group"] = c.ResourceGroup
+	if c.ResourceGroup == "" {
+		m["resource_group"] = c.Project
+	}
+	if c.ExistingResourceGroup {
+		m["create_resource_group"] = false
+	}
+	m["location"] = c.Location
+	m["agent_count"] = c.NodeCount
+	m["agent_vm_size"] = c.MachineType
+	m["agent_disk_size"] = c.DiskSizeGB
+	m["kubernetes_version"] = c.KubernetesVersion
+	setOptional(m, "subscription_id", c.SubscriptionID)
+	setOptional(m, "tenant_id", c.TenantID)
+	setOptional(m, "client_id", c.ClientID)
+	setOptional(m, "client_secret", c.ClientSecret)
+	setOptional(m, "node_pools", c.NodePools)
+	setOptional(m, "private_cluster", c.PrivateCluster)
+	setOptional(m, "enable_private_nodes", c.EnablePrivateNodes)
+	setOptional(m, "master_authorized_networks", c.MasterAuthorizedNetworks)
+	setOptional(m, "labels", c.Labels)
+	setOptional(m, "availability_zones", c.AvailabilityZones)
+	setOptional(m, "oidc_issuer", c.OIDCIssuer)
+	setOptional(m, "enable_audit_logging", c.EnableAuditLogging)
+	return m
+}
+
+// AWSConfig is the configuration of an EKS cluster.
+type AWSConfig struct {
+	ClusterConfig
+	Region            string
+	NodeCount         int
+	MachineType       string
+	DiskSizeGB        int
+	KubernetesVersion string
+	// CredentialsFilePath and Profile select the AWS credentials. If empty, the default credentials of the environment are used.
+	CredentialsFilePath string
+	Profile             string
+	Labels              map[string]string
+	CreateNetwork       bool
+	Network             string
+	Subnetworks         []string
+	// Spot runs the nodes on spot instances. It cannot be changed once the cluster is created.
+	Spot bool
+	// WorkloadIdentity and OIDCIssuer create an IAM OIDC provider for the issuer of the cluster, so the service accounts can assume IAM roles.
+	// The URL of the issuer is in the oidc_issuer_url output and the ARN of the provider in the oidc_provider_arn output.
+	WorkloadIdentity bool
+	OIDCIssuer       bool
+	// EnableAuditLogging sends the API server, audit and authenticator logs to CloudWatch.
+	EnableAuditLogging bool
+}
+
+// Provider returns AWS.
+func (c AWSConfig) Provider() ProviderType {
+	return AWS
+}
+
+// ToMap returns the configuration map of the EKS cluster.
+func (c AWSConfig) ToMap() map[string]interface{} {
+	m := c.ClusterConfig.toMap()
+	m["region"] = c.Region
+	m["node_count"] = c.NodeCount
+	m["machine_type"] = c.MachineType
+	m["disk_size"] = c.DiskSizeGB
+	m["kubernetes_version"] = c.KubernetesVersion
+	setOptional(m, "credentials_file_path", c.CredentialsFilePath)
+	setOptional(m, "profile", c.Profile)
+	setOptional(m, "labels", c.Labels)
+	setOptional(m, "create_network", c.CreateNetwork)
+	setOptional(m, "network", c.Network)
+	setOptional(m, "subnetworks", c.Subnetworks)
+	setOptional(m, "spot", c.Spot)
+	setOptional(m, "workload_identity", c.WorkloadIdentity)
+	setOptional(m, "oidc_issuer", c.OIDCIssuer)
+	setOptional(m, "enable_audit_logging", c.EnableAuditLogging)
+	return m
+}
+
+// GardenerConfig is the configuration of a Gardener shoot cluster.
+type GardenerConfig struct {
+	ClusterConfig
+	CredentialsFilePath string
+	// Namespace is the namespace of the project on Gardener. If empty, it is garden-<project>.
+	Namespace string
+	// TargetProvider is the provider the shoot runs on, TargetProfile its cloud profile and TargetSecret the secret binding with its credentials.
+	// SeedName pins the shoot to a seed cluster, if empty Gardener picks a seed of its region.
+	TargetProvider      string
+	TargetProfile       string
+	TargetSecret        string
+	SeedName            string
+	Location            string
+	Zones               []string
+	NodeCount           int
+	MachineType         string
+	MachineImageName    string
+	MachineImageVersion string
+	DiskSizeGB          int
+	DiskType            string
+	KubernetesVersion   string
+	// VnetCIDR and WorkerCIDR are the ranges of the network and the workers on the target provider.
+	VnetCIDR   string
+	WorkerCIDR string
+	// NetworkingNodes, NetworkingPods and NetworkingServices are the ranges of the shoot network. If empty, the nodes use the worker or network range.
+	NetworkingType       string
+	NetworkingNodes      string
+	NetworkingPods       string
+	NetworkingServices   string
+	ServiceEndpoints     []string
+	GCPControlPlaneZone  string
+	WorkerMinimum        int
+	WorkerMaximum        int
+	WorkerMaxSurge       int
+	WorkerMaxUnavailable int
+	Hibernated           bool
+	// DNSDomain is the domain of the shoot, such as my-cluster.example.com. If empty, Gardener assigns a domain of the garden.
+	// DNSProvider is the type of a DNS provider, such as aws-route53, managing the domain with the credentials of the target secret.
+	DNSDomain   string
+	DNSProvider string
+	// Addons are the Gardener add-ons enabled in the shoot: nginx_ingress and kubernetes_dashboard.
+	// With nginx_ingress and a DNSDomain the cluster info has the wildcard domain of the ingress in the ingress_domain output.
+	Addons []string
+}
+
+// Provider returns Gardener.
+func (c GardenerConfig) Provider() ProviderType {
+	return Gardener
+}
+
+// ToMap returns the configuration map of the shoot cluster.
+func (c GardenerConfig) ToMap() map[string]interface{} {
+	m := c.ClusterConfig.toMap()
+	m["credentials_file_path"] = c.CredentialsFilePath
+	m["namespace"] = c.Namespace
+	if c.Namespace == "" {
+		m["namespace"] = "garden-" + c.Project
+	}
+	m["target_provider"] = c.TargetProvider
+	m["target_profile"] = c.TargetProfile
+	m["target_secret"] = c.TargetSecret
+	m["location"] = c.Location
+	m["zones"] = c.Zones
+	if c.Zones == nil {
+		m["zones"] = []string{}
+	}
+	m["zoned"] = strconv.FormatBool(c.TargetProvider == string(Azure) && len(c.Zones) > 0)
+	m["node_count"] = c.NodeCount
+	m["machine_type"] = c.MachineType
+	m["machine_image_name"] = c.MachineImageName
+	m["machine_image_version"] = c.MachineImageVersion
+	m["disk_size"] = c.DiskSizeGB
+	m["disk_type"] = c.DiskType
+	m["kubernetes_version"] = c.KubernetesVersion
+	m["networking_type"] = c.NetworkingType
+	m["service_endpoints"] = c.ServiceEndpoints
+	if c.ServiceEndpoints == nil {
+		m["service_endpoints"] = []string{}
+	}
+	m["gcp_control_plane_zone"] = c.GCPControlPlaneZone
+	m["worker_minimum"] = c.WorkerMinimum
+	m["worker_maximum"] = c.WorkerMaximum
+	m["worker_max_surge"] = c.WorkerMaxSurge
+	m["worker_max_unavailable"] = c.WorkerMaxUnavailable
+	setOptional(m, "vnetcidr", c.VnetCIDR)
+	setOptional(m, "workercidr", c.WorkerCIDR)
+	setOptional(m, "networking_pods", c.NetworkingPods)
+	setOptional(m, "networking_services", c.NetworkingServices)
+	switch {
+	case c.NetworkingNodes != "":
+		m["networking_nodes"] = c.NetworkingNodes
+	case c.TargetProvider == string(GCP):
+		setOptional(m, "networking_nodes", c.WorkerCIDR)
+	default:
+		setOptional(m, "networking_nodes", c.VnetCIDR)
+	}
+	setOptional(m, "seed_name", c.SeedName)
+	setOptional(m, "dns_domain", c.DNSDomain)
+	setOptional(m, "dns_provider", c.DNSProvider)
+	setOptional(m, "addons", c.Addons)
+	setOptional(m, "hibernated", c.Hibernated)
+	return m
+}
+
+// KindConfig is the configuration of a kind cluster.
+type KindConfig struct {
+	ClusterConfig
+	NodeImage string
+}
+
+// Provider returns Kind.
+func (c KindConfig) Provider() ProviderType {
+	return Kind
+}
+
+// ToMap returns the configuration map of the kind cluster.
+func (c KindConfig) ToMap() map[string]interface{} {
+	m := c.ClusterConfig.toMap()
+	m["node_image"] = c.NodeImage
+	return m
+}
+
+// toMap returns a configuration map with the custom values and the identity of the cluster.
+func (c ClusterConfig) toMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(c.Custom)+2)
+	for k, v := range c.Custom {
+		m[k] = v
+	}
+	m["project"] = c.Project
+	m["cluster_name"] = c.ClusterName
+	setOptional(m, "extra_vars", c.ExtraVars)
+	setOptional(m, "additional_providers", c.AdditionalProviders)
+	setOptional(m, "kubeconfig_context_name", c.KubeconfigContextName)
+	return m
+}
+
+// setOptional sets the value of an optional field in the configuration map, unless it is empty.
+func setOptional(m map[string]interface{}, key string, v interface{}) {
+	if rv := reflect.ValueOf(v); rv.IsZero() || (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map) && rv.Len() == 0 {
+		return
+	}
+	m[key] = v
+}
@@ -0,0 +1,294 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrStateNotFound indicates that there is no terraform state for the cluster.
+	ErrStateNotFound = errors.New("cluster state not found")
+	// ErrAuthFailed indicates that the provider rejected the credentials or their permissions are not enough.
+	ErrAuthFailed = errors.New("authentication with the provider failed")
+	// ErrQuotaExceeded indicates that the provider refused the request because a quota was exceeded, such as the CPUs of a region.
+	// It does not go away until resources are freed or the quota is raised, so operations failing with it are not retried.
+	ErrQuotaExceeded = errors.New("provider quota exceeded")
+	// ErrRateLimited indicates that the provider throttled the requests, because they exceeded its rate limit.
+	ErrRateLimited = errors.New("provider rate limit exceeded")
+	// ErrTimeout indicates that the provider did not finish an operation within its timeout.
+	ErrTimeout = errors.New("provider operation timed out")
+	// ErrProviderUnavailable indicates that the provider API failed with a server error.
+	ErrProviderUnavailable = errors.New("provider API unavailable")
+	// ErrResourceNotFound indicates that a resource in the state of the cluster does not exist in the provider anymore.
+	ErrResourceNotFound = errors.New("provider resource not found")
+	// ErrUnsupportedOperation indicates that the provider of the cluster does not support the requested operation.
+	ErrUnsupportedOperation = errors.New("operation not supported by the provider")
+	// ErrLocked indicates that another operation, in this or another process, is working on the same cluster.
+	ErrLocked = errors.New("cluster is locked by another operation")
+	// ErrIdentityMismatch indicates that the state of the cluster belongs to another cluster than the one of the configuration.
+	ErrIdentityMismatch = errors.New("cluster state does not match the configuration")
+	// ErrTerraformNotFound indicates that terraform cannot run the provider of the cluster, because its plugin cannot be installed.
+	// The error explains what to install or configure.
+	ErrTerraformNotFound = errors.New("terraform is not usable")
+	// ErrShuttingDown indicates that the operator does not accept new operations, because it is shutting down.
+	ErrShuttingDown = errors.New("operator is shutting down")
+	// ErrIncompleteState indicates that the state of a cluster lacks outputs needed to access it, even after refreshing it.
+	// The errors are IncompleteStateErrors naming the missing outputs.
+	ErrIncompleteState = errors.New("cluster state is incomplete")
+	// ErrOperationNotFound indicates that there is no background operation with the given handle in the data dir.
+	ErrOperationNotFound = errors.New("operation not found")
+	// ErrNotReady indicates that the API server of a cluster did not serve requests in time, such as after waking up a hibernated shoot.
+	ErrNotReady = errors.New("cluster is not ready")
+	// ErrInvalidMachineType indicates that the provider does not offer a machine type of the configuration in the location of the cluster.
+	// The errors are MachineTypeErrors suggesting the closest machine type offered.
+	ErrInvalidMachineType = errors.New("machine type not offered by the provider")
+	// ErrStateLocked indicates that the state of the cluster in the remote backend is locked by another terraform operation, such as one of another replica.
+	// The errors are StateLockErrors telling who holds the lock.
+	ErrStateLocked = errors.New("cluster state is locked in the backend")
+	// ErrStateVersionMismatch indicates that the state of a cluster was written by a terraform version or in a format the embedded terraform cannot read,
+	// such as by a newer terraform. The errors are StateVersionErrors naming both versions.
+	ErrStateVersionMismatch = errors.New("cluster state version is not supported")
+)
+
+// RecreateError indicates that an operation was refused because it would destroy and recreate resources that must be kept, such as the cluster control plane.
+type RecreateError struct {
+	// Resources lists the addresses of the resources that would be recreated.
+	Resources []string
+	// Reason explains which change needs the resources to be recreated, if it is known.
+	Reason string
+}
+
+func (e *RecreateError) Error() string {
+	msg := fmt.Sprintf("the requested changes would destroy and recreate the following resources: %s", strings.Join(e.Resources, ", "))
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// IncompleteStateError indicates that the state of a cluster lacks outputs needed to access it, such as its endpoint, even after refreshing it.
+// This happens with states written by older versions of the templates. It unwraps to ErrIncompleteState.
+type IncompleteStateError struct {
+	// Outputs lists the names of the missing outputs.
+	Outputs []string
+}
+
+func (e *IncompleteStateError) Error() string {
+	return fmt.Sprintf("%s, the following outputs are missing: %s", ErrIncompleteState, strings.Join(e.Outputs, ", "))
+}
+
+func (e *IncompleteStateError) Unwrap() error {
+	return ErrIncompleteState
+}
+
+// MachineTypeError indicates that the provider does not offer a machine type of the configuration, such as a GCP machine type
+// given to an Azure cluster or a typo. It unwraps to ErrInvalidMachineType.
+type MachineTypeError struct {
+	// Field is the configuration field of the machine type, such as machine_type or node_pools[0].machine_type.
+	Field string
+	// MachineType is the machine type of the configuration.
+	MachineType string
+	// Provider is the provider of the cluster.
+	Provider ProviderType
+	// Location is the location of the cluster the machine types were listed for, empty if the provider offers them everywhere.
+	Location string
+	// Suggestion is the offered machine type closest to the one of the configuration, empty if there is none.
+	Suggestion string
+}
+
+func (e *MachineTypeError) Error() string {
+	msg := fmt.Sprintf("%s: the %s %q is not offered by %s", ErrInvalidMachineType, e.Field, e.MachineType, e.Provider)
+	if e.Location != "" {
+		msg += " in " + e.Location
+	}
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", e.Suggestion)
+	}
+	return msg
+}
+
+func (e *MachineTypeError) Unwrap() error {
+	return ErrInvalidMachineType
+}
+
+// StateLockError indicates that the state of the cluster in the remote backend is locked by another terraform operation.
+// The lock is released when that operation finishes. A lock held by an operation that was killed stays until it is removed from the backend,
+// such as with terraform force-unlock and the ID of the lock. The fields are empty if the backend did not tell. It unwraps to ErrStateLocked.
+type StateLockError struct {
+	// ID is the ID of the lock.
+	ID string
+	// Path is the path of the locked state in the backend.
+	Path string
+	// Operation is the terraform operation holding the lock, such as OperationTypeApply.
+	Operation string
+	// Who is the user and host that hold the lock, as user@host.
+	Who string
+	// Version is the terraform version holding the lock.
+	Version string
+	// Created is when the lock was acquired.
+	Created time.Time
+	// Info is the additional information stored with the lock.
+	Info string
+}
+
+func (e *StateLockError) Error() string {
+	msg := ErrStateLocked.Error()
+	if e.Who != "" {
+		msg += " by " + e.Who
+	}
+	if e.Operation != "" {
+		msg += " for " + e.Operation
+	}
+	if !e.Created.IsZero() {
+		msg += " since " + e.Created.UTC().Format(time.RFC3339)
+	}
+	if e.ID != "" {
+		msg += fmt.Sprintf(", lock ID %s", e.ID)
+	}
+	return msg
+}
+
+func (e *StateLockError) Unwrap() error {
+	return ErrStateLocked
+}
+
+// StateVersionError indicates that the state of a cluster cannot be read by the terraform embedded in Hydroform. States written by newer terraform versions
+// have to be read with a Hydroform embedding at least that version, the states of older versions are upgraded when they are read,
+// except the binary states older than terraform 0.7, which have to be upgraded with terraform 0.6.16 first. It unwraps to ErrStateVersionMismatch.
+type StateVersionError struct {
+	// StateVersion is the terraform version that wrote the state, empty if the state does not tell.
+	StateVersion string
+	// FormatVersion is the version of the format of the state file.
+	FormatVersion uint64
+	// SupportedVersion is the version of the embedded terraform.
+	SupportedVersion string
+}
+
+func (e *StateVersionError) Error() string {
+	writer := "an unknown terraform version"
+	if e.StateVersion != "" {
+		writer = "terraform " + e.StateVersion
+	}
+	return fmt.Sprintf("%s: the state was written by %s in format version %d, the embedded terraform %s cannot read it", ErrStateVersionMismatch, writer, e.FormatVersion, e.SupportedVersion)
+}
+
+func (e *StateVersionError) Unwrap() error {
+	return ErrStateVersionMismatch
+}
+
+// ResourceError indicates that terraform failed to create, update or destroy a resource of the cluster.
+// It unwraps to the error with all diagnostics reported by terraform, so it can still be checked against the error classes.
+type ResourceError struct {
+	// Resource is the terraform address of the failed resource, such as google_container_cluster.gke_cluster.
+	Resource string
+	// Message is the diagnostic terraform reported for the resource, including the error of the provider.
+	Message string
+	// Err contains all errors reported by terraform.
+	Err error
+}
+
+func (e *ResourceError) Error() string {
+	return fmt.Sprintf("resource %s failed: %s", e.Resource, e.Err)
+}
+
+// ResourceAddress returns the terraform address of the failed resource.
+func (e *ResourceError) ResourceAddress() string {
+	return e.Resource
+}
+
+// Diagnostic returns the diagnostic terraform reported for the failed resource.
+func (e *ResourceError) Diagnostic() string {
+	return e.Message
+}
+
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// UnsupportedVersionError indicates that the provider does not offer the requested kubernetes version in the location of the cluster.
+type UnsupportedVersionError struct {
+	// Version is the requested kubernetes version.
+	Version string
+	// Location is the region or zone of the cluster, empty if the versions of the provider do not depend on it.
+	Location string
+	// Supported lists the versions the provider offers.
+	Supported []string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	if e.Location == "" {
+		return fmt.Sprintf("kubernetes version %s is not supported, use one of: %s", e.Version, strings.Join(e.Supported, ", "))
+	}
+	return fmt.Sprintf("kubernetes version %s is not supported in %s, use one of: %s", e.Version, e.Location, strings.Join(e.Supported, ", "))
+}
+
+// ImportError indicates that some resources could not be imported into the state of a cluster.
+type ImportError struct {
+	// Imported lists the addresses of the resources that are now in the state.
+	Imported []string
+	// Failed contains the error of each resource that could not be imported, by address.
+	Failed map[string]error
+}
+
+func (e *ImportError) Error() string {
+	addrs := make([]string, 0, len(e.Failed))
+	for addr := range e.Failed {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	failed := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		failed = append(failed, fmt.Sprintf("%s (%s)", addr, e.Failed[addr]))
+	}
+	return fmt.Sprintf("could not import the following resources: %s; imported resources: [%s]", strings.Join(failed, ", "), strings.Join(e.Imported, ", "))
+}
+
+// CleanupError indicates that some files of a cluster, such as its state or credentials, could not be removed from the file system.
+type CleanupError struct {
+	// Failed contains the error of each file or directory that could not be removed, by path.
+	Failed map[string]error
+}
+
+func (e *CleanupError) Error() string {
+	paths := make([]string, 0, len(e.Failed))
+	for path := range e.Failed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	failed := make([]string, 0, len(paths))
+	for _, path := range paths {
+		failed = append(failed, fmt.Sprintf("%s (%s)", path, e.Failed[path]))
+	}
+	return fmt.Sprintf("could not remove the following files: %s", strings.Join(failed, ", "))
+}
+
+// ValidationError indicates that the configuration of a cluster is incomplete or has values of the wrong type.
+type ValidationError struct {
+	// Fields lists each invalid configuration field.
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return fmt.Sprintf("invalid cluster configuration: %s", strings.Join(msgs, "; "))
+}
+
+// FieldError describes a single missing or invalid configuration field.
+type FieldError struct {
+	// Field is the name of the configuration key.
+	Field string
+	// Reason explains why the field is invalid.
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
@@ -1,6 +1,12 @@
 package types
 
-import "time"
+import (
+	"io"
+	"io/fs"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
 
 // Options contains all possible configuration options for Hydroform.
 // Options need to be set each time a Hydroform function is called
@@ -9,13 +15,160 @@ type Options struct {
 	Persistent bool
 	Timeouts   *Timeouts
 	Verbose    bool // Print terraform log for debugging
+	Backend    *BackendConfig
+	Logger     Logger
+	Output     io.Writer             // Receive the raw output of terraform, regardless of Verbose
+	Progress   func(ProvisionEvent)  // Receive the progress events of the running operations
+	Report     func(OperationReport) // Receive the report of each provisioning and deprovisioning
+	Metrics    MetricsRecorder       // Record the duration and the errors of the operations
+	Retry      *Retry
+	// ForceDelete makes deprovisioning succeed and drop the cluster state when the cluster resources do not exist anymore
+	ForceDelete bool
+	// AllowIdentityMismatch lets deprovisioning destroy a cluster whose state does not match the configuration
+	AllowIdentityMismatch bool
+	// TerraformVersion is a version constraint, such as "~> 0.12.0", that the terraform used by Hydroform has to satisfy
+	TerraformVersion string
+	// Credentials are the in-memory credentials of each provider, used instead of the credentials files and the environment
+	Credentials map[ProviderType]Credentials
+	// InMemoryState keeps the cluster states in the memory of the process instead of the data dir
+	InMemoryState bool
+	// StateEncryptionKey encrypts the state files in the data dir with AES-GCM, it must have 16, 24 or 32 bytes
+	StateEncryptionKey []byte
+	// Templates are the terraform templates of each provider supplied by the caller, used instead of the built-in ones
+	Templates map[ProviderType]fs.FS
+	// PathStrategy places the files of each cluster in the data dir instead of the default clusters/<provider>/<project>/<cluster> layout
+	PathStrategy PathStrategy
+	// UseWorkspace stores the cluster states in terraform workspaces of the backend, Workspace is their name or empty to derive it from the project and the cluster name
+	UseWorkspace bool
+	Workspace    string
+	// PluginCacheDir is the directory where terraform caches the provider plugins, so they are downloaded once for all clusters and runs
+	PluginCacheDir string
+	// Parallelism is the number of resources terraform creates, updates or deletes at the same time, ProviderParallelism overrides it for each provider.
+	// Zero keeps the default of terraform, 10, others must be at least 1
+	Parallelism         int
+	ProviderParallelism map[ProviderType]int
+	// Proxy is the proxy of the outbound traffic of the operations, used instead of the proxy environment variables of the process
+	Proxy *Proxy
+	// OperationTimeout limits the time of each whole operation, including the init of terraform and its retries. Zero means no limit
+	OperationTimeout time.Duration
+	// SecretCredentials is the Kubernetes Secret with the credentials of the provider, read by each operation instead of using Credentials
+	SecretCredentials *SecretCredentials
+	// SkipGardenerInit indicates that the Gardener provider plugin is installed by the caller, so the operations do not install it
+	SkipGardenerInit bool
+	// VarFiles are the terraform variable files passed to the operations, their variables override the defaults of the template
+	// and are overridden by the ExtraVars and the configuration
+	VarFiles []string
+	// WakeUpReadyTimeout limits the wait for the API server of a shoot to serve requests after WakeUp. Zero means 10 minutes
+	WakeUpReadyTimeout time.Duration
+	// TargetedUpdate limits the apply of Update to the resources whose configuration changed and their dependencies
+	TargetedUpdate bool
+	// ProviderVersions are the version constraints of the terraform provider plugins, by provider name such as google
+	ProviderVersions map[string]string
+	// Sandbox runs each operation of the Persistent mode in a new temporary dir, only its state and a success marker are copied to the data dir.
+	// The state of a failed operation is copied back as well, it holds the resources created before the failure which could not be deleted or continued without it.
+	// KeepFailedSandbox keeps the temporary dir of the failed operations to debug them
+	Sandbox           bool
+	KeepFailedSandbox bool
+	// TerraformLogLevel is the TF_LOG level of the provider plugins, one of TRACE, DEBUG, INFO, WARN or ERROR, their logs are sent to the Logger
+	TerraformLogLevel string
+	// BatchWorkers is the number of clusters CreateBatch and DeleteBatch provision or deprovision at the same time, 4 if zero
+	BatchWorkers int
 }
 
+// PathStrategy returns the directory of the files of a cluster, including its state when it is kept in the data dir.
+// The directory must be unique for each cluster and stay the same across operations, or Hydroform loses track of the cluster.
+type PathStrategy func(dataDir, project, cluster string, p ProviderType) string
+
 // Timeouts specifies timeouts on various operation
+// Each timeout that is set bounds its operation, and is the timeout of the resources terraform creates, updates or deletes.
+// Operations without a timeout have no deadline, and their resources get the default timeouts of Hydroform.
 type Timeouts struct {
 	Create time.Duration
 	Update time.Duration
 	Delete time.Duration
+	// Default is used for the operations above without their own timeout.
+	Default time.Duration
+}
+
+// Retry specifies how operations failing with transient provider errors are retried
+type Retry struct {
+	// MaxAttempts is the maximum number of times an operation is run, including the first one.
+	MaxAttempts int
+	// Backoff is the time to wait before the first retry. It doubles after each attempt.
+	Backoff time.Duration
+}
+
+// Credentials contain the secrets to authenticate on a provider, for callers that hold them in memory, such as in a secret store.
+type Credentials struct {
+	// File is the content of the credentials file of the provider:
+	// the service account key for GCP, the shared credentials file for AWS or the kubeconfig of the Gardener project.
+	File []byte
+	// Values are credentials set directly in the provider configuration:
+	// subscription_id, tenant_id, client_id and client_secret for Azure, user_name and password for OpenStack, token for DigitalOcean, or access_key and secret_key for AliCloud.
+	Values map[string]string
+}
+
+// SecretCredentials describe a Kubernetes Secret holding the credentials of a provider, such as the secrets of the controllers running Hydroform.
+type SecretCredentials struct {
+	// Client reads the secret.
+	Client kubernetes.Interface
+	// Namespace and Name identify the secret.
+	Namespace string
+	Name      string
+	// KeyMapping maps the keys of the secret to the credentials of the provider: credentials_file_path for the content of the credentials file,
+	// or the name of a value of the provider configuration, such as client_secret. The other keys of the secret are ignored.
+	// If nil, the keys of the secret are the names of the credentials.
+	KeyMapping map[string]string
+}
+
+// Proxy describes the proxies used to reach the providers, in the format of the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+type Proxy struct {
+	// HTTPSProxy is the proxy of the HTTPS requests, such as "http://proxy.example.com:3128" or "socks5://proxy.example.com:1080".
+	HTTPSProxy string
+	// HTTPProxy is the proxy of the HTTP requests.
+	HTTPProxy string
+	// NoProxy is a comma-separated list of the hosts, domains and CIDR blocks reached directly, such as "localhost,.internal,10.0.0.0/8".
+	NoProxy string
+}
+
+// BackendConfig describes a remote terraform backend to store the cluster state in instead of the local file system.
+type BackendConfig struct {
+	// Type is the terraform backend type. Supported types are "s3", "gcs" and "azurerm".
+	Type string
+	// Bucket is the bucket holding the state. For azurerm it is the name of the storage container.
+	Bucket string
+	// Prefix is prepended to the path of each cluster state inside the bucket.
+	Prefix string
+	// Region is the region of the bucket. It is only used by the s3 backend.
+	Region string
+	// Credentials is the path to the credentials file for s3 and gcs, or the storage account access key for azurerm.
+	// If empty, the backend uses the credentials available in the environment. The access key is not written into the cluster directory, and is removed from the terraform data dir of the cluster once the operation finished.
+	Credentials string
+	// Config contains any additional backend specific setting, such as the storage_account_name for azurerm.
+	Config map[string]string
+	// LockTable is the DynamoDB table locking the states of the s3 backend, with a string hash key named LockID.
+	// Without it, the s3 backend does not lock the states. The gcs and azurerm backends always lock the states, with a lock file and a blob lease.
+	LockTable string
+	// LockTimeout is how long the operations wait for the lock of the state held by another operation, such as one of another replica,
+	// before failing with ErrStateLocked. If zero, they fail right away.
+	LockTimeout time.Duration
+}
+
+// Logger receives the output of the terraform commands run by Hydroform. A *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// MetricsRecorder receives the metrics of the operations run by Hydroform, such as to expose them to Prometheus.
+// The operations are "create", "status" and "delete". Errors are counted by kind, one of "validation", "locked", "identity_mismatch", "auth", "rate_limit",
+// "quota", "timeout", "provider_unavailable", "resource_not_found", "state_not_found", "unsupported_version", "unsupported_operation",
+// "terraform_not_found", "incomplete_state", "not_ready", "invalid_machine_type", "state_locked", "state_version_mismatch", "cleanup", "canceled" or "other",
+// so the labels stay the same for all providers.
+type MetricsRecorder interface {
+	// ObserveDuration is called once each operation finishes, whether it succeeded or not.
+	ObserveDuration(op string, p ProviderType, d time.Duration)
+	// IncError is called when an operation fails.
+	IncError(op string, p ProviderType, kind string)
 }
 
 // Option is a function that allows to extensibly configure Hydroform.
@@ -36,14 +189,321 @@ func WithDataDir(dir string) Option {
 	}
 }
 
+// Make deprovisioning succeed when the cluster was already deleted outside of Hydroform, removing its state.
+// By default deprovisioning fails if terraform cannot find the cluster resources.
+func ForceDelete() Option {
+	return func(ops *Options) {
+		ops.ForceDelete = true
+	}
+}
+
+// Let deprovisioning destroy the cluster of the state even if its project, name or ID differ from the configuration.
+// By default deprovisioning fails with ErrIdentityMismatch, so a state of another cluster is never destroyed by mistake.
+// The ID is compared with the one recorded in the data dir when the cluster was created, and with the cluster_id of the configuration if set.
+func AllowIdentityMismatch() Option {
+	return func(ops *Options) {
+		ops.AllowIdentityMismatch = true
+	}
+}
+
+// Keep the cluster states in memory instead of writing them to the data dir, for stateless services.
+// Terraform still needs the state of a cluster in a file while it runs, the file is removed as soon as each operation finishes.
+// The states are kept by the operator, by the directory of their cluster, and shared with the operations of its batches and background operations.
+// Other operators do not see them, and they are lost when the process exits, the clusters returned by provisioning still hold their state.
+// It has no effect with a backend, which never stores the states on disk. It cannot be used with the Sandbox option, which moves the cluster directory.
+func WithInMemoryState() Option {
+	return func(ops *Options) {
+		ops.InMemoryState = true
+	}
+}
+
+// Encrypt the state files kept in the data dir with the given AES key of 16, 24 or 32 bytes, so the secrets in the states are not stored in plaintext.
+// Terraform still needs the state of a cluster in plaintext while it runs, the file is encrypted again as soon as each terraform command finishes.
+// The plans saved during an operation contain the state as well, they are removed once it finishes.
+// States written without encryption are still read, and encrypted by the next operation on their cluster.
+// It has no effect with a backend, which never keeps the states in the data dir. With the in-memory state, only the files terraform gets are encrypted.
+func WithStateEncryption(key []byte) Option {
+	return func(ops *Options) {
+		ops.StateEncryptionKey = key
+	}
+}
+
 func WithTimeouts(timeouts *Timeouts) Option {
 	return func(ops *Options) {
 		ops.Timeouts = timeouts
 	}
 }
 
+// Set the same timeout on the create, update and delete operations.
+func WithTimeout(timeout time.Duration) Option {
+	return func(ops *Options) {
+		ops.Timeouts = &Timeouts{Default: timeout}
+	}
+}
+
 func Verbose(verbose bool) Option {
 	return func(ops *Options) {
 		ops.Verbose = verbose
 	}
 }
+
+// Retry operations failing with transient provider errors, such as rate limits or server errors, up to maxAttempts times.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(ops *Options) {
+		ops.Retry = &Retry{MaxAttempts: maxAttempts, Backoff: backoff}
+	}
+}
+
+// Store the cluster state in a remote terraform backend instead of the local file system.
+// Terraform keeps the configuration of the backend in its data dir, so each cluster gets a terraform data dir of its own in its directory,
+// and the operations on different clusters run at the same time. The providers are installed from the plugin cache, see WithPluginCacheDir, or without one from a plugin-cache dir in the data dir.
+func WithBackend(backend BackendConfig) Option {
+	return func(ops *Options) {
+		ops.Backend = &backend
+	}
+}
+
+// Send the output of terraform to the given logger. By default it is discarded.
+// Without Verbose, the log entries terraform writes to the standard logger while its commands run are dropped as well, the entries of the other packages,
+// such as the ones of a Logger writing to the standard logger, are kept.
+func WithLogger(l Logger) Option {
+	return func(ops *Options) {
+		ops.Logger = l
+	}
+}
+
+// Write the raw output of terraform to the given writer, such as a buffer, a file or a log aggregator.
+// Unlike Verbose, it only affects the operations run with these options.
+// The logs of the provider plugins are not included, they are only printed to the standard error with Verbose.
+func WithOutputWriter(w io.Writer) Option {
+	return func(ops *Options) {
+		ops.Output = w
+	}
+}
+
+// Receive the progress of each operation while it runs.
+func WithProgressHandler(handler func(ProvisionEvent)) Option {
+	return func(ops *Options) {
+		ops.Progress = handler
+	}
+}
+
+// Store the state of the clusters in the given terraform workspace of the backend, the way terraform automation sharing a configuration isolates its states.
+// If the name is empty, each cluster gets a workspace named after its project and cluster name, which is created if it does not exist.
+// Workspaces need a backend, without one each cluster already has its own state file.
+// The selected workspace is kept in the terraform data dir of the cluster, see WithBackend.
+// The derived names end with a digest of the project and the cluster name, so two clusters never share a workspace.
+func WithWorkspace(name string) Option {
+	return func(ops *Options) {
+		ops.UseWorkspace = true
+		ops.Workspace = name
+	}
+}
+
+// Record the duration and the errors of the provisioning, status and deprovisioning operations with the given recorder.
+func WithMetrics(m MetricsRecorder) Option {
+	return func(ops *Options) {
+		ops.Metrics = m
+	}
+}
+
+// Receive a report of each provisioning and deprovisioning once it finishes, with the duration of its phases and the number of resources it changed.
+// The report is also sent when the operation fails, with the phases run until the failure.
+func WithReportHandler(handler func(OperationReport)) Option {
+	return func(ops *Options) {
+		ops.Report = handler
+	}
+}
+
+// Require the terraform used by Hydroform to satisfy the given version constraint, such as "~> 0.12.0".
+// Operations fail before running terraform if it does not.
+func WithTerraformVersion(constraint string) Option {
+	return func(ops *Options) {
+		ops.TerraformVersion = constraint
+	}
+}
+
+// Authenticate on the given provider with credentials held in memory instead of the credentials file path and the environment.
+// The credentials are only visible to the operations run with these options. They are passed to terraform in a private temporary vars file
+// removed once the operation finishes, the terraform.tfvars file of the cluster never holds them.
+func WithCredentials(p ProviderType, creds Credentials) Option {
+	return func(ops *Options) {
+		if ops.Credentials == nil {
+			ops.Credentials = make(map[ProviderType]Credentials)
+		}
+		ops.Credentials[p] = creds
+	}
+}
+
+// Provision the clusters of the given provider with the terraform files of fsys instead of the built-in template.
+// Hydroform still manages the state, initialization, apply and outputs of the cluster. The files are copied into the cluster directory,
+// where a terraform.tfvars file sets a variable for each configuration value that is a string, number, duration or list of strings:
+// project, cluster_name, create_timeout, update_timeout, delete_timeout, the values the provisioner derives from the Cluster and Provider,
+// such as location, node_count, machine_type, disk_size, kubernetes_version and credentials_file_path, and the custom configurations.
+// The template must declare a variable for each of them, terraform warns about undeclared ones.
+// Only the project and cluster_name are validated, the template decides which other values it needs.
+// The template should output the "endpoint" of the cluster and its base64 encoded "cluster_ca_certificate", or a "kubeconfig".
+// All outputs are returned in the ClusterInfo.
+func WithTemplate(p ProviderType, fsys fs.FS) Option {
+	return func(ops *Options) {
+		if ops.Templates == nil {
+			ops.Templates = make(map[ProviderType]fs.FS)
+		}
+		ops.Templates[p] = fsys
+	}
+}
+
+// Cache the provider plugins terraform downloads in the given directory, so each provider version is only downloaded once and reused by all clusters and runs.
+// The directory can be shared by several processes, the operations install the plugins into it one at a time.
+func WithPluginCacheDir(dir string) Option {
+	return func(ops *Options) {
+		ops.PluginCacheDir = dir
+	}
+}
+
+// Place the files of each cluster in the directory returned by fn, such as one including a tenant ID, when the project and cluster names are not unique.
+// All operations on a cluster must use the same strategy. Listing the clusters only works with the default layout.
+func WithPathStrategy(fn PathStrategy) Option {
+	return func(ops *Options) {
+		ops.PathStrategy = fn
+	}
+}
+
+// Limit the number of resources terraform creates, updates or deletes at the same time in apply and destroy, which is 10 by default.
+// A higher parallelism speeds up clusters with many resources, a lower one avoids the rate limits of the provider APIs.
+// It must be at least 1, the operations fail before running terraform otherwise. WithProviderParallelism overrides it for a provider.
+func WithParallelism(n int) Option {
+	return func(ops *Options) {
+		ops.Parallelism = n
+	}
+}
+
+// Limit the number of resources terraform changes at the same time on the given provider, such as a low one for Gardener,
+// so the garden cluster is not overwhelmed, while other providers keep the parallelism of WithParallelism or the default of terraform.
+// It must be at least 1.
+func WithProviderParallelism(p ProviderType, n int) Option {
+	return func(ops *Options) {
+		if ops.ProviderParallelism == nil {
+			ops.ProviderParallelism = make(map[ProviderType]int)
+		}
+		ops.ProviderParallelism[p] = n
+	}
+}
+
+// Send the outbound traffic of the operations through the given proxies, such as a corporate proxy, instead of the ones of the environment of the process.
+// The proxies are set in the environment of the provider plugins the operations start and used by the requests Hydroform sends to the providers itself,
+// the environment of the process is not changed, the plugins of providers configured with aliases included.
+// Terraform itself still downloads the providers and the modules of init with the proxy of the process environment.
+func WithProxy(httpsProxy, httpProxy, noProxy string) Option {
+	return func(ops *Options) {
+		ops.Proxy = &Proxy{HTTPSProxy: httpsProxy, HTTPProxy: httpProxy, NoProxy: noProxy}
+	}
+}
+
+// Limit the time of each whole operation, such as a Create or a Delete, to the given duration, regardless of the Timeouts of the cluster resources,
+// which only apply while the provider changes a resource. Once it expires, terraform is stopped gracefully, so it saves the state of the resources
+// changed so far and a retry continues from it. If terraform does not stop in time, the provider plugins the operation started are killed,
+// and the operation still waits for terraform to save the state before the cluster is unlocked. The operation then fails with ErrTimeout.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(ops *Options) {
+		ops.OperationTimeout = d
+	}
+}
+
+// Authenticate on the provider of each operation with the credentials in the given Kubernetes Secret, instead of the Credentials of the provider.
+// The secret is read at the start of each operation, so rotated credentials are used by the next operation, and its values only end up
+// in the configuration of the operation and in private temporary files removed once the operation finishes, as for WithCredentials.
+// The key mapping maps the keys of the secret to the credentials_file_path, for the content of the credentials file,
+// or to the names of the credential values, such as client_id and client_secret for Azure. If it is nil, the keys are used as they are.
+func WithKubernetesSecretCredentials(client kubernetes.Interface, namespace, name string, keyMapping map[string]string) Option {
+	return func(ops *Options) {
+		ops.SecretCredentials = &SecretCredentials{Client: client, Namespace: namespace, Name: name, KeyMapping: keyMapping}
+	}
+}
+
+// Skip the installation of the Gardener provider plugin, for applications that install it themselves, such as to ship a patched plugin.
+// By default, the first Gardener operation of the process downloads the plugin into the terraform plugins directory of the user.
+// With this option the caller must install it before the first operation: the plugin must be named terraform-provider-gardener_v0.0.10
+// and be in the plugins directory of the user, ~/.terraform.d/plugins/<os>_<arch>. Otherwise the operations fail in the init of terraform.
+// The init of the Gardener operations does not verify the plugin in either case, since it is not signed.
+func WithSkipGardenerInit() Option {
+	return func(ops *Options) {
+		ops.SkipGardenerInit = true
+	}
+}
+
+// Pass the given terraform variable files, .tfvars or .tfvars.json, to the operations, such as the files of an environment shared with standalone terraform.
+// The variables are resolved in this order, each overriding the previous ones:
+// the defaults of the template variables, the variable files in the given order, the ExtraVars of the configuration, and the configuration itself,
+// including the values Hydroform derives from it. A variable file can thus set the variables the configuration leaves out, but not change the ones it sets.
+// The operations fail before running terraform if a file does not exist.
+func WithVarFiles(paths ...string) Option {
+	return func(ops *Options) {
+		ops.VarFiles = append(ops.VarFiles, paths...)
+	}
+}
+
+// Limit the wait for the API server of a Gardener shoot to serve requests once WakeUp reconciled it, 10 minutes by default.
+// The shoot reports reconciled before its API server is reachable, WakeUp polls it with the kubeconfig of the shoot
+// and fails with ErrNotReady if it does not serve requests within the timeout.
+func WithWakeUpReadyTimeout(d time.Duration) Option {
+	return func(ops *Options) {
+		ops.WakeUpReadyTimeout = d
+	}
+}
+
+// Apply only the resources whose configuration changed in Update, such as the node pool that changed among several,
+// instead of all the changes of the plan. Terraform also applies the resources they depend on, while the drift of the other resources is left
+// for the next full apply. Update falls back to a full apply if the targeted apply would differ from the full one for the changed resources,
+// or would leave behind changed resources depending on them.
+func WithTargetedUpdate() Option {
+	return func(ops *Options) {
+		ops.TargetedUpdate = true
+	}
+}
+
+// Constrain the versions of a terraform provider plugin, such as "~> 3.5" for google, so an upgrade of the plugins cannot change
+// the resources of the clusters unnoticed. The constraint is rendered into the required_providers of the cluster files: terraform init
+// installs a version that satisfies it, and the operations fail with ErrTerraformNotFound if no version does.
+// Invalid constraints fail the operations before terraform runs.
+func WithProviderVersion(provider, constraint string) Option {
+	return func(ops *Options) {
+		if ops.ProviderVersions == nil {
+			ops.ProviderVersions = make(map[string]string)
+		}
+		ops.ProviderVersions[provider] = constraint
+	}
+}
+
+// Run each operation of the Persistent mode in a new temporary dir, so a failed run cannot leave files behind that change the next ones,
+// such as a half downloaded module or a stale plan. The temporary dir gets the state of the cluster from the data dir, and the state is copied back
+// once the operation finishes, also when it fails, so the resources created so far are not lost. The other files stay in the temporary dir,
+// the data dir of each cluster only keeps its state and the hydroform.success marker with the time of its last successful operation.
+// The provider plugins are installed from the plugin cache, see WithPluginCacheDir, or without one from a plugin-cache dir in the data dir. With keepFailed, the temporary dir of a failed operation
+// is kept to debug it and its path is added to the error, it has to be removed by the caller.
+func WithSandbox(keepFailed bool) Option {
+	return func(ops *Options) {
+		ops.Sandbox = true
+		ops.KeepFailedSandbox = keepFailed
+	}
+}
+
+// Set the log level of the terraform provider plugins, one of TRACE, DEBUG, INFO, WARN or ERROR, to debug them without the noise of Verbose.
+// The plugins the operations start get the level in their TF_LOG environment variable, and their log entries are sent to the Logger with their own level.
+// The environment of the process is not changed, so operations with different levels run at the same time. The plugins of providers configured
+// with aliases get the level and send their log to the Logger as well. The log of terraform itself is not changed by the level, see WithLogger.
+// Invalid levels fail the operations before terraform runs.
+func WithTerraformLogLevel(level string) Option {
+	return func(ops *Options) {
+		ops.TerraformLogLevel = level
+	}
+}
+
+// Provision or deprovision up to n clusters at the same time in CreateBatch and DeleteBatch, which is 4 by default.
+// Each cluster runs as a single operation, in its own directory and with its own lock, the plugin downloads of the clusters are still serialized
+// by the lock of the plugin cache. A higher number speeds up large batches as long as the quotas and the rate limits of the provider allow it.
+func WithBatchWorkers(n int) Option {
+	return func(ops *Options) {
+		ops.BatchWorkers = n
+	}
+}
@@ -0,0 +1,49 @@
+package types
+
+// PreflightStatus is the outcome of a check of Preflight.
+type PreflightStatus string
+
+const (
+	// PreflightPassed indicates that the check succeeded.
+	PreflightPassed PreflightStatus = "Passed"
+	// PreflightFailed indicates that the check failed, provisioning the cluster would fail too.
+	PreflightFailed PreflightStatus = "Failed"
+	// PreflightSkipped indicates that the check was not run, because an earlier check failed or the provider does not support it.
+	PreflightSkipped PreflightStatus = "Skipped"
+)
+
+// names of the checks of Preflight, in the order they run
+const (
+	// CredentialsCheck authenticates to the provider with the credentials of the configuration.
+	CredentialsCheck = "credentials"
+	// LocationCheck verifies that the region or zone of the configuration exists and offers the machine types of the nodes.
+	LocationCheck = "location"
+	// CPUQuotaCheck verifies that the quota of the location has room for the CPUs of all nodes.
+	CPUQuotaCheck = "cpu_quota"
+	// IPAddressQuotaCheck verifies that the quota of the location has room for the IP addresses of all nodes.
+	IPAddressQuotaCheck = "ip_address_quota"
+)
+
+// PreflightResult lists the checks run before provisioning a cluster, in the order they ran.
+type PreflightResult struct {
+	Checks []PreflightCheck `json:"checks"`
+}
+
+// Passed reports whether no check failed.
+func (r *PreflightResult) Passed() bool {
+	for _, c := range r.Checks {
+		if c.Status == PreflightFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// PreflightCheck is the outcome of a check run before provisioning a cluster.
+type PreflightCheck struct {
+	// Name identifies the check, such as CredentialsCheck.
+	Name   string          `json:"name"`
+	Status PreflightStatus `json:"status"`
+	// Message explains the outcome, such as the quota available or why the check failed.
+	Message string `json:"message"`
+}
@@ -0,0 +1,108 @@
+package types
+
+import "time"
+
+// ProvisionPhase indicates the step of an operation a ProvisionEvent belongs to.
+type ProvisionPhase string
+
+const (
+	// InitPhase is the initialization of terraform, downloading modules and providers.
+	InitPhase ProvisionPhase = "Init"
+	// PlanPhase is the calculation of the changes to perform on the cluster resources.
+	PlanPhase ProvisionPhase = "Plan"
+	// ApplyPhase is the creation or update of the cluster resources.
+	ApplyPhase ProvisionPhase = "Apply"
+	// ImportPhase is the import of existing resources into the cluster state.
+	ImportPhase ProvisionPhase = "Import"
+	// DestroyPhase is the removal of the cluster resources.
+	DestroyPhase ProvisionPhase = "Destroy"
+	// RefreshPhase is the update of the cluster state with the current attributes of its resources.
+	RefreshPhase ProvisionPhase = "Refresh"
+	// OutputPhase is the reading of the cluster information from the outputs of the state.
+	OutputPhase ProvisionPhase = "Output"
+)
+
+// ProvisionEvent reports the progress of a running operation.
+type ProvisionEvent struct {
+	// Phase is the step of the operation the event belongs to.
+	Phase ProvisionPhase `json:"phase"`
+	// Message is the progress message as output by terraform.
+	Message string `json:"message"`
+	// Resource is the address of the resource the message refers to, if any.
+	Resource string `json:"resource,omitempty"`
+}
+
+// OperationReport describes how an operation went, such as for the metrics of the provisioning.
+// It is sent when the operation finishes, the phases run until a failure are included.
+type OperationReport struct {
+	// Operation is the operation that ran, "create" or "delete".
+	Operation string `json:"operation"`
+	// Provider is the provider of the cluster.
+	Provider ProviderType `json:"provider"`
+	// TerraformVersion is the version of the terraform that ran the operation.
+	TerraformVersion string `json:"terraformVersion"`
+	// Start is the time the operation started.
+	Start time.Time `json:"start"`
+	// Duration is the time the whole operation took, including the preparation and cleanup outside of its phases.
+	Duration time.Duration `json:"duration"`
+	// Phases lists the phases that ran, in order.
+	Phases []PhaseReport `json:"phases"`
+	// ResourcesBefore is the number of resources in the state of the cluster before the operation changed it.
+	ResourcesBefore int `json:"resourcesBefore"`
+	// ResourcesAfter is the number of resources in the state of the cluster once the operation changed it.
+	ResourcesAfter int `json:"resourcesAfter"`
+	// Error is the error the operation failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// PhaseReport describes a phase of an operation.
+type PhaseReport struct {
+	// Phase is the step of the operation.
+	Phase ProvisionPhase `json:"phase"`
+	// Duration is the time the phase took, including retries.
+	Duration time.Duration `json:"duration"`
+	// Failed indicates that the operation failed in this phase.
+	Failed bool `json:"failed,omitempty"`
+}
+
+// OperationHandle identifies an operation running in the background, such as a deletion started with DeleteAsync.
+// It can be persisted, such as in a database, to keep polling the operation after a restart: its state is kept in the data dir.
+type OperationHandle struct {
+	// ID identifies the operation among all operations in the data dir.
+	ID string `json:"id"`
+	// Operation is the operation that runs, "delete".
+	Operation string `json:"operation"`
+	// Provider, Project and Cluster identify the cluster of the operation.
+	Provider ProviderType `json:"provider"`
+	Project  string       `json:"project"`
+	Cluster  string       `json:"cluster"`
+}
+
+// OperationPhase indicates whether an operation running in the background is finished.
+type OperationPhase string
+
+const (
+	// OperationRunning indicates that the operation is still running.
+	OperationRunning OperationPhase = "Running"
+	// OperationSucceeded indicates that the operation finished without errors.
+	OperationSucceeded OperationPhase = "Succeeded"
+	// OperationFailed indicates that the operation failed or was interrupted, such as by a restart of the process running it.
+	OperationFailed OperationPhase = "Failed"
+)
+
+// OperationState describes the progress of an operation running in the background.
+type OperationState struct {
+	// Handle identifies the operation.
+	Handle OperationHandle `json:"handle"`
+	// Phase indicates whether the operation is finished and how.
+	Phase OperationPhase `json:"phase"`
+	// Step is the step of the operation the last progress event belonged to, such as the destroy.
+	Step ProvisionPhase `json:"step,omitempty"`
+	// Message is the last progress message of terraform.
+	Message string `json:"message,omitempty"`
+	// Start is the time the operation started, End the time it finished.
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+	// Error is the error the operation failed with.
+	Error string `json:"error,omitempty"`
+}
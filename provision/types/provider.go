@@ -27,4 +27,10 @@ const (
 	Gardener ProviderType = "gardener"
 	// Kind stands for the kind (kubernetes in docker) platform.
 	Kind ProviderType = "kind"
+	// OpenStack stands for OpenStack clouds running the Magnum container infrastructure service.
+	OpenStack ProviderType = "openstack"
+	// DigitalOcean stands for the DigitalOcean Kubernetes service.
+	DigitalOcean ProviderType = "digitalocean"
+	// AliCloud stands for the Alibaba Cloud Container Service for Kubernetes (ACK).
+	AliCloud ProviderType = "alicloud"
 )